@@ -23,6 +23,7 @@ package client
 import (
 	"context"
 	"net/url"
+	"time"
 
 	"github.com/loadimpact/k6/api/v1"
 )
@@ -32,3 +33,17 @@ var MetricsURL = &url.URL{Path: "/v1/metrics"}
 func (c *Client) Metrics(ctx context.Context) (ret []v1.Metric, err error) {
 	return ret, c.call(ctx, "GET", MetricsURL, nil, &ret)
 }
+
+// LiveMetrics returns the metrics with the tags of a submetric's conditions, like `status:200`, or
+// all of them if there are none, with their recent values over a window.
+func (c *Client) LiveMetrics(ctx context.Context, tags string, window time.Duration) (ret []v1.Metric, err error) {
+	query := url.Values{}
+	if tags != "" {
+		query.Set("tags", tags)
+	}
+	if window > 0 {
+		query.Set("window", window.String())
+	}
+	u := &url.URL{Path: MetricsURL.Path, RawQuery: query.Encode()}
+	return ret, c.call(ctx, "GET", u, nil, &ret)
+}
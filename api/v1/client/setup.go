@@ -0,0 +1,50 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/loadimpact/k6/api/v1"
+)
+
+var (
+	SetupURL    = &url.URL{Path: "/v1/setup"}
+	TeardownURL = &url.URL{Path: "/v1/teardown"}
+)
+
+func (c *Client) SetupData(ctx context.Context) (ret v1.SetupData, err error) {
+	return ret, c.call(ctx, "GET", SetupURL, nil, &ret)
+}
+
+func (c *Client) SetSetupData(ctx context.Context, data json.RawMessage) (ret v1.SetupData, err error) {
+	return ret, c.call(ctx, "PUT", SetupURL, v1.NewSetupData(data), &ret)
+}
+
+func (c *Client) RunSetup(ctx context.Context) (ret v1.SetupData, err error) {
+	return ret, c.call(ctx, "POST", SetupURL, nil, &ret)
+}
+
+func (c *Client) RunTeardown(ctx context.Context) (ret v1.SetupData, err error) {
+	return ret, c.call(ctx, "POST", TeardownURL, nil, &ret)
+}
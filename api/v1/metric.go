@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/loadimpact/k6/core"
 	"github.com/loadimpact/k6/stats"
 	"gopkg.in/guregu/null.v3"
 )
@@ -79,6 +80,9 @@ type Metric struct {
 	Tainted  null.Bool      `json:"tainted" yaml:"tainted"`
 
 	Sample map[string]float64 `json:"sample" yaml:"sample"`
+
+	// What the metric has had in the window of the request, null if it had no samples in it.
+	Recent map[string]float64 `json:"recent" yaml:"recent"`
 }
 
 func NewMetric(m *stats.Metric, t time.Duration) Metric {
@@ -91,6 +95,17 @@ func NewMetric(m *stats.Metric, t time.Duration) Metric {
 	}
 }
 
+func NewLiveMetric(m core.LiveMetric) Metric {
+	return Metric{
+		Name:     m.Name,
+		Type:     NullMetricType{m.Type, true},
+		Contains: NullValueType{m.Contains, true},
+		Tainted:  m.Tainted,
+		Sample:   m.Sample,
+		Recent:   m.Recent,
+	}
+}
+
 func (m Metric) GetID() string {
 	return m.Name
 }
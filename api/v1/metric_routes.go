@@ -21,25 +21,62 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/loadimpact/k6/api/common"
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/stats"
 	"github.com/manyminds/api2go/jsonapi"
 )
 
+// DefaultMetricsWindow is the window of the recent values of metrics, if a request doesn't say.
+const DefaultMetricsWindow = 10 * time.Second
+
+// getLiveMetrics returns the metrics a request asks for: with the tags of its `tags` parameter,
+// which has the conditions of a submetric, like `status:200,name!=login`, and the recent values
+// over the `window` parameter, like `30s`.
+func getLiveMetrics(engine *core.Engine, r *http.Request) ([]core.LiveMetric, error) {
+	query := r.URL.Query()
+
+	var filter *stats.Submetric
+	if tags := query.Get("tags"); tags != "" {
+		_, sm, err := stats.NewSubmetric("{" + tags + "}")
+		if err != nil {
+			return nil, err
+		}
+		filter = sm
+	}
+
+	window := DefaultMetricsWindow
+	if s := query.Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		if d < time.Second || d > core.LiveMetricsWindow {
+			return nil, fmt.Errorf("window must be between 1s and %s", core.LiveMetricsWindow)
+		}
+		window = d
+	}
+
+	return engine.GetLiveMetrics(filter, window), nil
+}
+
 func HandleGetMetrics(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	engine := common.GetEngine(r.Context())
 
-	var t time.Duration
-	if engine.Executor != nil {
-		t = engine.Executor.GetTime()
+	live, err := getLiveMetrics(engine, r)
+	if err != nil {
+		apiError(rw, "Invalid query", err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	metrics := make([]Metric, 0)
-	for _, m := range engine.Metrics {
-		metrics = append(metrics, NewMetric(m, t))
+	metrics := make([]Metric, 0, len(live))
+	for _, m := range live {
+		metrics = append(metrics, NewLiveMetric(m))
 	}
 
 	data, err := jsonapi.Marshal(metrics)
@@ -54,16 +91,17 @@ func HandleGetMetric(rw http.ResponseWriter, r *http.Request, p httprouter.Param
 	id := p.ByName("id")
 	engine := common.GetEngine(r.Context())
 
-	var t time.Duration
-	if engine.Executor != nil {
-		t = engine.Executor.GetTime()
+	live, err := getLiveMetrics(engine, r)
+	if err != nil {
+		apiError(rw, "Invalid query", err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	var metric Metric
 	var found bool
-	for _, m := range engine.Metrics {
+	for _, m := range live {
 		if m.Name == id {
-			metric = NewMetric(m, t)
+			metric = NewLiveMetric(m)
 			found = true
 			break
 		}
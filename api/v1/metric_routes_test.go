@@ -117,3 +117,42 @@ func TestGetMetric(t *testing.T) {
 		})
 	})
 }
+
+func TestGetMetricsQuery(t *testing.T) {
+	engine, err := core.NewEngine(nil, lib.Options{})
+	assert.NoError(t, err)
+
+	metric := stats.New("my_metric", stats.Counter)
+	engine.Metrics = map[string]*stats.Metric{"my_metric": metric}
+	metric.Sink.Add(stats.Sample{Metric: metric, Value: 1})
+
+	testdata := map[string]int{
+		"/v1/metrics":                              http.StatusOK,
+		"/v1/metrics?window=30s":                   http.StatusOK,
+		"/v1/metrics?window=1m":                    http.StatusBadRequest,
+		"/v1/metrics?window=100ms":                 http.StatusBadRequest,
+		"/v1/metrics?window=soon":                  http.StatusBadRequest,
+		"/v1/metrics?tags=status:200":              http.StatusOK,
+		"/v1/metrics?tags=status:%22200":           http.StatusBadRequest,
+		"/v1/metrics/my_metric?window=20s":         http.StatusOK,
+		"/v1/metrics/my_metric?tags=status:200":    http.StatusNotFound,
+		"/v1/metrics/my_metric?tags=status:%22200": http.StatusBadRequest,
+	}
+	for target, status := range testdata {
+		t.Run(target, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", target, nil))
+			assert.Equal(t, status, rw.Code)
+		})
+	}
+
+	t.Run("recent", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/metrics/my_metric", nil))
+		var m Metric
+		assert.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &m))
+		assert.Equal(t, 1.0, m.Sample["count"])
+		assert.Nil(t, m.Recent)
+		assert.Contains(t, rw.Body.String(), `"recent":null`)
+	})
+}
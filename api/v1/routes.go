@@ -32,6 +32,11 @@ func NewHandler() http.Handler {
 	router.GET("/v1/status", HandleGetStatus)
 	router.PATCH("/v1/status", HandlePatchStatus)
 
+	router.GET("/v1/setup", HandleGetSetupData)
+	router.PUT("/v1/setup", HandlePutSetupData)
+	router.POST("/v1/setup", HandlePostSetup)
+	router.POST("/v1/teardown", HandlePostTeardown)
+
 	router.GET("/v1/metrics", HandleGetMetrics)
 	router.GET("/v1/metrics/:id", HandleGetMetric)
 
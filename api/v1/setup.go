@@ -0,0 +1,49 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+)
+
+// SetupData is what the script's setup() returned, or what it was set to through the API.
+type SetupData struct {
+	Data json.RawMessage `json:"data" yaml:"data"`
+}
+
+func NewSetupData(data json.RawMessage) SetupData {
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+	return SetupData{Data: data}
+}
+
+func (d SetupData) GetName() string {
+	return "setupData"
+}
+
+func (d SetupData) GetID() string {
+	return "default"
+}
+
+func (d SetupData) SetID(id string) error {
+	return nil
+}
@@ -0,0 +1,143 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/api/common"
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+func HandleGetSetupData(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	runner := engine.Executor.GetRunner()
+	if runner == nil {
+		apiError(rw, "No script", "there's no script to get setup data from", http.StatusBadRequest)
+		return
+	}
+	writeSetupData(rw, runner)
+}
+
+// HandlePutSetupData replaces the setup data with the data attribute of the request, which can
+// be any JSON value.
+// It's meant to inject fixtures into a test started with --no-setup and --paused; VUs that have
+// already run an iteration keep the data they were given.
+func HandlePutSetupData(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	runner, ok := setupRunner(rw, engine, "Couldn't change setup data")
+	if !ok {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		apiError(rw, "Couldn't read request", err.Error(), http.StatusBadRequest)
+		return
+	}
+	var data SetupData
+	if err := jsonapi.Unmarshal(body, &data); err != nil {
+		apiError(rw, "Invalid data", err.Error(), http.StatusBadRequest)
+		return
+	}
+	if string(data.Data) == "null" {
+		data.Data = nil
+	}
+	if err := runner.SetSetupData(data.Data); err != nil {
+		apiError(rw, "Invalid data", err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeSetupData(rw, runner)
+}
+
+// HandlePostSetup runs the script's setup(), replacing the setup data with what it returns.
+func HandlePostSetup(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	runner, ok := setupRunner(rw, engine, "Couldn't run setup")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(
+		lib.WithExecutor(r.Context(), engine.Executor),
+		time.Duration(runner.GetOptions().SetupTimeout.Duration),
+	)
+	defer cancel()
+	if err := runner.Setup(ctx); err != nil {
+		apiError(rw, "Setup failed", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeSetupData(rw, runner)
+}
+
+// HandlePostTeardown runs the script's teardown() with the current setup data.
+func HandlePostTeardown(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	runner, ok := setupRunner(rw, engine, "Couldn't run teardown")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(
+		lib.WithExecutor(r.Context(), engine.Executor),
+		time.Duration(runner.GetOptions().TeardownTimeout.Duration),
+	)
+	defer cancel()
+	if err := runner.Teardown(ctx); err != nil {
+		apiError(rw, "Teardown failed", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeSetupData(rw, runner)
+}
+
+// setupRunner returns the runner whose setup data or setup/teardown a request wants to touch,
+// or writes an error if there is none, or if VUs may be using the data right now.
+func setupRunner(rw http.ResponseWriter, engine *core.Engine, title string) (lib.Runner, bool) {
+	runner := engine.Executor.GetRunner()
+	if runner == nil {
+		apiError(rw, title, "there's no script", http.StatusBadRequest)
+		return nil, false
+	}
+	if engine.Executor.IsRunning() && !engine.Executor.IsPaused() {
+		apiError(rw, title, "the test is running, pause it first", http.StatusConflict)
+		return nil, false
+	}
+	return runner, true
+}
+
+func writeSetupData(rw http.ResponseWriter, runner lib.Runner) {
+	data, err := jsonapi.Marshal(NewSetupData(runner.GetSetupData()))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
@@ -0,0 +1,127 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupData(t *testing.T) {
+	var tornDown []string
+	runner := &lib.MiniRunner{
+		Options: lib.Options{
+			SetupTimeout:    types.NullDurationFrom(10 * time.Second),
+			TeardownTimeout: types.NullDurationFrom(10 * time.Second),
+		},
+	}
+	runner.SetupFn = func(ctx context.Context) error {
+		return runner.SetSetupData(json.RawMessage(`{"token":"abc"}`))
+	}
+	runner.TeardownFn = func(ctx context.Context) error {
+		tornDown = append(tornDown, string(runner.GetSetupData()))
+		return nil
+	}
+	engine, err := core.NewEngine(local.New(runner), lib.Options{})
+	require.NoError(t, err)
+
+	do := func(method, target, body string) (int, json.RawMessage) {
+		rw := httptest.NewRecorder()
+		var reqBody io.Reader
+		if body != "" {
+			doc := fmt.Sprintf(`{"data":{"type":"setupData","id":"default","attributes":{"data":%s}}}`, body)
+			reqBody = bytes.NewBufferString(doc)
+		}
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, method, target, reqBody))
+		if rw.Code != http.StatusOK {
+			return rw.Code, nil
+		}
+		var doc jsonapi.Document
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &doc))
+		require.NotNil(t, doc.Data.DataObject)
+		assert.Equal(t, "setupData", doc.Data.DataObject.Type)
+		var data SetupData
+		require.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &data))
+		return rw.Code, data.Data
+	}
+
+	code, data := do("GET", "/v1/setup", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `null`, string(data))
+
+	code, data = do("PUT", "/v1/setup", `{"v":1}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"v":1}`, string(data))
+
+	code, data = do("GET", "/v1/setup", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"v":1}`, string(data))
+
+	code, _ = do("PUT", "/v1/setup", `{"v":`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, data = do("POST", "/v1/setup", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"token":"abc"}`, string(data))
+
+	code, _ = do("POST", "/v1/teardown", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{`{"token":"abc"}`}, tornDown)
+
+	code, data = do("PUT", "/v1/setup", "null")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `null`, string(data))
+
+	t.Run("failing", func(t *testing.T) {
+		runner.SetupFn = func(ctx context.Context) error { return errors.New("nope") }
+		runner.TeardownFn = runner.SetupFn
+		code, _ := do("POST", "/v1/setup", "")
+		assert.Equal(t, http.StatusInternalServerError, code)
+		code, _ = do("POST", "/v1/teardown", "")
+		assert.Equal(t, http.StatusInternalServerError, code)
+	})
+
+	t.Run("no script", func(t *testing.T) {
+		engine, err := core.NewEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		for _, method := range []string{"GET", "PUT", "POST"} {
+			rw := httptest.NewRecorder()
+			NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, method, "/v1/setup", nil))
+			assert.Equal(t, http.StatusBadRequest, rw.Code, method)
+		}
+	})
+}
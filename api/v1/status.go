@@ -22,6 +22,8 @@ package v1
 
 import (
 	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -30,19 +32,39 @@ type Status struct {
 	VUs    null.Int  `json:"vus" yaml:"vus"`
 	VUsMax null.Int  `json:"vus-max" yaml:"vus-max"`
 
+	// Whether the test is warming up; it can only be set to false, to end the warm-up early.
+	Warmup null.Bool `json:"warmup" yaml:"warmup"`
+
 	// Readonly.
 	Running bool `json:"running" yaml:"running"`
 	Tainted bool `json:"tainted" yaml:"tainted"`
+
+	// Readonly; why the script aborted the test, if it did.
+	AbortReason null.String `json:"abort-reason" yaml:"abort-reason"`
+
+	// Readonly; how far along the test is, see lib.GetProgress.
+	Elapsed   types.Duration     `json:"elapsed" yaml:"elapsed"`
+	Remaining types.NullDuration `json:"remaining" yaml:"remaining"`
+	Progress  null.Float         `json:"progress" yaml:"progress"`
 }
 
 func NewStatus(engine *core.Engine) Status {
-	return Status{
-		Paused:  null.BoolFrom(engine.Executor.IsPaused()),
-		VUs:     null.IntFrom(engine.Executor.GetVUs()),
-		VUsMax:  null.IntFrom(engine.Executor.GetVUsMax()),
-		Running: engine.Executor.IsRunning(),
-		Tainted: engine.IsTainted(),
+	progress := lib.GetProgress(engine.Executor)
+	status := Status{
+		Paused:    null.BoolFrom(engine.Executor.IsPaused()),
+		VUs:       null.IntFrom(engine.Executor.GetVUs()),
+		VUsMax:    null.IntFrom(engine.Executor.GetVUsMax()),
+		Warmup:    null.BoolFrom(engine.IsWarmingUp()),
+		Running:   engine.Executor.IsRunning(),
+		Tainted:   engine.IsTainted(),
+		Elapsed:   types.Duration(progress.Elapsed),
+		Remaining: progress.Remaining,
+		Progress:  progress.Fraction,
+	}
+	if reason, ok := engine.ScriptAbortReason(); ok {
+		status.AbortReason = null.StringFrom(reason)
 	}
+	return status
 }
 
 func (s Status) GetName() string {
@@ -71,6 +71,13 @@ func HandlePatchStatus(rw http.ResponseWriter, r *http.Request, p httprouter.Par
 	if status.Paused.Valid {
 		engine.Executor.SetPaused(status.Paused.Bool)
 	}
+	if status.Warmup.Valid {
+		if status.Warmup.Bool {
+			apiError(rw, "Couldn't change warm-up", "a warm-up can only be ended", http.StatusBadRequest)
+			return
+		}
+		engine.EndWarmup()
+	}
 
 	data, err := jsonapi.Marshal(NewStatus(engine))
 	if err != nil {
@@ -26,16 +26,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/loadimpact/k6/core"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/manyminds/api2go/jsonapi"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v3"
 )
 
 func TestGetStatus(t *testing.T) {
-	engine, err := core.NewEngine(nil, lib.Options{})
+	engine, err := core.NewEngine(nil, lib.Options{Duration: types.NullDurationFrom(10 * time.Second)})
 	assert.NoError(t, err)
 
 	rw := httptest.NewRecorder()
@@ -59,6 +61,9 @@ func TestGetStatus(t *testing.T) {
 		assert.True(t, status.VUs.Valid)
 		assert.True(t, status.VUsMax.Valid)
 		assert.False(t, status.Tainted)
+		assert.Equal(t, null.BoolFrom(false), status.Warmup)
+		assert.Equal(t, types.NullDurationFrom(10*time.Second), status.Remaining)
+		assert.Equal(t, null.FloatFrom(0), status.Progress)
 	})
 }
 
@@ -72,6 +77,8 @@ func TestPatchStatus(t *testing.T) {
 		"max vus":      {200, Status{VUsMax: null.IntFrom(10)}},
 		"too many vus": {400, Status{VUs: null.IntFrom(10), VUsMax: null.IntFrom(0)}},
 		"vus":          {200, Status{VUs: null.IntFrom(10), VUsMax: null.IntFrom(10)}},
+		"end warmup":   {200, Status{Warmup: null.BoolFrom(false)}},
+		"start warmup": {400, Status{Warmup: null.BoolFrom(true)}},
 	}
 
 	for name, indata := range testdata {
@@ -105,6 +112,9 @@ func TestPatchStatus(t *testing.T) {
 			if indata.Status.VUsMax.Valid {
 				assert.Equal(t, indata.Status.VUsMax, status.VUsMax)
 			}
+			if indata.Status.Warmup.Valid {
+				assert.Equal(t, indata.Status.Warmup, status.Warmup)
+			}
 		})
 	}
 }
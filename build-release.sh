@@ -1,6 +1,8 @@
 #!/bin/bash
 
 set -e
+# The dependencies are vendored with dep, so k6 is built in GOPATH mode.
+export GO111MODULE=off
 eval $(go env)
 
 # To override the latest git tag as the version, pass something else as the first arg.
@@ -0,0 +1,109 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/loadimpact/k6/core/distributed"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	agentName  = ""
+	agentToken = "" // Not taken from the environment here, so it's not printed in --help.
+)
+
+// agentCmd represents the agent command.
+var agentCmd = &cobra.Command{
+	Use:   "agent [coordinator address]",
+	Short: "Run a share of a distributed test",
+	Long: `Run a share of a distributed test.
+
+Registers with the coordinator, which is started with k6 run --agents, and runs
+the share of the test it's assigned once all of the test's agents have
+registered. The coordinator sends the agent the script, so it only needs to be
+on the coordinator's machine, and collects the agent's metrics; thresholds,
+outputs and the end-of-test summary are all the coordinator's.
+
+The agent authenticates with the token the coordinator was given. Until there's
+a test to take part in, it keeps trying to register.`,
+	Example: `
+  # Start a test split among 2 agents, and the agents that run it.
+  export K6_COORDINATOR_TOKEN=secret
+  k6 run --agents 2 --coordinator-address 0.0.0.0:6566 script.js
+  k6 agent 1.2.3.4:6566
+  k6 agent 1.2.3.4:6566`[1:],
+	Args: exactArgsWithMsg(1, "arg should be the address of the coordinator, eg. localhost:6566"),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		agent := distributed.NewAgent(args[0], func(arc *lib.Archive) (lib.Runner, error) {
+			if arc == nil {
+				return nil, errors.New("the coordinator didn't send a script")
+			}
+			return newRunnerFromArchive(arc, runtimeOptions)
+		})
+		agent.Name = agentName
+		agent.Token = agentToken
+		if agent.Token == "" {
+			agent.Token = os.Getenv("K6_COORDINATOR_TOKEN")
+		}
+		if agent.Name == "" {
+			agent.Name, _ = os.Hostname()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigC)
+		go func() {
+			sig := <-sigC
+			log.WithField("sig", sig).Debug("Stopping in response to signal")
+			cancel()
+		}()
+
+		log.WithField("coordinator", args[0]).Info("Registering with the coordinator...")
+		if err := agent.Run(ctx); err != nil {
+			return err
+		}
+		log.Info("Done")
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+
+	agentCmd.Flags().SortFlags = false
+	agentCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
+	agentCmd.Flags().StringVar(&agentName, "name", agentName, "name the coordinator knows the agent by (default: the hostname)")
+	agentCmd.Flags().StringVar(&agentToken, "token", agentToken, "token to authenticate with, the coordinator's --coordinator-token (default: $K6_COORDINATOR_TOKEN)")
+}
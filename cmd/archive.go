@@ -22,12 +22,18 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
 
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
-var archiveOut = "archive.tar"
+var (
+	archiveOut     = "archive.tar"
+	archiveInclude []string
+)
 
 // archiveCmd represents the pause command
 var archiveCmd = &cobra.Command{
@@ -35,13 +41,21 @@ var archiveCmd = &cobra.Command{
 	Short: "Create an archive",
 	Long: `Create an archive.
 
-An archive is a fully self-contained test run, and can be executed identically elsewhere.`,
+An archive is a fully self-contained test run, and can be executed identically elsewhere.
+
+It contains the script, the modules it imports and the files it open()s, with the
+user's name scrubbed from their paths; archiving the same test twice gives the
+same archive. Files the script only opens in some runs, eg. depending on an
+environment variable, can be added with --include.`,
 	Example: `
   # Archive a test run.
   k6 archive -u 10 -d 10s -O myarchive.tar script.js
 
   # Run the resulting archive.
-  k6 run myarchive.tar`[1:],
+  k6 run myarchive.tar
+
+  # Also archive the CSV files in the data directory.
+  k6 archive --include "data/*.csv" script.js`[1:],
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Runner.
@@ -51,6 +65,9 @@ An archive is a fully self-contained test run, and can be executed identically e
 		}
 		filename := args[0]
 		fs := afero.NewOsFs()
+		if err := setupModuleCache(cmd.Flags(), fs); err != nil {
+			return err
+		}
 		src, err := readSource(filename, pwd, fs, os.Stdin)
 		if err != nil {
 			return err
@@ -71,19 +88,17 @@ An archive is a fully self-contained test run, and can be executed identically e
 		if err != nil {
 			return err
 		}
-		fileConf, _, err := readDiskConfig(fs)
-		if err != nil {
-			return err
-		}
-		envConf, err := readEnvConfig()
+		conf, err := consolidateConfig(fs, Config{Options: cliOpts}, r)
 		if err != nil {
 			return err
 		}
-		opts := cliOpts.Apply(fileConf.Options).Apply(r.GetOptions()).Apply(envConf.Options).Apply(cliOpts)
-		r.SetOptions(opts)
+		r.SetOptions(conf.Options)
 
 		// Archive.
 		arc := r.MakeArchive()
+		if err := includeFiles(fs, pwd, arc, archiveInclude); err != nil {
+			return err
+		}
 		f, err := os.Create(archiveOut)
 		if err != nil {
 			return err
@@ -97,6 +112,50 @@ func init() {
 	archiveCmd.Flags().SortFlags = false
 	archiveCmd.Flags().AddFlagSet(optionFlagSet())
 	archiveCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
+	archiveCmd.Flags().AddFlagSet(moduleCacheFlagSet())
 	archiveCmd.Flags().AddFlagSet(configFileFlagSet())
 	archiveCmd.Flags().StringVarP(&archiveOut, "archive-out", "O", archiveOut, "archive output filename")
+	archiveCmd.Flags().StringArrayVar(&archiveInclude, "include", nil, "also archive the files matching a glob `pattern`, or in matching directories")
+}
+
+// Adds the files matching the patterns to the archive, where open() looks for them.
+func includeFiles(fs afero.Fs, pwd string, arc *lib.Archive, patterns []string) error {
+	if arc.Files == nil {
+		arc.Files = make(map[string][]byte)
+	}
+	add := func(filename string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name := filepath.ToSlash(filename)
+		if _, ok := arc.Files[name]; ok {
+			return nil
+		}
+		data, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			return err
+		}
+		arc.Files[name] = data
+		return nil
+	}
+
+	for _, pattern := range patterns {
+		abs := pattern
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(pwd, abs)
+		}
+		matches, err := afero.Glob(fs, abs)
+		if err != nil {
+			return errors.Wrapf(err, "invalid --include pattern %s", pattern)
+		}
+		if len(matches) == 0 {
+			return errors.Errorf("--include %s matches no files", pattern)
+		}
+		for _, match := range matches {
+			if err := afero.Walk(fs, match, add); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
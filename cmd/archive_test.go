@@ -0,0 +1,58 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludeFiles(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/test/data/a.csv", []byte("a"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/test/data/b.csv", []byte("b"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/test/data/c.txt", []byte("c"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/test/assets/img/logo.png", []byte("png"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/other/d.json", []byte("{}"), 0644))
+
+	t.Run("Patterns", func(t *testing.T) {
+		arc := &lib.Archive{Files: map[string][]byte{"/test/data/a.csv": []byte("opened")}}
+		require.NoError(t, includeFiles(fs, "/test", arc, []string{"data/*.csv", "assets", "/other/*.json"}))
+		assert.Equal(t, map[string][]byte{
+			"/test/data/a.csv":          []byte("opened"),
+			"/test/data/b.csv":          []byte("b"),
+			"/test/assets/img/logo.png": []byte("png"),
+			"/other/d.json":             []byte("{}"),
+		}, arc.Files)
+	})
+	t.Run("No Matches", func(t *testing.T) {
+		err := includeFiles(fs, "/test", &lib.Archive{}, []string{"data/*.xml"})
+		assert.EqualError(t, err, "--include data/*.xml matches no files")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		err := includeFiles(fs, "/test", &lib.Archive{}, []string{"data/[.csv"})
+		assert.EqualError(t, err, "invalid --include pattern data/[.csv: syntax error in pattern")
+	})
+}
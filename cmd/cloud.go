@@ -44,7 +44,10 @@ var cloudCmd = &cobra.Command{
 	Short: "Run a test on the cloud",
 	Long: `Run a test on the cloud.
 
-This will execute the test on the Load Impact cloud service. Use "k6 login cloud" to authenticate.`,
+This will execute the test on the Load Impact cloud service. Use "k6 login cloud" to authenticate.
+
+To run a test on this machine instead, and stream its metrics to the cloud service
+to look at and keep them there, use "k6 run -o cloud".`,
 	Example: `
         k6 cloud script.js`[1:],
 	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
@@ -63,7 +66,11 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 		}
 
 		filename := args[0]
-		src, err := readSource(filename, pwd, afero.NewOsFs(), os.Stdin)
+		fs := afero.NewOsFs()
+		if err := setupModuleCache(cmd.Flags(), fs); err != nil {
+			return err
+		}
+		src, err := readSource(filename, pwd, fs, os.Stdin)
 		if err != nil {
 			return err
 		}
@@ -73,28 +80,20 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 			return err
 		}
 
-		r, err := newRunner(src, runType, afero.NewOsFs(), runtimeOptions)
+		r, err := newRunner(src, runType, fs, runtimeOptions)
 		if err != nil {
 			return err
 		}
 
 		// Options
-		fs := afero.NewOsFs()
-		fileConf, _, err := readDiskConfig(fs)
-		if err != nil {
-			return err
-		}
 		options, err := getOptions(cmd.Flags())
 		if err != nil {
 			return err
 		}
-		cliConf := Config{Options: options}
-
-		envConf, err := readEnvConfig()
+		conf, err := consolidateConfig(fs, Config{Options: options}, r)
 		if err != nil {
 			return err
 		}
-		conf := cliConf.Apply(fileConf).Apply(Config{Options: r.GetOptions()}).Apply(envConf).Apply(cliConf)
 		r.SetOptions(conf.Options)
 
 		// Cloud config
@@ -187,7 +186,7 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 		fmt.Fprintf(stdout, "     test status: %s\n", ui.ValueColor.Sprint(testProgress.RunStatusText))
 
 		if testProgress.ResultStatus == 1 {
-			return ExitCode{errors.New("The test have failed"), 99}
+			return ExitCode{errors.New("The test have failed"), thresholdsFailedExitCode}
 		}
 
 		return nil
@@ -199,4 +198,5 @@ func init() {
 	cloudCmd.Flags().SortFlags = false
 	cloudCmd.Flags().AddFlagSet(optionFlagSet())
 	cloudCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
+	cloudCmd.Flags().AddFlagSet(moduleCacheFlagSet())
 }
@@ -28,16 +28,37 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats/cloud"
+	"github.com/loadimpact/k6/stats/csv"
+	"github.com/loadimpact/k6/stats/dashboard"
+	"github.com/loadimpact/k6/stats/elasticsearch"
+	"github.com/loadimpact/k6/stats/filter"
+	"github.com/loadimpact/k6/stats/graphite"
 	"github.com/loadimpact/k6/stats/influxdb"
 	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/loadimpact/k6/stats/kafka"
+	"github.com/loadimpact/k6/stats/newrelic"
+	"github.com/loadimpact/k6/stats/otlp"
+	outputext "github.com/loadimpact/k6/stats/output"
+	"github.com/loadimpact/k6/stats/statsd"
+	"github.com/loadimpact/k6/stats/timescaledb"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 )
 
 const (
-	collectorInfluxDB = "influxdb"
-	collectorJSON     = "json"
-	collectorCloud    = "cloud"
+	collectorInfluxDB      = "influxdb"
+	collectorJSON          = "json"
+	collectorCloud         = "cloud"
+	collectorOTLP          = "otlp"
+	collectorStatsD        = "statsd"
+	collectorDatadog       = "datadog"
+	collectorTimescaleDB   = "timescaledb"
+	collectorKafka         = "kafka"
+	collectorElasticsearch = "elasticsearch"
+	collectorCSV           = "csv"
+	collectorNewRelic      = "newrelic"
+	collectorGraphite      = "graphite"
+	collectorDashboard     = "dashboard"
 )
 
 func parseCollector(s string) (t, arg string) {
@@ -79,8 +100,80 @@ func newCollector(collectorName, arg string, src *lib.SourceData, conf Config) (
 				return nil, err
 			}
 			return cloud.New(config, src, conf.Options, Version)
+		case collectorOTLP:
+			config := otlp.NewConfig().Apply(conf.Collectors.OTLP)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return otlp.New(config)
+		case collectorStatsD, collectorDatadog:
+			config := statsd.NewConfig().Apply(conf.Collectors.StatsD)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			if collectorName == collectorDatadog {
+				config.EnableTags = true
+			}
+			return statsd.New(config)
+		case collectorTimescaleDB:
+			config := timescaledb.NewConfig().Apply(conf.Collectors.TimescaleDB)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return timescaledb.New(config)
+		case collectorKafka:
+			config := kafka.NewConfig().Apply(conf.Collectors.Kafka)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return kafka.New(config)
+		case collectorElasticsearch:
+			config := elasticsearch.NewConfig().Apply(conf.Collectors.Elasticsearch)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return elasticsearch.New(config, afero.NewOsFs(), src, Version)
+		case collectorCSV:
+			config := csv.NewConfig().Apply(conf.Collectors.CSV)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return csv.New(afero.NewOsFs(), config)
+		case collectorNewRelic:
+			config := newrelic.NewConfig().Apply(conf.Collectors.NewRelic)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return newrelic.New(config)
+		case collectorGraphite:
+			config := graphite.NewConfig().Apply(conf.Collectors.Graphite)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return graphite.New(config)
+		case collectorDashboard:
+			config := dashboard.NewConfig().Apply(conf.Collectors.Dashboard)
+			if err := loadConfig(&config); err != nil {
+				return nil, err
+			}
+			return dashboard.New(config)
 		default:
-			return nil, errors.Errorf("unknown output type: %s", collectorName)
+			constructor, ok := outputext.Get(collectorName)
+			if !ok {
+				return nil, errors.Errorf("unknown output type: %s", collectorName)
+			}
+			out, err := constructor(outputext.Params{
+				OutputType:     collectorName,
+				ConfigArgument: arg,
+				FS:             afero.NewOsFs(),
+				Source:         src,
+				ScriptOptions:  conf.Options,
+				Version:        Version,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return outputext.AsCollector(out), nil
 		}
 	}
 
@@ -104,5 +197,8 @@ func newCollector(collectorName, arg string, src *lib.SourceData, conf Config) (
 		)
 	}
 
+	if f := conf.Filters[collectorName]; !f.IsEmpty() {
+		return filter.New(collector, f), nil
+	}
 	return collector, nil
 }
@@ -0,0 +1,51 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/stats/filter"
+	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCollectorFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-collectors")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	fname := filepath.Join(dir, "results.json")
+
+	collector, err := newCollector("json", fname, nil, Config{})
+	require.NoError(t, err)
+	assert.IsType(t, &jsonc.Collector{}, collector)
+
+	conf := Config{Filters: map[string]filter.Config{"json": {DropTags: []string{"url"}}}}
+	collector, err = newCollector("json", fname, nil, conf)
+	require.NoError(t, err)
+	if assert.IsType(t, &filter.Collector{}, collector) {
+		assert.IsType(t, &jsonc.Collector{}, collector.(*filter.Collector).Collector)
+	}
+}
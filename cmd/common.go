@@ -27,7 +27,11 @@ import (
 	"os"
 	"sync"
 
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -51,6 +55,53 @@ type ExitCode struct {
 	Code int
 }
 
+// Exit codes of tests that didn't pass, so that CI systems can tell why. Other errors, like
+// invalid options, exit with -1.
+const (
+	// Some thresholds failed by the end of the test.
+	thresholdsFailedExitCode = 99
+	// A threshold with abortOnFail failed, and stopped the test early.
+	thresholdsAbortedExitCode = 100
+	// The test failed with an error that isn't the script's, eg. from an agent.
+	engineErrorExitCode = 103
+	// The user stopped the test, with Ctrl+C or a signal.
+	userAbortedExitCode = 105
+	// The script threw an exception, or has a syntax error, outside of VU iterations; eg. in the
+	// init context or setup().
+	scriptErrorExitCode = 107
+	// The script aborted the test, eg. with execution.abort().
+	scriptAbortedExitCode = 108
+	// k6 compare found stats that changed beyond their tolerances from the baseline run.
+	comparisonRegressedExitCode = 109
+)
+
+// Returns an error with the exit code for an error the test failed with.
+func testErrorExitCode(err error) ExitCode {
+	switch {
+	case isScriptAbort(err):
+		return ExitCode{err, scriptAbortedExitCode}
+	case isScriptError(err):
+		return ExitCode{err, scriptErrorExitCode}
+	default:
+		return ExitCode{err, engineErrorExitCode}
+	}
+}
+
+func isScriptAbort(err error) bool {
+	_, ok := errors.Cause(err).(lib.ScriptAbortError)
+	return ok
+}
+
+// Returns whether an error was thrown by the script, or is a syntax error in it.
+func isScriptError(err error) bool {
+	switch errors.Cause(err).(type) {
+	case *goja.Exception, *goja.CompilerSyntaxError, parser.ErrorList, *parser.Error:
+		return true
+	default:
+		return false
+	}
+}
+
 // A writer that syncs writes with a mutex and, if the output is a TTY, clears before newlines.
 type consoleWriter struct {
 	Writer io.Writer
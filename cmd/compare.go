@@ -0,0 +1,282 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/stats/results"
+	"github.com/loadimpact/k6/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareFormat     = ""
+	compareTolerances = results.DefaultTolerances
+	compareExport     = ""
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare baseline current",
+	Short: "Compare the metrics of two test runs",
+	Long: `Compare the metrics of two test runs.
+
+Shows how the key stats of the metrics both runs have changed from the baseline run
+to the current one, and fails if any of them changed by more than its tolerance, so
+that performance regressions can fail a CI pipeline.
+
+Runs are read from the summaries "k6 run --summary-export" writes, or from the
+results a test saved with --out json=... or --out csv=..., which are summed up like
+"k6 stats" does.
+
+Tolerances are written as metric.stat=change. Positive ones allow the stat to grow
+by up to the change, negative ones to shrink by it; changes ending in % are relative
+to the baseline's value. Giving any tolerances replaces the defaults.`,
+	Example: `
+  # Compare two runs with the default tolerances.
+  k6 run --summary-export baseline.json script.js
+  k6 run --summary-export current.json script.js
+  k6 compare baseline.json current.json
+
+  # Allow the 95th percentile of successful requests to get 5% slower, and throughput
+  # to drop by 10 requests per second.
+  k6 compare --tolerance 'http_req_duration{status:200}.p(95)=+5%' --tolerance 'http_reqs.rate=-10' baseline.json current.json
+
+  # Compare saved results, and export the comparison for other tools.
+  k6 compare --export comparison.json baseline.csv.gz current.csv.gz`[1:],
+	Args: exactArgsWithMsg(2, "args should be the baseline run and the current one"),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return compareRuns(afero.NewOsFs(), args[0], args[1])
+	},
+}
+
+// compareRuns prints how a run changed from a baseline one, and fails if it regressed.
+func compareRuns(fs afero.Fs, baselineFile, currentFile string) error {
+	tolerances := make([]results.Tolerance, len(compareTolerances))
+	for i, s := range compareTolerances {
+		t, err := results.ParseTolerance(s)
+		if err != nil {
+			return err
+		}
+		tolerances[i] = t
+	}
+
+	baseline, err := loadRun(fs, baselineFile, tolerances)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't read %s", baselineFile)
+	}
+	current, err := loadRun(fs, currentFile, tolerances)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't read %s", currentFile)
+	}
+
+	deltas := results.Compare(baseline, current, tolerances)
+	if len(deltas) == 0 {
+		return errors.Errorf("%s and %s have no metrics in common", baselineFile, currentFile)
+	}
+	if !quiet {
+		fmt.Fprintf(stdout, "\n  baseline: %s\n   current: %s\n\n", baselineFile, currentFile)
+		printComparison(stdout, baseline, deltas)
+		fmt.Fprintf(stdout, "\n")
+	}
+	if compareExport != "" {
+		if err := exportComparison(fs, compareExport, deltas); err != nil {
+			return err
+		}
+	}
+
+	var regressed []string
+	for _, d := range deltas {
+		if d.Regressed {
+			regressed = append(regressed, d.Metric+"."+d.Stat)
+		}
+	}
+	if len(regressed) > 0 {
+		return ExitCode{errors.Errorf("some stats have regressed beyond their tolerances: %s",
+			strings.Join(regressed, ", ")), comparisonRegressedExitCode}
+	}
+	return nil
+}
+
+// loadRun reads a run from an exported summary, or from saved results; the submetrics that have
+// tolerances are summed up from those, as the thresholds of the run would have.
+func loadRun(fs afero.Fs, filename string, tolerances []results.Tolerance) (results.Run, error) {
+	if summary, ok, err := readSummaryExport(fs, filename); err != nil || ok {
+		return summary, err
+	}
+
+	submetrics := make(map[string]stats.Thresholds)
+	for _, t := range tolerances {
+		if strings.Contains(t.Metric, "{") {
+			submetrics[t.Metric] = stats.Thresholds{}
+		}
+	}
+	res, err := results.New(submetrics, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := readResults(fs, filename, compareFormat, res.Add); err != nil {
+		return nil, err
+	}
+	t := res.Duration()
+	run := make(results.Run, len(res.Metrics))
+	for name, m := range res.Metrics {
+		run[name] = results.RunMetric{Type: m.Type, Contains: m.Contains, Values: ui.MetricValues(t, m)}
+	}
+	return run, nil
+}
+
+// readSummaryExport reads a run from a summary that --summary-export wrote, and returns whether
+// the file is one; results files are a stream of JSON lines or CSV instead.
+func readSummaryExport(fs afero.Fs, filename string) (results.Run, bool, error) {
+	if compareFormat != "" || strings.HasSuffix(filename, ".gz") || strings.HasSuffix(filename, ".csv") {
+		return nil, false, nil
+	}
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var summary struct {
+		Metrics map[string]struct {
+			Type     stats.MetricType   `json:"type"`
+			Contains stats.ValueType    `json:"contains"`
+			Values   map[string]float64 `json:"values"`
+		} `json:"metrics"`
+	}
+	if err := json.NewDecoder(f).Decode(&summary); err != nil || summary.Metrics == nil {
+		return nil, false, nil
+	}
+	run := make(results.Run, len(summary.Metrics))
+	for name, m := range summary.Metrics {
+		run[name] = results.RunMetric{Type: m.Type, Contains: m.Contains, Values: m.Values}
+	}
+	return run, true, nil
+}
+
+// printComparison prints a row for every compared stat, marking those with a tolerance as passed
+// or regressed.
+func printComparison(w io.Writer, baseline results.Run, deltas []results.Delta) {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "    metric\tstat\tbaseline\tcurrent\tchange\ttolerance\n")
+	for _, d := range deltas {
+		m := &stats.Metric{Type: baseline[d.Metric].Type, Contains: baseline[d.Metric].Contains}
+		mark, tolerance := " ", ""
+		if d.Tolerance != nil {
+			mark, tolerance = ui.SuccMark, strings.TrimPrefix(d.Tolerance.String(), d.Metric+"."+d.Stat+"=")
+			if d.Regressed {
+				mark = ui.FailMark
+			}
+		}
+		fmt.Fprintf(tw, "  %s %s\t%s\t%s\t%s\t%s\t%s\n", mark, d.Metric, d.Stat,
+			formatStat(m, d.Stat, d.Baseline), formatStat(m, d.Stat, d.Current), formatChange(d.Change()), tolerance)
+	}
+	_ = tw.Flush()
+
+	for i, line := range strings.SplitAfter(buf.String(), "\n") {
+		switch {
+		case i == 0:
+			_, _ = ui.GrayColor.Fprint(w, line)
+		case strings.HasPrefix(line, "  "+ui.FailMark):
+			_, _ = ui.FailColor.Fprint(w, line)
+		case strings.HasPrefix(line, "  "+ui.SuccMark):
+			_, _ = ui.SuccColor.Fprint(w, line)
+		default:
+			_, _ = fmt.Fprint(w, line)
+		}
+	}
+}
+
+// formatStat formats the value of a stat the way the summary does.
+func formatStat(m *stats.Metric, stat string, v float64) string {
+	switch {
+	case m.Type == stats.Counter && stat == "rate":
+		return m.HumanizeValue(v) + "/s"
+	case m.Type == stats.Rate && stat != "rate":
+		return fmt.Sprintf("%g", v)
+	}
+	return m.HumanizeValue(v)
+}
+
+// formatChange formats a relative change as a percentage.
+func formatChange(change float64) string {
+	if math.IsInf(change, 0) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", change*100)
+}
+
+// exportComparison writes the compared stats to a JSON file.
+func exportComparison(fs afero.Fs, filename string, deltas []results.Delta) error {
+	type exportedDelta struct {
+		Metric    string   `json:"metric"`
+		Stat      string   `json:"stat"`
+		Baseline  float64  `json:"baseline"`
+		Current   float64  `json:"current"`
+		Change    *float64 `json:"change"`
+		Tolerance string   `json:"tolerance,omitempty"`
+		Regressed bool     `json:"regressed"`
+	}
+	exported := struct {
+		Regressed bool            `json:"regressed"`
+		Stats     []exportedDelta `json:"stats"`
+	}{Stats: make([]exportedDelta, len(deltas))}
+	for i, d := range deltas {
+		e := exportedDelta{Metric: d.Metric, Stat: d.Stat, Baseline: d.Baseline, Current: d.Current, Regressed: d.Regressed}
+		if change := d.Change(); !math.IsInf(change, 0) {
+			e.Change = &change
+		}
+		if d.Tolerance != nil {
+			e.Tolerance = d.Tolerance.String()
+		}
+		exported.Regressed = exported.Regressed || d.Regressed
+		exported.Stats[i] = e
+	}
+
+	data, err := json.MarshalIndent(exported, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fs, filename, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "couldn't export the comparison")
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().SortFlags = false
+	compareCmd.Flags().StringArrayVar(&compareTolerances, "tolerance", compareTolerances, "flag a stat as regressed if it changes by more than a `tolerance`, as 'metric.stat=+change' or 'metric.stat=-change', eg. 'http_req_duration.p(95)=+10%'; can be given more than once")
+	compareCmd.Flags().StringVar(&compareFormat, "format", compareFormat, "`format` of saved results, json or csv, if the runs aren't exported summaries; by default, csv for files ending in .csv or .csv.gz, json for others")
+	compareCmd.Flags().StringVar(&compareExport, "export", compareExport, "write the comparison to a JSON `file`")
+}
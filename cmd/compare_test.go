@@ -0,0 +1,95 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/loadimpact/k6/stats/results"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareRuns(t *testing.T) {
+	defer func(q bool) { quiet = q }(quiet)
+	quiet = true
+	defer func() { compareTolerances, compareExport = results.DefaultTolerances, "" }()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/baseline.json", []byte(`{
+		"state": {"testRunDurationMs": 10000},
+		"metrics": {
+			"http_req_duration": {"type": "trend", "contains": "time", "values": {"avg": 100, "p(95)": 200}},
+			"checks": {"type": "rate", "contains": "default", "values": {"rate": 1, "passes": 2, "fails": 0}}
+		}
+	}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/current.json", []byte(
+		`{"type":"Metric","metric":"http_req_duration","data":{"name":"http_req_duration","type":"trend","contains":"time"}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2017-07-14T02:40:00Z","value":100,"tags":{"status":"200"}}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2017-07-14T02:40:10Z","value":260,"tags":{"status":"500"}}}
+`), 0644))
+
+	baseline, err := loadRun(fs, "/baseline.json", nil)
+	require.NoError(t, err)
+	assert.Len(t, baseline, 2)
+	current, err := loadRun(fs, "/current.json", []results.Tolerance{{Metric: "http_req_duration{status:200}", Stat: "avg"}})
+	require.NoError(t, err)
+	assert.Len(t, current, 2)
+	assert.Equal(t, 100.0, current["http_req_duration{status:200}"].Values["avg"])
+
+	// p(95) of 100 and 260 is 252, 26% over the baseline's.
+	compareTolerances = []string{"http_req_duration.p(95)=+30%"}
+	compareExport = "/comparison.json"
+	assert.NoError(t, compareRuns(fs, "/baseline.json", "/current.json"))
+
+	data, err := afero.ReadFile(fs, "/comparison.json")
+	require.NoError(t, err)
+	var exported struct {
+		Regressed bool `json:"regressed"`
+		Stats     []struct {
+			Metric    string   `json:"metric"`
+			Stat      string   `json:"stat"`
+			Change    *float64 `json:"change"`
+			Tolerance string   `json:"tolerance"`
+		} `json:"stats"`
+	}
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.False(t, exported.Regressed)
+	if assert.Len(t, exported.Stats, 2) {
+		assert.Equal(t, "avg", exported.Stats[0].Stat)
+		assert.InDelta(t, 0.8, *exported.Stats[0].Change, 1e-9)
+		assert.Equal(t, "p(95)", exported.Stats[1].Stat)
+		assert.Equal(t, "http_req_duration.p(95)=+30%", exported.Stats[1].Tolerance)
+	}
+
+	compareTolerances = []string{"http_req_duration.p(95)=+20%", "http_req_duration.avg=+50"}
+	err = compareRuns(fs, "/baseline.json", "/current.json")
+	if assert.IsType(t, ExitCode{}, err) {
+		assert.Equal(t, comparisonRegressedExitCode, err.(ExitCode).Code)
+		assert.EqualError(t, err, "some stats have regressed beyond their tolerances: http_req_duration.avg, http_req_duration.p(95)")
+	}
+
+	compareTolerances = []string{"http_req_duration=+20%"}
+	assert.EqualError(t, compareRuns(fs, "/baseline.json", "/current.json"),
+		"invalid tolerance http_req_duration=+20%, expected metric.stat=+change or metric.stat=-change")
+}
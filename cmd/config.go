@@ -22,17 +22,33 @@ package cmd
 
 import (
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats/cloud"
+	"github.com/loadimpact/k6/stats/csv"
+	"github.com/loadimpact/k6/stats/dashboard"
+	"github.com/loadimpact/k6/stats/elasticsearch"
+	"github.com/loadimpact/k6/stats/filter"
+	"github.com/loadimpact/k6/stats/graphite"
 	"github.com/loadimpact/k6/stats/influxdb"
+	"github.com/loadimpact/k6/stats/kafka"
+	"github.com/loadimpact/k6/stats/newrelic"
+	"github.com/loadimpact/k6/stats/otlp"
+	"github.com/loadimpact/k6/stats/statsd"
+	"github.com/loadimpact/k6/stats/timescaledb"
+	"github.com/pkg/errors"
 	"github.com/shibukawa/configdir"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
 	null "gopkg.in/guregu/null.v3"
+	yaml "gopkg.in/yaml.v2"
 )
 
 const configFilename = "config.json"
@@ -43,7 +59,7 @@ var configFile = os.Getenv("K6_CONFIG") // overridden by `-c` flag!
 // configFileFlagSet returns a FlagSet that contains flags needed for specifying a config file.
 func configFileFlagSet() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("", 0)
-	flags.StringVarP(&configFile, "config", "c", configFile, "specify config file to read")
+	flags.StringVarP(&configFile, "config", "c", configFile, "specify config file to read, JSON or, if it ends in .yaml or .yml, YAML")
 	return flags
 }
 
@@ -51,10 +67,15 @@ func configFileFlagSet() *pflag.FlagSet {
 func configFlagSet() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("", 0)
 	flags.SortFlags = false
-	flags.StringP("out", "o", "", "`uri` for an external metrics database")
+	flags.StringArrayP("out", "o", []string{}, "`uri` for an external metrics database, can be given more than once")
 	flags.BoolP("linger", "l", false, "keep the API server alive past test end")
+	flags.Bool("linger-on-failure", false, "keep the API server alive past test end, only if the test fails")
+	flags.Duration("linger-timeout", 0, "stop lingering after this long, instead of waiting for Ctrl+C")
 	flags.Bool("no-usage-report", false, "don't send anonymous stats to the developers")
 	flags.Bool("no-thresholds", false, "don't run thresholds")
+	flags.Bool("no-summary", false, "don't show the end-of-test summary, nor call handleSummary()")
+	flags.String("summary-export", "", "write the end-of-test summary to a JSON `file`")
+	flags.String("junit-export", "", "write the thresholds and checks to a JUnit XML `file`")
 	flags.AddFlagSet(configFileFlagSet())
 	return flags
 }
@@ -62,61 +83,221 @@ func configFlagSet() *pflag.FlagSet {
 type Config struct {
 	lib.Options
 
-	Out           null.String `json:"out" envconfig:"out"`
-	Linger        null.Bool   `json:"linger" envconfig:"linger"`
-	NoUsageReport null.Bool   `json:"noUsageReport" envconfig:"no_usage_report"`
-	NoThresholds  null.Bool   `json:"noThresholds" envconfig:"no_thresholds"`
+	Out             []string           `json:"out" envconfig:"out"`
+	Linger          null.Bool          `json:"linger" envconfig:"linger"`
+	LingerOnFailure null.Bool          `json:"lingerOnFailure" envconfig:"linger_on_failure"`
+	LingerTimeout   types.NullDuration `json:"lingerTimeout" envconfig:"linger_timeout"`
+	NoUsageReport   null.Bool          `json:"noUsageReport" envconfig:"no_usage_report"`
+	NoThresholds    null.Bool          `json:"noThresholds" envconfig:"no_thresholds"`
+	NoSummary       null.Bool          `json:"noSummary" envconfig:"no_summary"`
+	SummaryExport   null.String        `json:"summaryExport" envconfig:"summary_export"`
+	JUnitExport     null.String        `json:"junitExport" envconfig:"junit_export"`
+
+	// Filters say which metrics and tags every type of output gets, by name.
+	Filters map[string]filter.Config `json:"filters" ignored:"true"`
 
 	Collectors struct {
-		InfluxDB influxdb.Config `json:"influxdb"`
-		Cloud    cloud.Config    `json:"cloud"`
+		InfluxDB      influxdb.Config      `json:"influxdb"`
+		Cloud         cloud.Config         `json:"cloud"`
+		OTLP          otlp.Config          `json:"otlp"`
+		StatsD        statsd.Config        `json:"statsd"`
+		TimescaleDB   timescaledb.Config   `json:"timescaledb"`
+		Kafka         kafka.Config         `json:"kafka"`
+		Elasticsearch elasticsearch.Config `json:"elasticsearch"`
+		CSV           csv.Config           `json:"csv"`
+		NewRelic      newrelic.Config      `json:"newrelic"`
+		Graphite      graphite.Config      `json:"graphite"`
+		Dashboard     dashboard.Config     `json:"dashboard"`
 	} `json:"collectors"`
 }
 
 func (c Config) Apply(cfg Config) Config {
 	c.Options = c.Options.Apply(cfg.Options)
-	if cfg.Out.Valid {
+	if len(cfg.Out) > 0 {
 		c.Out = cfg.Out
 	}
 	if cfg.Linger.Valid {
 		c.Linger = cfg.Linger
 	}
+	if cfg.LingerOnFailure.Valid {
+		c.LingerOnFailure = cfg.LingerOnFailure
+	}
+	if cfg.LingerTimeout.Valid {
+		c.LingerTimeout = cfg.LingerTimeout
+	}
 	if cfg.NoUsageReport.Valid {
 		c.NoUsageReport = cfg.NoUsageReport
 	}
 	if cfg.NoThresholds.Valid {
 		c.NoThresholds = cfg.NoThresholds
 	}
+	if cfg.NoSummary.Valid {
+		c.NoSummary = cfg.NoSummary
+	}
+	if cfg.SummaryExport.Valid {
+		c.SummaryExport = cfg.SummaryExport
+	}
+	if cfg.JUnitExport.Valid {
+		c.JUnitExport = cfg.JUnitExport
+	}
+	if len(cfg.Filters) > 0 {
+		filters := make(map[string]filter.Config, len(c.Filters)+len(cfg.Filters))
+		for name, f := range c.Filters {
+			filters[name] = f
+		}
+		for name, f := range cfg.Filters {
+			filters[name] = f
+		}
+		c.Filters = filters
+	}
 	c.Collectors.InfluxDB = c.Collectors.InfluxDB.Apply(cfg.Collectors.InfluxDB)
 	c.Collectors.Cloud = c.Collectors.Cloud.Apply(cfg.Collectors.Cloud)
+	c.Collectors.OTLP = c.Collectors.OTLP.Apply(cfg.Collectors.OTLP)
+	c.Collectors.StatsD = c.Collectors.StatsD.Apply(cfg.Collectors.StatsD)
+	c.Collectors.TimescaleDB = c.Collectors.TimescaleDB.Apply(cfg.Collectors.TimescaleDB)
+	c.Collectors.Kafka = c.Collectors.Kafka.Apply(cfg.Collectors.Kafka)
+	c.Collectors.Elasticsearch = c.Collectors.Elasticsearch.Apply(cfg.Collectors.Elasticsearch)
+	c.Collectors.CSV = c.Collectors.CSV.Apply(cfg.Collectors.CSV)
+	c.Collectors.NewRelic = c.Collectors.NewRelic.Apply(cfg.Collectors.NewRelic)
+	c.Collectors.Graphite = c.Collectors.Graphite.Apply(cfg.Collectors.Graphite)
+	c.Collectors.Dashboard = c.Collectors.Dashboard.Apply(cfg.Collectors.Dashboard)
 	return c
 }
 
+// The defaults of options that have no flag to shadow them with.
+func defaultConfig() Config {
+	return Config{Options: lib.Options{
+		SetupTimeout:    types.NullDurationFrom(10 * time.Second),
+		TeardownTimeout: types.NullDurationFrom(10 * time.Second),
+		SystemTags:      lib.GetTagSet(lib.DefaultSystemTagList...),
+	}}
+}
+
 // Gets configuration from CLI flags.
 func getConfig(flags *pflag.FlagSet) (Config, error) {
 	opts, err := getOptions(flags)
 	if err != nil {
 		return Config{}, err
 	}
+	out, err := flags.GetStringArray("out")
+	if err != nil {
+		return Config{}, err
+	}
 	return Config{
-		Options:       opts,
-		Out:           getNullString(flags, "out"),
-		Linger:        getNullBool(flags, "linger"),
-		NoUsageReport: getNullBool(flags, "no-usage-report"),
-		NoThresholds:  getNullBool(flags, "no-thresholds"),
+		Options:         opts,
+		Out:             out,
+		Linger:          getNullBool(flags, "linger"),
+		LingerOnFailure: getNullBool(flags, "linger-on-failure"),
+		LingerTimeout:   getNullDuration(flags, "linger-timeout"),
+		NoUsageReport:   getNullBool(flags, "no-usage-report"),
+		NoThresholds:    getNullBool(flags, "no-thresholds"),
+		NoSummary:       getNullBool(flags, "no-summary"),
+		SummaryExport:   getNullString(flags, "summary-export"),
+		JUnitExport:     getNullString(flags, "junit-export"),
 	}, nil
 }
 
+// Merges the layers of configuration, from lowest to highest precedence: the defaults, the config
+// file, the environment, the CLI flags and the options the script exports. The CLI-provided config
+// goes under the defaults as well, to get the shadowed (non-Valid) defaults of the flags in there.
+func consolidateConfig(fs afero.Fs, cliConf Config, r lib.Runner) (Config, error) {
+	fileConf, _, err := readDiskConfig(fs)
+	if err != nil {
+		return Config{}, err
+	}
+	envConf, err := readEnvConfig()
+	if err != nil {
+		return Config{}, err
+	}
+	conf := cliConf.Apply(defaultConfig()).Apply(fileConf).Apply(envConf).Apply(cliConf).Apply(Config{Options: r.GetOptions()})
+	if err := conf.SystemTags.Validate(); err != nil {
+		return Config{}, err
+	}
+	return conf, nil
+}
+
+// Assembles the final configuration for a runner, with consolidateConfig, and fills in and checks
+// what a test run needs of it.
+func getConsolidatedConfig(fs afero.Fs, flags *pflag.FlagSet, r lib.Runner) (Config, error) {
+	cliConf, err := getConfig(flags)
+	if err != nil {
+		return Config{}, err
+	}
+	conf, err := consolidateConfig(fs, cliConf, r)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if conf.NoSummary.Bool && (conf.SummaryExport.String != "" || conf.JUnitExport.String != "") {
+		return Config{}, errors.New("there's no summary to export with noSummary")
+	}
+	if conf.Warmup.Valid && conf.Warmup.Duration < 0 {
+		return Config{}, errors.New("warmup can't be negative")
+	}
+	et, err := conf.ExecutionTuple()
+	if err != nil {
+		return Config{}, err
+	}
+
+	// Scenarios replace the options that describe a single workload, and set their own VUs.
+	if len(conf.Scenarios) > 0 {
+		if conf.VUs.Valid || conf.Duration.Valid || conf.Iterations.Valid || conf.Stages != nil {
+			return Config{}, errors.New("vus, duration, iterations and stages can't be used together with scenarios")
+		}
+		if err := conf.Scenarios.Validate(); err != nil {
+			return Config{}, err
+		}
+		if max := conf.Scenarios.InitVUs(); !conf.VUsMax.Valid {
+			conf.VUsMax = null.IntFrom(max)
+		} else if conf.VUsMax.Int64 < max {
+			return Config{}, errors.Errorf("the scenarios need %d VUs, but max is %d", max, conf.VUsMax.Int64)
+		}
+		// An instance running a segment of the test needs just the VUs its part of it needs.
+		if et != nil {
+			conf.Options = conf.Segment(et)
+			conf.VUsMax = null.IntFrom(conf.Scenarios.InitVUs())
+			if len(conf.Scenarios) == 0 {
+				return Config{}, errors.Errorf("execution segment %s has no part of any of the scenarios", et.Segment)
+			}
+		}
+		return conf, nil
+	}
+
+	// If -m/--max isn't specified, figure out the max that should be needed.
+	if !conf.VUsMax.Valid {
+		conf.VUsMax = null.IntFrom(conf.VUs.Int64)
+		for _, stage := range conf.Stages {
+			if stage.Target.Valid && stage.Target.Int64 > conf.VUsMax.Int64 {
+				conf.VUsMax = stage.Target
+			}
+		}
+	}
+	// If -d/--duration, -i/--iterations and -s/--stage are all unset, run to one iteration.
+	if !conf.Duration.Valid && !conf.Iterations.Valid && conf.Stages == nil {
+		conf.Iterations = null.IntFrom(1)
+	}
+	// If duration is explicitly set to 0, it means run forever.
+	if conf.Duration.Valid && conf.Duration.Duration == 0 {
+		conf.Duration = types.NullDuration{}
+	}
+	if et != nil {
+		conf.Options = conf.Segment(et)
+		if conf.VUsMax.Int64 == 0 {
+			return Config{}, errors.Errorf("execution segment %s has none of the test's VUs", et.Segment)
+		}
+	}
+	return conf, nil
+}
+
 // Reads a configuration file from disk.
 func readDiskConfig(fs afero.Fs) (Config, *configdir.Config, error) {
 	if configFile != "" {
-		data, err := ioutil.ReadFile(configFile)
+		data, err := afero.ReadFile(fs, configFile)
 		if err != nil {
 			return Config{}, nil, err
 		}
-		var conf Config
-		err = json.Unmarshal(data, &conf)
-		return conf, nil, err
+		conf, err := unmarshalConfig(configFile, data)
+		return conf, nil, errors.Wrap(err, configFile)
 	}
 
 	cdir := configDirs.QueryFolderContainsFile(configFilename)
@@ -132,16 +313,63 @@ func readDiskConfig(fs afero.Fs) (Config, *configdir.Config, error) {
 	return conf, cdir, err
 }
 
-// Writes configuration back to disk.
+// Parses a config file; JSON, or YAML if its name ends in .yaml or .yml.
+func unmarshalConfig(filename string, data []byte) (Config, error) {
+	var conf Config
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return conf, err
+		}
+		// YAML is read into maps that encoding/json can't write, but is otherwise read into what
+		// JSON would be, so it can go through the same UnmarshalJSON()s as a JSON config.
+		jsonData, err := json.Marshal(yamlToJSON(v))
+		if err != nil {
+			return conf, err
+		}
+		data = jsonData
+	}
+	err := json.Unmarshal(data, &conf)
+	return conf, err
+}
+
+// Turns the map[interface{}]interface{}s YAML is read into into map[string]interface{}s.
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = yamlToJSON(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = yamlToJSON(e)
+		}
+	}
+	return v
+}
+
+// Writes configuration back to disk, to the file given with -c or the user's config directory.
+// It may have credentials in it, so only the user can read it.
 func writeDiskConfig(fs afero.Fs, cdir *configdir.Config, conf Config) error {
 	data, err := json.MarshalIndent(conf, "", "  ")
 	if err != nil {
 		return err
 	}
-	if configFile != "" {
-		return afero.WriteFile(fs, configFilename, data, 0644)
+	filename := configFile
+	if filename == "" {
+		if err := fs.MkdirAll(cdir.Path, 0755); err != nil {
+			return err
+		}
+		filename = filepath.Join(cdir.Path, configFilename)
+	}
+	if err := afero.WriteFile(fs, filename, data, 0600); err != nil {
+		return err
 	}
-	return cdir.WriteFile(configFilename, data)
+	// WriteFile doesn't change the permissions of a file that's already there.
+	return fs.Chmod(filename, 0600)
 }
 
 // Reads configuration variables from the environment.
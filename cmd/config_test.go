@@ -22,10 +22,17 @@ package cmd
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats/filter"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -41,9 +48,28 @@ func TestConfigEnv(t *testing.T) {
 			"true":  func(c Config) { assert.Equal(t, null.BoolFrom(true), c.NoUsageReport) },
 			"false": func(c Config) { assert.Equal(t, null.BoolFrom(false), c.NoUsageReport) },
 		},
+		{"NoSummary", "K6_NO_SUMMARY"}: {
+			"":      func(c Config) { assert.Equal(t, null.Bool{}, c.NoSummary) },
+			"true":  func(c Config) { assert.Equal(t, null.BoolFrom(true), c.NoSummary) },
+			"false": func(c Config) { assert.Equal(t, null.BoolFrom(false), c.NoSummary) },
+		},
+		{"SummaryExport", "K6_SUMMARY_EXPORT"}: {
+			"":             func(c Config) { assert.Equal(t, null.String{}, c.SummaryExport) },
+			"summary.json": func(c Config) { assert.Equal(t, null.StringFrom("summary.json"), c.SummaryExport) },
+		},
+		{"JUnitExport", "K6_JUNIT_EXPORT"}: {
+			"":          func(c Config) { assert.Equal(t, null.String{}, c.JUnitExport) },
+			"junit.xml": func(c Config) { assert.Equal(t, null.StringFrom("junit.xml"), c.JUnitExport) },
+		},
 		{"Out", "K6_OUT"}: {
-			"":         func(c Config) { assert.Equal(t, null.String{}, c.Out) },
-			"influxdb": func(c Config) { assert.Equal(t, null.StringFrom("influxdb"), c.Out) },
+			"":              func(c Config) { assert.Equal(t, []string{""}, c.Out) },
+			"influxdb":      func(c Config) { assert.Equal(t, []string{"influxdb"}, c.Out) },
+			"influxdb,json": func(c Config) { assert.Equal(t, []string{"influxdb", "json"}, c.Out) },
+		},
+		{"SystemTags", "K6_SYSTEM_TAGS"}: {
+			"":             func(c Config) { assert.Equal(t, lib.TagSet{}, c.SystemTags) },
+			"url":          func(c Config) { assert.Equal(t, lib.GetTagSet("url"), c.SystemTags) },
+			"url, vu,name": func(c Config) { assert.Equal(t, lib.GetTagSet("url", "vu", "name"), c.SystemTags) },
 		},
 	}
 	for field, data := range testdata {
@@ -59,6 +85,7 @@ func TestConfigEnv(t *testing.T) {
 			}
 		})
 	}
+	os.Clearenv()
 }
 
 func TestConfigApply(t *testing.T) {
@@ -70,8 +97,198 @@ func TestConfigApply(t *testing.T) {
 		conf := Config{}.Apply(Config{NoUsageReport: null.BoolFrom(true)})
 		assert.Equal(t, null.BoolFrom(true), conf.NoUsageReport)
 	})
+	t.Run("SummaryExport", func(t *testing.T) {
+		conf := Config{}.Apply(Config{SummaryExport: null.StringFrom("summary.json")})
+		assert.Equal(t, null.StringFrom("summary.json"), conf.SummaryExport)
+	})
+	t.Run("JUnitExport", func(t *testing.T) {
+		conf := Config{}.Apply(Config{JUnitExport: null.StringFrom("junit.xml")})
+		assert.Equal(t, null.StringFrom("junit.xml"), conf.JUnitExport)
+	})
+	t.Run("Filters", func(t *testing.T) {
+		conf := Config{Filters: map[string]filter.Config{
+			"influxdb": {DropTags: []string{"url"}},
+			"json":     {Metrics: []string{"http_reqs"}},
+		}}.Apply(Config{Filters: map[string]filter.Config{
+			"json": {SkipMetrics: []string{"vus"}},
+		}})
+		assert.Equal(t, map[string]filter.Config{
+			"influxdb": {DropTags: []string{"url"}},
+			"json":     {SkipMetrics: []string{"vus"}},
+		}, conf.Filters)
+	})
 	t.Run("Out", func(t *testing.T) {
-		conf := Config{}.Apply(Config{Out: null.StringFrom("influxdb")})
-		assert.Equal(t, null.StringFrom("influxdb"), conf.Out)
+		conf := Config{}.Apply(Config{Out: []string{"influxdb"}})
+		assert.Equal(t, []string{"influxdb"}, conf.Out)
+
+		conf = Config{}.Apply(Config{Out: []string{"influxdb", "json"}})
+		assert.Equal(t, []string{"influxdb", "json"}, conf.Out)
+
+		conf = Config{Out: []string{"influxdb"}}.Apply(Config{})
+		assert.Equal(t, []string{"influxdb"}, conf.Out)
 	})
 }
+
+// Returns a filesystem with just a config file in it.
+func testConfigFs(t *testing.T, filename, data string) afero.Fs {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, filename, []byte(data), 0644))
+	return fs
+}
+
+func TestConsolidatedConfigScenarios(t *testing.T) {
+	fs := testConfigFs(t, "/config.json", "{}")
+	defer func(old string) { configFile = old }(configFile)
+	configFile = "/config.json"
+
+	scenarios := lib.Scenarios{
+		"browse": {Executor: lib.ConstantVUsExecutor, VUs: null.IntFrom(5), Duration: types.NullDurationFrom(time.Minute)},
+		"api": {
+			Executor: lib.RampingVUsExecutor,
+			Stages:   []lib.Stage{{Duration: types.NullDurationFrom(time.Minute), Target: null.IntFrom(10)}},
+		},
+	}
+	consolidate := func(opts lib.Options, args ...string) (Config, error) {
+		flags := optionFlagSet()
+		flags.AddFlagSet(configFlagSet())
+		require.NoError(t, flags.Parse(args))
+		return getConsolidatedConfig(fs, flags, &lib.MiniRunner{Options: opts})
+	}
+
+	conf, err := consolidate(lib.Options{Scenarios: scenarios})
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(15), conf.VUsMax)
+	assert.False(t, conf.Iterations.Valid)
+
+	_, err = consolidate(lib.Options{Scenarios: scenarios}, "--vus", "10")
+	assert.EqualError(t, err, "vus, duration, iterations and stages can't be used together with scenarios")
+
+	_, err = consolidate(lib.Options{Scenarios: scenarios}, "--max", "10")
+	assert.EqualError(t, err, "the scenarios need 15 VUs, but max is 10")
+
+	_, err = consolidate(lib.Options{Scenarios: lib.Scenarios{"browse": {Executor: lib.ConstantVUsExecutor}}})
+	assert.EqualError(t, err, "scenario browse: a positive duration is required")
+}
+
+func TestConsolidatedConfigSystemTags(t *testing.T) {
+	fs := testConfigFs(t, "/config.json", "{}")
+	defer func(old string) { configFile = old }(configFile)
+	configFile = "/config.json"
+
+	consolidate := func(opts lib.Options, args ...string) (Config, error) {
+		flags := optionFlagSet()
+		flags.AddFlagSet(configFlagSet())
+		require.NoError(t, flags.Parse(args))
+		return getConsolidatedConfig(fs, flags, &lib.MiniRunner{Options: opts})
+	}
+
+	conf, err := consolidate(lib.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, lib.GetTagSet(lib.DefaultSystemTagList...), conf.SystemTags)
+
+	conf, err = consolidate(lib.Options{SystemTags: lib.GetTagSet("url", "vu")})
+	require.NoError(t, err)
+	assert.Equal(t, lib.GetTagSet("url", "vu"), conf.SystemTags)
+
+	conf, err = consolidate(lib.Options{}, "--system-tags", "status")
+	require.NoError(t, err)
+	assert.Equal(t, lib.GetTagSet("status"), conf.SystemTags)
+
+	conf, err = consolidate(lib.Options{SystemTags: lib.GetTagSet("url", "vu")}, "--system-tags", "status")
+	require.NoError(t, err)
+	assert.Equal(t, lib.GetTagSet("url", "vu"), conf.SystemTags)
+
+	_, err = consolidate(lib.Options{SystemTags: lib.GetTagSet("url", "stauts")})
+	assert.EqualError(t, err, "unknown system tags: stauts, they can be: "+strings.Join(lib.SystemTagList, ", "))
+}
+
+func TestConsolidatedConfigNoSummary(t *testing.T) {
+	fs := testConfigFs(t, "/config.json", "{}")
+	defer func(old string) { configFile = old }(configFile)
+	configFile = "/config.json"
+
+	consolidate := func(args ...string) (Config, error) {
+		flags := optionFlagSet()
+		flags.AddFlagSet(configFlagSet())
+		require.NoError(t, flags.Parse(args))
+		return getConsolidatedConfig(fs, flags, &lib.MiniRunner{})
+	}
+
+	conf, err := consolidate("--no-summary")
+	require.NoError(t, err)
+	assert.Equal(t, null.BoolFrom(true), conf.NoSummary)
+
+	_, err = consolidate("--no-summary", "--summary-export", "summary.json")
+	assert.EqualError(t, err, "there's no summary to export with noSummary")
+	_, err = consolidate("--no-summary", "--junit-export", "junit.xml")
+	assert.EqualError(t, err, "there's no summary to export with noSummary")
+}
+
+func TestConsolidatedConfigPrecedence(t *testing.T) {
+	files := map[string]string{
+		"/config.json": `{"vus": 2, "maxRedirects": 3, "batch": 4, "userAgent": "file", "setupTimeout": "20s"}`,
+		"/config.yaml": "vus: 2\nmaxRedirects: 3\nbatch: 4\nuserAgent: file\nsetupTimeout: 20s\n",
+	}
+	for filename, data := range files {
+		t.Run(filename, func(t *testing.T) {
+			fs := testConfigFs(t, filename, data)
+			defer func(old string) { configFile = old }(configFile)
+			configFile = filename
+			os.Clearenv()
+			defer os.Clearenv()
+			require.NoError(t, os.Setenv("K6_MAX_REDIRECTS", "5"))
+			require.NoError(t, os.Setenv("K6_BATCH", "6"))
+			require.NoError(t, os.Setenv("K6_USER_AGENT", "env"))
+
+			flags := optionFlagSet()
+			flags.AddFlagSet(configFlagSet())
+			require.NoError(t, flags.Parse([]string{"--batch", "7", "--user-agent", "cli"}))
+			conf, err := getConsolidatedConfig(fs, flags, &lib.MiniRunner{Options: lib.Options{
+				UserAgent: null.StringFrom("script"),
+			}})
+			require.NoError(t, err)
+
+			assert.Equal(t, null.IntFrom(2), conf.VUs)
+			assert.Equal(t, null.IntFrom(5), conf.MaxRedirects)
+			assert.Equal(t, null.IntFrom(7), conf.Batch)
+			assert.Equal(t, null.StringFrom("script"), conf.UserAgent)
+			assert.Equal(t, types.NullDurationFrom(20*time.Second), conf.SetupTimeout)
+			assert.Equal(t, types.NullDurationFrom(10*time.Second), conf.TeardownTimeout)
+			assert.Equal(t, lib.GetTagSet(lib.DefaultSystemTagList...), conf.SystemTags)
+		})
+	}
+}
+
+func TestUnmarshalConfig(t *testing.T) {
+	yamlConf, err := unmarshalConfig("k6.YML", []byte(`
+vus: 10
+stages:
+  - duration: 30s
+    target: 20
+thresholds:
+  http_req_duration: ["p(95)<500"]
+collectors:
+  influxdb:
+    db: k6
+`))
+	require.NoError(t, err)
+	jsonConf, err := unmarshalConfig("k6.json", []byte(`{
+		"vus": 10,
+		"stages": [{"duration": "30s", "target": 20}],
+		"thresholds": {"http_req_duration": ["p(95)<500"]},
+		"collectors": {"influxdb": {"db": "k6"}}
+	}`))
+	require.NoError(t, err)
+	if assert.Len(t, yamlConf.Thresholds["http_req_duration"].Thresholds, 1) {
+		assert.Equal(t, "p(95)<500", yamlConf.Thresholds["http_req_duration"].Thresholds[0].Source)
+	}
+	// Thresholds have JS runtimes in them, which can't be compared.
+	jsonConf.Thresholds, yamlConf.Thresholds = nil, nil
+	assert.Equal(t, jsonConf, yamlConf)
+	assert.Equal(t, null.IntFrom(10), yamlConf.VUs)
+	assert.Equal(t, []lib.Stage{{Duration: types.NullDurationFrom(30 * time.Second), Target: null.IntFrom(20)}}, yamlConf.Stages)
+	assert.Equal(t, "k6", yamlConf.Collectors.InfluxDB.DB)
+
+	_, err = unmarshalConfig("k6.yaml", []byte("vus: [1"))
+	assert.Error(t, err)
+}
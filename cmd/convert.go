@@ -21,23 +21,38 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
+	"os"
 	"path/filepath"
 
 	"github.com/loadimpact/k6/converter/har"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
 var output = ""
 
+// Requests less than this many milliseconds apart are batched by default.
+const defaultBatchThreshold = 500
+
 var (
 	enableChecks        bool
 	returnOnFailedCheck bool
 	correlate           bool
+	thresholds          bool
+	thresholdsMargin    uint
+	merge               string
 	threshold           uint
 	nobatch             bool
 	only                []string
 	skip                []string
+	protocols           []string
+	loginModule         string
+	loginCredentials    string
 )
 
 var convertCmd = &cobra.Command{
@@ -51,9 +66,26 @@ var convertCmd = &cobra.Command{
   # Convert a HAR file to a k6 script creating requests only for the given domain/s.
   k6 convert -O har-session.js --only yourdomain.com,additionaldomain.com session.har
 
+  # Convert a HAR file, leaving out the recorded WebSocket connections and gRPC-web calls.
+  k6 convert -O har-session.js --protocols http session.har
+
   # Convert a HAR file. Batching requests together as long as idle time between requests <800ms
   k6 convert --batch-threshold 800 session.har
 
+  # Convert a HAR file, suggesting thresholds 30% above the recorded p(95) response times.
+  k6 convert --generate-thresholds --thresholds-margin 30 session.har
+
+  # Convert a HAR file into an updatable script, then merge a new recording into it, keeping any manual edits.
+  k6 convert --merge har-session.js session.har
+  k6 convert --merge har-session.js updated-session.har
+
+  # Convert a HAR file, moving the recorded login into a login() function in login.js.
+  # Credentials are taken from the LOGIN_USERNAME and LOGIN_PASSWORD environment variables.
+  k6 convert -O har-session.js --login-module login.js session.har
+
+  # Same, but log each VU in with credentials from a JSON array of {username, password}.
+  k6 convert -O har-session.js --login-module login.js --login-credentials users.json session.har
+
   # Run the k6 script.
   k6 run har-session.js`[1:],
 	Args: cobra.ExactArgs(1),
@@ -75,18 +107,86 @@ var convertCmd = &cobra.Command{
 			return err
 		}
 
-		script, err := har.Convert(h, enableChecks, returnOnFailedCheck, threshold, nobatch, correlate, only, skip)
+		// Detect the login, which is written to its own module
+		var login *har.Login
+		if loginModule != "" {
+			login, err = har.DetectLogin(h, only, skip)
+			if err != nil {
+				return err
+			}
+			if login == nil {
+				return har.ErrNoLogin
+			}
+			login.Import = "./" + filepath.ToSlash(filepath.Base(loginModule))
+			login.Credentials = loginCredentials
+
+			module, err := login.Module()
+			if err != nil {
+				return err
+			}
+			modulePath := loginModule
+			if dest := output; dest != "" && dest != "-" && !filepath.IsAbs(loginModule) {
+				modulePath = filepath.Join(filepath.Dir(dest), loginModule)
+			}
+			if err := afero.WriteFile(defaultFs, modulePath, []byte(module), 0644); err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"url":      login.Entry.Request.URL,
+				"username": login.UsernameField,
+				"password": login.PasswordField,
+			}).Info("Moved the login request to " + modulePath)
+		} else if loginCredentials != "" {
+			return errors.New("--login-credentials requires --login-module")
+		}
+
+		script, err := har.Convert(h, har.ConvertOptions{
+			EnableChecks:        enableChecks,
+			ReturnOnFailedCheck: returnOnFailedCheck,
+			BatchTime:           threshold,
+			NoBatch:             nobatch,
+			Correlate:           correlate,
+			Thresholds:          thresholds,
+			ThresholdsMargin:    thresholdsMargin,
+			Regions:             merge != "",
+			Only:                only,
+			Skip:                skip,
+			Protocols:           protocols,
+			Login:               login,
+		})
 		if err != nil {
 			return err
 		}
 
-		// Write script content to stdout or file
-		if output == "" || output == "-" {
+		// Merge the changes into the existing script, unless it's the first conversion
+		if merge != "" {
+			existing, err := afero.ReadFile(defaultFs, merge)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err == nil {
+				merged, warnings, err := har.MergeScript(string(existing), script)
+				if err != nil {
+					return err
+				}
+				for _, warning := range warnings {
+					log.Warn(warning)
+				}
+				script = merged
+			}
+		}
+
+		// Write script content to stdout or file; merged scripts are updated in place by default
+		dest := output
+		if dest == "" && merge != "" {
+			dest = merge
+		}
+		if dest == "" || dest == "-" {
 			if _, err := io.WriteString(defaultWriter, script); err != nil {
 				return err
 			}
 		} else {
-			f, err := defaultFs.Create(output)
+			f, err := defaultFs.Create(dest)
 			if err != nil {
 				return err
 			}
@@ -104,15 +204,47 @@ var convertCmd = &cobra.Command{
 	},
 }
 
+// isHAR returns whether the data is a HAR file: a JSON object with a log of entries.
+func isHAR(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return false
+	}
+	var h struct {
+		Log *struct {
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"log"`
+	}
+	return json.Unmarshal(data, &h) == nil && h.Log != nil && h.Log.Entries != nil
+}
+
+// convertHARForRun converts a HAR file for k6 run to replay, with status code checks and the
+// requests batched like by default. Correlation is left out, since it changes what's replayed.
+func convertHARForRun(data []byte) (string, error) {
+	h, err := har.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if h.Log.Creator == nil {
+		h.Log.Creator = &har.Creator{}
+	}
+	return har.Convert(h, har.ConvertOptions{EnableChecks: true, BatchTime: defaultBatchThreshold})
+}
+
 func init() {
 	RootCmd.AddCommand(convertCmd)
 	convertCmd.Flags().SortFlags = false
 	convertCmd.Flags().StringVarP(&output, "output", "O", output, "k6 script output filename (stdout by default)")
 	convertCmd.Flags().StringSliceVarP(&only, "only", "", []string{}, "include only requests from the given domains")
 	convertCmd.Flags().StringSliceVarP(&skip, "skip", "", []string{}, "skip requests from the given domains")
-	convertCmd.Flags().UintVarP(&threshold, "batch-threshold", "", 500, "batch request idle time threshold (see example)")
+	convertCmd.Flags().StringSliceVarP(&protocols, "protocols", "", har.Protocols, "convert only entries of the given protocols (http, ws and grpc-web)")
+	convertCmd.Flags().UintVarP(&threshold, "batch-threshold", "", defaultBatchThreshold, "batch request idle time threshold (see example)")
 	convertCmd.Flags().BoolVarP(&nobatch, "no-batch", "", false, "don't generate batch calls")
 	convertCmd.Flags().BoolVarP(&enableChecks, "enable-status-code-checks", "", false, "add a status code check for each HTTP response")
 	convertCmd.Flags().BoolVarP(&returnOnFailedCheck, "return-on-failed-check", "", false, "return from iteration if we get an unexpected response status code")
+	convertCmd.Flags().BoolVarP(&thresholds, "generate-thresholds", "", false, "suggest p(95) response time thresholds based on the recorded timings")
+	convertCmd.Flags().UintVarP(&thresholdsMargin, "thresholds-margin", "", 20, "margin in percent added to the recorded timings for --generate-thresholds")
+	convertCmd.Flags().StringVarP(&merge, "merge", "", "", "merge the conversion into the given previously generated script, keeping manual edits (the script is updated in place unless --output is given)")
+	convertCmd.Flags().StringVarP(&loginModule, "login-module", "", "", "detect the login request, and move it to a login() function in the given module file, next to the script")
+	convertCmd.Flags().StringVarP(&loginCredentials, "login-credentials", "", "", "JSON file with an array of {username, password} objects for --login-module to log VUs in with")
 	convertCmd.Flags().BoolVarP(&correlate, "correlate", "", false, "detect values in responses being used in subsequent requests and try adapt the script accordingly (only redirects and JSON values for now)")
 }
@@ -22,6 +22,7 @@ package cmd
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/pmezard/go-difflib/difflib"
@@ -184,4 +185,60 @@ func TestIntegrationConvertCmd(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, testHARConvertResult, string(output))
 	})
+	t.Run("Merge", func(t *testing.T) {
+		defaultFs = afero.NewMemMapFs()
+		err := afero.WriteFile(defaultFs, "/input.har", []byte(testHAR), 0644)
+		assert.NoError(t, err)
+
+		assert.NoError(t, convertCmd.Flags().Set("output", ""))
+		assert.NoError(t, convertCmd.Flags().Set("merge", "/script.js"))
+		defer func() { assert.NoError(t, convertCmd.Flags().Set("merge", "")) }()
+
+		// The first conversion creates the script.
+		err = convertCmd.RunE(convertCmd, []string{"/input.har"})
+		assert.NoError(t, err)
+		script, err := afero.ReadFile(defaultFs, "/script.js")
+		assert.NoError(t, err)
+		assert.Contains(t, string(script), "// k6-convert:begin ")
+
+		// Later conversions keep the manual edits.
+		edited := strings.Replace(string(script), "res = http.batch(req);", "res = http.batch(req);\n\t\tcheck(res[0], { ok: (r) => r.status === 200 });", 1)
+		edited = strings.Replace(edited, "export default function() {", "// my notes\nexport default function() {", 1)
+		assert.NoError(t, afero.WriteFile(defaultFs, "/script.js", []byte(edited), 0644))
+
+		err = convertCmd.RunE(convertCmd, []string{"/input.har"})
+		assert.NoError(t, err)
+		script, err = afero.ReadFile(defaultFs, "/script.js")
+		assert.NoError(t, err)
+		assert.Equal(t, edited, string(script))
+	})
+}
+
+func TestConvertHARForRun(t *testing.T) {
+	testdata := map[string]string{
+		testHAR:                              typeHAR,
+		`{"log": {"entries": []}}`:           typeHAR,
+		`{"log": {}}`:                        typeJS,
+		`{"entries": []}`:                    typeJS,
+		`{ let a = 1; }`:                     typeJS,
+		"export default function() {}":       typeJS,
+		"exports.default = function() {};\n": typeJS,
+	}
+	for data, typ := range testdata {
+		assert.Equal(t, typ, detectType([]byte(data)), data)
+	}
+
+	script, err := convertHARForRun([]byte(testHAR))
+	assert.NoError(t, err)
+	assert.Contains(t, script, "import { group, check, sleep } from 'k6';")
+	assert.Contains(t, script, `"url": "https://golang.org/"`)
+	assert.Contains(t, script, "res = http.batch(req);")
+
+	// Recordings don't have to say what made them.
+	script, err = convertHARForRun([]byte(`{"log": {"entries": []}}`))
+	assert.NoError(t, err)
+	assert.Contains(t, script, "export default function()")
+
+	_, err = convertHARForRun([]byte(`{"log": {"entries": {}}}`))
+	assert.Error(t, err)
 }
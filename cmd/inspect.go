@@ -17,80 +17,152 @@
  * along with this program.  If not, see <http://www.gnu.org/licenses/>.
  *
  */
-
 package cmd
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 
-	"github.com/loadimpact/k6/js"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
-// inspectCmd represents the resume command
+var (
+	inspectFiles     = false
+	inspectScenarios = false
+)
+
+// An inspectReport is what inspect prints with --files or --scenarios.
+type inspectReport struct {
+	Options   lib.Options       `json:"options"`
+	Files     []string          `json:"files,omitempty"`
+	Scenarios []inspectScenario `json:"scenarios,omitempty"`
+}
+
+// An inspectScenario sums up one of the test's scenarios.
+type inspectScenario struct {
+	Name        string         `json:"name"`
+	Executor    string         `json:"executor"`
+	Exec        string         `json:"exec"`
+	StartTime   types.Duration `json:"startTime"`
+	Duration    types.Duration `json:"duration"`
+	MaxVUs      int64          `json:"maxVUs"`
+	Description string         `json:"description"`
+}
+
+// inspectCmd represents the inspect command
 var inspectCmd = &cobra.Command{
 	Use:   "inspect [file]",
 	Short: "Inspect a script or archive",
-	Long:  `Inspect a script or archive.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Inspect a script or archive.
+
+Loads the script or archive, resolving its imports, and prints the test's options
+as JSON, consolidated from the command line, the config file, the script and the
+environment the same way "k6 run" does, without running the test.
+
+With --files or --scenarios, the options are printed under "options", next to the
+files that would be bundled into an archive, or a summary of each scenario.`,
+	Example: `
+  # Print the options a test would run with.
+  k6 inspect -u 10 -d 30s script.js
+
+  # Also list the files an archive of the test would contain, and its scenarios.
+  k6 inspect --files --scenarios script.js`[1:],
+	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		pwd, err := os.Getwd()
 		if err != nil {
 			return err
 		}
 		fs := afero.NewOsFs()
+		if err := setupModuleCache(cmd.Flags(), fs); err != nil {
+			return err
+		}
 		src, err := readSource(args[0], pwd, fs, os.Stdin)
 		if err != nil {
 			return err
 		}
 
-		typ := runType
-		if typ == "" {
-			typ = detectType(src.Data)
-		}
-
 		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
 		if err != nil {
 			return err
 		}
+		r, err := newRunner(src, runType, fs, runtimeOptions)
+		if err != nil {
+			return err
+		}
+		conf, err := getConsolidatedConfig(fs, cmd.Flags(), r)
+		if err != nil {
+			return err
+		}
+		r.SetOptions(conf.Options)
 
-		var opts lib.Options
-		switch typ {
-		case typeArchive:
-			arc, err := lib.ReadArchive(bytes.NewBuffer(src.Data))
-			if err != nil {
-				return err
-			}
-			b, err := js.NewBundleFromArchive(arc, runtimeOptions)
-			if err != nil {
-				return err
+		var v interface{} = conf.Options
+		if inspectFiles || inspectScenarios {
+			report := inspectReport{Options: conf.Options}
+			if inspectFiles {
+				report.Files = archiveFiles(r.MakeArchive())
 			}
-			opts = b.Options
-		case typeJS:
-			b, err := js.NewBundle(src, fs, runtimeOptions)
-			if err != nil {
-				return err
+			if inspectScenarios {
+				report.Scenarios = describeScenarios(conf.Scenarios)
 			}
-			opts = b.Options
+			v = report
 		}
-
-		data, err := json.MarshalIndent(opts, "", "  ")
+		data, err := json.MarshalIndent(v, "", "  ")
 		if err != nil {
 			return err
 		}
-		fmt.Println(string(data))
-		return nil
+		_, err = fmt.Fprintln(stdout, string(data))
+		return err
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(inspectCmd)
 	inspectCmd.Flags().SortFlags = false
+	inspectCmd.Flags().AddFlagSet(optionFlagSet())
 	inspectCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
-	inspectCmd.Flags().StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\" or \"archive\"")
+	inspectCmd.Flags().AddFlagSet(moduleCacheFlagSet())
+	inspectCmd.Flags().AddFlagSet(configFlagSet())
+	inspectCmd.Flags().StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\", \"archive\" or \"har\"")
+	inspectCmd.Flags().BoolVar(&inspectFiles, "files", inspectFiles, "list the files an archive of the test would contain")
+	inspectCmd.Flags().BoolVar(&inspectScenarios, "scenarios", inspectScenarios, "describe the test's scenarios")
+}
+
+// Lists the main script, the scripts it imports and the files it opens, sorted.
+func archiveFiles(arc *lib.Archive) []string {
+	seen := map[string]bool{arc.Filename: true}
+	files := []string{arc.Filename}
+	for _, m := range []map[string][]byte{arc.Scripts, arc.Files} {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				files = append(files, name)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Sums up the scenarios, by name, with their start times resolved.
+func describeScenarios(scenarios lib.Scenarios) []inspectScenario {
+	descs := make([]inspectScenario, 0, len(scenarios))
+	for _, name := range scenarios.Names() {
+		sc := scenarios[name]
+		descs = append(descs, inspectScenario{
+			Name:        name,
+			Executor:    sc.Executor,
+			Exec:        sc.GetExec(),
+			StartTime:   types.Duration(scenarios.GetStartTime(name)),
+			Duration:    types.Duration(sc.GetDuration()),
+			MaxVUs:      sc.GetMaxVUs(),
+			Description: sc.Description(),
+		})
+	}
+	return descs
 }
@@ -0,0 +1,77 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestArchiveFiles(t *testing.T) {
+	assert.Equal(t, []string{"/data.json", "/lib.js", "/script.js"}, archiveFiles(&lib.Archive{
+		Filename: "/script.js",
+		Scripts:  map[string][]byte{"/script.js": nil, "/lib.js": nil},
+		Files:    map[string][]byte{"/data.json": nil},
+	}))
+}
+
+func TestDescribeScenarios(t *testing.T) {
+	assert.Equal(t, []inspectScenario{}, describeScenarios(nil))
+
+	scenarios := lib.Scenarios{
+		"b": {
+			Executor:   lib.SharedIterationsExecutor,
+			StartAfter: null.StringFrom("a"),
+			Exec:       null.StringFrom("fn"),
+			VUs:        null.IntFrom(2),
+			Iterations: null.IntFrom(10),
+		},
+		"a": {
+			Executor: lib.ConstantVUsExecutor,
+			VUs:      null.IntFrom(5),
+			Duration: types.NullDurationFrom(30 * time.Second),
+		},
+	}
+	assert.Equal(t, []inspectScenario{
+		{
+			Name:        "a",
+			Executor:    lib.ConstantVUsExecutor,
+			Exec:        "default",
+			Duration:    types.Duration(30 * time.Second),
+			MaxVUs:      5,
+			Description: scenarios["a"].Description(),
+		},
+		{
+			Name:        "b",
+			Executor:    lib.SharedIterationsExecutor,
+			Exec:        "fn",
+			StartTime:   types.Duration(30 * time.Second),
+			Duration:    types.Duration(10 * time.Minute),
+			MaxVUs:      2,
+			Description: scenarios["b"].Description(),
+		},
+	}, describeScenarios(scenarios))
+}
@@ -0,0 +1,259 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/js"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultLokiURL    = "http://localhost:3100/loki/api/v1/push"
+	defaultLokiPeriod = time.Second
+	lokiPushTimeout   = 10 * time.Second
+
+	// Lines past this many, waiting for a push, are dropped rather than kept in memory.
+	lokiMaxPending = 100000
+)
+
+// lokiHook pushes log entries to Loki every period, in streams labeled with the test run's ID,
+// the scenario a line was logged in, if any, its level and whether a script or k6 logged it.
+type lokiHook struct {
+	url     string
+	labels  map[string]string
+	period  time.Duration
+	limiter *consoleLimiter
+	client  *http.Client
+
+	lock    sync.Mutex
+	pending []lokiLine
+	dropped int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type lokiLine struct {
+	labels map[string]string
+	time   time.Time
+	line   string
+}
+
+// lokiStream is a stream in the push API's JSON format; values are [<unix ns>, <line>] pairs.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func newLokiHook(out logOutput) (*lokiHook, error) {
+	labels := map[string]string{}
+	for k, v := range out.Labels {
+		labels[k] = v
+	}
+	if labels["test_run_id"] == "" {
+		id, err := newTestRunID()
+		if err != nil {
+			return nil, err
+		}
+		labels["test_run_id"] = id
+	}
+	h := &lokiHook{
+		url:    out.Target,
+		labels: labels,
+		period: out.Period,
+		client: &http.Client{Timeout: lokiPushTimeout},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if out.Limit > 0 {
+		h.limiter = newConsoleLimiter(out.Limit)
+	}
+	go h.run()
+	return h, nil
+}
+
+// newTestRunID makes up an ID for a test run that wasn't given one, to tell its logs apart.
+func newTestRunID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func (h *lokiHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *lokiHook) Fire(entry *log.Entry) error {
+	ok, dropped := h.limiter.allow(entry)
+	if !ok {
+		return nil
+	}
+	if dropped > 0 {
+		if err := h.add(droppedEntry(entry.Logger, dropped)); err != nil {
+			return err
+		}
+	}
+	return h.add(entry)
+}
+
+// add formats an entry with its logger's formatter, which keeps secrets out of it, and queues it.
+func (h *lokiHook) add(entry *log.Entry) error {
+	data, err := entry.Logger.Formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	labels := make(map[string]string, len(h.labels)+3)
+	for k, v := range h.labels {
+		labels[k] = v
+	}
+	labels["level"] = entry.Level.String()
+	labels["source"] = "k6"
+	if entry.Data["source"] == js.ConsoleSource {
+		labels["source"] = js.ConsoleSource
+	}
+	if scenario, ok := entry.Data["scenario"].(string); ok && scenario != "" {
+		labels["scenario"] = scenario
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.pending) >= lokiMaxPending {
+		h.dropped++
+		return nil
+	}
+	h.pending = append(h.pending, lokiLine{labels, entry.Time, strings.TrimRight(string(data), "\n")})
+	return nil
+}
+
+func (h *lokiHook) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.flush()
+		case <-h.stop:
+			h.flush()
+			return
+		}
+	}
+}
+
+// Close pushes what's left, and stops pushing.
+func (h *lokiHook) Close() error {
+	close(h.stop)
+	<-h.done
+	return nil
+}
+
+// flush pushes the lines logged since the last push. Failures can't be logged, since that would
+// loop back here, so they're written to stderr, and the lines are lost.
+func (h *lokiHook) flush() {
+	h.lock.Lock()
+	lines, dropped := h.pending, h.dropped
+	h.pending, h.dropped = nil, 0
+	h.lock.Unlock()
+
+	if dropped > 0 {
+		_, _ = fmt.Fprintf(stderr, "Loki: %d log lines were dropped while waiting to be pushed\n", dropped)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if err := h.push(lokiStreams(lines)); err != nil {
+		_, _ = fmt.Fprintf(stderr, "Loki: couldn't push %d log lines: %s\n", len(lines), err)
+	}
+}
+
+// lokiStreams groups lines by their labels, in the order their streams were first logged to.
+func lokiStreams(lines []lokiLine) []*lokiStream {
+	var streams []*lokiStream
+	byKey := make(map[string]*lokiStream)
+	for _, l := range lines {
+		key := lokiLabelsKey(l.labels)
+		s, ok := byKey[key]
+		if !ok {
+			s = &lokiStream{Stream: l.labels}
+			byKey[key] = s
+			streams = append(streams, s)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(l.time.UnixNano(), 10), l.line})
+	}
+	return streams
+}
+
+func lokiLabelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// push posts streams to Loki's push API, which accepts them with a 204.
+func (h *lokiHook) push(streams []*lokiStream) error {
+	body, err := json.Marshal(map[string]interface{}{"streams": streams})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.Errorf("%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/loadimpact/k6/js"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// logOutput is where logs go, as set with --log-output: "stderr", "stdout", "none",
+// "file=<path>" or "loki=<push URL>", optionally followed by ",level=<level>" and
+// ",limit=<lines/s>"; Loki also takes ",label.<name>=<value>" and ",period=<duration>".
+type logOutput struct {
+	Type   string
+	Target string
+	Level  log.Level
+	// The number of lines scripts may log every second; 0 is no limit.
+	Limit float64
+
+	// Extra labels of the lines pushed to Loki, and how often they're pushed.
+	Labels map[string]string
+	Period time.Duration
+
+	levelSet bool
+}
+
+// lokiLabelName is what Loki allows label names to be.
+var lokiLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func parseLogOutput(s string) (logOutput, error) {
+	var out logOutput
+	parts := strings.Split(s, ",")
+	kv := strings.SplitN(parts[0], "=", 2)
+	out.Type = kv[0]
+	if len(kv) > 1 {
+		out.Target = kv[1]
+	}
+	switch out.Type {
+	case "stderr", "stdout", "none":
+		if out.Target != "" {
+			return out, errors.Errorf("log output '%s' doesn't take a target", out.Type)
+		}
+	case "file":
+		if out.Target == "" {
+			return out, errors.New("log output 'file' needs a path, eg. file=k6.log")
+		}
+	case "loki":
+		if out.Target == "" {
+			out.Target = defaultLokiURL
+		}
+		u, err := url.Parse(out.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return out, errors.Errorf("invalid loki push URL '%s'", out.Target)
+		}
+		out.Period = defaultLokiPeriod
+	default:
+		return out, errors.Errorf(
+			"unknown log output '%s', expected stderr, stdout, none, file=<path> or loki=<push URL>", out.Type)
+	}
+
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return out, errors.Errorf("invalid log output option '%s', expected key=value", part)
+		}
+		switch kv[0] {
+		case "level":
+			level, err := log.ParseLevel(kv[1])
+			if err != nil {
+				return out, err
+			}
+			out.Level, out.levelSet = level, true
+		case "limit":
+			limit, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil || limit < 0 {
+				return out, errors.Errorf("invalid log output limit '%s', expected a number of lines per second", kv[1])
+			}
+			out.Limit = limit
+		case "period":
+			if out.Type != "loki" {
+				return out, errors.New("the period option is only for the loki log output")
+			}
+			period, err := time.ParseDuration(kv[1])
+			if err != nil || period <= 0 {
+				return out, errors.Errorf("invalid log output period '%s'", kv[1])
+			}
+			out.Period = period
+		default:
+			if name := strings.TrimPrefix(kv[0], "label."); name != kv[0] && out.Type == "loki" {
+				if !lokiLabelName.MatchString(name) {
+					return out, errors.Errorf("invalid loki label name '%s'", name)
+				}
+				if out.Labels == nil {
+					out.Labels = make(map[string]string)
+				}
+				out.Labels[name] = kv[1]
+				continue
+			}
+			return out, errors.Errorf("unknown log output option '%s'", kv[0])
+		}
+	}
+	return out, nil
+}
+
+// writer opens the log output.
+func (o logOutput) writer() (io.Writer, error) {
+	switch o.Type {
+	case "stdout":
+		return stdout, nil
+	case "none", "loki":
+		return ioutil.Discard, nil
+	case "file":
+		return os.OpenFile(o.Target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	default:
+		return stderr, nil
+	}
+}
+
+// consoleLimiter rate limits the entries scripts log, and counts the ones it drops.
+type consoleLimiter struct {
+	limiter *rate.Limiter
+	dropped int64
+}
+
+func newConsoleLimiter(limit float64) *consoleLimiter {
+	// A second's worth of burst, so that a test's first lines aren't dropped for being together.
+	burst := int(limit)
+	if burst < 1 {
+		burst = 1
+	}
+	return &consoleLimiter{limiter: rate.NewLimiter(rate.Limit(limit), burst)}
+}
+
+// allow returns whether an entry may be logged, and if so, how many were dropped before it.
+func (l *consoleLimiter) allow(entry *log.Entry) (bool, int64) {
+	if l == nil || entry.Data["source"] != js.ConsoleSource {
+		return true, 0
+	}
+	if !l.limiter.Allow() {
+		atomic.AddInt64(&l.dropped, 1)
+		return false, 0
+	}
+	return true, atomic.SwapInt64(&l.dropped, 0)
+}
+
+// droppedEntry reports entries a consoleLimiter dropped.
+func droppedEntry(logger *log.Logger, dropped int64) *log.Entry {
+	e := log.NewEntry(logger).WithField("source", js.ConsoleSource)
+	e.Time, e.Level = time.Now(), log.WarnLevel
+	e.Message = strconv.FormatInt(dropped, 10) + " console messages were dropped over the log output's limit"
+	return e
+}
+
+// consoleLimitFormatter drops the entries scripts log once they're over a rate limit. It's a
+// formatter, like the secrets one, because logrus writes nothing for an empty entry.
+type consoleLimitFormatter struct {
+	log.Formatter
+	limiter *consoleLimiter
+}
+
+func (f *consoleLimitFormatter) Format(entry *log.Entry) ([]byte, error) {
+	ok, dropped := f.limiter.allow(entry)
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := f.Formatter.Format(entry)
+	if err != nil || dropped == 0 {
+		return data, err
+	}
+	// Say how much was lost before the next line that goes through.
+	note, err := f.Formatter.Format(droppedEntry(entry.Logger, dropped))
+	if err != nil {
+		return nil, err
+	}
+	return append(note, data...), nil
+}
@@ -0,0 +1,154 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/js"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestParseLogOutput(t *testing.T) {
+	testdata := map[string]struct {
+		out logOutput
+		err string
+	}{
+		"stderr":                    {out: logOutput{Type: "stderr"}},
+		"none":                      {out: logOutput{Type: "none"}},
+		"stdout,limit=10":           {out: logOutput{Type: "stdout", Limit: 10}},
+		"file=k6.log,level=warning": {out: logOutput{Type: "file", Target: "k6.log", Level: log.WarnLevel, levelSet: true}},
+		"file=k6.log,limit=0.5":     {out: logOutput{Type: "file", Target: "k6.log", Limit: 0.5}},
+		"file":                      {err: "log output 'file' needs a path, eg. file=k6.log"},
+		"stderr=x":                  {err: "log output 'stderr' doesn't take a target"},
+		"syslog":                    {err: "unknown log output 'syslog', expected stderr, stdout, none, file=<path> or loki=<push URL>"},
+		"loki":                      {out: logOutput{Type: "loki", Target: defaultLokiURL, Period: defaultLokiPeriod}},
+		"loki=https://logs.example.com/loki/api/v1/push,label.env=staging,period=5s,limit=10": {out: logOutput{
+			Type: "loki", Target: "https://logs.example.com/loki/api/v1/push",
+			Labels: map[string]string{"env": "staging"}, Period: 5 * time.Second, Limit: 10,
+		}},
+		"loki=localhost:3100":        {err: "invalid loki push URL 'localhost:3100'"},
+		"loki,period=0s":             {err: "invalid log output period '0s'"},
+		"loki,label.test-run=1":      {err: "invalid loki label name 'test-run'"},
+		"stderr,period=1s":           {err: "the period option is only for the loki log output"},
+		"stderr,label.env=ci":        {err: "unknown log output option 'label.env'"},
+		"stderr,limit=-1":            {err: "invalid log output limit '-1', expected a number of lines per second"},
+		"stderr,limit":               {err: "invalid log output option 'limit', expected key=value"},
+		"stderr,color=1":             {err: "unknown log output option 'color'"},
+		"stderr,level=loud":          {err: `not a valid logrus Level: "loud"`},
+		"file=k6.log,level=debug,x=": {err: "unknown log output option 'x'"},
+	}
+	for s, data := range testdata {
+		t.Run(s, func(t *testing.T) {
+			out, err := parseLogOutput(s)
+			if data.err != "" {
+				assert.EqualError(t, err, data.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, data.out, out)
+		})
+	}
+}
+
+func TestConsoleLimitFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.Out = &buf
+	f := &consoleLimitFormatter{&log.TextFormatter{DisableColors: true, DisableTimestamp: true}, newConsoleLimiter(2)}
+	logger.Formatter = f
+
+	console := logger.WithField("source", js.ConsoleSource)
+	for i := 0; i < 5; i++ {
+		console.Info("script")
+	}
+	logger.Info("k6")
+	assert.Equal(t, 2, strings.Count(buf.String(), "msg=script"))
+	assert.Equal(t, 1, strings.Count(buf.String(), "msg=k6"))
+	assert.Equal(t, int64(3), f.limiter.dropped)
+
+	// The drops are reported with the next line that goes through.
+	buf.Reset()
+	f.limiter.limiter.SetLimit(rate.Inf)
+	console.Info("script")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `msg="3 console messages were dropped over the log output's limit"`)
+		assert.Contains(t, lines[1], "msg=script")
+	}
+	assert.Equal(t, int64(0), f.limiter.dropped)
+}
+
+func TestLokiHook(t *testing.T) {
+	var lock sync.Mutex
+	var streams []lokiStream
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Streams []lokiStream }
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		lock.Lock()
+		streams = append(streams, body.Streams...)
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	hook, err := newLokiHook(logOutput{
+		Type: "loki", Target: srv.URL, Period: time.Hour, Labels: map[string]string{"env": "ci"},
+	})
+	require.NoError(t, err)
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.Formatter = &log.TextFormatter{DisableColors: true, DisableTimestamp: true}
+	logger.AddHook(hook)
+
+	logger.Info("k6")
+	logger.WithFields(log.Fields{"source": js.ConsoleSource, "scenario": "checkout"}).Warn("script")
+	logger.WithFields(log.Fields{"source": js.ConsoleSource, "scenario": "checkout"}).Warn("again")
+	require.NoError(t, hook.Close())
+
+	require.Len(t, streams, 2)
+	runID := hook.labels["test_run_id"]
+	assert.Len(t, runID, 16)
+	assert.Equal(t, map[string]string{
+		"env": "ci", "test_run_id": runID, "level": "info", "source": "k6",
+	}, streams[0].Stream)
+	if assert.Len(t, streams[0].Values, 1) {
+		assert.Equal(t, "level=info msg=k6", streams[0].Values[0][1])
+	}
+	assert.Equal(t, map[string]string{
+		"env": "ci", "test_run_id": runID, "level": "warning", "source": "console", "scenario": "checkout",
+	}, streams[1].Stream)
+	if assert.Len(t, streams[1].Values, 2) {
+		assert.Contains(t, streams[1].Values[0][1], "msg=script")
+		assert.Contains(t, streams[1].Values[1][1], "msg=again")
+	}
+}
@@ -21,6 +21,14 @@
 package cmd
 
 import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shibukawa/configdir"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
@@ -32,7 +40,11 @@ var loginCmd = &cobra.Command{
 
 Logging into a service changes the default when just "-o [type]" is passed with
 no parameters, you can always override the stored credentials by passing some
-on the commandline.`,
+on the commandline.
+
+Credentials are stored in the config file given with -c, or k6's config file in
+the user's config directory, which only the user can read. Secrets can be piped
+in on stdin rather than passed as flags, to keep them out of the shell history.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return cmd.Usage()
 	},
@@ -40,4 +52,24 @@ on the commandline.`,
 
 func init() {
 	RootCmd.AddCommand(loginCmd)
+	loginCmd.PersistentFlags().AddFlagSet(configFileFlagSet())
+}
+
+// Reads the config credentials are stored in; unlike for other commands, the file given with -c
+// doesn't need to exist yet.
+func readLoginConfig(fs afero.Fs) (Config, *configdir.Config, error) {
+	conf, cdir, err := readDiskConfig(fs)
+	if os.IsNotExist(errors.Cause(err)) {
+		return Config{}, cdir, nil
+	}
+	return conf, cdir, err
+}
+
+// Reads a secret from the first line of r, eg. for --token-stdin.
+func readSecret(r io.Reader) (string, error) {
+	s, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(s, "\r\n"), nil
 }
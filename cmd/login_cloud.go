@@ -29,6 +29,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	null "gopkg.in/guregu/null.v3"
 )
 
 // loginCloudCommand represents the 'login cloud' command
@@ -45,18 +46,31 @@ This will set the default token used when just "k6 run -o cloud" is passed.`,
   # Store a token.
   k6 login cloud -t YOUR_TOKEN
 
+  # Store a token, without it ending up in the shell history.
+  cat token.txt | k6 login cloud --token-stdin
+
   # Log in with an email/password.
   k6 login cloud`[1:],
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fs := afero.NewOsFs()
-		config, cdir, err := readDiskConfig(fs)
+		config, cdir, err := readLoginConfig(fs)
 		if err != nil {
 			return err
 		}
 
 		show := getNullBool(cmd.Flags(), "show")
 		token := getNullString(cmd.Flags(), "token")
+		if getNullBool(cmd.Flags(), "token-stdin").Bool {
+			if token.Valid {
+				return errors.New("--token and --token-stdin can't be used together")
+			}
+			s, err := readSecret(os.Stdin)
+			if err != nil {
+				return err
+			}
+			token = null.StringFrom(s)
+		}
 
 		conf := config.Collectors.Cloud
 
@@ -71,7 +85,7 @@ This will set the default token used when just "k6 run -o cloud" is passed.`,
 						Key:   "Email",
 						Label: "Email",
 					},
-					ui.StringField{
+					ui.PasswordField{
 						Key:   "Password",
 						Label: "Password",
 					},
@@ -110,5 +124,6 @@ This will set the default token used when just "k6 run -o cloud" is passed.`,
 func init() {
 	loginCmd.AddCommand(loginCloudCommand)
 	loginCloudCommand.Flags().StringP("token", "t", "", "specify `token` to use")
+	loginCloudCommand.Flags().Bool("token-stdin", false, "read the token from stdin")
 	loginCloudCommand.Flags().BoolP("show", "s", false, "display saved token and exit")
 }
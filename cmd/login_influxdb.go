@@ -27,6 +27,7 @@ import (
 	"github.com/loadimpact/k6/stats/influxdb"
 	"github.com/loadimpact/k6/ui"
 	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
@@ -37,58 +38,81 @@ var loginInfluxDBCommand = &cobra.Command{
 	Short: "Authenticate with InfluxDB",
 	Long: `Authenticate with InfluxDB.
 
-This will set the default server used when just "-o influxdb" is passed.`,
+This will set the default server used when just "-o influxdb" is passed.
+
+Unless the server is given as an argument, or with any of the flags, the
+details are asked for; those of InfluxDB 2 if an organization, bucket or token
+is already stored. The server is pinged before anything is stored.`,
+	Example: `
+  # Enter the server's details.
+  k6 login influxdb
+
+  # Store them without being asked, reading the password from stdin.
+  cat password.txt | k6 login influxdb --username k6 --password-stdin http://localhost:8086/k6
+
+  # Store the details of an InfluxDB 2 server.
+  cat token.txt | k6 login influxdb --org myorg --bucket k6 --token-stdin http://localhost:8086`[1:],
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fs := afero.NewOsFs()
-		config, cdir, err := readDiskConfig(fs)
+		config, cdir, err := readLoginConfig(fs)
 		if err != nil {
 			return err
 		}
 
 		conf := influxdb.NewConfig().Apply(config.Collectors.InfluxDB)
+		prompt := len(args) == 0
 		if len(args) > 0 {
 			if err := conf.UnmarshalText([]byte(args[0])); err != nil {
 				return err
 			}
 		}
+		for _, name := range []string{"db", "username", "password-stdin", "org", "bucket", "token-stdin"} {
+			if cmd.Flags().Changed(name) {
+				prompt = false
+			}
+		}
+		if db := getNullString(cmd.Flags(), "db"); db.Valid {
+			conf.DB = db.String
+		}
+		if username := getNullString(cmd.Flags(), "username"); username.Valid {
+			conf.Username = username.String
+		}
+		if org := getNullString(cmd.Flags(), "org"); org.Valid {
+			conf.Organization = org.String
+		}
+		if bucket := getNullString(cmd.Flags(), "bucket"); bucket.Valid {
+			conf.Bucket = bucket.String
+		}
+		passwordStdin := getNullBool(cmd.Flags(), "password-stdin").Bool
+		tokenStdin := getNullBool(cmd.Flags(), "token-stdin").Bool
+		switch {
+		case passwordStdin && tokenStdin:
+			return errors.New("--password-stdin and --token-stdin can't be used together")
+		case passwordStdin:
+			if conf.Password, err = readSecret(os.Stdin); err != nil {
+				return err
+			}
+		case tokenStdin:
+			if conf.Token, err = readSecret(os.Stdin); err != nil {
+				return err
+			}
+		}
 		if conf.Addr == "" {
 			conf.Addr = "http://localhost:8086"
 		}
-		if conf.DB == "" {
+		if conf.DB == "" && !conf.IsV2() {
 			conf.DB = "k6"
 		}
 
-		form := ui.Form{
-			Fields: []ui.Field{
-				ui.StringField{
-					Key:     "Addr",
-					Label:   "Address",
-					Default: conf.Addr,
-				},
-				ui.StringField{
-					Key:     "DB",
-					Label:   "Database",
-					Default: conf.DB,
-				},
-				ui.StringField{
-					Key:     "Username",
-					Label:   "Username",
-					Default: conf.Username,
-				},
-				ui.StringField{
-					Key:     "Password",
-					Label:   "Password",
-					Default: conf.Password,
-				},
-			},
-		}
-		vals, err := form.Run(os.Stdin, stdout)
-		if err != nil {
-			return err
-		}
-		if err := mapstructure.Decode(vals, &conf); err != nil {
-			return err
+		if prompt {
+			vals, err := influxDBLoginForm(conf).Run(os.Stdin, stdout)
+			if err != nil {
+				return err
+			}
+			if err := mapstructure.Decode(vals, &conf); err != nil {
+				return err
+			}
 		}
 
 		coll, err := influxdb.New(conf)
@@ -106,4 +130,33 @@ This will set the default server used when just "-o influxdb" is passed.`,
 
 func init() {
 	loginCmd.AddCommand(loginInfluxDBCommand)
+	loginInfluxDBCommand.Flags().String("db", "", "the `database` to write to")
+	loginInfluxDBCommand.Flags().String("username", "", "the `username` to authenticate with")
+	loginInfluxDBCommand.Flags().Bool("password-stdin", false, "read the password from stdin")
+	loginInfluxDBCommand.Flags().String("org", "", "the `organization` of an InfluxDB 2 server")
+	loginInfluxDBCommand.Flags().String("bucket", "", "the `bucket` of an InfluxDB 2 server to write to")
+	loginInfluxDBCommand.Flags().Bool("token-stdin", false, "read the token of an InfluxDB 2 server from stdin")
+}
+
+// The form that asks for the details of an InfluxDB server, or an InfluxDB 2 one, with what's
+// already stored as the defaults.
+func influxDBLoginForm(conf influxdb.Config) ui.Form {
+	if conf.IsV2() {
+		return ui.Form{
+			Fields: []ui.Field{
+				ui.StringField{Key: "Addr", Label: "Address", Default: conf.Addr},
+				ui.StringField{Key: "Organization", Label: "Organization", Default: conf.Organization},
+				ui.StringField{Key: "Bucket", Label: "Bucket", Default: conf.Bucket},
+				ui.PasswordField{Key: "Token", Label: "Token", Default: conf.Token},
+			},
+		}
+	}
+	return ui.Form{
+		Fields: []ui.Field{
+			ui.StringField{Key: "Addr", Label: "Address", Default: conf.Addr},
+			ui.StringField{Key: "DB", Label: "Database", Default: conf.DB},
+			ui.StringField{Key: "Username", Label: "Username", Default: conf.Username},
+			ui.PasswordField{Key: "Password", Label: "Password", Default: conf.Password},
+		},
+	}
 }
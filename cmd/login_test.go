@@ -0,0 +1,104 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loadimpact/k6/stats/influxdb"
+	"github.com/loadimpact/k6/ui"
+	"github.com/shibukawa/configdir"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSecret(t *testing.T) {
+	for in, secret := range map[string]string{
+		"":              "",
+		"token":         "token",
+		"token\n":       "token",
+		"token\r\nmore": "token",
+		" spaced \n":    " spaced ",
+	} {
+		s, err := readSecret(strings.NewReader(in))
+		assert.NoError(t, err)
+		assert.Equal(t, secret, s, "%q", in)
+	}
+}
+
+func TestWriteDiskConfig(t *testing.T) {
+	defer func(old string) { configFile = old }(configFile)
+
+	var conf Config
+	conf.Collectors.InfluxDB.Password = "s3cret"
+
+	t.Run("Config Dir", func(t *testing.T) {
+		configFile = ""
+		fs := afero.NewMemMapFs()
+		cdir := &configdir.Config{Path: "/home/user/.config/loadimpact/k6"}
+		require.NoError(t, writeDiskConfig(fs, cdir, conf))
+
+		filename := filepath.Join(cdir.Path, configFilename)
+		info, err := fs.Stat(filename)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		data, err := afero.ReadFile(fs, filename)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"password": "s3cret"`)
+	})
+	t.Run("Config File", func(t *testing.T) {
+		configFile = "/k6.json"
+		fs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, configFile, []byte("{}"), 0644))
+		require.NoError(t, writeDiskConfig(fs, nil, conf))
+
+		info, err := fs.Stat(configFile)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		read, _, err := readLoginConfig(fs)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", read.Collectors.InfluxDB.Password)
+	})
+	t.Run("Missing Config File", func(t *testing.T) {
+		configFile = "/k6.json"
+		conf, _, err := readLoginConfig(afero.NewMemMapFs())
+		require.NoError(t, err)
+		assert.Equal(t, Config{}, conf)
+	})
+}
+
+func TestInfluxDBLoginForm(t *testing.T) {
+	keys := func(form ui.Form) []string {
+		var keys []string
+		for _, field := range form.Fields {
+			keys = append(keys, field.GetKey())
+		}
+		return keys
+	}
+	assert.Equal(t, []string{"Addr", "DB", "Username", "Password"}, keys(influxDBLoginForm(influxdb.Config{})))
+	assert.Equal(t, []string{"Addr", "Organization", "Bucket", "Token"}, keys(influxDBLoginForm(influxdb.Config{Bucket: "k6"})))
+	assert.IsType(t, ui.PasswordField{}, influxDBLoginForm(influxdb.Config{}).Fields[3])
+}
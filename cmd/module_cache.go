@@ -0,0 +1,64 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/loadimpact/k6/loader"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+func moduleCacheFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("", 0)
+	flags.SortFlags = false
+	flags.String("module-cache-dir", "", "cache remote modules in `dir` (default: a k6 directory in the user's cache directory)")
+	flags.String("lockfile", "", "pin remote modules to the hashes in `file`, adding any that aren't in it yet")
+	flags.Bool("offline", false, "never fetch remote modules, only use cached ones")
+	flags.Bool("frozen", false, "require every remote module to be pinned in the lockfile, and never update it")
+	return flags
+}
+
+// Sets up the cache that remote modules are loaded through.
+func setupModuleCache(flags *pflag.FlagSet, fs afero.Fs) error {
+	conf := loader.ModuleCacheConfig{
+		Dir:      getNullString(flags, "module-cache-dir").String,
+		Lockfile: getNullString(flags, "lockfile").String,
+		Offline:  getNullBool(flags, "offline").Bool,
+		Frozen:   getNullBool(flags, "frozen").Bool,
+	}
+	if conf.Dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			cacheDir = os.TempDir()
+		}
+		conf.Dir = filepath.Join(cacheDir, "k6", "modules")
+	}
+
+	cache, err := loader.NewModuleCache(fs, conf)
+	if err != nil {
+		return err
+	}
+	loader.SetModuleCache(cache)
+	return nil
+}
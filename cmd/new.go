@@ -0,0 +1,176 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/shibukawa/configdir"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newOutput = "script.js"
+	newForce  bool
+	newList   bool
+)
+
+// What script templates are executed with.
+type newScriptData struct {
+	// The base name of the script being created.
+	ScriptName string
+}
+
+var newCmd = &cobra.Command{
+	Use:   "new [template]",
+	Short: "Create a new test script from a template",
+	Long: `Create a new test script from a template.
+
+The template is one of the built-in ones (see --list), or the name of one installed as a .js file
+in the templates directory of k6's config directory, or the path to a template file. Templates
+are Go text/templates; {{.ScriptName}} is the name of the script being created.`,
+	Example: `
+  # Create script.js from the basic template.
+  k6 new
+
+  # Create an API test in api-test.js.
+  k6 new api -O api-test.js
+
+  # List the built-in and installed templates.
+  k6 new --list
+
+  # Create a script from a template your team shares.
+  k6 new -O checkout.js templates/team-conventions.js`[1:],
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := templatesDir()
+		if newList {
+			return listTemplates(defaultFs, defaultWriter, dir)
+		}
+
+		name := defaultTemplate
+		if len(args) > 0 {
+			name = args[0]
+		}
+		src, err := getTemplate(defaultFs, dir, name)
+		if err != nil {
+			return err
+		}
+		script, err := renderTemplate(name, src, newScriptData{ScriptName: filepath.Base(newOutput)})
+		if err != nil {
+			return err
+		}
+
+		if newOutput == "-" {
+			_, err := io.WriteString(defaultWriter, script)
+			return err
+		}
+		if ok, _ := afero.Exists(defaultFs, newOutput); ok && !newForce {
+			return errors.Errorf("%s already exists, use --force to overwrite it", newOutput)
+		}
+		if err := afero.WriteFile(defaultFs, newOutput, []byte(script), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(defaultWriter, "Created %s from the %s template; run it with `k6 run %s`\n", newOutput, name, newOutput)
+		return nil
+	},
+}
+
+// Returns where templates are installed: a templates directory in k6's config directory.
+func templatesDir() string {
+	return filepath.Join(configDirs.QueryFolders(configdir.Global)[0].Path, "templates")
+}
+
+// Returns the source of a template: a built-in one, an installed one, or a file.
+func getTemplate(fs afero.Fs, dir, name string) (string, error) {
+	if tmpl, ok := builtinTemplates[name]; ok {
+		return tmpl.Source, nil
+	}
+	for _, filename := range []string{filepath.Join(dir, name+".js"), name} {
+		data, err := afero.ReadFile(fs, filename)
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", errors.Errorf("unknown template: %s, see `k6 new --list`", name)
+}
+
+func renderTemplate(name, src string, data newScriptData) (string, error) {
+	tmpl, err := template.New(name).Parse(src)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid template")
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "invalid template")
+	}
+	return buf.String(), nil
+}
+
+func listTemplates(fs afero.Fs, w io.Writer, dir string) error {
+	names := make([]string, 0, len(builtinTemplates))
+	for name := range builtinTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Built-in templates:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, builtinTemplates[name].Description)
+	}
+
+	files, err := afero.ReadDir(fs, dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var installed []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".js") {
+			installed = append(installed, strings.TrimSuffix(file.Name(), ".js"))
+		}
+	}
+	if len(installed) > 0 {
+		fmt.Fprintf(w, "\nInstalled templates, in %s:\n", dir)
+		for _, name := range installed {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(newCmd)
+	newCmd.Flags().SortFlags = false
+	newCmd.Flags().StringVarP(&newOutput, "output", "O", newOutput, "script `filename`, or - for stdout")
+	newCmd.Flags().BoolVar(&newForce, "force", false, "overwrite the script if it already exists")
+	newCmd.Flags().BoolVar(&newList, "list", false, "list the available templates, and exit")
+}
@@ -0,0 +1,267 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+// The templates `k6 new` can create scripts from. They're text/templates, executed with a
+// newScriptData, and should show the structure of a test well enough to be a starting point.
+
+type scriptTemplate struct {
+	Description string
+	Source      string
+}
+
+var builtinTemplates = map[string]scriptTemplate{
+	"basic":     {"Load a web page with ramping VUs, checks and thresholds", basicTemplate},
+	"api":       {"Test a JSON API, with setup(), groups and per-endpoint thresholds", apiTemplate},
+	"browser":   {"Load pages with their static resources, like a mix of real browsers", browserTemplate},
+	"websocket": {"Open WebSocket connections that send and receive messages", websocketTemplate},
+}
+
+const defaultTemplate = "basic"
+
+const basicTemplate = `// {{.ScriptName}}: a k6 load test, created with ` + "`k6 new basic`" + `.
+// Run it with ` + "`k6 run {{.ScriptName}}`" + `; see https://docs.k6.io for everything scripts can do.
+
+import http from "k6/http";
+import { check, sleep } from "k6";
+
+// The site under test; override it with ` + "`k6 run -e BASE_URL=https://example.com {{.ScriptName}}`" + `.
+const BASE_URL = __ENV.BASE_URL || "https://test.loadimpact.com";
+
+// Options control how the test runs. This ramps up to 10 virtual users (VUs), holds, and ramps down.
+export let options = {
+    stages: [
+        { duration: "30s", target: 10 },
+        { duration: "1m", target: 10 },
+        { duration: "30s", target: 0 },
+    ],
+
+    // Thresholds are the test's pass/fail criteria; k6 exits with a non-zero code if any fail.
+    thresholds: {
+        http_req_duration: ["p(95)<500"],
+        http_req_failed: ["rate<0.01"],
+        checks: ["rate>0.99"],
+    },
+};
+
+// Code out here is the init context: it runs once per VU, before the test, and is the only place
+// that can import modules and open() files.
+
+// The default function is what each VU runs, over and over, for as long as the test lasts.
+export default function() {
+    let res = http.get(BASE_URL + "/");
+
+    // Checks validate responses without stopping the test; their pass rate is in the summary.
+    check(res, {
+        "status is 200": (r) => r.status === 200,
+    });
+
+    // Think time between iterations, like a real user.
+    sleep(1);
+}
+`
+
+const apiTemplate = `// {{.ScriptName}}: a k6 API test, created with ` + "`k6 new api`" + `.
+// Run it with ` + "`k6 run {{.ScriptName}}`" + `; see https://docs.k6.io for everything scripts can do.
+
+import http from "k6/http";
+import { check, group, sleep } from "k6";
+
+// The API under test; override it with ` + "`k6 run -e BASE_URL=https://api.example.com {{.ScriptName}}`" + `.
+const BASE_URL = __ENV.BASE_URL || "https://test-api.loadimpact.com";
+
+export let options = {
+    vus: 10,
+    duration: "1m",
+
+    // Requests are tagged with the name of their endpoint, so that each can have thresholds of its own.
+    thresholds: {
+        "http_req_duration{name:list}": ["p(95)<300"],
+        "http_req_duration{name:item}": ["p(95)<200"],
+        http_req_failed: ["rate<0.01"],
+        checks: ["rate>0.99"],
+    },
+};
+
+// setup() runs once, before the VUs start; what it returns is passed to every iteration. This is
+// the place to log in, or to create test data. teardown(data) can clean up after the test.
+export function setup() {
+    let params = { headers: { "Accept": "application/json" } };
+    // Pass a token with ` + "`-e API_TOKEN=...`" + `, or log in here and take it from the response.
+    if (__ENV.API_TOKEN) {
+        params.headers["Authorization"] = "Bearer " + __ENV.API_TOKEN;
+    }
+    return { params: params };
+}
+
+export default function(data) {
+    let ids = [];
+
+    // Groups organize the results in the summary, like the steps of a user flow.
+    group("list", function() {
+        let res = http.get(BASE_URL + "/public/crocodiles/", Object.assign({ tags: { name: "list" } }, data.params));
+        check(res, {
+            "status is 200": (r) => r.status === 200,
+            "returns a list": (r) => Array.isArray(r.json()),
+        });
+        if (res.status === 200) {
+            ids = res.json().map((item) => item.id);
+        }
+    });
+
+    group("item", function() {
+        if (ids.length === 0) {
+            return;
+        }
+        let id = ids[Math.floor(Math.random() * ids.length)];
+        let res = http.get(BASE_URL + "/public/crocodiles/" + id + "/", Object.assign({ tags: { name: "item" } }, data.params));
+        check(res, {
+            "status is 200": (r) => r.status === 200,
+            "has the right id": (r) => r.json().id === id,
+        });
+    });
+
+    sleep(1);
+}
+`
+
+const browserTemplate = `// {{.ScriptName}}: a k6 test of page loads, created with ` + "`k6 new browser`" + `.
+// Run it with ` + "`k6 run {{.ScriptName}}`" + `; see https://docs.k6.io for everything scripts can do.
+//
+// k6 simulates browsers at the protocol level: each VU sends the headers of a real browser, and
+// loads the static resources of the pages it visits in parallel, like a browser would.
+
+import http from "k6/http";
+import { check, group, sleep } from "k6";
+
+// The site under test; override it with ` + "`k6 run -e BASE_URL=https://example.com {{.ScriptName}}`" + `.
+const BASE_URL = __ENV.BASE_URL || "https://test.loadimpact.com";
+
+export let options = {
+    stages: [
+        { duration: "1m", target: 20 },
+        { duration: "3m", target: 20 },
+        { duration: "1m", target: 0 },
+    ],
+
+    // The mix of browsers the VUs pretend to be, by weight.
+    clientProfiles: { "chrome-desktop": 60, "safari-ios": 25, "firefox-desktop": 15 },
+
+    thresholds: {
+        "http_req_duration{resource:page}": ["p(95)<1000"],
+        "http_req_duration{resource:static}": ["p(95)<500"],
+        http_req_failed: ["rate<0.01"],
+    },
+};
+
+// Turns the URLs in a page into absolute ones.
+function absolute(url) {
+    if (/^https?:\/\//.test(url)) {
+        return url;
+    }
+    if (url.indexOf("//") === 0) {
+        return BASE_URL.split(":")[0] + ":" + url;
+    }
+    return BASE_URL + (url[0] === "/" ? "" : "/") + url;
+}
+
+// Loads a page, and then the scripts, stylesheets and images it references.
+function loadPage(path) {
+    let res = http.get(BASE_URL + path, { tags: { resource: "page" } });
+    check(res, {
+        "page status is 200": (r) => r.status === 200,
+    });
+    if (res.status !== 200) {
+        return res;
+    }
+
+    let resources = res.html().find("script[src], img[src], link[rel=stylesheet][href]");
+    let requests = [];
+    for (let i = 0; i < resources.size(); i++) {
+        let el = resources.eq(i);
+        let url = el.attr("src") || el.attr("href");
+        if (url && url.indexOf("data:") !== 0) {
+            requests.push(["GET", absolute(url), null, { tags: { resource: "static" } }]);
+        }
+    }
+    http.batch(requests);
+    return res;
+}
+
+export default function() {
+    group("home page", function() {
+        loadPage("/");
+    });
+
+    // Users read pages before moving on.
+    sleep(Math.random() * 3 + 2);
+}
+`
+
+const websocketTemplate = `// {{.ScriptName}}: a k6 WebSocket test, created with ` + "`k6 new websocket`" + `.
+// Run it with ` + "`k6 run {{.ScriptName}}`" + `; see https://docs.k6.io for everything scripts can do.
+
+import ws from "k6/ws";
+import { check } from "k6";
+
+// The server under test; override it with ` + "`k6 run -e WS_URL=wss://example.com/ws {{.ScriptName}}`" + `.
+const WS_URL = __ENV.WS_URL || "wss://echo.websocket.org";
+
+export let options = {
+    vus: 10,
+    duration: "1m",
+
+    thresholds: {
+        ws_connecting: ["p(95)<500"],
+        checks: ["rate>0.99"],
+    },
+};
+
+// Each iteration holds a connection open, sending a message every second, until it's closed.
+export default function() {
+    let res = ws.connect(WS_URL, {}, function(socket) {
+        socket.on("open", function() {
+            socket.setInterval(function() {
+                socket.send(JSON.stringify({ sent: Date.now() }));
+            }, 1000);
+        });
+
+        socket.on("message", function(message) {
+            check(message, {
+                "message is JSON": (m) => JSON.parse(m).sent > 0,
+            });
+        });
+
+        socket.on("error", function(e) {
+            console.error("WebSocket error: " + e.error());
+        });
+
+        // Close the connection after 10 seconds, and start over with a new one.
+        socket.setTimeout(function() {
+            socket.close();
+        }, 10000);
+    });
+
+    check(res, {
+        "status is 101": (r) => r && r.status === 101,
+    });
+}
+`
@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/js/compiler"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	for name, tmpl := range builtinTemplates {
+		t.Run(name, func(t *testing.T) {
+			script, err := renderTemplate(name, tmpl.Source, newScriptData{ScriptName: "test.js"})
+			require.NoError(t, err)
+			assert.Contains(t, script, "k6 run test.js")
+			assert.Contains(t, script, "export default function")
+			assert.Contains(t, script, "thresholds: {")
+
+			// The scripts must at least parse.
+			_, err = compiler.StripTypes(script, "test.js")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewCmd(t *testing.T) {
+	defer func() {
+		newOutput, newForce, newList = "script.js", false, false
+	}()
+	dir := templatesDir()
+
+	defaultFs = afero.NewMemMapFs()
+	require.NoError(t, defaultFs.MkdirAll(dir, 0755))
+	require.NoError(t, afero.WriteFile(defaultFs, filepath.Join(dir, "team.js"), []byte(`// {{.ScriptName}}, the team way`), 0644))
+	require.NoError(t, afero.WriteFile(defaultFs, "/shared.js", []byte(`// shared {{.ScriptName}}`), 0644))
+
+	testdata := map[string]struct {
+		args   []string
+		output string
+		script string
+	}{
+		"Default":   {nil, "/script.js", "// script.js: a k6 load test, created with `k6 new basic`."},
+		"Builtin":   {[]string{"api"}, "/api.js", "// api.js: a k6 API test, created with `k6 new api`."},
+		"Installed": {[]string{"team"}, "/team-test.js", "// team-test.js, the team way"},
+		"File":      {[]string{"/shared.js"}, "/out.js", "// shared out.js"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			newOutput = data.output
+			defaultWriter = &bytes.Buffer{}
+			require.NoError(t, newCmd.RunE(newCmd, data.args))
+			script, err := afero.ReadFile(defaultFs, data.output)
+			require.NoError(t, err)
+			assert.Contains(t, string(script), data.script)
+		})
+	}
+
+	t.Run("Exists", func(t *testing.T) {
+		newOutput = "/script.js"
+		err := newCmd.RunE(newCmd, []string{"api"})
+		assert.EqualError(t, err, "/script.js already exists, use --force to overwrite it")
+
+		newForce = true
+		defer func() { newForce = false }()
+		assert.NoError(t, newCmd.RunE(newCmd, []string{"api"}))
+		script, err := afero.ReadFile(defaultFs, "/script.js")
+		require.NoError(t, err)
+		assert.Contains(t, string(script), "k6 new api")
+	})
+
+	t.Run("Stdout", func(t *testing.T) {
+		newOutput = "-"
+		buf := &bytes.Buffer{}
+		defaultWriter = buf
+		require.NoError(t, newCmd.RunE(newCmd, []string{"websocket"}))
+		assert.Contains(t, buf.String(), `import ws from "k6/ws";`)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		err := newCmd.RunE(newCmd, []string{"grpc"})
+		assert.EqualError(t, err, "unknown template: grpc, see `k6 new --list`")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		newList = true
+		defer func() { newList = false }()
+		buf := &bytes.Buffer{}
+		defaultWriter = buf
+		require.NoError(t, newCmd.RunE(newCmd, nil))
+		assert.Contains(t, buf.String(), "  browser    Load pages with their static resources")
+		assert.Contains(t, buf.String(), "Installed templates, in "+dir+":\n  team\n")
+	})
+}
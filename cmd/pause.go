@@ -36,6 +36,10 @@ var pauseCmd = &cobra.Command{
 	Short: "Pause a running test",
 	Long: `Pause a running test.
 
+  No new iterations are started while the test is paused, and its time and stages
+  stand still; iterations already running are finished. Use "k6 resume" to resume it,
+  and "k6 run --paused" to start a test paused.
+
   Use the global --address flag to specify the URL to the API server.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		c, err := client.New(address)
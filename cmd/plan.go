@@ -0,0 +1,198 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/ui"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+var (
+	planIterationDuration    = time.Second
+	planRequestsPerIteration = 1.0
+	planJSON                 = false
+)
+
+// The number of VUs that are instantiated to estimate the memory needs of each.
+const planSampleVUs = 3
+
+// A planReport is a plan with a memory estimate for it.
+type planReport struct {
+	local.Plan
+
+	MemoryPerVU uint64 `json:"memoryPerVU"`
+	Memory      uint64 `json:"memory"`
+}
+
+// planCmd represents the plan command.
+var planCmd = &cobra.Command{
+	Use:   "plan [file]",
+	Short: "Estimate what running a test will take",
+	Long: `Estimate what running a test will take.
+
+Consolidates the test's options the same way "k6 run" does, and prints the
+maximum number of VUs, the expected number of iterations and requests in each
+stage, and roughly how much memory the VUs will need.
+
+Iteration and request counts depend on how long an iteration takes and how
+many requests it makes, which can't be known without running the script; use
+--iteration-duration and --requests-per-iteration to describe them. Memory use
+is extrapolated from a few VUs that are instantiated for the purpose.`,
+	Example: `
+  # Estimate a test that ramps up to 100 VUs.
+  k6 plan -s 10s:100 -s 1m -s 10s:0 script.js
+
+  # Iterations take around 3s and make 5 requests each.
+  k6 plan --iteration-duration 3s --requests-per-iteration 5 script.js`[1:],
+	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		fs := afero.NewOsFs()
+		if err := setupModuleCache(cmd.Flags(), fs); err != nil {
+			return err
+		}
+		src, err := readSource(args[0], pwd, fs, os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		r, err := newRunner(src, runType, fs, runtimeOptions)
+		if err != nil {
+			return err
+		}
+		conf, err := getConsolidatedConfig(fs, cmd.Flags(), r)
+		if err != nil {
+			return err
+		}
+		r.SetOptions(conf.Options)
+
+		report := planReport{Plan: local.NewPlan(conf.Options, planIterationDuration, planRequestsPerIteration)}
+		report.MemoryPerVU, err = estimateVUMemory(r, planSampleVUs)
+		if err != nil {
+			return err
+		}
+		report.Memory = report.MemoryPerVU * uint64(report.MaxVUs)
+
+		if planJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(stdout, string(data))
+			return err
+		}
+		printPlan(stdout, report)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().SortFlags = false
+	planCmd.Flags().AddFlagSet(optionFlagSet())
+	planCmd.Flags().AddFlagSet(runtimeOptionFlagSet(true))
+	planCmd.Flags().AddFlagSet(moduleCacheFlagSet())
+	planCmd.Flags().AddFlagSet(configFlagSet())
+	planCmd.Flags().StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\", \"archive\" or \"har\"")
+	planCmd.Flags().DurationVar(&planIterationDuration, "iteration-duration", planIterationDuration, "expected `duration` of one iteration")
+	planCmd.Flags().Float64Var(&planRequestsPerIteration, "requests-per-iteration", planRequestsPerIteration, "expected number of requests made by one iteration")
+	planCmd.Flags().BoolVar(&planJSON, "json", planJSON, "print the plan as JSON")
+}
+
+// Instantiates a few VUs and measures how much heap each of them takes.
+func estimateVUMemory(r lib.Runner, n int) (uint64, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	vus := make([]lib.VU, n)
+	for i := range vus {
+		vu, err := r.NewVU()
+		if err != nil {
+			return 0, err
+		}
+		vus[i] = vu
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(vus)
+
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0, nil
+	}
+	return (after.HeapAlloc - before.HeapAlloc) / uint64(n), nil
+}
+
+func printPlan(w io.Writer, report planReport) {
+	duration := ui.ValueColor.Sprint("unbounded")
+	if report.Duration.Valid {
+		duration = ui.ValueColor.Sprint(time.Duration(report.Duration.Duration))
+	}
+	approx := func(v float64) string {
+		s := ui.ValueColor.Sprint(humanize.Comma(int64(math.Ceil(v))))
+		if !report.Finite {
+			s += ui.ExtraColor.Sprint(" (before unbounded stage)")
+		}
+		return s
+	}
+
+	fmt.Fprintf(w, "     max vus: %s\n", ui.ValueColor.Sprint(report.MaxVUs))
+	fmt.Fprintf(w, "    duration: %s\n", duration)
+	fmt.Fprintf(w, "  iterations: %s\n", approx(report.Iterations))
+	fmt.Fprintf(w, "    requests: %s\n", approx(report.Requests))
+	fmt.Fprintf(w, "      memory: %s%s\n",
+		ui.ValueColor.Sprint(humanize.Bytes(report.Memory)),
+		ui.ExtraColor.Sprintf(" (%s/VU)", humanize.Bytes(report.MemoryPerVU)),
+	)
+	fmt.Fprintf(w, "\n")
+
+	for i, stage := range report.Stages {
+		d, iters, reqs := ui.GrayColor.Sprint("-"), ui.GrayColor.Sprint("-"), ui.GrayColor.Sprint("-")
+		if stage.Duration.Valid {
+			d = ui.ValueColor.Sprint(time.Duration(stage.Duration.Duration))
+			iters = ui.ValueColor.Sprint(humanize.Comma(int64(math.Ceil(stage.Iterations))))
+			reqs = ui.ValueColor.Sprint(humanize.Comma(int64(math.Ceil(stage.Requests))))
+		}
+		fmt.Fprintf(w, "  stage #%d: %s, %s -> %s VUs, %s iterations, %s requests\n",
+			i, d, ui.ValueColor.Sprint(stage.StartVUs), ui.ValueColor.Sprint(stage.EndVUs), iters, reqs)
+	}
+}
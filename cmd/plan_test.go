@@ -0,0 +1,84 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/js"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestEstimateVUMemory(t *testing.T) {
+	r, err := js.New(&lib.SourceData{
+		Filename: "/script.js",
+		Data:     []byte(`var data = []; for (var i = 0; i < 100000; i++) { data.push("item" + i); }; exports.default = function() {};`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	mem, err := estimateVUMemory(r, 2)
+	require.NoError(t, err)
+	assert.True(t, mem > 1<<20, "expected each VU to take over 1MB, got %d", mem)
+}
+
+func TestPrintPlan(t *testing.T) {
+	t.Run("Finite", func(t *testing.T) {
+		var buf bytes.Buffer
+		printPlan(&buf, planReport{
+			Plan: local.NewPlan(lib.Options{
+				VUs:      null.IntFrom(10),
+				VUsMax:   null.IntFrom(10),
+				Duration: types.NullDurationFrom(10 * time.Second),
+			}, time.Second, 200),
+			MemoryPerVU: 1000000,
+			Memory:      10000000,
+		})
+		assert.Equal(t, ""+
+			"     max vus: 10\n"+
+			"    duration: 10s\n"+
+			"  iterations: 100\n"+
+			"    requests: 20,000\n"+
+			"      memory: 10 MB (1.0 MB/VU)\n"+
+			"\n"+
+			"  stage #0: 10s, 10 -> 10 VUs, 100 iterations, 20,000 requests\n",
+			buf.String())
+	})
+	t.Run("Infinite", func(t *testing.T) {
+		var buf bytes.Buffer
+		printPlan(&buf, planReport{
+			Plan: local.NewPlan(lib.Options{
+				VUs:    null.IntFrom(1),
+				VUsMax: null.IntFrom(1),
+				Stages: []lib.Stage{{}},
+			}, time.Second, 1),
+		})
+		assert.Contains(t, buf.String(), "duration: unbounded\n")
+		assert.Contains(t, buf.String(), "stage #0: -, 1 -> 1 VUs, - iterations, - requests\n")
+	})
+}
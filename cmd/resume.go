@@ -36,6 +36,8 @@ var resumeCmd = &cobra.Command{
 	Short: "Resume a paused test",
 	Long: `Resume a paused test.
 
+  The test carries on where it was paused, as if no time had passed.
+
   Use the global --address flag to specify the URL to the API server.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		c, err := client.New(address)
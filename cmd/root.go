@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"io"
 	"os"
 	"sync"
 
@@ -56,7 +57,11 @@ var (
 	quiet   bool
 	noColor bool
 	logFmt  string
+	logOut  string
 	address string
+
+	// closeLogs flushes the log output, for the ones that don't write right away.
+	closeLogs = func() {}
 )
 
 // RootCmd represents the base command when called without any subcommands.
@@ -66,20 +71,27 @@ var RootCmd = &cobra.Command{
 	Long:          BannerColor.Sprint(Banner),
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		setupLoggers(logFmt)
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupLoggers(logFmt, logOut); err != nil {
+			return err
+		}
 		if noColor {
 			stdout.Writer = colorable.NewNonColorable(os.Stdout)
 			stdout.Writer = colorable.NewNonColorable(os.Stderr)
 		}
+		return nil
 	},
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	err := RootCmd.Execute()
+	if err != nil {
 		log.Error(err.Error())
+	}
+	closeLogs()
+	if err != nil {
 		if e, ok := err.(ExitCode); ok {
 			os.Exit(e.Code)
 		}
@@ -92,24 +104,64 @@ func init() {
 	RootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "disable progress updates")
 	RootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output")
 	RootCmd.PersistentFlags().StringVar(&logFmt, "logformat", "", "log output format")
+	RootCmd.PersistentFlags().StringVar(&logOut, "log-output", "stderr",
+		"where logs go: stderr, stdout, none, file=<path> or loki=<push URL>, with optional level=<level> and limit=<script lines/s>")
 	RootCmd.PersistentFlags().StringVarP(&address, "address", "a", "localhost:6565", "address for the api server")
 	RootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default ./k6.yaml or ~/.config/k6.yaml)")
 	must(cobra.MarkFlagFilename(RootCmd.PersistentFlags(), "config"))
 }
 
-func setupLoggers(logFmt string) {
+func setupLoggers(logFmt, logOut string) error {
+	out, err := parseLogOutput(logOut)
+	if err != nil {
+		return err
+	}
+	w, err := out.writer()
+	if err != nil {
+		return err
+	}
+
 	if verbose {
 		log.SetLevel(log.DebugLevel)
 	}
-	log.SetOutput(stderr)
+	if out.levelSet {
+		log.SetLevel(out.Level)
+	}
+	log.SetOutput(w)
+	if out.Type == "loki" {
+		hook, err := newLokiHook(out)
+		if err != nil {
+			return err
+		}
+		log.AddHook(hook)
+		closeLogs = func() { _ = hook.Close() }
+	}
 
+	var formatter log.Formatter
 	switch logFmt {
 	case "json":
-		log.SetFormatter(&log.JSONFormatter{})
-		log.Debug("Logger format: JSON")
+		formatter = &log.JSONFormatter{}
 	default:
-		log.SetFormatter(&log.TextFormatter{ForceColors: stderrTTY})
+		formatter = &log.TextFormatter{ForceColors: isTTYWriter(w)}
+	}
+	// Loki rate limits what it pushes itself, since it formats entries with this.
+	if out.Limit > 0 && out.Type != "loki" {
+		formatter = &consoleLimitFormatter{formatter, newConsoleLimiter(out.Limit)}
+	}
+	log.SetFormatter(formatter)
+
+	if logFmt == "json" {
+		log.Debug("Logger format: JSON")
+	} else {
 		log.Debug("Logger format: TEXT")
 	}
+	return nil
+}
 
+// isTTYWriter returns whether logs written to w go to a terminal, and can have colors.
+func isTTYWriter(w io.Writer) bool {
+	if cw, ok := w.(consoleWriter); ok {
+		return cw.IsTTY
+	}
+	return false
 }
@@ -33,12 +33,14 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/loadimpact/k6/api"
 	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/distributed"
 	"github.com/loadimpact/k6/core/local"
 	"github.com/loadimpact/k6/js"
 	"github.com/loadimpact/k6/lib"
@@ -49,18 +51,23 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
-	null "gopkg.in/guregu/null.v3"
 )
 
 const (
 	typeJS      = "js"
 	typeArchive = "archive"
+	typeHAR     = "har"
 )
 
 var (
 	runType       = os.Getenv("K6_TYPE")
 	runNoSetup    = os.Getenv("K6_NO_SETUP") != ""
 	runNoTeardown = os.Getenv("K6_NO_TEARDOWN") != ""
+	runDumpConfig = false
+
+	runAgents             = 0
+	runCoordinatorAddress = "localhost:6566"
+	runCoordinatorToken   = "" // Not taken from the environment here, so it's not printed in --help.
 )
 
 // runCmd represents the run command.
@@ -70,7 +77,12 @@ var runCmd = &cobra.Command{
 	Long: `Start a load test.
 
 This also exposes a REST API to interact with it. Various k6 subcommands offer
-a commandline interface for interacting with it.`,
+a commandline interface for interacting with it.
+
+Options are taken from, in increasing order of precedence: their defaults, the
+config file, K6_* environment variables, the command line, and the options the
+script exports. --dump-config prints what they add up to, without running the
+test.`,
 	Example: `
   # Run a single VU, once.
   k6 run script.js
@@ -84,14 +96,28 @@ a commandline interface for interacting with it.`,
   # Run 5 VUs for 10s.
   k6 run -u 5 -d 10s script.js
 
+  # Replay a HAR recording with 5 VUs for 10s, without converting it first.
+  k6 run -u 5 -d 10s session.har
+
   # Ramp VUs from 0 to 100 over 10s, stay there for 60s, then 10s down to 0.
   k6 run -u 0 -s 10s:100 -s 60s -s 10s:0
 
   # Send metrics to an influxdb server
-  k6 run -o influxdb=http://1.2.3.4:8086/k6`[1:],
+  k6 run -o influxdb=http://1.2.3.4:8086/k6
+
+  # Split a test among 3 agents, started with: k6 agent --token secret 1.2.3.4:6566
+  k6 run --agents 3 --coordinator-address 1.2.3.4:6566 --coordinator-token secret script.js
+
+  # Run the first of 3 equal parts of a test, the other instances run 1/3:2/3 and 2/3:1.
+  k6 run --execution-segment 0:1/3 --execution-segment-sequence 0,1/3,2/3,1 script.js
+
+  # Print the configuration a test would run with, given a YAML config file.
+  k6 run -c k6.yaml --dump-config script.js`[1:],
 	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		_, _ = BannerColor.Fprint(stdout, Banner+"\n\n")
+		if !runDumpConfig {
+			_, _ = BannerColor.Fprint(stdout, Banner+"\n\n")
+		}
 
 		initBar := ui.ProgressBar{
 			Width: 60,
@@ -99,13 +125,18 @@ a commandline interface for interacting with it.`,
 		}
 
 		// Create the Runner.
-		fmt.Fprintf(stdout, "%s runner\r", initBar.String())
+		if !runDumpConfig {
+			fmt.Fprintf(stdout, "%s runner\r", initBar.String())
+		}
 		pwd, err := os.Getwd()
 		if err != nil {
 			return err
 		}
 		filename := args[0]
 		fs := afero.NewOsFs()
+		if err := setupModuleCache(cmd.Flags(), fs); err != nil {
+			return err
+		}
 		src, err := readSource(filename, pwd, fs, os.Stdin)
 		if err != nil {
 			return err
@@ -115,58 +146,77 @@ a commandline interface for interacting with it.`,
 		if err != nil {
 			return err
 		}
+		warnExperimentalFeatures(runtimeOptions.Enable)
 
 		r, err := newRunner(src, runType, afero.NewOsFs(), runtimeOptions)
 		if err != nil {
+			if isScriptError(err) {
+				return ExitCode{err, scriptErrorExitCode}
+			}
 			return err
 		}
 
-		// Assemble options; start with the CLI-provided options to get shadowed (non-Valid)
-		// defaults in there, override with Runner-provided ones, then merge the CLI opts in
-		// on top to give them priority.
-		fmt.Fprintf(stdout, "%s options\r", initBar.String())
-		cliConf, err := getConfig(cmd.Flags())
-		if err != nil {
-			return err
+		// Assemble options.
+		if !runDumpConfig {
+			fmt.Fprintf(stdout, "%s options\r", initBar.String())
 		}
-		fileConf, _, err := readDiskConfig(fs)
+		conf, err := getConsolidatedConfig(fs, cmd.Flags(), r)
 		if err != nil {
 			return err
 		}
-		envConf, err := readEnvConfig()
-		if err != nil {
+		if runDumpConfig {
+			data, err := json.MarshalIndent(conf, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(stdout, string(data))
 			return err
 		}
-		conf := cliConf.Apply(fileConf).Apply(Config{Options: r.GetOptions()}).Apply(envConf).Apply(cliConf)
 
-		// If -m/--max isn't specified, figure out the max that should be needed.
-		if !conf.VUsMax.Valid {
-			conf.VUsMax = null.IntFrom(conf.VUs.Int64)
-			for _, stage := range conf.Stages {
-				if stage.Target.Valid && stage.Target.Int64 > conf.VUsMax.Int64 {
-					conf.VUsMax = stage.Target
-				}
-			}
-		}
-		// If -d/--duration, -i/--iterations and -s/--stage are all unset, run to one iteration.
-		if !conf.Duration.Valid && !conf.Iterations.Valid && conf.Stages == nil {
-			conf.Iterations = null.IntFrom(1)
-		}
-		// If duration is explicitly set to 0, it means run forever.
-		if conf.Duration.Valid && conf.Duration.Duration == 0 {
-			conf.Duration = types.NullDuration{}
-		}
 		// If summary trend stats are defined, update the UI to reflect them
 		if len(conf.SummaryTrendStats) > 0 {
 			ui.UpdateTrendColumns(conf.SummaryTrendStats)
 		}
 
+		// Give the test run its ID, and tag all samples with it and the run's metadata.
+		if conf.Options, err = conf.Options.WithRunMetadata(); err != nil {
+			return err
+		}
+
 		// Write options back to the runner too.
 		r.SetOptions(conf.Options)
 
-		// Create a local executor wrapping the runner.
+		// Create a local executor wrapping the runner, or a coordinator that runs the test on
+		// its agents, and serve them.
 		fmt.Fprintf(stdout, "%s executor\r", initBar.String())
-		ex := local.New(r)
+		var ex lib.Executor = local.New(r)
+		execution := "local"
+		if runAgents > 0 {
+			if conf.ExecutionSegment.Valid() {
+				return errors.New("a distributed test is split into execution segments for its agents, it can't be given one")
+			}
+			if runCoordinatorToken == "" {
+				runCoordinatorToken = os.Getenv("K6_COORDINATOR_TOKEN")
+			}
+			if runCoordinatorToken == "" {
+				return errors.New("--agents needs a --coordinator-token, or K6_COORDINATOR_TOKEN, for the agents to authenticate with")
+			}
+			if _, err := distributed.Split(conf.Scenarios, runAgents); err != nil {
+				return err
+			}
+			coordinator := distributed.NewCoordinator(r, runAgents)
+			coordinator.Token = runCoordinatorToken
+			go func() {
+				if err := http.ListenAndServe(runCoordinatorAddress, coordinator); err != nil {
+					log.WithError(err).Error("Error from the coordinator's server")
+				}
+			}()
+			ex = coordinator
+			execution = fmt.Sprintf("distributed, %d agents at %s", runAgents, runCoordinatorAddress)
+		}
+		if conf.ExecutionSegment.Valid() {
+			execution = fmt.Sprintf("local, segment %s", conf.ExecutionSegment)
+		}
 		if runNoSetup {
 			ex.SetRunSetup(false)
 		}
@@ -185,11 +235,18 @@ a commandline interface for interacting with it.`,
 		if conf.NoThresholds.Valid {
 			engine.NoThresholds = conf.NoThresholds.Bool
 		}
+		if conf.NoSummary.Valid {
+			engine.NoSummary = conf.NoSummary.Bool
+		}
 
-		// Create a collector and assign it to the engine if requested.
+		// Create the collectors and assign them to the engine, if any were requested.
 		fmt.Fprintf(stdout, "%s   collector\r", initBar.String())
-		if conf.Out.Valid {
-			t, arg := parseCollector(conf.Out.String)
+		var outs []string
+		for _, out := range conf.Out {
+			if out == "" {
+				continue
+			}
+			t, arg := parseCollector(out)
 			collector, err := newCollector(t, arg, src, conf)
 			if err != nil {
 				return err
@@ -197,7 +254,8 @@ a commandline interface for interacting with it.`,
 			if err := collector.Init(); err != nil {
 				return err
 			}
-			engine.Collector = collector
+			engine.Collectors = append(engine.Collectors, collector)
+			outs = append(outs, out)
 		}
 
 		// Create an API server.
@@ -210,40 +268,52 @@ a commandline interface for interacting with it.`,
 
 		// Write the big banner.
 		{
-			out := "-"
-			link := ""
-			if engine.Collector != nil {
-				out = conf.Out.String
-				if l := engine.Collector.Link(); l != "" {
-					link = " (" + l + ")"
+			out := ui.ValueColor.Sprint("-")
+			if len(engine.Collectors) > 0 {
+				descs := make([]string, len(engine.Collectors))
+				for i, collector := range engine.Collectors {
+					descs[i] = ui.ValueColor.Sprint(outs[i])
+					if l := collector.Link(); l != "" {
+						descs[i] += ui.ExtraColor.Sprint(" (" + l + ")")
+					}
 				}
+				out = strings.Join(descs, ", ")
 			}
 
-			fmt.Fprintf(stdout, "  execution: %s\n", ui.ValueColor.Sprint("local"))
-			fmt.Fprintf(stdout, "     output: %s%s\n", ui.ValueColor.Sprint(out), ui.ExtraColor.Sprint(link))
+			fmt.Fprintf(stdout, "  execution: %s\n", ui.ValueColor.Sprint(execution))
+			fmt.Fprintf(stdout, "     output: %s\n", out)
 			fmt.Fprintf(stdout, "     script: %s\n", ui.ValueColor.Sprint(filename))
+			fmt.Fprintf(stdout, "     run id: %s\n", ui.ValueColor.Sprint(conf.RunID.String))
 			fmt.Fprintf(stdout, "\n")
 
-			duration := ui.GrayColor.Sprint("-")
-			iterations := ui.GrayColor.Sprint("-")
-			if conf.Duration.Valid {
-				duration = ui.ValueColor.Sprint(conf.Duration.Duration)
-			}
-			if conf.Iterations.Valid {
-				iterations = ui.ValueColor.Sprint(conf.Iterations.Int64)
-			}
-			vus := ui.ValueColor.Sprint(conf.VUs.Int64)
-			max := ui.ValueColor.Sprint(conf.VUsMax.Int64)
+			if len(conf.Scenarios) > 0 {
+				fmt.Fprintf(stdout, "   scenarios: %s\n", ui.ValueColor.Sprintf(
+					"%d scenarios, %d max VUs", len(conf.Scenarios), conf.Scenarios.GetMaxVUs()))
+				for _, name := range conf.Scenarios.Names() {
+					fmt.Fprintf(stdout, "              * %s: %s\n", name, conf.Scenarios[name].Description())
+				}
+			} else {
+				duration := ui.GrayColor.Sprint("-")
+				iterations := ui.GrayColor.Sprint("-")
+				if conf.Duration.Valid {
+					duration = ui.ValueColor.Sprint(conf.Duration.Duration)
+				}
+				if conf.Iterations.Valid {
+					iterations = ui.ValueColor.Sprint(conf.Iterations.Int64)
+				}
+				vus := ui.ValueColor.Sprint(conf.VUs.Int64)
+				max := ui.ValueColor.Sprint(conf.VUsMax.Int64)
 
-			leftWidth := ui.StrWidth(duration)
-			if l := ui.StrWidth(vus); l > leftWidth {
-				leftWidth = l
-			}
-			durationPad := strings.Repeat(" ", leftWidth-ui.StrWidth(duration))
-			vusPad := strings.Repeat(" ", leftWidth-ui.StrWidth(vus))
+				leftWidth := ui.StrWidth(duration)
+				if l := ui.StrWidth(vus); l > leftWidth {
+					leftWidth = l
+				}
+				durationPad := strings.Repeat(" ", leftWidth-ui.StrWidth(duration))
+				vusPad := strings.Repeat(" ", leftWidth-ui.StrWidth(vus))
 
-			fmt.Fprintf(stdout, "    duration: %s,%s iterations: %s\n", duration, durationPad, iterations)
-			fmt.Fprintf(stdout, "         vus: %s,%s max: %s\n", vus, vusPad, max)
+				fmt.Fprintf(stdout, "    duration: %s,%s iterations: %s\n", duration, durationPad, iterations)
+				fmt.Fprintf(stdout, "         vus: %s,%s max: %s\n", vus, vusPad, max)
+			}
 			fmt.Fprintf(stdout, "\n")
 		}
 
@@ -252,6 +322,10 @@ a commandline interface for interacting with it.`,
 		ctx, cancel := context.WithCancel(context.Background())
 		errC := make(chan error)
 		go func() { errC <- engine.Run(ctx) }()
+		if engine.Executor.IsPaused() {
+			log.Infof("The test is paused; resume it with `k6 resume --address %s`, "+
+				"or a PATCH of /v1/status in the REST API", address)
+		}
 
 		// Trap Interrupts, SIGINTs and SIGTERMs.
 		sigC := make(chan os.Signal, 1)
@@ -307,12 +381,7 @@ a commandline interface for interacting with it.`,
 				}
 				precision := 100 * time.Millisecond
 				atT := engine.Executor.GetTime()
-				stagesEndT := lib.SumStages(engine.Executor.GetStages())
-				endT := engine.Executor.GetEndTime()
-				if !endT.Valid || (stagesEndT.Valid && endT.Duration > stagesEndT.Duration) {
-					endT = stagesEndT
-				}
-				if endT.Valid {
+				if endT := lib.GetEndTime(engine.Executor); endT.Valid {
 					return fmt.Sprintf("%s / %s",
 						(atT/precision)*precision,
 						(time.Duration(endT.Duration)/precision)*precision,
@@ -322,6 +391,13 @@ a commandline interface for interacting with it.`,
 			},
 		}
 
+		// Draw a bar per scenario under the test's, if the executor can tell how far along they are.
+		progressUI := ui.ProgressUI{Width: 60, ScenarioWidth: 40}
+		getScenarioProgress := func() []lib.ScenarioProgress { return nil }
+		if reporter, ok := engine.Executor.(lib.ScenarioProgressReporter); ok {
+			getScenarioProgress = reporter.GetScenarioProgress
+		}
+
 		// Ticker for progress bar updates. Less frequent updates for non-TTYs, none if quiet.
 		updateFreq := 50 * time.Millisecond
 		if !stdoutTTY {
@@ -331,52 +407,37 @@ a commandline interface for interacting with it.`,
 		if quiet || conf.HttpDebug.Valid && conf.HttpDebug.String != "" {
 			ticker.Stop()
 		}
+		var engineErr error
+		var userAborted bool
 	mainLoop:
 		for {
 			select {
 			case <-ticker.C:
 				if quiet || !stdoutTTY {
-					l := log.WithFields(log.Fields{
-						"t": engine.Executor.GetTime(),
-						"i": engine.Executor.GetIterations(),
-					})
-					fn := l.Info
-					if quiet {
-						fn = l.Debug
-					}
-					if engine.Executor.IsPaused() {
-						fn("Paused")
-					} else {
-						fn("Running")
-					}
+					logProgress(engine.Executor, &progressUI, getScenarioProgress(), quiet)
 					break
 				}
 
 				var prog float64
 				if endIt := engine.Executor.GetEndIterations(); endIt.Valid {
 					prog = float64(engine.Executor.GetIterations()) / float64(endIt.Int64)
-				} else {
-					stagesEndT := lib.SumStages(engine.Executor.GetStages())
-					endT := engine.Executor.GetEndTime()
-					if !endT.Valid || (stagesEndT.Valid && endT.Duration > stagesEndT.Duration) {
-						endT = stagesEndT
-					}
-					if endT.Valid {
-						prog = float64(engine.Executor.GetTime()) / float64(endT.Duration)
-					}
+				} else if endT := lib.GetEndTime(engine.Executor); endT.Valid {
+					prog = float64(engine.Executor.GetTime()) / float64(endT.Duration)
 				}
 				progress.Progress = prog
-				fmt.Fprintf(stdout, "%s\x1b[0K\r", progress.String())
+				progressUI.Render(stdout, progress, getScenarioProgress())
 			case err := <-errC:
 				if err != nil {
 					log.WithError(err).Error("Engine error")
 				} else {
 					log.Debug("Engine terminated cleanly")
 				}
+				engineErr = err
 				cancel()
 				break mainLoop
 			case sig := <-sigC:
 				log.WithField("sig", sig).Debug("Exiting in response to signal")
+				userAborted = true
 				cancel()
 			}
 		}
@@ -392,7 +453,7 @@ a commandline interface for interacting with it.`,
 			fn("Test finished")
 		} else {
 			progress.Progress = 1
-			fmt.Fprintf(stdout, "%s\x1b[0K\n", progress.String())
+			progressUI.Render(stdout, progress, getScenarioProgress())
 		}
 
 		// Warn if no iterations could be completed.
@@ -400,43 +461,193 @@ a commandline interface for interacting with it.`,
 			log.Warn("No data generated, because no script iterations finished, consider making the test duration longer")
 		}
 
-		// Print the end-of-test summary.
-		if !quiet {
-			fmt.Fprintf(stdout, "\n")
-			ui.Summarize(stdout, "", ui.SummaryData{
-				Opts:    conf.Options,
-				Root:    engine.Executor.GetRunner().GetDefaultGroup(),
-				Metrics: engine.Metrics,
-				Time:    engine.Executor.GetTime(),
-			})
-			fmt.Fprintf(stdout, "\n")
+		// Print the end-of-test summary, or let the script handle it if it exports handleSummary().
+		if err := handleSummary(fs, engine, conf); err != nil {
+			return err
 		}
 
-		if conf.Linger.Bool {
-			log.Info("Linger set; waiting for Ctrl+C...")
-			<-sigC
+		exitErr := getRunExitError(engine, engineErr, userAborted)
+		if conf.Linger.Bool || (conf.LingerOnFailure.Bool && exitErr != nil) {
+			linger(sigC, conf.LingerTimeout)
 		}
-
-		if engine.IsTainted() {
-			return ExitCode{errors.New("some thresholds have failed"), 99}
+		if exitErr != nil {
+			return *exitErr
 		}
 		return nil
 	},
 }
 
+// Returns the error a finished test exits with, if it didn't pass; the reasons it ended early take
+// precedence over its thresholds.
+// logProgress logs how far along a test is, for when the output isn't a terminal that progress
+// bars can be drawn on: a line for the test, or one for each of its running scenarios.
+func logProgress(ex lib.Executor, progressUI *ui.ProgressUI, scenarios []lib.ScenarioProgress, quiet bool) {
+	msg := "Running"
+	if ex.IsPaused() {
+		msg = "Paused"
+	}
+	logFn := func(l *log.Entry) {
+		if quiet {
+			l.Debug(msg)
+		} else {
+			l.Info(msg)
+		}
+	}
+
+	t := ex.GetTime()
+	running := 0
+	for _, p := range scenarios {
+		if !p.Started || p.Done {
+			continue
+		}
+		running++
+		logFn(log.WithField("t", t).WithFields(log.Fields(progressUI.Fields(p))))
+	}
+	if running == 0 {
+		logFn(log.WithFields(log.Fields{"t": t, "i": ex.GetIterations()}))
+	}
+}
+
+func getRunExitError(engine *core.Engine, engineErr error, userAborted bool) *ExitCode {
+	var exitErr ExitCode
+	switch {
+	case engineErr != nil:
+		exitErr = testErrorExitCode(engineErr)
+	case userAborted:
+		exitErr = ExitCode{errors.New("the test was stopped by the user"), userAbortedExitCode}
+	case engine.IsAborted():
+		exitErr = ExitCode{errors.New("some thresholds have failed, and aborted the test"), thresholdsAbortedExitCode}
+	case engine.IsTainted():
+		exitErr = ExitCode{errors.New("some thresholds have failed"), thresholdsFailedExitCode}
+	default:
+		return nil
+	}
+	return &exitErr
+}
+
+// Keeps the API server alive after the test has ended, until Ctrl+C or the timeout, if valid.
+func linger(sigC <-chan os.Signal, timeout types.NullDuration) {
+	if !timeout.Valid {
+		log.Info("Linger set; waiting for Ctrl+C...")
+		<-sigC
+		return
+	}
+	log.Infof("Linger set; waiting for Ctrl+C, or %s...", timeout.Duration)
+	select {
+	case <-sigC:
+	case <-time.After(time.Duration(timeout.Duration)):
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(runCmd)
 
 	runCmd.Flags().SortFlags = false
 	runCmd.Flags().AddFlagSet(optionFlagSet())
 	runCmd.Flags().AddFlagSet(runtimeOptionFlagSet(true))
+	runCmd.Flags().AddFlagSet(moduleCacheFlagSet())
 	runCmd.Flags().AddFlagSet(configFlagSet())
-	runCmd.Flags().StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\" or \"archive\"")
+	runCmd.Flags().StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\", \"archive\" or \"har\"")
 	runCmd.Flags().BoolVar(&runNoSetup, "no-setup", runNoSetup, "don't run setup()")
 	runCmd.Flags().BoolVar(&runNoTeardown, "no-teardown", runNoTeardown, "don't run teardown()")
+	runCmd.Flags().BoolVar(&runDumpConfig, "dump-config", runDumpConfig, "print the consolidated config as JSON, and exit without running the test")
+	runCmd.Flags().IntVar(&runAgents, "agents", runAgents, "split the test among this many agents, see: k6 agent --help")
+	runCmd.Flags().StringVar(&runCoordinatorAddress, "coordinator-address", runCoordinatorAddress, "address the agents connect to, with --agents")
+	runCmd.Flags().StringVar(&runCoordinatorToken, "coordinator-token", runCoordinatorToken, "token the agents authenticate with, with --agents (default: $K6_COORDINATOR_TOKEN)")
+}
+
+// Prints the end-of-test summary, or lets the script handle it if it exports handleSummary(), and
+// exports it; unless there's to be no summary.
+func handleSummary(fs afero.Fs, engine *core.Engine, conf Config) error {
+	if conf.NoSummary.Bool {
+		return nil
+	}
+
+	summary := ui.SummaryData{
+		Opts:    conf.Options,
+		Root:    engine.Executor.GetRunner().GetDefaultGroup(),
+		Metrics: engine.Metrics,
+		Time:    engine.Executor.GetTime(),
+
+		Scenarios:      engine.ScenarioMetrics,
+		GroupDurations: engine.GroupDurations,
+	}
+	summary.AbortReason, summary.Aborted = engine.ScriptAbortReason()
+	files, err := engine.Executor.GetRunner().HandleSummary(context.Background(), summary.Export())
+	if err != nil {
+		log.WithError(err).Error("Couldn't handle the summary, printing the default one")
+	}
+	if files != nil {
+		if err := writeSummaryFiles(fs, files); err != nil {
+			return err
+		}
+	} else if !quiet {
+		fmt.Fprintf(stdout, "\n")
+		ui.Summarize(stdout, "", summary)
+		fmt.Fprintf(stdout, "\n")
+	}
+	if conf.SummaryExport.String != "" {
+		if err := exportSummary(fs, conf.SummaryExport.String, summary); err != nil {
+			return err
+		}
+	}
+	if conf.JUnitExport.String != "" {
+		if err := exportJUnit(fs, conf.JUnitExport.String, summary); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Reads a source file from any supported destination.
+// Writes the files handleSummary() returned; "stdout" and "stderr" are written to the terminal.
+func writeSummaryFiles(fs afero.Fs, files map[string]io.Reader) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var err error
+		switch name {
+		case "stdout":
+			_, err = io.Copy(stdout, files[name])
+		case "stderr":
+			_, err = io.Copy(stderr, files[name])
+		default:
+			err = afero.WriteReader(fs, name, files[name])
+		}
+		if err != nil {
+			return errors.Wrapf(err, "couldn't write the summary to %s", name)
+		}
+	}
+	return nil
+}
+
+// Writes the end-of-test summary to a JSON file.
+func exportSummary(fs afero.Fs, filename string, summary ui.SummaryData) error {
+	data, err := json.MarshalIndent(summary.Export(), "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fs, filename, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "couldn't export the summary")
+	}
+	return nil
+}
+
+// Writes the thresholds and checks to a JUnit XML file.
+func exportJUnit(fs afero.Fs, filename string, summary ui.SummaryData) error {
+	data, err := summary.JUnit()
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fs, filename, data, 0644); err != nil {
+		return errors.Wrap(err, "couldn't export the JUnit report")
+	}
+	return nil
+}
+
 func readSource(src, pwd string, fs afero.Fs, stdin io.Reader) (*lib.SourceData, error) {
 	if src == "-" {
 		data, err := ioutil.ReadAll(stdin)
@@ -464,20 +675,34 @@ func newRunner(src *lib.SourceData, typ string, fs afero.Fs, rtOpts lib.RuntimeO
 		if err != nil {
 			return nil, err
 		}
-		switch arc.Type {
-		case typeJS:
-			return js.NewFromArchive(arc, rtOpts)
-		default:
-			return nil, errors.Errorf("archive requests unsupported runner: %s", arc.Type)
+		return newRunnerFromArchive(arc, rtOpts)
+	case typeHAR:
+		script, err := convertHARForRun(src.Data)
+		if err != nil {
+			return nil, err
 		}
+		return js.New(&lib.SourceData{Filename: src.Filename, Data: []byte(script)}, fs, rtOpts)
 	default:
 		return nil, errors.Errorf("unknown -t/--type: %s", typ)
 	}
 }
 
+// Creates a runner for an archive, of the type it requests.
+func newRunnerFromArchive(arc *lib.Archive, rtOpts lib.RuntimeOptions) (lib.Runner, error) {
+	switch arc.Type {
+	case typeJS:
+		return js.NewFromArchive(arc, rtOpts)
+	default:
+		return nil, errors.Errorf("archive requests unsupported runner: %s", arc.Type)
+	}
+}
+
 func detectType(data []byte) string {
 	if _, err := tar.NewReader(bytes.NewReader(data)).Next(); err == nil {
 		return typeArchive
 	}
+	if isHAR(data) {
+		return typeHAR
+	}
 	return typeJS
 }
@@ -0,0 +1,109 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportSummary(t *testing.T) {
+	counter := stats.New("iterations", stats.Counter)
+	counter.Sink.Add(stats.Sample{Value: 4})
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, exportSummary(fs, "summary.json", ui.SummaryData{
+		Metrics: map[string]*stats.Metric{"iterations": counter},
+		Time:    2 * time.Second,
+	}))
+
+	data, err := afero.ReadFile(fs, "summary.json")
+	require.NoError(t, err)
+	var summary map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, map[string]interface{}{
+		"iterations": map[string]interface{}{
+			"type":     "counter",
+			"contains": "default",
+			"values":   map[string]interface{}{"count": 4.0, "rate": 2.0},
+		},
+	}, summary["metrics"])
+	assert.Equal(t, map[string]interface{}{"testRunDurationMs": 2000.0}, summary["state"])
+}
+
+func TestGetRunExitError(t *testing.T) {
+	engine, err := core.NewEngine(local.New(nil), lib.Options{})
+	require.NoError(t, err)
+
+	_, jsErr := goja.New().RunString(`throw new Error("oops")`)
+	_, syntaxErr := goja.Compile("script.js", `var a = ;`, true)
+	_, parseErr := parser.ParseFile(nil, "script.js", `var a = ;`, 0)
+	testdata := map[string]struct {
+		err         error
+		userAborted bool
+		code        int
+	}{
+		"Passed":        {nil, false, 0},
+		"UserAborted":   {nil, true, userAbortedExitCode},
+		"ScriptError":   {errors.Wrap(jsErr, "setup"), true, scriptErrorExitCode},
+		"SyntaxError":   {syntaxErr, false, scriptErrorExitCode},
+		"ParseError":    {parseErr, false, scriptErrorExitCode},
+		"ScriptAborted": {lib.ScriptAbortError{Reason: "broken"}, false, scriptAbortedExitCode},
+		"EngineError":   {errors.New("agent disconnected"), false, engineErrorExitCode},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			exitErr := getRunExitError(engine, data.err, data.userAborted)
+			if data.code == 0 {
+				assert.Nil(t, exitErr)
+				return
+			}
+			if assert.NotNil(t, exitErr) {
+				assert.Equal(t, data.code, exitErr.Code)
+			}
+		})
+	}
+}
+
+func TestLinger(t *testing.T) {
+	sigC := make(chan os.Signal, 1)
+	start := time.Now()
+	linger(sigC, types.NullDurationFrom(10*time.Millisecond))
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+
+	sigC <- os.Interrupt
+	linger(sigC, types.NullDuration{})
+}
@@ -25,8 +25,12 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/loadimpact/k6/js/modules"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/secrets"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
 )
 
@@ -53,6 +57,8 @@ func runtimeOptionFlagSet(includeSysEnv bool) *pflag.FlagSet {
 	flags.SortFlags = false
 	flags.Bool("include-system-env-vars", includeSysEnv, "pass the real system environment variables to the runtime")
 	flags.StringSliceP("env", "e", nil, "add/override environment variable with `VAR=value`")
+	flags.StringSlice("enable", nil, "enable experimental `features`, eg. --enable=name1,name2")
+	flags.StringArray("secret-source", nil, "get secrets from a `source`, eg. file=secrets.env, vault=secret/k6 or aws; can be repeated, the first is the default")
 	return flags
 }
 
@@ -83,5 +89,57 @@ func getRuntimeOptions(flags *pflag.FlagSet) (lib.RuntimeOptions, error) {
 		}
 	}
 
+	enable, err := flags.GetStringSlice("enable")
+	if err != nil {
+		return opts, err
+	}
+	features := modules.ExperimentalFeatures()
+	for _, name := range enable {
+		if _, ok := features[name]; !ok {
+			return opts, errors.Errorf("Unknown experimental feature '%s'", name)
+		}
+	}
+	if len(enable) > 0 {
+		opts.Enable = enable
+	}
+
+	sources, err := flags.GetStringArray("secret-source")
+	if err != nil {
+		return opts, err
+	}
+	if len(sources) > 0 {
+		if opts.Secrets, err = getSecrets(afero.NewOsFs(), collectEnv(), sources); err != nil {
+			return opts, err
+		}
+		// Keep the secrets out of everything that's logged, including console.log()s.
+		log.SetFormatter(opts.Secrets.Formatter(log.StandardLogger().Formatter))
+	}
+
 	return opts, nil
 }
+
+func getSecrets(fs afero.Fs, env map[string]string, sources []string) (*secrets.Manager, error) {
+	m := secrets.NewManager()
+	for _, spec := range sources {
+		name, src, err := secrets.ParseSource(fs, env, spec)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Add(name, src); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// warnExperimentalFeatures lets the user know which experimental features are in use, as their
+// APIs may change in any release.
+func warnExperimentalFeatures(names []string) {
+	features := modules.ExperimentalFeatures()
+	for _, name := range names {
+		log.WithFields(log.Fields{
+			"feature": name,
+			"docs":    features[name].DocsURL,
+		}).Warn("Experimental feature enabled, its APIs may change in future versions")
+	}
+}
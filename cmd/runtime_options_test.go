@@ -26,6 +26,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/loadimpact/k6/js/modules"
 	"github.com/loadimpact/k6/lib"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -240,3 +241,23 @@ func TestEnvVars(t *testing.T) {
 		})
 	}
 }
+
+func TestEnableFlag(t *testing.T) {
+	modules.Experimental["k6/x/test"] = modules.ExperimentalFeature{Name: "test"}
+	defer delete(modules.Experimental, "k6/x/test")
+
+	t.Run("Known", func(t *testing.T) {
+		flags := runtimeOptionFlagSet(false)
+		require.NoError(t, flags.Parse([]string{"--enable=test"}))
+		rtOpts, err := getRuntimeOptions(flags)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test"}, rtOpts.Enable)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		flags := runtimeOptionFlagSet(false)
+		require.NoError(t, flags.Parse([]string{"--enable=test,nope"}))
+		_, err := getRuntimeOptions(flags)
+		assert.EqualError(t, err, "Unknown experimental feature 'nope'")
+	})
+}
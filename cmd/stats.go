@@ -21,21 +21,65 @@
 package cmd
 
 import (
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io"
+	"strings"
 
 	"github.com/loadimpact/k6/api/v1/client"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/stats/csv"
+	"github.com/loadimpact/k6/stats/json"
+	"github.com/loadimpact/k6/stats/results"
 	"github.com/loadimpact/k6/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
+var (
+	statsFormat        = ""
+	statsFilter        = ""
+	statsThresholds    []string
+	statsTrendStats    []string
+	statsSummaryExport = ""
+)
+
 // statsCmd represents the stats command
 var statsCmd = &cobra.Command{
-	Use:   "stats",
+	Use:   "stats [results]",
 	Short: "Show test metrics",
 	Long: `Show test metrics.
 
-  Use the global --address flag to specify the URL to the API server.`,
+Without arguments, shows the metrics of the running test; use the global --address
+flag to specify the URL to the API server.
+
+Given the results a test saved with --out json=... or --out csv=..., adds their
+samples back up into the end-of-test summary, and evaluates thresholds on them, so
+the results can be looked at again, or differently, without running the test again.
+Thresholds are read from the config file given with -c, which may be the options of
+the test as printed by "k6 inspect", or given with --threshold. The command fails
+like "k6 run" does if any of them fails.`,
+	Example: `
+  # Show the metrics of the test that's running.
+  k6 stats
+
+  # Sum up saved results again, with the thresholds of the test they came from.
+  k6 inspect script.js > options.json
+  k6 stats -c options.json results.json
+
+  # Look at only the requests of one scenario, with other trend stats.
+  k6 stats --filter scenario:checkout --summary-trend-stats avg,p(99),max results.csv.gz
+
+  # Check saved results against a new threshold, and export the summary to compare it later.
+  k6 stats --threshold 'http_req_duration{status:200}=p(95)<300' --summary-export summary.json results.json`[1:],
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return showResultStats(afero.NewOsFs(), args[0])
+		}
 		c, err := client.New(address)
 		if err != nil {
 			return err
@@ -49,6 +93,169 @@ var statsCmd = &cobra.Command{
 	},
 }
 
+// showResultStats prints the summary of the results a test saved, and fails if their thresholds do.
+func showResultStats(fs afero.Fs, filename string) error {
+	thresholds, trendStats, err := getStatsConfig(fs)
+	if err != nil {
+		return err
+	}
+	for _, s := range trendStats {
+		if err := ui.VerifyTrendColumnStat(s); err != nil {
+			return errors.Wrapf(err, "stat '%s'", s)
+		}
+	}
+	if len(trendStats) > 0 {
+		ui.UpdateTrendColumns(trendStats)
+	}
+
+	res, err := results.New(thresholds, statsFilter)
+	if err != nil {
+		return err
+	}
+	if err := readResults(fs, filename, statsFormat, res.Add); err != nil {
+		return errors.Wrapf(err, "couldn't read %s", filename)
+	}
+	if len(res.Metrics) == 0 {
+		return errors.Errorf("%s has no samples that match", filename)
+	}
+	for _, name := range res.MissingThresholds() {
+		fmt.Fprintf(stderr, "The thresholds of %s weren't evaluated, as it has no samples\n", name)
+	}
+	passed, err := res.RunThresholds()
+	if err != nil {
+		return err
+	}
+
+	summary := ui.SummaryData{
+		Root:           res.Root,
+		Metrics:        res.Metrics,
+		Time:           res.Duration(),
+		GroupDurations: res.GroupDurations,
+	}
+	// A test without scenarios runs the default one; that's no different from the whole test.
+	if _, ok := res.Scenarios["default"]; len(res.Scenarios) > 1 || len(res.Scenarios) == 1 && !ok {
+		summary.Scenarios = res.Scenarios
+	}
+	if !quiet {
+		fmt.Fprintf(stdout, "\n")
+		ui.Summarize(stdout, "", summary)
+		fmt.Fprintf(stdout, "\n")
+	}
+	if statsSummaryExport != "" {
+		if err := exportSummary(fs, statsSummaryExport, summary); err != nil {
+			return err
+		}
+	}
+	if !passed {
+		return ExitCode{errors.New("some thresholds have failed"), thresholdsFailedExitCode}
+	}
+	return nil
+}
+
+// getStatsConfig returns the thresholds and trend stats of the config file, if one's given, and
+// the flags, which take precedence.
+func getStatsConfig(fs afero.Fs) (map[string]stats.Thresholds, []string, error) {
+	thresholds := make(map[string]stats.Thresholds)
+	var trendStats []string
+	if configFile != "" {
+		conf, _, err := readDiskConfig(fs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, ths := range conf.Thresholds {
+			thresholds[name] = ths
+		}
+		trendStats = conf.SummaryTrendStats
+	}
+
+	sources := make(map[string][]string)
+	var names []string
+	for _, s := range statsThresholds {
+		name, source, err := parseStatsThreshold(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := sources[name]; !ok {
+			names = append(names, name)
+		}
+		sources[name] = append(sources[name], source)
+	}
+	for _, name := range names {
+		ths, err := stats.NewThresholds(sources[name])
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "threshold of %s", name)
+		}
+		thresholds[name] = ths
+	}
+
+	if len(statsTrendStats) > 0 {
+		trendStats = statsTrendStats
+	}
+	return thresholds, trendStats, nil
+}
+
+// parseStatsThreshold splits a --threshold into the metric, which may be a submetric with = in
+// its conditions, and the threshold expression.
+func parseStatsThreshold(s string) (string, string, error) {
+	from := 0
+	if end := strings.Index(s, "}"); strings.Contains(s, "{") && end >= 0 {
+		from = end
+	}
+	i := strings.Index(s[from:], "=")
+	if i < 0 {
+		return "", "", errors.Errorf("invalid threshold %s, expected metric=expression", s)
+	}
+	name, source := strings.TrimSpace(s[:from+i]), strings.TrimSpace(s[from+i+1:])
+	if name == "" || source == "" {
+		return "", "", errors.Errorf("invalid threshold %s, expected metric=expression", s)
+	}
+	return name, source, nil
+}
+
+// readResults reads the samples of a file of results, in the format of its extension unless one
+// is given; files ending in .gz are gzipped, like the outputs write them.
+func readResults(fs afero.Fs, filename, format string, fn func(stats.Sample) error) error {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	name := filename
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	if format == "" {
+		format = "json"
+		if strings.HasSuffix(name, ".csv") {
+			format = "csv"
+		}
+	}
+	switch format {
+	case "json":
+		return json.ReadSamples(r, fn)
+	case "csv":
+		return csv.ReadSamples(r, metrics.Get, fn)
+	default:
+		return errors.Errorf("unknown format %s, expected json or csv", format)
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().SortFlags = false
+	statsCmd.Flags().AddFlagSet(configFileFlagSet())
+	statsCmd.Flags().StringVar(&statsFormat, "format", statsFormat, "`format` of the results, json or csv; by default, csv for files ending in .csv or .csv.gz, json for others")
+	statsCmd.Flags().StringVar(&statsFilter, "filter", statsFilter, "only sum up the samples with these `tags`, as 'tag:value,tag!=value,...' like the conditions of a submetric")
+	statsCmd.Flags().StringArrayVar(&statsThresholds, "threshold", nil, "evaluate a `threshold`, as 'metric=expression', eg. 'http_req_duration{status:200}=p(95)<300'; can be given more than once")
+	statsCmd.Flags().StringSliceVar(&statsTrendStats, "summary-trend-stats", nil, "define `stats` for trend metrics (response times), one or more as 'avg,p(95),...'")
+	statsCmd.Flags().StringVar(&statsSummaryExport, "summary-export", statsSummaryExport, "output the end-of-test summary report to a JSON `file`")
 }
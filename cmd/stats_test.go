@@ -0,0 +1,102 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatsThreshold(t *testing.T) {
+	testdata := map[string][2]string{
+		"http_req_duration=p(95)<500":                     {"http_req_duration", "p(95)<500"},
+		"checks = rate>=0.9":                              {"checks", "rate>=0.9"},
+		`http_req_duration{name!="a=b",status:200}=max<1`: {`http_req_duration{name!="a=b",status:200}`, "max<1"},
+	}
+	for s, expected := range testdata {
+		name, source, err := parseStatsThreshold(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, [2]string{name, source}, s)
+		}
+	}
+	for _, s := range []string{"p(95)<500", "=p(95)<500", "checks="} {
+		_, _, err := parseStatsThreshold(s)
+		assert.EqualError(t, err, "invalid threshold "+s+", expected metric=expression")
+	}
+}
+
+func TestShowResultStats(t *testing.T) {
+	defer func(q bool) { quiet = q }(quiet)
+	quiet = true
+	defer func() { statsThresholds, statsFilter, statsSummaryExport = nil, "", "" }()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/results.json", []byte(
+		`{"type":"Metric","metric":"http_req_duration","data":{"name":"http_req_duration","type":"trend","contains":"time"}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2017-07-14T02:40:00Z","value":100,"tags":{"status":"200"}}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2017-07-14T02:40:10Z","value":900,"tags":{"status":"500"}}}
+`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/results.csv", []byte(
+		"metric_name,timestamp,metric_value,status,extra_tags\n"+
+			"http_req_duration,1500000000,100,200,\n"+
+			"http_req_duration,1500000010,900,500,\n"), 0644))
+
+	for _, filename := range []string{"/results.json", "/results.csv"} {
+		t.Run(filename, func(t *testing.T) {
+			statsThresholds = []string{"http_req_duration{status:200}=max<200", "http_req_duration=avg<600"}
+			statsSummaryExport = "/summary.json"
+			assert.NoError(t, showResultStats(fs, filename))
+
+			data, err := afero.ReadFile(fs, "/summary.json")
+			require.NoError(t, err)
+			var summary struct {
+				State   map[string]interface{} `json:"state"`
+				Metrics map[string]struct {
+					Values     map[string]float64         `json:"values"`
+					Thresholds map[string]map[string]bool `json:"thresholds"`
+				} `json:"metrics"`
+			}
+			require.NoError(t, json.Unmarshal(data, &summary))
+			assert.Equal(t, 10000.0, summary.State["testRunDurationMs"])
+			assert.Equal(t, 500.0, summary.Metrics["http_req_duration"].Values["avg"])
+			assert.Equal(t, map[string]map[string]bool{"max<200": {"ok": true}},
+				summary.Metrics["http_req_duration{status:200}"].Thresholds)
+
+			statsThresholds = []string{"http_req_duration=avg<400"}
+			err = showResultStats(fs, filename)
+			if assert.IsType(t, ExitCode{}, err) {
+				assert.Equal(t, thresholdsFailedExitCode, err.(ExitCode).Code)
+			}
+
+			statsThresholds = nil
+			statsFilter = "status:404"
+			assert.EqualError(t, showResultStats(fs, filename), filename+" has no samples that match")
+			statsFilter = ""
+		})
+	}
+
+	assert.EqualError(t, showResultStats(fs, "/missing.json"),
+		"couldn't read /missing.json: open /missing.json: file does not exist")
+}
@@ -23,6 +23,8 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/loadimpact/k6/js/modules"
+	outputext "github.com/loadimpact/k6/stats/output"
 	"github.com/spf13/cobra"
 )
 
@@ -30,9 +32,21 @@ import (
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show application version",
-	Long:  `Show the application version and exit.`,
+	Long:  `Show the application version, and the extensions built into it, and exit.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("k6 v" + Version)
+
+		jsExts, outputExts := modules.Extensions(), outputext.Names()
+		if len(jsExts)+len(outputExts) == 0 {
+			return
+		}
+		fmt.Println("Extensions:")
+		for _, name := range jsExts {
+			fmt.Printf("  %s (JS module)\n", name)
+		}
+		for _, name := range outputExts {
+			fmt.Printf("  %s (output)\n", name)
+		}
 	},
 }
 
@@ -27,49 +27,81 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/tidwall/pretty"
+	"math"
 	"net/url"
 	"sort"
 	"strings"
 )
 
-func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint, nobatch bool, correlate bool, only, skip []string) (string, error) {
+// ConvertOptions are the settings of a conversion of a HAR file into a k6 script.
+type ConvertOptions struct {
+	// Check the status codes of the responses, and optionally return if one is unexpected.
+	EnableChecks        bool
+	ReturnOnFailedCheck bool
+
+	// Requests started within BatchTime milliseconds of each other are batched, unless NoBatch
+	// is set; Correlate needs it, and replaces recorded redirects and IDs with the real ones.
+	BatchTime uint
+	NoBatch   bool
+	Correlate bool
+
+	// Suggest thresholds of the recorded response times plus a percentage margin.
+	Thresholds       bool
+	ThresholdsMargin uint
+
+	// Wrap the generated code in regions that later conversions can be merged with.
+	Regions bool
+
+	// Filters of the converted requests by host and by protocol.
+	Only, Skip []string
+	Protocols  []string
+
+	// The login request moved to a module of its own, if any.
+	Login *Login
+}
+
+func Convert(h HAR, opts ConvertOptions) (string, error) {
 	var b bytes.Buffer
 	w := bufio.NewWriter(&b)
 
-	if returnOnFailedCheck && !enableChecks {
+	// Generated code is wrapped in marked regions, so it can be merged with later conversions.
+	begin := func(indent, id string) {
+		if opts.Regions {
+			fmt.Fprintf(w, "%s%s%s\n", indent, regionBegin, id)
+		}
+	}
+	end := func(indent, id string) {
+		if opts.Regions {
+			fmt.Fprintf(w, "%s%s%s\n", indent, regionEnd, id)
+		}
+	}
+
+	if opts.ReturnOnFailedCheck && !opts.EnableChecks {
 		return "", errors.Errorf("return on failed check requires --enable-status-code-checks")
 	}
 
-	if correlate && !nobatch {
+	if opts.Correlate && !opts.NoBatch {
 		return "", errors.Errorf("correlation requires --no-batch")
 	}
 
-	if enableChecks {
-		fmt.Fprint(w, "import { group, check, sleep } from 'k6';\n")
-	} else {
-		fmt.Fprint(w, "import { group, sleep } from 'k6';\n")
+	if err := validateProtocols(opts.Protocols); err != nil {
+		return "", err
 	}
-	fmt.Fprint(w, "import http from 'k6/http';\n\n")
 
-	fmt.Fprintf(w, "// Version: %v\n", h.Log.Version)
-	fmt.Fprintf(w, "// Creator: %v\n", h.Log.Creator.Name)
-	if h.Log.Browser != nil {
-		fmt.Fprintf(w, "// Browser: %v\n", h.Log.Browser.Name)
-	}
-	if h.Log.Comment != "" {
-		fmt.Fprintf(w, "// %v\n", h.Log.Comment)
+	check := func(res string, status int) {
+		if opts.ReturnOnFailedCheck {
+			fmt.Fprintf(w, "\t\tif (!check(%s, {\"status is %v\": (r) => r.status === %v })) { return };\n", res, status, status)
+		} else {
+			fmt.Fprintf(w, "\t\tcheck(%s, {\"status is %v\": (r) => r.status === %v });\n", res, status, status)
+		}
 	}
 
-	// recordings include redirections as separate requests, and we dont want to trigger them twice
-	fmt.Fprint(w, "\nexport let options = { maxRedirects: 0 };\n\n")
-
-	fmt.Fprint(w, "export default function() {\n\n")
-
 	pages := h.Log.Pages
 	sort.Sort(PageByStarted(pages))
 
-	// Grouping by page and URL filtering
+	// Grouping by page, and URL and protocol filtering
 	pageEntries := make(map[string][]*Entry)
+	webSockets := false
 	for _, e := range h.Log.Entries {
 
 		// URL filtering
@@ -77,15 +109,38 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 		if err != nil {
 			return "", err
 		}
-		if !IsAllowedURL(u.Host, only, skip) {
+		if !IsAllowedURL(u.Host, opts.Only, opts.Skip) {
 			continue
 		}
 
+		// Protocol filtering
+		protocol := e.Protocol()
+		if !isAllowedProtocol(protocol, opts.Protocols) {
+			continue
+		}
 		// Avoid multipart/form-data requests until k6 scripts can support binary data
 		if e.Request.PostData != nil && strings.HasPrefix(e.Request.PostData.MimeType, "multipart/form-data") {
 			continue
 		}
 
+		// The login request is replayed by the login module instead
+		if opts.Login != nil {
+			if e == opts.Login.Entry {
+				continue
+			}
+			e = opts.Login.stripSessionCookies(e)
+		}
+
+		// WebSockets need the ws module, and gRPC-web calls a body the script can hold
+		switch protocol {
+		case ProtocolWebSocket:
+			webSockets = true
+		case ProtocolGRPCWeb:
+			ge := *e
+			ge.Request = buildGRPCWebRequest(e.Request)
+			e = &ge
+		}
+
 		// Create new group o adding page to a existing one
 		if _, ok := pageEntries[e.Pageref]; !ok {
 			pageEntries[e.Pageref] = append([]*Entry{}, e)
@@ -94,14 +149,75 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 		}
 	}
 
+	begin("", "imports")
+	if opts.EnableChecks {
+		fmt.Fprint(w, "import { group, check, sleep } from 'k6';\n")
+	} else {
+		fmt.Fprint(w, "import { group, sleep } from 'k6';\n")
+	}
+	fmt.Fprint(w, "import http from 'k6/http';\n")
+	if webSockets {
+		fmt.Fprint(w, "import ws from 'k6/ws';\n")
+	}
+	if opts.Login != nil {
+		fmt.Fprintf(w, "import { login } from '%s';\n", opts.Login.Import)
+	}
+	end("", "imports")
+	fmt.Fprint(w, "\n")
+
+	fmt.Fprintf(w, "// Version: %v\n", h.Log.Version)
+	fmt.Fprintf(w, "// Creator: %v\n", h.Log.Creator.Name)
+	if h.Log.Browser != nil {
+		fmt.Fprintf(w, "// Browser: %v\n", h.Log.Browser.Name)
+	}
+	if h.Log.Comment != "" {
+		fmt.Fprintf(w, "// %v\n", h.Log.Comment)
+	}
+
+	if opts.Login != nil && opts.Login.Credentials != "" {
+		fmt.Fprintf(w, "\nconst credentials = JSON.parse(open(%q));\n", opts.Login.Credentials)
+	}
+
+	// recordings include redirections as separate requests, and we dont want to trigger them twice
+	fmt.Fprint(w, "\n")
+	begin("", "options")
+	if opts.Thresholds {
+		fmt.Fprint(w, "export let options = {\n\tmaxRedirects: 0,\n")
+		fmt.Fprintf(w, "\t// Suggested thresholds: the recorded p(95) response times plus a %d%% margin\n", opts.ThresholdsMargin)
+		fmt.Fprint(w, "\tthresholds: {\n")
+		for _, t := range buildK6Thresholds(pages, pageEntries, opts.ThresholdsMargin) {
+			fmt.Fprintf(w, "\t\t%s,\n", t)
+		}
+		fmt.Fprint(w, "\t}\n};\n")
+	} else {
+		fmt.Fprint(w, "export let options = { maxRedirects: 0 };\n")
+	}
+	end("", "options")
+	fmt.Fprint(w, "\n")
+
+	fmt.Fprint(w, "export default function() {\n\n")
+	if opts.Login != nil {
+		fmt.Fprint(w, opts.Login.call())
+		fmt.Fprint(w, "\n")
+	}
+
+	// Page regions are keyed by the page's title (usually its URL) rather than its ID, since IDs are
+	// just numbered in the order they're recorded. Pages with the same title are told apart by order.
+	pageRegions := make(map[string]int)
 	for i, page := range pages {
 
 		entries := pageEntries[page.ID]
+		region := "page " + strings.Join(strings.Fields(page.Title), " ")
+		pageRegions[region]++
+		if n := pageRegions[region]; n > 1 {
+			region = fmt.Sprintf("%s #%d", region, n)
+		}
+		begin("\t", region)
 		fmt.Fprintf(w, "\tgroup(\"%s - %s\", function() {\n", page.ID, page.Title)
 
 		sort.Sort(EntryByStarted(entries))
 
-		if nobatch {
+		if opts.NoBatch {
 			var recordedRedirectURL string
 			previousResponse := map[string]interface{}{}
 
@@ -115,6 +231,21 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 
 				fmt.Fprintf(w, "\t\t// Request #%d\n", entryIndex)
 
+				switch e.Protocol() {
+				case ProtocolWebSocket:
+					socket, err := buildK6WebSocket(e, "\t\t")
+					if err != nil {
+						return "", err
+					}
+					fmt.Fprintf(w, "\t\tres = %s;\n", socket)
+					if opts.EnableChecks && e.Response != nil && e.Response.Status > 0 {
+						check("res", e.Response.Status)
+					}
+					continue
+				case ProtocolGRPCWeb:
+					fmt.Fprintf(w, "\t\t// gRPC-web call %s\n", grpcWebMethod(e))
+				}
+
 				if e.Request.PostData != nil {
 					body = e.Request.PostData.Text
 				}
@@ -132,7 +263,7 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 
 				fmt.Fprintf(w, "\t\tres = http.%s(", strings.ToLower(e.Request.Method))
 
-				if correlate && recordedRedirectURL != "" {
+				if opts.Correlate && recordedRedirectURL != "" {
 					if recordedRedirectURL != e.Request.URL {
 						return "", errors.Errorf("The har file contained a redirect but the next request did not match that redirect. Possibly a misbehaving client or concurrent requests?")
 					}
@@ -143,7 +274,7 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 				}
 
 				if e.Request.Method != "GET" {
-					if correlate && e.Request.PostData != nil && strings.Contains(e.Request.PostData.MimeType, "json") {
+					if opts.Correlate && e.Request.PostData != nil && strings.Contains(e.Request.PostData.MimeType, "json") {
 						requestMap := map[string]interface{}{}
 
 						escapedPostdata := strings.Replace(e.Request.PostData.Text, "$", "\\$", -1)
@@ -176,14 +307,8 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 
 				if e.Response != nil {
 					// the response is nil if there is a failed request in the recording, or if responses were not recorded
-					if enableChecks {
-						if e.Response.Status > 0 {
-							if returnOnFailedCheck {
-								fmt.Fprintf(w, "\t\tif (!check(res, {\"status is %v\": (r) => r.status === %v })) { return };\n", e.Response.Status, e.Response.Status)
-							} else {
-								fmt.Fprintf(w, "\t\tcheck(res, {\"status is %v\": (r) => r.status === %v });\n", e.Response.Status, e.Response.Status)
-							}
-						}
+					if opts.EnableChecks && e.Response.Status > 0 {
+						check("res", e.Response.Status)
 					}
 
 					if e.Response.Headers != nil {
@@ -197,7 +322,7 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 					}
 
 					responseMimeType := e.Response.Content.MimeType
-					if correlate &&
+					if opts.Correlate &&
 						strings.Index(responseMimeType, "application/") == 0 &&
 						strings.Index(responseMimeType, "json") == len(responseMimeType)-4 {
 						if err := json.Unmarshal([]byte(e.Response.Content.Text), &previousResponse); err != nil {
@@ -208,30 +333,42 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 				}
 			}
 		} else {
-			batches := SplitEntriesInBatches(entries, batchTime)
+			batches := SplitEntriesInBatches(entries, opts.BatchTime)
 
 			fmt.Fprint(w, "\t\tlet req, res;\n")
 
 			for j, batchEntries := range batches {
 
-				fmt.Fprint(w, "\t\treq = [")
-				for k, e := range batchEntries {
-					r, err := buildK6RequestObject(e.Request)
-					if err != nil {
-						return "", err
+				// WebSocket connections can't be batched, they're opened after the batch
+				var requests, sockets []*Entry
+				for _, e := range batchEntries {
+					if e.Protocol() == ProtocolWebSocket {
+						sockets = append(sockets, e)
+					} else {
+						requests = append(requests, e)
 					}
-					fmt.Fprintf(w, "%v", r)
-					if k != len(batchEntries)-1 {
-						fmt.Fprint(w, ",")
+				}
+
+				if len(requests) > 0 {
+					fmt.Fprint(w, "\t\treq = [")
+					for k, e := range requests {
+						r, err := buildK6RequestObject(e.Request)
+						if err != nil {
+							return "", err
+						}
+						fmt.Fprintf(w, "%v", r)
+						if k != len(requests)-1 {
+							fmt.Fprint(w, ",")
+						}
 					}
+					fmt.Fprint(w, "];\n")
+					fmt.Fprint(w, "\t\tres = http.batch(req);\n")
 				}
-				fmt.Fprint(w, "];\n")
-				fmt.Fprint(w, "\t\tres = http.batch(req);\n")
 
-				if enableChecks {
-					for k, e := range batchEntries {
-						if e.Response.Status > 0 {
-							if returnOnFailedCheck {
+				if opts.EnableChecks {
+					for k, e := range requests {
+						if e.Response != nil && e.Response.Status > 0 {
+							if opts.ReturnOnFailedCheck {
 								fmt.Fprintf(w, "\t\tif (!check(res, {\"status is %v\": (r) => r.status === %v })) { return };\n", e.Response.Status, e.Response.Status)
 							} else {
 								fmt.Fprintf(w, "\t\tcheck(res[%v], {\"status is %v\": (r) => r.status === %v });\n", k, e.Response.Status, e.Response.Status)
@@ -240,6 +377,17 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 					}
 				}
 
+				for _, e := range sockets {
+					socket, err := buildK6WebSocket(e, "\t\t")
+					if err != nil {
+						return "", err
+					}
+					fmt.Fprintf(w, "\t\tres = %s;\n", socket)
+					if opts.EnableChecks && e.Response != nil && e.Response.Status > 0 {
+						check("res", e.Response.Status)
+					}
+				}
+
 				if j != len(batches)-1 {
 					lastBatchEntry := batchEntries[len(batchEntries)-1]
 					firstBatchEntry := batches[j+1][0]
@@ -255,23 +403,42 @@ func Convert(h HAR, enableChecks bool, returnOnFailedCheck bool, batchTime uint,
 			} else {
 				// Add sleep time at the end of the group
 				nextPage := pages[i+1]
-				lastEntry := entries[len(entries)-1]
-				t := nextPage.StartedDateTime.Sub(lastEntry.StartedDateTime).Seconds()
-				if t < 0.01 {
-					t = 0.5
+				t := 0.5
+				if len(entries) > 0 {
+					lastEntry := entries[len(entries)-1]
+					if d := nextPage.StartedDateTime.Sub(lastEntry.StartedDateTime).Seconds(); d >= 0.01 {
+						t = d
+					}
 				}
 				fmt.Fprintf(w, "\t\tsleep(%.2f);\n", t)
 			}
 		}
 
 		fmt.Fprint(w, "\t});\n")
+		end("\t", region)
 	}
 
 	fmt.Fprint(w, "\n}\n")
 	if err := w.Flush(); err != nil {
 		return "", err
 	}
-	return b.String(), nil
+	script := b.String()
+	if opts.Login != nil {
+		script = opts.Login.replaceToken(script)
+	}
+	if opts.Regions {
+		return sealRegions(script), nil
+	}
+	return script, nil
+}
+
+// k6Method returns the name of the k6/http function for an HTTP method; delete is a reserved word.
+func k6Method(method string) string {
+	method = strings.ToLower(method)
+	if method == "delete" {
+		return "del"
+	}
+	return method
 }
 
 func buildK6RequestObject(req *Request) (string, error) {
@@ -280,10 +447,7 @@ func buildK6RequestObject(req *Request) (string, error) {
 
 	fmt.Fprint(w, "{\n")
 
-	method := strings.ToLower(req.Method)
-	if method == "delete" {
-		method = "del"
-	}
+	method := k6Method(req.Method)
 	fmt.Fprintf(w, `"method": %q, "url": %q`, method, req.URL)
 
 	if req.PostData != nil && method != "get" {
@@ -327,6 +491,43 @@ func buildK6RequestObject(req *Request) (string, error) {
 	return buffer.String(), nil
 }
 
+// buildK6Thresholds suggests a p(95) http_req_duration threshold for the whole recording and for
+// every page group, based on the recorded response times plus a percentage margin.
+func buildK6Thresholds(pages []Page, pageEntries map[string][]*Entry, margin uint) []string {
+	var thresholds []string
+	suggest := func(name string, entries []*Entry) {
+		var times []float64
+		for _, e := range entries {
+			// WebSocket connections aren't timed by http_req_duration
+			if e.Time > 0 && e.Protocol() != ProtocolWebSocket {
+				times = append(times, float64(e.Time))
+			}
+		}
+		if len(times) == 0 {
+			return
+		}
+		sort.Float64s(times)
+		p95 := times[int(math.Ceil(0.95*float64(len(times))))-1]
+		limit := math.Ceil(p95 * (100 + float64(margin)) / 100)
+		thresholds = append(thresholds, fmt.Sprintf("%q: [\"p(95)<%d\"]", name, int64(limit)))
+	}
+
+	var recorded []*Entry
+	for _, page := range pages {
+		recorded = append(recorded, pageEntries[page.ID]...)
+	}
+	suggest("http_req_duration", recorded)
+	for _, page := range pages {
+		group := fmt.Sprintf("%s - %s", page.ID, page.Title)
+		// Sub-metric tag values can't contain the separators of the sub-metric syntax.
+		if strings.ContainsAny(group, ",{}") {
+			continue
+		}
+		suggest(fmt.Sprintf("http_req_duration{group:::%s}", group), pageEntries[page.ID])
+	}
+	return thresholds
+}
+
 func buildK6Headers(headers []Header) []string {
 	var h []string
 	if len(headers) > 0 {
@@ -23,7 +23,9 @@ package har
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/loadimpact/k6/js"
 	"github.com/loadimpact/k6/lib"
@@ -100,3 +102,107 @@ func TestBuildK6Body(t *testing.T) {
 	assert.Equal(t, len(postParams), 2, "postParams should have two items")
 	assert.Equal(t, postParams[0], expectedEmailParam, "expected unescaped value")
 }
+
+func TestBuildK6Thresholds(t *testing.T) {
+	pages := []Page{{ID: "page_1", Title: "Home"}, {ID: "page_2", Title: "Search, results"}, {ID: "page_3", Title: "Empty"}}
+	pageEntries := map[string][]*Entry{
+		"page_1": {{Time: 100}, {Time: 200}, {Time: 0}},
+		"page_2": {{Time: 1000}},
+	}
+
+	thresholds := buildK6Thresholds(pages, pageEntries, 10)
+	assert.Equal(t, []string{
+		`"http_req_duration": ["p(95)<1100"]`,
+		`"http_req_duration{group:::page_1 - Home}": ["p(95)<220"]`,
+	}, thresholds)
+
+	entries := []*Entry{
+		{Pageref: "page_1", Time: 150, Request: &Request{Method: "GET", URL: "http://example.com/"}},
+		{Pageref: "page_2", Time: 300, Request: &Request{Method: "GET", URL: "http://example.com/search"}},
+	}
+	script, err := Convert(HAR{Log: &Log{Creator: &Creator{}, Pages: pages, Entries: entries}},
+		ConvertOptions{BatchTime: 500, Thresholds: true, ThresholdsMargin: 10})
+	assert.NoError(t, err)
+	assert.Contains(t, script, `"http_req_duration{group:::page_1 - Home}": ["p(95)<165"]`)
+}
+
+func TestConvertProtocols(t *testing.T) {
+	started := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	seconds := func(d time.Duration) float64 {
+		return float64(started.Add(d).UnixNano()) / 1e9
+	}
+	h := HAR{Log: &Log{
+		Creator: &Creator{},
+		Pages:   []Page{{ID: "page_1", Title: "Chat", StartedDateTime: started}},
+		Entries: []*Entry{
+			{
+				Pageref: "page_1", StartedDateTime: started,
+				Request: &Request{Method: "GET", URL: "https://example.com/"},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: started.Add(100 * time.Millisecond), ResourceType: "websocket",
+				Request: &Request{Method: "GET", URL: "wss://example.com/chat", Headers: []Header{
+					{"Origin", "https://example.com"},
+					{"Upgrade", "websocket"},
+					{"Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ=="},
+				}},
+				Response: &Response{Status: 101},
+				WebSocketMessages: []WebSocketMessage{
+					{Type: "send", Time: seconds(300 * time.Millisecond), Opcode: 1, Data: `{"text":"hi"}`},
+					{Type: "receive", Time: seconds(400 * time.Millisecond), Opcode: 1, Data: `{"text":"hello"}`},
+					{Type: "send", Time: seconds(600 * time.Millisecond), Opcode: 2, Data: "AQID"},
+				},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: started.Add(200 * time.Millisecond),
+				GRPC: &GRPCCall{Service: "chat.Rooms", Method: "List"},
+				Request: &Request{
+					Method: "POST", URL: "https://example.com/chat.Rooms/List",
+					Headers:  []Header{{"Content-Type", "application/grpc-web+proto"}, {"Accept", "application/grpc-web"}},
+					PostData: &PostData{MimeType: "application/grpc-web+proto", Text: "AAAAAAA=", Encoding: "base64"},
+				},
+			},
+		},
+	}}
+
+	t.Run("NoBatch", func(t *testing.T) {
+		script, err := Convert(h, ConvertOptions{EnableChecks: true, BatchTime: 500, NoBatch: true})
+		assert.NoError(t, err)
+		assert.Contains(t, script, "import ws from 'k6/ws';\n")
+		assert.Contains(t, script, `res = ws.connect("wss://example.com/chat", {`)
+		assert.Contains(t, script, `"Origin": "https://example.com"`)
+		assert.NotContains(t, script, "Sec-WebSocket-Key")
+		assert.Contains(t, script, `socket.setTimeout(function() { socket.send("{\"text\":\"hi\"}"); }, 200);`)
+		assert.NotContains(t, script, "hello")
+		assert.Contains(t, script, `socket.setTimeout(function() { socket.sendBinary([1, 2, 3]); }, 500);`)
+		assert.Contains(t, script, `socket.setTimeout(function() { socket.close(); }, 1500);`)
+		assert.Contains(t, script, `check(res, {"status is 101": (r) => r.status === 101 });`)
+
+		assert.Contains(t, script, "// gRPC-web call chat.Rooms/List\n")
+		assert.Contains(t, script, `"Content-Type": "application/grpc-web-text+proto"`)
+		assert.Contains(t, script, `"Accept": "application/grpc-web-text"`)
+		assert.Contains(t, script, `"AAAAAAA="`)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		script, err := Convert(h, ConvertOptions{BatchTime: 500})
+		assert.NoError(t, err)
+		assert.Contains(t, script, "res = http.batch(req);\n\t\tres = ws.connect(")
+		assert.Equal(t, 1, strings.Count(script, `"url": "https://example.com/chat.Rooms/List"`))
+		assert.NotContains(t, script, `"url": "wss://`)
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		script, err := Convert(h, ConvertOptions{BatchTime: 500, NoBatch: true, Protocols: []string{ProtocolHTTP}})
+		assert.NoError(t, err)
+		assert.NotContains(t, script, "k6/ws")
+		assert.NotContains(t, script, "ws.connect")
+		assert.NotContains(t, script, "chat.Rooms")
+		assert.Contains(t, script, `"https://example.com/"`)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := Convert(h, ConvertOptions{BatchTime: 500, NoBatch: true, Protocols: []string{"smtp"}})
+		assert.EqualError(t, err, `unknown protocol "smtp", expected one of http, ws, grpc-web`)
+	})
+}
@@ -0,0 +1,352 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	passwordFieldRE = regexp.MustCompile(`(?i)^(.*[_.-])?(pass(word)?|passwd|pwd)$`)
+	usernameFieldRE = regexp.MustCompile(`(?i)(user(name)?|login|e-?mail|account|uid)`)
+	tokenFieldRE    = regexp.MustCompile(`(?i)^(.*[_.-])?(access[_-]?token|id[_-]?token|token|jwt|session[_-]?id)$`)
+)
+
+// ErrNoLogin is returned when a login module is requested, but the recording has no login.
+var ErrNoLogin = errors.New("no login request found in the recording")
+
+// Tokens shorter than this aren't replaced in the script, as they're likely to match unrelated text.
+const minTokenLength = 8
+
+// A Login is a login sequence detected in a recording: a request submitting credentials, and
+// optionally a token in its response that later requests authenticate with.
+type Login struct {
+	Entry *Entry
+
+	UsernameField, Username string
+	PasswordField, Password string
+
+	// Path to the token in the JSON response, eg. ["data", "access_token"]; nil if there's none.
+	TokenPath []string
+	Token     string
+
+	// Set by the caller; where the script imports the login module from, and optionally a JSON
+	// data file with an array of {username, password} objects to log VUs in with. Credentials
+	// are taken from the LOGIN_USERNAME and LOGIN_PASSWORD environment variables otherwise.
+	Import      string
+	Credentials string
+}
+
+// DetectLogin returns the first request in a recording that submits a password, or nil.
+func DetectLogin(h HAR, only, skip []string) (*Login, error) {
+	entries := append([]*Entry{}, h.Log.Entries...)
+	sort.Stable(EntryByStarted(entries))
+	for _, e := range entries {
+		if e.Request == nil || e.Request.PostData == nil {
+			continue
+		}
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		if !IsAllowedURL(u.Host, only, skip) {
+			continue
+		}
+
+		fields, _, err := loginBodyFields(e.Request.PostData)
+		if err != nil || fields == nil {
+			continue
+		}
+		l := &Login{Entry: e}
+		for _, f := range fields {
+			if l.PasswordField == "" && passwordFieldRE.MatchString(f.Name) {
+				l.PasswordField, l.Password = f.Name, f.Value
+			}
+		}
+		if l.PasswordField == "" {
+			continue
+		}
+		for _, f := range fields {
+			if f.Name != l.PasswordField && usernameFieldRE.MatchString(f.Name) {
+				l.UsernameField, l.Username = f.Name, f.Value
+				break
+			}
+		}
+
+		if e.Response != nil && e.Response.Content != nil && strings.Contains(e.Response.Content.MimeType, "json") {
+			var body interface{}
+			if err := json.Unmarshal([]byte(e.Response.Content.Text), &body); err == nil {
+				l.TokenPath, l.Token = findToken(body, nil, 3)
+			}
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// Returns the fields of a body that may carry credentials, in order, and whether it's JSON.
+func loginBodyFields(pd *PostData) ([]Param, bool, error) {
+	switch {
+	case strings.Contains(pd.MimeType, "json"):
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(pd.Text), &body); err != nil {
+			return nil, true, err
+		}
+		var fields []Param
+		for k, v := range body {
+			if s, ok := v.(string); ok {
+				fields = append(fields, Param{Name: k, Value: s})
+			}
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		return fields, true, nil
+	case strings.HasPrefix(pd.MimeType, "application/x-www-form-urlencoded"):
+		if len(pd.Params) == 0 {
+			values, err := url.ParseQuery(pd.Text)
+			if err != nil {
+				return nil, false, err
+			}
+			var fields []Param
+			for _, kv := range strings.Split(pd.Text, "&") {
+				name, err := url.QueryUnescape(strings.SplitN(kv, "=", 2)[0])
+				if err != nil || name == "" {
+					continue
+				}
+				fields = append(fields, Param{Name: name, Value: values.Get(name)})
+			}
+			return fields, false, nil
+		}
+		fields := make([]Param, 0, len(pd.Params))
+		for _, p := range pd.Params {
+			name, err := url.QueryUnescape(p.Name)
+			if err != nil {
+				return nil, false, err
+			}
+			value, err := url.QueryUnescape(p.Value)
+			if err != nil {
+				return nil, false, err
+			}
+			fields = append(fields, Param{Name: name, Value: value})
+		}
+		return fields, false, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// Finds the shallowest string value with a token-like key, searching down to a max depth.
+func findToken(v interface{}, path []string, depth int) ([]string, string) {
+	obj, ok := v.(map[string]interface{})
+	if !ok || depth == 0 {
+		return nil, ""
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if s, ok := obj[k].(string); ok && len(s) >= minTokenLength && tokenFieldRE.MatchString(k) {
+			return append(append([]string{}, path...), k), s
+		}
+	}
+	for _, k := range keys {
+		if p, s := findToken(obj[k], append(append([]string{}, path...), k), depth-1); p != nil {
+			return p, s
+		}
+	}
+	return nil, ""
+}
+
+// Module generates a k6 module exporting a login(username, password) function, which replays the
+// login request with the given credentials and returns the response, and the token if any.
+func (l *Login) Module() (string, error) {
+	req := l.Entry.Request
+	fields, isJSON, err := loginBodyFields(req.PostData)
+	if err != nil {
+		return "", err
+	}
+
+	// Build the body as a JS object, with the credentials swapped for the function's arguments.
+	body := make([]string, 0, len(fields))
+	if isJSON {
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(req.PostData.Text), &values); err != nil {
+			return "", err
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			body = append(body, fmt.Sprintf("%q: %s", k, l.bodyValue(k, string(values[k]))))
+		}
+	} else {
+		for _, f := range fields {
+			body = append(body, fmt.Sprintf("%q: %s", f.Name, l.bodyValue(f.Name, fmt.Sprintf("%q", f.Value))))
+		}
+	}
+
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	fmt.Fprint(w, "import { check } from 'k6';\n")
+	fmt.Fprint(w, "import http from 'k6/http';\n")
+	fmt.Fprint(w, "\n")
+	fmt.Fprintf(w, "// Replays the recorded login request: %s %s\n", req.Method, req.URL)
+	fmt.Fprint(w, "export function login(username, password) {\n")
+	fmt.Fprintf(w, "\tlet body = {\n\t\t%s\n\t};\n", strings.Join(body, ",\n\t\t"))
+	if isJSON {
+		fmt.Fprint(w, "\tbody = JSON.stringify(body);\n")
+	}
+	var headers []string
+	for _, h := range buildK6Headers(req.Headers) {
+		// The length changes with the credentials; k6 sets it.
+		if !strings.HasPrefix(strings.ToLower(h), `"content-length"`) {
+			headers = append(headers, h)
+		}
+	}
+	fmt.Fprintf(w, "\tlet res = http.%s(%q, body, {\n\t\t\"headers\": {\n\t\t\t%s\n\t\t}\n\t});\n",
+		k6Method(req.Method), req.URL, strings.Join(headers, ",\n\t\t\t"))
+	if l.Entry.Response != nil && l.Entry.Response.Status > 0 {
+		fmt.Fprintf(w, "\tcheck(res, { \"login succeeded\": (r) => r.status === %d });\n", l.Entry.Response.Status)
+	}
+	if l.TokenPath != nil {
+		fmt.Fprintf(w, "\treturn { res: res, token: JSON.parse(res.body)%s };\n", jsPropertyPath(l.TokenPath))
+	} else {
+		fmt.Fprint(w, "\treturn { res: res };\n")
+	}
+	fmt.Fprint(w, "}\n")
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (l *Login) bodyValue(name, recorded string) string {
+	switch name {
+	case l.UsernameField:
+		return "username"
+	case l.PasswordField:
+		return "password"
+	default:
+		return recorded
+	}
+}
+
+// Returns an entry without the recorded values of the cookies set by the login response, as the
+// cookie jar has fresh ones from the VU's own login.
+func (l *Login) stripSessionCookies(e *Entry) *Entry {
+	if l.Entry.Response == nil || len(l.Entry.Response.Cookies) == 0 || len(e.Request.Cookies) == 0 {
+		return e
+	}
+	session := make(map[string]bool, len(l.Entry.Response.Cookies))
+	for _, c := range l.Entry.Response.Cookies {
+		session[c.Name] = true
+	}
+	req := *e.Request
+	req.Cookies = nil
+	for _, c := range e.Request.Cookies {
+		if !session[c.Name] {
+			req.Cookies = append(req.Cookies, c)
+		}
+	}
+	stripped := *e
+	stripped.Request = &req
+	return &stripped
+}
+
+// Returns the code that logs a VU in at the start of an iteration.
+func (l *Login) call() string {
+	if l.Credentials != "" {
+		return "\tlet user = credentials[(__VU - 1) % credentials.length];\n" +
+			"\tlet session = login(user.username, user.password);\n"
+	}
+	return "\tlet session = login(__ENV.LOGIN_USERNAME, __ENV.LOGIN_PASSWORD);\n"
+}
+
+// Replaces the recorded token in the string literals of a script with the session's token.
+func (l *Login) replaceToken(script string) string {
+	if len(l.Token) < minTokenLength || l.TokenPath == nil {
+		return script
+	}
+
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote == 0 && strings.HasPrefix(script[i:], "//"):
+			// Copy comments as they are.
+			end := strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				end = len(script) - i
+			}
+			b.WriteString(script[i : i+end])
+			i += end - 1
+			continue
+		case quote != 0 && quote != '`' && c == '\n':
+			quote = 0
+		case quote != 0 && c == '\\':
+			b.WriteByte(c)
+			i++
+			if i < len(script) {
+				b.WriteByte(script[i])
+			}
+			continue
+		case quote == 0 && (c == '"' || c == '\'' || c == '`'):
+			quote = c
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0 && strings.HasPrefix(script[i:], l.Token):
+			if quote == '`' {
+				b.WriteString("${session.token}")
+			} else {
+				b.WriteString(string(quote) + " + session.token + " + string(quote))
+			}
+			i += len(l.Token) - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return strings.NewReplacer(
+		`"" + session.token`, "session.token", `session.token + ""`, "session.token",
+		`'' + session.token`, "session.token", `session.token + ''`, "session.token",
+	).Replace(b.String())
+}
+
+// Returns the property accessors for a path, eg. `["data"]["token"]`.
+func jsPropertyPath(path []string) string {
+	var s string
+	for _, p := range path {
+		s += fmt.Sprintf("[%q]", p)
+	}
+	return s
+}
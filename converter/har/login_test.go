@@ -0,0 +1,175 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "eyJhbGciOiJIUzI1NiJ9.c2Vzc2lvbg.c2lnbmF0dXJl"
+
+func loginHAR() HAR {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	return HAR{Log: &Log{
+		Creator: &Creator{Name: "test"},
+		Pages:   []Page{{ID: "page_1", Title: "Login", StartedDateTime: start}},
+		Entries: []*Entry{
+			{
+				Pageref: "page_1", StartedDateTime: start,
+				Request: &Request{Method: "GET", URL: "https://example.com/login"},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: start.Add(time.Second),
+				Request: &Request{
+					Method:  "POST",
+					URL:     "https://example.com/api/login",
+					Headers: []Header{{"Content-Type", "application/json"}, {"Content-Length", "58"}},
+					PostData: &PostData{
+						MimeType: "application/json",
+						Text:     `{"email":"alice@example.com","password":"hunter2","remember":true}`,
+					},
+				},
+				Response: &Response{
+					Status:  200,
+					Cookies: []Cookie{{Name: "sid", Value: "abc"}},
+					Content: &Content{
+						MimeType: "application/json",
+						Text:     `{"user":{"id":1},"data":{"access_token":"` + testToken + `","type":"bearer"}}`,
+					},
+				},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: start.Add(2 * time.Second),
+				Request: &Request{
+					Method:  "GET",
+					URL:     "https://example.com/api/me",
+					Headers: []Header{{"Authorization", "Bearer " + testToken}},
+					Cookies: []Cookie{{Name: "sid", Value: "abc"}, {Name: "theme", Value: "dark"}},
+				},
+			},
+		},
+	}}
+}
+
+func TestDetectLogin(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		h := loginHAR()
+		login, err := DetectLogin(h, nil, nil)
+		require.NoError(t, err)
+		require.NotNil(t, login)
+		assert.Equal(t, h.Log.Entries[1], login.Entry)
+		assert.Equal(t, "email", login.UsernameField)
+		assert.Equal(t, "alice@example.com", login.Username)
+		assert.Equal(t, "password", login.PasswordField)
+		assert.Equal(t, "hunter2", login.Password)
+		assert.Equal(t, []string{"data", "access_token"}, login.TokenPath)
+		assert.Equal(t, testToken, login.Token)
+	})
+	t.Run("Form", func(t *testing.T) {
+		h := loginHAR()
+		h.Log.Entries[1].Request.PostData = &PostData{
+			MimeType: "application/x-www-form-urlencoded",
+			Text:     "csrf=x&user_name=alice&user_pwd=hunter%202",
+		}
+		h.Log.Entries[1].Response.Content = &Content{MimeType: "text/html"}
+		login, err := DetectLogin(h, nil, nil)
+		require.NoError(t, err)
+		require.NotNil(t, login)
+		assert.Equal(t, "user_name", login.UsernameField)
+		assert.Equal(t, "user_pwd", login.PasswordField)
+		assert.Equal(t, "hunter 2", login.Password)
+		assert.Nil(t, login.TokenPath)
+	})
+	t.Run("None", func(t *testing.T) {
+		login, err := DetectLogin(loginHAR(), []string{"other.com"}, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, login)
+	})
+}
+
+func TestLoginModule(t *testing.T) {
+	login, err := DetectLogin(loginHAR(), nil, nil)
+	require.NoError(t, err)
+	module, err := login.Module()
+	require.NoError(t, err)
+	assert.Equal(t, `import { check } from 'k6';
+import http from 'k6/http';
+
+// Replays the recorded login request: POST https://example.com/api/login
+export function login(username, password) {
+	let body = {
+		"email": username,
+		"password": password,
+		"remember": true
+	};
+	body = JSON.stringify(body);
+	let res = http.post("https://example.com/api/login", body, {
+		"headers": {
+			"Content-Type": "application/json"
+		}
+	});
+	check(res, { "login succeeded": (r) => r.status === 200 });
+	return { res: res, token: JSON.parse(res.body)["data"]["access_token"] };
+}
+`, module)
+
+	t.Run("Delete", func(t *testing.T) {
+		// Some APIs log in by deleting the previous session.
+		h := loginHAR()
+		h.Log.Entries[1].Request.Method = "DELETE"
+		login, err := DetectLogin(h, nil, nil)
+		require.NoError(t, err)
+		module, err := login.Module()
+		require.NoError(t, err)
+		assert.Contains(t, module, `let res = http.del("https://example.com/api/login", body, {`)
+	})
+}
+
+func TestLoginReplaceToken(t *testing.T) {
+	login := &Login{Token: testToken, TokenPath: []string{"token"}}
+	assert.Equal(t,
+		`{"Authorization": "Bearer " + session.token}, 'x' + session.token, `+"`{\"t\": \"${session.token}\"}`\n// don't touch "+testToken,
+		login.replaceToken(`{"Authorization": "Bearer `+testToken+`"}, 'x`+testToken+`', `+"`{\"t\": \""+testToken+"\"}`\n// don't touch "+testToken),
+	)
+}
+
+func TestConvertWithLogin(t *testing.T) {
+	h := loginHAR()
+	login, err := DetectLogin(h, nil, nil)
+	require.NoError(t, err)
+	login.Import = "./login.js"
+	login.Credentials = "users.json"
+
+	script, err := Convert(h, ConvertOptions{BatchTime: 500, NoBatch: true, Login: login})
+	require.NoError(t, err)
+	assert.Contains(t, script, "import { login } from './login.js';\n")
+	assert.Contains(t, script, "const credentials = JSON.parse(open(\"users.json\"));\n")
+	assert.Contains(t, script, "\tlet user = credentials[(__VU - 1) % credentials.length];\n\tlet session = login(user.username, user.password);\n")
+	assert.NotContains(t, script, "api/login")
+	assert.Contains(t, script, `"Authorization": "Bearer " + session.token`)
+	assert.NotContains(t, script, testToken)
+	assert.NotContains(t, script, `"sid"`)
+	assert.Contains(t, script, `"theme": "dark"`)
+}
@@ -0,0 +1,201 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Markers around the regions of a script generated by the converter. Begin markers also carry a
+// hash of the region's generated content, which is used to tell if it has been edited by hand.
+const (
+	regionBegin = "// k6-convert:begin "
+	regionEnd   = "// k6-convert:end "
+)
+
+type region struct {
+	id, hash   string
+	begin, end string
+	lines      []string
+	edited     bool
+}
+
+// A script is a sequence of regions and the lines between them; for lines, region is nil.
+type scriptPart struct {
+	line   string
+	region *region
+}
+
+func regionHash(lines []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// sealRegions adds the hash of each region's content to its begin marker.
+func sealRegions(script string) string {
+	lines := strings.Split(script, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, regionBegin) {
+			continue
+		}
+		id := strings.TrimPrefix(trimmed, regionBegin)
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == regionEnd+id {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				lines[i] = indent + regionBegin + regionHash(lines[i+1:j]) + " " + id
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseRegions(script string) ([]scriptPart, map[string]*region, error) {
+	var parts []scriptPart
+	regions := make(map[string]*region)
+	var current *region
+	for n, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, regionBegin):
+			if current != nil {
+				return nil, nil, errors.Errorf("line %d: region '%s' starts inside region '%s'", n+1, trimmed, current.id)
+			}
+			fields := strings.SplitN(strings.TrimPrefix(trimmed, regionBegin), " ", 2)
+			if len(fields) != 2 {
+				return nil, nil, errors.Errorf("line %d: malformed region marker", n+1)
+			}
+			current = &region{hash: fields[0], id: fields[1], begin: line}
+			if _, ok := regions[current.id]; ok {
+				return nil, nil, errors.Errorf("line %d: duplicate region '%s'", n+1, current.id)
+			}
+		case strings.HasPrefix(trimmed, regionEnd):
+			id := strings.TrimPrefix(trimmed, regionEnd)
+			if current == nil || current.id != id {
+				return nil, nil, errors.Errorf("line %d: unexpected end of region '%s'", n+1, id)
+			}
+			current.end = line
+			current.edited = regionHash(current.lines) != current.hash
+			regions[id] = current
+			parts = append(parts, scriptPart{region: current})
+			current = nil
+		case current != nil:
+			current.lines = append(current.lines, line)
+		default:
+			parts = append(parts, scriptPart{line: line})
+		}
+	}
+	if current != nil {
+		return nil, nil, errors.Errorf("region '%s' is never closed", current.id)
+	}
+	return parts, regions, nil
+}
+
+// MergeScript merges a freshly generated script into a previously generated and possibly hand-edited
+// one. Everything outside of the generated regions of the existing script is kept as is. Regions
+// that weren't edited are replaced with their new version, regions that were edited are kept, new
+// regions are inserted after the region that precedes them in the generated script and regions
+// that disappeared from the recording are removed, unless they were edited. The returned warnings
+// list the edited regions that were kept even though the recording changed.
+func MergeScript(existing, generated string) (string, []string, error) {
+	oldParts, oldRegions, err := parseRegions(existing)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "existing script")
+	}
+	if len(oldRegions) == 0 {
+		return "", nil, errors.New("the existing script doesn't contain any generated regions")
+	}
+	newParts, newRegions, err := parseRegions(generated)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "generated script")
+	}
+
+	var warnings []string
+	keep := func(old *region) {
+		if newRegion, ok := newRegions[old.id]; !ok {
+			warnings = append(warnings, "'"+old.id+"' was edited by hand, but isn't part of the recording anymore; keeping it")
+		} else if newRegion.hash != old.hash {
+			warnings = append(warnings, "'"+old.id+"' was edited by hand and has changed in the recording; keeping the edited version")
+		}
+	}
+
+	// Regions that are new in the recording follow the region that precedes them there.
+	following := make(map[string][]*region)
+	var leading []*region
+	prev := ""
+	for _, p := range newParts {
+		if p.region == nil {
+			continue
+		}
+		if _, ok := oldRegions[p.region.id]; !ok {
+			if prev == "" {
+				leading = append(leading, p.region)
+			} else {
+				following[prev] = append(following[prev], p.region)
+			}
+		}
+		prev = p.region.id
+	}
+
+	var out []string
+	var write func(r *region)
+	write = func(r *region) {
+		out = append(out, r.begin)
+		out = append(out, r.lines...)
+		out = append(out, r.end)
+		for _, next := range following[r.id] {
+			write(next)
+		}
+	}
+	first := true
+	for _, p := range oldParts {
+		if p.region == nil {
+			out = append(out, p.line)
+			continue
+		}
+		if first {
+			for _, r := range leading {
+				write(r)
+			}
+			first = false
+		}
+		old := p.region
+		newRegion, ok := newRegions[old.id]
+		switch {
+		case old.edited:
+			keep(old)
+			write(old)
+		case ok:
+			write(newRegion)
+		default:
+			// Removed from the recording; anything generated after it still needs a place.
+			for _, next := range following[old.id] {
+				write(next)
+			}
+		}
+	}
+	return strings.Join(out, "\n"), warnings, nil
+}
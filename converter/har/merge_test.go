@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateRegions(regions ...string) string {
+	var b strings.Builder
+	b.WriteString("// header\n")
+	for i := 0; i < len(regions); i += 2 {
+		b.WriteString(regionBegin + regions[i] + "\n" + regions[i+1] + "\n" + regionEnd + regions[i] + "\n")
+	}
+	b.WriteString("// footer\n")
+	return sealRegions(b.String())
+}
+
+func TestMergeScript(t *testing.T) {
+	existing := generateRegions("a", "let a = 1;", "b", "let b = 1;", "c", "let c = 1;")
+	assert.Contains(t, existing, regionBegin+regionHash([]string{"let a = 1;"})+" a\n")
+
+	t.Run("Unchanged", func(t *testing.T) {
+		merged, warnings, err := MergeScript(existing, existing)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, existing, merged)
+	})
+
+	t.Run("Updated", func(t *testing.T) {
+		generated := generateRegions("a", "let a = 2;", "b", "let b = 2;", "c", "let c = 1;")
+		edited := strings.Replace(existing, "let b = 1;", "let b = 1; check(b);", 1)
+		edited = strings.Replace(edited, "// footer", "// footer\nfunction helper() {}", 1)
+
+		merged, warnings, err := MergeScript(edited, generated)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"'b' was edited by hand and has changed in the recording; keeping the edited version"}, warnings)
+		assert.Contains(t, merged, "let a = 2;")
+		assert.Contains(t, merged, "let b = 1; check(b);")
+		assert.Contains(t, merged, "function helper() {}")
+
+		// Merging the same recording again shouldn't change anything.
+		again, _, err := MergeScript(merged, generated)
+		require.NoError(t, err)
+		assert.Equal(t, merged, again)
+	})
+
+	t.Run("AddedAndRemoved", func(t *testing.T) {
+		generated := generateRegions("new", "let n = 1;", "a", "let a = 1;", "a2", "let a2 = 1;", "a3", "let a3 = 1;", "c", "let c = 1;")
+		merged, warnings, err := MergeScript(existing, generated)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, generated, merged)
+	})
+
+	t.Run("EditedAndRemoved", func(t *testing.T) {
+		edited := strings.Replace(existing, "let b = 1;", "let b = 3;", 1)
+		generated := generateRegions("a", "let a = 1;", "c", "let c = 1;")
+		merged, warnings, err := MergeScript(edited, generated)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"'b' was edited by hand, but isn't part of the recording anymore; keeping it"}, warnings)
+		assert.Contains(t, merged, "let b = 3;")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, _, err := MergeScript("let a = 1;", existing)
+		assert.EqualError(t, err, "the existing script doesn't contain any generated regions")
+
+		_, _, err = MergeScript(strings.Replace(existing, regionEnd+"a", "", 1), existing)
+		assert.EqualError(t, err, "existing script: line 5: region '"+strings.Split(existing, "\n")[4]+"' starts inside region 'a'")
+	})
+}
+
+func TestMergeRecordings(t *testing.T) {
+	record := func(ids ...string) string {
+		h := HAR{Log: &Log{Creator: &Creator{}}}
+		started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, title := range []string{"https://example.com/", "https://example.com/search", "https://example.com/"} {
+			started = started.Add(time.Second)
+			h.Log.Pages = append(h.Log.Pages, Page{ID: ids[i], Title: title, StartedDateTime: started})
+			h.Log.Entries = append(h.Log.Entries, &Entry{
+				Pageref: ids[i], StartedDateTime: started,
+				Request:  &Request{Method: "GET", URL: title},
+				Response: &Response{Status: 200},
+			})
+		}
+		script, err := Convert(h, ConvertOptions{BatchTime: 500, Regions: true})
+		require.NoError(t, err)
+		return script
+	}
+
+	existing := record("page_1", "page_2", "page_3")
+	assert.Contains(t, existing, regionEnd+"page https://example.com/\n")
+	assert.Contains(t, existing, regionEnd+"page https://example.com/search\n")
+	assert.Contains(t, existing, regionEnd+"page https://example.com/ #2\n")
+
+	// The page IDs of another recording of the same flow don't match, so the edited region has to
+	// be found by its page's title.
+	edited := strings.Replace(existing, `"https://example.com/search"`, `"https://example.com/search?q=edited"`, 1)
+	merged, _, err := MergeScript(edited, record("page_4", "page_5", "page_6"))
+	require.NoError(t, err)
+	assert.Contains(t, merged, "https://example.com/search?q=edited")
+	assert.NotContains(t, merged, `"https://example.com/search"`)
+	assert.Equal(t, 3, strings.Count(merged, regionEnd+"page "))
+}
@@ -0,0 +1,232 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Protocols the entries of a recording can be converted for.
+const (
+	ProtocolHTTP      = "http"
+	ProtocolWebSocket = "ws"
+	ProtocolGRPCWeb   = "grpc-web"
+)
+
+// Protocols is the list of all protocols entries can be converted for.
+var Protocols = []string{ProtocolHTTP, ProtocolWebSocket, ProtocolGRPCWeb}
+
+// Protocol returns the protocol the entry was recorded for.
+func (e *Entry) Protocol() string {
+	if e.ResourceType == "websocket" || len(e.WebSocketMessages) > 0 {
+		return ProtocolWebSocket
+	}
+	if e.GRPC != nil {
+		return ProtocolGRPCWeb
+	}
+	if e.Request == nil {
+		return ProtocolHTTP
+	}
+	if u, err := url.Parse(e.Request.URL); err == nil && (u.Scheme == "ws" || u.Scheme == "wss") {
+		return ProtocolWebSocket
+	}
+	if isGRPCWeb(requestContentType(e.Request)) {
+		return ProtocolGRPCWeb
+	}
+	return ProtocolHTTP
+}
+
+// validateProtocols returns an error if any of the given protocols isn't known.
+func validateProtocols(protocols []string) error {
+	for _, p := range protocols {
+		if !isAllowedProtocol(p, Protocols) {
+			return errors.Errorf("unknown protocol %q, expected one of %s", p, strings.Join(Protocols, ", "))
+		}
+	}
+	return nil
+}
+
+// isAllowedProtocol returns whether the protocol is in the list of protocols; all of them are
+// allowed if the list is empty.
+func isAllowedProtocol(protocol string, protocols []string) bool {
+	if len(protocols) == 0 {
+		return true
+	}
+	for _, p := range protocols {
+		if strings.TrimSpace(p) == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+func isGRPCWeb(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc-web")
+}
+
+func requestContentType(req *Request) string {
+	if req.PostData != nil && req.PostData.MimeType != "" {
+		return req.PostData.MimeType
+	}
+	for _, h := range req.Headers {
+		if strings.EqualFold(h.Name, "content-type") {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// grpcWebMethod returns the gRPC method called by a gRPC-web entry, as Service/Method.
+func grpcWebMethod(e *Entry) string {
+	if e.GRPC != nil && e.GRPC.Service != "" {
+		return e.GRPC.Service + "/" + e.GRPC.Method
+	}
+	if u, err := url.Parse(e.Request.URL); err == nil {
+		return strings.TrimPrefix(u.Path, "/")
+	}
+	return ""
+}
+
+// buildGRPCWebRequest returns the request of a gRPC-web entry, ready to be replayed. A binary
+// body can't be written into a script, so such calls are replayed in the grpc-web-text format,
+// which carries the same messages base64 encoded, and which gRPC-web proxies accept as well.
+func buildGRPCWebRequest(req *Request) *Request {
+	contentType := requestContentType(req)
+	if strings.HasPrefix(contentType, "application/grpc-web-text") || req.PostData == nil {
+		return req
+	}
+
+	text := req.PostData.Text
+	if req.PostData.Encoding != "base64" {
+		text = base64.StdEncoding.EncodeToString([]byte(text))
+	}
+	textType := strings.Replace(contentType, "application/grpc-web", "application/grpc-web-text", 1)
+
+	r := *req
+	r.PostData = &PostData{MimeType: textType, Text: text}
+	r.Headers = make([]Header, len(req.Headers))
+	for i, h := range req.Headers {
+		switch strings.ToLower(h.Name) {
+		case "content-type":
+			h.Value = textType
+		case "accept":
+			h.Value = strings.Replace(h.Value, "application/grpc-web", "application/grpc-web-text", -1)
+		case "content-length":
+			h.Value = fmt.Sprint(len(text))
+		}
+		r.Headers[i] = h
+	}
+	return &r
+}
+
+// webSocketHeaders are set by the WebSocket handshake itself, and can't be given to ws.connect().
+var webSocketHeaders = map[string]bool{
+	"connection":               true,
+	"upgrade":                  true,
+	"sec-websocket-key":        true,
+	"sec-websocket-version":    true,
+	"sec-websocket-extensions": true,
+}
+
+// webSocketCloseDelay is how long after the last recorded message a replayed connection is
+// closed, leaving time for the responses to the last messages sent.
+const webSocketCloseDelay = 1000
+
+// buildK6WebSocket returns a ws.connect() call replaying the messages the client sent over a
+// recorded WebSocket connection, at the same times into the connection, and closing it after
+// the last recorded message.
+func buildK6WebSocket(e *Entry, indent string) (string, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+
+	var headers []Header
+	for _, h := range e.Request.Headers {
+		if !webSocketHeaders[strings.ToLower(h.Name)] {
+			headers = append(headers, h)
+		}
+	}
+	var params []string
+	var cookies []string
+	for _, c := range e.Request.Cookies {
+		cookies = append(cookies, fmt.Sprintf(`%q: %q`, c.Name, c.Value))
+	}
+	if len(cookies) > 0 {
+		params = append(params, fmt.Sprintf("\"cookies\": { %s }", strings.Join(cookies, ", ")))
+	}
+	if h := buildK6Headers(headers); len(h) > 0 {
+		params = append(params, fmt.Sprintf("\"headers\": {\n%s\t\t%s\n%s\t}", indent, strings.Join(h, ",\n"+indent+"\t\t"), indent))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ws.connect(%q, ", u.String())
+	if len(params) > 0 {
+		fmt.Fprintf(&b, "{\n%s\t%s\n%s}, ", indent, strings.Join(params, ",\n"+indent+"\t"), indent)
+	}
+	fmt.Fprintf(&b, "function(socket) {\n%s\tsocket.on(\"open\", function() {\n", indent)
+
+	started := e.StartedDateTime.UnixNano() / 1e6
+	var closeAt int64
+	for _, m := range e.WebSocketMessages {
+		at := int64(m.Time*1000) - started
+		if at < 0 {
+			at = 0
+		}
+		if at > closeAt {
+			closeAt = at
+		}
+		if m.Type != "send" {
+			continue
+		}
+		send := fmt.Sprintf("socket.send(%q);", m.Data)
+		if m.Opcode == 2 {
+			data, err := base64.StdEncoding.DecodeString(m.Data)
+			if err != nil {
+				return "", errors.Wrap(err, "invalid binary WebSocket message")
+			}
+			bytes := make([]string, len(data))
+			for i, c := range data {
+				bytes[i] = fmt.Sprint(c)
+			}
+			send = fmt.Sprintf("socket.sendBinary([%s]);", strings.Join(bytes, ", "))
+		}
+		fmt.Fprintf(&b, "%s\t\tsocket.setTimeout(function() { %s }, %d);\n", indent, send, at)
+	}
+	if len(e.WebSocketMessages) > 0 {
+		fmt.Fprintf(&b, "%s\t\tsocket.setTimeout(function() { socket.close(); }, %d);\n", indent, closeAt+webSocketCloseDelay)
+	} else {
+		fmt.Fprintf(&b, "%s\t\tsocket.close();\n", indent)
+	}
+	fmt.Fprintf(&b, "%s\t});\n%s})", indent, indent)
+	return b.String(), nil
+}
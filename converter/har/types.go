@@ -102,6 +102,36 @@ type Entry struct {
 	// Timings describes various phases within request-response round trip. All
 	// times are specified in milliseconds.
 	Timings *Timings `json:"timings"`
+
+	// The fields below aren't part of HAR 1.2, but extensions recorders use to capture other
+	// protocols than plain HTTP, and are optional.
+
+	// ResourceType is the kind of resource requested, "websocket" for WebSocket connections.
+	ResourceType string `json:"_resourceType,omitempty"`
+	// WebSocketMessages is a list of the messages sent and received over a WebSocket connection.
+	WebSocketMessages []WebSocketMessage `json:"_webSocketMessages,omitempty"`
+	// GRPC describes the gRPC call made by a gRPC-web request.
+	GRPC *GRPCCall `json:"_grpc,omitempty"`
+}
+
+// WebSocketMessage is a message sent or received over a WebSocket connection.
+type WebSocketMessage struct {
+	// Type is "send" for messages sent by the client, and "receive" for messages it received.
+	Type string `json:"type"`
+	// Time is the time the message was sent or received at, in seconds since the Unix epoch.
+	Time float64 `json:"time"`
+	// Opcode is the WebSocket frame opcode, 1 for text and 2 for binary messages.
+	Opcode int `json:"opcode"`
+	// Data is the message, base64 encoded for binary messages.
+	Data string `json:"data"`
+}
+
+// GRPCCall describes a gRPC call made over gRPC-web.
+type GRPCCall struct {
+	// Service is the fully qualified name of the service (package.Service).
+	Service string `json:"service"`
+	// Method is the name of the called method.
+	Method string `json:"method"`
 }
 
 // Request holds data about an individual HTTP request.
@@ -218,6 +248,9 @@ type PostData struct {
 	Params []Param `json:"params"`
 	// Text contains the plain text posted data.
 	Text string `json:"text"`
+	// Encoding used for the text field, "base64" for binary data. This isn't part of
+	// HAR 1.2, but an extension recorders use to capture binary request bodies.
+	Encoding string `json:"_encoding,omitempty"`
 }
 
 // Param describes an individual posted parameter.
@@ -0,0 +1,295 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// An Agent runs its share of a distributed test, as the coordinator assigns it.
+type Agent struct {
+	// The coordinator's address, eg. "localhost:6566", or its URL.
+	Coordinator string
+
+	// The agent's name, for the coordinator's logs.
+	Name string
+
+	// The token the agent authenticates with, which the coordinator was given.
+	Token string
+
+	// Creates a runner for the test from its archive; nil if the coordinator has none.
+	NewRunner func(arc *lib.Archive) (lib.Runner, error)
+
+	// How often the agent sends its samples to the coordinator.
+	FlushInterval time.Duration
+
+	Client *http.Client
+	Logger *log.Logger
+}
+
+// NewAgent returns an Agent that runs its share of the coordinator's test with runners it
+// creates with newRunner.
+func NewAgent(coordinator string, newRunner func(arc *lib.Archive) (lib.Runner, error)) *Agent {
+	return &Agent{
+		Coordinator:   coordinator,
+		NewRunner:     newRunner,
+		FlushInterval: 1 * time.Second,
+		Client:        http.DefaultClient,
+		Logger:        log.StandardLogger(),
+	}
+}
+
+// Run registers with the coordinator, waits for the test to start, and runs the agent's share of
+// it, until it's over or the context is done.
+func (a *Agent) Run(ctx context.Context) error {
+	asg, err := a.register(ctx)
+	if err != nil || asg == nil {
+		return err
+	}
+	a.Logger.WithFields(log.Fields{"id": asg.ID, "scenarios": len(asg.Options.Scenarios)}).
+		Info("Assigned a share of the test")
+
+	engine, err := a.newEngine(asg)
+	if err != nil {
+		_ = a.post(ctx, fmt.Sprintf("/v1/agents/%d/done", asg.ID), Result{Error: err.Error()}, nil)
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c := &forwarder{agent: a, id: asg.ID, executor: engine.Executor, stop: cancel}
+	engine.Collectors = append(engine.Collectors, c)
+	runErr := engine.Run(runCtx)
+
+	res := Result{Batch: c.batch(), Unsent: c.unsent}
+	if runErr != nil {
+		res.Error = runErr.Error()
+	}
+	if err := a.post(ctx, fmt.Sprintf("/v1/agents/%d/done", asg.ID), res, nil); err != nil {
+		return errors.Wrap(err, "couldn't report to the coordinator")
+	}
+	return runErr
+}
+
+// register registers the agent with the coordinator, retrying until there's a test for it to
+// take part in, and returns its assignment; or nil, if the context is done first.
+func (a *Agent) register(ctx context.Context) (*Assignment, error) {
+	for {
+		var asg Assignment
+		err := a.post(ctx, "/v1/agents", Registration{Name: a.Name}, &asg)
+		if err == nil {
+			return &asg, nil
+		}
+		if e, ok := errors.Cause(err).(statusError); ok && e.code != http.StatusServiceUnavailable {
+			return nil, err
+		}
+		a.Logger.WithError(err).Debug("Agent: Couldn't register, retrying")
+
+		select {
+		case <-time.After(1 * time.Second):
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+// newEngine creates the engine that runs the agent's share of the test. It leaves thresholds to
+// the coordinator, as well as setup() and teardown().
+func (a *Agent) newEngine(asg *Assignment) (*core.Engine, error) {
+	var arc *lib.Archive
+	if len(asg.Archive) > 0 {
+		var err error
+		if arc, err = lib.ReadArchive(bytes.NewReader(asg.Archive)); err != nil {
+			return nil, err
+		}
+	}
+	r, err := a.NewRunner(arc)
+	if err != nil {
+		return nil, err
+	}
+	r.SetOptions(asg.Options)
+	if err := r.SetSetupData(asg.SetupData); err != nil {
+		return nil, err
+	}
+
+	ex := local.New(r)
+	ex.SetRunSetup(false)
+	ex.SetRunTeardown(false)
+	engine, err := core.NewEngine(ex, asg.Options)
+	if err != nil {
+		return nil, err
+	}
+	engine.SetLogger(a.Logger)
+	engine.NoThresholds = true
+	return engine, nil
+}
+
+// A statusError is an unsuccessful response from the coordinator.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.code, http.StatusText(e.code), e.msg)
+}
+
+// post sends a request to the coordinator, and decodes the response into out, if it's not nil.
+func (a *Agent) post(ctx context.Context, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	url := a.Coordinator
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(url, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	res, err := a.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode >= 400 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return statusError{res.StatusCode, strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// A forwarder is a collector that sends an agent's samples to the coordinator, and follows its
+// instructions.
+type forwarder struct {
+	agent    *Agent
+	id       int
+	executor lib.Executor
+	stop     func()
+
+	lock    sync.Mutex
+	samples []Sample
+	seq     int64
+
+	// Batches that haven't made it to the coordinator yet, oldest first.
+	unsent []Batch
+}
+
+func (f *forwarder) Init() error {
+	return nil
+}
+
+func (f *forwarder) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.agent.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.flush(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (f *forwarder) Collect(samples []stats.Sample) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, s := range samples {
+		// The coordinator emits the VUs of the whole test.
+		if s.Metric == metrics.VUs || s.Metric == metrics.VUsMax {
+			continue
+		}
+		f.samples = append(f.samples, NewSample(s))
+	}
+}
+
+func (f *forwarder) Link() string {
+	return ""
+}
+
+func (f *forwarder) GetRequiredSystemTags() lib.TagSet {
+	return lib.GetTagSet("group", "check")
+}
+
+// batch takes the samples collected so far, along with the agent's progress, and numbers them.
+func (f *forwarder) batch() Batch {
+	f.lock.Lock()
+	samples := f.samples
+	f.samples = nil
+	f.seq++
+	seq := f.seq
+	f.lock.Unlock()
+	return Batch{
+		Seq:        seq,
+		Samples:    samples,
+		Iterations: f.executor.GetIterations(),
+		VUs:        f.executor.GetVUs(),
+		VUsMax:     f.executor.GetVUsMax(),
+	}
+}
+
+// flush sends a batch to the coordinator, after any earlier ones it couldn't; the ones it can't
+// send are kept, as they are, for the next time. It sends nothing once the context is done, and
+// leaves what's left to the agent's final report.
+func (f *forwarder) flush(ctx context.Context) {
+	f.unsent = append(f.unsent, f.batch())
+	for len(f.unsent) > 0 && ctx.Err() == nil {
+		var ins Instructions
+		path := fmt.Sprintf("/v1/agents/%d/samples", f.id)
+		if err := f.agent.post(ctx, path, f.unsent[0], &ins); err != nil {
+			if ctx.Err() == nil {
+				f.agent.Logger.WithError(err).Warn("Couldn't send samples to the coordinator")
+			}
+			return
+		}
+		f.unsent = f.unsent[1:]
+		if ins.Paused != f.executor.IsPaused() {
+			f.executor.SetPaused(ins.Paused)
+		}
+		if ins.Stop {
+			f.stop()
+		}
+	}
+}
@@ -0,0 +1,739 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// Ensure Coordinator conforms to Executor.
+var _ lib.Executor = &Coordinator{}
+
+// StopTimeout is how long a coordinator waits for its agents to wrap up, once a test is stopped
+// early, before it gives up on them.
+var StopTimeout = 1 * time.Minute
+
+// An agent taking part in a test.
+type agent struct {
+	id   int
+	name string
+
+	iterations int64
+	vus        int64
+	vusMax     int64
+
+	// The numbers of the batches collected from the agent, and the latest one.
+	seqs    map[int64]bool
+	lastSeq int64
+}
+
+// The end of an agent's share of a test.
+type agentResult struct {
+	agent *agent
+	err   error
+}
+
+// A Coordinator is an Executor that runs a test on its agents, rather than on its own VUs: it
+// splits the test into execution segments for them, and funnels the samples they send back to the engine.
+// It runs the test's setup() and teardown() itself, and hands what setup() returned to them.
+//
+// It's also the http.Handler the agents talk to; see the package documentation.
+type Coordinator struct {
+	Runner lib.Runner
+	Logger *log.Logger
+
+	// How many agents the test is split among; it starts once they've all registered.
+	Agents int
+
+	// The token agents authenticate with; requests without it are rejected, and with no token
+	// set, all of them are.
+	Token string
+
+	runLock sync.Mutex
+
+	// Receives the error a test is aborted with; see Abort().
+	abort chan error
+
+	// Lock for everything below.
+	lock sync.RWMutex
+
+	runSetup    bool
+	runTeardown bool
+
+	stages    []lib.Stage
+	scenarios lib.Scenarios
+	endIters  null.Int
+	endTime   types.NullDuration
+	vus       int64
+	vusMax    int64
+
+	paused    bool
+	pausedAt  time.Time
+	pausedFor time.Duration
+
+	// The state of the running test, or of the last one.
+	running     bool
+	agents      []*agent
+	registered  chan struct{} // Closed once all of the agents have registered.
+	assigned    chan struct{} // Closed once their assignments are ready, or failed.
+	assignments []Assignment
+	assignErr   error
+	start       time.Time
+	end         time.Time
+	stop        bool
+	samples     chan []stats.Sample
+	results     chan agentResult
+	finished    chan struct{} // Closed once the test is over.
+	metrics     map[string]*stats.Metric
+}
+
+// NewCoordinator returns a Coordinator that runs the runner's test on as many agents.
+func NewCoordinator(r lib.Runner, agents int) *Coordinator {
+	return &Coordinator{
+		Runner:      r,
+		Logger:      log.StandardLogger(),
+		Agents:      agents,
+		abort:       make(chan error, 1),
+		runSetup:    true,
+		runTeardown: true,
+	}
+}
+
+// A Part is an agent's share of a test: an execution segment of it, and the parts of the
+// scenarios the agent runs as a result, with their start times resolved.
+type Part struct {
+	Tuple     *lib.ExecutionTuple
+	Scenarios lib.Scenarios
+}
+
+// Split divides a test among as many agents, giving each an equal execution segment of every
+// scenario, eg. 0:1/3, 1/3:2/3 and 2/3:1 for three of them. Since the segments are of the same
+// sequence, the agents' VUs and iterations don't overlap.
+func Split(scenarios lib.Scenarios, agents int) ([]Part, error) {
+	if len(scenarios) == 0 {
+		return nil, errors.New("a distributed test needs scenarios to split among its agents")
+	}
+	if agents < 1 {
+		return nil, errors.Errorf("can't split a test among %d agents", agents)
+	}
+	for name, sc := range scenarios {
+		if sc.Executor == lib.ExternallyControlledExecutor {
+			return nil, errors.Errorf("scenario %s: externally controlled scenarios can't be distributed", name)
+		}
+	}
+
+	seq := make(lib.ExecutionSegmentSequence, agents+1)
+	for i := range seq {
+		seq[i] = big.NewRat(int64(i), int64(agents))
+	}
+	parts := make([]Part, agents)
+	for i := range parts {
+		seg, err := lib.NewExecutionSegment(seq[i], seq[i+1])
+		if err != nil {
+			return nil, err
+		}
+		et, err := lib.NewExecutionTuple(seg, seq)
+		if err != nil {
+			return nil, err
+		}
+		part := scenarios.Segment(et)
+		if len(part) == 0 {
+			return nil, errors.Errorf("can't split the test among %d agents, segment %s has no part of any of its scenarios", agents, seg)
+		}
+		parts[i] = Part{Tuple: et, Scenarios: part}
+	}
+	return parts, nil
+}
+
+func (c *Coordinator) Run(ctx context.Context, out chan<- []stats.Sample) (reterr error) {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+
+	// Forget about aborts that happened outside of a test.
+	select {
+	case <-c.abort:
+	default:
+	}
+
+	c.lock.RLock()
+	parts, err := Split(c.scenarios, c.Agents)
+	c.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.running = true
+	c.agents = make([]*agent, 0, c.Agents)
+	c.registered = make(chan struct{})
+	c.assigned = make(chan struct{})
+	c.assignments, c.assignErr = nil, nil
+	c.start, c.end, c.stop = time.Time{}, time.Time{}, false
+	c.samples = make(chan []stats.Sample)
+	c.results = make(chan agentResult)
+	c.finished = make(chan struct{})
+	c.metrics = make(map[string]*stats.Metric)
+	registered, assigned, finished := c.registered, c.assigned, c.finished
+	c.lock.Unlock()
+
+	defer func() {
+		close(finished)
+		c.lock.Lock()
+		c.running = false
+		if !c.start.IsZero() {
+			c.end = time.Now()
+			if c.paused {
+				c.pausedFor += c.end.Sub(c.pausedAt)
+			}
+		}
+		c.lock.Unlock()
+	}()
+
+	c.Logger.WithField("agents", c.Agents).Info("Waiting for agents to register...")
+	select {
+	case <-registered:
+	case <-ctx.Done():
+		c.failAssignments(errors.New("the test was stopped before it started"))
+		return nil
+	}
+
+	assignments, err := c.assign(ctx, parts)
+	if err != nil {
+		c.failAssignments(err)
+		return err
+	}
+	c.lock.Lock()
+	c.assignments = assignments
+	c.start = time.Now()
+	if c.paused {
+		c.pausedAt = c.start
+	}
+	c.pausedFor = 0
+	c.lock.Unlock()
+	close(assigned)
+	c.Logger.Debug("Coordinator: Agents assigned, starting")
+
+	defer func() {
+		if c.Runner != nil && c.runTeardown {
+			teardownCtx, teardownCancel := context.WithTimeout(
+				context.Background(),
+				time.Duration(c.Runner.GetOptions().TeardownTimeout.Duration),
+			)
+			if err := c.Runner.Teardown(teardownCtx); err != nil && reterr == nil {
+				reterr = err
+			}
+			teardownCancel()
+		}
+	}()
+
+	var firstErr error
+	var timeout <-chan time.Time
+	done := ctx.Done()
+	for remaining := c.Agents; remaining > 0; {
+		select {
+		case samples := <-c.samples:
+			if out != nil {
+				out <- samples
+			}
+		case res := <-c.results:
+			remaining--
+			if res.err != nil && firstErr == nil {
+				firstErr = errors.Wrapf(res.err, "agent %s", res.agent.name)
+				c.stopAgents()
+			}
+		case err := <-c.abort:
+			c.Logger.WithError(err).Debug("Coordinator: Aborted")
+			if firstErr == nil {
+				firstErr = err
+			}
+			c.stopAgents()
+		case <-done:
+			c.Logger.Debug("Coordinator: Stopping the agents")
+			done = nil
+			timeout = time.After(StopTimeout)
+			c.stopAgents()
+		case <-timeout:
+			c.Logger.Warnf("%d agents didn't finish within %s of being stopped", remaining, StopTimeout)
+			return firstErr
+		}
+	}
+	return firstErr
+}
+
+// assign runs setup() and works out every agent's assignment.
+func (c *Coordinator) assign(ctx context.Context, parts []Part) ([]Assignment, error) {
+	if c.Runner == nil {
+		return nil, errors.New("a distributed test needs a script")
+	}
+	c.lock.RLock()
+	runSetup := c.runSetup
+	c.lock.RUnlock()
+	if runSetup {
+		setupCtx, setupCancel := context.WithTimeout(
+			ctx,
+			time.Duration(c.Runner.GetOptions().SetupTimeout.Duration),
+		)
+		err := c.Runner.Setup(setupCtx)
+		setupCancel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var archive []byte
+	if arc := c.Runner.MakeArchive(); arc != nil {
+		var buf bytes.Buffer
+		if err := arc.Write(&buf); err != nil {
+			return nil, err
+		}
+		archive = buf.Bytes()
+	}
+
+	assignments := make([]Assignment, len(parts))
+	for i, part := range parts {
+		opts := c.Runner.GetOptions()
+		opts.ExecutionSegment = *part.Tuple.Segment
+		opts.ExecutionSegmentSequence = part.Tuple.Sequence
+		opts.Scenarios = part.Scenarios
+		opts.VUs = null.Int{}
+		opts.VUsMax = null.IntFrom(part.Scenarios.InitVUs())
+		assignments[i] = Assignment{
+			ID:        i,
+			Archive:   archive,
+			Options:   opts,
+			SetupData: c.Runner.GetSetupData(),
+		}
+	}
+	return assignments, nil
+}
+
+// failAssignments lets the agents waiting for their assignments know that there won't be any.
+func (c *Coordinator) failAssignments(err error) {
+	c.lock.Lock()
+	c.assignErr = err
+	c.lock.Unlock()
+	close(c.assigned)
+}
+
+// stopAgents tells the agents to stop their shares of the test, with their next batch.
+func (c *Coordinator) stopAgents() {
+	c.lock.Lock()
+	c.stop = true
+	c.lock.Unlock()
+}
+
+// ServeHTTP handles the agents' requests.
+func (c *Coordinator) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if c.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) != 1 {
+		http.Error(rw, "missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	router := httprouter.New()
+	router.POST("/v1/agents", c.handleRegister)
+	router.POST("/v1/agents/:id/samples", c.handleBatch)
+	router.POST("/v1/agents/:id/done", c.handleDone)
+	router.ServeHTTP(rw, r)
+}
+
+func (c *Coordinator) handleRegister(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var reg Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.lock.Lock()
+	if !c.running || len(c.agents) == c.Agents {
+		c.lock.Unlock()
+		http.Error(rw, "no test is waiting for agents", http.StatusServiceUnavailable)
+		return
+	}
+	a := &agent{id: len(c.agents), name: reg.Name, seqs: make(map[int64]bool)}
+	if a.name == "" {
+		a.name = strconv.Itoa(a.id)
+	}
+	c.agents = append(c.agents, a)
+	if len(c.agents) == c.Agents {
+		close(c.registered)
+	}
+	assigned := c.assigned
+	c.lock.Unlock()
+	c.Logger.WithFields(log.Fields{"agent": a.name, "id": a.id}).Info("Agent registered")
+
+	select {
+	case <-assigned:
+	case <-r.Context().Done():
+		return
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.assignErr != nil {
+		http.Error(rw, c.assignErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, c.assignments[a.id])
+}
+
+func (c *Coordinator) handleBatch(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	a, ok := c.getAgent(rw, p)
+	if !ok {
+		return
+	}
+	var batch Batch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !c.collect(a, batch) {
+		http.Error(rw, "the test is over", http.StatusGone)
+		return
+	}
+
+	c.lock.RLock()
+	instructions := Instructions{Paused: c.paused, Stop: c.stop}
+	c.lock.RUnlock()
+	writeJSON(rw, instructions)
+}
+
+func (c *Coordinator) handleDone(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	a, ok := c.getAgent(rw, p)
+	if !ok {
+		return
+	}
+	var res Result
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, batch := range append(res.Unsent, res.Batch) {
+		if !c.collect(a, batch) {
+			http.Error(rw, "the test is over", http.StatusGone)
+			return
+		}
+	}
+
+	c.Logger.WithFields(log.Fields{"agent": a.name, "error": res.Error}).Info("Agent finished")
+	result := agentResult{agent: a}
+	if res.Error != "" {
+		result.err = errors.New(res.Error)
+	}
+	select {
+	case c.results <- result:
+		rw.WriteHeader(http.StatusNoContent)
+	case <-c.finished:
+		http.Error(rw, "the test is over", http.StatusGone)
+	}
+}
+
+// getAgent returns the agent a request is from, or responds with an error if there's no such
+// agent taking part in the running test.
+func (c *Coordinator) getAgent(rw http.ResponseWriter, p httprouter.Params) (*agent, bool) {
+	id, err := strconv.Atoi(p.ByName("id"))
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if err != nil || id < 0 || id >= len(c.agents) || !c.running || c.start.IsZero() {
+		http.Error(rw, "no such agent", http.StatusNotFound)
+		return nil, false
+	}
+	return c.agents[id], true
+}
+
+// collect records an agent's progress and hands its samples to the engine, unless the test is
+// over; it returns whether it isn't. A batch that's already been collected is ignored.
+func (c *Coordinator) collect(a *agent, batch Batch) bool {
+	c.lock.Lock()
+	if a.seqs[batch.Seq] {
+		c.lock.Unlock()
+		return true
+	}
+	a.seqs[batch.Seq] = true
+	if batch.Seq > a.lastSeq {
+		a.lastSeq = batch.Seq
+		a.iterations, a.vus, a.vusMax = batch.Iterations, batch.VUs, batch.VUsMax
+	}
+	samples := make([]stats.Sample, len(batch.Samples))
+	for i, s := range batch.Samples {
+		m, ok := c.metrics[s.Metric]
+		if !ok {
+			m = stats.New(s.Metric, s.Type, s.Contains)
+			c.metrics[s.Metric] = m
+		}
+		samples[i] = stats.Sample{Metric: m, Time: s.Time, Value: s.Value, Tags: s.Tags}
+	}
+	c.lock.Unlock()
+
+	if len(samples) == 0 {
+		return true
+	}
+	if c.Runner != nil {
+		for _, s := range samples {
+			if s.Metric.Name == "checks" {
+				countCheck(c.Runner.GetDefaultGroup(), s)
+			}
+		}
+	}
+	select {
+	case c.samples <- samples:
+		return true
+	case <-c.finished:
+		return false
+	}
+}
+
+// countCheck counts a check's result towards the group tree, going by the tags of its sample,
+// since the agents' VUs count theirs on their own.
+func countCheck(root *lib.Group, s stats.Sample) {
+	name, ok := s.Tags.Get("check")
+	if !ok {
+		return
+	}
+	group := root
+	if path, _ := s.Tags.Get("group"); path != "" {
+		for _, name := range strings.Split(path, lib.GroupSeparator)[1:] {
+			g, err := group.Group(name)
+			if err != nil {
+				return
+			}
+			group = g
+		}
+	}
+	check, err := group.Check(name)
+	if err != nil {
+		return
+	}
+	if s.Value != 0 {
+		atomic.AddInt64(&check.Passes, 1)
+	} else {
+		atomic.AddInt64(&check.Fails, 1)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (c *Coordinator) Abort(err error) {
+	select {
+	case c.abort <- err:
+	default:
+	}
+}
+
+func (c *Coordinator) IsRunning() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.running && !c.start.IsZero()
+}
+
+func (c *Coordinator) GetRunner() lib.Runner {
+	return c.Runner
+}
+
+func (c *Coordinator) SetLogger(l *log.Logger) {
+	c.Logger = l
+}
+
+func (c *Coordinator) GetLogger() *log.Logger {
+	return c.Logger
+}
+
+func (c *Coordinator) GetStages() []lib.Stage {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.stages
+}
+
+func (c *Coordinator) SetStages(s []lib.Stage) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stages = s
+}
+
+func (c *Coordinator) GetScenarios() lib.Scenarios {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.scenarios
+}
+
+func (c *Coordinator) SetScenarios(s lib.Scenarios) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.scenarios = s
+}
+
+// GetIterations returns the iterations the agents have finished so far, as of their last batch.
+func (c *Coordinator) GetIterations() int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var iters int64
+	for _, a := range c.agents {
+		iters += a.iterations
+	}
+	return iters
+}
+
+func (c *Coordinator) GetEndIterations() null.Int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.endIters
+}
+
+func (c *Coordinator) SetEndIterations(i null.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.endIters = i
+}
+
+// GetTime returns the time since the agents started, not counting pauses.
+func (c *Coordinator) GetTime() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.start.IsZero() {
+		return 0
+	}
+	if !c.end.IsZero() {
+		return c.end.Sub(c.start) - c.pausedFor
+	}
+	t := time.Since(c.start) - c.pausedFor
+	if c.paused {
+		t -= time.Since(c.pausedAt)
+	}
+	return t
+}
+
+func (c *Coordinator) GetEndTime() types.NullDuration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.endTime
+}
+
+func (c *Coordinator) SetEndTime(t types.NullDuration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.endTime = t
+}
+
+func (c *Coordinator) IsPaused() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.paused
+}
+
+// SetPaused pauses or resumes the agents, with their next batch.
+func (c *Coordinator) SetPaused(paused bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if paused == c.paused {
+		return
+	}
+	c.paused = paused
+	if !c.running || c.start.IsZero() {
+		return
+	}
+	if paused {
+		c.pausedAt = time.Now()
+	} else {
+		c.pausedFor += time.Since(c.pausedAt)
+	}
+}
+
+// GetVUs returns the agents' VUs, as of their last batch, once the test has started.
+func (c *Coordinator) GetVUs() int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if !c.running {
+		return c.vus
+	}
+	var vus int64
+	for _, a := range c.agents {
+		vus += a.vus
+	}
+	return vus
+}
+
+func (c *Coordinator) SetVUs(vus int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.running {
+		return errors.New("the VUs of a distributed test are controlled by its scenarios")
+	}
+	c.vus = vus
+	return nil
+}
+
+func (c *Coordinator) GetVUsMax() int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if !c.running {
+		return c.vusMax
+	}
+	var vusMax int64
+	for _, a := range c.agents {
+		vusMax += a.vusMax
+	}
+	return vusMax
+}
+
+func (c *Coordinator) SetVUsMax(max int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.running {
+		return errors.New("the VUs of a distributed test are controlled by its scenarios")
+	}
+	c.vusMax = max
+	return nil
+}
+
+func (c *Coordinator) SetRunSetup(r bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.runSetup = r
+}
+
+func (c *Coordinator) SetRunTeardown(r bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.runTeardown = r
+}
@@ -0,0 +1,284 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestSplit(t *testing.T) {
+	second := types.NullDurationFrom(1 * time.Second)
+	scenarios := lib.Scenarios{
+		"a": {Executor: lib.ConstantVUsExecutor, VUs: null.IntFrom(2), Duration: second},
+		"b": {Executor: lib.ConstantVUsExecutor, VUs: null.IntFrom(2), Duration: second, StartAfter: null.StringFrom("a")},
+		"c": {Executor: lib.ConstantVUsExecutor, VUs: null.IntFrom(2), Duration: second},
+	}
+
+	parts, err := Split(scenarios, 2)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	for i, part := range parts {
+		assert.Equal(t, []string{"a", "b", "c"}, part.Scenarios.Names())
+		assert.Equal(t, int64(1), part.Scenarios["a"].VUs.Int64)
+		assert.False(t, part.Scenarios["b"].StartAfter.Valid)
+		assert.Equal(t, second, part.Scenarios["b"].StartTime)
+		assert.NoError(t, part.Scenarios.Validate())
+		assert.Equal(t, []string{"0:1/2", "1/2:1"}[i], part.Tuple.Segment.String())
+		assert.Equal(t, "0,1/2,1", part.Tuple.Sequence.String())
+	}
+
+	t.Run("Uneven", func(t *testing.T) {
+		scenarios := lib.Scenarios{
+			"vus":   {Executor: lib.ConstantVUsExecutor, VUs: null.IntFrom(5), Duration: second},
+			"iters": {Executor: lib.SharedIterationsExecutor, VUs: null.IntFrom(2), Iterations: null.IntFrom(2)},
+		}
+		parts, err := Split(scenarios, 3)
+		require.NoError(t, err)
+		var vus, iters int64
+		for _, part := range parts {
+			vus += part.Scenarios["vus"].VUs.Int64
+			if sc, ok := part.Scenarios["iters"]; ok {
+				iters += sc.Iterations.Int64
+			}
+		}
+		assert.Equal(t, int64(5), vus)
+		assert.Equal(t, int64(2), iters)
+		assert.NotContains(t, parts[2].Scenarios, "iters")
+	})
+
+	_, err = Split(lib.Scenarios{"one": {Executor: lib.ConstantVUsExecutor, Duration: second}}, 2)
+	assert.EqualError(t, err, "can't split the test among 2 agents, segment 1/2:1 has no part of any of its scenarios")
+	_, err = Split(scenarios, 0)
+	assert.EqualError(t, err, "can't split a test among 0 agents")
+	_, err = Split(nil, 1)
+	assert.EqualError(t, err, "a distributed test needs scenarios to split among its agents")
+	_, err = Split(lib.Scenarios{"x": {Executor: lib.ExternallyControlledExecutor}}, 1)
+	assert.EqualError(t, err, "scenario x: externally controlled scenarios can't be distributed")
+}
+
+// testToken is what the coordinators and agents of the tests authenticate with.
+const testToken = "secret"
+
+// startAgents starts agents that run tests with the given function, and returns a channel that
+// receives their errors.
+func startAgents(
+	ctx context.Context, url string, n int, fn func(ctx context.Context) ([]stats.Sample, error),
+	setupData chan<- json.RawMessage,
+) <-chan error {
+	errC := make(chan error, n)
+	for i := 0; i < n; i++ {
+		agent := NewAgent(url, func(arc *lib.Archive) (lib.Runner, error) {
+			return &setupDataRunner{MiniRunner: lib.MiniRunner{Fn: fn}, setupData: setupData}, nil
+		})
+		agent.Token = testToken
+		agent.FlushInterval = 10 * time.Millisecond
+		go func() { errC <- agent.Run(ctx) }()
+	}
+	return errC
+}
+
+// A setupDataRunner reports the setup data it's given.
+type setupDataRunner struct {
+	lib.MiniRunner
+	setupData chan<- json.RawMessage
+}
+
+func (r *setupDataRunner) SetSetupData(data json.RawMessage) error {
+	r.setupData <- data
+	return nil
+}
+
+func TestCoordinator(t *testing.T) {
+	var teardowns int
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	runner := &lib.MiniRunner{Group: root}
+	runner.SetupFn = func(ctx context.Context) error {
+		runner.SetupData = json.RawMessage(`{"token":"abc"}`)
+		return nil
+	}
+	runner.TeardownFn = func(ctx context.Context) error {
+		teardowns++
+		return nil
+	}
+	c := NewCoordinator(runner, 2)
+	c.Token = testToken
+	c.SetScenarios(lib.Scenarios{
+		"shared": {
+			Executor:   lib.SharedIterationsExecutor,
+			Iterations: null.IntFrom(5),
+		},
+		"perVU": {
+			Executor:   lib.PerVUIterationsExecutor,
+			VUs:        null.IntFrom(2),
+			Iterations: null.IntFrom(3),
+		},
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setupData := make(chan json.RawMessage, 2)
+	errC := startAgents(ctx, srv.URL, 2, func(ctx context.Context) ([]stats.Sample, error) {
+		tags := stats.IntoSampleTags(&map[string]string{"group": "", "check": "ok"})
+		return []stats.Sample{{Metric: metrics.Checks, Time: time.Now(), Value: 1, Tags: tags}}, nil
+	}, setupData)
+
+	var lock sync.Mutex
+	var iterations, checks float64
+	out := make(chan []stats.Sample)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for samples := range out {
+			lock.Lock()
+			for _, s := range samples {
+				switch s.Metric.Name {
+				case "iterations":
+					iterations += s.Value
+				case "checks":
+					checks += s.Value
+				}
+			}
+			lock.Unlock()
+		}
+	}()
+	require.NoError(t, c.Run(ctx, out))
+	close(out)
+	<-done
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-errC)
+		assert.JSONEq(t, `{"token":"abc"}`, string(<-setupData))
+	}
+
+	assert.Equal(t, 11.0, iterations)
+	assert.Equal(t, 11.0, checks)
+	assert.Equal(t, int64(11), c.GetIterations())
+	assert.Equal(t, int64(11), root.Checks["ok"].Passes)
+	assert.Equal(t, 1, teardowns)
+	assert.False(t, c.IsRunning())
+
+	t.Run("Stop", func(t *testing.T) {
+		c := NewCoordinator(&lib.MiniRunner{}, 1)
+		c.Token = testToken
+		c.SetScenarios(lib.Scenarios{"long": {
+			Executor: lib.ConstantVUsExecutor,
+			Duration: types.NullDurationFrom(1 * time.Hour),
+		}})
+		srv := httptest.NewServer(c)
+		defer srv.Close()
+
+		errC := startAgents(context.Background(), srv.URL, 1, func(ctx context.Context) ([]stats.Sample, error) {
+			time.Sleep(1 * time.Millisecond)
+			return nil, nil
+		}, make(chan json.RawMessage, 1))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runErrC := make(chan error)
+		go func() { runErrC <- c.Run(ctx, nil) }()
+		for c.GetIterations() == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(t, c.IsRunning())
+		assert.Equal(t, int64(1), c.GetVUs())
+		cancel()
+
+		select {
+		case err := <-runErrC:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the coordinator didn't stop")
+		}
+		assert.NoError(t, <-errC)
+	})
+
+	t.Run("Resend", func(t *testing.T) {
+		c := NewCoordinator(nil, 1)
+		c.metrics = make(map[string]*stats.Metric)
+		c.samples = make(chan []stats.Sample, 3)
+		c.finished = make(chan struct{})
+		a := &agent{seqs: make(map[int64]bool)}
+		sample := Sample{Metric: "iterations", Type: stats.Counter, Value: 1}
+
+		assert.True(t, c.collect(a, Batch{Seq: 1, Samples: []Sample{sample}, Iterations: 1}))
+		assert.True(t, c.collect(a, Batch{Seq: 2, Samples: []Sample{sample}, Iterations: 2}))
+		assert.True(t, c.collect(a, Batch{Seq: 1, Samples: []Sample{sample}, Iterations: 1}))
+		assert.Len(t, c.samples, 2)
+		assert.Equal(t, int64(2), a.iterations)
+	})
+
+	t.Run("AgentError", func(t *testing.T) {
+		c := NewCoordinator(&lib.MiniRunner{}, 1)
+		c.Token = testToken
+		c.SetScenarios(lib.Scenarios{"broken": {
+			Executor: lib.ConstantVUsExecutor,
+			Duration: types.NullDurationFrom(1 * time.Hour),
+		}})
+		srv := httptest.NewServer(c)
+		defer srv.Close()
+
+		agent := NewAgent(srv.URL, func(arc *lib.Archive) (lib.Runner, error) {
+			return nil, assert.AnError
+		})
+		agent.Token = testToken
+		agentErrC := make(chan error, 1)
+		go func() { agentErrC <- agent.Run(context.Background()) }()
+
+		err := c.Run(context.Background(), nil)
+		assert.EqualError(t, err, "agent 0: "+assert.AnError.Error())
+		assert.Equal(t, assert.AnError, <-agentErrC)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		c := NewCoordinator(&lib.MiniRunner{}, 1)
+		c.Token = testToken
+		c.SetScenarios(lib.Scenarios{"one": {Executor: lib.PerVUIterationsExecutor}})
+		srv := httptest.NewServer(c)
+		defer srv.Close()
+
+		for _, token := range []string{"", "wrong"} {
+			agent := NewAgent(srv.URL, func(arc *lib.Archive) (lib.Runner, error) {
+				return nil, assert.AnError
+			})
+			agent.Token = token
+			assert.EqualError(t, agent.Run(context.Background()), "401 Unauthorized: missing or invalid token")
+		}
+
+		// Without a token of its own, a coordinator lets no one in.
+		c.Token = ""
+		agent := NewAgent(srv.URL, nil)
+		assert.EqualError(t, agent.Run(context.Background()), "401 Unauthorized: missing or invalid token")
+	})
+}
@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package distributed runs a test over several k6 instances: a coordinator hands each of its
+// agents an execution segment of the test's scenarios, and collects their samples, so that
+// thresholds and the summary cover the whole test.
+//
+// Agents talk to the coordinator over HTTP, with JSON bodies, and authenticate with the token it
+// was given, as an "Authorization: Bearer <token>" header:
+//
+//	POST /v1/agents                 registers an agent, and returns its assignment once all of
+//	                                the test's agents have registered
+//	POST /v1/agents/:id/samples     sends a batch of samples and the agent's progress, and
+//	                                returns what the agent should do, eg. pause or stop
+//	POST /v1/agents/:id/done        reports that the agent's share of the test has ended
+package distributed
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+)
+
+// An Assignment is an agent's share of a test.
+type Assignment struct {
+	// The agent's ID, which it identifies itself with from then on.
+	ID int `json:"id"`
+
+	// The test, as a tar archive, along with the options the agent runs it with; its scenarios
+	// are the ones assigned to the agent.
+	Archive []byte      `json:"archive"`
+	Options lib.Options `json:"options"`
+
+	// What the coordinator's setup() returned.
+	SetupData json.RawMessage `json:"setupData"`
+}
+
+// A Registration is an agent's request to take part in a test.
+type Registration struct {
+	// The agent's name, for the coordinator's logs; its hostname, by default.
+	Name string `json:"name"`
+}
+
+// A Sample is a stats.Sample on the wire.
+type Sample struct {
+	Metric   string            `json:"metric"`
+	Type     stats.MetricType  `json:"type"`
+	Contains stats.ValueType   `json:"contains"`
+	Time     time.Time         `json:"time"`
+	Value    float64           `json:"value"`
+	Tags     *stats.SampleTags `json:"tags"`
+}
+
+// A Batch is what an agent sends the coordinator periodically: the samples it's collected since
+// the last batch, and how far along its share of the test is.
+type Batch struct {
+	// The batch's number, counting from 1. A batch the agent sends again, because it doesn't know
+	// whether the coordinator got it, keeps its number, so it isn't counted twice.
+	Seq int64 `json:"seq"`
+
+	Samples    []Sample `json:"samples"`
+	Iterations int64    `json:"iterations"`
+	VUs        int64    `json:"vus"`
+	VUsMax     int64    `json:"vusMax"`
+}
+
+// Instructions are the coordinator's answer to a batch.
+type Instructions struct {
+	// Whether the agent should be paused.
+	Paused bool `json:"paused"`
+
+	// Whether the agent should stop its share of the test, because the test is ending early.
+	Stop bool `json:"stop"`
+}
+
+// A Result is what an agent reports once its share of the test is over.
+type Result struct {
+	Batch
+
+	// Earlier batches the agent couldn't send, or doesn't know whether the coordinator got.
+	Unsent []Batch `json:"unsent,omitempty"`
+
+	// Why the agent's share of the test failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// NewSample converts a sample for the wire.
+func NewSample(s stats.Sample) Sample {
+	return Sample{
+		Metric:   s.Metric.Name,
+		Type:     s.Metric.Type,
+		Contains: s.Metric.Contains,
+		Time:     s.Time,
+		Value:    s.Value,
+		Tags:     s.Tags,
+	}
+}
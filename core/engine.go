@@ -22,6 +22,7 @@ package core
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -30,6 +31,7 @@ import (
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/guregu/null.v3"
 )
@@ -43,6 +45,11 @@ const (
 
 	BackoffAmount = 50 * time.Millisecond
 	BackoffMax    = 10 * time.Second
+
+	// A metric that's at the time series limit forgets the series that haven't had samples for
+	// StaleTimeSeriesAfter, looking for them at most every StaleTimeSeriesFlushRate.
+	StaleTimeSeriesAfter     = 1 * time.Minute
+	StaleTimeSeriesFlushRate = 10 * time.Second
 )
 
 // The Engine is the beating heart of K6.
@@ -51,20 +58,49 @@ type Engine struct {
 
 	Executor     lib.Executor
 	Options      lib.Options
-	Collector    lib.Collector
+	Collectors   []lib.Collector
 	NoThresholds bool
 
+	// Whether there's to be no end-of-test summary; with NoThresholds too, the engine has no use
+	// for the metrics, so samples only go to the collectors.
+	NoSummary bool
+
 	logger *log.Logger
 
 	Metrics     map[string]*stats.Metric
 	MetricsLock sync.Mutex
 
+	// Every scenario's own metrics, by scenario and metric name, for the summary's breakdown.
+	ScenarioMetrics map[string]map[string]*stats.Metric
+
+	// The group_duration of every group, by group path, for the summary's group tree.
+	GroupDurations map[string]*stats.Metric
+
+	// When the warm-up of the test, and of every scenario that has one, by name, ends; samples
+	// from before then aren't added to the metrics. Zero if it hasn't started yet, or there's
+	// none, and warmupEnded is set once EndWarmup() ends them all early.
+	warmupEnd          time.Time
+	scenarioWarmupEnds map[string]time.Time
+	warmupEnded        bool
+
+	// The sinks of every time series of every metric, for GetLiveMetrics().
+	liveMetrics liveMetrics
+
+	// The time series of every metric, to keep their number under the limit.
+	timeSeries *timeSeries
+
 	// Assigned to metrics upon first received sample.
 	thresholds map[string]stats.Thresholds
 	submetrics map[string][]*stats.Submetric
 
 	// Are thresholds tainted?
 	thresholdsTainted bool
+
+	// Did a threshold abort the test?
+	thresholdsAborted bool
+
+	// Why the script aborted the test, if it did.
+	scriptAbort *lib.ScriptAbortError
 }
 
 func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
@@ -76,6 +112,12 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 		Executor: ex,
 		Options:  o,
 		Metrics:  make(map[string]*stats.Metric),
+
+		GroupDurations: make(map[string]*stats.Metric),
+
+		liveMetrics: make(liveMetrics),
+
+		timeSeries: newTimeSeries(int(o.MaxTimeSeries.Int64)),
 	}
 	e.SetLogger(log.StandardLogger())
 
@@ -87,17 +129,35 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 	}
 	ex.SetPaused(o.Paused.Bool)
 	ex.SetStages(o.Stages)
+	ex.SetScenarios(o.Scenarios)
 	ex.SetEndTime(o.Duration)
 	ex.SetEndIterations(o.Iterations)
 
-	e.thresholds = o.Thresholds
+	if len(o.Scenarios) > 0 {
+		e.ScenarioMetrics = make(map[string]map[string]*stats.Metric, len(o.Scenarios))
+		for name := range o.Scenarios {
+			e.ScenarioMetrics[name] = make(map[string]*stats.Metric)
+		}
+	}
+
+	if err := metrics.ValidateThresholds(o.Thresholds); err != nil {
+		return nil, err
+	}
+	e.thresholds = make(map[string]stats.Thresholds, len(o.Thresholds))
+	for name, ths := range o.Thresholds {
+		ths.Metrics = e.metricSink
+		e.thresholds[name] = ths
+	}
 	e.submetrics = make(map[string][]*stats.Submetric)
 	for name := range e.thresholds {
 		if !strings.Contains(name, "{") {
 			continue
 		}
 
-		parent, sm := stats.NewSubmetric(name)
+		parent, sm, err := stats.NewSubmetric(name)
+		if err != nil {
+			return nil, err
+		}
 		e.submetrics[parent] = append(e.submetrics[parent], sm)
 	}
 
@@ -119,6 +179,10 @@ func (e *Engine) Run(ctx context.Context) error {
 		}
 		e.logger.WithFields(fields).Debugf(" - stage #%d", i)
 	}
+	scenarios := e.Executor.GetScenarios()
+	for _, name := range scenarios.Names() {
+		e.logger.WithField("executor", scenarios[name].Executor).Debugf(" - scenario %s", name)
+	}
 
 	fields := make(log.Fields)
 	if endTime := e.Executor.GetEndTime(); endTime.Valid {
@@ -129,14 +193,21 @@ func (e *Engine) Run(ctx context.Context) error {
 	}
 	e.logger.WithFields(fields).Debug(" - end conditions (if any)")
 
+	e.MetricsLock.Lock()
+	if e.Options.Warmup.Duration > 0 {
+		e.warmupEnd = time.Now().Add(time.Duration(e.Options.Warmup.Duration))
+	}
+	e.MetricsLock.Unlock()
+
+	e.setRunStatus(lib.RunStatusRunning)
 	collectorwg := sync.WaitGroup{}
 	collectorctx, collectorcancel := context.WithCancel(context.Background())
-	if e.Collector != nil {
+	for _, collector := range e.Collectors {
 		collectorwg.Add(1)
-		go func() {
-			e.Collector.Run(collectorctx)
+		go func(collector lib.Collector) {
+			collector.Run(collectorctx)
 			collectorwg.Done()
-		}()
+		}(collector)
 	}
 
 	subctx, subcancel := context.WithCancel(context.Background())
@@ -170,6 +241,7 @@ func (e *Engine) Run(ctx context.Context) error {
 		subwg.Done()
 	}()
 
+	status := lib.RunStatusFinished
 	defer func() {
 		// Shut down subsystems.
 		subcancel()
@@ -190,6 +262,7 @@ func (e *Engine) Run(ctx context.Context) error {
 
 		// Emit final metrics.
 		e.emitMetrics()
+		e.warnDroppedTimeSeries()
 
 		// Process final thresholds.
 		if !e.NoThresholds {
@@ -197,6 +270,10 @@ func (e *Engine) Run(ctx context.Context) error {
 		}
 
 		// Finally, shut down collector.
+		if e.thresholdsAborted {
+			status = lib.RunStatusAbortedThreshold
+		}
+		e.setRunStatus(status)
 		collectorcancel()
 		collectorwg.Wait()
 	}()
@@ -209,12 +286,18 @@ func (e *Engine) Run(ctx context.Context) error {
 			errC = nil
 			if err != nil {
 				e.logger.WithError(err).Debug("run: executor returned an error")
+				status = lib.RunStatusAbortedSystem
+				if abort, ok := errors.Cause(err).(lib.ScriptAbortError); ok {
+					e.scriptAbort = &abort
+					status = lib.RunStatusAbortedScriptError
+				}
 				return err
 			}
 			e.logger.Debug("run: executor terminated")
 			return nil
 		case <-ctx.Done():
 			e.logger.Debug("run: context expired; exiting...")
+			status = lib.RunStatusAbortedUser
 			return nil
 		}
 	}
@@ -224,6 +307,19 @@ func (e *Engine) IsTainted() bool {
 	return e.thresholdsTainted
 }
 
+// ScriptAbortReason returns why the script aborted the test, eg. with execution.abort(), if it did.
+func (e *Engine) ScriptAbortReason() (string, bool) {
+	if e.scriptAbort == nil {
+		return "", false
+	}
+	return e.scriptAbort.Reason, true
+}
+
+// IsAborted returns whether a failed threshold with abortOnFail stopped the test early.
+func (e *Engine) IsAborted() bool {
+	return e.thresholdsAborted
+}
+
 func (e *Engine) SetLogger(l *log.Logger) {
 	e.logger = l
 	e.Executor.SetLogger(l)
@@ -281,7 +377,7 @@ func (e *Engine) processThresholds(abort func()) {
 	defer e.MetricsLock.Unlock()
 
 	t := e.Executor.GetTime()
-	abortOnFail := false
+	var abortedBy []string
 
 	e.thresholdsTainted = false
 	for _, m := range e.Metrics {
@@ -300,17 +396,138 @@ func (e *Engine) processThresholds(abort func()) {
 			e.logger.WithField("m", m.Name).Debug("Thresholds failed")
 			m.Tainted = null.BoolFrom(true)
 			e.thresholdsTainted = true
-			if !abortOnFail && m.Thresholds.Abort {
-				abortOnFail = true
+			if m.Thresholds.Abort {
+				abortedBy = append(abortedBy, m.Name)
 			}
 		}
 	}
 
-	if abortOnFail && abort != nil {
+	if len(abortedBy) > 0 && abort != nil {
+		sort.Strings(abortedBy)
+		e.logger.WithField("metrics", strings.Join(abortedBy, ", ")).
+			Warn("Thresholds with abortOnFail have failed, aborting the test")
+		e.thresholdsAborted = true
 		abort()
 	}
 }
 
+// isWarmup returns whether a sample is from the warm-up of the test, or of its scenario; the
+// first sample of a scenario starts the scenario's.
+func (e *Engine) isWarmup(sample stats.Sample) bool {
+	if e.warmupEnded {
+		return false
+	}
+	return e.isScenarioWarmup(sample) || sample.Time.Before(e.warmupEnd)
+}
+
+// isScenarioWarmup returns whether a sample is from the warm-up of its scenario.
+func (e *Engine) isScenarioWarmup(sample stats.Sample) bool {
+	name, ok := sample.Tags.Get("scenario")
+	if !ok {
+		return false
+	}
+	sc, ok := e.Options.Scenarios[name]
+	if !ok || sc.Warmup.Duration <= 0 {
+		return false
+	}
+	end, ok := e.scenarioWarmupEnds[name]
+	if !ok {
+		if e.scenarioWarmupEnds == nil {
+			e.scenarioWarmupEnds = make(map[string]time.Time)
+		}
+		end = sample.Time.Add(time.Duration(sc.Warmup.Duration))
+		e.scenarioWarmupEnds[name] = end
+	}
+	return sample.Time.Before(end)
+}
+
+// IsWarmingUp returns whether the test, or any of its scenarios that has started, is warming up.
+func (e *Engine) IsWarmingUp() bool {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	if e.warmupEnded {
+		return false
+	}
+	now := time.Now()
+	if now.Before(e.warmupEnd) {
+		return true
+	}
+	for _, end := range e.scenarioWarmupEnds {
+		if now.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// EndWarmup ends the warm-up of the test and all of its scenarios, including the ones that haven't
+// started yet; samples from then on are added to the metrics.
+func (e *Engine) EndWarmup() {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	e.warmupEnded = true
+}
+
+// limitTimeSeries drops the samples of new time series of the metrics that are at the limit, with
+// a warning the first time it does for each of them.
+func (e *Engine) limitTimeSeries(samples []stats.Sample) []stats.Sample {
+	if e.timeSeries.limit <= 0 {
+		return samples
+	}
+
+	kept := make([]stats.Sample, 0, len(samples))
+	for _, sample := range samples {
+		ok, first := e.timeSeries.add(sample)
+		if ok {
+			kept = append(kept, sample)
+			continue
+		}
+		if first {
+			e.logger.WithFields(log.Fields{"metric": sample.Metric.Name, "limit": e.timeSeries.limit}).
+				Warn("A metric has samples with too many different sets of tags, dropping the ones with new " +
+					"sets; if a tag has a different value every time, eg. a URL with an ID, consider naming the " +
+					"requests with http.url or the name tag, or leaving the tag out with systemTags")
+		}
+	}
+	return kept
+}
+
+// warnDroppedTimeSeries tells how many samples each metric had dropped for being over the time
+// series limit, after the test.
+func (e *Engine) warnDroppedTimeSeries() {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	names := make([]string, 0, len(e.timeSeries.dropped))
+	for name := range e.timeSeries.dropped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e.logger.WithFields(log.Fields{"metric": name, "samples": e.timeSeries.dropped[name]}).
+			Warn("Samples were dropped for being over the time series limit")
+	}
+}
+
+// metricSink returns the sink of a metric for thresholds that refer to it, or nil if the metric
+// has had no samples; it's called with MetricsLock held.
+func (e *Engine) metricSink(name string) stats.Sink {
+	if m, ok := e.Metrics[name]; ok {
+		return m.Sink
+	}
+	return nil
+}
+
+// setRunStatus tells the collectors that want to know about it what the status of the run is.
+func (e *Engine) setRunStatus(status lib.RunStatus) {
+	for _, collector := range e.Collectors {
+		if updater, ok := collector.(lib.RunStatusUpdater); ok {
+			updater.SetRunStatus(status)
+		}
+	}
+}
+
 func (e *Engine) processSamples(samples ...stats.Sample) {
 	if len(samples) == 0 {
 		return
@@ -319,7 +536,19 @@ func (e *Engine) processSamples(samples ...stats.Sample) {
 	e.MetricsLock.Lock()
 	defer e.MetricsLock.Unlock()
 
+	samples = e.limitTimeSeries(samples)
+	if e.NoThresholds && e.NoSummary {
+		for _, collector := range e.Collectors {
+			collector.Collect(samples)
+		}
+		return
+	}
 	for _, sample := range samples {
+		// Samples from warming up only go to the collectors.
+		if e.isWarmup(sample) {
+			continue
+		}
+
 		m, ok := e.Metrics[sample.Metric.Name]
 		if !ok {
 			m = stats.New(sample.Metric.Name, sample.Metric.Type, sample.Metric.Contains)
@@ -328,9 +557,10 @@ func (e *Engine) processSamples(samples ...stats.Sample) {
 			e.Metrics[m.Name] = m
 		}
 		m.Sink.Add(sample)
+		e.liveMetrics.add(sample)
 
 		for _, sm := range m.Submetrics {
-			if !sm.Tags.IsEqual(sample.Tags) {
+			if !sm.Matches(sample.Tags) {
 				continue
 			}
 
@@ -342,8 +572,28 @@ func (e *Engine) processSamples(samples ...stats.Sample) {
 			}
 			sm.Metric.Sink.Add(sample)
 		}
+
+		if name, ok := sample.Tags.Get("scenario"); ok && e.ScenarioMetrics[name] != nil {
+			scm, ok := e.ScenarioMetrics[name][m.Name]
+			if !ok {
+				scm = stats.New(m.Name, m.Type, m.Contains)
+				e.ScenarioMetrics[name][m.Name] = scm
+			}
+			scm.Sink.Add(sample)
+		}
+
+		if m.Name == metrics.GroupDuration.Name {
+			if path, ok := sample.Tags.Get("group"); ok {
+				gd, ok := e.GroupDurations[path]
+				if !ok {
+					gd = stats.New(m.Name, m.Type, m.Contains)
+					e.GroupDurations[path] = gd
+				}
+				gd.Sink.Add(sample)
+			}
+		}
 	}
-	if e.Collector != nil {
-		e.Collector.Collect(samples)
+	for _, collector := range e.Collectors {
+		collector.Collect(samples)
 	}
 }
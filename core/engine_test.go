@@ -77,6 +77,29 @@ func TestNewEngine(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestNewEngineThresholds(t *testing.T) {
+	testdata := map[string]string{
+		"http_req_duration":             "",
+		"http_req_duration{status:200}": "",
+		"http_reqs":                     "invalid thresholds on http_reqs: threshold 'p(95)<100' uses p(), which is only available on trend metrics, not on counter metrics",
+		"checks{group:::a}":             "invalid thresholds on checks{group:::a}: threshold 'p(95)<100' uses p(), which is only available on trend metrics, not on rate metrics",
+		"vus":                           "invalid thresholds on vus: threshold 'p(95)<100' uses p(), which is only available on trend metrics, not on gauge metrics",
+		"my_custom_metric":              "",
+	}
+	for name, expected := range testdata {
+		t.Run(name, func(t *testing.T) {
+			ths, err := stats.NewThresholds([]string{"p(95)<100"})
+			require.NoError(t, err)
+			_, err, _ = newTestEngine(nil, lib.Options{Thresholds: map[string]stats.Thresholds{name: ths}})
+			if expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, expected)
+			}
+		})
+	}
+}
+
 func TestNewEngineOptions(t *testing.T) {
 	t.Run("Duration", func(t *testing.T) {
 		e, err, _ := newTestEngine(nil, lib.Options{
@@ -217,6 +240,14 @@ func TestNewEngineOptions(t *testing.T) {
 			assert.Contains(t, e.thresholds, "my_metric{tag:value}")
 			assert.Contains(t, e.submetrics, "my_metric")
 		})
+		t.Run("invalid submetric", func(t *testing.T) {
+			_, err, _ := newTestEngine(nil, lib.Options{
+				Thresholds: map[string]stats.Thresholds{
+					`my_metric{tag:"value}`: {},
+				},
+			})
+			assert.EqualError(t, err, `invalid submetric my_metric{tag:"value}: unterminated quote in "tag:\"value"`)
+		})
 	})
 }
 
@@ -275,7 +306,7 @@ func TestEngineRun(t *testing.T) {
 		}
 
 		c := &dummy.Collector{}
-		e.Collector = c
+		e.Collectors = []lib.Collector{c}
 
 		ctx, cancel := context.WithCancel(context.Background())
 		errC := make(chan error)
@@ -314,7 +345,7 @@ func TestEngineCollector(t *testing.T) {
 	assert.NoError(t, err)
 
 	c := &dummy.Collector{}
-	e.Collector = c
+	e.Collectors = []lib.Collector{c}
 
 	assert.NoError(t, e.Run(context.Background()))
 
@@ -335,6 +366,75 @@ func TestEngineCollector(t *testing.T) {
 	}
 }
 
+func TestEngineCollectors(t *testing.T) {
+	testMetric := stats.New("test_metric", stats.Trend)
+
+	e, err, _ := newTestEngine(LF(func(ctx context.Context) ([]stats.Sample, error) {
+		return []stats.Sample{{Metric: testMetric}}, nil
+	}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1)})
+	assert.NoError(t, err)
+
+	c1, c2 := &dummy.Collector{}, &dummy.Collector{}
+	e.Collectors = []lib.Collector{c1, c2}
+
+	assert.NoError(t, e.Run(context.Background()))
+
+	assert.NotEmpty(t, c1.Samples)
+	assert.Equal(t, c1.Samples, c2.Samples)
+}
+
+type statusCollector struct {
+	dummy.Collector
+	statuses []lib.RunStatus
+}
+
+func (c *statusCollector) SetRunStatus(status lib.RunStatus) {
+	c.statuses = append(c.statuses, status)
+}
+
+func TestEngineRunStatus(t *testing.T) {
+	t.Run("finished", func(t *testing.T) {
+		e, err, _ := newTestEngine(LF(func(ctx context.Context) ([]stats.Sample, error) {
+			return nil, nil
+		}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1)})
+		require.NoError(t, err)
+		c := &statusCollector{}
+		e.Collectors = []lib.Collector{c}
+
+		assert.NoError(t, e.Run(context.Background()))
+		assert.Equal(t, []lib.RunStatus{lib.RunStatusRunning, lib.RunStatusFinished}, c.statuses)
+	})
+	t.Run("aborted", func(t *testing.T) {
+		e, err, _ := newTestEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		c := &statusCollector{}
+		e.Collectors = []lib.Collector{c}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.NoError(t, e.Run(ctx))
+		assert.Equal(t, []lib.RunStatus{lib.RunStatusRunning, lib.RunStatusAbortedUser}, c.statuses)
+		_, aborted := e.ScriptAbortReason()
+		assert.False(t, aborted)
+	})
+	t.Run("script aborted", func(t *testing.T) {
+		e, err, _ := newTestEngine(LF(func(ctx context.Context) ([]stats.Sample, error) {
+			abort := lib.ScriptAbortError{Reason: "smoke check failed"}
+			lib.GetExecutor(ctx).Abort(abort)
+			return nil, abort
+		}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Duration: types.NullDurationFrom(10 * time.Second)})
+		require.NoError(t, err)
+		c := &statusCollector{}
+		e.Collectors = []lib.Collector{c}
+
+		assert.EqualError(t, e.Run(context.Background()), "test aborted: smoke check failed")
+		assert.Equal(t, []lib.RunStatus{lib.RunStatusRunning, lib.RunStatusAbortedScriptError}, c.statuses)
+		reason, aborted := e.ScriptAbortReason()
+		assert.True(t, aborted)
+		assert.Equal(t, "smoke check failed", reason)
+	})
+}
+
 func TestEngine_processSamples(t *testing.T) {
 	metric := stats.New("my_metric", stats.Gauge)
 
@@ -370,6 +470,143 @@ func TestEngine_processSamples(t *testing.T) {
 
 		assert.IsType(t, &stats.GaugeSink{}, e.Metrics["my_metric"].Sink)
 		assert.IsType(t, &stats.GaugeSink{}, e.Metrics["my_metric{a:1}"].Sink)
+
+		// Samples with more tags than the submetric's count towards it too.
+		e.processSamples(
+			stats.Sample{Metric: metric, Value: 2.5, Tags: stats.IntoSampleTags(&map[string]string{"a": "1", "b": "2"})},
+			stats.Sample{Metric: metric, Value: 5, Tags: stats.IntoSampleTags(&map[string]string{"b": "2"})},
+		)
+		assert.Equal(t, 2.5, e.Metrics["my_metric{a:1}"].Sink.(*stats.GaugeSink).Value)
+
+		t.Run("excluded", func(t *testing.T) {
+			e, err, _ := newTestEngine(nil, lib.Options{
+				Thresholds: map[string]stats.Thresholds{
+					`my_metric{a:1,b!="2"}`: ths,
+				},
+			})
+			assert.NoError(t, err)
+
+			e.processSamples(
+				stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"})},
+				stats.Sample{Metric: metric, Value: 2.5, Tags: stats.IntoSampleTags(&map[string]string{"a": "1", "b": "2"})},
+			)
+			assert.Equal(t, 1.25, e.Metrics[`my_metric{a:1,b!="2"}`].Sink.(*stats.GaugeSink).Value)
+		})
+	})
+	t.Run("scenario", func(t *testing.T) {
+		e, err, _ := newTestEngine(nil, lib.Options{
+			Scenarios: lib.Scenarios{
+				"checkout": {Executor: lib.ConstantVUsExecutor, Duration: types.NullDurationFrom(time.Second)},
+				"browse":   {Executor: lib.ConstantVUsExecutor, Duration: types.NullDurationFrom(time.Second)},
+			},
+		})
+		assert.NoError(t, err)
+
+		e.processSamples(
+			stats.Sample{Metric: metric, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"scenario": "checkout"})},
+			stats.Sample{Metric: metric, Value: 2, Tags: stats.IntoSampleTags(&map[string]string{"scenario": "unknown"})},
+			stats.Sample{Metric: metric, Value: 3},
+		)
+
+		assert.Equal(t, 3.0, e.Metrics["my_metric"].Sink.(*stats.GaugeSink).Value)
+		assert.Len(t, e.ScenarioMetrics, 2)
+		assert.Empty(t, e.ScenarioMetrics["browse"])
+		if assert.Contains(t, e.ScenarioMetrics["checkout"], "my_metric") {
+			assert.Equal(t, 1.0, e.ScenarioMetrics["checkout"]["my_metric"].Sink.(*stats.GaugeSink).Value)
+		}
+	})
+	t.Run("warmup", func(t *testing.T) {
+		e, err, _ := newTestEngine(nil, lib.Options{
+			Scenarios: lib.Scenarios{
+				"checkout": {
+					Executor: lib.ConstantVUsExecutor,
+					Duration: types.NullDurationFrom(time.Minute),
+					Warmup:   types.NullDurationFrom(10 * time.Second),
+				},
+				"browse": {Executor: lib.ConstantVUsExecutor, Duration: types.NullDurationFrom(time.Minute)},
+			},
+		})
+		assert.NoError(t, err)
+		c := &dummy.Collector{}
+		e.Collectors = []lib.Collector{c}
+
+		start := time.Now()
+		e.warmupEnd = start.Add(5 * time.Second)
+		sample := func(scenario string, d time.Duration, v float64) stats.Sample {
+			return stats.Sample{
+				Metric: metric, Time: start.Add(d), Value: v,
+				Tags: stats.IntoSampleTags(&map[string]string{"scenario": scenario}),
+			}
+		}
+
+		// The test's warm-up is over at 5s, the checkout scenario's 10s after its first sample.
+		e.processSamples(sample("browse", time.Second, 1), sample("checkout", 2*time.Second, 2))
+		assert.NotContains(t, e.Metrics, "my_metric")
+		assert.True(t, e.IsWarmingUp())
+		e.processSamples(sample("browse", 6*time.Second, 3), sample("checkout", 7*time.Second, 4))
+		assert.Equal(t, 3.0, e.Metrics["my_metric"].Sink.(*stats.GaugeSink).Value)
+		assert.Empty(t, e.ScenarioMetrics["checkout"])
+		e.processSamples(sample("checkout", 12*time.Second, 5))
+		assert.Equal(t, 5.0, e.Metrics["my_metric"].Sink.(*stats.GaugeSink).Value)
+		assert.Len(t, c.Samples, 5)
+
+		e.scenarioWarmupEnds["checkout"] = time.Now().Add(time.Minute)
+		assert.True(t, e.IsWarmingUp())
+		e.EndWarmup()
+		assert.False(t, e.IsWarmingUp())
+		e.processSamples(sample("checkout", 13*time.Second, 6))
+		assert.Equal(t, 6.0, e.Metrics["my_metric"].Sink.(*stats.GaugeSink).Value)
+	})
+	t.Run("no thresholds or summary", func(t *testing.T) {
+		e, err, _ := newTestEngine(nil, lib.Options{})
+		assert.NoError(t, err)
+		e.NoThresholds = true
+		e.NoSummary = true
+		c := &dummy.Collector{}
+		e.Collectors = []lib.Collector{c}
+
+		e.processSamples(stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"})})
+		assert.Empty(t, e.Metrics)
+		assert.Len(t, c.Samples, 1)
+	})
+	t.Run("time series limit", func(t *testing.T) {
+		e, err, hook := newTestEngine(nil, lib.Options{MaxTimeSeries: null.IntFrom(2)})
+		assert.NoError(t, err)
+		c := &dummy.Collector{}
+		e.Collectors = []lib.Collector{c}
+
+		sample := func(url string) stats.Sample {
+			return stats.Sample{Metric: metric, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"url": url})}
+		}
+		e.processSamples(sample("/1"), sample("/2"), sample("/3"), sample("/1"))
+		e.processSamples(sample("/4"))
+
+		assert.Equal(t, 1.0, e.Metrics["my_metric"].Sink.(*stats.GaugeSink).Value)
+		assert.Len(t, c.Samples, 3)
+		if assert.Len(t, hook.Entries, 1) {
+			assert.Equal(t, "my_metric", hook.Entries[0].Data["metric"])
+		}
+		assert.Equal(t, map[string]int64{"my_metric": 2}, e.timeSeries.dropped)
+	})
+	t.Run("group_duration", func(t *testing.T) {
+		e, err, _ := newTestEngine(nil, lib.Options{})
+		assert.NoError(t, err)
+
+		e.processSamples(
+			stats.Sample{Metric: metrics.GroupDuration, Value: 10, Tags: stats.IntoSampleTags(&map[string]string{"group": "::login"})},
+			stats.Sample{Metric: metrics.GroupDuration, Value: 30, Tags: stats.IntoSampleTags(&map[string]string{"group": "::login"})},
+			stats.Sample{Metric: metrics.GroupDuration, Value: 5, Tags: stats.IntoSampleTags(&map[string]string{"group": "::login::form"})},
+			stats.Sample{Metric: metrics.GroupDuration, Value: 7},
+			stats.Sample{Metric: metric, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"group": "::login"})},
+		)
+
+		assert.Equal(t, uint64(4), e.Metrics["group_duration"].Sink.(*stats.TrendSink).Count)
+		assert.Len(t, e.GroupDurations, 2)
+		if assert.Contains(t, e.GroupDurations, "::login") {
+			sink := e.GroupDurations["::login"].Sink.(*stats.TrendSink)
+			assert.Equal(t, uint64(2), sink.Count)
+			assert.Equal(t, 40.0, sink.Sum)
+		}
 	})
 }
 
@@ -401,6 +638,7 @@ func TestEngine_runThresholds(t *testing.T) {
 		e.runThresholds(ctx, cancelFunc)
 
 		assert.True(t, aborted)
+		assert.True(t, e.IsAborted())
 	})
 
 	t.Run("canceled", func(t *testing.T) {
@@ -447,6 +685,12 @@ func TestEngine_processThresholds(t *testing.T) {
 		"submetric,match,failing":   {false, map[string][]string{"my_metric{a:1}": {"1+1==3"}}, false},
 		"submetric,nomatch,passing": {true, map[string][]string{"my_metric{a:2}": {"1+1==2"}}, false},
 		"submetric,nomatch,failing": {true, map[string][]string{"my_metric{a:2}": {"1+1==3"}}, false},
+
+		"compound,passing":       {true, map[string][]string{"my_metric": {"value > 1 && value < 2"}}, false},
+		"compound,failing":       {false, map[string][]string{"my_metric": {"value > 1 && value < 1.2"}}, false},
+		"other metric,passing":   {true, map[string][]string{"my_metric{a:1}": {"value == metric('my_metric').value"}}, false},
+		"other metric,failing":   {false, map[string][]string{"my_metric{a:1}": {"value < metric('my_metric').value / 2"}}, false},
+		"other metric,no sample": {true, map[string][]string{"my_metric": {"value < metric('nope').value"}}, false},
 	}
 
 	for name, data := range testdata {
@@ -563,7 +807,7 @@ func TestSentReceivedMetrics(t *testing.T) {
 		require.NoError(t, err)
 
 		collector := &dummy.Collector{}
-		engine.Collector = collector
+		engine.Collectors = []lib.Collector{collector}
 
 		ctx, cancel := context.WithCancel(context.Background())
 		errC := make(chan error)
@@ -705,7 +949,7 @@ func TestRunTags(t *testing.T) {
 	require.NoError(t, err)
 
 	collector := &dummy.Collector{}
-	engine.Collector = collector
+	engine.Collectors = []lib.Collector{collector}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	errC := make(chan error)
@@ -0,0 +1,190 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"sort"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"gopkg.in/guregu/null.v3"
+)
+
+// LiveMetricsWindow is how far back the engine keeps track of what every time series of every
+// metric has had, for the REST API to tell what's been happening in a running test lately.
+const LiveMetricsWindow = 30 * time.Second
+
+// liveSeconds is how many whole seconds every series keeps, including the current one.
+const liveSeconds = int64(LiveMetricsWindow/time.Second) + 1
+
+// A LiveMetric is what the samples of a metric, or the ones of it with some tags, have added up
+// to over the whole test, and over the last window of time.
+type LiveMetric struct {
+	Name     string
+	Type     stats.MetricType
+	Contains stats.ValueType
+	Tainted  null.Bool
+
+	Sample map[string]float64
+	Recent map[string]float64
+}
+
+// liveMetrics keeps the sinks of every time series of every metric, by metric name and tag set, so
+// that metrics can be broken down by any tags while the test runs. Trends count their values in
+// histograms, which take much less memory than the values themselves.
+type liveMetrics map[string]map[string]*liveSeries
+
+// A liveSeries is what a time series has had in total, and in each of the last few seconds.
+type liveSeries struct {
+	tags    *stats.SampleTags
+	total   stats.Sink
+	seconds [liveSeconds]liveSecond
+}
+
+// A liveSecond is the sink of the samples of a series in one second, by Unix time.
+type liveSecond struct {
+	at   int64
+	sink stats.Sink
+}
+
+func newLiveSink(typ stats.MetricType) stats.Sink {
+	if typ == stats.Trend {
+		return stats.NewApproxTrendSink()
+	}
+	return stats.NewSink(typ)
+}
+
+func (lm liveMetrics) add(sample stats.Sample) {
+	series := lm[sample.Metric.Name]
+	if series == nil {
+		series = make(map[string]*liveSeries)
+		lm[sample.Metric.Name] = series
+	}
+	data, _ := sample.Tags.MarshalJSON()
+	s, ok := series[string(data)]
+	if !ok {
+		s = &liveSeries{tags: sample.Tags, total: newLiveSink(sample.Metric.Type)}
+		series[string(data)] = s
+	}
+	s.total.Add(sample)
+
+	at := sample.Time.Unix()
+	sec := &s.seconds[(at%liveSeconds+liveSeconds)%liveSeconds] // Zero times are before 1970.
+	if sec.sink == nil || sec.at != at {
+		sec.at = at
+		sec.sink = newLiveSink(sample.Metric.Type)
+	}
+	sec.sink.Add(sample)
+}
+
+// sum adds up the series of a metric that match a filter, if there is one, in total and over the
+// whole seconds of a window before now; total is nil if no series matches, recent if none of them
+// has had samples in the window.
+func (lm liveMetrics) sum(
+	name string, typ stats.MetricType, filter *stats.Submetric, window time.Duration, now time.Time,
+) (total, recent stats.Sink) {
+	from, to := now.Add(-window).Unix(), now.Unix()
+	for _, s := range lm[name] {
+		if filter != nil && !filter.Matches(s.tags) {
+			continue
+		}
+		if total == nil {
+			total = newLiveSink(typ)
+		}
+		_ = stats.MergeSink(total, s.total)
+		for _, sec := range s.seconds {
+			if sec.sink == nil || sec.at < from || sec.at >= to {
+				continue
+			}
+			if recent == nil {
+				recent = newLiveSink(typ)
+			}
+			_ = stats.MergeSink(recent, sec.sink)
+		}
+	}
+	return total, recent
+}
+
+// formatRecent formats what a live metric has had in a window, or nil if it had no samples.
+func formatRecent(recent stats.Sink, window time.Duration) map[string]float64 {
+	if recent == nil {
+		return nil
+	}
+	return recent.Format(window)
+}
+
+// GetLiveMetrics returns what every metric has had over the whole test, and over a window of time
+// before now, in whole seconds up to LiveMetricsWindow; Recent is nil for the ones that had no samples in it. With a filter, only the samples with its tags count, and
+// only the metrics that have had any are returned; otherwise, the submetrics of thresholds are
+// returned too, and the totals are the same as the summary's.
+func (e *Engine) GetLiveMetrics(filter *stats.Submetric, window time.Duration) []LiveMetric {
+	if window > LiveMetricsWindow {
+		window = LiveMetricsWindow
+	}
+	if window < time.Second {
+		window = time.Second
+	}
+	window = window.Truncate(time.Second)
+	t := e.Executor.GetTime()
+	now := time.Now()
+
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	var metrics []LiveMetric
+	if filter == nil {
+		metrics = make([]LiveMetric, 0, len(e.Metrics))
+		for _, m := range e.Metrics {
+			name, sub := m.Name, (*stats.Submetric)(nil)
+			if m.Sub.Parent != "" {
+				name, sub = m.Sub.Parent, &m.Sub
+			}
+			_, recent := e.liveMetrics.sum(name, m.Type, sub, window, now)
+			metrics = append(metrics, LiveMetric{
+				Name:     m.Name,
+				Type:     m.Type,
+				Contains: m.Contains,
+				Tainted:  m.Tainted,
+				Sample:   m.Sink.Format(t),
+				Recent:   formatRecent(recent, window),
+			})
+		}
+	} else {
+		for _, m := range e.Metrics {
+			if m.Sub.Parent != "" {
+				continue
+			}
+			total, recent := e.liveMetrics.sum(m.Name, m.Type, filter, window, now)
+			if total == nil {
+				continue
+			}
+			metrics = append(metrics, LiveMetric{
+				Name:     m.Name,
+				Type:     m.Type,
+				Contains: m.Contains,
+				Sample:   total.Format(t),
+				Recent:   formatRecent(recent, window),
+			})
+		}
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
@@ -0,0 +1,104 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLiveMetrics(t *testing.T) {
+	counter := stats.New("my_counter", stats.Counter)
+	trend := stats.New("my_trend", stats.Trend)
+	now := time.Now()
+	sample := func(m *stats.Metric, status string, ago time.Duration, v float64) stats.Sample {
+		return stats.Sample{
+			Metric: m,
+			Time:   now.Add(-ago),
+			Tags:   stats.IntoSampleTags(&map[string]string{"status": status}),
+			Value:  v,
+		}
+	}
+
+	ths, err := stats.NewThresholds([]string{`count>0`})
+	require.NoError(t, err)
+	e, err, _ := newTestEngine(nil, lib.Options{
+		Thresholds: map[string]stats.Thresholds{"my_counter{status:500}": ths},
+	})
+	require.NoError(t, err)
+	e.processSamples(
+		sample(counter, "200", 25*time.Second, 1),
+		sample(counter, "200", 3*time.Second, 2),
+		sample(counter, "500", 2*time.Second, 4),
+		sample(trend, "200", 25*time.Second, 10),
+		sample(trend, "500", 2*time.Second, 20),
+	)
+
+	byName := func(metrics []LiveMetric) map[string]LiveMetric {
+		res := make(map[string]LiveMetric, len(metrics))
+		for _, m := range metrics {
+			res[m.Name] = m
+		}
+		return res
+	}
+
+	t.Run("all", func(t *testing.T) {
+		metrics := byName(e.GetLiveMetrics(nil, 10*time.Second))
+		if !assert.Len(t, metrics, 3) {
+			return
+		}
+		assert.Equal(t, 7.0, metrics["my_counter"].Sample["count"])
+		assert.Equal(t, 6.0, metrics["my_counter"].Recent["count"])
+		assert.InDelta(t, 0.6, metrics["my_counter"].Recent["rate"], 0.0001)
+		assert.Equal(t, 4.0, metrics["my_counter{status:500}"].Sample["count"])
+		assert.Equal(t, 4.0, metrics["my_counter{status:500}"].Recent["count"])
+		assert.Equal(t, 15.0, metrics["my_trend"].Sample["avg"])
+		assert.Equal(t, 20.0, metrics["my_trend"].Recent["avg"])
+
+		metrics = byName(e.GetLiveMetrics(nil, LiveMetricsWindow+time.Hour))
+		assert.Equal(t, 7.0, metrics["my_counter"].Recent["count"])
+
+		metrics = byName(e.GetLiveMetrics(nil, time.Second))
+		assert.Nil(t, metrics["my_counter"].Recent)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		_, filter, err := stats.NewSubmetric("{status:200}")
+		require.NoError(t, err)
+		metrics := byName(e.GetLiveMetrics(filter, 10*time.Second))
+		if !assert.Len(t, metrics, 2) {
+			return
+		}
+		assert.Equal(t, 3.0, metrics["my_counter"].Sample["count"])
+		assert.Equal(t, 2.0, metrics["my_counter"].Recent["count"])
+		assert.Equal(t, 10.0, metrics["my_trend"].Sample["avg"])
+		assert.Nil(t, metrics["my_trend"].Recent)
+
+		_, filter, err = stats.NewSubmetric("{status:404}")
+		require.NoError(t, err)
+		assert.Empty(t, e.GetLiveMetrics(filter, 10*time.Second))
+	})
+}
@@ -23,6 +23,7 @@ package local
 import (
 	"context"
 	"fmt"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,36 +34,74 @@ import (
 	"github.com/loadimpact/k6/stats"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 	null "gopkg.in/guregu/null.v3"
 )
 
 var _ lib.Executor = &Executor{}
+var _ lib.ScenarioProgressReporter = &Executor{}
 
 type vuHandle struct {
 	sync.RWMutex
 	vu     lib.VU
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	// Closed to have the VU stop once it's done with its iteration, and once it's stopped.
+	stop chan struct{}
+	done chan struct{}
+
+	// The scenario the VU is set aside for, if the test has scenarios.
+	scenario *scenarioRun
 }
 
-func (h *vuHandle) run(logger *log.Logger, flow <-chan int64, out chan<- []stats.Sample) {
-	h.RLock()
-	ctx := h.ctx
-	h.RUnlock()
+func (h *vuHandle) run(
+	logger *log.Logger, ctx context.Context, stop <-chan struct{}, flow <-chan int64, out chan<- []stats.Sample,
+	iterationLimit *rate.Limiter,
+) {
+	limits := []*rate.Limiter{iterationLimit}
+	if h.scenario != nil {
+		limits = append(limits, h.scenario.iterationLimit)
+	}
 
-	for {
+	for n := int64(0); ; n++ {
+		if h.scenario != nil && h.scenario.perVU > 0 && n >= h.scenario.perVU {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		default:
+		}
+
+		var iter int64
 		select {
-		case _, ok := <-flow:
+		case i, ok := <-flow:
 			if !ok {
 				return
 			}
+			iter = i
 		case <-ctx.Done():
 			return
+		case <-stop:
+			return
+		}
+
+		// Iterations are paced when they're started, so a VU that's been handed one may wait.
+		if !waitForLimits(ctx, stop, limits...) {
+			return
 		}
 
 		var samples []stats.Sample
 		if h.vu != nil {
-			s, err := h.vu.RunOnce(ctx)
+			iterCtx := lib.WithIteration(ctx, iter)
+			if h.scenario != nil {
+				iterCtx = lib.WithScenario(iterCtx, h.scenario.state)
+			}
+			s, err := h.vu.RunOnce(iterCtx)
 			if err != nil {
 				select {
 				case <-ctx.Done():
@@ -76,10 +115,36 @@ func (h *vuHandle) run(logger *log.Logger, flow <-chan int64, out chan<- []stats
 			}
 			samples = s
 		}
+		if h.scenario != nil {
+			atomic.AddInt64(&h.scenario.completed, 1)
+			samples = append(samples, stats.Sample{
+				Time:   time.Now(),
+				Metric: metrics.Iterations,
+				Value:  1,
+				Tags:   h.scenario.tags,
+			})
+		}
 		out <- samples
 	}
 }
 
+// isRunning returns whether the VU is still running, which it may be for a while after it's been
+// stopped gracefully.
+func (h *vuHandle) isRunning() bool {
+	h.RLock()
+	done := h.done
+	h.RUnlock()
+	if done == nil {
+		return false
+	}
+	select {
+	case <-done:
+		return false
+	default:
+		return true
+	}
+}
+
 type Executor struct {
 	Runner lib.Runner
 	Logger *log.Logger
@@ -106,7 +171,14 @@ type Executor struct {
 	pauseLock sync.RWMutex
 	pause     chan interface{}
 
-	stages []lib.Stage
+	stages    []lib.Stage
+	scenarios lib.Scenarios
+
+	// The scenarios of the running test, if it has any; guarded by vusLock.
+	runs []*scenarioRun
+
+	// Receives the error a test is aborted with; see Abort().
+	abort chan error
 
 	// Lock for: ctx, flow, out
 	lock sync.RWMutex
@@ -119,6 +191,9 @@ type Executor struct {
 
 	// Flow control for VUs; iterations are run only after reading from this channel.
 	flow chan int64
+
+	// Paces the iterations of all VUs, if the iterationRate option is set.
+	iterationLimit *rate.Limiter
 }
 
 func New(r lib.Runner) *Executor {
@@ -129,6 +204,7 @@ func New(r lib.Runner) *Executor {
 		runTeardown: true,
 		endIters:    -1,
 		endTime:     -1,
+		abort:       make(chan error, 1),
 	}
 }
 
@@ -136,6 +212,15 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 	e.runLock.Lock()
 	defer e.runLock.Unlock()
 
+	// Let everything running in the test see its progress, and abort it.
+	parent = lib.WithExecutor(parent, e)
+
+	// Forget about aborts that happened outside of a test.
+	select {
+	case <-e.abort:
+	default:
+	}
+
 	if e.Runner != nil && e.runSetup {
 		setupCtx, setupCancel := context.WithTimeout(
 			parent,
@@ -143,6 +228,12 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 		)
 		if err := e.Runner.Setup(setupCtx); err != nil {
 			setupCancel()
+			// Aborting throws, so setup() fails with the exception; the test fails with the abort.
+			select {
+			case abortErr := <-e.abort:
+				return abortErr
+			default:
+			}
 			return err
 		}
 		setupCancel()
@@ -151,21 +242,39 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 	ctx, cancel := context.WithCancel(parent)
 	vuOut := make(chan []stats.Sample)
 	vuFlow := make(chan int64)
+	var iterationLimit *rate.Limiter
+	if e.Runner != nil {
+		if ir := e.Runner.GetOptions().IterationRate; ir.Valid && ir.Int64 > 0 {
+			iterationLimit = rate.NewLimiter(rate.Limit(ir.Int64), 1)
+		}
+	}
 
 	e.lock.Lock()
 	e.ctx = ctx
 	e.out = vuOut
 	e.flow = vuFlow
+	e.iterationLimit = iterationLimit
 	e.lock.Unlock()
 
 	var cutoff time.Time
+	var scenarios []*scenarioRun
 	defer func() {
+		// Scenarios' own teardowns come before the test's.
+		e.tearDownScenarios(scenarios)
 		if e.Runner != nil && e.runTeardown {
 			teardownCtx, teardownCancel := context.WithTimeout(
 				parent,
 				time.Duration(e.Runner.GetOptions().TeardownTimeout.Duration),
 			)
-			reterr = e.Runner.Teardown(teardownCtx)
+			if err := e.Runner.Teardown(teardownCtx); err != nil || reterr == nil {
+				reterr = err
+			}
+			// Like in setup(), an abort in teardown() takes precedence over its exception.
+			select {
+			case abortErr := <-e.abort:
+				reterr = abortErr
+			default:
+			}
 			teardownCancel()
 		}
 
@@ -176,6 +285,7 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 		e.ctx = nil
 		e.out = nil
 		e.flow = nil
+		e.iterationLimit = nil
 		e.lock.Unlock()
 
 		wait := make(chan interface{})
@@ -207,11 +317,18 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 		}
 	}()
 
+	// A test with scenarios leaves scheduling VUs and iterations to them; otherwise, the VUs
+	// take turns at the iterations in vuFlow.
 	startVUs := atomic.LoadInt64(&e.numVUs)
-	if err := e.scale(ctx, lib.Max(0, startVUs)); err != nil {
+	if len(e.scenarios) > 0 {
+		scenarios = e.startScenarios(ctx)
+	} else if err := e.scale(ctx, lib.Max(0, startVUs)); err != nil {
 		return err
 	}
 
+	// Iterations are numbered within the test's execution segment.
+	tuple := e.executionTuple()
+
 	ticker := time.NewTicker(1 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -246,7 +363,7 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 		}
 
 		select {
-		case flow <- partials:
+		case flow <- tuple.GlobalIndex(partials):
 			// Start an iteration if there's a VU waiting. See also: the big comment block above.
 			atomic.AddInt64(&e.partIters, 1)
 		case t := <-ticker.C:
@@ -264,8 +381,20 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 				return nil
 			}
 
+			if scenarios != nil {
+				keepRunning, err := e.processScenarios(scenarios, at)
+				if err != nil {
+					return err
+				}
+				if !keepRunning {
+					e.Logger.WithField("at", at).Debug("Local: All scenarios ended")
+					cutoff = time.Now()
+					return nil
+				}
+			}
+
 			stages := e.stages
-			if stages != nil {
+			if stages != nil && scenarios == nil {
 				vus, keepRunning := ProcessStages(startVUs, stages, at)
 				if !keepRunning {
 					e.Logger.WithField("at", at).Debug("Local: Ran out of stages")
@@ -280,8 +409,9 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 			}
 		case samples := <-vuOut:
 			// Every iteration ends with a write to vuOut. Check if we've hit the end point.
-			// If not, make sure to include an Iterations bump in the list!
-			if out != nil {
+			// If not, make sure to include an Iterations bump in the list! Scenarios' VUs add
+			// their own, with the scenario's tags.
+			if out != nil && scenarios == nil {
 				var tags *stats.SampleTags
 				if e.Runner != nil {
 					tags = e.Runner.GetOptions().RunTags
@@ -292,6 +422,8 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 					Value:  1,
 					Tags:   tags,
 				})
+			}
+			if out != nil {
 				out <- samples
 			}
 
@@ -301,6 +433,11 @@ func (e *Executor) Run(parent context.Context, out chan<- []stats.Sample) (reter
 				e.Logger.WithFields(log.Fields{"at": at, "end": end}).Debug("Local: Hit iteration limit")
 				return nil
 			}
+		case err := <-e.abort:
+			// An aborted test ends just like one that hit its time limit, but with an error.
+			e.Logger.WithError(err).Debug("Local: Aborted")
+			cutoff = time.Now()
+			return err
 		case <-ctx.Done():
 			// If the test is cancelled, just set the cutoff point to now and proceed down the same
 			// logic as if the time limit was hit.
@@ -322,44 +459,102 @@ func (e *Executor) scale(ctx context.Context, num int64) error {
 	out := e.out
 	e.lock.RUnlock()
 
-	for i, handle := range e.vus {
+	if err := e.scaleHandles(ctx, e.vus, num, 0, flow, out); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&e.numVUs, num)
+	return nil
+}
+
+// scaleHandles starts the first num VUs of a list that aren't running yet, taking iterations from
+// flow, and stops the rest. VUs stopped with a grace period finish the iteration they're running
+// first, unless it takes longer than that. The caller must hold vusLock.
+func (e *Executor) scaleHandles(
+	ctx context.Context, handles []*vuHandle, num int64, grace time.Duration,
+	flow <-chan int64, out chan<- []stats.Sample,
+) error {
+	e.lock.RLock()
+	iterationLimit := e.iterationLimit
+	e.lock.RUnlock()
+
+	for i, handle := range handles {
 		handle := handle
 		handle.RLock()
 		cancel := handle.cancel
+		prev := handle.done
 		handle.RUnlock()
 
 		if i < int(num) {
 			if cancel == nil {
 				vuctx, cancel := context.WithCancel(ctx)
+				stop, done := make(chan struct{}), make(chan struct{})
 				handle.Lock()
 				handle.ctx = vuctx
 				handle.cancel = cancel
+				handle.stop = stop
+				handle.done = done
 				handle.Unlock()
 
-				if handle.vu != nil {
-					if err := handle.vu.Reconfigure(atomic.AddInt64(&e.nextVUID, 1)); err != nil {
+				// A VU that's still finishing an iteration from before it was stopped is
+				// restarted once it's done with it.
+				restarting := false
+				if prev != nil {
+					select {
+					case <-prev:
+					default:
+						restarting = true
+					}
+				}
+				if handle.vu != nil && !restarting {
+					if err := handle.vu.Reconfigure(e.newVUID()); err != nil {
 						return err
 					}
 				}
 
 				e.wg.Add(1)
 				go func() {
-					handle.run(e.Logger, flow, out)
-					e.wg.Done()
+					defer e.wg.Done()
+					defer close(done)
+					if restarting {
+						select {
+						case <-prev:
+						case <-vuctx.Done():
+							return
+						}
+						if handle.vu != nil {
+							if err := handle.vu.Reconfigure(e.newVUID()); err != nil {
+								e.Logger.WithError(err).Error("Couldn't restart a VU")
+								return
+							}
+						}
+					}
+					handle.run(e.Logger, vuctx, stop, flow, out, iterationLimit)
 				}()
 			}
 		} else if cancel != nil {
 			handle.Lock()
-			handle.cancel()
 			handle.cancel = nil
+			if grace > 0 {
+				close(handle.stop)
+				time.AfterFunc(grace, cancel)
+			} else {
+				// Interrupted VUs are restarted right away, as they were before grace periods.
+				cancel()
+				handle.done = nil
+			}
 			handle.Unlock()
 		}
 	}
-
-	atomic.StoreInt64(&e.numVUs, num)
 	return nil
 }
 
+func (e *Executor) Abort(err error) {
+	select {
+	case e.abort <- err:
+	default:
+	}
+}
+
 func (e *Executor) IsRunning() bool {
 	e.lock.RLock()
 	defer e.lock.RUnlock()
@@ -386,6 +581,14 @@ func (e *Executor) SetStages(s []lib.Stage) {
 	e.stages = s
 }
 
+func (e *Executor) GetScenarios() lib.Scenarios {
+	return e.scenarios
+}
+
+func (e *Executor) SetScenarios(s lib.Scenarios) {
+	e.scenarios = s
+}
+
 func (e *Executor) GetIterations() int64 {
 	return atomic.LoadInt64(&e.iters)
 }
@@ -454,6 +657,15 @@ func (e *Executor) SetVUs(num int64) error {
 		return errors.New("vu count can't be negative")
 	}
 
+	run, err := e.externallyControlled()
+	if err != nil {
+		return err
+	}
+	if run != nil {
+		e.Logger.WithField("vus", num).Debug("Local: Scaling an externally controlled scenario")
+		return e.scaleExternallyControlled(run, num)
+	}
+
 	if atomic.LoadInt64(&e.numVUs) == num {
 		return nil
 	}
@@ -495,6 +707,30 @@ func (e *Executor) SetVUsMax(max int64) error {
 		return errors.Errorf("can't lower vu cap (to %d) below vu count (%d)", max, numVUs)
 	}
 
+	run, err := e.externallyControlled()
+	if err != nil {
+		return err
+	}
+	if run != nil {
+		e.vusLock.RLock()
+		external := run.external
+		e.vusLock.RUnlock()
+		if max < external {
+			return errors.Errorf("can't lower vu cap (to %d) below vu count (%d)", max, external)
+		}
+		// An externally controlled scenario gets all of the VUs.
+		defer func() {
+			e.vusLock.Lock()
+			run.vus = e.vus[:len(e.vus):len(e.vus)]
+			for _, handle := range run.vus {
+				if handle.scenario == nil {
+					handle.scenario = run
+				}
+			}
+			e.vusLock.Unlock()
+		}()
+	}
+
 	if max < numVUsMax {
 		e.vus = e.vus[:max]
 		atomic.StoreInt64(&e.numVUsMax, max)
@@ -504,17 +740,13 @@ func (e *Executor) SetVUsMax(max int64) error {
 	e.vusLock.Lock()
 	defer e.vusLock.Unlock()
 
+	newVUs, err := e.newVUs(max - numVUsMax)
+	if err != nil {
+		return err
+	}
 	vus := e.vus
-	for i := numVUsMax; i < max; i++ {
-		var handle vuHandle
-		if e.Runner != nil {
-			vu, err := e.Runner.NewVU()
-			if err != nil {
-				return err
-			}
-			handle.vu = vu
-		}
-		vus = append(vus, &handle)
+	for _, vu := range newVUs {
+		vus = append(vus, &vuHandle{vu: vu})
 	}
 	e.vus = vus
 
@@ -523,6 +755,66 @@ func (e *Executor) SetVUsMax(max int64) error {
 	return nil
 }
 
+// newVUs instantiates VUs in parallel. The script is only compiled once, and shared by them, so
+// it's setting up their runtimes that takes the time, which doesn't depend on the other VUs.
+func (e *Executor) newVUs(n int64) ([]lib.VU, error) {
+	vus := make([]lib.VU, n)
+	if e.Runner == nil || n == 0 {
+		return vus, nil
+	}
+
+	workers := int64(runtime.GOMAXPROCS(0))
+	if workers > n {
+		workers = n
+	}
+	var next int64 = -1
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := int64(0); w < workers; w++ {
+		wg.Add(1)
+		go func(w int64) {
+			defer wg.Done()
+			for i := atomic.AddInt64(&next, 1); i < n; i = atomic.AddInt64(&next, 1) {
+				vu, err := e.Runner.NewVU()
+				if err != nil {
+					errs[w] = err
+					// Make the other workers stop too; the VUs are thrown away.
+					atomic.StoreInt64(&next, n)
+					return
+				}
+				vus[i] = vu
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vus, nil
+}
+
+// executionTuple returns the segment of the test the executor runs, or nil if it runs all of it.
+func (e *Executor) executionTuple() *lib.ExecutionTuple {
+	if e.Runner == nil {
+		return nil
+	}
+	// The options are validated before the test starts, so an invalid segment never gets here.
+	et, err := e.Runner.GetOptions().ExecutionTuple()
+	if err != nil {
+		return nil
+	}
+	return et
+}
+
+// newVUID returns the ID of the next VU to be started; VUs are numbered from 1 within the test's
+// execution segment, so that instances running other segments of it don't reuse their IDs.
+func (e *Executor) newVUID() int64 {
+	return e.executionTuple().GlobalIndex(atomic.AddInt64(&e.nextVUID, 1)-1) + 1
+}
+
 func (e *Executor) SetRunSetup(r bool) {
 	e.runSetup = r
 }
@@ -22,6 +22,7 @@ package local
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -33,6 +34,7 @@ import (
 	"github.com/pkg/errors"
 	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	null "gopkg.in/guregu/null.v3"
 )
 
@@ -82,6 +84,29 @@ func TestExecutorSetupTeardownRun(t *testing.T) {
 		})
 		assert.EqualError(t, e.Run(context.Background(), nil), "setup error")
 
+		t.Run("Abort", func(t *testing.T) {
+			e := New(nil)
+			e.Runner = &lib.MiniRunner{
+				SetupFn: func(ctx context.Context) error {
+					lib.GetExecutor(ctx).Abort(lib.ScriptAbortError{Reason: "wrong environment"})
+					return errors.New("GoError: test aborted: wrong environment")
+				},
+			}
+			assert.Equal(t, lib.ScriptAbortError{Reason: "wrong environment"}, e.Run(context.Background(), nil))
+		})
+		t.Run("Abort In Teardown", func(t *testing.T) {
+			e := New(nil)
+			e.Runner = &lib.MiniRunner{
+				TeardownFn: func(ctx context.Context) error {
+					lib.GetExecutor(ctx).Abort(lib.ScriptAbortError{Reason: "cleanup failed"})
+					return errors.New("GoError: test aborted: cleanup failed")
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			assert.Equal(t, lib.ScriptAbortError{Reason: "cleanup failed"}, e.Run(ctx, nil))
+		})
+
 		t.Run("Don't Run Setup", func(t *testing.T) {
 			e := New(&lib.MiniRunner{
 				SetupFn: func(ctx context.Context) error {
@@ -258,6 +283,122 @@ func TestExecutorEndIterations(t *testing.T) {
 	}
 }
 
+func TestExecutorScenarios(t *testing.T) {
+	var lock sync.Mutex
+	iters := map[string]int{}
+	e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+		sc := lib.GetScenario(ctx)
+		lock.Lock()
+		iters[sc.Name]++
+		lock.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+		return nil, nil
+	}})
+	scenarios := lib.Scenarios{
+		"first": {
+			Executor: lib.ConstantVUsExecutor,
+			VUs:      null.IntFrom(2),
+			Duration: types.NullDurationFrom(200 * time.Millisecond),
+			Tags:     map[string]string{"type": "first"},
+		},
+		"second": {
+			Executor:  lib.RampingVUsExecutor,
+			StartTime: types.NullDurationFrom(100 * time.Millisecond),
+			Stages:    []lib.Stage{{Duration: types.NullDurationFrom(200 * time.Millisecond), Target: null.IntFrom(3)}},
+		},
+	}
+	e.SetScenarios(scenarios)
+	assert.Equal(t, scenarios, e.GetScenarios())
+	assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+	assert.Equal(t, types.NullDurationFrom(300*time.Millisecond), lib.GetEndTime(e))
+
+	out := make(chan []stats.Sample)
+	tags := map[string]int{}
+	done := make(chan struct{})
+	go func() {
+		for samples := range out {
+			for _, s := range samples {
+				if s.Metric == metrics.Iterations {
+					tag, _ := s.Tags.Get("type")
+					tags[tag]++
+				}
+			}
+		}
+		close(done)
+	}()
+
+	errC := make(chan error)
+	go func() { errC <- e.Run(context.Background(), out) }()
+	for !e.IsRunning() {
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(2), e.GetVUs())
+	assert.EqualError(t, e.SetVUs(1), "the VUs of a test with scenarios are controlled by its scenarios")
+
+	assert.NoError(t, <-errC)
+	close(out)
+	<-done
+	assert.True(t, e.GetTime() >= 300*time.Millisecond)
+	assert.Equal(t, int64(0), e.GetVUs())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(t, iters["first"] > 0)
+	assert.True(t, iters["second"] > 0)
+	assert.True(t, tags["first"] > 0)
+	assert.True(t, tags[""] > 0)
+}
+
+func TestExecutorProgress(t *testing.T) {
+	t.Run("Stages", func(t *testing.T) {
+		e := New(nil)
+		e.SetStages([]lib.Stage{
+			{Duration: types.NullDurationFrom(10 * time.Second)},
+			{Duration: types.NullDurationFrom(10 * time.Second)},
+		})
+		e.SetEndTime(types.NullDurationFrom(40 * time.Second))
+		atomic.StoreInt64(&e.time, int64(5*time.Second))
+		assert.Equal(t, lib.Progress{
+			Elapsed:   5 * time.Second,
+			Remaining: types.NullDurationFrom(15 * time.Second),
+			Fraction:  null.FloatFrom(0.25),
+		}, lib.GetProgress(e))
+	})
+	t.Run("Iterations", func(t *testing.T) {
+		e := New(nil)
+		e.SetEndTime(types.NullDurationFrom(40 * time.Second))
+		e.SetEndIterations(null.IntFrom(10))
+		atomic.StoreInt64(&e.time, int64(10*time.Second))
+		atomic.StoreInt64(&e.iters, 5)
+		assert.Equal(t, lib.Progress{
+			Elapsed:   10 * time.Second,
+			Remaining: types.NullDurationFrom(10 * time.Second),
+			Fraction:  null.FloatFrom(0.5),
+		}, lib.GetProgress(e))
+	})
+	t.Run("Infinite", func(t *testing.T) {
+		e := New(nil)
+		e.SetStages([]lib.Stage{{Duration: types.NullDurationFrom(10 * time.Second)}, {}})
+		atomic.StoreInt64(&e.time, int64(20*time.Second))
+		assert.Equal(t, lib.Progress{Elapsed: 20 * time.Second}, lib.GetProgress(e))
+	})
+	t.Run("Context", func(t *testing.T) {
+		var ex lib.Executor
+		e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+			ex = lib.GetExecutor(ctx)
+			return nil, nil
+		}})
+		assert.NoError(t, e.SetVUsMax(1))
+		assert.NoError(t, e.SetVUs(1))
+		e.SetEndIterations(null.IntFrom(1))
+		assert.NoError(t, e.Run(context.Background(), nil))
+		assert.Equal(t, e, ex)
+	})
+}
+
 func TestExecutorIsRunning(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	e := New(nil)
@@ -399,3 +540,632 @@ func TestExecutorSetVUs(t *testing.T) {
 		})
 	})
 }
+
+func TestExecutorArrivalRate(t *testing.T) {
+	var iters int64
+	e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+		atomic.AddInt64(&iters, 1)
+		select {
+		case <-ctx.Done():
+		case <-time.After(30 * time.Millisecond):
+		}
+		return nil, nil
+	}})
+	scenarios := lib.Scenarios{
+		"rate": {
+			Executor:        lib.ConstantArrivalRateExecutor,
+			Rate:            null.IntFrom(100),
+			Duration:        types.NullDurationFrom(500 * time.Millisecond),
+			PreAllocatedVUs: null.IntFrom(1),
+			MaxVUs:          null.IntFrom(10),
+		},
+	}
+	e.SetScenarios(scenarios)
+	assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+
+	out := make(chan []stats.Sample)
+	go func() {
+		for range out {
+		}
+	}()
+	assert.NoError(t, e.Run(context.Background(), out))
+	close(out)
+
+	// Iterations take 30ms, so starting 100/s takes ~3 VUs, rather than the one preallocated.
+	n := atomic.LoadInt64(&iters)
+	assert.True(t, n >= 40 && n <= 51, "%d iterations", n)
+	assert.True(t, e.GetVUsMax() > 1 && e.GetVUsMax() <= 10, "%d max VUs", e.GetVUsMax())
+}
+
+func TestExecutorIterationRate(t *testing.T) {
+	t.Run("Global", func(t *testing.T) {
+		var iters int64
+		e := New(&lib.MiniRunner{
+			Fn: func(ctx context.Context) ([]stats.Sample, error) {
+				atomic.AddInt64(&iters, 1)
+				return nil, nil
+			},
+			Options: lib.Options{IterationRate: null.IntFrom(20)},
+		})
+		assert.NoError(t, e.SetVUsMax(5))
+		assert.NoError(t, e.SetVUs(5))
+		e.SetEndTime(types.NullDurationFrom(500 * time.Millisecond))
+		assert.NoError(t, e.Run(context.Background(), nil))
+
+		// 5 VUs with nothing to do would run thousands of iterations.
+		n := atomic.LoadInt64(&iters)
+		assert.True(t, n >= 9 && n <= 12, "%d iterations", n)
+	})
+
+	t.Run("Scenario", func(t *testing.T) {
+		var lock sync.Mutex
+		iters := map[string]int{}
+		e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+			sc := lib.GetScenario(ctx)
+			lock.Lock()
+			iters[sc.Name]++
+			lock.Unlock()
+			if sc.Name == "paced" {
+				assert.NotNil(t, sc.RPSLimit)
+			} else {
+				assert.Nil(t, sc.RPSLimit)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Millisecond):
+			}
+			return nil, nil
+		}})
+		scenarios := lib.Scenarios{
+			"paced": {
+				Executor:      lib.ConstantVUsExecutor,
+				VUs:           null.IntFrom(5),
+				Duration:      types.NullDurationFrom(500 * time.Millisecond),
+				IterationRate: null.IntFrom(20),
+				RPS:           null.IntFrom(100),
+			},
+			"free": {
+				Executor: lib.ConstantVUsExecutor,
+				VUs:      null.IntFrom(1),
+				Duration: types.NullDurationFrom(500 * time.Millisecond),
+			},
+		}
+		e.SetScenarios(scenarios)
+		assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+
+		out := make(chan []stats.Sample)
+		go func() {
+			for range out {
+			}
+		}()
+		assert.NoError(t, e.Run(context.Background(), out))
+		close(out)
+
+		lock.Lock()
+		defer lock.Unlock()
+		assert.True(t, iters["paced"] >= 9 && iters["paced"] <= 12, "%d paced iterations", iters["paced"])
+		assert.True(t, iters["free"] > 50, "%d free iterations", iters["free"])
+	})
+}
+
+func TestExecutorArrivalRateDropped(t *testing.T) {
+	e := New(&lib.MiniRunner{
+		Fn: func(ctx context.Context) ([]stats.Sample, error) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(100 * time.Millisecond):
+			}
+			return nil, nil
+		},
+		Options: lib.Options{SystemTags: lib.GetTagSet("scenario")},
+	})
+	scenarios := lib.Scenarios{
+		"rate": {
+			Executor:        lib.ConstantArrivalRateExecutor,
+			Rate:            null.IntFrom(100),
+			Duration:        types.NullDurationFrom(300 * time.Millisecond),
+			PreAllocatedVUs: null.IntFrom(1),
+			MaxVUs:          null.IntFrom(2),
+		},
+	}
+	e.SetScenarios(scenarios)
+	assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+
+	var dropped float64
+	out := make(chan []stats.Sample)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for samples := range out {
+			for _, s := range samples {
+				if s.Metric == metrics.DroppedIterations {
+					scenario, _ := s.Tags.Get("scenario")
+					assert.Equal(t, "rate", scenario)
+					dropped += s.Value
+				}
+			}
+		}
+	}()
+	assert.NoError(t, e.Run(context.Background(), out))
+	close(out)
+	<-done
+
+	// Two VUs busy for 100ms each can start ~6 of the ~30 iterations asked for.
+	assert.True(t, dropped >= 15 && dropped <= 30, "%v dropped iterations", dropped)
+	assert.Equal(t, int64(2), e.GetVUsMax())
+}
+
+func TestExecutorExternallyControlled(t *testing.T) {
+	e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Millisecond):
+		}
+		return nil, nil
+	}})
+	scenarios := lib.Scenarios{
+		"explore": {
+			Executor: lib.ExternallyControlledExecutor,
+			VUs:      null.IntFrom(2),
+			MaxVUs:   null.IntFrom(5),
+			Duration: types.NullDurationFrom(300 * time.Millisecond),
+		},
+	}
+	e.SetScenarios(scenarios)
+	assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+
+	out := make(chan []stats.Sample)
+	go func() {
+		for range out {
+		}
+	}()
+	errC := make(chan error)
+	go func() { errC <- e.Run(context.Background(), out) }()
+	for !e.IsRunning() {
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(2), e.GetVUs())
+
+	assert.NoError(t, e.SetVUs(4))
+	assert.Equal(t, int64(4), e.GetVUs())
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int64(4), e.GetVUs())
+	assert.EqualError(t, e.SetVUs(6), "can't raise vu count (to 6) above vu cap (5)")
+
+	assert.NoError(t, e.SetVUsMax(8))
+	assert.NoError(t, e.SetVUs(6))
+	assert.Equal(t, int64(6), e.GetVUs())
+	assert.EqualError(t, e.SetVUsMax(5), "can't lower vu cap (to 5) below vu count (6)")
+	assert.NoError(t, e.SetVUs(1))
+	assert.Equal(t, int64(1), e.GetVUs())
+
+	assert.NoError(t, <-errC)
+	close(out)
+	assert.Equal(t, int64(0), e.GetVUs())
+}
+
+func TestExecutorGracefulStop(t *testing.T) {
+	run := func(t *testing.T, sc lib.Scenario) (finished, interrupted int64) {
+		e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+			select {
+			case <-ctx.Done():
+				atomic.AddInt64(&interrupted, 1)
+			case <-time.After(100 * time.Millisecond):
+				atomic.AddInt64(&finished, 1)
+			}
+			return nil, nil
+		}})
+		scenarios := lib.Scenarios{"test": sc}
+		e.SetScenarios(scenarios)
+		assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+
+		out := make(chan []stats.Sample)
+		go func() {
+			for range out {
+			}
+		}()
+		assert.NoError(t, e.Run(context.Background(), out))
+		close(out)
+		return atomic.LoadInt64(&finished), atomic.LoadInt64(&interrupted)
+	}
+
+	// Both VUs are halfway through their second iterations when the scenario ends.
+	constant := lib.Scenario{
+		Executor: lib.ConstantVUsExecutor,
+		VUs:      null.IntFrom(2),
+		Duration: types.NullDurationFrom(150 * time.Millisecond),
+	}
+	t.Run("GracefulStop", func(t *testing.T) {
+		sc := constant
+		sc.GracefulStop = types.NullDurationFrom(1 * time.Second)
+		finished, interrupted := run(t, sc)
+		assert.Equal(t, int64(4), finished)
+		assert.Equal(t, int64(0), interrupted)
+	})
+	t.Run("NoGracefulStop", func(t *testing.T) {
+		sc := constant
+		sc.GracefulStop = types.NullDurationFrom(0)
+		finished, interrupted := run(t, sc)
+		assert.Equal(t, int64(2), finished)
+		assert.Equal(t, int64(2), interrupted)
+	})
+
+	// One VU is ramped down halfway through its second iteration, the other is interrupted at the
+	// end of the scenario, halfway through its fourth.
+	rampDown := lib.Scenario{
+		Executor:     lib.RampingVUsExecutor,
+		StartVUs:     null.IntFrom(2),
+		GracefulStop: types.NullDurationFrom(0),
+		Stages: []lib.Stage{
+			{Duration: types.NullDurationFrom(150 * time.Millisecond), Target: null.IntFrom(2)},
+			{Duration: types.NullDurationFrom(1 * time.Millisecond), Target: null.IntFrom(1)},
+			{Duration: types.NullDurationFrom(200 * time.Millisecond), Target: null.IntFrom(1)},
+		},
+	}
+	t.Run("GracefulRampDown", func(t *testing.T) {
+		sc := rampDown
+		sc.GracefulRampDown = types.NullDurationFrom(1 * time.Second)
+		_, interrupted := run(t, sc)
+		assert.Equal(t, int64(1), interrupted)
+	})
+	t.Run("NoGracefulRampDown", func(t *testing.T) {
+		sc := rampDown
+		sc.GracefulRampDown = types.NullDurationFrom(0)
+		_, interrupted := run(t, sc)
+		assert.Equal(t, int64(2), interrupted)
+	})
+}
+
+func TestExecutorScenariosStartAfter(t *testing.T) {
+	var lock sync.Mutex
+	first, last := map[string]time.Time{}, map[string]time.Time{}
+	e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+		name := lib.GetScenario(ctx).Name
+		lock.Lock()
+		if _, ok := first[name]; !ok {
+			first[name] = time.Now()
+		}
+		lock.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		lock.Lock()
+		last[name] = time.Now()
+		lock.Unlock()
+		return nil, nil
+	}})
+	scenarios := lib.Scenarios{
+		"warmup": {
+			Executor: lib.ConstantVUsExecutor,
+			Duration: types.NullDurationFrom(100 * time.Millisecond),
+		},
+		"load": {
+			Executor:   lib.ConstantVUsExecutor,
+			Duration:   types.NullDurationFrom(100 * time.Millisecond),
+			StartAfter: null.StringFrom("warmup"),
+		},
+	}
+	e.SetScenarios(scenarios)
+	assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+	assert.Equal(t, types.NullDurationFrom(200*time.Millisecond), lib.GetEndTime(e))
+
+	out := make(chan []stats.Sample)
+	go func() {
+		for range out {
+		}
+	}()
+	start := time.Now()
+	assert.NoError(t, e.Run(context.Background(), out))
+	close(out)
+
+	// Warmup's last iteration may still be finishing in its gracefulStop, though.
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(t, first["load"].Sub(start) >= 100*time.Millisecond, "load started before warmup ended")
+	assert.True(t, last["warmup"].Sub(start) < 150*time.Millisecond, "warmup ran too long")
+}
+
+func TestExecutorScenarioProgress(t *testing.T) {
+	e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, nil
+	}})
+	scenarios := lib.Scenarios{
+		"early": {
+			Executor: lib.ConstantVUsExecutor,
+			VUs:      null.IntFrom(2),
+			Duration: types.NullDurationFrom(200 * time.Millisecond),
+		},
+		"late": {
+			Executor:   lib.SharedIterationsExecutor,
+			Iterations: null.IntFrom(5),
+			StartTime:  types.NullDurationFrom(150 * time.Millisecond),
+		},
+	}
+	e.SetScenarios(scenarios)
+	assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+	assert.Empty(t, e.GetScenarioProgress())
+
+	out := make(chan []stats.Sample)
+	go func() {
+		for range out {
+		}
+	}()
+	errC := make(chan error)
+	go func() { errC <- e.Run(context.Background(), out) }()
+
+	time.Sleep(75 * time.Millisecond)
+	progress := e.GetScenarioProgress()
+	if assert.Len(t, progress, 2) {
+		early, late := progress[0], progress[1]
+		assert.Equal(t, "early", early.Name)
+		assert.True(t, early.Started)
+		assert.False(t, early.Done)
+		assert.Equal(t, int64(2), early.VUs)
+		assert.True(t, early.Iterations > 0)
+		assert.True(t, early.Fraction > 0 && early.Fraction < 1, "fraction: %v", early.Fraction)
+		assert.True(t, early.Remaining.Valid)
+		assert.True(t, time.Duration(early.Remaining.Duration) < 200*time.Millisecond)
+
+		assert.Equal(t, "late", late.Name)
+		assert.False(t, late.Started)
+		assert.Equal(t, int64(0), late.VUs)
+		assert.Equal(t, time.Duration(0), late.Elapsed)
+	}
+
+	assert.NoError(t, <-errC)
+	close(out)
+	for _, p := range e.GetScenarioProgress() {
+		assert.True(t, p.Done, p.Name)
+		assert.Equal(t, 1.0, p.Fraction, p.Name)
+		assert.Equal(t, types.NullDurationFrom(0), p.Remaining, p.Name)
+	}
+}
+
+func TestExecutorScenarioSetupTeardown(t *testing.T) {
+	var lock sync.Mutex
+	var events []string
+	record := func(ctx context.Context, event string) {
+		if sc := lib.GetScenario(ctx); sc != nil {
+			event += ":" + sc.Name
+		}
+		lock.Lock()
+		events = append(events, event)
+		lock.Unlock()
+	}
+	e := New(&lib.MiniRunner{
+		Fn: func(ctx context.Context) ([]stats.Sample, error) {
+			record(ctx, "iteration")
+			return nil, nil
+		},
+		SetupFn: func(ctx context.Context) error {
+			record(ctx, "setup")
+			return nil
+		},
+		TeardownFn: func(ctx context.Context) error {
+			record(ctx, "teardown")
+			return nil
+		},
+	})
+	scenarios := lib.Scenarios{
+		"api": {
+			Executor:   lib.SharedIterationsExecutor,
+			Iterations: null.IntFrom(2),
+			Setup:      null.StringFrom("apiSetup"),
+			Teardown:   null.StringFrom("apiTeardown"),
+		},
+	}
+	e.SetScenarios(scenarios)
+	assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+
+	out := make(chan []stats.Sample)
+	go func() {
+		for range out {
+		}
+	}()
+	assert.NoError(t, e.Run(context.Background(), out))
+	close(out)
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{
+		"setup", "setup:api", "iteration:api", "iteration:api", "teardown:api", "teardown",
+	}, events)
+
+	t.Run("Setup Error", func(t *testing.T) {
+		e := New(&lib.MiniRunner{
+			Fn: func(ctx context.Context) ([]stats.Sample, error) {
+				return nil, nil
+			},
+			SetupFn: func(ctx context.Context) error {
+				if lib.GetScenario(ctx) != nil {
+					return errors.New("scenario setup error")
+				}
+				return nil
+			},
+		})
+		e.SetScenarios(scenarios)
+		assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+		assert.EqualError(t, e.Run(context.Background(), nil), "scenario setup error")
+	})
+}
+
+func TestExecutorPause(t *testing.T) {
+	for name, scenarios := range map[string]lib.Scenarios{
+		"Stages": nil,
+		"Scenarios": {"test": {
+			Executor: lib.ConstantVUsExecutor,
+			Duration: types.NullDurationFrom(1 * time.Second),
+		}},
+		"ArrivalRate": {"test": {
+			Executor:        lib.ConstantArrivalRateExecutor,
+			Rate:            null.IntFrom(200),
+			Duration:        types.NullDurationFrom(1 * time.Second),
+			PreAllocatedVUs: null.IntFrom(2),
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var iters int64
+			e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+				atomic.AddInt64(&iters, 1)
+				time.Sleep(1 * time.Millisecond)
+				return nil, nil
+			}})
+			if scenarios != nil {
+				e.SetScenarios(scenarios)
+				assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+			} else {
+				assert.NoError(t, e.SetVUsMax(1))
+				assert.NoError(t, e.SetVUs(1))
+				e.SetStages([]lib.Stage{{Duration: types.NullDurationFrom(1 * time.Second)}})
+			}
+			e.SetPaused(true)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			out := make(chan []stats.Sample)
+			go func() {
+				for range out {
+				}
+			}()
+			errC := make(chan error)
+			go func() { errC <- e.Run(ctx, out) }()
+			for !e.IsRunning() {
+			}
+
+			// Nothing happens while paused, not even the passing of time.
+			time.Sleep(50 * time.Millisecond)
+			assert.True(t, e.IsPaused())
+			assert.Equal(t, int64(0), atomic.LoadInt64(&iters))
+			assert.Equal(t, time.Duration(0), e.GetTime())
+
+			e.SetPaused(false)
+			time.Sleep(50 * time.Millisecond)
+			assert.True(t, atomic.LoadInt64(&iters) > 0)
+
+			e.SetPaused(true)
+			time.Sleep(10 * time.Millisecond)
+			pausedAt, pausedIters := e.GetTime(), atomic.LoadInt64(&iters)
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, pausedAt, e.GetTime())
+			assert.Equal(t, pausedIters, atomic.LoadInt64(&iters))
+
+			cancel()
+			assert.NoError(t, <-errC)
+			close(out)
+		})
+	}
+}
+
+func TestExecutorIterations(t *testing.T) {
+	run := func(t *testing.T, sc lib.Scenario, d time.Duration) (map[int64]int, time.Duration) {
+		var lock sync.Mutex
+		iters := map[int64]int{}
+		e := New(&lib.MiniRunner{Fn: func(ctx context.Context) ([]stats.Sample, error) {
+			iter, _ := lib.GetIteration(ctx)
+			lock.Lock()
+			iters[iter]++
+			lock.Unlock()
+			time.Sleep(d)
+			return nil, nil
+		}})
+		scenarios := lib.Scenarios{"test": sc}
+		e.SetScenarios(scenarios)
+		assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+
+		out := make(chan []stats.Sample)
+		go func() {
+			for range out {
+			}
+		}()
+		start := time.Now()
+		assert.NoError(t, e.Run(context.Background(), out))
+		close(out)
+
+		lock.Lock()
+		defer lock.Unlock()
+		return iters, time.Since(start)
+	}
+
+	t.Run("Shared", func(t *testing.T) {
+		iters, took := run(t, lib.Scenario{
+			Executor:   lib.SharedIterationsExecutor,
+			VUs:        null.IntFrom(3),
+			Iterations: null.IntFrom(20),
+		}, 1*time.Millisecond)
+		assert.Len(t, iters, 20)
+		for i := int64(0); i < 20; i++ {
+			assert.Equal(t, 1, iters[i], "iteration %d", i)
+		}
+		assert.True(t, took < 1*time.Second, "took %s", took)
+	})
+	t.Run("PerVU", func(t *testing.T) {
+		iters, took := run(t, lib.Scenario{
+			Executor:   lib.PerVUIterationsExecutor,
+			VUs:        null.IntFrom(3),
+			Iterations: null.IntFrom(4),
+		}, 1*time.Millisecond)
+		assert.Len(t, iters, 12)
+		assert.True(t, took < 1*time.Second, "took %s", took)
+	})
+	t.Run("MaxDuration", func(t *testing.T) {
+		iters, took := run(t, lib.Scenario{
+			Executor:     lib.SharedIterationsExecutor,
+			Iterations:   null.IntFrom(100),
+			MaxDuration:  types.NullDurationFrom(100 * time.Millisecond),
+			GracefulStop: types.NullDurationFrom(0),
+		}, 20*time.Millisecond)
+		assert.True(t, len(iters) < 100, "%d iterations", len(iters))
+		assert.True(t, took >= 100*time.Millisecond, "took %s", took)
+	})
+}
+
+func TestExecutorExecutionSegment(t *testing.T) {
+	seg, err := lib.ParseExecutionSegment("1/2:1")
+	require.NoError(t, err)
+
+	run := func(t *testing.T, e *Executor, iters map[int64]int) {
+		out := make(chan []stats.Sample)
+		go func() {
+			for range out {
+			}
+		}()
+		assert.NoError(t, e.Run(context.Background(), out))
+		close(out)
+
+		// The second half of the test gets every other iteration and VU, from the second.
+		assert.Equal(t, map[int64]int{1: 1, 3: 1}, iters)
+		assert.Equal(t, int64(2), e.vus[0].vu.(*lib.MiniRunnerVU).ID)
+	}
+	newExecutor := func(iters map[int64]int) *Executor {
+		var lock sync.Mutex
+		return New(&lib.MiniRunner{
+			Fn: func(ctx context.Context) ([]stats.Sample, error) {
+				iter, _ := lib.GetIteration(ctx)
+				lock.Lock()
+				iters[iter]++
+				lock.Unlock()
+				return nil, nil
+			},
+			Options: lib.Options{ExecutionSegment: *seg},
+		})
+	}
+
+	t.Run("Scenarios", func(t *testing.T) {
+		iters := map[int64]int{}
+		e := newExecutor(iters)
+		scenarios := lib.Scenarios{"test": {
+			Executor:   lib.SharedIterationsExecutor,
+			Iterations: null.IntFrom(2),
+		}}
+		e.SetScenarios(scenarios)
+		assert.NoError(t, e.SetVUsMax(scenarios.InitVUs()))
+		run(t, e, iters)
+	})
+	t.Run("Iterations", func(t *testing.T) {
+		iters := map[int64]int{}
+		e := newExecutor(iters)
+		e.SetEndIterations(null.IntFrom(2))
+		assert.NoError(t, e.SetVUsMax(1))
+		assert.NoError(t, e.SetVUs(1))
+		run(t, e, iters)
+	})
+}
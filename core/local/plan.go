@@ -0,0 +1,160 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package local
+
+import (
+	"math"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+)
+
+// A PlanStage is the estimated load during one stage of a test.
+type PlanStage struct {
+	Duration   types.NullDuration `json:"duration"`
+	StartVUs   int64              `json:"startVUs"`
+	EndVUs     int64              `json:"endVUs"`
+	Iterations float64            `json:"iterations"`
+	Requests   float64            `json:"requests"`
+}
+
+// A Plan is an estimate of what running a test with a set of options will take, based on how
+// long a single iteration is expected to take and how many requests it makes. Estimates use the
+// same linear ramping between stage targets as the executor does.
+type Plan struct {
+	MaxVUs     int64              `json:"maxVUs"`
+	Duration   types.NullDuration `json:"duration"`
+	Iterations float64            `json:"iterations"`
+	Requests   float64            `json:"requests"`
+	Stages     []PlanStage        `json:"stages"`
+
+	// Finite is false if the test has no end condition, in which case the totals only cover the
+	// stages that do end.
+	Finite bool `json:"finite"`
+}
+
+// NewPlan estimates the execution of a test with the given (consolidated) options.
+func NewPlan(opts lib.Options, iterDuration time.Duration, reqsPerIter float64) Plan {
+	plan := Plan{MaxVUs: opts.VUsMax.Int64, Finite: true}
+	if iterDuration <= 0 {
+		iterDuration = time.Second
+	}
+
+	stages := opts.Stages
+	if len(stages) == 0 {
+		switch {
+		case opts.Duration.Valid:
+			stages = []lib.Stage{{Duration: opts.Duration}}
+		case opts.Iterations.Valid:
+			// With only an iteration count, VUs split the iterations between them.
+			vus := opts.VUs.Int64
+			if vus < 1 {
+				vus = 1
+			}
+			rounds := (opts.Iterations.Int64 + vus - 1) / vus
+			stages = []lib.Stage{{Duration: types.NullDurationFrom(time.Duration(rounds) * iterDuration)}}
+		default:
+			stages = []lib.Stage{{}}
+		}
+	}
+
+	// A duration cuts the stages short, if they'd run any longer.
+	var end time.Duration = math.MaxInt64
+	if opts.Duration.Valid {
+		end = time.Duration(opts.Duration.Duration)
+	}
+
+	remaining := math.Inf(1)
+	if opts.Iterations.Valid {
+		remaining = float64(opts.Iterations.Int64)
+	}
+
+	vus := opts.VUs.Int64
+	var elapsed time.Duration
+	for _, stage := range stages {
+		if elapsed >= end || remaining <= 0 {
+			break
+		}
+
+		ps := PlanStage{StartVUs: vus, EndVUs: vus, Duration: stage.Duration}
+		if stage.Target.Valid {
+			ps.EndVUs = stage.Target.Int64
+		}
+		if !stage.Duration.Valid {
+			if end == math.MaxInt64 && math.IsInf(remaining, 1) {
+				// Nothing will ever stop this stage.
+				plan.Stages = append(plan.Stages, ps)
+				plan.Finite = false
+				break
+			}
+			if end == math.MaxInt64 {
+				ps.Duration = types.NullDurationFrom(timeForIterations(ps.EndVUs, remaining, iterDuration))
+			} else {
+				ps.Duration = types.NullDurationFrom(end - elapsed)
+			}
+			ps.StartVUs = ps.EndVUs
+		}
+
+		d := time.Duration(ps.Duration.Duration)
+		if elapsed+d > end {
+			prog := float64(end-elapsed) / float64(d)
+			ps.EndVUs = lib.Lerp(ps.StartVUs, ps.EndVUs, prog)
+			d = end - elapsed
+			ps.Duration = types.NullDurationFrom(d)
+		}
+
+		// The average number of VUs over a linear ramp is the midpoint of its ends.
+		vuSeconds := float64(ps.StartVUs+ps.EndVUs) / 2 * d.Seconds()
+		ps.Iterations = vuSeconds / iterDuration.Seconds()
+		if opts.RPS.Valid && opts.RPS.Int64 > 0 && reqsPerIter > 0 {
+			ps.Iterations = math.Min(ps.Iterations, float64(opts.RPS.Int64)*d.Seconds()/reqsPerIter)
+		}
+		if ps.Iterations > remaining {
+			// The iteration budget runs out partway through; assume a constant rate.
+			d = time.Duration(float64(d) * remaining / ps.Iterations)
+			ps.EndVUs = lib.Lerp(ps.StartVUs, ps.EndVUs, remaining/ps.Iterations)
+			ps.Duration = types.NullDurationFrom(d)
+			ps.Iterations = remaining
+		}
+		ps.Requests = ps.Iterations * reqsPerIter
+		remaining -= ps.Iterations
+
+		plan.Stages = append(plan.Stages, ps)
+		plan.Iterations += ps.Iterations
+		plan.Requests += ps.Requests
+		elapsed += d
+		vus = ps.EndVUs
+	}
+
+	if plan.Finite {
+		plan.Duration = types.NullDurationFrom(elapsed)
+	}
+	return plan
+}
+
+// Returns how long it takes n VUs to run a number of iterations.
+func timeForIterations(vus int64, iterations float64, iterDuration time.Duration) time.Duration {
+	if vus < 1 {
+		vus = 1
+	}
+	return time.Duration(math.Ceil(iterations/float64(vus)) * float64(iterDuration))
+}
@@ -0,0 +1,134 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package local
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestNewPlan(t *testing.T) {
+	t.Run("Duration", func(t *testing.T) {
+		plan := NewPlan(lib.Options{
+			VUs:      null.IntFrom(10),
+			VUsMax:   null.IntFrom(10),
+			Duration: types.NullDurationFrom(60 * time.Second),
+		}, 2*time.Second, 3)
+		assert.True(t, plan.Finite)
+		assert.Equal(t, int64(10), plan.MaxVUs)
+		assert.Equal(t, types.NullDurationFrom(60*time.Second), plan.Duration)
+		assert.Equal(t, 300.0, plan.Iterations)
+		assert.Equal(t, 900.0, plan.Requests)
+		assert.Len(t, plan.Stages, 1)
+	})
+	t.Run("Iterations", func(t *testing.T) {
+		plan := NewPlan(lib.Options{
+			VUs:        null.IntFrom(4),
+			VUsMax:     null.IntFrom(4),
+			Iterations: null.IntFrom(10),
+		}, time.Second, 1)
+		assert.Equal(t, types.NullDurationFrom(2500*time.Millisecond), plan.Duration)
+		assert.Equal(t, 10.0, plan.Iterations)
+		assert.Equal(t, 10.0, plan.Requests)
+	})
+	t.Run("Stages", func(t *testing.T) {
+		plan := NewPlan(lib.Options{
+			VUs:    null.IntFrom(0),
+			VUsMax: null.IntFrom(100),
+			Stages: []lib.Stage{
+				{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(100)},
+				{Duration: types.NullDurationFrom(60 * time.Second)},
+				{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(0)},
+			},
+		}, time.Second, 2)
+		assert.True(t, plan.Finite)
+		assert.Equal(t, types.NullDurationFrom(80*time.Second), plan.Duration)
+		if assert.Len(t, plan.Stages, 3) {
+			assert.Equal(t, PlanStage{
+				Duration: types.NullDurationFrom(10 * time.Second), StartVUs: 0, EndVUs: 100,
+				Iterations: 500, Requests: 1000,
+			}, plan.Stages[0])
+			assert.Equal(t, 6000.0, plan.Stages[1].Iterations)
+			assert.Equal(t, 500.0, plan.Stages[2].Iterations)
+		}
+		assert.Equal(t, 7000.0, plan.Iterations)
+		assert.Equal(t, 14000.0, plan.Requests)
+	})
+	t.Run("StagesCutByDuration", func(t *testing.T) {
+		plan := NewPlan(lib.Options{
+			VUs:      null.IntFrom(0),
+			VUsMax:   null.IntFrom(100),
+			Duration: types.NullDurationFrom(5 * time.Second),
+			Stages: []lib.Stage{
+				{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(100)},
+				{Duration: types.NullDurationFrom(10 * time.Second)},
+			},
+		}, time.Second, 1)
+		assert.Equal(t, types.NullDurationFrom(5*time.Second), plan.Duration)
+		if assert.Len(t, plan.Stages, 1) {
+			assert.Equal(t, int64(50), plan.Stages[0].EndVUs)
+		}
+		assert.Equal(t, 125.0, plan.Iterations)
+	})
+	t.Run("StagesCutByIterations", func(t *testing.T) {
+		plan := NewPlan(lib.Options{
+			VUs:        null.IntFrom(10),
+			VUsMax:     null.IntFrom(10),
+			Iterations: null.IntFrom(50),
+			Stages: []lib.Stage{
+				{Duration: types.NullDurationFrom(10 * time.Second)},
+				{Duration: types.NullDurationFrom(10 * time.Second)},
+			},
+		}, time.Second, 1)
+		assert.Equal(t, types.NullDurationFrom(5*time.Second), plan.Duration)
+		assert.Len(t, plan.Stages, 1)
+		assert.Equal(t, 50.0, plan.Iterations)
+	})
+	t.Run("RPS", func(t *testing.T) {
+		plan := NewPlan(lib.Options{
+			VUs:      null.IntFrom(100),
+			VUsMax:   null.IntFrom(100),
+			Duration: types.NullDurationFrom(10 * time.Second),
+			RPS:      null.IntFrom(20),
+		}, time.Second, 2)
+		assert.Equal(t, 100.0, plan.Iterations)
+		assert.Equal(t, 200.0, plan.Requests)
+	})
+	t.Run("Infinite", func(t *testing.T) {
+		plan := NewPlan(lib.Options{
+			VUs:    null.IntFrom(1),
+			VUsMax: null.IntFrom(5),
+			Stages: []lib.Stage{
+				{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(5)},
+				{},
+			},
+		}, time.Second, 1)
+		assert.False(t, plan.Finite)
+		assert.False(t, plan.Duration.Valid)
+		assert.Len(t, plan.Stages, 2)
+		assert.Equal(t, 30.0, plan.Iterations)
+	})
+}
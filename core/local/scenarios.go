@@ -0,0 +1,536 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package local
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// A scenarioRun is a scenario being run, with the VUs set aside for it; they take the scenario's
+// iterations from its own flow channel.
+type scenarioRun struct {
+	completed int64 // Iterations finished, or interrupted.
+	dropped   int64 // Iterations of an arrival rate that no VU was free to start.
+
+	state *lib.ScenarioState
+	tags  *stats.SampleTags // Tags of the iterations metric.
+
+	// Paces the scenario's iterations, if it has an iterationRate.
+	iterationLimit *rate.Limiter
+
+	// The VUs run in the test's context, so that they can finish their iterations once the
+	// scenario's own context, which feeds them, is done.
+	testCtx context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	vus     []*vuHandle
+	flow    chan int64
+
+	target   int64 // VUs the scenario calls for; arrival rates may run more.
+	active   int64 // Currently running VUs.
+	pending  int64 // VUs being initialized for an arrival rate.
+	external int64 // VUs an externally controlled scenario is scaled to.
+	perVU    int64 // Iterations each VU runs at most, if not 0.
+	started  bool
+	done     bool
+
+	// The segment of the test the executor runs, which its iterations are numbered within.
+	tuple *lib.ExecutionTuple
+
+	tornDown chan struct{} // Closed once the scenario's own teardown has run.
+}
+
+// startScenarios sets aside VUs for every scenario, in the order of their names; they're started,
+// and fed iterations, once the scenario does.
+func (e *Executor) startScenarios(ctx context.Context) []*scenarioRun {
+	var runTags map[string]string
+	var tagScenario bool
+	if e.Runner != nil {
+		opts := e.Runner.GetOptions()
+		runTags = opts.RunTags.CloneTags()
+		tagScenario = opts.SystemTags["scenario"]
+	}
+	tuple := e.executionTuple()
+
+	e.vusLock.Lock()
+	defer e.vusLock.Unlock()
+
+	var runs []*scenarioRun
+	var offset int64
+	for _, name := range e.scenarios.Names() {
+		sc := e.scenarios.Resolve(name)
+		tags := make(map[string]string, len(runTags)+len(sc.Tags)+1)
+		for k, v := range runTags {
+			tags[k] = v
+		}
+		if tagScenario {
+			tags["scenario"] = name
+		}
+		for k, v := range sc.Tags {
+			tags[k] = v
+		}
+
+		run := &scenarioRun{
+			state: &lib.ScenarioState{Name: name, Scenario: sc},
+			tags:  stats.IntoSampleTags(&tags),
+			flow:  make(chan int64),
+			tuple: tuple,
+		}
+		if sc.IterationRate.Valid {
+			run.iterationLimit = rate.NewLimiter(rate.Limit(sc.IterationRate.Int64), 1)
+		}
+		if sc.RPS.Valid {
+			run.state.RPSLimit = rate.NewLimiter(rate.Limit(sc.RPS.Int64), 1)
+		}
+		run.testCtx = ctx
+		run.ctx, run.cancel = context.WithCancel(ctx)
+		run.external, _ = sc.VUsAt(time.Duration(sc.StartTime.Duration))
+		if sc.Executor == lib.PerVUIterationsExecutor {
+			run.perVU = sc.GetIterations() / sc.InitVUs()
+		}
+		end := lib.Min(offset+sc.InitVUs(), int64(len(e.vus)))
+		// Cap the slice, so VUs added to it later don't overwrite the next scenario's.
+		run.vus = e.vus[lib.Min(offset, end):end:end]
+		offset = end
+		for _, handle := range run.vus {
+			handle.scenario = run
+		}
+		runs = append(runs, run)
+	}
+	e.runs = runs
+	return runs
+}
+
+// externallyControlled returns the scenario of the running test that's scaled from outside, if
+// there is one; the VUs of other scenarios can't be changed while they run.
+func (e *Executor) externallyControlled() (*scenarioRun, error) {
+	if len(e.scenarios) == 0 || !e.IsRunning() {
+		return nil, nil
+	}
+	e.vusLock.RLock()
+	defer e.vusLock.RUnlock()
+	for _, run := range e.runs {
+		if run.state.Executor == lib.ExternallyControlledExecutor {
+			return run, nil
+		}
+	}
+	return nil, errors.New("the VUs of a test with scenarios are controlled by its scenarios")
+}
+
+// scaleExternallyControlled scales an externally controlled scenario, right away if it has
+// started, or for when it does otherwise.
+func (e *Executor) scaleExternallyControlled(run *scenarioRun, num int64) error {
+	e.lock.RLock()
+	out := e.out
+	e.lock.RUnlock()
+
+	e.vusLock.Lock()
+	defer e.vusLock.Unlock()
+	if max := int64(len(run.vus)); num > max {
+		return errors.Errorf("can't raise vu count (to %d) above vu cap (%d)", num, max)
+	}
+	run.external = num
+	if !run.started || run.done {
+		return nil
+	}
+	if err := e.scaleHandles(run.testCtx, run.vus, num, 0, run.flow, out); err != nil {
+		return err
+	}
+	run.target = num
+	run.active = num
+	atomic.StoreInt64(&e.numVUs, num)
+	return nil
+}
+
+// feed hands out a scenario's iterations to its VUs, numbered from 0 within the test's execution
+// segment, until its context is done
+// or, for the iteration based executors, all of them have been; except while the test is paused.
+func (e *Executor) feed(run *scenarioRun) {
+	limit := run.state.GetIterations()
+	for iter := int64(0); limit == 0 || iter < limit; iter++ {
+		e.pauseLock.RLock()
+		pause := e.pause
+		e.pauseLock.RUnlock()
+		if pause != nil {
+			select {
+			case <-pause:
+			case <-run.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case run.flow <- run.tuple.GlobalIndex(iter):
+		case <-run.ctx.Done():
+			return
+		}
+	}
+}
+
+// feedArrivalRate starts a scenario's iterations at its rate, on whichever of its VUs is free,
+// until the context is done. If they're all busy, another VU is started, up to its maxVUs;
+// past that, the iteration is dropped. Time spent paused doesn't count.
+func (e *Executor) feedArrivalRate(run *scenarioRun) {
+	sc := run.state.Scenario
+	interval := sc.GetTimeUnit() / time.Duration(sc.Rate.Int64)
+	maxVUs := sc.GetMaxVUs()
+
+	start := time.Now()
+	for n := int64(0); ; n++ {
+		timer := time.NewTimer(time.Until(start.Add(time.Duration(n) * interval)))
+		select {
+		case <-timer.C:
+		case <-run.ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		e.pauseLock.RLock()
+		pause := e.pause
+		e.pauseLock.RUnlock()
+		if pause != nil {
+			pausedAt := time.Now()
+			select {
+			case <-pause:
+				start = start.Add(time.Since(pausedAt))
+			case <-run.ctx.Done():
+				return
+			}
+		}
+
+		iter := run.tuple.GlobalIndex(n)
+		select {
+		case run.flow <- iter:
+			continue
+		default:
+		}
+
+		e.vusLock.Lock()
+		grow := !run.done && int64(len(run.vus))+run.pending < maxVUs
+		if grow {
+			run.pending++
+		}
+		e.vusLock.Unlock()
+		if !grow {
+			e.Logger.WithFields(log.Fields{"scenario": run.state.Name, "iter": iter}).
+				Debug("Local: No VU free to start an iteration, dropping it")
+			e.dropIteration(run)
+			continue
+		}
+		e.wg.Add(1)
+		go func(iter int64) {
+			defer e.wg.Done()
+			e.growArrivalRate(run, iter)
+		}(iter)
+	}
+}
+
+// dropIteration counts an iteration that couldn't be started, and emits a sample for it.
+func (e *Executor) dropIteration(run *scenarioRun) {
+	atomic.AddInt64(&run.dropped, 1)
+
+	e.lock.RLock()
+	out := e.out
+	e.lock.RUnlock()
+	if out == nil {
+		return
+	}
+	select {
+	case out <- []stats.Sample{{
+		Time:   time.Now(),
+		Metric: metrics.DroppedIterations,
+		Value:  1,
+		Tags:   run.tags,
+	}}:
+	case <-run.ctx.Done():
+	}
+}
+
+// growArrivalRate starts another VU for an arrival rate scenario, and has it run an iteration.
+func (e *Executor) growArrivalRate(run *scenarioRun, iter int64) {
+	var handle vuHandle
+	if e.Runner != nil {
+		vu, err := e.Runner.NewVU()
+		if err != nil {
+			e.Logger.WithError(err).WithField("scenario", run.state.Name).Error("Couldn't start a VU")
+			e.vusLock.Lock()
+			run.pending--
+			e.vusLock.Unlock()
+			return
+		}
+		handle.vu = vu
+	}
+	handle.scenario = run
+
+	e.lock.RLock()
+	out := e.out
+	e.lock.RUnlock()
+
+	e.vusLock.Lock()
+	run.pending--
+	if run.done {
+		e.vusLock.Unlock()
+		return
+	}
+	run.vus = append(run.vus, &handle)
+	e.vus = append(e.vus, &handle)
+	err := e.scaleHandles(run.testCtx, run.vus, int64(len(run.vus)), 0, run.flow, out)
+	run.active = int64(len(run.vus))
+	atomic.AddInt64(&e.numVUs, 1)
+	atomic.AddInt64(&e.numVUsMax, 1)
+	e.vusLock.Unlock()
+	if err != nil {
+		e.Logger.WithError(err).WithField("scenario", run.state.Name).Error("Couldn't start a VU")
+		return
+	}
+
+	select {
+	case run.flow <- iter:
+	case <-run.ctx.Done():
+	}
+}
+
+// isFinishing returns whether any of the scenario's VUs is still running, after it's ended.
+func (run *scenarioRun) isFinishing() bool {
+	for _, handle := range run.vus {
+		if handle.isRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// setUpScenario runs the scenario's own setup, if it has one, before its first iteration, and
+// returns whether it succeeded; if not, the test is aborted with the error.
+func (e *Executor) setUpScenario(run *scenarioRun) bool {
+	if !run.state.Setup.Valid || e.Runner == nil || !e.runSetup {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(
+		lib.WithScenario(run.ctx, run.state),
+		time.Duration(e.Runner.GetOptions().SetupTimeout.Duration),
+	)
+	defer cancel()
+	if err := e.Runner.Setup(ctx); err != nil {
+		if run.ctx.Err() == nil {
+			e.Abort(err)
+		}
+		return false
+	}
+	return true
+}
+
+// tearDownScenario starts the ended scenario's own teardown, if it has one and it hasn't yet,
+// and returns whether it's still running.
+func (e *Executor) tearDownScenario(run *scenarioRun) bool {
+	if !run.started || !run.state.Teardown.Valid || e.Runner == nil || !e.runTeardown {
+		return false
+	}
+	if run.tornDown == nil {
+		run.tornDown = make(chan struct{})
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			defer close(run.tornDown)
+			ctx, cancel := context.WithTimeout(
+				lib.WithScenario(run.testCtx, run.state),
+				time.Duration(e.Runner.GetOptions().TeardownTimeout.Duration),
+			)
+			defer cancel()
+			if err := e.Runner.Teardown(ctx); err != nil {
+				e.Abort(err)
+			}
+		}()
+	}
+	select {
+	case <-run.tornDown:
+		return false
+	default:
+		return true
+	}
+}
+
+// tearDownScenarios runs the teardowns of the scenarios that started, but didn't get to run
+// theirs before the test ended, and waits for all of them to finish.
+func (e *Executor) tearDownScenarios(runs []*scenarioRun) {
+	e.vusLock.Lock()
+	var pending []chan struct{}
+	for _, run := range runs {
+		if e.tearDownScenario(run) {
+			pending = append(pending, run.tornDown)
+		}
+	}
+	e.vusLock.Unlock()
+	for _, tornDown := range pending {
+		<-tornDown
+	}
+}
+
+// processScenarios scales the VUs of every scenario to what it calls for at a point of the test,
+// stopping the ones that have ended, and returns whether any of them is still running, or still
+// has VUs finishing their iterations.
+func (e *Executor) processScenarios(runs []*scenarioRun, at time.Duration) (bool, error) {
+	e.vusLock.Lock()
+	defer e.vusLock.Unlock()
+
+	e.lock.RLock()
+	out := e.out
+	e.lock.RUnlock()
+
+	keepRunning := false
+	var total int64
+	for _, run := range runs {
+		if run.done {
+			// An ended scenario's VUs may still be finishing their iterations, and then it may
+			// have its own teardown to run.
+			if run.isFinishing() || e.tearDownScenario(run) {
+				keepRunning = true
+			}
+			continue
+		}
+		vus, running := run.state.VUsAt(at)
+		if iters := run.state.GetIterations(); iters > 0 {
+			if completed := atomic.LoadInt64(&run.completed); completed >= iters {
+				running = false
+			} else if !running {
+				e.Logger.WithField("scenario", run.state.Name).Warnf(
+					"Only %d of the %d iterations of scenario %s were finished within its maxDuration",
+					completed, iters, run.state.Name)
+			}
+		}
+		if running && run.state.Executor == lib.ExternallyControlledExecutor &&
+			at >= time.Duration(run.state.StartTime.Duration) {
+			vus = run.external
+		}
+		var grace time.Duration
+		if run.state.Executor == lib.RampingVUsExecutor {
+			grace = run.state.GetGracefulRampDown()
+		}
+		if !running {
+			e.Logger.WithField("scenario", run.state.Name).Debug("Local: Scenario ended")
+			if dropped := atomic.LoadInt64(&run.dropped); dropped > 0 {
+				e.Logger.WithField("scenario", run.state.Name).Warnf(
+					"%d iterations of scenario %s couldn't be started, as all of its %d maxVUs were busy; "+
+						"it generated less load than configured, raise its maxVUs to avoid that",
+					dropped, run.state.Name, run.state.GetMaxVUs())
+			}
+			run.done = true
+			run.cancel()
+			vus = 0
+			grace = run.state.GetGracefulStop()
+		}
+		if int64(len(run.vus)) < vus {
+			return false, errors.Errorf("scenario %s needs %d VUs, but only %d are set aside for it",
+				run.state.Name, vus, len(run.vus))
+		}
+		if vus != run.target || run.done {
+			if err := e.scaleHandles(run.testCtx, run.vus, vus, grace, run.flow, out); err != nil {
+				return false, err
+			}
+			run.target = vus
+			run.active = vus
+		}
+		if run.done {
+			if run.isFinishing() || e.tearDownScenario(run) {
+				keepRunning = true
+			}
+			continue
+		}
+		if !run.started {
+			run.started = true
+			e.wg.Add(1)
+			go func(run *scenarioRun) {
+				defer e.wg.Done()
+				if !e.setUpScenario(run) {
+					return
+				}
+				if run.state.Executor == lib.ConstantArrivalRateExecutor {
+					e.feedArrivalRate(run)
+				} else {
+					e.feed(run)
+				}
+			}(run)
+		}
+		keepRunning = true
+		total += run.active
+	}
+	atomic.StoreInt64(&e.numVUs, total)
+	return keepRunning, nil
+}
+
+// GetScenarioProgress returns how far along each of the scenarios of the running test is, in the
+// order of their names; it's empty if the test has no scenarios, or hasn't started them yet.
+func (e *Executor) GetScenarioProgress() []lib.ScenarioProgress {
+	at := e.GetTime()
+
+	e.vusLock.RLock()
+	defer e.vusLock.RUnlock()
+
+	progress := make([]lib.ScenarioProgress, 0, len(e.runs))
+	for _, run := range e.runs {
+		p := lib.ScenarioProgress{
+			Name:       run.state.Name,
+			Executor:   run.state.Executor,
+			Iterations: atomic.LoadInt64(&run.completed),
+			Done:       run.done,
+		}
+		// The scenario's feed is started right away, it just has no VUs until its startTime.
+		if start := time.Duration(run.state.StartTime.Duration); run.started && at >= start {
+			p.Started = true
+			p.Elapsed = at - start
+		}
+		if run.done {
+			p.Fraction = 1.0
+			p.Remaining = types.NullDurationFrom(0)
+			progress = append(progress, p)
+			continue
+		}
+		if p.Started {
+			p.VUs = run.active
+		}
+
+		if d := run.state.GetDuration(); d > 0 {
+			p.Fraction = lib.Clampf(float64(p.Elapsed)/float64(d), 0.0, 1.0)
+			p.Remaining = types.NullDurationFrom(time.Duration(lib.Max(0, int64(d-p.Elapsed))))
+		}
+		if iters := run.state.GetIterations(); iters > 0 {
+			if f := lib.Clampf(float64(p.Iterations)/float64(iters), 0.0, 1.0); f > p.Fraction {
+				p.Fraction = f
+				remaining := time.Duration(float64(p.Elapsed) * (1 - f) / f)
+				if !p.Remaining.Valid || remaining < time.Duration(p.Remaining.Duration) {
+					p.Remaining = types.NullDurationFrom(remaining)
+				}
+			}
+		}
+		progress = append(progress, p)
+	}
+	return progress
+}
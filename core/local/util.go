@@ -21,9 +21,11 @@
 package local
 
 import (
+	"context"
 	"time"
 
 	"github.com/loadimpact/k6/lib"
+	"golang.org/x/time/rate"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -62,3 +64,31 @@ func ProcessStages(startVUs int64, stages []lib.Stage, t time.Duration) (null.In
 	}
 	return vus, false
 }
+
+// waitForLimits waits until all of the limiters, the nil ones aside, allow an event, and returns
+// whether they did before the context was done or stop was closed.
+func waitForLimits(ctx context.Context, stop <-chan struct{}, limiters ...*rate.Limiter) bool {
+	for _, limiter := range limiters {
+		if limiter == nil {
+			continue
+		}
+		r := limiter.Reserve()
+		delay := r.Delay()
+		if delay == 0 {
+			continue
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			r.Cancel()
+			return false
+		case <-stop:
+			timer.Stop()
+			r.Cancel()
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+)
+
+// timeSeries tracks the distinct tag sets, each of them a time series, that every metric has had
+// samples with. A script that puts a unique tag on every sample, eg. a URL with an ID in it, would
+// otherwise make the outputs keep track of more and more series until they run out of memory.
+type timeSeries struct {
+	// The most series a metric may have at once; 0 for no limit.
+	limit int
+
+	// Last sample time of every series of every metric, by metric name and tag set.
+	series map[string]map[string]time.Time
+
+	// When the stale series of a metric were last flushed, by metric name.
+	flushed map[string]time.Time
+
+	// Samples dropped for being over the limit, by metric name.
+	dropped map[string]int64
+}
+
+func newTimeSeries(limit int) *timeSeries {
+	return &timeSeries{
+		limit:   limit,
+		series:  make(map[string]map[string]time.Time),
+		flushed: make(map[string]time.Time),
+		dropped: make(map[string]int64),
+	}
+}
+
+// add records a sample and returns whether it may be processed: samples of a series the metric
+// already has always may, samples of a new one only while the metric is under the limit. The
+// second return value is true for the first sample of the metric that's dropped.
+func (ts *timeSeries) add(sample stats.Sample) (ok, first bool) {
+	if ts.limit <= 0 {
+		return true, false
+	}
+
+	name := sample.Metric.Name
+	series := ts.series[name]
+	if series == nil {
+		series = make(map[string]time.Time)
+		ts.series[name] = series
+	}
+	data, _ := sample.Tags.MarshalJSON()
+	key := string(data)
+	if _, ok := series[key]; ok || len(series) < ts.limit {
+		series[key] = sample.Time
+		return true, false
+	}
+
+	// Make room by forgetting the series that haven't had samples for a while; at most every so
+	// often, so a metric that's over the limit doesn't scan its series on every sample.
+	if sample.Time.Sub(ts.flushed[name]) >= StaleTimeSeriesFlushRate {
+		ts.flushed[name] = sample.Time
+		for k, t := range series {
+			if sample.Time.Sub(t) >= StaleTimeSeriesAfter {
+				delete(series, k)
+			}
+		}
+		if len(series) < ts.limit {
+			series[key] = sample.Time
+			return true, false
+		}
+	}
+
+	ts.dropped[name]++
+	return false, ts.dropped[name] == 1
+}
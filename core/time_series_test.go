@@ -0,0 +1,84 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeSeries(t *testing.T) {
+	metric := stats.New("my_metric", stats.Counter)
+	other := stats.New("other_metric", stats.Counter)
+	start := time.Now()
+	sample := func(m *stats.Metric, url string, d time.Duration) stats.Sample {
+		return stats.Sample{
+			Metric: m,
+			Time:   start.Add(d),
+			Tags:   stats.IntoSampleTags(&map[string]string{"url": url}),
+			Value:  1,
+		}
+	}
+	type result struct{ ok, first bool }
+	add := func(ts *timeSeries, s stats.Sample) result {
+		ok, first := ts.add(s)
+		return result{ok, first}
+	}
+
+	t.Run("no limit", func(t *testing.T) {
+		ts := newTimeSeries(0)
+		for _, url := range []string{"a", "b", "c"} {
+			assert.Equal(t, result{true, false}, add(ts, sample(metric, url, 0)))
+		}
+		assert.Empty(t, ts.series)
+	})
+	t.Run("limit", func(t *testing.T) {
+		ts := newTimeSeries(2)
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "a", 0)))
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "b", 0)))
+		assert.Equal(t, result{false, true}, add(ts, sample(metric, "c", 0)))
+		assert.Equal(t, result{false, false}, add(ts, sample(metric, "d", 0)))
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "a", time.Second)))
+		assert.Equal(t, result{true, false}, add(ts, sample(other, "c", 0)))
+		assert.Equal(t, map[string]int64{"my_metric": 2}, ts.dropped)
+	})
+	t.Run("stale", func(t *testing.T) {
+		ts := newTimeSeries(2)
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "a", 0)))
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "b", 0)))
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "b", StaleTimeSeriesAfter)))
+
+		// "a" is stale by now, so "c" takes its place.
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "c", StaleTimeSeriesAfter)))
+		assert.Len(t, ts.series["my_metric"], 2)
+		assert.NotContains(t, ts.series["my_metric"], `{"url":"a"}`)
+
+		// Series aren't looked for again until StaleTimeSeriesFlushRate has passed.
+		d := StaleTimeSeriesAfter + StaleTimeSeriesFlushRate
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "c", d)))
+		assert.Equal(t, result{false, true}, add(ts, sample(metric, "d", d+time.Second)))
+		assert.Equal(t, result{false, false}, add(ts, sample(metric, "d", d+2*time.Second)))
+		assert.Equal(t, result{true, false}, add(ts, sample(metric, "d", 2*StaleTimeSeriesAfter+time.Second)))
+	})
+}
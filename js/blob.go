@@ -0,0 +1,119 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// A Blob is immutable binary data with a MIME type. With no event loop to resolve promises on,
+// text() and bytes() return their results directly.
+type Blob struct {
+	data []byte
+
+	Size int    `js:"size"`
+	Type string `js:"type"`
+}
+
+func newBlob(rt *goja.Runtime, args ...goja.Value) (*Blob, error) {
+	b := &Blob{}
+	if len(args) > 0 && !goja.IsUndefined(args[0]) && !goja.IsNull(args[0]) {
+		parts := args[0].ToObject(rt)
+		length := int(parts.Get("length").ToInteger())
+		for i := 0; i < length; i++ {
+			part := parts.Get(strconv.Itoa(i))
+			if s, ok := part.Export().(string); ok {
+				b.data = append(b.data, s...)
+				continue
+			}
+			data, err := exportBytes(part)
+			if err != nil {
+				return nil, err
+			}
+			b.data = append(b.data, data...)
+		}
+	}
+	if len(args) > 1 && !goja.IsUndefined(args[1]) && !goja.IsNull(args[1]) {
+		opts := args[1].ToObject(rt)
+		for _, k := range opts.Keys() {
+			switch k {
+			case "type":
+				b.Type = normalizeBlobType(opts.Get(k).String())
+			}
+		}
+	}
+	b.Size = len(b.data)
+	return b, nil
+}
+
+// normalizeBlobType lowercases a type, or returns an empty one if it has non-printable
+// characters, as the File API specifies.
+func normalizeBlobType(t string) string {
+	for _, c := range t {
+		if c < 0x20 || c > 0x7E {
+			return ""
+		}
+	}
+	return strings.ToLower(t)
+}
+
+func (b *Blob) Text() string {
+	return string(b.data)
+}
+
+func (b *Blob) Bytes() []byte {
+	return append([]byte{}, b.data...)
+}
+
+// Slice returns a blob with the bytes from start up to end, which count from the end of the
+// blob if negative, like Array.prototype.slice().
+func (b *Blob) Slice(start, end, contentType goja.Value) *Blob {
+	clamp := func(v goja.Value, def int) int {
+		if v == nil || goja.IsUndefined(v) {
+			return def
+		}
+		i := int(v.ToInteger())
+		if i < 0 {
+			i += len(b.data)
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > len(b.data) {
+			return len(b.data)
+		}
+		return i
+	}
+	from, to := clamp(start, 0), clamp(end, len(b.data))
+
+	slice := &Blob{}
+	if to > from {
+		slice.data = b.data[from:to]
+	}
+	if contentType != nil && !goja.IsUndefined(contentType) {
+		slice.Type = normalizeBlobType(contentType.String())
+	}
+	slice.Size = len(slice.data)
+	return slice
+}
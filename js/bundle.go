@@ -28,10 +28,13 @@ import (
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/js/compiler"
 	jslib "github.com/loadimpact/k6/js/lib"
+	k6crypto "github.com/loadimpact/k6/js/modules/k6/crypto"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/secrets"
 	"github.com/loadimpact/k6/loader"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
+	"gopkg.in/guregu/null.v3"
 )
 
 // A Bundle is a self-contained bundle of scripts and resources.
@@ -45,6 +48,12 @@ type Bundle struct {
 	BaseInitContext *InitContext
 
 	Env map[string]string
+
+	// Experimental features enabled for the bundle.
+	Enable []string
+
+	// Where the script's secrets come from, if anywhere; not part of archives.
+	Secrets *secrets.Manager
 }
 
 // A BundleInstance is a self-contained instance of a Bundle.
@@ -52,6 +61,18 @@ type BundleInstance struct {
 	Runtime *goja.Runtime
 	Context *context.Context
 	Default goja.Callable
+
+	// The HTTP response callback the script set, or the default one.
+	ResponseCallback *common.ResponseCallback
+
+	// The HTTP hooks the script set, if any.
+	HTTPHooks *common.HTTPHooks
+
+	// The tags the script set on its VU with k6/execution, if any.
+	VUTags map[string]string
+
+	// The values the script stored on its VU with k6/execution.
+	Store *common.VUStore
 }
 
 // Creates a new bundle from a source file and a filesystem.
@@ -77,8 +98,11 @@ func NewBundle(src *lib.SourceData, fs afero.Fs, rtOpts lib.RuntimeOptions) (*Bu
 		Program:         pgm,
 		BaseInitContext: NewInitContext(rt, new(context.Context), cachedFS, loader.Dir(src.Filename)),
 		Env:             rtOpts.Env,
+		Enable:          rtOpts.Enable,
+		Secrets:         rtOpts.Secrets,
 	}
-	if err := bundle.instantiate(rt, bundle.BaseInitContext); err != nil {
+	bundle.BaseInitContext.features = featureSet(bundle.Enable)
+	if err := bundle.instantiate(rt, bundle.BaseInitContext, newResponseCallback(), &common.HTTPHooks{}); err != nil {
 		return nil, err
 	}
 
@@ -89,12 +113,11 @@ func NewBundle(src *lib.SourceData, fs afero.Fs, rtOpts lib.RuntimeOptions) (*Bu
 	}
 	exports := exportsV.ToObject(rt)
 
-	// Validate the default function.
+	// Validate the default function; it may be left out if the scenarios all run others, which is
+	// checked below, once the options are known.
 	def := exports.Get("default")
-	if def == nil || goja.IsNull(def) || goja.IsUndefined(def) {
-		return nil, errors.New("script must export a default function")
-	}
-	if _, ok := goja.AssertFunction(def); !ok {
+	hasDefault := def != nil && !goja.IsNull(def) && !goja.IsUndefined(def)
+	if _, ok := goja.AssertFunction(def); hasDefault && !ok {
 		return nil, errors.New("default export must be a function")
 	}
 
@@ -104,7 +127,7 @@ func NewBundle(src *lib.SourceData, fs afero.Fs, rtOpts lib.RuntimeOptions) (*Bu
 		switch k {
 		case "default": // Already checked above.
 		case "options":
-			data, err := json.Marshal(v.Export())
+			data, err := json.Marshal(exportOptions(rt, v))
 			if err != nil {
 				return nil, err
 			}
@@ -119,8 +142,35 @@ func NewBundle(src *lib.SourceData, fs afero.Fs, rtOpts lib.RuntimeOptions) (*Bu
 			if _, ok := goja.AssertFunction(v); !ok {
 				return nil, errors.New("exported 'teardown' must be a function")
 			}
+		case "handleSummary":
+			if _, ok := goja.AssertFunction(v); !ok {
+				return nil, errors.New("exported 'handleSummary' must be a function")
+			}
+		}
+	}
+
+	// Validate the functions the scenarios run.
+	needsDefault := len(bundle.Options.Scenarios) == 0
+	for _, name := range bundle.Options.Scenarios.Names() {
+		sc := bundle.Options.Scenarios[name]
+		exec := sc.GetExec()
+		if exec == "default" {
+			needsDefault = true
+		} else if _, ok := getScenarioFunc(rt, name, "exec", exec); !ok {
+			return nil, errors.Errorf("scenario %s: exported function '%s' not found", name, exec)
+		}
+		for option, part := range map[string]null.String{"setup": sc.Setup, "teardown": sc.Teardown} {
+			if !part.Valid {
+				continue
+			}
+			if _, ok := getScenarioFunc(rt, name, option, part.String); !ok {
+				return nil, errors.Errorf("scenario %s: exported function '%s' not found", name, part.String)
+			}
 		}
 	}
+	if needsDefault && !hasDefault {
+		return nil, errors.New("script must export a default function")
+	}
 
 	return &bundle, nil
 }
@@ -155,6 +205,9 @@ func NewBundleFromArchive(arc *lib.Archive, rtOpts lib.RuntimeOptions) (*Bundle,
 		env[k] = v
 	}
 
+	enable := append(append([]string{}, arc.Enable...), rtOpts.Enable...)
+	initctx.features = featureSet(enable)
+
 	return &Bundle{
 		Filename:        arc.Filename,
 		Source:          string(arc.Data),
@@ -162,9 +215,19 @@ func NewBundleFromArchive(arc *lib.Archive, rtOpts lib.RuntimeOptions) (*Bundle,
 		Options:         arc.Options,
 		BaseInitContext: initctx,
 		Env:             env,
+		Enable:          enable,
+		Secrets:         rtOpts.Secrets,
 	}, nil
 }
 
+func featureSet(names []string) map[string]bool {
+	features := make(map[string]bool, len(names))
+	for _, name := range names {
+		features[name] = true
+	}
+	return features
+}
+
 func (b *Bundle) MakeArchive() *lib.Archive {
 	arc := &lib.Archive{
 		Type:     "js",
@@ -173,8 +236,11 @@ func (b *Bundle) MakeArchive() *lib.Archive {
 		Data:     []byte(b.Source),
 		Pwd:      b.BaseInitContext.pwd,
 		Env:      b.Env,
+		Enable:   b.Enable,
 	}
 
+	b.BaseInitContext.mu.RLock()
+	defer b.BaseInitContext.mu.RUnlock()
 	arc.Scripts = make(map[string][]byte, len(b.BaseInitContext.programs))
 	for name, pgm := range b.BaseInitContext.programs {
 		arc.Scripts[name] = []byte(pgm.src)
@@ -193,29 +259,42 @@ func (b *Bundle) Instantiate() (*BundleInstance, error) {
 	// runtime, but no state, to allow module-provided types to function within the init context.
 	rt := goja.New()
 	init := newBoundInitContext(b.BaseInitContext, ctxPtr, rt)
-	if err := b.instantiate(rt, init); err != nil {
+	responseCallback := newResponseCallback()
+	httpHooks := &common.HTTPHooks{}
+	if err := b.instantiate(rt, init, responseCallback, httpHooks); err != nil {
 		return nil, err
 	}
 
-	// Grab the default function; type is already checked in NewBundle().
+	// Grab the default function, if the script has one; type is already checked in NewBundle().
 	exports := rt.Get("exports").ToObject(rt)
-	def, ok := goja.AssertFunction(exports.Get("default"))
-	if !ok || def == nil {
-		panic("exported default is not a function")
-	}
+	def, _ := goja.AssertFunction(exports.Get("default"))
 
 	return &BundleInstance{
 		Runtime: rt,
 		Context: ctxPtr,
 		Default: def,
+
+		ResponseCallback: responseCallback,
+		HTTPHooks:        httpHooks,
+		VUTags:           make(map[string]string),
+		Store:            common.NewVUStore(),
 	}, nil
 }
 
+func newResponseCallback() *common.ResponseCallback {
+	cb := common.ResponseCallback(common.DefaultResponseCallback)
+	return &cb
+}
+
 // Instantiates the bundle into an existing runtime. Not public because it also messes with a bunch
 // of other things, will potentially thrash data and makes a mess in it if the operation fails.
-func (b *Bundle) instantiate(rt *goja.Runtime, init *InitContext) error {
+func (b *Bundle) instantiate(rt *goja.Runtime, init *InitContext, responseCallback *common.ResponseCallback, httpHooks *common.HTTPHooks) error {
 	rt.SetFieldNameMapper(common.FieldNameMapper{})
-	rt.SetRandSource(common.NewRandSource())
+	if seed := b.Options.Seed; seed.Valid {
+		rt.SetRandSource(common.NewSeededRandSource(seed.Int64))
+	} else {
+		rt.SetRandSource(common.NewRandSource())
+	}
 
 	if _, err := rt.RunProgram(jslib.CoreJS); err != nil {
 		return err
@@ -226,14 +305,28 @@ func (b *Bundle) instantiate(rt *goja.Runtime, init *InitContext) error {
 	module := rt.NewObject()
 	_ = module.Set("exports", exports)
 	rt.Set("module", module)
+	init.modules[b.Filename] = module
 
 	rt.Set("__ENV", b.Env)
+	common.BindToGlobal(rt, common.Bind(rt, webAPI{Crypto: webCrypto{Subtle: &k6crypto.SubtleCrypto{}}, rt: rt}, init.ctxPtr))
 
 	*init.ctxPtr = common.WithRuntime(context.Background(), rt)
+	rejections := &common.Rejections{}
+	*init.ctxPtr = common.WithRejections(*init.ctxPtr, rejections)
+	*init.ctxPtr = common.WithInitEnv(*init.ctxPtr, &common.InitEnvironment{
+		Resolve:          init.resolve,
+		ReadFile:         init.readFile,
+		ResponseCallback: responseCallback,
+		HTTPHooks:        httpHooks,
+		Secrets:          b.Secrets,
+	})
 	unbindInit := common.BindToGlobal(rt, common.Bind(rt, init, init.ctxPtr))
 	if _, err := rt.RunProgram(b.Program); err != nil {
 		return err
 	}
+	if err := rejections.Err(); err != nil {
+		return err
+	}
 	unbindInit()
 	*init.ctxPtr = nil
 
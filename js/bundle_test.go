@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/modules"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/spf13/afero"
@@ -78,6 +79,66 @@ func TestNewBundle(t *testing.T) {
 		_, err := getSimpleBundle("/script.js", `export default function() {};`)
 		assert.NoError(t, err)
 	})
+	t.Run("TypeScript", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		assert.NoError(t, afero.WriteFile(fs, "/lib.ts", []byte(`
+			interface Greeting { text: string }
+			exports.greet = function(name: string): Greeting { return { text: "hi " + name }; };
+		`), 0644))
+		b, err := NewBundle(&lib.SourceData{Filename: "/script.ts", Data: []byte(`
+			var greet: (name: string) => { text: string } = require("./lib.ts").greet;
+			exports.options = { vus: 2 as number };
+			exports.default = function(): string { return greet("k6").text; };
+		`)}, fs, lib.RuntimeOptions{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, null.IntFrom(2), b.Options.VUs)
+		bi, err := b.Instantiate()
+		if assert.NoError(t, err) {
+			v, err := bi.Default(goja.Undefined())
+			if assert.NoError(t, err) {
+				assert.Equal(t, "hi k6", v.Export())
+			}
+		}
+
+		_, err = getSimpleBundle("/script.ts", "var a: = 1;")
+		assert.EqualError(t, err, `/script.ts:1:8: Unexpected "="`)
+	})
+	t.Run("Scenarios", func(t *testing.T) {
+		t.Run("NoDefault", func(t *testing.T) {
+			_, err := getSimpleBundle("/script.js", `
+				exports.options = { scenarios: { api: { executor: "constant-vus", duration: "1s", exec: "api" } } };
+				exports.api = function() {};
+			`)
+			assert.NoError(t, err)
+		})
+		t.Run("NoDefaultButNeeded", func(t *testing.T) {
+			_, err := getSimpleBundle("/script.js", `
+				exports.options = { scenarios: {
+					api: { executor: "constant-vus", duration: "1s", exec: "api" },
+					web: { executor: "constant-vus", duration: "1s" },
+				} };
+				exports.api = function() {};
+			`)
+			assert.EqualError(t, err, "script must export a default function")
+		})
+		t.Run("ExecNotFound", func(t *testing.T) {
+			_, err := getSimpleBundle("/script.js", `
+				exports.options = { scenarios: { api: { executor: "constant-vus", duration: "1s", exec: "api" } } };
+				exports.default = function() {};
+				exports.api = 5;
+			`)
+			assert.EqualError(t, err, "scenario api: exported function 'api' not found")
+		})
+		t.Run("SetupNotFound", func(t *testing.T) {
+			_, err := getSimpleBundle("/script.js", `
+				exports.options = { scenarios: { api: { executor: "constant-vus", duration: "1s", setup: "apiSetup" } } };
+				exports.default = function() {};
+			`)
+			assert.EqualError(t, err, "scenario api: exported function 'apiSetup' not found")
+		})
+	})
 	t.Run("stdin", func(t *testing.T) {
 		b, err := getSimpleBundle("-", `export default function() {};`)
 		if assert.NoError(t, err) {
@@ -475,3 +536,58 @@ func TestBundleEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestBundleExperimentalModules(t *testing.T) {
+	modules.Index["k6/x/test"] = &struct{}{}
+	modules.Experimental["k6/x/test"] = modules.ExperimentalFeature{Name: "test", DocsURL: "https://example.com/test"}
+	defer func() {
+		delete(modules.Index, "k6/x/test")
+		delete(modules.Experimental, "k6/x/test")
+	}()
+	src := &lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			var test = require("k6/x/test");
+			exports.default = function() {};
+		`),
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		_, err := NewBundle(src, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		assert.EqualError(t, err, "GoError: k6/x/test is an experimental module, run k6 with --enable=test to use it; see https://example.com/test")
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		b1, err := NewBundle(src, afero.NewMemMapFs(), lib.RuntimeOptions{Enable: []string{"test"}})
+		if !assert.NoError(t, err) {
+			return
+		}
+		arc := b1.MakeArchive()
+		assert.Equal(t, []string{"test"}, arc.Enable)
+
+		b2, err := NewBundleFromArchive(arc, lib.RuntimeOptions{})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		bundles := map[string]*Bundle{"Source": b1, "Archive": b2}
+		for name, b := range bundles {
+			t.Run(name, func(t *testing.T) {
+				_, err := b.Instantiate()
+				assert.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("SOAP", func(t *testing.T) {
+		src := &lib.SourceData{
+			Filename: "/script.js",
+			Data:     []byte(`var soap = require("k6/soap"); exports.default = function() {};`),
+		}
+		_, err := NewBundle(src, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		assert.EqualError(t, err, "GoError: k6/soap is an experimental module, run k6 with --enable=soap to use it; see https://docs.k6.io/docs/k6soap")
+
+		_, err = NewBundle(src, afero.NewMemMapFs(), lib.RuntimeOptions{Enable: []string{"soap"}})
+		assert.NoError(t, err)
+	})
+}
@@ -24,6 +24,7 @@ import (
 	"context"
 	"reflect"
 	"strings"
+	"unicode"
 
 	"github.com/dop251/goja"
 	"github.com/pkg/errors"
@@ -53,7 +54,7 @@ func FieldName(t reflect.Type, f reflect.StructField) string {
 	}
 
 	// Allow a `js:"name"` tag to override the default name.
-	if tag := f.Tag.Get("js"); tag != "" {
+	if tag := strings.SplitN(f.Tag.Get("js"), ",", 2)[0]; tag != "" {
 		// Matching encoding/json, `js:"-"` hides a field.
 		if tag == "-" {
 			return ""
@@ -70,7 +71,7 @@ func FieldName(t reflect.Type, f reflect.StructField) string {
 func MethodName(t reflect.Type, m reflect.Method) string {
 	// A field with a name beginning with an X is a constructor, and just gets the prefix stripped.
 	// Note: They also get some special treatment from Bridge(), see further down.
-	if m.Name[0] == 'X' {
+	if isConstructor(m.Name) {
 		return m.Name[1:]
 	}
 
@@ -78,6 +79,12 @@ func MethodName(t reflect.Type, m reflect.Method) string {
 	return strings.ToLower(m.Name[0:1]) + m.Name[1:]
 }
 
+// isConstructor returns whether a method is a constructor, eg. XCookieJar. An X that isn't
+// followed by an uppercase letter is just the start of a name, eg. Xml.
+func isConstructor(name string) bool {
+	return len(name) > 1 && name[0] == 'X' && unicode.IsUpper(rune(name[1]))
+}
+
 // FieldNameMapper for goja.Runtime.SetFieldNameMapper()
 type FieldNameMapper struct{}
 
@@ -224,7 +231,7 @@ func Bind(rt *goja.Runtime, v interface{}, ctxPtr *context.Context) map[string]i
 
 		// X-Prefixed methods are assumed to be constructors; use a closure to wrap them in a
 		// pure-JS function to allow them to be `new`d. (This is an awful hack...)
-		if meth.Name[0] == 'X' {
+		if isConstructor(meth.Name) {
 			wrapperV, _ := rt.RunProgram(constructWrap)
 			wrapper, _ := goja.AssertFunction(wrapperV)
 			v, _ := wrapper(goja.Undefined(), rt.ToValue(fn.Interface()))
@@ -242,9 +249,19 @@ func Bind(rt *goja.Runtime, v interface{}, ctxPtr *context.Context) map[string]i
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
 		name := FieldName(typ, field)
-		if name != "" {
-			exports[name] = val.Field(i).Interface()
+		if name == "" {
+			continue
+		}
+
+		// A `js:"name,bind"` tag binds a nested object the same way, so its methods can be
+		// invoked with the context too.
+		if tag := strings.SplitN(field.Tag.Get("js"), ",", 2); len(tag) == 2 && tag[1] == "bind" {
+			if fv := val.Field(i); fv.Kind() != reflect.Ptr || !fv.IsNil() {
+				exports[name] = Bind(rt, fv.Interface(), ctxPtr)
+				continue
+			}
 		}
+		exports[name] = val.Field(i).Interface()
 	}
 
 	return exports
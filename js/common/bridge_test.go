@@ -219,6 +219,10 @@ func (m *bridgeTestCounterType) Count() int {
 	return m.Counter
 }
 
+type bridgeTestNestedType struct {
+	Nested *bridgeTestContextAddType `js:"nested,bind"`
+}
+
 type bridgeTestConstructorType struct{}
 
 type bridgeTestConstructorSpawnedType struct{}
@@ -227,6 +231,8 @@ func (bridgeTestConstructorType) XConstructor() bridgeTestConstructorSpawnedType
 	return bridgeTestConstructorSpawnedType{}
 }
 
+func (bridgeTestConstructorType) Xml() string { return "" }
+
 func TestFieldNameMapper(t *testing.T) {
 	testdata := []struct {
 		Typ     reflect.Type
@@ -248,8 +254,12 @@ func TestFieldNameMapper(t *testing.T) {
 			"TwoWords": "two_words",
 			"URL":      "url",
 		}, nil},
+		{reflect.TypeOf(bridgeTestNestedType{}), map[string]string{
+			"Nested": "nested",
+		}, nil},
 		{reflect.TypeOf(bridgeTestConstructorType{}), nil, map[string]string{
 			"XConstructor": "Constructor",
+			"Xml":          "xml",
 		}},
 	}
 	for _, data := range testdata {
@@ -643,6 +653,20 @@ func TestBind(t *testing.T) {
 				}
 			})
 		}},
+		{"Nested", bridgeTestNestedType{&bridgeTestContextAddType{}}, func(t *testing.T, obj interface{}, rt *goja.Runtime) {
+			_, err := RunString(rt, `obj.nested.contextAdd(1, 2)`)
+			assert.EqualError(t, err, "GoError: contextAdd() can only be called from within default()")
+
+			t.Run("Valid", func(t *testing.T) {
+				*ctxPtr = context.Background()
+				defer func() { *ctxPtr = nil }()
+
+				v, err := RunString(rt, `obj.nested.contextAdd(1, 2)`)
+				if assert.NoError(t, err) {
+					assert.Equal(t, int64(3), v.Export())
+				}
+			})
+		}},
 		{"Constructor", bridgeTestConstructorType{}, func(t *testing.T, obj interface{}, rt *goja.Runtime) {
 			v, err := RunString(rt, `new obj.Constructor()`)
 			assert.NoError(t, err)
@@ -24,6 +24,7 @@ import (
 	"context"
 
 	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/lib/secrets"
 )
 
 type ctxKey int
@@ -31,8 +32,28 @@ type ctxKey int
 const (
 	ctxKeyState ctxKey = iota
 	ctxKeyRuntime
+	ctxKeyInitEnv
+	ctxKeyRejections
 )
 
+// InitEnvironment gives modules access to the init context that is being run.
+type InitEnvironment struct {
+	// Resolves a path relative to the script being initialised, like open() does.
+	Resolve func(name string) string
+
+	// Loads a file like open() does, so that it's read once per test and archives include it.
+	ReadFile func(name string) ([]byte, error)
+
+	// The response callback of the instance of the script being initialised.
+	ResponseCallback *ResponseCallback
+
+	// The HTTP hooks of the instance of the script being initialised.
+	HTTPHooks *HTTPHooks
+
+	// Where secrets come from, if anywhere.
+	Secrets *secrets.Manager
+}
+
 func WithState(ctx context.Context, state *State) context.Context {
 	return context.WithValue(ctx, ctxKeyState, state)
 }
@@ -56,3 +77,29 @@ func GetRuntime(ctx context.Context) *goja.Runtime {
 	}
 	return v.(*goja.Runtime)
 }
+
+func WithInitEnv(ctx context.Context, env *InitEnvironment) context.Context {
+	return context.WithValue(ctx, ctxKeyInitEnv, env)
+}
+
+func GetInitEnv(ctx context.Context) *InitEnvironment {
+	v := ctx.Value(ctxKeyInitEnv)
+	if v == nil {
+		return nil
+	}
+	return v.(*InitEnvironment)
+}
+
+// WithRejections attaches the tracker the rejections of promise-like objects created by the code
+// being run are added to.
+func WithRejections(ctx context.Context, r *Rejections) context.Context {
+	return context.WithValue(ctx, ctxKeyRejections, r)
+}
+
+func GetRejections(ctx context.Context) *Rejections {
+	v := ctx.Value(ctxKeyRejections)
+	if v == nil {
+		return nil
+	}
+	return v.(*Rejections)
+}
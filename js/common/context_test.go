@@ -45,3 +45,12 @@ func TestContextRuntime(t *testing.T) {
 func TestContextRuntimeNil(t *testing.T) {
 	assert.Nil(t, GetRuntime(context.Background()))
 }
+
+func TestContextInitEnv(t *testing.T) {
+	env := &InitEnvironment{}
+	assert.Equal(t, env, GetInitEnv(WithInitEnv(context.Background(), env)))
+}
+
+func TestContextInitEnvNil(t *testing.T) {
+	assert.Nil(t, GetInitEnv(context.Background()))
+}
@@ -24,6 +24,7 @@ import (
 	crand "crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 
 	"github.com/dop251/goja"
@@ -40,3 +41,15 @@ func NewRandSource() goja.RandSource {
 	}
 	return rand.New(rand.NewSource(seed)).Float64
 }
+
+// NewSeededRandSource returns a RandSource that always makes the same numbers for the same seed
+// and numbers mixed into it, eg. a VU's ID, so every VU gets a sequence of its own.
+func NewSeededRandSource(seed int64, mix ...int64) goja.RandSource {
+	h := fnv.New64a()
+	var b [8]byte
+	for _, n := range append([]int64{seed}, mix...) {
+		binary.LittleEndian.PutUint64(b[:], uint64(n))
+		_, _ = h.Write(b[:])
+	}
+	return rand.New(rand.NewSource(int64(h.Sum64()))).Float64
+}
@@ -0,0 +1,63 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package common
+
+import (
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// Rejections keeps track of the promise-like objects that were rejected, so the ones no handler
+// was attached to by the time the code that created them is done running can be reported.
+type Rejections struct {
+	pending []*Rejection
+}
+
+// Rejection is a rejected promise-like object's reason.
+type Rejection struct {
+	Reason  goja.Value
+	handled bool
+}
+
+// Add tracks a rejection; Handle has to be called on it once a handler is attached.
+func (r *Rejections) Add(reason goja.Value) *Rejection {
+	rejection := &Rejection{Reason: reason}
+	r.pending = append(r.pending, rejection)
+	return rejection
+}
+
+// Handle marks the rejection as handled.
+func (r *Rejection) Handle() {
+	r.handled = true
+}
+
+// Err returns an error for the first rejection that wasn't handled, as if its reason had been
+// thrown, and stops tracking all of them.
+func (r *Rejections) Err() error {
+	pending := r.pending
+	r.pending = nil
+	for _, rejection := range pending {
+		if !rejection.handled {
+			return errors.Errorf("Uncaught (in promise) %s", rejection.Reason.String())
+		}
+	}
+	return nil
+}
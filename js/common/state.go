@@ -23,10 +23,12 @@ package common
 import (
 	"crypto/tls"
 	"net/http"
-	"net/http/cookiejar"
+	"sync"
 
+	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/secrets"
 	"github.com/loadimpact/k6/stats"
 	"github.com/oxtoacart/bpool"
 	log "github.com/sirupsen/logrus"
@@ -47,7 +49,7 @@ type State struct {
 	// Networking equipment.
 	HTTPTransport http.RoundTripper
 	Dialer        *netext.Dialer
-	CookieJar     *cookiejar.Jar
+	CookieJar     *netext.CookieJar
 	TLSConfig     *tls.Config
 
 	// Rate limits.
@@ -59,5 +61,71 @@ type State struct {
 	// Buffer pool; use instead of allocating fresh buffers when possible.
 	BPool *bpool.BufferPool
 
+	// Client profile simulated by the VU; sets the default headers of HTTP requests.
+	Profile *lib.ClientProfile
+
+	// Headers added to every HTTP request made by the VU, eg. by its geo region.
+	Headers map[string]string
+
 	Vu, Iteration int64
+
+	// Tags set by the script that are added to all metrics emitted for the rest of the iteration.
+	Tags map[string]string
+
+	// Tags the script set on the VU, which last for the rest of its iterations; the iteration's
+	// own tags take precedence over them.
+	VUTags map[string]string
+
+	// Values the script stored on the VU; the ones stored for the iteration are deleted after it.
+	Store *VUStore
+
+	// Decides whether HTTP responses are expected ones; it belongs to the VU's instance of the
+	// script rather than the iteration, so setting it lasts. A nil callback turns it off.
+	ResponseCallback *ResponseCallback
+
+	// Hooks the script set to run around every HTTP request the VU makes; they last, like the
+	// response callback.
+	HTTPHooks *HTTPHooks
+
+	// Where secrets come from, if anywhere.
+	Secrets *secrets.Manager
+}
+
+// HTTPHooks are script functions called around every HTTP request: BeforeRequest with the
+// request about to be made, and AfterResponse with the response before it's returned.
+type HTTPHooks struct {
+	BeforeRequest goja.Callable
+	AfterResponse goja.Callable
+
+	// Requests in a batch are made in parallel, but only one of them can use the runtime at a time.
+	mu sync.Mutex
+}
+
+// Run runs fn, which calls the hooks, while no other request of the VU is calling them.
+func (h *HTTPHooks) Run(fn func() error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return fn()
+}
+
+// ResponseCallback tells whether an HTTP response with the given status is an expected one.
+// Requests that don't get one are tagged expected_response:false and counted in http_req_failed.
+type ResponseCallback func(status int) bool
+
+// DefaultResponseCallback expects 2xx and 3xx responses, like
+// http.expectedStatuses({min: 200, max: 399}) does.
+func DefaultResponseCallback(status int) bool {
+	return status >= 200 && status <= 399
+}
+
+// CloneTags returns a copy of the run tags merged with the tags set by the script.
+func (s *State) CloneTags() map[string]string {
+	tags := s.Options.RunTags.CloneTags()
+	for k, v := range s.VUTags {
+		tags[k] = v
+	}
+	for k, v := range s.Tags {
+		tags[k] = v
+	}
+	return tags
 }
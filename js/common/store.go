@@ -0,0 +1,91 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package common
+
+import (
+	"sort"
+
+	"github.com/dop251/goja"
+)
+
+// VUStore holds values the script keeps on its VU, eg. tokens or IDs correlated between requests,
+// so helpers and groups can pass them along without globals. Values last for the rest of the VU's
+// iterations, or only until the end of the current one. It belongs to the VU's instance of the
+// script, as the values do, so a VU that starts iterations from scratch starts with an empty store.
+type VUStore struct {
+	values map[string]goja.Value
+
+	// The keys to delete at the end of the iteration.
+	iteration map[string]bool
+}
+
+// NewVUStore returns an empty store.
+func NewVUStore() *VUStore {
+	return &VUStore{values: make(map[string]goja.Value), iteration: make(map[string]bool)}
+}
+
+// Set stores a value, for the rest of the VU's iterations, or until the end of the current one.
+func (s *VUStore) Set(key string, value goja.Value, iteration bool) {
+	s.values[key] = value
+	if iteration {
+		s.iteration[key] = true
+	} else {
+		delete(s.iteration, key)
+	}
+}
+
+// Get returns the stored value, and whether there is one.
+func (s *VUStore) Get(key string) (goja.Value, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Delete deletes a value, and returns whether there was one.
+func (s *VUStore) Delete(key string) bool {
+	_, ok := s.values[key]
+	delete(s.values, key)
+	delete(s.iteration, key)
+	return ok
+}
+
+// Keys returns the keys of the stored values, sorted.
+func (s *VUStore) Keys() []string {
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Clear deletes all values.
+func (s *VUStore) Clear() {
+	s.values = make(map[string]goja.Value)
+	s.iteration = make(map[string]bool)
+}
+
+// EndIteration deletes the values that were stored for the iteration that just ended.
+func (s *VUStore) EndIteration() {
+	for k := range s.iteration {
+		delete(s.values, k)
+	}
+	s.iteration = make(map[string]bool)
+}
@@ -106,8 +106,15 @@ func (c *Compiler) Transform(src, filename string) (code string, srcmap SourceMa
 	return code, srcmap, nil
 }
 
-// Compiles the program, first trying ES5, then ES6.
+// Compiles the program, first trying ES5, then ES6; TypeScript has its types stripped first.
 func (c *Compiler) Compile(src, filename string, pre, post string, strict bool) (*goja.Program, string, error) {
+	if IsTypeScript(filename) {
+		js, err := StripTypes(src, filename)
+		if err != nil {
+			return nil, src, err
+		}
+		src = js
+	}
 	return c.compile(src, filename, pre, post, strict, true)
 }
 
@@ -118,7 +125,18 @@ func (c *Compiler) compile(src, filename string, pre, post string, strict, tryBa
 		if tryBabel {
 			code, _, err := c.Transform(src, filename)
 			if err != nil {
-				return nil, code, err
+				// Babel doesn't know object spread, which composes scenarios imported from other
+				// scripts, so give it another go with just that lowered. If that doesn't help, the
+				// error is still the one of the script as it was written.
+				lowered, ok, lowerErr := LowerObjectSpread(src, filename)
+				if lowerErr != nil || !ok {
+					return nil, code, err
+				}
+				loweredCode, _, loweredErr := c.Transform(lowered, filename)
+				if loweredErr != nil {
+					return nil, code, err
+				}
+				code = loweredCode
 			}
 			return c.compile(code, filename, pre, post, strict, false)
 		}
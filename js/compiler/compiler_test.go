@@ -160,5 +160,28 @@ func TestCompile(t *testing.T) {
 > 1 | 1+(=>2)()
     |    ^ at <eval>:2:26853(114)`)
 		})
+
+		t.Run("ObjectSpread", func(t *testing.T) {
+			pgm, _, err := c.Compile("let a = { x: 1 };\nlet b = { ...a, y: 2 };\nb.x + b.y", "script.js", "", "", true)
+			if !assert.NoError(t, err) {
+				return
+			}
+			v, err := goja.New().RunProgram(pgm)
+			if assert.NoError(t, err) {
+				assert.Equal(t, int64(3), v.Export())
+			}
+
+			// Errors are those Babel reports for the script as it was written, where they are.
+			for _, src := range []string{
+				"let a = { x: 1 };\nlet b = { ...a, y: };",
+				"let a = { x: 1 };\nlet b = { ...a, y: a?.x };",
+			} {
+				_, _, err := c.Compile(src, "script.js", "", "", true)
+				_, _, babelErr := c.Transform(src, "script.js")
+				if assert.Error(t, babelErr) {
+					assert.EqualError(t, err, babelErr.Error())
+				}
+			}
+		})
 	})
 }
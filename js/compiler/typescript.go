@@ -0,0 +1,96 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/pkg/errors"
+)
+
+// IsTypeScript returns whether a file is TypeScript, by its extension.
+func IsTypeScript(filename string) bool {
+	switch path.Ext(filename) {
+	case ".ts", ".mts", ".cts":
+		return true
+	default:
+		return false
+	}
+}
+
+// StripTypes turns TypeScript into JavaScript with esbuild, which removes the types and turns the
+// few TypeScript-only constructs, like enums, into JavaScript ones. Syntax newer than ES2017 is
+// lowered too, so that Babel can take it from there, like it does with any other ES6 script; ES
+// modules are left to it as well. The types aren't checked, and lines don't stay where they were.
+func StripTypes(src, filename string) (string, error) {
+	return transform(src, filename, api.TransformOptions{Loader: api.LoaderTS, Target: api.ES2017})
+}
+
+// LowerObjectSpread lowers object rest and spread, which Babel's presets don't know, but which
+// scenarios imported from other scripts are composed with, into helper calls with esbuild. Other
+// syntax is left alone, except for logical assignment, which Babel doesn't know either and those
+// helpers use. Since esbuild doesn't keep lines where they were, it also reports whether the script
+// had any object rest or spread, so it can be left alone if it didn't.
+func LowerObjectSpread(src, filename string) (string, bool, error) {
+	printed, err := transform(src, filename, api.TransformOptions{
+		Loader:    api.LoaderJS,
+		Target:    api.ESNext,
+		Supported: map[string]bool{"logical-assignment": false},
+	})
+	if err != nil {
+		return "", false, err
+	}
+	lowered, err := transform(src, filename, api.TransformOptions{
+		Loader:    api.LoaderJS,
+		Target:    api.ESNext,
+		Supported: map[string]bool{"logical-assignment": false, "object-rest-spread": false},
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return lowered, lowered != printed, nil
+}
+
+func transform(src, filename string, opts api.TransformOptions) (string, error) {
+	opts.Sourcefile = filename
+	opts.LogLevel = api.LogLevelSilent
+	result := api.Transform(src, opts)
+	if len(result.Errors) > 0 {
+		msgs := make([]string, len(result.Errors))
+		for i, msg := range result.Errors {
+			msgs[i] = formatMessage(msg)
+		}
+		return "", errors.New(strings.Join(msgs, "\n"))
+	}
+	return string(result.Code), nil
+}
+
+// formatMessage formats an esbuild error like goja's syntax errors: file:line:column: text
+func formatMessage(msg api.Message) string {
+	if msg.Location == nil {
+		return msg.Text
+	}
+	loc := msg.Location
+	return fmt.Sprintf("%s:%d:%d: %s", loc.File, loc.Line, loc.Column+1, msg.Text)
+}
@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTypeScript(t *testing.T) {
+	testdata := map[string]bool{
+		"script.ts":      true,
+		"/a/b/script.ts": true,
+		"lib.mts":        true,
+		"lib.cts":        true,
+		"script.js":      false,
+		"script.d.ts.js": false,
+		"ts":             false,
+	}
+	for filename, expected := range testdata {
+		assert.Equal(t, expected, IsTypeScript(filename), filename)
+	}
+}
+
+func TestStripTypes(t *testing.T) {
+	t.Run("Types", func(t *testing.T) {
+		code, err := StripTypes(`
+interface User { name: string; age?: number }
+type ID = string | number;
+var user: User = { name: "a" } as User;
+function greet(u: User, id: ID): string { return u.name + id!; }
+`, "script.ts")
+		assert.NoError(t, err)
+		assert.NotContains(t, code, "interface")
+		assert.NotContains(t, code, ": string")
+		assert.Contains(t, code, "function greet(u, id)")
+	})
+	t.Run("Enum", func(t *testing.T) {
+		code, err := StripTypes(`enum Color { Red, Green }`, "script.ts")
+		assert.NoError(t, err)
+		assert.Contains(t, code, `Color2[Color2["Green"] = 1] = "Green"`)
+	})
+	t.Run("ESM", func(t *testing.T) {
+		code, err := StripTypes("import type { A } from \"./a.ts\";\nexport default function(a: A) {}\n", "script.ts")
+		assert.NoError(t, err)
+		assert.Equal(t, "export default function(a) {\n}\n", code)
+	})
+	t.Run("Lowered", func(t *testing.T) {
+		code, err := StripTypes(`var a: any; var b = a?.b ?? 1;`, "script.ts")
+		assert.NoError(t, err)
+		assert.NotContains(t, code, "?.")
+		assert.NotContains(t, code, "??")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := StripTypes("var a: = 1;", "/path/script.ts")
+		assert.EqualError(t, err, `/path/script.ts:1:8: Unexpected "="`)
+	})
+}
+
+func TestLowerObjectSpread(t *testing.T) {
+	t.Run("ObjectSpread", func(t *testing.T) {
+		code, ok, err := LowerObjectSpread("import { scenarios } from \"./lib.js\";\nexport let options = { scenarios: { ...scenarios, b: {} } };\n", "script.js")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NotContains(t, code, "...")
+		assert.NotContains(t, code, "||=")
+		assert.Contains(t, code, "import { scenarios } from \"./lib.js\";")
+		assert.Contains(t, code, "export let options")
+	})
+	t.Run("OtherSyntax", func(t *testing.T) {
+		// Only object spread is lowered, anything else Babel can't handle is left for it to report.
+		_, ok, err := LowerObjectSpread("let a = b?.c ?? 1;\nlet d = [...e];\n", "script.js")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := LowerObjectSpread("var a = ;", "/path/script.js")
+		assert.EqualError(t, err, `/path/script.js:1:9: Unexpected ";"`)
+	})
+}
+
+func TestCompileTypeScript(t *testing.T) {
+	c, err := New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	pgm, _, err := c.Compile(`var add = function(a: number, b: number): number { return a + b; }; add(1, 2)`,
+		"script.ts", "", "", true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	v, err := goja.New().RunProgram(pgm)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), v.Export())
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := c.Compile("var a: = 1;", "script.ts", "", "", true)
+		assert.EqualError(t, err, `script.ts:1:8: Unexpected "="`)
+	})
+	t.Run("JS", func(t *testing.T) {
+		_, _, err := c.Compile("var a: number = 1;", "script.js", "", "", true)
+		assert.Error(t, err)
+	})
+}
@@ -25,9 +25,15 @@ import (
 	"strconv"
 
 	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
 	log "github.com/sirupsen/logrus"
 )
 
+// ConsoleSource is the "source" field of the entries scripts log, which tells them apart from
+// k6's own; log outputs rate limit them by it.
+const ConsoleSource = "console"
+
 type Console struct {
 	Logger *log.Logger
 }
@@ -45,10 +51,26 @@ func (c Console) log(ctx *context.Context, level log.Level, msgobj goja.Value, a
 		}
 	}
 
-	fields := make(log.Fields)
+	// Objects are structured fields; other arguments are numbered ones, as they used to be.
+	fields := log.Fields{"source": ConsoleSource}
 	for i, arg := range args {
+		if obj, ok := arg.Export().(map[string]interface{}); ok {
+			for k, v := range obj {
+				fields[k] = v
+			}
+			continue
+		}
 		fields[strconv.Itoa(i)] = arg.String()
 	}
+	if ctx != nil && *ctx != nil {
+		if state := common.GetState(*ctx); state != nil {
+			fields["vu"] = state.Vu
+			fields["iter"] = state.Iteration
+		}
+		if sc := lib.GetScenario(*ctx); sc != nil {
+			fields["scenario"] = sc.Name
+		}
+	}
 	msg := msgobj.ToString()
 	e := c.Logger.WithFields(fields)
 	switch level {
@@ -46,6 +46,7 @@ func TestConsoleContext(t *testing.T) {
 	assert.NoError(t, err)
 	if entry := hook.LastEntry(); assert.NotNil(t, entry) {
 		assert.Equal(t, "a", entry.Message)
+		assert.Equal(t, log.Fields{"source": ConsoleSource}, entry.Data)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -64,6 +65,31 @@ func TestConsoleContext(t *testing.T) {
 	}
 }
 
+func TestConsoleFields(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	ctxPtr := new(context.Context)
+	*ctxPtr = common.WithState(context.Background(), &common.State{Vu: 3, Iteration: 7})
+	logger, hook := logtest.NewNullLogger()
+	rt.Set("console", common.Bind(rt, &Console{logger}, ctxPtr))
+
+	_, err := common.RunString(rt, `console.warn("login failed", { user: "alice", status: 401 }, "extra")`)
+	assert.NoError(t, err)
+	if entry := hook.LastEntry(); assert.NotNil(t, entry) {
+		assert.Equal(t, log.WarnLevel, entry.Level)
+		assert.Equal(t, "login failed", entry.Message)
+		assert.Equal(t, log.Fields{
+			"source": ConsoleSource,
+			"vu":     int64(3),
+			"iter":   int64(7),
+			"user":   "alice",
+			"status": int64(401),
+			"1":      "extra",
+		}, entry.Data)
+	}
+}
+
 func TestConsole(t *testing.T) {
 	levels := map[string]log.Level{
 		"log":   log.InfoLevel,
@@ -80,6 +106,9 @@ func TestConsole(t *testing.T) {
 		`"string","a","b"`: {Message: "string", Data: log.Fields{"0": "a", "1": "b"}},
 		`"string",1,2`:     {Message: "string", Data: log.Fields{"0": "1", "1": "2"}},
 		`{}`:               {Message: "[object Object]"},
+
+		`"string",{a:1,b:"c"}`:    {Message: "string", Data: log.Fields{"a": int64(1), "b": "c"}},
+		`"string","a",{b:true},1`: {Message: "string", Data: log.Fields{"0": "a", "b": true, "2": "1"}},
 	}
 	for name, level := range levels {
 		t.Run(name, func(t *testing.T) {
@@ -109,9 +138,9 @@ func TestConsole(t *testing.T) {
 						assert.Equal(t, level, entry.Level)
 						assert.Equal(t, result.Message, entry.Message)
 
-						data := result.Data
-						if data == nil {
-							data = make(log.Fields)
+						data := log.Fields{"source": ConsoleSource, "vu": int64(0), "iter": int64(0)}
+						for k, v := range result.Data {
+							data[k] = v
 						}
 						assert.Equal(t, data, entry.Data)
 					}
@@ -22,7 +22,16 @@ package js
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
@@ -38,6 +47,62 @@ type programWithSource struct {
 	src string
 }
 
+// The programs and files loaded by a bundle's init contexts. It's shared by all of its VUs, which
+// only read it, unless one of them loads something the bundle's first run didn't; so every script
+// is compiled, and every file read, once per test.
+type initCache struct {
+	mu       sync.RWMutex
+	programs map[string]programWithSource
+	files    map[string][]byte
+
+	// Files opened as text, converted to strings once, which the VUs' runtimes share.
+	texts map[string]string
+}
+
+func (c *initCache) program(filename string) (programWithSource, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pgm, ok := c.programs[filename]
+	return pgm, ok
+}
+
+func (c *initCache) setProgram(filename string, pgm programWithSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.programs[filename] = pgm
+}
+
+func (c *initCache) file(filename string) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	data, ok := c.files[filename]
+	return data, ok
+}
+
+func (c *initCache) setFile(filename string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[filename] = data
+}
+
+// text returns a cached file as a string, converting it the first time it's asked for.
+func (c *initCache) text(filename string) string {
+	c.mu.RLock()
+	text, ok := c.texts[filename]
+	c.mu.RUnlock()
+	if ok {
+		return text
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if text, ok = c.texts[filename]; !ok {
+		text = string(c.files[filename])
+		c.texts[filename] = text
+	}
+	return text
+}
+
 // Provides APIs for use in the init context.
 type InitContext struct {
 	// Bound runtime; used to instantiate objects.
@@ -50,9 +115,15 @@ type InitContext struct {
 	fs  afero.Fs
 	pwd string
 
-	// Cache of loaded programs and files.
-	programs map[string]programWithSource
-	files    map[string][]byte
+	// Cache of loaded programs and files, shared with the bundle's other init contexts.
+	*initCache
+
+	// Modules that have been run in this runtime, by filename, so that each only runs once and a
+	// circular import gets what the module has exported so far.
+	modules map[string]*goja.Object
+
+	// Experimental features that are enabled, by name.
+	features map[string]bool
 }
 
 func NewInitContext(rt *goja.Runtime, ctxPtr *context.Context, fs afero.Fs, pwd string) *InitContext {
@@ -62,8 +133,12 @@ func NewInitContext(rt *goja.Runtime, ctxPtr *context.Context, fs afero.Fs, pwd
 		fs:      fs,
 		pwd:     pwd,
 
-		programs: make(map[string]programWithSource),
-		files:    make(map[string][]byte),
+		initCache: &initCache{
+			programs: make(map[string]programWithSource),
+			files:    make(map[string][]byte),
+			texts:    make(map[string]string),
+		},
+		modules: make(map[string]*goja.Object),
 	}
 }
 
@@ -75,8 +150,9 @@ func newBoundInitContext(base *InitContext, ctxPtr *context.Context, rt *goja.Ru
 		fs:  nil,
 		pwd: base.pwd,
 
-		programs: base.programs,
-		files:    base.files,
+		initCache: base.initCache,
+		modules:   make(map[string]*goja.Object),
+		features:  base.features,
 	}
 }
 
@@ -102,16 +178,32 @@ func (i *InitContext) Require(arg string) goja.Value {
 
 func (i *InitContext) requireModule(name string) (goja.Value, error) {
 	mod, ok := modules.Index[name]
+	if !ok && strings.HasPrefix(name, modules.ExtensionPrefix) {
+		return nil, errors.Errorf("unknown extension module: %s, it has to be built into k6 to be used", name)
+	}
 	if !ok {
 		return nil, errors.Errorf("unknown builtin module: %s", name)
 	}
+	if feature, ok := modules.Experimental[name]; ok && !i.features[feature.Name] {
+		return nil, errors.Errorf(
+			"%s is an experimental module, run k6 with --enable=%s to use it; see %s",
+			name, feature.Name, feature.DocsURL,
+		)
+	}
 	return i.runtime.ToValue(common.Bind(i.runtime, mod, i.ctxPtr)), nil
 }
 
 func (i *InitContext) requireFile(name string) (goja.Value, error) {
 	// Resolve the file path, push the target directory as pwd to make relative imports work.
 	pwd := i.pwd
-	filename := loader.Resolve(pwd, name)
+	filename := loader.ResolveModule(initContextFS{i}, pwd, name)
+
+	// If the module has run already, or is still running because of a circular import, hand out
+	// what it has exported so far.
+	if module, ok := i.modules[filename]; ok {
+		return module.Get("exports"), nil
+	}
+
 	i.pwd = loader.Dir(filename)
 	defer func() { i.pwd = pwd }()
 
@@ -127,10 +219,10 @@ func (i *InitContext) requireFile(name string) (goja.Value, error) {
 	i.runtime.Set("module", module)
 
 	// First, check if we have a cached program already.
-	pgm, ok := i.programs[filename]
+	pgm, ok := i.program(filename)
 	if !ok {
 		// Load the sources; the loader takes care of remote loading, etc.
-		data, err := loader.Load(i.fs, pwd, name)
+		data, err := loader.Load(i.fs, pwd, filename)
 		if err != nil {
 			return goja.Undefined(), err
 		}
@@ -144,11 +236,13 @@ func (i *InitContext) requireFile(name string) (goja.Value, error) {
 
 		// Cache the compiled program.
 		pgm = programWithSource{pgm_, src}
-		i.programs[filename] = pgm
+		i.setProgram(filename, pgm)
 	}
 
-	// Run the program.
+	// Run the program; it's registered first, so modules it imports can import it back.
+	i.modules[filename] = module
 	if _, err := i.runtime.RunProgram(pgm.pgm); err != nil {
+		delete(i.modules, filename)
 		return goja.Undefined(), err
 	}
 
@@ -160,20 +254,171 @@ func (i *InitContext) compileImport(src, filename string) (*goja.Program, error)
 	return pgm, err
 }
 
+func (i *InitContext) resolve(name string) string {
+	return loader.Resolve(i.pwd, name)
+}
+
+// Lets the loader resolve imports against what's been loaded already, as well as the filesystem,
+// so that VUs and archives, which have no filesystem, resolve them the same way.
+type initContextFS struct{ *InitContext }
+
+func (c initContextFS) IsFile(filename string) bool {
+	if _, ok := c.program(filename); ok {
+		return true
+	}
+	if _, ok := c.file(filename); ok {
+		return true
+	}
+	if _, ok := c.modules[filename]; ok {
+		return true
+	}
+	if c.fs == nil {
+		return false
+	}
+	info, err := c.fs.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+func (c initContextFS) ReadFile(filename string) ([]byte, error) {
+	if data, ok := c.file(filename); ok {
+		return data, nil
+	}
+	if c.fs == nil {
+		return nil, os.ErrNotExist
+	}
+	data, err := afero.ReadFile(c.fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	c.setFile(filename, data)
+	return data, nil
+}
+
+// readFile loads a file, or returns it from the cache if it's been loaded already.
+func (i *InitContext) readFile(name string) ([]byte, error) {
+	filename := loader.Resolve(i.pwd, name)
+	if data, ok := i.file(filename); ok {
+		return data, nil
+	}
+	src, err := loader.Load(i.fs, i.pwd, name)
+	if err != nil {
+		return nil, err
+	}
+	i.setFile(filename, src.Data)
+	return src.Data, nil
+}
+
 func (i *InitContext) Open(name string, args ...string) (goja.Value, error) {
 	filename := loader.Resolve(i.pwd, name)
-	data, ok := i.files[filename]
-	if !ok {
-		data_, err := loader.Load(i.fs, i.pwd, name)
+	data, err := i.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The VUs share the file's data, and the string it's converted to once.
+	if len(args) > 0 && args[0] == "b" {
+		return i.runtime.ToValue(data), nil
+	}
+	return i.runtime.ToValue(i.text(filename)), nil
+}
+
+const (
+	defaultFetchDataTimeout = 10 * time.Second
+
+	// fetchData is for config and test data, not for downloading anything large into every archive.
+	maxFetchDataSize = 10 << 20
+)
+
+// FetchData makes a GET request in the init context, for data the test is configured with, like
+// feature flags or a test matrix. What it gets is cached like the files open() reads: it's fetched
+// once per test, every VU gets the same data, and archives keep it, so runs from them don't fetch
+// it again. It takes the same "b" mode as open(), and params with headers and a timeout in ms.
+func (i *InitContext) FetchData(rawurl string, args ...goja.Value) (goja.Value, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("fetchData() only supports http and https URLs, not %q", rawurl)
+	}
+	key := fetchDataKey(u)
+
+	var mode string
+	var params goja.Value
+	for _, arg := range args {
+		if goja.IsUndefined(arg) || goja.IsNull(arg) {
+			continue
+		}
+		if _, ok := arg.Export().(string); ok {
+			mode = arg.String()
+		} else {
+			params = arg
+		}
+	}
+
+	if _, ok := i.file(key); !ok {
+		data, err := i.fetchData(u, params)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrapf(err, "fetchData(%s)", rawurl)
 		}
-		i.files[filename] = data_.Data
-		data = data_.Data
+		i.setFile(key, data)
 	}
 
-	if len(args) > 0 && args[0] == "b" {
+	data, _ := i.file(key)
+	if mode == "b" {
 		return i.runtime.ToValue(data), nil
 	}
-	return i.runtime.ToValue(string(data)), nil
+	return i.runtime.ToValue(i.text(key)), nil
+}
+
+func (i *InitContext) fetchData(u *url.URL, params goja.Value) ([]byte, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: defaultFetchDataTimeout}
+	if params != nil {
+		obj := params.ToObject(i.runtime)
+		for _, k := range obj.Keys() {
+			switch k {
+			case "headers":
+				headers := obj.Get(k).ToObject(i.runtime)
+				for _, name := range headers.Keys() {
+					req.Header.Set(name, headers.Get(name).String())
+				}
+			case "timeout":
+				client.Timeout = time.Duration(obj.Get(k).ToFloat() * float64(time.Millisecond))
+			default:
+				return nil, errors.Errorf("unknown param %q", k)
+			}
+		}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, errors.Errorf("wrong status: %s", res.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxFetchDataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFetchDataSize {
+		return nil, errors.Errorf("the response is larger than the limit of %d bytes", maxFetchDataSize)
+	}
+	return data, nil
+}
+
+// fetchDataKey is the name fetched data is cached, and archived, under. Archives store files by
+// path, so it's made into one: the scheme and host are directories, and the query is escaped.
+func fetchDataKey(u *url.URL) string {
+	key := path.Clean(fmt.Sprintf("%s/%s/%s", u.Scheme, u.Host, u.EscapedPath()))
+	if u.RawQuery != "" {
+		key += "?" + url.PathEscape(u.RawQuery)
+	}
+	return key
 }
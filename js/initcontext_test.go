@@ -21,6 +21,7 @@
 package js
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -33,6 +34,7 @@ import (
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/js/modules"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/netext"
 	"github.com/oxtoacart/bpool"
@@ -41,6 +43,10 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+type testExtension struct{}
+
+func (testExtension) Hello(name string) string { return "hello " + name }
+
 func TestInitContextRequire(t *testing.T) {
 	t.Run("Modules", func(t *testing.T) {
 		t.Run("Nonexistent", func(t *testing.T) {
@@ -48,6 +54,31 @@ func TestInitContextRequire(t *testing.T) {
 			assert.EqualError(t, err, "GoError: unknown builtin module: k6/NONEXISTENT")
 		})
 
+		t.Run("Extension", func(t *testing.T) {
+			modules.Register("k6/x/test-initcontext", testExtension{})
+
+			_, err := getSimpleBundle("/script.js", `require("k6/x/nonexistent");`)
+			assert.EqualError(t, err, "GoError: unknown extension module: k6/x/nonexistent, it has to be built into k6 to be used")
+
+			b, err := getSimpleBundle("/script.js", `
+				var ext = require("k6/x/test-initcontext");
+				exports.default = function() {
+					if (ext.hello("k6") !== "hello k6") {
+						throw new Error("wrong greeting: " + ext.hello("k6"));
+					}
+				};
+			`)
+			if !assert.NoError(t, err) {
+				return
+			}
+			bi, err := b.Instantiate()
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = bi.Default(goja.Undefined())
+			assert.NoError(t, err)
+		})
+
 		t.Run("k6", func(t *testing.T) {
 			b, err := getSimpleBundle("/script.js", `
 					import k6 from "k6";
@@ -237,6 +268,84 @@ func TestInitContextRequire(t *testing.T) {
 			_, err = bi.Default(goja.Undefined())
 			assert.NoError(t, err)
 		})
+
+		t.Run("Resolution", func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			files := map[string]string{
+				"/path/node_modules/pkg/package.json": `{"main": "lib/main"}`,
+				"/path/node_modules/pkg/lib/main.js":  `exports.name = "pkg";`,
+				"/path/to/util/index.js":              `exports.name = "util";`,
+				"/path/to/helper.js":                  `exports.name = "helper";`,
+			}
+			for filename, data := range files {
+				assert.NoError(t, fs.MkdirAll(filepath.Dir(filename), 0755))
+				assert.NoError(t, afero.WriteFile(fs, filename, []byte(data), 0644))
+			}
+			b, err := NewBundle(&lib.SourceData{
+				Filename: "/path/to/script.js",
+				Data: []byte(`
+				var names = [require("pkg").name, require("./util").name, require("./helper").name];
+				exports.default = function() {
+					if (names.join() !== "pkg,util,helper") {
+						throw new Error("wrong modules: " + names.join());
+					}
+				};
+				`),
+			}, fs, lib.RuntimeOptions{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Contains(t, b.BaseInitContext.programs, "/path/node_modules/pkg/lib/main.js")
+			assert.Contains(t, b.BaseInitContext.files, "/path/node_modules/pkg/package.json")
+
+			bi, err := b.Instantiate()
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = bi.Default(goja.Undefined())
+			assert.NoError(t, err)
+		})
+
+		t.Run("Circular", func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			assert.NoError(t, afero.WriteFile(fs, "/a.js", []byte(`
+				exports.a = 1;
+				var b = require("./b.js");
+				exports.fromB = b.b;
+			`), 0644))
+			assert.NoError(t, afero.WriteFile(fs, "/b.js", []byte(`
+				var a = require("./a.js");
+				exports.b = 2;
+				exports.fromA = a.a;
+				exports.script = require("./script.js");
+			`), 0644))
+			b, err := NewBundle(&lib.SourceData{
+				Filename: "/script.js",
+				Data: []byte(`
+				var a = require("./a.js");
+				var b = require("./b.js");
+				var again = require("./b");
+				exports.default = function() {
+					if (a.fromB !== 2 || b.fromA !== 1) {
+						throw new Error("wrong exports: " + a.fromB + ", " + b.fromA);
+					}
+					if (b !== again || b.script !== exports) {
+						throw new Error("modules ran more than once");
+					}
+				};
+				`),
+			}, fs, lib.RuntimeOptions{})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			bi, err := b.Instantiate()
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = bi.Default(goja.Undefined())
+			assert.NoError(t, err)
+		})
 	})
 }
 
@@ -306,6 +415,112 @@ func TestInitContextOpenBinary(t *testing.T) {
 	assert.Equal(t, bytes, bi.Runtime.Get("data").Export())
 }
 
+func TestInitContextDataFiles(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, fs.MkdirAll("/path/to", 0755))
+	assert.NoError(t, afero.WriteFile(fs, "/path/to/users.csv", []byte("name\nalice\n"), 0644))
+
+	b, err := NewBundle(&lib.SourceData{
+		Filename: "/path/to/script.js",
+		Data: []byte(`
+		import { MappedFile } from "k6/data";
+		let users = new MappedFile("./users.csv");
+		export let name = users.line(1);
+		export default function() {}
+		`),
+	}, fs, lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The file is loaded from the bundle's filesystem, and archived along with the script.
+	arc := b.MakeArchive()
+	assert.Equal(t, []byte("name\nalice\n"), arc.Files["/path/to/users.csv"])
+
+	b, err = NewBundleFromArchive(arc, lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	bi, err := b.Instantiate()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "alice", bi.Runtime.Get("exports").ToObject(bi.Runtime).Get("name").String())
+}
+
+func TestInitContextFetchData(t *testing.T) {
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		switch r.URL.Path {
+		case "/flags.json":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"env":%q}`, r.URL.Query().Get("env"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	b, err := NewBundle(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+		export let flags = JSON.parse(fetchData("%s/flags.json?env=a/b", { headers: { Authorization: "Bearer token" } }));
+		export let raw = fetchData("%[1]s/flags.json?env=a/b", "b");
+		export default function() {}
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	bi, err := b.Instantiate()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]interface{}{"env": "a/b"}, bi.Runtime.Get("flags").Export())
+	assert.Equal(t, []byte(`{"env":"a/b"}`), bi.Runtime.Get("raw").Export())
+	assert.Equal(t, 1, fetches)
+
+	t.Run("archive", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		assert.NoError(t, b.MakeArchive().Write(buf))
+		arc, err := lib.ReadArchive(buf)
+		if !assert.NoError(t, err) {
+			return
+		}
+		b2, err := NewBundleFromArchive(arc, lib.RuntimeOptions{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		bi, err := b2.Instantiate()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, map[string]interface{}{"env": "a/b"}, bi.Runtime.Get("flags").Export())
+		assert.Equal(t, 1, fetches)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		testdata := map[string]string{
+			"status": `fetchData("` + srv.URL + `/nope")`,
+			"scheme": `fetchData("file:///etc/passwd")`,
+			"param":  `fetchData("` + srv.URL + `/flags.json", { method: "POST" })`,
+		}
+		for name, src := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := NewBundle(&lib.SourceData{
+					Filename: "/script.js",
+					Data:     []byte(src + `; export default function() {}`),
+				}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
 func TestRequestWithBinaryFile(t *testing.T) {
 	t.Parallel()
 
@@ -21,22 +21,104 @@
 package modules
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/loadimpact/k6/js/modules/k6"
+	"github.com/loadimpact/k6/js/modules/k6/amqp"
 	"github.com/loadimpact/k6/js/modules/k6/crypto"
+	"github.com/loadimpact/k6/js/modules/k6/data"
 	"github.com/loadimpact/k6/js/modules/k6/encoding"
+	"github.com/loadimpact/k6/js/modules/k6/execution"
+	"github.com/loadimpact/k6/js/modules/k6/experiments"
 	"github.com/loadimpact/k6/js/modules/k6/html"
 	"github.com/loadimpact/k6/js/modules/k6/http"
+	"github.com/loadimpact/k6/js/modules/k6/jwt"
 	"github.com/loadimpact/k6/js/modules/k6/metrics"
+	"github.com/loadimpact/k6/js/modules/k6/redis"
+	"github.com/loadimpact/k6/js/modules/k6/secrets"
+	"github.com/loadimpact/k6/js/modules/k6/soap"
+	"github.com/loadimpact/k6/js/modules/k6/sql"
+	"github.com/loadimpact/k6/js/modules/k6/sse"
 	"github.com/loadimpact/k6/js/modules/k6/ws"
+	"github.com/loadimpact/k6/js/modules/k6/xml"
 )
 
+// An ExperimentalFeature gates modules whose APIs may still change. Scripts can only import them
+// once the feature is enabled, eg. with --enable=name.
+type ExperimentalFeature struct {
+	Name        string
+	Description string
+	DocsURL     string
+}
+
+// Experimental maps the import paths of experimental modules to the features that gate them.
+// Experimental modules must also be in the Index.
+var Experimental = map[string]ExperimentalFeature{
+	"k6/soap": {
+		Name:        "soap",
+		Description: "SOAP clients built from WSDLs",
+		DocsURL:     "https://docs.k6.io/docs/k6soap",
+	},
+}
+
+// ExperimentalFeatures returns all the experimental features, by name.
+func ExperimentalFeatures() map[string]ExperimentalFeature {
+	features := make(map[string]ExperimentalFeature, len(Experimental))
+	for _, feature := range Experimental {
+		features[feature.Name] = feature
+	}
+	return features
+}
+
 // Index of module implementations.
 var Index = map[string]interface{}{
-	"k6":          k6.New(),
-	"k6/crypto":   crypto.New(),
-	"k6/encoding": encoding.New(),
-	"k6/http":     http.New(),
-	"k6/metrics":  metrics.New(),
-	"k6/html":     html.New(),
-	"k6/ws":       ws.New(),
+	"k6":             k6.New(),
+	"k6/amqp":        amqp.New(),
+	"k6/crypto":      crypto.New(),
+	"k6/data":        data.New(),
+	"k6/encoding":    encoding.New(),
+	"k6/execution":   execution.New(),
+	"k6/experiments": experiments.New(),
+	"k6/http":        http.New(),
+	"k6/jwt":         jwt.New(),
+	"k6/metrics":     metrics.New(),
+	"k6/html":        html.New(),
+	"k6/redis":       redis.New(),
+	"k6/secrets":     secrets.New(),
+	"k6/soap":        soap.New(),
+	"k6/sql":         sql.New(),
+	"k6/sse":         sse.New(),
+	"k6/ws":          ws.New(),
+	"k6/xml":         xml.New(),
+}
+
+// ExtensionPrefix is what the import paths of modules that aren't part of k6 itself start with,
+// so that they can never clash with built-in ones.
+const ExtensionPrefix = "k6/x/"
+
+var extensions []string
+
+// Register makes a module that isn't part of k6 itself importable by scripts, as name, which must
+// start with ExtensionPrefix. Like a built-in module, mod is bound to each VU's runtime, with its
+// exported methods and fields available to scripts, and methods taking a context.Context first
+// getting the VU's. Modules are compiled into k6 by importing their packages from main, and must
+// register themselves from init() functions; Register panics if name is invalid or taken.
+func Register(name string, mod interface{}) {
+	if !strings.HasPrefix(name, ExtensionPrefix) || len(name) == len(ExtensionPrefix) {
+		panic(fmt.Sprintf("the import path of a module extension must start with '%s': %s", ExtensionPrefix, name))
+	}
+	if _, ok := Index[name]; ok {
+		panic(fmt.Sprintf("a module named '%s' is already registered", name))
+	}
+	Index[name] = mod
+	extensions = append(extensions, name)
+}
+
+// Extensions returns the import paths of the registered module extensions, sorted.
+func Extensions() []string {
+	names := append([]string{}, extensions...)
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,56 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package modules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testExtension struct{}
+
+func (testExtension) Hello(name string) string { return "hello " + name }
+
+func TestRegister(t *testing.T) {
+	Register("k6/x/test-register", testExtension{})
+	assert.Equal(t, testExtension{}, Index["k6/x/test-register"])
+	assert.Contains(t, Extensions(), "k6/x/test-register")
+
+	assert.PanicsWithValue(t, "a module named 'k6/x/test-register' is already registered", func() {
+		Register("k6/x/test-register", testExtension{})
+	})
+	for _, name := range []string{"k6/http", "k6/x/", "my-module"} {
+		assert.PanicsWithValue(t, "the import path of a module extension must start with 'k6/x/': "+name, func() {
+			Register(name, testExtension{})
+		})
+	}
+	assert.NotContains(t, Extensions(), "k6/http")
+}
+
+func TestExperimental(t *testing.T) {
+	for name, feature := range Experimental {
+		assert.Contains(t, Index, name)
+		assert.NotEmpty(t, feature.Name, name)
+		assert.NotEmpty(t, feature.DocsURL, name)
+	}
+	assert.Equal(t, Experimental["k6/soap"], ExperimentalFeatures()["soap"])
+}
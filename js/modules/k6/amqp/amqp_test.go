@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package amqp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	// Find a port nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("amqp", common.Bind(rt, New(), &ctx))
+	rt.Set("url", "amqp://guest:guest@"+addr+"/")
+
+	_, err = common.RunString(rt, `var client = new amqp.Client(url);`)
+	require.NoError(t, err)
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `client.publish("", "queue", "hello")`)
+		assert.Contains(t, err.Error(), "the broker can only be used in VU code")
+	})
+
+	ctx = common.WithState(ctx, &common.State{Dialer: netext.NewDialer(net.Dialer{})})
+
+	t.Run("ConnectionRefused", func(t *testing.T) {
+		_, err := common.RunString(rt, `client.declareQueue("orders", { durable: true })`)
+		assert.Contains(t, err.Error(), "connection refused")
+	})
+
+	t.Run("BadURL", func(t *testing.T) {
+		_, err := common.RunString(rt, `new amqp.Client("http://localhost").get("orders")`)
+		assert.Contains(t, err.Error(), "AMQP scheme must be either 'amqp://' or 'amqps://'")
+	})
+}
+
+func TestParseOptions(t *testing.T) {
+	rt := goja.New()
+
+	flags, args := parseFlags(rt, goja.Undefined(), "durable")
+	assert.Equal(t, map[string]bool{}, flags)
+	assert.Nil(t, args)
+
+	v, err := rt.RunString(`({ durable: true, exclusive: 0, arguments: { "x-max-length": 10 } })`)
+	require.NoError(t, err)
+	flags, args = parseFlags(rt, v, "durable", "autoDelete", "exclusive")
+	assert.Equal(t, map[string]bool{"durable": true, "exclusive": false}, flags)
+	assert.Equal(t, amqp.Table{"x-max-length": int64(10)}, args)
+
+	assert.Equal(t, receiveOptions{autoAck: true, timeout: DefaultTimeout}, parseReceiveOptions(rt, goja.Undefined()))
+	v, err = rt.RunString(`({ autoAck: false, timeout: 250 })`)
+	require.NoError(t, err)
+	assert.Equal(t, receiveOptions{autoAck: false, timeout: 250 * time.Millisecond}, parseReceiveOptions(rt, v))
+}
@@ -24,6 +24,7 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
@@ -31,14 +32,18 @@ import (
 	"encoding/hex"
 	"errors"
 	"hash"
+	"strconv"
 
 	"golang.org/x/crypto/md4"
 	"golang.org/x/crypto/ripemd160"
 
+	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
 )
 
-type Crypto struct{}
+type Crypto struct {
+	Subtle *SubtleCrypto `js:"subtle,bind"`
+}
 
 type Hasher struct {
 	ctx context.Context
@@ -47,7 +52,84 @@ type Hasher struct {
 }
 
 func New() *Crypto {
-	return &Crypto{}
+	return &Crypto{Subtle: &SubtleCrypto{}}
+}
+
+// uint8View creates a Uint8Array over part of an ArrayBuffer. Typed arrays are provided by core-js,
+// and goja can't call a JS constructor from Go.
+var uint8View = goja.MustCompile(
+	"uint8View",
+	"(function(buffer, offset, length) { return new Uint8Array(buffer, offset, length); })",
+	true,
+)
+
+// GetRandomValues fills an array (or typed array) with cryptographically strong random bytes and
+// returns it, like the WebCrypto API's crypto.getRandomValues(). A typed array has the bytes of its
+// buffer filled, so every element is random over its whole width; other arrays get a random byte
+// per element.
+func (*Crypto) GetRandomValues(ctx context.Context, array goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	obj, ok := array.(*goja.Object)
+	if !ok {
+		return nil, errors.New("TypeError: getRandomValues() requires an array")
+	}
+
+	// Byte slices are filled in place.
+	if data, ok := obj.Export().([]byte); ok {
+		return obj, fillRandom(data)
+	}
+
+	buffer, ok := obj.Get("buffer").(*goja.Object)
+	if !ok {
+		return obj, setRandomBytes(rt, obj, int(obj.Get("length").ToInteger()))
+	}
+	offset, length := obj.Get("byteOffset").ToInteger(), obj.Get("byteLength").ToInteger()
+	if data, ok := buffer.Export().([]byte); ok {
+		if offset < 0 || length < 0 || offset+length > int64(len(data)) {
+			return nil, errors.New("RangeError: the typed array's view is out of its buffer's bounds")
+		}
+		return obj, fillRandom(data[offset : offset+length])
+	}
+	newView, err := rt.RunProgram(uint8View)
+	if err != nil {
+		return nil, err
+	}
+	fn, _ := goja.AssertFunction(newView)
+	view, err := fn(goja.Undefined(), buffer, rt.ToValue(offset), rt.ToValue(length))
+	if err != nil {
+		return nil, err
+	}
+	return obj, setRandomBytes(rt, view.ToObject(rt), int(length))
+}
+
+func checkRandomQuota(length int) error {
+	if length > 65536 {
+		return errors.New("QuotaExceededError: can't generate more than 65536 random bytes at a time")
+	}
+	return nil
+}
+
+// fillRandom fills data with random bytes.
+func fillRandom(data []byte) error {
+	if err := checkRandomQuota(len(data)); err != nil {
+		return err
+	}
+	_, err := rand.Read(data)
+	return err
+}
+
+// setRandomBytes sets the first length elements of an array-like object to random bytes.
+func setRandomBytes(rt *goja.Runtime, obj *goja.Object, length int) error {
+	buf := make([]byte, length)
+	if err := fillRandom(buf); err != nil {
+		return err
+	}
+	for i, b := range buf {
+		if err := obj.Set(strconv.Itoa(i), rt.ToValue(b)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *Crypto) Md4(ctx context.Context, input []byte, outputEncoding string) string {
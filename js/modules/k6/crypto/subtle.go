@@ -0,0 +1,385 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// SubtleCrypto implements a subset of the WebCrypto API's crypto.subtle: digest, HMAC sign/verify
+// and AES-GCM encrypt/decrypt, along with the key management needed to use them.
+//
+// k6 doesn't have an event loop, so all methods do their work synchronously and return an already
+// settled promise-like object, whose then() and catch() callbacks are invoked right away.
+type SubtleCrypto struct{}
+
+// CryptoKey is an opaque key usable with the SubtleCrypto methods.
+type CryptoKey struct {
+	Type        string                 `js:"type"`
+	Extractable bool                   `js:"extractable"`
+	Algorithm   map[string]interface{} `js:"algorithm"`
+	Usages      []string               `js:"usages"`
+
+	hash func() hash.Hash
+	raw  []byte
+}
+
+// Digest hashes data with SHA-1, SHA-256, SHA-384 or SHA-512.
+func (*SubtleCrypto) Digest(ctx context.Context, algorithm goja.Value, data []byte) goja.Value {
+	return settle(ctx, func() (interface{}, error) {
+		newHash, err := hashFunc(algorithmName(algorithm))
+		if err != nil {
+			return nil, err
+		}
+		h := newHash()
+		_, _ = h.Write(data)
+		return h.Sum(nil), nil
+	})
+}
+
+// GenerateKey generates a random HMAC or AES-GCM key.
+func (*SubtleCrypto) GenerateKey(
+	ctx context.Context, algorithm goja.Value, extractable bool, usages []string,
+) goja.Value {
+	return settle(ctx, func() (interface{}, error) {
+		key, err := newKey(algorithm, extractable, usages, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := rand.Read(key.raw); err != nil {
+			return nil, err
+		}
+		return key, nil
+	})
+}
+
+// ImportKey imports raw HMAC or AES-GCM key material.
+func (*SubtleCrypto) ImportKey(
+	ctx context.Context, format string, keyData []byte, algorithm goja.Value, extractable bool, usages []string,
+) goja.Value {
+	return settle(ctx, func() (interface{}, error) {
+		if format != "raw" {
+			return nil, errors.Errorf("NotSupportedError: unsupported key format: %s", format)
+		}
+		return newKey(algorithm, extractable, usages, keyData)
+	})
+}
+
+// ExportKey returns the raw key material of an extractable key.
+func (*SubtleCrypto) ExportKey(ctx context.Context, format string, key *CryptoKey) goja.Value {
+	return settle(ctx, func() (interface{}, error) {
+		if format != "raw" {
+			return nil, errors.Errorf("NotSupportedError: unsupported key format: %s", format)
+		}
+		if key == nil {
+			return nil, errors.New("TypeError: key must be a CryptoKey")
+		}
+		if !key.Extractable {
+			return nil, errors.New("InvalidAccessError: key is not extractable")
+		}
+		return append([]byte{}, key.raw...), nil
+	})
+}
+
+// Sign computes an HMAC signature of data.
+func (*SubtleCrypto) Sign(ctx context.Context, algorithm goja.Value, key *CryptoKey, data []byte) goja.Value {
+	return settle(ctx, func() (interface{}, error) {
+		if err := checkKey(algorithm, key, "HMAC", "sign"); err != nil {
+			return nil, err
+		}
+		mac := hmac.New(key.hash, key.raw)
+		_, _ = mac.Write(data)
+		return mac.Sum(nil), nil
+	})
+}
+
+// Verify checks an HMAC signature of data.
+func (*SubtleCrypto) Verify(
+	ctx context.Context, algorithm goja.Value, key *CryptoKey, signature, data []byte,
+) goja.Value {
+	return settle(ctx, func() (interface{}, error) {
+		if err := checkKey(algorithm, key, "HMAC", "verify"); err != nil {
+			return nil, err
+		}
+		mac := hmac.New(key.hash, key.raw)
+		_, _ = mac.Write(data)
+		return hmac.Equal(signature, mac.Sum(nil)), nil
+	})
+}
+
+// Encrypt encrypts data with AES-GCM.
+func (*SubtleCrypto) Encrypt(ctx context.Context, algorithm goja.Value, key *CryptoKey, data []byte) goja.Value {
+	rt := common.GetRuntime(ctx)
+	return settle(ctx, func() (interface{}, error) {
+		if err := checkKey(algorithm, key, "AES-GCM", "encrypt"); err != nil {
+			return nil, err
+		}
+		aead, iv, additionalData, err := gcmParams(rt, algorithm, key)
+		if err != nil {
+			return nil, err
+		}
+		return aead.Seal(nil, iv, data, additionalData), nil
+	})
+}
+
+// Decrypt decrypts and authenticates data with AES-GCM.
+func (*SubtleCrypto) Decrypt(ctx context.Context, algorithm goja.Value, key *CryptoKey, data []byte) goja.Value {
+	rt := common.GetRuntime(ctx)
+	return settle(ctx, func() (interface{}, error) {
+		if err := checkKey(algorithm, key, "AES-GCM", "decrypt"); err != nil {
+			return nil, err
+		}
+		aead, iv, additionalData, err := gcmParams(rt, algorithm, key)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := aead.Open(nil, iv, data, additionalData)
+		if err != nil {
+			return nil, errors.New("OperationError: decryption failed")
+		}
+		return plaintext, nil
+	})
+}
+
+// algorithmName returns the normalized name of an algorithm given either as a string or as an
+// object with a name property.
+func algorithmName(algorithm goja.Value) string {
+	if goja.IsUndefined(algorithm) || goja.IsNull(algorithm) {
+		return ""
+	}
+	if obj, ok := algorithm.(*goja.Object); ok {
+		if name := obj.Get("name"); name != nil {
+			return strings.ToUpper(name.String())
+		}
+		return ""
+	}
+	return strings.ToUpper(algorithm.String())
+}
+
+// algorithmParam returns a property of an algorithm object, or nil if it isn't set.
+func algorithmParam(algorithm goja.Value, name string) goja.Value {
+	obj, ok := algorithm.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	v := obj.Get(name)
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	return v
+}
+
+func hashFunc(name string) (func() hash.Hash, error) {
+	switch name {
+	case "SHA-1":
+		return sha1.New, nil
+	case "SHA-256":
+		return sha256.New, nil
+	case "SHA-384":
+		return sha512.New384, nil
+	case "SHA-512":
+		return sha512.New, nil
+	default:
+		return nil, errors.Errorf("NotSupportedError: unsupported hash algorithm: %s", name)
+	}
+}
+
+// newKey creates a key for the given algorithm. If raw is nil, key material of the right length is
+// allocated for the caller to fill in.
+func newKey(algorithm goja.Value, extractable bool, usages []string, raw []byte) (*CryptoKey, error) {
+	key := &CryptoKey{Type: "secret", Extractable: extractable, Usages: usages}
+	if key.Usages == nil {
+		key.Usages = []string{}
+	}
+
+	switch name := algorithmName(algorithm); name {
+	case "HMAC":
+		hashV := algorithmParam(algorithm, "hash")
+		if hashV == nil {
+			return nil, errors.New("TypeError: HMAC keys require a hash algorithm")
+		}
+		hashName := algorithmName(hashV)
+		newHash, err := hashFunc(hashName)
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			length := newHash().BlockSize()
+			if l := algorithmParam(algorithm, "length"); l != nil {
+				length = int(l.ToInteger()+7) / 8
+			}
+			raw = make([]byte, length)
+		}
+		if len(raw) == 0 {
+			return nil, errors.New("DataError: HMAC keys must not be empty")
+		}
+		key.hash = newHash
+		key.Algorithm = map[string]interface{}{
+			"name":   name,
+			"hash":   map[string]interface{}{"name": hashName},
+			"length": len(raw) * 8,
+		}
+	case "AES-GCM":
+		if raw == nil {
+			l := algorithmParam(algorithm, "length")
+			if l == nil {
+				return nil, errors.New("TypeError: AES-GCM keys require a length")
+			}
+			raw = make([]byte, l.ToInteger()/8)
+		}
+		if n := len(raw); n != 16 && n != 24 && n != 32 {
+			return nil, errors.New("DataError: AES-GCM keys must be 128, 192 or 256 bits long")
+		}
+		key.Algorithm = map[string]interface{}{"name": name, "length": len(raw) * 8}
+	default:
+		return nil, errors.Errorf("NotSupportedError: unsupported algorithm: %s", name)
+	}
+
+	key.raw = raw
+	return key, nil
+}
+
+func checkKey(algorithm goja.Value, key *CryptoKey, name, usage string) error {
+	if key == nil {
+		return errors.New("TypeError: key must be a CryptoKey")
+	}
+	if algorithmName(algorithm) != name || key.Algorithm["name"] != name {
+		return errors.Errorf("InvalidAccessError: key can't be used with %s", algorithmName(algorithm))
+	}
+	for _, u := range key.Usages {
+		if u == usage {
+			return nil
+		}
+	}
+	return errors.Errorf("InvalidAccessError: key usages don't permit %s", usage)
+}
+
+func gcmParams(rt *goja.Runtime, algorithm goja.Value, key *CryptoKey) (cipher.AEAD, []byte, []byte, error) {
+	var iv, additionalData []byte
+	ivV := algorithmParam(algorithm, "iv")
+	if ivV == nil {
+		return nil, nil, nil, errors.New("TypeError: AES-GCM requires an iv")
+	}
+	if err := rt.ExportTo(ivV, &iv); err != nil {
+		return nil, nil, nil, err
+	}
+	if v := algorithmParam(algorithm, "additionalData"); v != nil {
+		if err := rt.ExportTo(v, &additionalData); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	tagLength := 128
+	if v := algorithmParam(algorithm, "tagLength"); v != nil {
+		tagLength = int(v.ToInteger())
+	}
+	if tagLength < 96 || tagLength > 128 || tagLength%8 != 0 {
+		return nil, nil, nil, errors.Errorf("OperationError: unsupported tag length: %d", tagLength)
+	}
+	if len(iv) == 0 {
+		return nil, nil, nil, errors.New("OperationError: iv must not be empty")
+	}
+
+	block, err := aes.NewCipher(key.raw)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if tagLength != 128 {
+		if len(iv) != 12 {
+			return nil, nil, nil, errors.New("OperationError: short tags require a 96-bit iv")
+		}
+		if aead, err = cipher.NewGCMWithTagSize(block, tagLength/8); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return aead, iv, additionalData, nil
+}
+
+// settle runs fn and wraps its result in a promise-like object.
+func settle(ctx context.Context, fn func() (interface{}, error)) goja.Value {
+	rt := common.GetRuntime(ctx)
+	rejections := common.GetRejections(ctx)
+	v, err := fn()
+	if err != nil {
+		return newSettled(rt, rejections, nil, rt.NewGoError(err))
+	}
+	return newSettled(rt, rejections, rt.ToValue(v), nil)
+}
+
+// newSettled returns a promise-like object that's fulfilled with value, or rejected with reason if
+// it's non-nil. Rejections are added to the tracker, if there is one, until a handler is attached,
+// so they don't go unnoticed.
+func newSettled(rt *goja.Runtime, rejections *common.Rejections, value, reason goja.Value) *goja.Object {
+	var rejection *common.Rejection
+	if reason != nil && rejections != nil {
+		rejection = rejections.Add(reason)
+	}
+
+	obj := rt.NewObject()
+	then := func(onFulfilled, onRejected goja.Value) goja.Value {
+		arg, cbV := value, onFulfilled
+		if reason != nil {
+			arg, cbV = reason, onRejected
+		}
+		cb, ok := goja.AssertFunction(cbV)
+		if !ok {
+			return obj
+		}
+		if rejection != nil {
+			rejection.Handle()
+		}
+		ret, err := cb(goja.Undefined(), arg)
+		if err != nil {
+			if ex, ok := err.(*goja.Exception); ok {
+				return newSettled(rt, rejections, nil, ex.Value())
+			}
+			return newSettled(rt, rejections, nil, rt.NewGoError(err))
+		}
+		// Adopt returned thenables, like a real promise would.
+		if retObj, ok := ret.(*goja.Object); ok {
+			if _, ok := goja.AssertFunction(retObj.Get("then")); ok {
+				return retObj
+			}
+		}
+		return newSettled(rt, rejections, ret, nil)
+	}
+	_ = obj.Set("then", then)
+	_ = obj.Set("catch", func(onRejected goja.Value) goja.Value {
+		return then(goja.Undefined(), onRejected)
+	})
+	return obj
+}
@@ -0,0 +1,215 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package crypto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtleCrypto(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := context.Background()
+	ctx = common.WithRuntime(ctx, rt)
+	rt.Set("crypto", common.Bind(rt, New(), &ctx))
+
+	_, err := common.RunString(rt, `
+	function hex(bytes) {
+		let s = "";
+		for (let i = 0; i < bytes.length; i++) {
+			s += (bytes[i] < 16 ? "0" : "") + bytes[i].toString(16);
+		}
+		return s;
+	}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("Digest", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		const correct = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9";
+		let result;
+		crypto.subtle.digest("SHA-256", "hello world").then(function(digest) { result = hex(digest); });
+		if (result !== correct) {
+			throw new Error("Digest mismatch: " + result);
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("DigestUnsupported", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let rejected;
+		crypto.subtle.digest({ name: "MD5" }, "hello world").catch(function(e) { rejected = e; });
+		if (!rejected || String(rejected).indexOf("NotSupportedError") === -1) {
+			throw new Error("Unexpected rejection: " + rejected);
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("HMAC", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		const correct = "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8";
+		let signature, valid, invalid;
+		crypto.subtle.importKey("raw", "key", { name: "HMAC", hash: "SHA-256" }, false, ["sign", "verify"])
+			.then(function(key) {
+				return crypto.subtle.sign("HMAC", key, "The quick brown fox jumps over the lazy dog")
+					.then(function(sig) {
+						signature = hex(sig);
+						invalid = crypto.subtle.verify("HMAC", key, sig, "tampered");
+						return crypto.subtle.verify("HMAC", key, sig, "The quick brown fox jumps over the lazy dog");
+					});
+			})
+			.then(function(result) { valid = result; });
+		invalid.then(function(result) { invalid = result; });
+		if (signature !== correct || valid !== true || invalid !== false) {
+			throw new Error("Unexpected results: " + signature + " " + valid + " " + invalid);
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("AES-GCM", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let iv = crypto.getRandomValues(new Array(12));
+		let key, ciphertext, plaintext, failure;
+		crypto.subtle.generateKey({ name: "AES-GCM", length: 256 }, true, ["encrypt", "decrypt"])
+			.then(function(k) { key = k; });
+		if (key.algorithm.length !== 256 || key.extractable !== true) {
+			throw new Error("Unexpected key: " + JSON.stringify(key.algorithm));
+		}
+
+		let params = { name: "AES-GCM", iv: iv, additionalData: "aad" };
+		crypto.subtle.encrypt(params, key, "secret message").then(function(c) { ciphertext = c; });
+		crypto.subtle.decrypt(params, key, ciphertext).then(function(p) {
+			plaintext = String.fromCharCode.apply(null, p);
+		});
+		if (plaintext !== "secret message") {
+			throw new Error("Plaintext mismatch: " + plaintext);
+		}
+
+		ciphertext[0] ^= 1;
+		crypto.subtle.decrypt(params, key, ciphertext).catch(function(e) { failure = String(e); });
+		if (failure.indexOf("OperationError") === -1) {
+			throw new Error("Tampered ciphertext was decrypted: " + failure);
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("KeyUsages", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let failure;
+		crypto.subtle.importKey("raw", "0123456789abcdef", "AES-GCM", false, ["encrypt"])
+			.then(function(key) {
+				crypto.subtle.exportKey("raw", key).catch(function(e) { failure = String(e); });
+				if (failure.indexOf("not extractable") === -1) {
+					throw new Error("Exported a non-extractable key: " + failure);
+				}
+				return crypto.subtle.decrypt({ name: "AES-GCM", iv: "0123456789ab" }, key, "");
+			})
+			.catch(function(e) { failure = String(e); });
+		if (failure.indexOf("don't permit decrypt") === -1) {
+			throw new Error("Unexpected failure: " + failure);
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("GetRandomValues", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let values = crypto.getRandomValues(new Array(64));
+		let nonZero = false;
+		for (let i = 0; i < values.length; i++) {
+			if (typeof values[i] !== "number" || values[i] < 0 || values[i] > 255) {
+				throw new Error("Invalid value: " + values[i]);
+			}
+			nonZero = nonZero || values[i] !== 0;
+		}
+		if (!nonZero) {
+			throw new Error("No random values");
+		}`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `crypto.getRandomValues(new Array(65537));`)
+		assert.Contains(t, err.Error(), "QuotaExceededError")
+
+		t.Run("Bytes", func(t *testing.T) {
+			data := make([]byte, 32)
+			rt.Set("data", data)
+			_, err := common.RunString(rt, `crypto.getRandomValues(data);`)
+			assert.NoError(t, err)
+			assert.NotEqual(t, make([]byte, 32), data)
+		})
+
+		t.Run("TypedArray", func(t *testing.T) {
+			// A Uint32Array over bytes 4-11 of its buffer.
+			data := make([]byte, 16)
+			rt.Set("view", map[string]interface{}{"buffer": data, "byteOffset": 4, "byteLength": 8, "length": 2})
+			_, err := common.RunString(rt, `crypto.getRandomValues(view);`)
+			assert.NoError(t, err)
+			assert.Equal(t, make([]byte, 4), data[:4])
+			assert.NotEqual(t, make([]byte, 8), data[4:12])
+			assert.Equal(t, make([]byte, 4), data[12:])
+		})
+
+		t.Run("PolyfilledTypedArray", func(t *testing.T) {
+			_, err := common.RunString(rt, `
+			var buffer = { bytes: [0, 0, 0, 0, 0, 0, 0, 0] };
+			this.Uint8Array = function(buffer, offset, length) {
+				for (var i = 0; i < length; i++) {
+					(function(i) {
+						Object.defineProperty(this, i, { set: function(v) { buffer.bytes[offset + i] = v; } });
+					}).call(this, i);
+				}
+			};
+			crypto.getRandomValues({ buffer: buffer, byteOffset: 2, byteLength: 4, length: 2 });
+			delete this.Uint8Array;
+			var bytes = buffer.bytes;
+			if (bytes[0] !== 0 || bytes[1] !== 0 || bytes[6] !== 0 || bytes[7] !== 0) {
+				throw new Error("Bytes outside of the view were written: " + bytes);
+			}
+			if (bytes[2] + bytes[3] + bytes[4] + bytes[5] === 0) {
+				throw new Error("No random values: " + bytes);
+			}`)
+			assert.NoError(t, err)
+		})
+	})
+	t.Run("UnhandledRejection", func(t *testing.T) {
+		rejections := &common.Rejections{}
+		ctx = common.WithRejections(ctx, rejections)
+		defer func() { ctx = common.WithRejections(ctx, nil) }()
+
+		_, err := common.RunString(rt, `
+		crypto.subtle.digest("MD5", "a").catch(function() {});
+		crypto.subtle.digest("SHA-1", "b").then(function() { throw new Error("handled"); }).then(null, function() {});`)
+		assert.NoError(t, err)
+		assert.NoError(t, rejections.Err())
+
+		_, err = common.RunString(rt, `
+		crypto.subtle.digest("SHA-1", "c").then(function() { throw new Error("unhandled"); });
+		crypto.subtle.digest("MD5", "d").then(function() {});`)
+		assert.NoError(t, err)
+		assert.EqualError(t, rejections.Err(), "Uncaught (in promise) Error: unhandled")
+		assert.NoError(t, rejections.Err())
+	})
+}
@@ -0,0 +1,270 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// csvOptions are the options taken by the CSV functions: delimiter, comment, header,
+// trimLeadingSpace and lazyQuotes.
+type csvOptions struct {
+	Delimiter        rune
+	Comment          rune
+	Header           bool
+	TrimLeadingSpace bool
+	LazyQuotes       bool
+}
+
+func parseCSVOptions(rt *goja.Runtime, v goja.Value) (csvOptions, error) {
+	opts := csvOptions{Delimiter: ','}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return opts, nil
+	}
+	obj := v.ToObject(rt)
+	for _, k := range obj.Keys() {
+		var err error
+		switch k {
+		case "delimiter":
+			opts.Delimiter, err = parseCSVRune(k, obj.Get(k).String())
+		case "comment":
+			opts.Comment, err = parseCSVRune(k, obj.Get(k).String())
+		case "header":
+			opts.Header = obj.Get(k).ToBoolean()
+		case "trimLeadingSpace":
+			opts.TrimLeadingSpace = obj.Get(k).ToBoolean()
+		case "lazyQuotes":
+			opts.LazyQuotes = obj.Get(k).ToBoolean()
+		}
+		if err != nil {
+			return opts, err
+		}
+	}
+	if opts.Delimiter == opts.Comment {
+		return opts, errors.New("the CSV delimiter and comment characters must be different")
+	}
+	return opts, nil
+}
+
+func parseCSVRune(name, s string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, errors.Errorf("invalid CSV %s: %q", name, s)
+	}
+	return r, nil
+}
+
+// csvDecoder reads CSV records and encodes them as JSON; an array of fields, or an object keyed
+// by the header if there is one.
+type csvDecoder struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVDecoder(r io.Reader, opts csvOptions) (*csvDecoder, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = opts.Delimiter
+	cr.Comment = opts.Comment
+	cr.TrimLeadingSpace = opts.TrimLeadingSpace
+	cr.LazyQuotes = opts.LazyQuotes
+	cr.FieldsPerRecord = -1
+
+	dec := &csvDecoder{r: cr}
+	if opts.Header {
+		header, err := cr.Read()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		dec.header = header
+		// Every row must match the header.
+		cr.FieldsPerRecord = len(header)
+	}
+	return dec, nil
+}
+
+// next returns the next record, or io.EOF once there are no more.
+func (dec *csvDecoder) next() (json.RawMessage, error) {
+	record, err := dec.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if dec.header == nil {
+		return json.Marshal(record)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range record {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(dec.header[i])
+		value, _ := json.Marshal(field)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// all returns every remaining record.
+func (dec *csvDecoder) all() ([]json.RawMessage, error) {
+	var rows []json.RawMessage
+	for {
+		row, err := dec.next()
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// ParseCSV parses a CSV string into an array of rows. Rows are arrays of fields, or objects keyed
+// by the first row if the header option is set.
+func (*Data) ParseCSV(ctx context.Context, text string, options goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	opts, err := parseCSVOptions(rt, options)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := newCSVDecoder(strings.NewReader(text), opts)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := dec.all()
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = []json.RawMessage{}
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	return parseJSON(rt, data)
+}
+
+// SharedCSV returns a SharedArray holding the rows of a local CSV file. The first VU to ask for
+// a name parses the file; every other VU gets to read the same copy. Paths are resolved like
+// open() does.
+func (d *Data) SharedCSV(ctxPtr *context.Context, name, filename string, options goja.Value) (interface{}, error) {
+	env := common.GetInitEnv(*ctxPtr)
+	if env == nil || common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("sharedCSV must be called in the init context")
+	}
+	if name == "" {
+		return nil, errors.New("sharedCSV needs a name")
+	}
+	rt := common.GetRuntime(*ctxPtr)
+
+	arr, err := d.sharedArray(name, func() (*sharedArray, error) {
+		opts, err := parseCSVOptions(rt, options)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readLocal(env, "sharedCSV", filename)
+		if err != nil {
+			return nil, err
+		}
+		dec, err := newCSVDecoder(bytes.NewReader(data), opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "sharedCSV '%s'", name)
+		}
+		rows, err := dec.all()
+		if err != nil {
+			return nil, errors.Wrapf(err, "sharedCSV '%s'", name)
+		}
+		return &sharedArray{elements: rows}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return common.Bind(rt, &SharedArray{arr: arr, Length: len(arr.elements)}, ctxPtr), nil
+}
+
+// A CSVReader reads the rows of a local CSV file one at a time. The file is loaded once, like
+// open() does, and shared by all VUs; every VU that constructs a reader gets its own position.
+type CSVReader struct {
+	dec    *csvDecoder
+	closed bool
+
+	Header []string `js:"header"`
+}
+
+// XCSVReader opens a local CSV file for reading. Paths are resolved like open() does.
+func (*Data) XCSVReader(ctxPtr *context.Context, filename string, options goja.Value) (interface{}, error) {
+	env := common.GetInitEnv(*ctxPtr)
+	if env == nil || common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("CSVReader must be constructed in the init context")
+	}
+	rt := common.GetRuntime(*ctxPtr)
+	opts, err := parseCSVOptions(rt, options)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readLocal(env, "CSVReader", filename)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := newCSVDecoder(bytes.NewReader(data), opts)
+	if err != nil {
+		return nil, err
+	}
+	return common.Bind(rt, &CSVReader{dec: dec, Header: dec.header}, ctxPtr), nil
+}
+
+// ReadRow returns the next row, or null at the end of the file.
+func (cr *CSVReader) ReadRow(ctx context.Context) (goja.Value, error) {
+	if cr.closed {
+		return nil, os.ErrClosed
+	}
+	row, err := cr.dec.next()
+	if err == io.EOF {
+		return goja.Null(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseJSON(common.GetRuntime(ctx), row)
+}
+
+// Close closes the reader; it can't be read from afterwards.
+func (cr *CSVReader) Close() error {
+	if cr.closed {
+		return os.ErrClosed
+	}
+	cr.closed = true
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	rt, _ := newRuntime(New())
+	rt.Set("text", "name,note\nalice,\"likes \"\"quotes\"\", and commas\"\n\nbob,\"multi\nline\"\n")
+
+	t.Run("Rows", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var rows = data.parseCSV(text);
+		if (!Array.isArray(rows) || !Array.isArray(rows[0])) { throw new Error("rows aren't arrays"); }
+		var expected = '[["name","note"],["alice","likes \\"quotes\\", and commas"],["bob","multi\\nline"]]';
+		if (JSON.stringify(rows) !== expected) { throw new Error("wrong rows: " + JSON.stringify(rows)); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var rows = data.parseCSV(text, { header: true });
+		if (rows.length !== 2 || rows[1].name !== "bob" || rows[1].note !== "multi\nline") {
+			throw new Error("wrong rows: " + JSON.stringify(rows));
+		}
+		if (Object.keys(rows[0]).join() !== "name,note") { throw new Error("wrong key order"); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var rows = data.parseCSV("# users\na; b\nc;d", { delimiter: ";", comment: "#", trimLeadingSpace: true });
+		if (JSON.stringify(rows) !== '[["a","b"],["c","d"]]') { throw new Error("wrong rows: " + JSON.stringify(rows)); }
+		if (data.parseCSV("").length !== 0) { throw new Error("empty input has rows"); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := common.RunString(rt, `data.parseCSV("a,b\nc", { header: true });`)
+		assert.Contains(t, err.Error(), "wrong number of fields")
+		_, err = common.RunString(rt, `data.parseCSV("a,\"b", {});`)
+		assert.Contains(t, err.Error(), "extraneous or missing \" in quoted-field")
+		_, err = common.RunString(rt, `data.parseCSV("a", { delimiter: "::" });`)
+		assert.Contains(t, err.Error(), `invalid CSV delimiter: "::"`)
+	})
+}
+
+func TestCSVFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-data")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "users.csv"), []byte("name\tage\nalice\t30\r\nbob\t40\n"), 0644))
+
+	d := New()
+	resolve := func(name string) string { return filepath.Join(dir, name) }
+	env := &common.InitEnvironment{Resolve: resolve, ReadFile: func(name string) ([]byte, error) {
+		return ioutil.ReadFile(resolve(name))
+	}}
+	rt1, ctxPtr1 := newRuntime(d)
+	*ctxPtr1 = common.WithInitEnv(*ctxPtr1, env)
+	rt2, ctxPtr2 := newRuntime(d)
+	*ctxPtr2 = common.WithInitEnv(*ctxPtr2, env)
+
+	t.Run("SharedCSV", func(t *testing.T) {
+		_, err := common.RunString(rt1, `
+		var users = data.sharedCSV("users", "./users.csv", { delimiter: "\t", header: true });
+		if (users.length !== 2 || users.get(1).name !== "bob" || users.get(1).age !== "40") {
+			throw new Error("wrong rows: " + JSON.stringify(users.get(1)));
+		}
+		`)
+		require.NoError(t, err)
+
+		// Other VUs get the same copy, without reading the file again.
+		require.NoError(t, os.Remove(filepath.Join(dir, "users.csv")))
+		defer func() {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "users.csv"), []byte("name\tage\nalice\t30\r\nbob\t40\n"), 0644))
+		}()
+		_, err = common.RunString(rt2, `
+		var users = data.sharedCSV("users", "./users.csv", { delimiter: "\t", header: true });
+		if (users.get(0).name !== "alice") { throw new Error("wrong row: " + JSON.stringify(users.get(0))); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("CSVReader", func(t *testing.T) {
+		_, err := common.RunString(rt1, `var reader = new data.CSVReader("./users.csv", { delimiter: "\t" });`)
+		require.NoError(t, err)
+
+		*ctxPtr1 = common.WithState(*ctxPtr1, &common.State{})
+		_, err = common.RunString(rt1, `
+		var rows = [];
+		for (var row = reader.readRow(); row !== null; row = reader.readRow()) {
+			rows.push(row);
+		}
+		if (JSON.stringify(rows) !== '[["name","age"],["alice","30"],["bob","40"]]') {
+			throw new Error("wrong rows: " + JSON.stringify(rows));
+		}
+		reader.close();
+		`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt1, `new data.CSVReader("./users.csv");`)
+		assert.Contains(t, err.Error(), "CSVReader must be constructed in the init context")
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		_, err := common.RunString(rt2, `
+		var reader = new data.CSVReader("./users.csv", { delimiter: "\t", header: true });
+		if (reader.header.join() !== "name,age") { throw new Error("wrong header: " + reader.header); }
+		if (reader.readRow().age !== "30") { throw new Error("wrong row"); }
+		`)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,141 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+type Data struct {
+	mu     sync.Mutex
+	arrays map[string]*sharedArrayEntry
+	files  map[string]*mappedFile
+}
+
+func New() *Data {
+	return &Data{
+		arrays: make(map[string]*sharedArrayEntry),
+		files:  make(map[string]*mappedFile),
+	}
+}
+
+// sharedArray holds the JSON encoding of each element, which is only decoded in a VU's runtime
+// when the element is accessed. It's never modified once created, so it's safe to share.
+type sharedArray struct {
+	elements []json.RawMessage
+}
+
+// A sharedArrayEntry is a named shared array, which is populated by the first VU to ask for it.
+// Its own lock keeps the others waiting meanwhile, without blocking ones that ask for other
+// arrays, including from the function that populates it.
+type sharedArrayEntry struct {
+	mu  sync.Mutex
+	arr *sharedArray
+}
+
+// sharedArray returns the shared array with the given name, calling load to populate it if it
+// hasn't been yet. If load fails, the next VU to ask for the array tries again.
+func (d *Data) sharedArray(name string, load func() (*sharedArray, error)) (*sharedArray, error) {
+	d.mu.Lock()
+	entry, ok := d.arrays[name]
+	if !ok {
+		entry = &sharedArrayEntry{}
+		d.arrays[name] = entry
+	}
+	d.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.arr == nil {
+		arr, err := load()
+		if err != nil {
+			return nil, err
+		}
+		entry.arr = arr
+	}
+	return entry.arr, nil
+}
+
+// A SharedArray is a VU's read-only view of an array shared by all VUs.
+type SharedArray struct {
+	arr *sharedArray
+
+	Length int `js:"length"`
+}
+
+// XSharedArray returns the shared array with the given name. The first VU to ask for it calls
+// the function to populate it; every other VU gets to read the same copy.
+func (d *Data) XSharedArray(ctxPtr *context.Context, name string, fn goja.Callable) (interface{}, error) {
+	if common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("SharedArray must be constructed in the init context")
+	}
+	if name == "" {
+		return nil, errors.New("SharedArray needs a name")
+	}
+	rt := common.GetRuntime(*ctxPtr)
+
+	arr, err := d.sharedArray(name, func() (*sharedArray, error) {
+		if fn == nil {
+			return nil, errors.New("SharedArray needs a function returning the array's contents")
+		}
+		v, err := fn(goja.Undefined())
+		if err != nil {
+			return nil, err
+		}
+		elements, ok := v.Export().([]interface{})
+		if !ok {
+			return nil, errors.Errorf("SharedArray '%s': the function must return an array", name)
+		}
+		data, err := json.Marshal(elements)
+		if err != nil {
+			return nil, errors.Wrapf(err, "SharedArray '%s'", name)
+		}
+		arr := &sharedArray{}
+		if err := json.Unmarshal(data, &arr.elements); err != nil {
+			return nil, errors.Wrapf(err, "SharedArray '%s'", name)
+		}
+		return arr, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return common.Bind(rt, &SharedArray{arr: arr, Length: len(arr.elements)}, ctxPtr), nil
+}
+
+// Get returns a fresh copy of the element at the given index, or undefined if it's out of range.
+func (a *SharedArray) Get(ctx context.Context, i int) (goja.Value, error) {
+	if i < 0 || i >= len(a.arr.elements) {
+		return goja.Undefined(), nil
+	}
+	return parseJSON(common.GetRuntime(ctx), a.arr.elements[i])
+}
+
+// parseJSON decodes JSON into a value owned by the given runtime.
+func parseJSON(rt *goja.Runtime, data []byte) (goja.Value, error) {
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	return parse(goja.Undefined(), rt.ToValue(string(data)))
+}
@@ -0,0 +1,105 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRuntime(d *Data) (*goja.Runtime, *context.Context) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctxPtr := new(context.Context)
+	*ctxPtr = common.WithRuntime(context.Background(), rt)
+	rt.Set("data", common.Bind(rt, d, ctxPtr))
+	return rt, ctxPtr
+}
+
+func TestSharedArray(t *testing.T) {
+	d := New()
+	rt1, _ := newRuntime(d)
+	rt2, ctxPtr2 := newRuntime(d)
+
+	_, err := common.RunString(rt1, `
+	var calls = 0;
+	var users = new data.SharedArray("users", function() {
+		calls++;
+		return [{ name: "alice", roles: ["admin"] }, { name: "bob", roles: [] }, 42];
+	});
+	if (users.length !== 3) { throw new Error("wrong length: " + users.length); }
+	if (users.get(0).name !== "alice") { throw new Error("wrong element: " + JSON.stringify(users.get(0))); }
+	if (users.get(2) !== 42) { throw new Error("wrong element: " + users.get(2)); }
+	if (users.get(3) !== undefined || users.get(-1) !== undefined) { throw new Error("out of range"); }
+	users.get(0).roles.push("root");
+	if (users.get(0).roles.length !== 1) { throw new Error("shared data was modified"); }
+	`)
+	require.NoError(t, err)
+
+	t.Run("Shared", func(t *testing.T) {
+		_, err := common.RunString(rt2, `
+		var users = new data.SharedArray("users", function() {
+			throw new Error("called twice");
+		});
+		if (users.length !== 3 || users.get(1).name !== "bob") { throw new Error("wrong contents"); }
+		`)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), rt1.Get("calls").ToInteger())
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		_, err := common.RunString(rt1, `
+		var outer = new data.SharedArray("outer", function() {
+			var inner = new data.SharedArray("inner", function() { return [1, 2]; });
+			return [inner.get(0) + inner.get(1)];
+		});
+		if (outer.get(0) !== 3) { throw new Error("wrong element: " + outer.get(0)); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("NotArray", func(t *testing.T) {
+		_, err := common.RunString(rt1, `new data.SharedArray("object", function() { return {}; });`)
+		assert.Contains(t, err.Error(), "SharedArray 'object': the function must return an array")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := common.RunString(rt1, `new data.SharedArray("error", function() { throw new Error("oops"); });`)
+		assert.Contains(t, err.Error(), "oops")
+
+		_, err = common.RunString(rt1, `
+		var arr = new data.SharedArray("error", function() { return [1]; });
+		if (arr.length !== 1) { throw new Error("wrong length: " + arr.length); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		*ctxPtr2 = common.WithState(*ctxPtr2, &common.State{})
+		_, err := common.RunString(rt2, `new data.SharedArray("late", function() { return []; });`)
+		assert.Contains(t, err.Error(), "SharedArray must be constructed in the init context")
+	})
+}
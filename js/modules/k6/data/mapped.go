@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// mappedFile is a file's contents along with the offsets of its lines. It's never modified once
+// created, so it's safe to share, and to hand out substrings of.
+type mappedFile struct {
+	text  string
+	lines []int
+}
+
+func newMappedFile(data []byte) *mappedFile {
+	file := &mappedFile{text: string(data)}
+	for start := 0; start < len(file.text); {
+		file.lines = append(file.lines, start)
+		i := strings.IndexByte(file.text[start:], '\n')
+		if i == -1 {
+			break
+		}
+		start += i + 1
+	}
+	return file
+}
+
+// str returns the given part of the file.
+func (f *mappedFile) str(start, end int) string {
+	if start >= end {
+		return ""
+	}
+	return f.text[start:end]
+}
+
+// A MappedFile is a VU's view of a read-only file, loaded into memory once and shared by all VUs.
+type MappedFile struct {
+	file *mappedFile
+
+	// Size of the file in bytes, and its number of lines.
+	Size   int `js:"size"`
+	Length int `js:"length"`
+}
+
+// XMappedFile loads a local file like open() does, or returns the copy another VU already loaded.
+func (d *Data) XMappedFile(ctxPtr *context.Context, name string) (interface{}, error) {
+	env := common.GetInitEnv(*ctxPtr)
+	if env == nil || common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("MappedFile must be constructed in the init context")
+	}
+	if name == "" {
+		return nil, errors.New("MappedFile needs a path")
+	}
+	filename := env.Resolve(name)
+	if !filepath.IsAbs(filepath.FromSlash(filename)) {
+		return nil, errors.Errorf("MappedFile needs a local path, eg. ./%s", name)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	file, ok := d.files[filename]
+	if !ok {
+		data, err := env.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		file = newMappedFile(data)
+		d.files[filename] = file
+	}
+	rt := common.GetRuntime(*ctxPtr)
+	return common.Bind(rt, &MappedFile{file: file, Size: len(file.text), Length: len(file.lines)}, ctxPtr), nil
+}
+
+// Line returns the line with the given index, without its line ending, or undefined if it's out
+// of range. The string shares its memory with the file.
+func (m *MappedFile) Line(ctx context.Context, i int) goja.Value {
+	if i < 0 || i >= len(m.file.lines) {
+		return goja.Undefined()
+	}
+	start, end := m.file.lines[i], len(m.file.text)
+	if i+1 < len(m.file.lines) {
+		end = m.file.lines[i+1]
+	}
+	if end > start && m.file.text[end-1] == '\n' {
+		end--
+	}
+	if end > start && m.file.text[end-1] == '\r' {
+		end--
+	}
+	return common.GetRuntime(ctx).ToValue(m.file.str(start, end))
+}
+
+// Slice returns the bytes between two offsets as a string. Offsets are clamped to the file.
+func (m *MappedFile) Slice(start, end int) string {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(m.file.text) {
+		end = len(m.file.text)
+	}
+	return m.file.str(start, end)
+}
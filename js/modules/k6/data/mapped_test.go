@@ -0,0 +1,102 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMappedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-data")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "users.csv"), []byte("name,age\nalice,30\r\nbob,40"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "empty.csv"), nil, 0644))
+
+	d := New()
+	resolve := func(name string) string {
+		if name[0] == '.' {
+			return filepath.Join(dir, name)
+		}
+		return name
+	}
+	env := &common.InitEnvironment{Resolve: resolve, ReadFile: func(name string) ([]byte, error) {
+		return ioutil.ReadFile(resolve(name))
+	}}
+	rt1, ctxPtr1 := newRuntime(d)
+	*ctxPtr1 = common.WithInitEnv(*ctxPtr1, env)
+	rt2, ctxPtr2 := newRuntime(d)
+	*ctxPtr2 = common.WithInitEnv(*ctxPtr2, env)
+
+	_, err = common.RunString(rt1, `
+	var users = new data.MappedFile("./users.csv");
+	if (users.size !== 25) { throw new Error("wrong size: " + users.size); }
+	if (users.length !== 3) { throw new Error("wrong length: " + users.length); }
+	if (users.line(0) !== "name,age") { throw new Error("wrong line 0: " + users.line(0)); }
+	if (users.line(1) !== "alice,30") { throw new Error("wrong line 1: " + JSON.stringify(users.line(1))); }
+	if (users.line(2) !== "bob,40") { throw new Error("wrong line 2: " + users.line(2)); }
+	if (users.line(3) !== undefined) { throw new Error("line out of range"); }
+	if (users.slice(9, 14) !== "alice") { throw new Error("wrong slice: " + users.slice(9, 14)); }
+	if (users.slice(23, 100) !== "40") { throw new Error("wrong slice: " + users.slice(23, 100)); }
+	`)
+	require.NoError(t, err)
+
+	t.Run("Shared", func(t *testing.T) {
+		_, err := common.RunString(rt2, `
+		var users = new data.MappedFile("./users.csv");
+		if (users.line(2) !== "bob,40") { throw new Error("wrong line 2: " + users.line(2)); }
+		`)
+		assert.NoError(t, err)
+		assert.Len(t, d.files, 1)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, err := common.RunString(rt1, `
+		var empty = new data.MappedFile("./empty.csv");
+		if (empty.size !== 0 || empty.length !== 0) { throw new Error("not empty"); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Nonexistent", func(t *testing.T) {
+		_, err := common.RunString(rt1, `new data.MappedFile("./nope.csv");`)
+		assert.Contains(t, err.Error(), "no such file or directory")
+	})
+
+	t.Run("Remote", func(t *testing.T) {
+		_, err := common.RunString(rt1, `new data.MappedFile("example.com/users.csv");`)
+		assert.Contains(t, err.Error(), "MappedFile needs a local path, eg. ./example.com/users.csv")
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		*ctxPtr2 = common.WithState(context.Background(), &common.State{})
+		_, err := common.RunString(rt2, `new data.MappedFile("./users.csv");`)
+		assert.Contains(t, err.Error(), "MappedFile must be constructed in the init context")
+	})
+}
@@ -0,0 +1,147 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// A FileReader reads a local file a line or a chunk at a time. The file is loaded once, like
+// open() does, and shared by all VUs; every VU that constructs a reader gets its own position.
+type FileReader struct {
+	f      *bytes.Reader
+	r      *bufio.Reader
+	offset int64
+	closed bool
+}
+
+// XFileReader opens a local file for reading. Paths are resolved like open() does.
+func (*Data) XFileReader(ctxPtr *context.Context, name string) (interface{}, error) {
+	env := common.GetInitEnv(*ctxPtr)
+	if env == nil || common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("FileReader must be constructed in the init context")
+	}
+	data, err := readLocal(env, "FileReader", name)
+	if err != nil {
+		return nil, err
+	}
+	f := bytes.NewReader(data)
+	rt := common.GetRuntime(*ctxPtr)
+	return common.Bind(rt, &FileReader{f: f, r: bufio.NewReader(f)}, ctxPtr), nil
+}
+
+// readLocal loads a file for the named function through the init context, resolving its path
+// like open() does.
+func readLocal(env *common.InitEnvironment, fn, name string) ([]byte, error) {
+	if name == "" {
+		return nil, errors.Errorf("%s needs a path", fn)
+	}
+	if !filepath.IsAbs(filepath.FromSlash(env.Resolve(name))) {
+		return nil, errors.Errorf("%s needs a local path, eg. ./%s", fn, name)
+	}
+	return env.ReadFile(name)
+}
+
+// ReadLine returns the next line without its line ending, or null at the end of the file.
+func (fr *FileReader) ReadLine(ctx context.Context) (goja.Value, error) {
+	if fr.closed {
+		return nil, os.ErrClosed
+	}
+	line, err := fr.r.ReadString('\n')
+	fr.offset += int64(len(line))
+	if err == io.EOF {
+		if line == "" {
+			return goja.Null(), nil
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	return common.GetRuntime(ctx).ToValue(line), nil
+}
+
+// Read returns up to n bytes as a string, which is only shorter at the end of the file, or null
+// once the end has been reached.
+func (fr *FileReader) Read(ctx context.Context, n int) (goja.Value, error) {
+	if n <= 0 {
+		return nil, errors.New("read needs a positive number of bytes")
+	}
+	if fr.closed {
+		return nil, os.ErrClosed
+	}
+	buf := make([]byte, n)
+	read, err := io.ReadFull(fr.r, buf)
+	fr.offset += int64(read)
+	if err == io.EOF {
+		return goja.Null(), nil
+	} else if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return common.GetRuntime(ctx).ToValue(string(buf[:read])), nil
+}
+
+// Seek moves to a byte offset relative to the start of the file (whence 0, the default), the
+// current offset (1) or the end of the file (2), and returns the new offset. seek(0) starts
+// reading the file over.
+func (fr *FileReader) Seek(offset int64, whence int) (int64, error) {
+	if fr.closed {
+		return 0, os.ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += fr.offset
+	case io.SeekEnd:
+		offset += fr.f.Size()
+	default:
+		return 0, errors.Errorf("invalid whence: %d", whence)
+	}
+	if _, err := fr.f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	fr.r.Reset(fr.f)
+	fr.offset = offset
+	return offset, nil
+}
+
+// Offset returns the byte offset the next read starts at.
+func (fr *FileReader) Offset() int64 {
+	return fr.offset
+}
+
+// Close closes the reader; it can't be read from afterwards.
+func (fr *FileReader) Close() error {
+	if fr.closed {
+		return os.ErrClosed
+	}
+	fr.closed = true
+	return nil
+}
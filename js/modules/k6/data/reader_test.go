@@ -0,0 +1,95 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-data")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "users.csv"), []byte("name,age\nalice,30\r\nbob,40"), 0644))
+
+	rt, ctxPtr := newRuntime(New())
+	resolve := func(name string) string { return filepath.Join(dir, name) }
+	*ctxPtr = common.WithInitEnv(*ctxPtr, &common.InitEnvironment{Resolve: resolve, ReadFile: func(name string) ([]byte, error) {
+		return ioutil.ReadFile(resolve(name))
+	}})
+	_, err = common.RunString(rt, `var users = new data.FileReader("./users.csv");`)
+	require.NoError(t, err)
+
+	// Reading happens in VU code.
+	*ctxPtr = common.WithState(*ctxPtr, &common.State{})
+
+	t.Run("ReadLine", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var lines = [];
+		for (var line = users.readLine(); line !== null; line = users.readLine()) {
+			lines.push(line);
+		}
+		if (JSON.stringify(lines) !== '["name,age","alice,30","bob,40"]') {
+			throw new Error("wrong lines: " + JSON.stringify(lines));
+		}
+		if (users.offset() !== 25) { throw new Error("wrong offset: " + users.offset()); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Seek", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		if (users.seek(0) !== 0 || users.readLine() !== "name,age") { throw new Error("seek to start failed"); }
+		if (users.seek(-6, 2) !== 19 || users.readLine() !== "bob,40") { throw new Error("seek from end failed"); }
+		users.seek(9);
+		if (users.seek(6, 1) !== 15 || users.read(2) !== "30") { throw new Error("seek from current failed"); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		users.seek(0);
+		if (users.read(4) !== "name") { throw new Error("wrong read"); }
+		users.seek(20);
+		if (users.read(100) !== "ob,40") { throw new Error("wrong read at the end"); }
+		if (users.read(1) !== null) { throw new Error("read past the end"); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `new data.FileReader("./users.csv");`)
+		assert.Contains(t, err.Error(), "FileReader must be constructed in the init context")
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		_, err := common.RunString(rt, `users.close(); users.seek(0);`)
+		assert.Error(t, err)
+	})
+}
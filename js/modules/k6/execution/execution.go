@@ -0,0 +1,239 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package execution
+
+import (
+	"context"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+// DefaultScenarioName is the name of the scenario a test's VUs and iterations belong to, unless
+// its options have scenarios.
+const DefaultScenarioName = "default"
+
+type Execution struct{}
+
+func New() *Execution {
+	return &Execution{}
+}
+
+// TestProgress is how far along the test is. Durations are in milliseconds, like other times
+// scripts deal with; remaining and progress are null if the test has no end.
+type TestProgress struct {
+	Elapsed   float64     `js:"elapsed"`
+	Remaining interface{} `js:"remaining"`
+	Progress  interface{} `js:"progress"`
+}
+
+// Progress returns how far along the test is, going by its end conditions. This lets scripts
+// avoid starting work near the end of a test that would be cut off by it.
+func (*Execution) Progress(ctx context.Context) (*TestProgress, error) {
+	ex := lib.GetExecutor(ctx)
+	if ex == nil {
+		return nil, errors.New("test progress is only available while the test is running")
+	}
+
+	p := lib.GetProgress(ex)
+	tp := &TestProgress{Elapsed: msec(p.Elapsed)}
+	if p.Remaining.Valid {
+		tp.Remaining = msec(time.Duration(p.Remaining.Duration))
+	}
+	if p.Fraction.Valid {
+		tp.Progress = p.Fraction.Float64
+	}
+	return tp, nil
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// VUInfo describes the VU running the script. VU IDs start from 1, while iterations are numbered
+// from 0; setup() and teardown() run in VU 0. Tags set on tags are added to all the samples the
+// VU emits from then on, and store keeps values for the VU's later iterations or groups.
+type VUInfo struct {
+	IDInTest            int64             `js:"idInTest"`
+	IDInScenario        int64             `js:"idInScenario"`
+	IterationInScenario int64             `js:"iterationInScenario"`
+	Tags                map[string]string `js:"tags"`
+	Store               *Store            `js:"store"`
+}
+
+// Store lets the script keep values on its VU, like tokens or IDs that later requests are made
+// with, and pass them between groups and helper modules without globals. A value is kept for the
+// rest of the VU's iterations, or, stored with {scope: "iteration"}, until the iteration ends.
+type Store struct {
+	store *common.VUStore
+}
+
+// Set stores a value under a key, replacing the one stored before, if any.
+func (s *Store) Set(key string, value goja.Value, opts map[string]interface{}) error {
+	iteration := false
+	for k, v := range opts {
+		if k != "scope" {
+			return errors.Errorf("unknown store option: %s", k)
+		}
+		switch v {
+		case "vu":
+		case "iteration":
+			iteration = true
+		default:
+			return errors.Errorf("invalid store scope: %v, it has to be vu or iteration", v)
+		}
+	}
+	s.store.Set(key, value, iteration)
+	return nil
+}
+
+// Get returns the value stored under a key, or the default, undefined unless it's given.
+func (s *Store) Get(key string, def goja.Value) goja.Value {
+	if v, ok := s.store.Get(key); ok {
+		return v
+	}
+	if def == nil {
+		return goja.Undefined()
+	}
+	return def
+}
+
+// Has returns whether a value is stored under a key.
+func (s *Store) Has(key string) bool {
+	_, ok := s.store.Get(key)
+	return ok
+}
+
+// Delete deletes the value stored under a key, and returns whether there was one.
+func (s *Store) Delete(key string) bool {
+	return s.store.Delete(key)
+}
+
+// Keys returns the keys values are stored under, sorted.
+func (s *Store) Keys() []string {
+	return s.store.Keys()
+}
+
+// Clear deletes all stored values.
+func (s *Store) Clear() {
+	s.store.Clear()
+}
+
+// ScenarioInfo describes the scenario being run. iterationInTest counts the iterations the
+// scenario has started, on all of its VUs, and is null outside of iterations, eg. in setup().
+type ScenarioInfo struct {
+	Name            string      `js:"name"`
+	Executor        string      `js:"executor"`
+	IterationInTest interface{} `js:"iterationInTest"`
+}
+
+// Vu returns information about the VU running the script.
+func (*Execution) Vu(ctx context.Context) (*VUInfo, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("VU information is only available in VU code")
+	}
+	return &VUInfo{
+		IDInTest:            state.Vu,
+		IDInScenario:        state.Vu,
+		IterationInScenario: state.Iteration,
+		Tags:                state.VUTags,
+		Store:               &Store{state.Store},
+	}, nil
+}
+
+// Scenario returns information about the scenario being run.
+func (*Execution) Scenario(ctx context.Context) (*ScenarioInfo, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("scenario information is only available in VU code")
+	}
+	info := &ScenarioInfo{Name: DefaultScenarioName, Executor: executorName(state.Options)}
+	if sc := lib.GetScenario(ctx); sc != nil {
+		info.Name, info.Executor = sc.Name, sc.Executor
+	}
+	if iter, ok := lib.GetIteration(ctx); ok {
+		info.IterationInTest = iter
+	}
+	return info, nil
+}
+
+// WithTags runs fn with tags added to all the samples emitted while it runs, and returns what it
+// returns. Tags it had before, eg. from an enclosing call, are back once it's done.
+func (*Execution) WithTags(ctx context.Context, tags map[string]string, fn goja.Callable) (goja.Value, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return goja.Undefined(), errors.New("tags can only be set in VU code")
+	}
+	if fn == nil {
+		return goja.Undefined(), errors.New("withTags() needs a function to run")
+	}
+
+	if state.Tags == nil {
+		state.Tags = make(map[string]string, len(tags))
+	}
+	old := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		if prev, ok := state.Tags[k]; ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		state.Tags[k] = v
+	}
+	defer func() {
+		for k, prev := range old {
+			if prev != nil {
+				state.Tags[k] = *prev
+			} else {
+				delete(state.Tags, k)
+			}
+		}
+	}()
+	return fn(goja.Undefined())
+}
+
+// Abort stops the whole test, as if it had reached its end, and makes k6 exit with an error. It
+// throws, so the rest of the iteration doesn't run.
+func (*Execution) Abort(ctx context.Context, reason string) (goja.Value, error) {
+	ex := lib.GetExecutor(ctx)
+	if ex == nil {
+		return goja.Undefined(), errors.New("the test can only be aborted while it's running")
+	}
+	err := lib.ScriptAbortError{Reason: reason}
+	ex.Abort(err)
+	return goja.Undefined(), err
+}
+
+// executorName describes how the options schedule VUs, in the terms of k6 executors.
+func executorName(opts lib.Options) string {
+	switch {
+	case len(opts.Stages) > 0:
+		return "ramping-vus"
+	case opts.Iterations.Valid && !opts.Duration.Valid:
+		return "shared-iterations"
+	default:
+		return "constant-vus"
+	}
+}
@@ -0,0 +1,277 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package execution
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestExecutionProgress(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Set("execution", common.Bind(rt, New(), &ctx))
+
+	t.Run("NotRunning", func(t *testing.T) {
+		_, err := common.RunString(rt, `execution.progress()`)
+		assert.Contains(t, err.Error(), "test progress is only available while the test is running")
+	})
+
+	t.Run("Iterations", func(t *testing.T) {
+		ex := local.New(nil)
+		ex.SetEndIterations(null.IntFrom(10))
+		ctx = lib.WithExecutor(context.Background(), ex)
+		_, err := common.RunString(rt, `
+		var p = execution.progress();
+		if (p.elapsed !== 0 || p.progress !== 0 || p.remaining !== null) {
+			throw new Error("wrong progress: " + JSON.stringify(p));
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Infinite", func(t *testing.T) {
+		ctx = lib.WithExecutor(context.Background(), local.New(nil))
+		_, err := common.RunString(rt, `
+		var p = execution.progress();
+		if (p.progress !== null || p.remaining !== null) {
+			throw new Error("wrong progress: " + JSON.stringify(p));
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Duration", func(t *testing.T) {
+		ex := local.New(nil)
+		ex.SetEndTime(types.NullDurationFrom(10 * time.Second))
+		ctx = lib.WithExecutor(context.Background(), ex)
+		_, err := common.RunString(rt, `
+		var p = execution.progress();
+		if (p.progress !== 0 || p.remaining !== 10000) {
+			throw new Error("wrong progress: " + JSON.stringify(p));
+		}`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestExecutionInfo(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Set("execution", common.Bind(rt, New(), &ctx))
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `execution.vu()`)
+		assert.Contains(t, err.Error(), "VU information is only available in VU code")
+		_, err = common.RunString(rt, `execution.scenario()`)
+		assert.Contains(t, err.Error(), "scenario information is only available in VU code")
+	})
+
+	ctx = common.WithState(lib.WithIteration(context.Background(), 42), &common.State{
+		Options:   lib.Options{Stages: []lib.Stage{{Duration: types.NullDurationFrom(time.Second)}}},
+		Vu:        3,
+		Iteration: 7,
+	})
+
+	t.Run("VU", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var vu = execution.vu();
+		if (vu.idInTest !== 3 || vu.idInScenario !== 3 || vu.iterationInScenario !== 7) {
+			throw new Error("wrong vu: " + JSON.stringify(vu));
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Scenario", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var s = execution.scenario();
+		if (s.name !== "default" || s.executor !== "ramping-vus" || s.iterationInTest !== 42) {
+			throw new Error("wrong scenario: " + JSON.stringify(s));
+		}`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("NamedScenario", func(t *testing.T) {
+		ctx = lib.WithScenario(ctx, &lib.ScenarioState{
+			Name:     "browse",
+			Scenario: lib.Scenario{Executor: lib.ConstantVUsExecutor},
+		})
+		_, err := common.RunString(rt, `
+		var s = execution.scenario();
+		if (s.name !== "browse" || s.executor !== "constant-vus" || s.iterationInTest !== 42) {
+			throw new Error("wrong scenario: " + JSON.stringify(s));
+		}`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTags(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Set("execution", common.Bind(rt, New(), &ctx))
+
+	_, err := common.RunString(rt, `execution.withTags({ step: "login" }, function() {})`)
+	assert.Contains(t, err.Error(), "tags can only be set in VU code")
+
+	state := &common.State{
+		Options: lib.Options{RunTags: stats.IntoSampleTags(&map[string]string{"env": "ci", "team": "a"})},
+		Tags:    map[string]string{"scenario": "browse"},
+		VUTags:  map[string]string{},
+	}
+	ctx = common.WithState(context.Background(), state)
+	rt.Set("tags", func() map[string]string { return state.CloneTags() })
+
+	t.Run("VU", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var vu = execution.vu();
+		vu.tags.team = "b";
+		vu.tags.shard = 2;
+		vu.tags.scenario = "checkout";`)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "b", "shard": "2", "scenario": "checkout"}, state.VUTags)
+		// The iteration's own tags win over the VU's.
+		assert.Equal(t, map[string]string{
+			"env": "ci", "team": "b", "shard": "2", "scenario": "browse",
+		}, state.CloneTags())
+	})
+
+	t.Run("WithTags", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		execution.withTags({ step: "login", scenario: "auth" }, function() {
+			var inner = execution.withTags({ step: "mfa" }, function() { return tags().step; });
+			return [tags().step, tags().scenario, inner].join(",");
+		})`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "login,auth,mfa", v.String())
+		}
+		assert.Equal(t, map[string]string{"scenario": "browse"}, state.Tags)
+	})
+
+	t.Run("Throws", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		execution.withTags({ step: "login" }, function() { throw new Error("oops"); })`)
+		assert.Contains(t, err.Error(), "oops")
+		assert.Equal(t, map[string]string{"scenario": "browse"}, state.Tags)
+	})
+}
+
+func TestStore(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	store := common.NewVUStore()
+	ctx := common.WithState(context.Background(), &common.State{Store: store})
+	rt.Set("execution", common.Bind(rt, New(), &ctx))
+
+	t.Run("SetGet", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var store = execution.vu().store;
+		store.set("token", "abc");
+		store.set("user", { id: 42 }, { scope: "vu" });
+		store.set("order", 7, { scope: "iteration" });
+		if (store.get("token") !== "abc" || store.get("user").id !== 42 || store.get("order") !== 7) {
+			throw new Error("wrong values");
+		}
+		if (store.get("missing") !== undefined || store.get("missing", "none") !== "none") {
+			throw new Error("wrong default");
+		}
+		if (!store.has("token") || store.has("missing")) {
+			throw new Error("wrong has");
+		}
+		if (store.keys().join(",") !== "order,token,user") {
+			throw new Error("wrong keys: " + store.keys());
+		}`)
+		assert.NoError(t, err)
+
+		store.EndIteration()
+		assert.Equal(t, []string{"token", "user"}, store.Keys())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var store = execution.vu().store;
+		if (!store.delete("token") || store.delete("token")) {
+			throw new Error("wrong delete");
+		}
+		store.clear();`)
+		assert.NoError(t, err)
+		assert.Empty(t, store.Keys())
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		// Storing a value for the VU again keeps it past the iteration.
+		_, err := common.RunString(rt, `
+		var store = execution.vu().store;
+		store.set("token", "abc", { scope: "iteration" });
+		store.set("token", "def");`)
+		assert.NoError(t, err)
+		store.EndIteration()
+		assert.Equal(t, []string{"token"}, store.Keys())
+
+		_, err = common.RunString(rt, `execution.vu().store.set("token", "abc", { scope: "test" })`)
+		assert.Contains(t, err.Error(), "invalid store scope: test, it has to be vu or iteration")
+		_, err = common.RunString(rt, `execution.vu().store.set("token", "abc", { ttl: 10 })`)
+		assert.Contains(t, err.Error(), "unknown store option: ttl")
+	})
+}
+
+func TestExecutorName(t *testing.T) {
+	assert.Equal(t, "constant-vus", executorName(lib.Options{}))
+	assert.Equal(t, "constant-vus", executorName(lib.Options{
+		Duration:   types.NullDurationFrom(time.Second),
+		Iterations: null.IntFrom(10),
+	}))
+	assert.Equal(t, "shared-iterations", executorName(lib.Options{Iterations: null.IntFrom(10)}))
+	assert.Equal(t, "ramping-vus", executorName(lib.Options{Stages: []lib.Stage{{}}}))
+}
+
+func TestAbort(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Set("execution", common.Bind(rt, New(), &ctx))
+
+	_, err := common.RunString(rt, `execution.abort("nope")`)
+	assert.Contains(t, err.Error(), "the test can only be aborted while it's running")
+
+	ex := local.New(&lib.MiniRunner{Fn: func(vuCtx context.Context) ([]stats.Sample, error) {
+		ctx = vuCtx
+		_, err := common.RunString(rt, `execution.abort("environment is broken"); throw new Error("unreachable");`)
+		return nil, err
+	}})
+	assert.NoError(t, ex.SetVUsMax(1))
+	assert.NoError(t, ex.SetVUs(1))
+	ex.SetEndTime(types.NullDurationFrom(10 * time.Second))
+
+	start := time.Now()
+	err = ex.Run(context.Background(), nil)
+	assert.EqualError(t, err, "test aborted: environment is broken")
+	assert.True(t, time.Since(start) < 5*time.Second, "the test wasn't aborted")
+}
@@ -0,0 +1,210 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package k6
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+)
+
+// CheckResult is the outcome of an assertion made with expect(). Checks that return one, or an
+// array of them, fail with its message, which is logged along with the values compared.
+type CheckResult struct {
+	OK       bool        `js:"ok"`
+	Message  string      `js:"message"`
+	Actual   interface{} `js:"actual"`
+	Expected interface{} `js:"expected"`
+
+	hasExpected bool
+}
+
+// String describes the values compared, for logging failures.
+func (r *CheckResult) String() string {
+	if !r.hasExpected {
+		return fmt.Sprintf("%s (actual: %s)", r.Message, formatCheckValue(r.Actual))
+	}
+	return fmt.Sprintf("%s (actual: %s, expected: %s)",
+		r.Message, formatCheckValue(r.Actual), formatCheckValue(r.Expected))
+}
+
+// Expectation holds a value to assert things about; its methods make CheckResults.
+type Expectation struct {
+	ctx     context.Context
+	actual  goja.Value
+	message string
+}
+
+// Expect starts an assertion about a value. The optional message replaces the generated one
+// when the assertion fails.
+func (*K6) Expect(ctx context.Context, actual goja.Value, message ...string) *Expectation {
+	e := &Expectation{ctx: ctx, actual: actual}
+	if len(message) > 0 {
+		e.message = message[0]
+	}
+	return e
+}
+
+func (e *Expectation) result(ok bool, expected goja.Value, format string, args ...interface{}) *CheckResult {
+	r := &CheckResult{OK: ok, Message: e.message, Actual: exportCheckValue(e.actual)}
+	if expected != nil {
+		r.Expected = exportCheckValue(expected)
+		r.hasExpected = true
+	}
+	if r.Message == "" {
+		r.Message = fmt.Sprintf(format, args...)
+	}
+	return r
+}
+
+// ToBe asserts that the value is strictly equal (===) to another one.
+func (e *Expectation) ToBe(expected goja.Value) *CheckResult {
+	return e.result(e.actual.StrictEquals(expected), expected, "expected %s to be %s",
+		formatCheckValue(e.actual), formatCheckValue(expected))
+}
+
+// ToEqual asserts that the value has the same content as another one, comparing objects and
+// arrays deeply.
+func (e *Expectation) ToEqual(expected goja.Value) *CheckResult {
+	a, errA := json.Marshal(exportCheckValue(e.actual))
+	b, errB := json.Marshal(exportCheckValue(expected))
+	ok := errA == nil && errB == nil && bytes.Equal(a, b)
+	return e.result(ok, expected, "expected %s to equal %s",
+		formatCheckValue(e.actual), formatCheckValue(expected))
+}
+
+// ToBeTruthy asserts that the value is truthy.
+func (e *Expectation) ToBeTruthy() *CheckResult {
+	return e.result(e.actual.ToBoolean(), nil, "expected %s to be truthy", formatCheckValue(e.actual))
+}
+
+// ToBeGreaterThan asserts that the value is a number greater than another one.
+func (e *Expectation) ToBeGreaterThan(n float64) *CheckResult {
+	ok := isNumber(e.actual) && e.actual.ToFloat() > n
+	return e.result(ok, common.GetRuntime(e.ctx).ToValue(n), "expected %s to be greater than %v",
+		formatCheckValue(e.actual), n)
+}
+
+// ToBeLessThan asserts that the value is a number less than another one.
+func (e *Expectation) ToBeLessThan(n float64) *CheckResult {
+	ok := isNumber(e.actual) && e.actual.ToFloat() < n
+	return e.result(ok, common.GetRuntime(e.ctx).ToValue(n), "expected %s to be less than %v",
+		formatCheckValue(e.actual), n)
+}
+
+// ToContain asserts that the value is a string containing a substring, or an array containing
+// an item (compared with ===).
+func (e *Expectation) ToContain(item goja.Value) *CheckResult {
+	rt := common.GetRuntime(e.ctx)
+	var ok bool
+	if goja.IsUndefined(e.actual) || goja.IsNull(e.actual) {
+		return e.result(false, item, "expected %s to contain %s", formatCheckValue(e.actual), formatCheckValue(item))
+	}
+	switch v := e.actual.Export().(type) {
+	case string:
+		ok = strings.Contains(v, item.String())
+	default:
+		obj := e.actual.ToObject(rt)
+		length := obj.Get("length")
+		if length == nil {
+			break
+		}
+		for i := int64(0); i < length.ToInteger(); i++ {
+			if obj.Get(fmt.Sprint(i)).StrictEquals(item) {
+				ok = true
+				break
+			}
+		}
+	}
+	return e.result(ok, item, "expected %s to contain %s", formatCheckValue(e.actual), formatCheckValue(item))
+}
+
+// ToMatch asserts that the value is a string matching a regular expression, given in Go's syntax.
+func (e *Expectation) ToMatch(pattern string) *CheckResult {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		common.Throw(common.GetRuntime(e.ctx), err)
+	}
+	ok := !goja.IsUndefined(e.actual) && !goja.IsNull(e.actual) && re.MatchString(e.actual.String())
+	return e.result(ok, common.GetRuntime(e.ctx).ToValue(pattern), "expected %s to match /%s/",
+		formatCheckValue(e.actual), pattern)
+}
+
+// checkResults returns the CheckResults a check's value is made of, if any.
+func checkResults(val goja.Value) []*CheckResult {
+	if val == nil {
+		return nil
+	}
+	switch v := val.Export().(type) {
+	case *CheckResult:
+		return []*CheckResult{v}
+	case []interface{}:
+		results := make([]*CheckResult, 0, len(v))
+		for _, item := range v {
+			r, ok := item.(*CheckResult)
+			if !ok {
+				return nil
+			}
+			results = append(results, r)
+		}
+		return results
+	}
+	return nil
+}
+
+func isNumber(v goja.Value) bool {
+	switch v.Export().(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func exportCheckValue(v goja.Value) interface{} {
+	if v == nil || goja.IsUndefined(v) {
+		return nil
+	}
+	return v.Export()
+}
+
+func formatCheckValue(v interface{}) string {
+	if gv, ok := v.(goja.Value); ok {
+		if goja.IsUndefined(gv) {
+			return "undefined"
+		}
+		v = exportCheckValue(gv)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	// Long bodies would drown the log.
+	if len(data) > 200 {
+		return string(data[:200]) + "..."
+	}
+	return string(data)
+}
@@ -0,0 +1,111 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package k6
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpect(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	logger, hook := logtest.NewNullLogger()
+	state := &common.State{
+		Group:   root,
+		Logger:  logger,
+		Options: lib.Options{SystemTags: lib.GetTagSet(lib.DefaultSystemTagList...)},
+	}
+
+	ctx := new(context.Context)
+	*ctx = common.WithState(common.WithRuntime(context.Background(), rt), state)
+	rt.Set("k6", common.Bind(rt, New(), ctx))
+
+	testdata := map[string]struct {
+		expr string
+		ok   bool
+		msg  string
+	}{
+		"toBe":                {`k6.expect(200).toBe(200)`, true, "expected 200 to be 200"},
+		"toBe fail":           {`k6.expect("200").toBe(200)`, false, `expected "200" to be 200`},
+		"toEqual":             {`k6.expect({ a: [1, 2] }).toEqual({ a: [1, 2] })`, true, `expected {"a":[1,2]} to equal {"a":[1,2]}`},
+		"toEqual fail":        {`k6.expect({ a: [1] }).toEqual({ a: [2] })`, false, `expected {"a":[1]} to equal {"a":[2]}`},
+		"toBeTruthy":          {`k6.expect("token").toBeTruthy()`, true, `expected "token" to be truthy`},
+		"toBeTruthy fail":     {`k6.expect(undefined).toBeTruthy()`, false, "expected undefined to be truthy"},
+		"toBeGreaterThan":     {`k6.expect(3).toBeGreaterThan(2)`, true, "expected 3 to be greater than 2"},
+		"toBeGreaterThan NaN": {`k6.expect("3").toBeGreaterThan(2)`, false, `expected "3" to be greater than 2`},
+		"toBeLessThan":        {`k6.expect(1.5).toBeLessThan(2)`, true, "expected 1.5 to be less than 2"},
+		"toBeLessThan fail":   {`k6.expect(2).toBeLessThan(2)`, false, "expected 2 to be less than 2"},
+		"toContain string":    {`k6.expect("hello world").toContain("world")`, true, `expected "hello world" to contain "world"`},
+		"toContain array":     {`k6.expect([1, "a"]).toContain("a")`, true, `expected [1,"a"] to contain "a"`},
+		"toContain fail":      {`k6.expect([1, 2]).toContain(3)`, false, "expected [1,2] to contain 3"},
+		"toContain null":      {`k6.expect(null).toContain(3)`, false, "expected null to contain 3"},
+		"toMatch":             {`k6.expect("abc123").toMatch("^[a-z]+\\d+$")`, true, `expected "abc123" to match /^[a-z]+\d+$/`},
+		"toMatch fail":        {`k6.expect("abc").toMatch("\\d")`, false, `expected "abc" to match /\d/`},
+		"custom message":      {`k6.expect(500, "login failed").toBe(200)`, false, "login failed"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			v, err := common.RunString(rt, data.expr)
+			require.NoError(t, err)
+			r, ok := v.Export().(*CheckResult)
+			require.True(t, ok)
+			assert.Equal(t, data.ok, r.OK)
+			assert.Equal(t, data.msg, r.Message)
+		})
+	}
+
+	t.Run("Check", func(t *testing.T) {
+		hook.Reset()
+		state.Samples = nil
+		v, err := common.RunString(rt, `k6.check({ status: 500, body: "oops" }, {
+			"status": function(r) { return k6.expect(r.status, "wrong status").toBe(200); },
+			"passes": function(r) { return k6.expect(r.body).toBeTruthy(); },
+			"grouped": function(r) { return [k6.expect(r.body).toContain("oops"), k6.expect(r.body).toMatch("^ok")]; },
+		})`)
+		require.NoError(t, err)
+		assert.False(t, v.ToBoolean())
+
+		values := map[string]float64{}
+		for _, sample := range state.Samples {
+			check, _ := sample.Tags.Get("check")
+			values[check] = sample.Value
+		}
+		assert.Equal(t, map[string]float64{"status": 0, "passes": 1, "grouped": 0}, values)
+
+		entries := hook.AllEntries()
+		if assert.Len(t, entries, 2) {
+			msgs := []string{entries[0].Message, entries[1].Message}
+			assert.Contains(t, msgs, `check "status" failed: wrong status (actual: 500, expected: 200)`)
+			assert.Contains(t, msgs, `check "grouped" failed: expected "oops" to match /^ok/ (actual: "oops", expected: "^ok")`)
+		}
+	})
+}
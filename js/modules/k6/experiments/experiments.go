@@ -0,0 +1,124 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package experiments
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+type Experiments struct{}
+
+func New() *Experiments {
+	return &Experiments{}
+}
+
+// Split deterministically assigns a key to one of the weighted buckets of the named experiment
+// and returns the bucket's name. The same experiment name and key always land in the same bucket.
+// The key defaults to the VU ID; pass __ITER or a user ID to split by iteration or by user instead.
+// In VU code, all metrics emitted for the rest of the iteration are tagged with name=bucket, so
+// system tag names like url or status can't be used as experiment names.
+func (*Experiments) Split(ctx context.Context, name string, weights goja.Value, key ...goja.Value) (string, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+
+	if name == "" {
+		return "", errors.New("experiment name must not be empty")
+	}
+	// The name becomes a tag, so it mustn't be able to overwrite the ones k6 sets itself.
+	for _, tag := range lib.SystemTagList {
+		if name == tag {
+			return "", errors.Errorf("'%s' is a system tag and can't be used as an experiment name", name)
+		}
+	}
+	if goja.IsUndefined(weights) || goja.IsNull(weights) {
+		return "", errors.New("no buckets given")
+	}
+
+	var k string
+	switch {
+	case len(key) > 0 && !goja.IsUndefined(key[0]) && !goja.IsNull(key[0]):
+		k = key[0].String()
+	case state != nil:
+		k = strconv.FormatInt(state.Vu, 10)
+	default:
+		return "", errors.New("a key is required when splitting traffic in the init context")
+	}
+
+	// Keys are walked in declaration order, so reordering buckets reshuffles the assignments.
+	obj := weights.ToObject(rt)
+	names := obj.Keys()
+	bucketWeights := make([]float64, len(names))
+	total := 0.0
+	for i, bucket := range names {
+		w := obj.Get(bucket).ToFloat()
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return "", errors.Errorf("invalid weight for bucket '%s': %v", bucket, obj.Get(bucket))
+		}
+		bucketWeights[i] = w
+		total += w
+	}
+	if total <= 0 {
+		return "", errors.New("the total weight of all buckets must be positive")
+	}
+
+	bucket := pick(names, bucketWeights, total, hash(name, k))
+	if state != nil {
+		if state.Tags == nil {
+			state.Tags = make(map[string]string)
+		}
+		state.Tags[name] = bucket
+	}
+	return bucket, nil
+}
+
+// hash maps an experiment and key onto [0, 1).
+func hash(name, key string) float64 {
+	sum := sha256.Sum256([]byte(name + "\x00" + key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+func pick(names []string, weights []float64, total, point float64) string {
+	target := point * total
+	acc := 0.0
+	for i, w := range weights {
+		acc += w
+		if target < acc {
+			return names[i]
+		}
+	}
+
+	// Floating point rounding can leave the target just past the last boundary.
+	for i := len(weights) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return names[i]
+		}
+	}
+	return names[len(names)-1]
+}
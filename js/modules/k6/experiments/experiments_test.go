@@ -0,0 +1,108 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package experiments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("experiments", common.Bind(rt, New(), &ctx))
+
+	t.Run("InitContext", func(t *testing.T) {
+		t.Run("Deterministic", func(t *testing.T) {
+			_, err := common.RunString(rt, `
+			for (let i = 0; i < 100; i++) {
+				let a = experiments.split("exp", { a: 1, b: 1 }, "user-" + i);
+				let b = experiments.split("exp", { a: 1, b: 1 }, "user-" + i);
+				if (a !== b) {
+					throw new Error("Bucket changed for user-" + i + ": " + a + " != " + b);
+				}
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("Weights", func(t *testing.T) {
+			_, err := common.RunString(rt, `
+			let counts = { control: 0, variant: 0, never: 0 };
+			for (let i = 0; i < 10000; i++) {
+				counts[experiments.split("weights", { control: 80, variant: 20, never: 0 }, i)]++;
+			}
+			if (counts.never !== 0) {
+				throw new Error("Zero-weight bucket was picked");
+			}
+			if (counts.variant < 1700 || counts.variant > 2300) {
+				throw new Error("Unexpected distribution: " + JSON.stringify(counts));
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("NoKey", func(t *testing.T) {
+			_, err := common.RunString(rt, `experiments.split("exp", { a: 1, b: 1 });`)
+			assert.Contains(t, err.Error(), "a key is required")
+		})
+		t.Run("NoWeight", func(t *testing.T) {
+			_, err := common.RunString(rt, `experiments.split("exp", { a: 0 }, "x");`)
+			assert.Contains(t, err.Error(), "must be positive")
+		})
+		t.Run("SystemTagName", func(t *testing.T) {
+			for _, name := range []string{"url", "status", "method", "vu"} {
+				_, err := common.RunString(rt, `experiments.split("`+name+`", { a: 1, b: 1 }, "x");`)
+				if assert.Error(t, err, name) {
+					assert.Contains(t, err.Error(), "'"+name+"' is a system tag and can't be used as an experiment name")
+				}
+			}
+		})
+		t.Run("NegativeWeight", func(t *testing.T) {
+			_, err := common.RunString(rt, `experiments.split("exp", { a: 1, b: -1 }, "x");`)
+			assert.Contains(t, err.Error(), "invalid weight for bucket 'b'")
+		})
+	})
+
+	t.Run("VUContext", func(t *testing.T) {
+		root, _ := lib.NewGroup("", nil)
+		state := &common.State{Group: root, Vu: 7}
+		ctx = common.WithState(ctx, state)
+
+		v, err := common.RunString(rt, `experiments.split("backend", { old: 1, new: 1 })`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]string{"backend": v.String()}, state.Tags)
+			assert.Equal(t, v.String(), state.CloneTags()["backend"])
+		}
+
+		v2, err := common.RunString(rt, `experiments.split("backend", { old: 1, new: 1 }, 7)`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, v.String(), v2.String())
+		}
+
+		state.Tags["url"] = "https://example.com/"
+		_, err = common.RunString(rt, `experiments.split("url", { old: 1, new: 1 })`)
+		assert.Error(t, err)
+		assert.Equal(t, "https://example.com/", state.Tags["url"])
+	})
+}
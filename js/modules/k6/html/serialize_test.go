@@ -151,6 +151,20 @@ func TestSerialize(t *testing.T) {
 				assert.Equal(t, "on", arr[6].Value.String())
 			}
 		})
+
+		t.Run("booleanAttributes", func(t *testing.T) {
+			v, err := common.RunString(rt, `html.parseHTML('<form>'+
+				'<input type="checkbox" name="checked" checked>'+
+				'<input type="checkbox" name="unchecked">'+
+				'<input type="text" name="disabled" value="x" disabled>'+
+				'</form>').find("form").serializeArray()`)
+			if assert.NoError(t, err) {
+				arr := v.Export().([]FormValue)
+				assert.Equal(t, 1, len(arr))
+				assert.Equal(t, "checked", arr[0].Name)
+				assert.Equal(t, "on", arr[0].Value.String())
+			}
+		})
 	})
 
 	t.Run("SerializeObject", func(t *testing.T) {
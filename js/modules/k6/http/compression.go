@@ -0,0 +1,142 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Content codings understood by the compression param and decoded in responses.
+const (
+	CompressionGzip    = "gzip"
+	CompressionDeflate = "deflate"
+	CompressionBrotli  = "br"
+	CompressionZstd    = "zstd"
+)
+
+// parseCompression parses a comma separated list of content codings, in the order they're applied.
+func parseCompression(s string) ([]string, error) {
+	var algos []string
+	for _, algo := range strings.Split(s, ",") {
+		algo = strings.ToLower(strings.TrimSpace(algo))
+		switch algo {
+		case "":
+			continue
+		case CompressionGzip, CompressionDeflate, CompressionBrotli, CompressionZstd:
+			algos = append(algos, algo)
+		default:
+			return nil, errors.Errorf("unknown compression algorithm: %s, supported algorithms are gzip, deflate, br and zstd", algo)
+		}
+	}
+	return algos, nil
+}
+
+// compressBody compresses a request body with each of the given algorithms in turn.
+func compressBody(algos []string, body *bytes.Buffer) (*bytes.Buffer, error) {
+	for _, algo := range algos {
+		buf := &bytes.Buffer{}
+		var w io.WriteCloser
+		switch algo {
+		case CompressionGzip:
+			w = gzip.NewWriter(buf)
+		case CompressionDeflate:
+			w = zlib.NewWriter(buf)
+		case CompressionBrotli:
+			w = brotli.NewWriter(buf)
+		case CompressionZstd:
+			var err error
+			if w, err = zstd.NewWriter(buf, zstd.WithEncoderConcurrency(1)); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := body.WriteTo(w); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		body = buf
+	}
+	return body, nil
+}
+
+// decompressedBody closes both the decoder and the underlying response body.
+type decompressedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if cerr := b.closers[i].Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// decompressBody wraps a response body in decoders for its Content-Encoding, which may list
+// several codings; they're undone last to first. Unknown codings are left as they are.
+func decompressBody(body io.ReadCloser, contentEncoding string) (io.ReadCloser, error) {
+	codings := strings.Split(contentEncoding, ",")
+	res := &decompressedBody{Reader: body, closers: []io.Closer{body}}
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch strings.ToLower(strings.TrimSpace(codings[i])) {
+		case CompressionGzip, "x-gzip":
+			r, err := gzip.NewReader(res.Reader)
+			if err != nil {
+				return body, err
+			}
+			res.Reader = r
+			res.closers = append(res.closers, r)
+		case CompressionDeflate:
+			r, err := zlib.NewReader(res.Reader)
+			if err != nil {
+				return body, err
+			}
+			res.Reader = r
+			res.closers = append(res.closers, r)
+		case CompressionBrotli:
+			res.Reader = brotli.NewReader(res.Reader)
+		case CompressionZstd:
+			r, err := zstd.NewReader(res.Reader, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return body, err
+			}
+			rc := r.IOReadCloser()
+			res.Reader = rc
+			res.closers = append(res.closers, rc)
+		default:
+			// Unknown codings (and identity) are passed through; there's nothing else to undo.
+			return res, nil
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,121 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompression(t *testing.T) {
+	algos, err := parseCompression("gzip, BR,zstd,deflate")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gzip", "br", "zstd", "deflate"}, algos)
+
+	algos, err = parseCompression("")
+	assert.NoError(t, err)
+	assert.Empty(t, algos)
+
+	_, err = parseCompression("gzip, lzma")
+	assert.EqualError(t, err, "unknown compression algorithm: lzma, supported algorithms are gzip, deflate, br and zstd")
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	body := strings.Repeat("k6 compresses this body. ", 100)
+	testdata := map[string][]string{
+		"gzip":     {"gzip"},
+		"deflate":  {"deflate"},
+		"br":       {"br"},
+		"zstd":     {"zstd"},
+		"gzip, br": {"gzip", "br"},
+	}
+	for name, algos := range testdata {
+		t.Run(name, func(t *testing.T) {
+			compressed, err := compressBody(algos, bytes.NewBufferString(body))
+			require.NoError(t, err)
+			assert.True(t, compressed.Len() < len(body))
+
+			r, err := decompressBody(ioutil.NopCloser(compressed), name)
+			require.NoError(t, err)
+			data, err := ioutil.ReadAll(r)
+			assert.NoError(t, err)
+			assert.NoError(t, r.Close())
+			assert.Equal(t, body, string(data))
+		})
+	}
+
+	t.Run("Unknown", func(t *testing.T) {
+		r, err := decompressBody(ioutil.NopCloser(strings.NewReader("raw")), "identity")
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "raw", string(data))
+	})
+}
+
+func TestCompression(t *testing.T) {
+	tb, _, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	// Echoes the decoded request body, encoded as the "encoding" query param asks.
+	tb.Mux.HandleFunc("/compressed", func(w http.ResponseWriter, r *http.Request) {
+		body, err := decompressBody(r.Body, r.Header.Get("Content-Encoding"))
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(body)
+		require.NoError(t, err)
+
+		encoding := r.URL.Query().Get("encoding")
+		algos, err := parseCompression(encoding)
+		require.NoError(t, err)
+		compressed, err := compressBody(algos, bytes.NewBuffer(data))
+		require.NoError(t, err)
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("X-Request-Encoding", r.Header.Get("Content-Encoding"))
+		_, _ = compressed.WriteTo(w)
+	})
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd", "gzip, br"} {
+		t.Run(encoding, func(t *testing.T) {
+			_, err := common.RunString(rt, sr(`
+			var res = http.post("HTTPBIN_URL/compressed?encoding=" + encodeURIComponent("`+encoding+`"), "hello k6", { compression: "`+encoding+`" });
+			if (res.body !== "hello k6") { throw new Error("unexpected body: " + res.body); }
+			if (res.request.body !== "hello k6") { throw new Error("unexpected request body: " + res.request.body); }
+			if (res.headers["X-Request-Encoding"] !== "`+encoding+`") {
+				throw new Error("unexpected request encoding: " + res.headers["X-Request-Encoding"]);
+			}
+			`))
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`http.post("HTTPBIN_URL/compressed", "hello k6", { compression: "lzma" });`))
+		assert.Contains(t, err.Error(), "unknown compression algorithm: lzma")
+	})
+}
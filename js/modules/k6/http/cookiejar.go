@@ -22,24 +22,25 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
-	"net/http/cookiejar"
 	neturl "net/url"
 	"strings"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/netext"
 	"github.com/pkg/errors"
 )
 
 type HTTPCookieJar struct {
-	jar *cookiejar.Jar
+	jar *netext.CookieJar
 	ctx *context.Context
 }
 
 func newCookieJar(ctxPtr *context.Context) *HTTPCookieJar {
-	jar, err := cookiejar.New(nil)
+	jar, err := netext.NewCookieJar()
 	if err != nil {
 		common.Throw(common.GetRuntime(*ctxPtr), err)
 	}
@@ -104,3 +105,41 @@ func (j HTTPCookieJar) Set(url, name, value string, opts goja.Value) (bool, erro
 	j.jar.SetCookies(u, []*http.Cookie{&c})
 	return true, nil
 }
+
+// Export returns the cookies in the jar as an array of plain objects, which can be returned
+// from setup() or saved as JSON and handed to import() on another jar.
+func (j HTTPCookieJar) Export() (goja.Value, error) {
+	rt := common.GetRuntime(*j.ctx)
+	data, err := json.Marshal(j.jar.Export())
+	if err != nil {
+		return nil, err
+	}
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	return parse(goja.Undefined(), rt.ToValue(string(data)))
+}
+
+// Import stores cookies from export(), given either as an array or its JSON encoding.
+func (j HTTPCookieJar) Import(cookiesV goja.Value) (bool, error) {
+	if cookiesV == nil || goja.IsUndefined(cookiesV) || goja.IsNull(cookiesV) {
+		return false, errors.New("import() needs the cookies to import")
+	}
+
+	var data []byte
+	if s, ok := cookiesV.Export().(string); ok {
+		data = []byte(s)
+	} else {
+		var err error
+		if data, err = json.Marshal(cookiesV.Export()); err != nil {
+			return false, err
+		}
+	}
+
+	var cookies []netext.JarCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return false, errors.Wrap(err, "invalid cookies")
+	}
+	if err := j.jar.Import(cookies); err != nil {
+		return false, err
+	}
+	return true, nil
+}
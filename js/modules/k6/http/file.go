@@ -21,9 +21,17 @@
 package http
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
 )
 
 // FileData represents a binary file requiring multipart request encoding
@@ -58,3 +66,145 @@ func (h *HTTP) File(data []byte, args ...string) FileData {
 		ContentType: ct,
 	}
 }
+
+// FileStream is a multipart request parameter whose contents are read from disk while the request
+// is sent, rather than held in memory like FileData.
+type FileStream struct {
+	Path        string
+	Filename    string
+	ContentType string
+	Size        int64
+}
+
+// FileStream returns a FileStream parameter for a local file. Paths are resolved like open() does,
+// and the file must exist on the machine running the test when requests are sent.
+func (h *HTTP) FileStream(ctx context.Context, path string, args ...string) (FileStream, error) {
+	env := common.GetInitEnv(ctx)
+	if env == nil || common.GetState(ctx) != nil {
+		return FileStream{}, errors.New("fileStream must be called in the init context")
+	}
+	if path == "" {
+		return FileStream{}, errors.New("fileStream needs a path")
+	}
+	filename := env.Resolve(path)
+	if !filepath.IsAbs(filepath.FromSlash(filename)) {
+		return FileStream{}, errors.Errorf("fileStream needs a local path, eg. ./%s", path)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return FileStream{}, err
+	}
+	if info.IsDir() {
+		return FileStream{}, errors.Errorf("%s is a directory", path)
+	}
+
+	fs := FileStream{
+		Path:        filename,
+		Filename:    filepath.Base(filename),
+		ContentType: "application/octet-stream",
+		Size:        info.Size(),
+	}
+	if len(args) > 0 && args[0] != "" {
+		fs.Filename = args[0]
+	}
+	if len(args) > 1 && args[1] != "" {
+		fs.ContentType = args[1]
+	}
+	return fs, nil
+}
+
+// A multipartBody is a multipart request body made of buffered segments and files streamed from
+// disk; it's written to by a multipart.Writer, with the files added in between.
+type multipartBody struct {
+	segments []interface{}
+	size     int64
+}
+
+func (b *multipartBody) Write(p []byte) (int, error) {
+	var buf *bytes.Buffer
+	if len(b.segments) > 0 {
+		buf, _ = b.segments[len(b.segments)-1].(*bytes.Buffer)
+	}
+	if buf == nil {
+		buf = &bytes.Buffer{}
+		b.segments = append(b.segments, buf)
+	}
+	b.size += int64(len(p))
+	return buf.Write(p)
+}
+
+func (b *multipartBody) addFile(fs FileStream) {
+	b.segments = append(b.segments, fs)
+	b.size += fs.Size
+}
+
+// buffer returns the whole body if it has no streamed files, or nil otherwise.
+func (b *multipartBody) buffer() *bytes.Buffer {
+	if len(b.segments) != 1 {
+		return nil
+	}
+	buf, _ := b.segments[0].(*bytes.Buffer)
+	return buf
+}
+
+// open returns a reader for the body, which opens the files as it gets to them. It can be called
+// again to send the body again, eg. after a redirect.
+func (b *multipartBody) open() (io.ReadCloser, error) {
+	return &multipartBodyReader{body: b}, nil
+}
+
+type multipartBodyReader struct {
+	body *multipartBody
+	next int
+	cur  io.Reader
+	file *os.File
+}
+
+func (r *multipartBodyReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if r.next >= len(r.body.segments) {
+				return 0, io.EOF
+			}
+			switch seg := r.body.segments[r.next].(type) {
+			case *bytes.Buffer:
+				r.cur = bytes.NewReader(seg.Bytes())
+			case FileStream:
+				f, err := os.Open(seg.Path)
+				if err != nil {
+					return 0, err
+				}
+				r.file = f
+				// Don't send more than was announced, in case the file grew.
+				r.cur = io.LimitReader(f, seg.Size)
+			}
+			r.next++
+		}
+
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur = nil
+			if err := r.closeFile(); err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (r *multipartBodyReader) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *multipartBodyReader) Close() error {
+	return r.closeFile()
+}
@@ -0,0 +1,147 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStream(t *testing.T) {
+	tb, _, rt, ctx := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	dir, err := ioutil.TempDir("", "k6-filestream")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "upload.bin"), data, 0644))
+	sum := sha256.Sum256(data)
+
+	tb.Mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%d", r.ContentLength)
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			h := sha256.New()
+			_, _ = io.Copy(h, part)
+			_, _ = fmt.Fprintf(w, " %s=%s;%s;%s", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), hex.EncodeToString(h.Sum(nil)))
+		}
+	})
+
+	tb.Mux.HandleFunc("/aws-upload", func(w http.ResponseWriter, r *http.Request) {
+		h := sha256.New()
+		_, _ = io.Copy(h, r.Body)
+		if hex.EncodeToString(h.Sum(nil)) != r.Header.Get("X-Amz-Content-Sha256") {
+			http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+		}
+	})
+
+	// fileStream() is only available in the init context.
+	vuCtx := *ctx
+	*ctx = common.WithInitEnv(common.WithRuntime(context.Background(), rt), &common.InitEnvironment{
+		Resolve: func(name string) string { return filepath.Join(dir, name) },
+	})
+	_, err = common.RunString(rt, `
+	var f1 = http.fileStream("upload.bin");
+	var f2 = http.fileStream("upload.bin", "data.csv", "text/csv");
+	`)
+	require.NoError(t, err)
+	_, err = common.RunString(rt, `http.fileStream("missing.bin")`)
+	assert.Error(t, err)
+	*ctx = vuCtx
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `http.fileStream("upload.bin")`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "fileStream must be called in the init context")
+		}
+	})
+
+	t.Run("Upload", func(t *testing.T) {
+		rt.Set("size", len(data))
+		rt.Set("sum", hex.EncodeToString(sum[:]))
+		textSum := sha256.Sum256([]byte("hello"))
+		rt.Set("textSum", hex.EncodeToString(textSum[:]))
+		_, err := common.RunString(rt, sr(`
+		var res = http.post("HTTPBIN_URL/upload", { file: f1 });
+		if (res.status !== 200) { throw new Error("wrong status: " + res.status + " " + res.body); }
+		var parts = res.body.split(" ");
+		if (parts[1] !== "file=upload.bin;application/octet-stream;" + sum) { throw new Error("wrong part: " + parts[1]); }
+		if (Number(parts[0]) <= size) { throw new Error("wrong content length: " + parts[0]); }
+		if (res.request.body !== "") { throw new Error("the body was buffered"); }
+
+		res = http.post("HTTPBIN_URL/upload", { a: f1, b: f2, c: "hello" });
+		parts = res.body.split(" ").slice(1).sort();
+		var want = [
+			"a=upload.bin;application/octet-stream;" + sum,
+			"b=data.csv;text/csv;" + sum,
+			"c=;;" + textSum,
+		];
+		if (JSON.stringify(parts) !== JSON.stringify(want)) { throw new Error("wrong parts: " + JSON.stringify(parts)); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		var res = http.post("HTTPBIN_URL/redirect-to?url=/upload&status_code=307", { file: f1 });
+		if (res.status !== 200 || res.body.split(" ")[1] !== "file=upload.bin;application/octet-stream;" + sum) {
+			throw new Error("wrong response: " + res.status + " " + res.body);
+		}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("AWS", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		var res = http.post("HTTPBIN_URL/aws-upload", { file: f1 }, {
+			aws: { accessKeyId: "AKID", secretAccessKey: "secret", region: "eu-west-1", service: "s3" },
+		});
+		if (res.status !== 200) { throw new Error("wrong status: " + res.status + " " + res.body); }
+		`))
+		assert.NoError(t, err)
+	})
+}
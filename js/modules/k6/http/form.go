@@ -0,0 +1,192 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	neturl "net/url"
+
+	"github.com/dop251/goja"
+)
+
+// A formField is a field of a form body. Values are strings, or files as FileData or FileStream.
+type formField struct {
+	name  string
+	value interface{}
+}
+
+// A formBody is a request body made of form fields, which are sent in order, and may repeat.
+type formBody struct {
+	fields []formField
+
+	// Sends the form as multipart/form-data even if it doesn't contain files.
+	multipart bool
+}
+
+// newFormBody returns the form body for an object passed as a request body. Array values are
+// sent as repeated fields.
+func newFormBody(data map[string]goja.Value) *formBody {
+	form := &formBody{}
+	for k, v := range data {
+		form.add(k, v)
+	}
+	return form
+}
+
+// add adds fields for a value, or for every element of an array.
+func (f *formBody) add(name string, v goja.Value) {
+	switch ve := v.Export().(type) {
+	case FileData, FileStream:
+		f.fields = append(f.fields, formField{name, ve})
+	case []string:
+		for _, s := range ve {
+			f.fields = append(f.fields, formField{name, s})
+		}
+	case []interface{}:
+		obj := v.(*goja.Object)
+		for i := range ve {
+			f.add(name, obj.Get(fmt.Sprint(i)))
+		}
+	default:
+		f.fields = append(f.fields, formField{name, v.String()})
+	}
+}
+
+// set replaces all fields with the given name, keeping the position of the first one.
+func (f *formBody) set(name string, v goja.Value) {
+	values := &formBody{}
+	values.add(name, v)
+	f.replace(name, values.fields...)
+}
+
+// replace replaces all fields with the given name with others, at the position of the first one,
+// or at the end if there was none.
+func (f *formBody) replace(name string, with ...formField) {
+	at := -1
+	fields := make([]formField, 0, len(f.fields)+len(with))
+	for _, field := range f.fields {
+		if field.name == name {
+			if at == -1 {
+				at = len(fields)
+				fields = append(fields, with...)
+			}
+			continue
+		}
+		fields = append(fields, field)
+	}
+	if at == -1 {
+		fields = append(fields, with...)
+	}
+	f.fields = fields
+}
+
+func (f *formBody) hasFiles() bool {
+	for _, field := range f.fields {
+		switch field.value.(type) {
+		case FileData, FileStream:
+			return true
+		}
+	}
+	return false
+}
+
+// query returns the fields as URL values, which can't contain files.
+func (f *formBody) query() (neturl.Values, error) {
+	values := make(neturl.Values, len(f.fields))
+	for _, field := range f.fields {
+		s, ok := field.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("file field '%s' can only be sent in a multipart body", field.name)
+		}
+		values.Add(field.name, s)
+	}
+	return values, nil
+}
+
+// encode returns the body and its content type. Bodies with files are multipart, and the ones
+// with streamed files are returned as a multipartBody rather than a buffer.
+func (f *formBody) encode() (*bytes.Buffer, *multipartBody, string, error) {
+	if !f.multipart && !f.hasFiles() {
+		values, err := f.query()
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return bytes.NewBufferString(values.Encode()), nil, "application/x-www-form-urlencoded", nil
+	}
+
+	mpBody := &multipartBody{}
+	mpw := multipart.NewWriter(mpBody)
+
+	// For fields of type FileData, created with http.file(), we write the file boundary to the
+	// body buffer. Otherwise fields are treated as standard form fields.
+	for _, field := range f.fields {
+		switch v := field.value.(type) {
+		case FileData:
+			// writing our own part to handle receiving
+			// different content-type than the default application/octet-stream
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition",
+				fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+					escapeQuotes(field.name), escapeQuotes(v.Filename)))
+			h.Set("Content-Type", v.ContentType)
+
+			// this writer will be closed either by the next part or
+			// the call to mpw.Close()
+			fw, err := mpw.CreatePart(h)
+			if err != nil {
+				return nil, nil, "", err
+			}
+			if _, err := fw.Write(v.Data); err != nil {
+				return nil, nil, "", err
+			}
+		case FileStream:
+			// The file is read while the request is sent, after this part's headers.
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition",
+				fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+					escapeQuotes(field.name), escapeQuotes(v.Filename)))
+			h.Set("Content-Type", v.ContentType)
+			if _, err := mpw.CreatePart(h); err != nil {
+				return nil, nil, "", err
+			}
+			mpBody.addFile(v)
+		case string:
+			fw, err := mpw.CreateFormField(field.name)
+			if err != nil {
+				return nil, nil, "", err
+			}
+			if _, err := fw.Write([]byte(v)); err != nil {
+				return nil, nil, "", err
+			}
+		}
+	}
+
+	if err := mpw.Close(); err != nil {
+		return nil, nil, "", err
+	}
+	if bodyBuf := mpBody.buffer(); bodyBuf != nil {
+		return bodyBuf, nil, mpw.FormDataContentType(), nil
+	}
+	return nil, mpBody, mpw.FormDataContentType(), nil
+}
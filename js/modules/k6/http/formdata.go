@@ -0,0 +1,184 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// FormData is a multipart form body with the API of the browsers' FormData. Its fields are sent
+// in order, without re-encoding them in JS, so it's also an efficient way to upload binary data.
+type FormData struct {
+	rt   *goja.Runtime
+	body formBody
+}
+
+func NewFormData(rt *goja.Runtime) *FormData {
+	return &FormData{rt: rt, body: formBody{multipart: true}}
+}
+
+func (*HTTP) XFormData(ctxPtr *context.Context) *FormData {
+	return NewFormData(common.GetRuntime(*ctxPtr))
+}
+
+// A blob is a Blob global, which is defined outside of this package.
+type blob interface {
+	Bytes() []byte
+	Text() string
+}
+
+// field returns a form field for a value, which is a string unless it's a file or binary data.
+// Like in browsers, binary data is sent as a file named "blob" unless it's given a filename.
+func (f *FormData) field(name string, value, filename goja.Value) (formField, error) {
+	if value == nil {
+		return formField{}, errors.New("a form field needs a name and a value")
+	}
+	hasFilename := filename != nil && !goja.IsUndefined(filename)
+
+	switch v := value.Export().(type) {
+	case FileData:
+		if hasFilename {
+			v.Filename = filename.String()
+		}
+		return formField{name, v}, nil
+	case FileStream:
+		if hasFilename {
+			v.Filename = filename.String()
+		}
+		return formField{name, v}, nil
+	case blob, []byte:
+		fd := FileData{Filename: "blob", ContentType: "application/octet-stream"}
+		if b, ok := v.(blob); ok {
+			fd.Data = b.Bytes()
+			if t := value.ToObject(f.rt).Get("type"); t != nil && t.String() != "" {
+				fd.ContentType = t.String()
+			}
+		} else {
+			fd.Data = v.([]byte)
+		}
+		if hasFilename {
+			fd.Filename = filename.String()
+		}
+		return formField{name, fd}, nil
+	default:
+		if hasFilename {
+			return formField{}, errors.Errorf("field '%s' isn't a file, so it can't have a filename", name)
+		}
+		return formField{name, value.String()}, nil
+	}
+}
+
+// Append adds a field, after any others with the same name.
+func (f *FormData) Append(name string, value, filename goja.Value) error {
+	field, err := f.field(name, value, filename)
+	if err != nil {
+		return err
+	}
+	f.body.fields = append(f.body.fields, field)
+	return nil
+}
+
+// Set replaces the fields with a name, or adds one.
+func (f *FormData) Set(name string, value, filename goja.Value) error {
+	field, err := f.field(name, value, filename)
+	if err != nil {
+		return err
+	}
+	f.body.replace(name, field)
+	return nil
+}
+
+func (f *FormData) Delete(name string) {
+	f.body.replace(name)
+}
+
+func (f *FormData) Has(name string) bool {
+	for _, field := range f.body.fields {
+		if field.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the value of the first field with a name, or null.
+func (f *FormData) Get(name string) goja.Value {
+	for _, field := range f.body.fields {
+		if field.name == name {
+			return f.rt.ToValue(field.value)
+		}
+	}
+	return goja.Null()
+}
+
+func (f *FormData) GetAll(name string) []goja.Value {
+	values := []goja.Value{}
+	for _, field := range f.body.fields {
+		if field.name == name {
+			values = append(values, f.rt.ToValue(field.value))
+		}
+	}
+	return values
+}
+
+// Entries returns the [name, value] pairs of the fields.
+func (f *FormData) Entries() [][]goja.Value {
+	entries := make([][]goja.Value, len(f.body.fields))
+	for i, field := range f.body.fields {
+		entries[i] = []goja.Value{f.rt.ToValue(field.name), f.rt.ToValue(field.value)}
+	}
+	return entries
+}
+
+func (f *FormData) Keys() []string {
+	keys := make([]string, len(f.body.fields))
+	for i, field := range f.body.fields {
+		keys[i] = field.name
+	}
+	return keys
+}
+
+func (f *FormData) Values() []goja.Value {
+	values := make([]goja.Value, len(f.body.fields))
+	for i, field := range f.body.fields {
+		values[i] = f.rt.ToValue(field.value)
+	}
+	return values
+}
+
+// ForEach calls a function with the value and name of every field.
+func (f *FormData) ForEach(fn goja.Value) error {
+	call, ok := goja.AssertFunction(fn)
+	if !ok {
+		return errors.New("argument to forEach() must be a function")
+	}
+	self := f.rt.ToValue(f)
+	for _, field := range append([]formField{}, f.body.fields...) {
+		if _, err := call(goja.Undefined(), f.rt.ToValue(field.value), f.rt.ToValue(field.name), self); err != nil {
+			return err
+		}
+	}
+	return nil
+}
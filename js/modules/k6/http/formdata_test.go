@@ -0,0 +1,78 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormData(t *testing.T) {
+	tb, _, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	tb.Mux.HandleFunc("/myforms/echo", myFormEchoHandler)
+
+	t.Run("Fields", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let fd = new http.FormData();
+		fd.append("a", "1");
+		fd.append("b", 2);
+		fd.append("a", "3");
+		if (fd.getAll("a").join() !== "1,3") { throw new Error("wrong values: " + fd.getAll("a")); }
+		if (fd.get("b") !== "2") { throw new Error("wrong value: " + fd.get("b")); }
+		if (fd.get("c") !== null) { throw new Error("wrong missing value: " + fd.get("c")); }
+		fd.set("a", "4");
+		if (fd.keys().join() !== "a,b" || fd.values().join() !== "4,2") { throw new Error("wrong fields after set: " + fd.entries()); }
+		fd.delete("a");
+		if (fd.has("a") || !fd.has("b")) { throw new Error("wrong fields after delete: " + fd.entries()); }
+		let names = [];
+		fd.forEach(function(value, name) { names.push(name + "=" + value); });
+		if (names.join() !== "b=2") { throw new Error("wrong forEach: " + names); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Post", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		let fd = new http.FormData();
+		fd.append("title", "My file");
+		fd.append("upload", http.file("file contents", "my.txt", "text/plain"));
+		fd.append("renamed", http.file("abc", "old.txt"), "new.txt");
+		let res = http.post("HTTPBIN_URL/myforms/echo", fd);
+		if (res.status !== 200) { throw new Error("wrong status: " + res.status + " " + res.body); }
+		let data = res.json();
+		if (data.form.title[0] !== "My file" ||
+			data.files.upload[0] !== "my.txt:text/plain:file contents" ||
+			data.files.renamed[0] !== "new.txt:application/octet-stream:abc"
+		) { throw new Error("incorrect body: " + JSON.stringify(data, null, 4) ); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("FilenameWithoutFile", func(t *testing.T) {
+		_, err := common.RunString(rt, `new http.FormData().append("a", "1", "a.txt");`)
+		assert.EqualError(t, err, "GoError: field 'a' isn't a file, so it can't have a filename")
+	})
+}
@@ -0,0 +1,175 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+)
+
+// GraphQL operations start with their type and, unless they're anonymous, their name; a query may
+// also be written in the shorthand form, eg. "{ user(id: 1) { name } }".
+var graphQLOperationRE = regexp.MustCompile(`^(query|mutation|subscription)\b\s*([_A-Za-z][_0-9A-Za-z]*)?`)
+
+// parseGraphQLOperation returns the type and name of the first operation in a document.
+func parseGraphQLOperation(query string) (opType, opName string) {
+	for _, line := range strings.Split(query, "\n") {
+		// Skip blank lines and comments.
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := graphQLOperationRE.FindStringSubmatch(line); m != nil {
+			return m[1], m[2]
+		}
+		return "query", ""
+	}
+	return "query", ""
+}
+
+type graphQLPersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     interface{}            `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// isPersistedQueryNotFound returns whether a server didn't recognize a persisted query's hash,
+// and needs the full query to register it.
+func isPersistedQueryNotFound(body string) bool {
+	var res struct {
+		Errors []struct {
+			Message    string `json:"message"`
+			Extensions struct {
+				Code string `json:"code"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		return false
+	}
+	for _, e := range res.Errors {
+		if e.Message == "PersistedQueryNotFound" || e.Extensions.Code == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+	return false
+}
+
+// Graphql POSTs a GraphQL query with its variables as JSON, and tags the request with the type and
+// name of the operation. Besides the regular request params, it takes operationName, to pick the
+// operation in a document that has several, and persisted, to send the query's hash instead of the
+// query itself, as in Apollo's automatic persisted queries, falling back to the full query if the
+// server doesn't know the hash yet.
+func (h *HTTP) Graphql(ctx context.Context, url goja.Value, query string, args ...goja.Value) (*HTTPResponse, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+
+	u, err := ToURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	gqlReq := graphQLRequest{Query: query}
+	if len(args) > 0 && !goja.IsUndefined(args[0]) && !goja.IsNull(args[0]) {
+		gqlReq.Variables = args[0].Export()
+	}
+
+	opType, opName := parseGraphQLOperation(query)
+	persisted := false
+	params := rt.NewObject()
+	tags := rt.NewObject()
+	headers := rt.NewObject()
+	_ = headers.Set("Content-Type", "application/json")
+	if len(args) > 1 && !goja.IsUndefined(args[1]) && !goja.IsNull(args[1]) {
+		paramsObj := args[1].ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			v := paramsObj.Get(k)
+			switch k {
+			case "operationName":
+				gqlReq.OperationName = v.String()
+				opName = gqlReq.OperationName
+			case "persisted":
+				persisted = v.ToBoolean()
+			case "headers", "tags":
+				if goja.IsUndefined(v) || goja.IsNull(v) {
+					continue
+				}
+				dst := headers
+				if k == "tags" {
+					dst = tags
+				}
+				obj := v.ToObject(rt)
+				for _, key := range obj.Keys() {
+					_ = dst.Set(key, obj.Get(key))
+				}
+			default:
+				_ = params.Set(k, v)
+			}
+		}
+	}
+	if tags.Get("graphql_operation_type") == nil {
+		_ = tags.Set("graphql_operation_type", opType)
+	}
+	if tags.Get("graphql_operation") == nil && opName != "" {
+		_ = tags.Set("graphql_operation", opName)
+	}
+	_ = params.Set("headers", headers)
+	_ = params.Set("tags", tags)
+
+	send := func(req graphQLRequest) (*HTTPResponse, error) {
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		res, samples, err := h.request(ctx, rt, state, HTTP_METHOD_POST, u, rt.ToValue(string(body)), params)
+		state.Samples = append(state.Samples, samples...)
+		return res, err
+	}
+
+	if !persisted {
+		return send(gqlReq)
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	gqlReq.Extensions = map[string]interface{}{
+		"persistedQuery": graphQLPersistedQuery{Version: 1, Sha256Hash: hex.EncodeToString(sum[:])},
+	}
+	hashOnly := gqlReq
+	hashOnly.Query = ""
+	res, err := send(hashOnly)
+	if err != nil || res == nil || !isPersistedQueryNotFound(res.bodyString()) {
+		return res, err
+	}
+	return send(gqlReq)
+}
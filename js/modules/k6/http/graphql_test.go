@@ -0,0 +1,138 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGraphQLOperation(t *testing.T) {
+	testdata := map[string][2]string{
+		`{ user(id: 1) { name } }`:                                      {"query", ""},
+		`query { user(id: 1) { name } }`:                                {"query", ""},
+		`query GetUser($id: ID!) { user(id: $id) { name } }`:            {"query", "GetUser"},
+		"# Creates a user\n\nmutation CreateUser { createUser { id } }": {"mutation", "CreateUser"},
+		`subscription OnMessage{ message }`:                             {"subscription", "OnMessage"},
+		`queryable`:                                                     {"query", ""},
+	}
+	for query, expected := range testdata {
+		opType, opName := parseGraphQLOperation(query)
+		assert.Equal(t, expected, [2]string{opType, opName}, query)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	tb, state, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	var mu sync.Mutex
+	persisted := map[string]string{}
+	tb.Mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if pq, ok := req.Extensions["persistedQuery"].(map[string]interface{}); ok {
+			hash := pq["sha256Hash"].(string)
+			if req.Query == "" {
+				if req.Query = persisted[hash]; req.Query == "" {
+					_, _ = w.Write([]byte(`{"errors": [{"message": "PersistedQueryNotFound"}]}`))
+					return
+				}
+			}
+			persisted[hash] = req.Query
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": req})
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var res = http.graphql("HTTPBIN_URL/graphql", "query GetUser($id: ID!) { user(id: $id) { name } }", { id: 1 }, { headers: { "X-Test": "1" } });
+		var data = res.json().data;
+		if (data.operationName !== undefined) { throw new Error("unexpected operationName: " + data.operationName); }
+		if (data.variables.id !== 1) { throw new Error("wrong variables: " + JSON.stringify(data.variables)); }
+		`))
+		assert.NoError(t, err)
+
+		require.NotEmpty(t, state.Samples)
+		for _, sample := range state.Samples {
+			tags := sample.Tags.CloneTags()
+			assert.Equal(t, "GetUser", tags["graphql_operation"])
+			assert.Equal(t, "query", tags["graphql_operation_type"])
+			assert.Equal(t, "POST", tags["method"])
+		}
+	})
+
+	t.Run("OperationName", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var doc = "query A { a } mutation B { b }";
+		var res = http.graphql("HTTPBIN_URL/graphql", doc, null, { operationName: "B", tags: { graphql_operation_type: "mutation", team: "x" } });
+		if (res.json().data.operationName !== "B") { throw new Error("wrong operationName"); }
+		`))
+		assert.NoError(t, err)
+
+		require.NotEmpty(t, state.Samples)
+		tags := state.Samples[0].Tags.CloneTags()
+		assert.Equal(t, "B", tags["graphql_operation"])
+		assert.Equal(t, "mutation", tags["graphql_operation_type"])
+		assert.Equal(t, "x", tags["team"])
+	})
+
+	t.Run("Persisted", func(t *testing.T) {
+		countRequests := func() (n int) {
+			for _, sample := range state.Samples {
+				if sample.Metric == metrics.HTTPReqs {
+					n++
+				}
+			}
+			return n
+		}
+
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var res = http.graphql("HTTPBIN_URL/graphql", "{ me { name } }", null, { persisted: true });
+		if (res.json().data.query !== "{ me { name } }") { throw new Error("wrong query: " + res.body); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, countRequests(), "the unknown hash should be followed by the full query")
+
+		state.Samples = nil
+		_, err = common.RunString(rt, sr(`
+		var res = http.graphql("HTTPBIN_URL/graphql", "{ me { name } }", null, { persisted: true });
+		if (res.json().data.query !== "{ me { name } }") { throw new Error("wrong query: " + res.body); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, countRequests(), "the known hash should be enough")
+	})
+}
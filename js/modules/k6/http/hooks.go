@@ -0,0 +1,129 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// SetHooks sets the hooks called around every HTTP request the VU makes, for things that apply to
+// all of them, like signing requests or scrubbing responses: beforeRequest(req) is called with
+// the request about to be made, whose url and headers it may change, and afterResponse(res) with
+// the response before it's returned, which it may change too. null removes them.
+func (*HTTP) SetHooks(ctx context.Context, v goja.Value) {
+	rt := common.GetRuntime(ctx)
+
+	var hooks *common.HTTPHooks
+	if state := common.GetState(ctx); state != nil {
+		hooks = state.HTTPHooks
+	} else if env := common.GetInitEnv(ctx); env != nil {
+		hooks = env.HTTPHooks
+	}
+	if hooks == nil {
+		common.Throw(rt, errors.New("setHooks can't be used here"))
+	}
+
+	var beforeRequest, afterResponse goja.Callable
+	if !goja.IsUndefined(v) && !goja.IsNull(v) {
+		obj := v.ToObject(rt)
+		for _, k := range obj.Keys() {
+			hookV := obj.Get(k)
+			if goja.IsUndefined(hookV) || goja.IsNull(hookV) {
+				continue
+			}
+			fn, ok := goja.AssertFunction(hookV)
+			if !ok {
+				common.Throw(rt, errors.Errorf("the %s hook must be a function, got %s", k, hookV))
+			}
+			switch k {
+			case "beforeRequest":
+				beforeRequest = fn
+			case "afterResponse":
+				afterResponse = fn
+			default:
+				common.Throw(rt, errors.Errorf("unknown hook: %s; there are beforeRequest and afterResponse", k))
+			}
+		}
+	}
+	hooks.BeforeRequest, hooks.AfterResponse = beforeRequest, afterResponse
+}
+
+// callBeforeRequest calls the beforeRequest hook with the request, then applies the changes it
+// made to the URL and headers.
+func callBeforeRequest(rt *goja.Runtime, hooks *common.HTTPHooks, req *http.Request, body string) error {
+	return hooks.Run(func() error {
+		headers := rt.NewObject()
+		for k, vs := range req.Header {
+			_ = headers.Set(k, strings.Join(vs, ", "))
+		}
+		if req.Host != "" {
+			_ = headers.Set("Host", req.Host)
+		}
+		url := req.URL.String()
+		obj := rt.NewObject()
+		_ = obj.Set("method", req.Method)
+		_ = obj.Set("url", url)
+		_ = obj.Set("headers", headers)
+		_ = obj.Set("body", body)
+
+		if _, err := hooks.BeforeRequest(goja.Undefined(), obj); err != nil {
+			return err
+		}
+
+		if newURL := obj.Get("url").String(); newURL != url {
+			u, err := neturl.Parse(newURL)
+			if err != nil {
+				return errors.Wrap(err, "beforeRequest hook set an invalid url")
+			}
+			req.URL = u
+		}
+		req.Header = make(http.Header)
+		req.Host = ""
+		if headersV := obj.Get("headers"); !goja.IsUndefined(headersV) && !goja.IsNull(headersV) {
+			headers := headersV.ToObject(rt)
+			for _, k := range headers.Keys() {
+				str := headers.Get(k).String()
+				switch strings.ToLower(k) {
+				case "host":
+					req.Host = str
+				default:
+					req.Header.Set(k, str)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// callAfterResponse calls the afterResponse hook with the response.
+func callAfterResponse(rt *goja.Runtime, hooks *common.HTTPHooks, resp *HTTPResponse) error {
+	return hooks.Run(func() error {
+		_, err := hooks.AfterResponse(goja.Undefined(), rt.ToValue(resp))
+		return err
+	})
+}
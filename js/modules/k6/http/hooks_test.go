@@ -0,0 +1,114 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	tb, state, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	hooks := &common.HTTPHooks{}
+	state.HTTPHooks = hooks
+	defer func() { state.HTTPHooks = nil }()
+
+	t.Run("beforeRequest", func(t *testing.T) {
+		defer func() { *hooks = common.HTTPHooks{} }()
+		_, err := common.RunString(rt, sr(`
+		http.setHooks({
+			beforeRequest: function(req) {
+				req.headers["X-Signature"] = req.method + " " + req.url + " " + req.body;
+				delete req.headers["X-Remove"];
+				req.url += "?signed=1";
+			},
+		});
+		var res = http.post("HTTPBIN_URL/post", "data", { headers: { "X-Remove": "1" } });
+		if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+		var headers = res.json().headers;
+		if (headers["X-Signature"] != "POST HTTPBIN_URL/post data") { throw new Error("wrong signature: " + headers["X-Signature"]); }
+		if (headers["X-Remove"] !== undefined) { throw new Error("header not removed: " + headers["X-Remove"]); }
+		if (res.json().args.signed != "1") { throw new Error("wrong args: " + JSON.stringify(res.json().args)); }
+		if (res.request.url != "HTTPBIN_URL/post?signed=1") { throw new Error("wrong request url: " + res.request.url); }
+
+		var reqs = [];
+		for (var i = 0; i < 5; i++) {
+			reqs.push(["POST", "HTTPBIN_URL/post", "data"]);
+		}
+		var responses = http.batch(reqs);
+		for (var key in responses) {
+			if (responses[key].json().headers["X-Signature"] != "POST HTTPBIN_URL/post data") { throw new Error("wrong batch signature: " + key); }
+		}
+		`))
+		require.NoError(t, err)
+	})
+	t.Run("afterResponse", func(t *testing.T) {
+		defer func() { *hooks = common.HTTPHooks{} }()
+		_, err := common.RunString(rt, sr(`
+		http.setHooks({
+			afterResponse: function(res) {
+				res.body = res.body.replace("secret", "[scrubbed]");
+			},
+		});
+		var res = http.get("HTTPBIN_URL/get?token=secret");
+		if (res.json().args.token != "[scrubbed]") { throw new Error("wrong body: " + res.body); }
+		`))
+		require.NoError(t, err)
+	})
+	t.Run("removed", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		http.setHooks({ afterResponse: function(res) { res.body = "{}"; } });
+		http.setHooks(null);
+		var res = http.get("HTTPBIN_URL/get?a=1");
+		if (res.json().args.a != "1") { throw new Error("wrong body: " + res.body); }
+		`))
+		require.NoError(t, err)
+		assert.Nil(t, hooks.BeforeRequest)
+		assert.Nil(t, hooks.AfterResponse)
+	})
+	t.Run("throws", func(t *testing.T) {
+		defer func() { *hooks = common.HTTPHooks{} }()
+		_, err := common.RunString(rt, sr(`
+		http.setHooks({ beforeRequest: function(req) { throw new Error("no signing key"); } });
+		http.get("HTTPBIN_URL/get");
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no signing key")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			"not a function": `http.setHooks({ beforeRequest: "sign" })`,
+			"unknown hook":   `http.setHooks({ beforeResponse: function() {} })`,
+		}
+		for name, src := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := common.RunString(rt, src)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
@@ -23,13 +23,13 @@ package http
 import (
 	"context"
 	"net/http"
-	"net/http/cookiejar"
 	"reflect"
 
 	"fmt"
 	"net/http/httputil"
 
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/netext"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -132,7 +132,7 @@ func (*HTTP) CookieJar(ctx context.Context) *HTTPCookieJar {
 	return &HTTPCookieJar{state.CookieJar, &ctx}
 }
 
-func (*HTTP) mergeCookies(req *http.Request, jar *cookiejar.Jar, reqCookies map[string]*HTTPRequestCookie) map[string][]*HTTPRequestCookie {
+func (*HTTP) mergeCookies(req *http.Request, jar *netext.CookieJar, reqCookies map[string]*HTTPRequestCookie) map[string][]*HTTPRequestCookie {
 	allCookies := make(map[string][]*HTTPRequestCookie)
 	for _, c := range jar.Cookies(req.URL) {
 		allCookies[c.Name] = append(allCookies[c.Name], &HTTPRequestCookie{Name: c.Name, Value: c.Value})
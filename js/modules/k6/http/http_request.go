@@ -22,17 +22,12 @@ package http
 
 import (
 	"bytes"
-	"compress/gzip"
-	"compress/zlib"
 	"context"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
+	"math/rand"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
-	"net/textproto"
 	neturl "net/url"
 	"strconv"
 	"strings"
@@ -42,8 +37,12 @@ import (
 	digest "github.com/Soontao/goHttpDigestClient"
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	null "gopkg.in/guregu/null.v3"
 )
@@ -101,66 +100,30 @@ func (http *HTTP) Request(ctx context.Context, method string, url goja.Value, ar
 	return res, err
 }
 
-func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.State, method string, url URL, args ...goja.Value) (*HTTPResponse, []stats.Sample, error) {
+// requestOnce makes a request, without retrying it.
+func (h *HTTP) requestOnce(ctx context.Context, rt *goja.Runtime, state *common.State, method string, url URL, args ...goja.Value) (*HTTPResponse, []stats.Sample, error) {
 	var bodyBuf *bytes.Buffer
+	var streamBody *multipartBody
 	var contentType string
 	if len(args) > 0 && !goja.IsUndefined(args[0]) && !goja.IsNull(args[0]) {
 		var data map[string]goja.Value
-		if rt.ExportTo(args[0], &data) == nil {
-			// handling multipart request
-			if requestContainsFile(data) {
-				bodyBuf = &bytes.Buffer{}
-				mpw := multipart.NewWriter(bodyBuf)
-
-				// For parameters of type common.FileData, created with open(file, "b"),
-				// we write the file boundary to the body buffer.
-				// Otherwise parameters are treated as standard form field.
-				for k, v := range data {
-					switch ve := v.Export().(type) {
-					case FileData:
-						// writing our own part to handle receiving
-						// different content-type than the default application/octet-stream
-						h := make(textproto.MIMEHeader)
-						escapedFilename := escapeQuotes(ve.Filename)
-						h.Set("Content-Disposition",
-							fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
-								k, escapedFilename))
-						h.Set("Content-Type", ve.ContentType)
-
-						// this writer will be closed either by the next part or
-						// the call to mpw.Close()
-						fw, err := mpw.CreatePart(h)
-						if err != nil {
-							return nil, nil, err
-						}
-
-						if _, err := fw.Write(ve.Data); err != nil {
-							return nil, nil, err
-						}
-					default:
-						fw, err := mpw.CreateFormField(k)
-						if err != nil {
-							return nil, nil, err
-						}
-
-						if _, err := fw.Write([]byte(v.String())); err != nil {
-							return nil, nil, err
-						}
-					}
-				}
-
-				if err := mpw.Close(); err != nil {
-					return nil, nil, err
-				}
-
-				contentType = mpw.FormDataContentType()
-			} else {
-				bodyQuery := make(neturl.Values, len(data))
-				for k, v := range data {
-					bodyQuery.Set(k, v.String())
-				}
-				bodyBuf = bytes.NewBufferString(bodyQuery.Encode())
-				contentType = "application/x-www-form-urlencoded"
+		var form *formBody
+		isForm := true
+		switch v := args[0].Export().(type) {
+		case *formBody:
+			form = v
+		case *FormData:
+			form = &v.body
+		default:
+			isForm = false
+		}
+		if !isForm && rt.ExportTo(args[0], &data) == nil {
+			form, isForm = newFormBody(data), true
+		}
+		if isForm {
+			var err error
+			if bodyBuf, streamBody, contentType, err = form.encode(); err != nil {
+				return nil, nil, err
 			}
 		} else {
 			bodyBuf = bytes.NewBufferString(args[0].String())
@@ -181,14 +144,46 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 		req.ContentLength = int64(bodyBuf.Len())
 		respReq.Body = bodyBuf.String()
 	}
+	if streamBody != nil {
+		// Streamed bodies are too big to keep a copy of, so res.request.body is left empty.
+		req.Body, _ = streamBody.open()
+		req.GetBody = streamBody.open
+		req.ContentLength = streamBody.size
+	}
 	if contentType != "" {
 		req.Header.Set("Content-Type", contentType)
 	}
 	if userAgent := state.Options.UserAgent; userAgent.String != "" {
 		req.Header.Set("User-Agent", userAgent.String)
 	}
+	if profile := state.Profile; profile != nil {
+		// A userAgent set in the options wins over the profile's; only the default gives way.
+		if !state.Options.UserAgent.Valid {
+			req.Header.Set("User-Agent", profile.UserAgent)
+		}
+		req.Header.Set("Accept", profile.Accept)
+		req.Header.Set("Accept-Language", profile.AcceptLanguage)
+	}
+	for k, v := range state.Headers {
+		req.Header.Set(k, v)
+	}
 
-	tags := state.Options.RunTags.CloneTags()
+	// Every request starts a trace of its own; headers set in params take precedence.
+	var traceID string
+	if tracing := state.Options.Tracing; tracing.Enabled() {
+		rate := tracing.SamplingRate()
+		tc, err := netext.NewTraceContext(rate >= 1 || rand.Float64() < rate)
+		if err != nil {
+			return nil, nil, err
+		}
+		tc.Inject(tracing.Propagator.String, req.Header)
+		traceID = tc.TraceIDString()
+	}
+
+	tags := state.CloneTags()
+	if traceID != "" && state.Options.SystemTags["trace_id"] {
+		tags["trace_id"] = traceID
+	}
 	if state.Options.SystemTags["method"] {
 		tags["method"] = method
 	}
@@ -196,7 +191,14 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 		tags["url"] = url.URLString
 	}
 	if state.Options.SystemTags["name"] {
-		tags["name"] = url.Name
+		name := url.Name
+		// URLs that weren't made with http.url are named by the first URL group they match.
+		if name == url.URLString {
+			if groupName, ok := state.Options.URLGroups.Match(url.URLString); ok {
+				name = groupName
+			}
+		}
+		tags["name"] = name
 	}
 	if state.Options.SystemTags["group"] {
 		tags["group"] = state.Group.Path
@@ -207,13 +209,27 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 	if state.Options.SystemTags["iter"] {
 		tags["iter"] = strconv.FormatInt(state.Iteration, 10)
 	}
+	if retries, ok := ctx.Value(ctxKeyRetries).(int); ok && state.Options.SystemTags["retries"] {
+		tags["retries"] = strconv.Itoa(retries)
+	}
 
 	redirects := state.Options.MaxRedirects
 	timeout := 60 * time.Second
 	throw := state.Options.Throw.Bool
+	responseType := ResponseTypeText
+	if state.Options.DiscardResponseBodies.Bool {
+		responseType = ResponseTypeNone
+	}
 	auth := ""
+	var compression []string
+	var awsCreds *AWSCredentials
+	var clientCerts []netext.ClientCertificate
+	var responseCallback common.ResponseCallback = common.DefaultResponseCallback
+	if state.ResponseCallback != nil {
+		responseCallback = *state.ResponseCallback
+	}
 
-	var activeJar *cookiejar.Jar
+	var activeJar *netext.CookieJar
 	if state.CookieJar != nil {
 		activeJar = state.CookieJar
 	}
@@ -298,10 +314,95 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 					}
 				case "auth":
 					auth = params.Get(k).String()
+				case "compression":
+					compressionV := params.Get(k)
+					if goja.IsUndefined(compressionV) || goja.IsNull(compressionV) {
+						continue
+					}
+					var err error
+					if compression, err = parseCompression(compressionV.String()); err != nil {
+						return nil, nil, err
+					}
+				case "oauth2":
+					oauth2V := params.Get(k)
+					if goja.IsUndefined(oauth2V) || goja.IsNull(oauth2V) {
+						continue
+					}
+					client, ok := oauth2V.Export().(*OAuth2Client)
+					if !ok {
+						return nil, nil, errors.New("the oauth2 param must be an http.OAuth2Client")
+					}
+					authorization, err := client.authorization(ctx)
+					if err != nil {
+						return nil, nil, err
+					}
+					req.Header.Set("Authorization", authorization)
+				case "aws":
+					awsV := params.Get(k)
+					if goja.IsUndefined(awsV) || goja.IsNull(awsV) {
+						continue
+					}
+					creds, err := parseAWSCredentials(rt, awsV)
+					if err != nil {
+						return nil, nil, err
+					}
+					awsCreds = creds
+				case "tlsAuth":
+					tlsAuthV := params.Get(k)
+					if goja.IsUndefined(tlsAuthV) || goja.IsNull(tlsAuthV) {
+						continue
+					}
+					certs, err := parseClientCertificates(tlsAuthV)
+					if err != nil {
+						return nil, nil, err
+					}
+					clientCerts = certs
 				case "timeout":
 					timeout = time.Duration(params.Get(k).ToFloat() * float64(time.Millisecond))
+				case "connectTimeout":
+					ctx = netext.WithConnectTimeout(ctx, time.Duration(params.Get(k).ToFloat()*float64(time.Millisecond)))
+				case "tlsHandshakeTimeout":
+					ctx = netext.WithTLSHandshakeTimeout(ctx, time.Duration(params.Get(k).ToFloat()*float64(time.Millisecond)))
+				case "httpVersion":
+					versionV := params.Get(k)
+					if goja.IsUndefined(versionV) || goja.IsNull(versionV) {
+						continue
+					}
+					version, err := types.ParseHTTPVersion(versionV.String())
+					if err != nil {
+						return nil, nil, err
+					}
+					ctx = netext.WithHTTPVersion(ctx, version)
 				case "throw":
 					throw = params.Get(k).ToBoolean()
+				case "responseType":
+					responseTypeV := params.Get(k)
+					if goja.IsUndefined(responseTypeV) || goja.IsNull(responseTypeV) {
+						continue
+					}
+					var err error
+					if responseType, err = ParseResponseType(responseTypeV.String()); err != nil {
+						return nil, nil, err
+					}
+				case "responseCallback":
+					var err error
+					if responseCallback, err = toResponseCallback(params.Get(k)); err != nil {
+						return nil, nil, err
+					}
+				case "proxy":
+					proxyV := params.Get(k)
+					if goja.IsUndefined(proxyV) || goja.IsNull(proxyV) {
+						continue
+					}
+					// An empty proxy makes the request directly, whatever the proxy option says.
+					var proxyURL *neturl.URL
+					if s := proxyV.String(); s != "" {
+						var err error
+						if proxyURL, err = types.ParseProxyURL(s); err != nil {
+							return nil, nil, err
+						}
+					}
+					ctx = netext.WithProxy(ctx, proxyURL)
 				}
 			}
 		}
@@ -313,12 +414,46 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 		h.setRequestCookies(req, mergedCookies)
 	}
 
+	if len(compression) > 0 && (bodyBuf != nil || streamBody != nil) {
+		if streamBody != nil {
+			return nil, nil, errors.New("streamed file uploads can't be compressed")
+		}
+		// res.request.body keeps the uncompressed body, which is what the script sent.
+		var err error
+		if bodyBuf, err = compressBody(compression, bodyBuf); err != nil {
+			return nil, nil, err
+		}
+		req.Body = ioutil.NopCloser(bodyBuf)
+		req.ContentLength = int64(bodyBuf.Len())
+		req.Header.Set("Content-Encoding", strings.Join(compression, ", "))
+	}
+
+	if hooks := state.HTTPHooks; hooks != nil && hooks.BeforeRequest != nil {
+		if err := callBeforeRequest(rt, hooks, req, respReq.Body); err != nil {
+			return nil, nil, err
+		}
+		respReq.URL = req.URL.String()
+	}
+
+	if awsCreds != nil {
+		payloadHash, err := awsPayloadHash(bodyBuf, streamBody)
+		if err != nil {
+			return nil, nil, err
+		}
+		signAWSv4(req, payloadHash, awsCreds, time.Now())
+	}
+
 	// Check rate limit *after* we've prepared a request; no need to wait with that part.
 	if rpsLimit := state.RPSLimit; rpsLimit != nil {
 		if err := rpsLimit.Wait(ctx); err != nil {
 			return nil, nil, err
 		}
 	}
+	if sc := lib.GetScenario(ctx); sc != nil && sc.RPSLimit != nil {
+		if err := sc.RPSLimit.Wait(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	respReq.Headers = req.Header
 
@@ -397,8 +532,11 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 		statsSamples = append(statsSamples, tracer.Done().Samples(stats.NewSampleTags(tags))...)
 	}
 
-	if auth == "ntlm" {
-		ctx = netext.WithAuth(ctx, "ntlm")
+	if auth == "ntlm" || auth == "negotiate" {
+		ctx = netext.WithAuth(ctx, auth)
+	}
+	if clientCerts != nil {
+		ctx = netext.WithClientCertificates(ctx, clientCerts)
 	}
 
 	tracer := netext.Tracer{}
@@ -406,22 +544,30 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 	res, resErr := client.Do(req.WithContext(netext.WithTracer(ctx, &tracer)))
 	h.debugResponse(state, res, "Response")
 	if resErr == nil && res != nil {
-		switch res.Header.Get("Content-Encoding") {
-		case "deflate":
-			res.Body, resErr = zlib.NewReader(res.Body)
-		case "gzip":
-			res.Body, resErr = gzip.NewReader(res.Body)
+		if contentEncoding := res.Header.Get("Content-Encoding"); contentEncoding != "" {
+			res.Body, resErr = decompressBody(res.Body, contentEncoding)
 		}
 	}
 	if resErr == nil && res != nil {
 		buf := state.BPool.Get()
 		buf.Reset()
 		defer state.BPool.Put(buf)
-		_, err := io.Copy(buf, res.Body)
+
+		// Discarded bodies are still read, so the timings and data_received are accurate.
+		var dst io.Writer = buf
+		if responseType == ResponseTypeNone {
+			dst = ioutil.Discard
+		}
+		_, err := io.Copy(dst, res.Body)
 		if err != nil && err != io.EOF {
 			resErr = err
 		}
-		resp.Body = buf.String()
+		switch responseType {
+		case ResponseTypeText:
+			resp.Body = buf.String()
+		case ResponseTypeBinary:
+			resp.Body = append([]byte(nil), buf.Bytes()...)
+		}
 		_ = res.Body.Close()
 	}
 	trail := tracer.Done()
@@ -442,10 +588,16 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 	}
 
 	if resErr != nil {
-		resp.Error = resErr.Error()
+		netErr := netext.NewError(resErr)
+		resErr = netErr
+		resp.Error = netErr.Message
+		resp.ErrorCode = netErr.Code
 		if state.Options.SystemTags["error"] {
 			tags["error"] = resp.Error
 		}
+		if state.Options.SystemTags["error_code"] {
+			tags["error_code"] = strconv.Itoa(resp.ErrorCode)
+		}
 
 		//TODO: expand/replace this so we can recognize the different non-HTTP
 		// errors, probably by using a type switch for resErr
@@ -517,27 +669,74 @@ func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.Stat
 		}
 	}
 
-	statsSamples = append(statsSamples, trail.Samples(stats.IntoSampleTags(&tags))...)
+	expected := true
+	if responseCallback != nil {
+		expected = responseCallback(resp.Status)
+		if state.Options.SystemTags["expected_response"] {
+			tags["expected_response"] = strconv.FormatBool(expected)
+		}
+	}
+
+	sampleTags := stats.IntoSampleTags(&tags)
+	statsSamples = append(statsSamples, trail.Samples(sampleTags)...)
+	if responseCallback != nil {
+		failed := 0.0
+		if !expected {
+			failed = 1
+		}
+		statsSamples = append(statsSamples, stats.Sample{
+			Metric: metrics.HTTPReqFailed, Time: trail.EndTime, Tags: sampleTags, Value: failed,
+		})
+	}
+
+	// The hook only changes what the script sees; the metrics are of the actual response.
+	if hooks := state.HTTPHooks; hooks != nil && hooks.AfterResponse != nil {
+		if err := callAfterResponse(rt, hooks, resp); err != nil {
+			return nil, statsSamples, err
+		}
+	}
 	return resp, statsSamples, nil
 }
 
-func (http *HTTP) Batch(ctx context.Context, reqsV goja.Value) (goja.Value, error) {
+// Batch makes several requests in parallel. How many may be in flight at once, in total and to any
+// one host, is set by the batch and batchPerHost options, which params can override for the call.
+func (http *HTTP) Batch(ctx context.Context, reqsV goja.Value, params ...goja.Value) (goja.Value, error) {
 	rt := common.GetRuntime(ctx)
 	state := common.GetState(ctx)
 
-	// Return values; retval must be guarded by the mutex.
-	var mutex sync.Mutex
-	retval := rt.NewObject()
-	errs := make(chan error)
-
 	// Concurrency limits.
-	globalLimiter := NewSlotLimiter(int(state.Options.Batch.Int64))
-	perHostLimiter := NewMultiSlotLimiter(int(state.Options.BatchPerHost.Int64))
-
+	batch, batchPerHost := int64(lib.DefaultBatch), int64(lib.DefaultBatchPerHost)
+	if state.Options.Batch.Valid {
+		batch = state.Options.Batch.Int64
+	}
+	if state.Options.BatchPerHost.Valid {
+		batchPerHost = state.Options.BatchPerHost.Int64
+	}
+	if len(params) > 0 && !goja.IsUndefined(params[0]) && !goja.IsNull(params[0]) {
+		paramsObj := params[0].ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			switch k {
+			case "batch":
+				batch = paramsObj.Get(k).ToInteger()
+			case "batchPerHost":
+				batchPerHost = paramsObj.Get(k).ToInteger()
+			}
+		}
+	}
+	globalLimiter := NewSlotLimiter(int(batch))
+	perHostLimiter := NewMultiSlotLimiter(int(batchPerHost))
+
+	// Parse all of the requests before any is made, so an invalid one doesn't leave others running.
+	type batchRequest struct {
+		key    string
+		method string
+		url    URL
+		args   []goja.Value
+	}
 	reqs := reqsV.ToObject(rt)
 	keys := reqs.Keys()
+	batchReqs := make([]batchRequest, 0, len(keys))
 	for _, k := range keys {
-		k := k
 		v := reqs.Get(k)
 
 		method := HTTP_METHOD_GET
@@ -576,24 +775,35 @@ func (http *HTTP) Batch(ctx context.Context, reqsV goja.Value) (goja.Value, erro
 				}
 			}
 		}
+		batchReqs = append(batchReqs, batchRequest{k, method, url, args})
+	}
+
+	// Return values; retval must be guarded by the mutex.
+	var mutex sync.Mutex
+	retval := rt.NewObject()
+	errs := make(chan error)
 
+	// The requests are started in order; each waits for a slot for its host first, so one that has
+	// to wait for its host doesn't hold up requests to others.
+	for _, req := range batchReqs {
+		req := req
+		hostLimiter := perHostLimiter.Slot(req.url.URL.Host)
 		go func() {
+			if hostLimiter != nil {
+				hostLimiter.Begin()
+				defer hostLimiter.End()
+			}
 			globalLimiter.Begin()
 			defer globalLimiter.End()
 
-			if hl := perHostLimiter.Slot(url.URL.Host); hl != nil {
-				hl.Begin()
-				defer hl.End()
-			}
-
-			res, samples, err := http.request(ctx, rt, state, method, url, args...)
+			res, samples, err := http.request(ctx, rt, state, req.method, req.url, req.args...)
 			if err != nil {
 				errs <- err
 				return
 			}
 
 			mutex.Lock()
-			_ = retval.Set(k, res)
+			_ = retval.Set(req.key, res)
 			state.Samples = append(state.Samples, samples...)
 			mutex.Unlock()
 
@@ -602,20 +812,10 @@ func (http *HTTP) Batch(ctx context.Context, reqsV goja.Value) (goja.Value, erro
 	}
 
 	var err error
-	for range keys {
+	for range batchReqs {
 		if e := <-errs; e != nil {
 			err = e
 		}
 	}
 	return retval, err
 }
-
-func requestContainsFile(data map[string]goja.Value) bool {
-	for _, v := range data {
-		switch v.Export().(type) {
-		case FileData:
-			return true
-		}
-	}
-	return false
-}
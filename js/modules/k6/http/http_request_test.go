@@ -25,10 +25,12 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -39,6 +41,7 @@ import (
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/netext"
 	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 	"github.com/oxtoacart/bpool"
 	"github.com/sirupsen/logrus"
@@ -223,6 +226,28 @@ func TestRequestAndBatch(t *testing.T) {
 			}
 		})
 	})
+	t.Run("TLSHandshakeTimeout", func(t *testing.T) {
+		// Takes connections, but never starts the handshake.
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = l.Close() }()
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer func() { _ = conn.Close() }()
+			}
+		}()
+		rt.Set("silentURL", "https://"+l.Addr().String()+"/")
+
+		_, err = common.RunString(rt, `
+			let res = http.get(silentURL, { tlsHandshakeTimeout: 100, connectTimeout: 1000, throw: false });
+			if (res.error_code !== 1330) { throw new Error("wrong error code: " + res.error_code); }
+		`)
+		assert.NoError(t, err)
+	})
 	t.Run("UserAgent", func(t *testing.T) {
 		_, err := common.RunString(rt, sr(`
 			let res = http.get("HTTPBIN_URL/user-agent");
@@ -243,6 +268,68 @@ func TestRequestAndBatch(t *testing.T) {
 			`))
 			assert.NoError(t, err)
 		})
+
+		t.Run("ClientProfile", func(t *testing.T) {
+			// The default user agent, which isn't set explicitly, gives way to the profile's.
+			userAgent := state.Options.UserAgent
+			state.Options.UserAgent = null.NewString("k6/test", false)
+			state.Profile = lib.ClientProfiles["firefox-desktop"]
+			defer func() { state.Options.UserAgent, state.Profile = userAgent, nil }()
+
+			_, err := common.RunString(rt, sr(`
+				let res = http.get("HTTPBIN_URL/headers");
+				let headers = res.json().headers;
+				if (String(headers["User-Agent"]).indexOf("Firefox/") === -1) {
+					throw new Error("incorrect user agent: " + headers["User-Agent"]);
+				}
+				if (String(headers["Accept-Language"]) != "en-US,en;q=0.5") {
+					throw new Error("incorrect accept language: " + headers["Accept-Language"]);
+				}
+				res = http.get("HTTPBIN_URL/headers", { headers: { "Accept-Language": "sv-SE" } });
+				if (String(res.json().headers["Accept-Language"]) != "sv-SE") {
+					throw new Error("accept language not overridden: " + res.json().headers["Accept-Language"]);
+				}
+			`))
+			assert.NoError(t, err)
+		})
+
+		t.Run("ClientProfileUserAgent", func(t *testing.T) {
+			state.Profile = lib.ClientProfiles["firefox-desktop"]
+			defer func() { state.Profile = nil }()
+
+			_, err := common.RunString(rt, sr(`
+				let headers = http.get("HTTPBIN_URL/headers").json().headers;
+				if (headers["User-Agent"] != "TestUserAgent") {
+					throw new Error("incorrect user agent: " + headers["User-Agent"]);
+				}
+				if (String(headers["Accept-Language"]) != "en-US,en;q=0.5") {
+					throw new Error("incorrect accept language: " + headers["Accept-Language"]);
+				}
+			`))
+			assert.NoError(t, err)
+		})
+
+		t.Run("RegionHeaders", func(t *testing.T) {
+			state.Profile = lib.ClientProfiles["firefox-desktop"]
+			state.Headers = map[string]string{"Accept-Language": "de-DE", "X-Forwarded-For": "192.0.2.1"}
+			defer func() { state.Profile, state.Headers = nil, nil }()
+
+			_, err := common.RunString(rt, sr(`
+				let res = http.get("HTTPBIN_URL/headers");
+				let headers = res.json().headers;
+				if (String(headers["Accept-Language"]) != "de-DE") {
+					throw new Error("incorrect accept language: " + headers["Accept-Language"]);
+				}
+				if (String(headers["X-Forwarded-For"]) != "192.0.2.1") {
+					throw new Error("incorrect forwarded for: " + headers["X-Forwarded-For"]);
+				}
+				res = http.get("HTTPBIN_URL/headers", { headers: { "X-Forwarded-For": "192.0.2.2" } });
+				if (String(res.json().headers["X-Forwarded-For"]) != "192.0.2.2") {
+					throw new Error("forwarded for not overridden: " + res.json().headers["X-Forwarded-For"]);
+				}
+			`))
+			assert.NoError(t, err)
+		})
 	})
 	t.Run("Compression", func(t *testing.T) {
 		t.Run("gzip", func(t *testing.T) {
@@ -315,6 +402,22 @@ func TestRequestAndBatch(t *testing.T) {
 			assert.Equal(t, "HTTP/2.0", proto)
 		}
 	})
+	t.Run("httpVersion", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		let res = http.get("HTTPSBIN_URL/get", { httpVersion: 1.1 });
+		if (res.proto != "HTTP/1.1") { throw new Error("wrong proto: " + res.proto) }
+		`))
+		assert.NoError(t, err)
+		for _, sample := range state.Samples {
+			proto, ok := sample.Tags.Get("proto")
+			assert.True(t, ok)
+			assert.Equal(t, "HTTP/1.1", proto)
+		}
+
+		_, err = common.RunString(rt, sr(`http.get("HTTPSBIN_URL/get", { httpVersion: "h3" });`))
+		assert.EqualError(t, err, "GoError: invalid HTTP version: h3, it can be 1.1, 2 or 3")
+	})
 	t.Run("TLS", func(t *testing.T) {
 		t.Run("cert_expired", func(t *testing.T) {
 			_, err := common.RunString(rt, `http.get("https://expired.badssl.com/");`)
@@ -399,6 +502,43 @@ func TestRequestAndBatch(t *testing.T) {
 		_, err := common.RunString(rt, `http.request("GET", "http://sdafsgdhfjg/");`)
 		assert.Error(t, err)
 	})
+	t.Run("ErrorCodes", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		closedURL := "http://" + l.Addr().String() + "/"
+		require.NoError(t, l.Close())
+		rt.Set("closedURL", closedURL)
+
+		t.Run("throw=false", func(t *testing.T) {
+			state.Samples = nil
+			_, err := common.RunString(rt, `
+				let res = http.request("GET", closedURL, null, { throw: false });
+				if (res.error_code !== 1212) { throw new Error("wrong error code: " + res.error_code); }
+				res = http.request("", "", null, { throw: false });
+				if (res.error_code !== 1020) { throw new Error("wrong error code: " + res.error_code); }
+			`)
+			assert.NoError(t, err)
+			require.NotEmpty(t, state.Samples)
+			tag, ok := state.Samples[0].Tags.Get("error_code")
+			assert.True(t, ok)
+			assert.Equal(t, "1212", tag)
+		})
+
+		t.Run("throw=true", func(t *testing.T) {
+			_, err := common.RunString(rt, `
+				try {
+					http.request("GET", closedURL);
+					throw new Error("no error thrown");
+				} catch (e) {
+					let v = e.value;
+					if (v.code !== 1212 || v.category !== "tcp" || v.op !== "dial" || v.syscall !== "connect" || v.errno !== "ECONNREFUSED") {
+						throw new Error("wrong error: " + JSON.stringify(v));
+					}
+				}
+			`)
+			assert.NoError(t, err)
+		})
+	})
 
 	t.Run("Params", func(t *testing.T) {
 		for _, literal := range []string{`undefined`, `null`} {
@@ -415,7 +555,7 @@ func TestRequestAndBatch(t *testing.T) {
 
 		t.Run("cookies", func(t *testing.T) {
 			t.Run("access", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -428,7 +568,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("vuJar", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -447,7 +587,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("requestScope", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -463,7 +603,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("requestScopeReplace", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -480,7 +620,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("redirect", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -503,7 +643,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("domain", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -528,7 +668,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("path", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -553,7 +693,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("expires", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -575,7 +715,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("secure", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -592,7 +732,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("localJar", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				state.Samples = nil
@@ -609,6 +749,33 @@ func TestRequestAndBatch(t *testing.T) {
 				assert.NoError(t, err)
 				assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/cookies"), "", 200, "")
 			})
+
+			t.Run("exportImport", func(t *testing.T) {
+				cookieJar, err := netext.NewCookieJar()
+				assert.NoError(t, err)
+				state.CookieJar = cookieJar
+				state.Samples = nil
+				_, err = common.RunString(rt, sr(`
+				http.cookieJar().set("HTTPBIN_URL/cookies", "key", "value");
+				let cookies = http.cookieJar().export();
+				if (cookies.length != 1) { throw new Error("wrong number of exported cookies: " + cookies.length); }
+				if (cookies[0].name != "key" || cookies[0].value != "value") { throw new Error("wrong exported cookie: " + JSON.stringify(cookies[0])); }
+				if (cookies[0].domain != "HTTPBIN_DOMAIN" || cookies[0].path != "/" || !cookies[0].host_only) { throw new Error("wrong exported cookie: " + JSON.stringify(cookies[0])); }
+
+				let jar = new http.CookieJar();
+				jar.import(JSON.stringify(cookies));
+				let res = http.request("GET", "HTTPBIN_URL/cookies", null, { jar: jar });
+				if (res.json().key != "value") { throw new Error("wrong cookie value: " + res.json().key); }
+
+				let other = new http.CookieJar();
+				other.import(cookies);
+				if (other.cookiesForURL("HTTPBIN_URL/cookies").key[0] != "value") { throw new Error("cookie not imported"); }
+				`))
+				assert.NoError(t, err)
+
+				_, err = common.RunString(rt, `new http.CookieJar().import([{ value: "value" }]);`)
+				assert.EqualError(t, err, "GoError: cookies need a name")
+			})
 		})
 
 		t.Run("auth", func(t *testing.T) {
@@ -635,7 +802,7 @@ func TestRequestAndBatch(t *testing.T) {
 				assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_IP_URL/digest-auth/auth/bob/pass"), url, 200, "")
 			})
 			t.Run("ntlm", func(t *testing.T) {
-				tb.Mux.HandleFunc("/ntlm", http.HandlerFunc(ntlmHandler("bob", "pass")))
+				tb.Mux.HandleFunc("/ntlm", http.HandlerFunc(ntlmHandler("NTLM", "", "bob", "pass")))
 
 				t.Run("success auth", func(t *testing.T) {
 					state.Samples = nil
@@ -658,6 +825,28 @@ func TestRequestAndBatch(t *testing.T) {
 					assertRequestMetricsEmitted(t, state.Samples, "GET", url, url, 401, "")
 				})
 			})
+			t.Run("negotiate", func(t *testing.T) {
+				tb.Mux.HandleFunc("/negotiate", http.HandlerFunc(ntlmHandler("Negotiate", "CORP", "bob", "pass")))
+
+				t.Run("success auth", func(t *testing.T) {
+					state.Samples = nil
+					url := strings.Replace(tb.ServerHTTP.URL+"/negotiate", "http://", "http://CORP%5Cbob:pass@", -1)
+					_, err := common.RunString(rt, fmt.Sprintf(`
+						let res = http.request("GET", "%s", null, { auth: "negotiate" });
+						if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+						`, url))
+					assert.NoError(t, err)
+				})
+				t.Run("failed auth", func(t *testing.T) {
+					state.Samples = nil
+					url := strings.Replace(tb.ServerHTTP.URL+"/negotiate", "http://", "http://CORP%5Cbob:otherpass@", -1)
+					_, err := common.RunString(rt, fmt.Sprintf(`
+						let res = http.request("GET", "%s", null, { auth: "negotiate" });
+						if (res.status != 401) { throw new Error("wrong status: " + res.status); }
+						`, url))
+					assert.NoError(t, err)
+				})
+			})
 		})
 
 		t.Run("headers", func(t *testing.T) {
@@ -754,6 +943,31 @@ func TestRequestAndBatch(t *testing.T) {
 			assert.NoError(t, err)
 			assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/get?a=1&b=2"), sr("HTTPBIN_URL/get?a=${}&b=${}"), 200, "")
 		})
+		t.Run("URLGroups", func(t *testing.T) {
+			group, err := lib.NewURLGroup(sr("HTTPBIN_URL/*"), "", "")
+			require.NoError(t, err)
+			oldOpts := state.Options
+			defer func() { state.Options = oldOpts }()
+			state.Options.URLGroups = lib.URLGroups{group}
+
+			state.Samples = nil
+			_, err = common.RunString(rt, sr(`
+			let res = http.get("HTTPBIN_URL/get?a=1");
+			if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+			`))
+			assert.NoError(t, err)
+			assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/get?a=1"), sr("HTTPBIN_URL/*"), 200, "")
+
+			// Names given by http.url, or by tags, win over the groups.
+			state.Samples = nil
+			_, err = common.RunString(rt, sr(`
+			http.get(http.url(["HTTPBIN_URL/get?a=", ""], "1"));
+			http.get("HTTPBIN_URL/get?a=2", { tags: { name: "tagged" } });
+			`))
+			assert.NoError(t, err)
+			assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/get?a=1"), sr("HTTPBIN_URL/get?a=${}"), 200, "")
+			assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/get?a=2"), "tagged", 200, "")
+		})
 	})
 	t.Run("HEAD", func(t *testing.T) {
 		state.Samples = nil
@@ -833,6 +1047,16 @@ func TestRequestAndBatch(t *testing.T) {
 					assert.NoError(t, err)
 					assertRequestMetricsEmitted(t, state.Samples, method, sr("HTTPBIN_URL/")+strings.ToLower(method), "", 200, "")
 				})
+				t.Run("Array", func(t *testing.T) {
+					state.Samples = nil
+					_, err := common.RunString(rt, fmt.Sprintf(sr(`
+						let res = http.%s("HTTPBIN_URL/%s", {a: ["a", "b"], c: []});
+						if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+						if (res.json().form.a.join() != "a,b") { throw new Error("wrong a=: " + res.json().form.a); }
+						if (res.json().form.c !== undefined) { throw new Error("wrong c=: " + res.json().form.c); }
+						`), fn, strings.ToLower(method)))
+					assert.NoError(t, err)
+				})
 			})
 		})
 	}
@@ -946,6 +1170,52 @@ func TestRequestAndBatch(t *testing.T) {
 			assert.NoError(t, err)
 			assertRequestMetricsEmitted(t, state.Samples, "PUT", sr("HTTPBIN_URL/put"), "", 200, "")
 		})
+		t.Run("Concurrency", func(t *testing.T) {
+			var inFlight, maxInFlight int64
+			tb.Mux.HandleFunc("/batch-concurrency", func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt64(&inFlight, 1)
+				defer atomic.AddInt64(&inFlight, -1)
+				for {
+					max := atomic.LoadInt64(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+			})
+			run := func(t *testing.T, script string) int64 {
+				atomic.StoreInt64(&maxInFlight, 0)
+				_, err := common.RunString(rt, sr(`
+				var reqs = [];
+				for (var i = 0; i < 12; i++) {
+					reqs.push("HTTPBIN_URL/batch-concurrency", "HTTPBIN_IP_URL/batch-concurrency");
+				}
+				var res = `+script+`;
+				for (var key in res) {
+					if (res[key].status != 200) { throw new Error("wrong status: " + key + ": " + res[key].status); }
+				}`))
+				require.NoError(t, err)
+				return atomic.LoadInt64(&maxInFlight)
+			}
+
+			t.Run("Defaults", func(t *testing.T) {
+				assert.Equal(t, int64(lib.DefaultBatch), run(t, "http.batch(reqs)"))
+			})
+			t.Run("Options", func(t *testing.T) {
+				state.Options.Batch = null.IntFrom(20)
+				state.Options.BatchPerHost = null.IntFrom(3)
+				defer func() {
+					state.Options.Batch = null.Int{}
+					state.Options.BatchPerHost = null.Int{}
+				}()
+				assert.Equal(t, int64(6), run(t, "http.batch(reqs)"))
+			})
+			t.Run("Params", func(t *testing.T) {
+				assert.Equal(t, int64(4), run(t, "http.batch(reqs, { batchPerHost: 2 })"))
+				assert.Equal(t, int64(3), run(t, "http.batch(reqs, { batch: 3, batchPerHost: 0 })"))
+				assert.Equal(t, int64(24), run(t, "http.batch(reqs, { batch: 0, batchPerHost: 0 })"))
+			})
+		})
 	})
 
 	t.Run("HTTPRequest", func(t *testing.T) {
@@ -996,6 +1266,8 @@ func TestSystemTags(t *testing.T) {
 		"iter":        tb.ServerHTTP.URL,
 		"tls_version": tb.ServerHTTPS.URL,
 		"ocsp_status": tb.ServerHTTPS.URL,
+
+		"expected_response": tb.ServerHTTP.URL,
 	}
 
 	//TODO: test error
@@ -1017,13 +1289,213 @@ func TestSystemTags(t *testing.T) {
 	}
 }
 
-// Simple NTLM mock handler
-func ntlmHandler(username, password string) func(w http.ResponseWriter, r *http.Request) {
+func TestTracing(t *testing.T) {
+	tb, state, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	defer func() { state.Options.Tracing = lib.TracingConfig{} }()
+
+	// Returns the trace_id tags of the requests' http_reqs samples.
+	traceIDs := func() (ids []string) {
+		for _, sample := range state.Samples {
+			if sample.Metric == metrics.HTTPReqs {
+				id, _ := sample.Tags.Get("trace_id")
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	t.Run("off", func(t *testing.T) {
+		state.Samples = nil
+		v, err := common.RunString(rt, sr(`
+		var headers = http.get("HTTPBIN_URL/headers").json().headers;
+		[headers["Traceparent"], headers["B3"]].join();`))
+		require.NoError(t, err)
+		assert.Equal(t, ",", v.String())
+		assert.Equal(t, []string{""}, traceIDs())
+	})
+	t.Run("w3c", func(t *testing.T) {
+		state.Options.Tracing = lib.TracingConfig{Propagator: null.StringFrom("w3c")}
+		state.Samples = nil
+		v, err := common.RunString(rt, sr(`
+		var reqs = ["HTTPBIN_URL/headers", "HTTPBIN_URL/headers"];
+		var res = http.batch(reqs);
+		[res[0].json().headers["Traceparent"], res[1].json().headers["Traceparent"]].join();`))
+		require.NoError(t, err)
+		headers := strings.Split(v.String(), ",")
+		require.Len(t, headers, 2)
+		assert.NotEqual(t, headers[0], headers[1])
+
+		ids := traceIDs()
+		require.Len(t, ids, 2)
+		for _, header := range headers {
+			assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, header)
+			assert.Contains(t, ids, header[3:35])
+		}
+	})
+	t.Run("b3 unsampled", func(t *testing.T) {
+		state.Options.Tracing = lib.TracingConfig{Propagator: null.StringFrom("b3"), Sampling: null.FloatFrom(0)}
+		state.Samples = nil
+		v, err := common.RunString(rt, sr(`http.get("HTTPBIN_URL/headers").json().headers["B3"];`))
+		require.NoError(t, err)
+		assert.Regexp(t, `^[0-9a-f]{32}-[0-9a-f]{16}-0$`, v.String())
+		assert.Equal(t, []string{v.String()[:32]}, traceIDs())
+	})
+	t.Run("params override", func(t *testing.T) {
+		state.Options.Tracing = lib.TracingConfig{Propagator: null.StringFrom("w3c")}
+		v, err := common.RunString(rt, sr(`
+		var traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01";
+		http.get("HTTPBIN_URL/headers", { headers: { traceparent: traceparent } }).json().headers["Traceparent"];`))
+		require.NoError(t, err)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", v.String())
+	})
+}
+
+func TestProxy(t *testing.T) {
+	tb, _, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	proxy := testutils.NewHTTPProxy(tb.Dialer, "bob", "pass")
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	proxyURL.User = url.UserPassword("bob", "pass")
+	rt.Set("PROXY_URL", proxyURL.String())
+
+	// Requests go through no proxy by default, like with the proxy option set to "".
+	tb.HTTPTransport.Proxy = tb.Dialer.ProxyFunc(types.NullProxyFrom(nil).ProxyFunc())
+
+	proxied := func(f func()) int64 {
+		before := atomic.LoadInt64(&proxy.Proxied)
+		f()
+		return atomic.LoadInt64(&proxy.Proxied) - before
+	}
+
+	t.Run("params", func(t *testing.T) {
+		assert.Equal(t, int64(2), proxied(func() {
+			_, err := common.RunString(rt, sr(`
+			var res = http.get("HTTPBIN_URL/get", { proxy: PROXY_URL });
+			if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+			res = http.get("HTTPSBIN_URL/get", { proxy: PROXY_URL });
+			if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+			`))
+			require.NoError(t, err)
+		}))
+	})
+	t.Run("auth", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		var res = http.get("HTTPBIN_URL/get", { proxy: PROXY_URL.replace("pass", "nope") });
+		if (res.status != 407) { throw new Error("wrong status: " + res.status); }
+		`))
+		require.NoError(t, err)
+	})
+	t.Run("default", func(t *testing.T) {
+		tb.HTTPTransport.Proxy = tb.Dialer.ProxyFunc(types.NullProxyFrom(proxyURL).ProxyFunc())
+		defer func() { tb.HTTPTransport.Proxy = nil }()
+		assert.Equal(t, int64(1), proxied(func() {
+			_, err := common.RunString(rt, sr(`
+			http.get("HTTPBIN_URL/get");
+			var res = http.get("HTTPBIN_URL/get", { proxy: "" });
+			if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+			`))
+			require.NoError(t, err)
+		}))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`http.get("HTTPBIN_URL/get", { proxy: "ftp://proxy.example.com" });`))
+		assert.EqualError(t, err, "GoError: invalid proxy scheme: ftp, it can be http, https, socks5 or socks5h")
+	})
+}
+
+func TestResponseCallback(t *testing.T) {
+	tb, state, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	cb := common.ResponseCallback(common.DefaultResponseCallback)
+	state.ResponseCallback = &cb
+
+	// Returns the expected_response tag and the http_req_failed value of each request.
+	results := func() (tags []string, failed []float64) {
+		for _, sample := range state.Samples {
+			if sample.Metric != metrics.HTTPReqFailed {
+				continue
+			}
+			tag, _ := sample.Tags.Get("expected_response")
+			tags = append(tags, tag)
+			failed = append(failed, sample.Value)
+		}
+		return tags, failed
+	}
+
+	t.Run("default", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		http.get("HTTPBIN_URL/status/200");
+		http.get("HTTPBIN_URL/status/404");
+		`))
+		require.NoError(t, err)
+		tags, failed := results()
+		assert.Equal(t, []string{"true", "false"}, tags)
+		assert.Equal(t, []float64{0, 1}, failed)
+	})
+	t.Run("expectedStatuses", func(t *testing.T) {
+		defer func() { cb = common.DefaultResponseCallback }()
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		http.setResponseCallback(http.expectedStatuses(404, {min: 500, max: 502}));
+		http.get("HTTPBIN_URL/status/200");
+		http.get("HTTPBIN_URL/status/404");
+		http.get("HTTPBIN_URL/status/501");
+		http.get("HTTPBIN_URL/status/200", { responseCallback: http.expectedStatuses(200) });
+		`))
+		require.NoError(t, err)
+		tags, failed := results()
+		assert.Equal(t, []string{"false", "true", "true", "true"}, tags)
+		assert.Equal(t, []float64{1, 0, 0, 0}, failed)
+	})
+	t.Run("off", func(t *testing.T) {
+		defer func() { cb = common.DefaultResponseCallback }()
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		http.setResponseCallback(null);
+		http.get("HTTPBIN_URL/status/404");
+		http.get("HTTPBIN_URL/status/404", { responseCallback: http.expectedStatuses(200) });
+		`))
+		require.NoError(t, err)
+		tags, failed := results()
+		assert.Equal(t, []string{"false"}, tags)
+		assert.Equal(t, []float64{1}, failed)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			"no statuses": `http.expectedStatuses()`,
+			"float":       `http.expectedStatuses(200.5)`,
+			"string":      `http.expectedStatuses("200")`,
+			"bad range":   `http.expectedStatuses({min: 400, max: 200})`,
+			"half range":  `http.expectedStatuses({min: 400})`,
+			"callback":    `http.setResponseCallback(function(res) { return true; })`,
+			"param":       `http.get("HTTPBIN_URL/get", { responseCallback: 200 })`,
+		}
+		for name, src := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := common.RunString(rt, sr(src))
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+// Simple NTLM mock handler, also used for NTLM over Negotiate
+func ntlmHandler(scheme, domain, username, password string) func(w http.ResponseWriter, r *http.Request) {
 	challenges := make(map[string]*ntlm.ChallengeMessage)
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Make sure there is some kind of authentication
 		if r.Header.Get("Authorization") == "" {
-			w.Header().Set("WWW-Authenticate", "NTLM")
+			w.Header().Set("WWW-Authenticate", scheme)
 			w.WriteHeader(401)
 			return
 		}
@@ -1035,8 +1507,8 @@ func ntlmHandler(username, password string) func(w http.ResponseWriter, r *http.
 		authPayload := parts[1]
 
 		// Filter out unsupported authentication methods
-		if authType != "NTLM" {
-			w.Header().Set("WWW-Authenticate", "NTLM")
+		if authType != scheme {
+			w.Header().Set("WWW-Authenticate", scheme)
 			w.WriteHeader(401)
 			return
 		}
@@ -1052,7 +1524,7 @@ func ntlmHandler(username, password string) func(w http.ResponseWriter, r *http.
 				return
 			}
 
-			session.SetUserInfo(username, password, "")
+			session.SetUserInfo(username, password, domain)
 
 			challenge, err := session.GenerateChallengeMessage()
 			if err != nil {
@@ -1063,7 +1535,7 @@ func ntlmHandler(username, password string) func(w http.ResponseWriter, r *http.
 
 			authPayload := base64.StdEncoding.EncodeToString(challenge.Bytes())
 
-			w.Header().Set("WWW-Authenticate", "NTLM "+authPayload)
+			w.Header().Set("WWW-Authenticate", scheme+" "+authPayload)
 			w.WriteHeader(401)
 
 			return
@@ -1072,7 +1544,7 @@ func ntlmHandler(username, password string) func(w http.ResponseWriter, r *http.
 		if ntlmMessageType == 3 {
 			challenge := challenges[r.RemoteAddr]
 			if challenge == nil {
-				w.Header().Set("WWW-Authenticate", "NTLM")
+				w.Header().Set("WWW-Authenticate", scheme)
 				w.WriteHeader(401)
 				return
 			}
@@ -1091,11 +1563,11 @@ func ntlmHandler(username, password string) func(w http.ResponseWriter, r *http.
 				}
 
 				session.SetServerChallenge(challenge.ServerChallenge)
-				session.SetUserInfo(username, password, "")
+				session.SetUserInfo(username, password, domain)
 
 				err = session.ProcessAuthenticateMessage(msg2)
 				if err != nil {
-					w.Header().Set("WWW-Authenticate", "NTLM")
+					w.Header().Set("WWW-Authenticate", scheme)
 					w.WriteHeader(401)
 					return
 				}
@@ -1106,11 +1578,11 @@ func ntlmHandler(username, password string) func(w http.ResponseWriter, r *http.
 				}
 
 				session.SetServerChallenge(challenge.ServerChallenge)
-				session.SetUserInfo(username, password, "")
+				session.SetUserInfo(username, password, domain)
 
 				err = session.ProcessAuthenticateMessage(msg)
 				if err != nil {
-					w.Header().Set("WWW-Authenticate", "NTLM")
+					w.Header().Set("WWW-Authenticate", scheme)
 					w.WriteHeader(401)
 					return
 				}
@@ -0,0 +1,239 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	neturl "net/url"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// DefaultOAuth2RefreshMargin is how long before they expire tokens are refreshed by default, so
+// requests don't race with the expiry.
+const DefaultOAuth2RefreshMargin = 10 * time.Second
+
+// An OAuth2Client fetches access tokens from an authorization server with the client credentials
+// or resource owner password grants, caches them, and refreshes them shortly before they expire.
+// Clients are usually created in the init context, so every VU has tokens of its own; passing one
+// to a request in the oauth2 param adds an Authorization header with its token.
+type OAuth2Client struct {
+	ctx *context.Context
+	h   *HTTP
+
+	TokenURL     string `js:"tokenURL"`
+	GrantType    string `js:"grantType"`
+	ClientID     string `js:"clientID"`
+	ClientSecret string `js:"clientSecret"`
+	Username     string `js:"username"`
+	Password     string `js:"password"`
+	Scope        string `js:"scope"`
+	// Send the client's credentials in the request body rather than with basic authentication.
+	CredentialsInBody bool          `js:"credentialsInBody"`
+	RefreshMargin     time.Duration `js:"-"`
+
+	accessToken  string
+	tokenType    string
+	refreshToken string
+	expires      time.Time
+}
+
+type oauth2TokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+}
+
+// XOAuth2Client creates a client. Options are tokenURL, grantType ("client_credentials", the
+// default, or "password"), clientID, clientSecret, username, password, scope, credentialsInBody
+// and refreshMargin, in milliseconds.
+func (h *HTTP) XOAuth2Client(ctxPtr *context.Context, options goja.Value) (*OAuth2Client, error) {
+	rt := common.GetRuntime(*ctxPtr)
+	c := &OAuth2Client{
+		ctx:           ctxPtr,
+		h:             h,
+		GrantType:     "client_credentials",
+		RefreshMargin: DefaultOAuth2RefreshMargin,
+	}
+	if options == nil || goja.IsUndefined(options) || goja.IsNull(options) {
+		return nil, errors.New("oauth2 client options are required")
+	}
+
+	obj := options.ToObject(rt)
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		switch k {
+		case "tokenURL":
+			c.TokenURL = v.String()
+		case "grantType":
+			c.GrantType = v.String()
+		case "clientID":
+			c.ClientID = v.String()
+		case "clientSecret":
+			c.ClientSecret = v.String()
+		case "username":
+			c.Username = v.String()
+		case "password":
+			c.Password = v.String()
+		case "scope":
+			c.Scope = v.String()
+		case "credentialsInBody":
+			c.CredentialsInBody = v.ToBoolean()
+		case "refreshMargin":
+			c.RefreshMargin = time.Duration(v.ToFloat() * float64(time.Millisecond))
+		default:
+			return nil, errors.Errorf("unknown oauth2 client option '%s'", k)
+		}
+	}
+
+	if c.TokenURL == "" {
+		return nil, errors.New("oauth2 client needs a tokenURL")
+	}
+	switch c.GrantType {
+	case "client_credentials":
+	case "password":
+		if c.Username == "" {
+			return nil, errors.New("the password grant needs a username")
+		}
+	default:
+		return nil, errors.Errorf("unsupported oauth2 grant type '%s'", c.GrantType)
+	}
+	return c, nil
+}
+
+// Token returns the current access token, fetching a new one first if there's none or it's about
+// to expire.
+func (c *OAuth2Client) Token() (string, error) {
+	token, _, err := c.token(*c.ctx)
+	return token, err
+}
+
+// Invalidate drops the current token, so the next request fetches a new one; eg. after the
+// server revoked it.
+func (c *OAuth2Client) Invalidate() {
+	c.accessToken = ""
+	c.expires = time.Time{}
+}
+
+// authorization returns the value of the Authorization header for a request.
+func (c *OAuth2Client) authorization(ctx context.Context) (string, error) {
+	token, tokenType, err := c.token(ctx)
+	if err != nil {
+		return "", err
+	}
+	return tokenType + " " + token, nil
+}
+
+func (c *OAuth2Client) token(ctx context.Context) (string, string, error) {
+	if c.accessToken != "" && (c.expires.IsZero() || time.Now().Add(c.RefreshMargin).Before(c.expires)) {
+		return c.accessToken, c.tokenType, nil
+	}
+
+	// Prefer refreshing a token over authenticating from scratch, but fall back to it if the
+	// refresh token was rejected too.
+	if c.refreshToken != "" {
+		form := neturl.Values{"grant_type": {"refresh_token"}, "refresh_token": {c.refreshToken}}
+		err := c.fetch(ctx, form)
+		if err == nil {
+			return c.accessToken, c.tokenType, nil
+		}
+		c.refreshToken = ""
+	}
+
+	form := neturl.Values{"grant_type": {c.GrantType}}
+	if c.GrantType == "password" {
+		form.Set("username", c.Username)
+		form.Set("password", c.Password)
+	}
+	if err := c.fetch(ctx, form); err != nil {
+		return "", "", err
+	}
+	return c.accessToken, c.tokenType, nil
+}
+
+// fetch sends a token request, and stores the token in the response.
+func (c *OAuth2Client) fetch(ctx context.Context, form neturl.Values) error {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return errors.New("oauth2 tokens can only be fetched in VU code")
+	}
+
+	if c.Scope != "" {
+		form.Set("scope", c.Scope)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+		"Accept":       "application/json",
+	}
+	if c.CredentialsInBody {
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+	} else if c.ClientID != "" {
+		creds := neturl.QueryEscape(c.ClientID) + ":" + neturl.QueryEscape(c.ClientSecret)
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	}
+
+	u, err := ToURL(rt.ToValue(c.TokenURL))
+	if err != nil {
+		return err
+	}
+	params := rt.ToValue(map[string]interface{}{"headers": headers})
+	start := time.Now()
+	res, samples, err := c.h.request(ctx, rt, state, HTTP_METHOD_POST, u, rt.ToValue(form.Encode()), params)
+	state.Samples = append(state.Samples, samples...)
+	if err != nil {
+		return errors.Wrap(err, "oauth2 token request")
+	}
+	if res.Error != "" {
+		return errors.Errorf("oauth2 token request failed: %s", res.Error)
+	}
+	if res.Status < 200 || res.Status >= 300 {
+		return errors.Errorf("oauth2 token request failed with status %d: %s", res.Status, res.bodyString())
+	}
+
+	var tr oauth2TokenResponse
+	if err := json.Unmarshal([]byte(res.bodyString()), &tr); err != nil {
+		return errors.Wrap(err, "oauth2 token response")
+	}
+	if tr.AccessToken == "" {
+		return errors.New("oauth2 token response has no access_token")
+	}
+	c.accessToken = tr.AccessToken
+	c.tokenType = tr.TokenType
+	if c.tokenType == "" || c.tokenType == "bearer" {
+		c.tokenType = "Bearer"
+	}
+	if tr.RefreshToken != "" {
+		c.refreshToken = tr.RefreshToken
+	}
+	c.expires = time.Time{}
+	if expiresIn, err := tr.ExpiresIn.Float64(); err == nil && expiresIn > 0 {
+		c.expires = start.Add(time.Duration(expiresIn * float64(time.Second)))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuth2Client(t *testing.T) {
+	tb, _, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	var mu sync.Mutex
+	var grants []string
+	issued := 0
+	tb.Mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		}
+		if clientID != "k6" || clientSecret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error": "invalid_client"}`)
+			return
+		}
+
+		grant := r.PostFormValue("grant_type")
+		switch grant {
+		case "password":
+			if r.PostFormValue("username") != "bob" || r.PostFormValue("password") != "pass" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = fmt.Fprint(w, `{"error": "invalid_grant"}`)
+				return
+			}
+		case "refresh_token":
+			if r.PostFormValue("refresh_token") != "refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		grants = append(grants, grant+" "+r.PostFormValue("scope"))
+		issued++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": %s, "refresh_token": "refresh-%d"}`,
+			issued, r.URL.Query().Get("expires_in"), issued)
+	})
+	tb.Mux.HandleFunc("/oauth2/protected", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Header.Get("Authorization"))
+	})
+
+	reset := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		grants = nil
+		issued = 0
+	}
+
+	t.Run("ClientCredentials", func(t *testing.T) {
+		reset()
+		_, err := common.RunString(rt, sr(`
+		var client = new http.OAuth2Client({
+			tokenURL: "HTTPBIN_URL/oauth2/token?expires_in=3600",
+			clientID: "k6",
+			clientSecret: "s3cr3t",
+			scope: "read write",
+		});
+		for (var i = 0; i < 3; i++) {
+			var res = http.get("HTTPBIN_URL/oauth2/protected", { oauth2: client });
+			if (res.body !== "Bearer token-1") { throw new Error("wrong authorization: " + res.body); }
+		}
+		if (client.token() !== "token-1") { throw new Error("wrong token"); }
+		client.invalidate();
+		if (client.token() !== "token-2") { throw new Error("the token wasn't fetched again"); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"client_credentials read write", "refresh_token read write"}, grants)
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		reset()
+		_, err := common.RunString(rt, sr(`
+		var client = new http.OAuth2Client({
+			tokenURL: "HTTPBIN_URL/oauth2/token?expires_in=5",
+			grantType: "password",
+			clientID: "k6",
+			clientSecret: "s3cr3t",
+			username: "bob",
+			password: "pass",
+			credentialsInBody: true,
+		});
+		// Tokens that expire within the refresh margin are refreshed right away.
+		if (client.token() !== "token-1") { throw new Error("wrong first token"); }
+		if (client.token() !== "token-2") { throw new Error("wrong refreshed token"); }
+		// The second refresh token isn't accepted, so it falls back to the password grant.
+		if (client.token() !== "token-3") { throw new Error("wrong token after a failed refresh"); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"password ", "refresh_token ", "password "}, grants)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		var client = new http.OAuth2Client({ tokenURL: "HTTPBIN_URL/oauth2/token", clientID: "k6", clientSecret: "wrong" });
+		http.get("HTTPBIN_URL/oauth2/protected", { oauth2: client });
+		`))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), `oauth2 token request failed with status 401: {"error": "invalid_client"}`)
+		}
+
+		_, err = common.RunString(rt, `new http.OAuth2Client({ tokenURL: "https://example.com", grantType: "implicit" })`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "unsupported oauth2 grant type 'implicit'")
+		}
+
+		_, err = common.RunString(rt, `http.get("https://example.com", { oauth2: {} })`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "the oauth2 param must be an http.OAuth2Client")
+		}
+	})
+
+}
@@ -32,7 +32,10 @@ import (
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/js/modules/k6/html"
+	"github.com/loadimpact/k6/js/modules/k6/xml"
 	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
 	"golang.org/x/crypto/ocsp"
 )
 
@@ -42,6 +45,25 @@ type OCSP struct {
 	Status                                        string
 }
 
+// ResponseType is how a response body is read: as text, as binary data, or not at all.
+type ResponseType string
+
+const (
+	ResponseTypeText   ResponseType = "text"
+	ResponseTypeBinary ResponseType = "binary"
+	ResponseTypeNone   ResponseType = "none"
+)
+
+// ParseResponseType validates a responseType request param.
+func ParseResponseType(s string) (ResponseType, error) {
+	switch rt := ResponseType(s); rt {
+	case ResponseTypeText, ResponseTypeBinary, ResponseTypeNone:
+		return rt, nil
+	default:
+		return "", errors.Errorf("invalid responseType '%s', expected \"text\", \"binary\" or \"none\"", s)
+	}
+}
+
 type HTTPResponseTimings struct {
 	Duration, Blocked, LookingUp, Connecting, TLSHandshaking, Sending, Waiting, Receiving float64
 }
@@ -56,15 +78,20 @@ type HTTPResponse struct {
 	Proto          string
 	Headers        map[string]string
 	Cookies        map[string][]*HTTPCookie
-	Body           string
+	Body           interface{}
 	Timings        HTTPResponseTimings
 	TLSVersion     string
 	TLSCipherSuite string
 	OCSP           OCSP `js:"ocsp"`
 	Error          string
+	ErrorCode      int
 	Request        HTTPRequest
 
-	cachedJSON goja.Value
+	// How many times the request was retried before this response.
+	Retries int
+
+	cachedJSON    goja.Value
+	validatedJSON bool
 }
 
 func (res *HTTPResponse) setTLSInfo(tlsState *tls.ConnectionState) {
@@ -124,19 +151,51 @@ func (res *HTTPResponse) setTLSInfo(tlsState *tls.ConnectionState) {
 	res.OCSP = ocspStapledRes
 }
 
-func (res *HTTPResponse) Json() goja.Value {
+// bodyString returns the body as a string, whichever responseType it was read with; discarded
+// bodies are empty.
+func (res *HTTPResponse) bodyString() string {
+	switch body := res.Body.(type) {
+	case string:
+		return body
+	case []byte:
+		return string(body)
+	default:
+		return ""
+	}
+}
+
+// Json parses the body as JSON. With a selector, only the value it selects is converted, which
+// is much cheaper than the whole body; values that aren't there are undefined. The selector
+// syntax is GJSON's, eg. "data.items.#.id" or "data.items.#[name==\"k6\"].id".
+func (res *HTTPResponse) Json(selector ...string) goja.Value {
+	rt := common.GetRuntime(res.ctx)
+	if len(selector) > 0 {
+		body := res.bodyString()
+		if !res.validatedJSON {
+			if !gjson.Valid(body) {
+				common.Throw(rt, errors.New("the body isn't valid JSON"))
+			}
+			res.validatedJSON = true
+		}
+		result := gjson.Get(body, selector[0])
+		if !result.Exists() {
+			return goja.Undefined()
+		}
+		return rt.ToValue(result.Value())
+	}
+
 	if res.cachedJSON == nil {
 		var v interface{}
-		if err := json.Unmarshal([]byte(res.Body), &v); err != nil {
-			common.Throw(common.GetRuntime(res.ctx), err)
+		if err := json.Unmarshal([]byte(res.bodyString()), &v); err != nil {
+			common.Throw(rt, err)
 		}
-		res.cachedJSON = common.GetRuntime(res.ctx).ToValue(v)
+		res.cachedJSON = rt.ToValue(v)
 	}
 	return res.cachedJSON
 }
 
 func (res *HTTPResponse) Html(selector ...string) html.Selection {
-	sel, err := html.HTML{}.ParseHTML(res.ctx, res.Body)
+	sel, err := html.HTML{}.ParseHTML(res.ctx, res.bodyString())
 	if err != nil {
 		common.Throw(common.GetRuntime(res.ctx), err)
 	}
@@ -147,12 +206,28 @@ func (res *HTTPResponse) Html(selector ...string) html.Selection {
 	return sel
 }
 
+// Xml parses the body as an XML document, and optionally selects nodes from it with an XPath
+// expression, like Html() does with CSS selectors.
+func (res *HTTPResponse) Xml(selector ...string) xml.Selection {
+	sel, err := xml.XML{}.ParseXML(res.ctx, res.bodyString())
+	if err != nil {
+		common.Throw(common.GetRuntime(res.ctx), err)
+	}
+	if len(selector) > 0 {
+		sel = sel.Find(selector[0])
+	}
+	return sel
+}
+
+// SubmitForm submits a form in the response like a browser would, with the values of its fields,
+// the name and value of the submit button, and the given fields, which may be files for file
+// inputs, or arrays for fields with several values.
 func (res *HTTPResponse) SubmitForm(args ...goja.Value) (*HTTPResponse, error) {
 	rt := common.GetRuntime(res.ctx)
 
 	formSelector := "form"
-	submitSelector := "[type=\"submit\"]"
-	var fields map[string]goja.Value
+	submitSelector := "[type=\"submit\"], button:not([type])"
+	var fields *goja.Object
 	requestParams := goja.Null()
 	if len(args) > 0 {
 		params := args[0].ToObject(rt)
@@ -163,8 +238,8 @@ func (res *HTTPResponse) SubmitForm(args ...goja.Value) (*HTTPResponse, error) {
 			case "submitSelector":
 				submitSelector = params.Get(k).String()
 			case "fields":
-				if rt.ExportTo(params.Get(k), &fields) != nil {
-					fields = nil
+				if v := params.Get(k); !goja.IsUndefined(v) && !goja.IsNull(v) {
+					fields = v.ToObject(rt)
 				}
 			case "params":
 				requestParams = params.Get(k)
@@ -176,8 +251,17 @@ func (res *HTTPResponse) SubmitForm(args ...goja.Value) (*HTTPResponse, error) {
 	if form.Size() == 0 {
 		common.Throw(rt, fmt.Errorf("no form found for selector '%s' in response '%s'", formSelector, res.URL))
 	}
+	submit := form.Find(submitSelector).First()
+
+	// The submit button can override the form's method, action and encoding.
+	formAttr := func(name string) goja.Value {
+		if v := submit.Attr("form" + name); v != goja.Undefined() {
+			return v
+		}
+		return form.Attr(name)
+	}
 
-	methodAttr := form.Attr("method")
+	methodAttr := formAttr("method")
 	var requestMethod string
 	if methodAttr == goja.Undefined() {
 		// Use GET by default
@@ -191,7 +275,7 @@ func (res *HTTPResponse) SubmitForm(args ...goja.Value) (*HTTPResponse, error) {
 		common.Throw(rt, err)
 	}
 
-	actionAttr := form.Attr("action")
+	actionAttr := formAttr("action")
 	var requestUrl *url.URL
 	if actionAttr == goja.Undefined() {
 		// Use the url of the response if no action is set
@@ -204,31 +288,56 @@ func (res *HTTPResponse) SubmitForm(args ...goja.Value) (*HTTPResponse, error) {
 		requestUrl = responseUrl.ResolveReference(actionUrl)
 	}
 
-	// Set the body based on the form values
-	values := form.SerializeObject()
+	body := &formBody{}
+	if enctype := formAttr("enctype"); enctype != goja.Undefined() {
+		body.multipart = strings.EqualFold(enctype.String(), "multipart/form-data")
+	}
 
-	// Set the name + value of the submit button
-	submit := form.Find(submitSelector)
-	submitName := submit.Attr("name")
-	submitValue := submit.Val()
-	if submitName != goja.Undefined() && submitValue != goja.Undefined() {
-		values[submitName.String()] = submitValue
+	// Set the body based on the form values, in document order
+	for _, v := range form.SerializeArray() {
+		body.add(v.Name, v.Value)
+	}
+
+	// File inputs are sent as empty files, or in other encodings as empty values, if they
+	// aren't given files
+	for _, input := range form.Find("input[type=\"file\"][name]:not([disabled])").ToArray() {
+		name := input.Attr("name").String()
+		if body.multipart {
+			body.add(name, rt.ToValue(FileData{ContentType: "application/octet-stream"}))
+		} else {
+			body.add(name, rt.ToValue(""))
+		}
+	}
+
+	// Set the name + value of the submit button; image buttons send the coordinates of the click
+	if submitName := submit.Attr("name"); submitName != goja.Undefined() {
+		if submit.Attr("type").String() == "image" {
+			body.add(submitName.String()+".x", rt.ToValue("0"))
+			body.add(submitName.String()+".y", rt.ToValue("0"))
+		} else if submitValue := submit.Val(); submitValue != goja.Undefined() {
+			body.add(submitName.String(), submitValue)
+		}
+	} else if submit.Attr("type").String() == "image" {
+		body.add("x", rt.ToValue("0"))
+		body.add("y", rt.ToValue("0"))
 	}
 
 	// Set the values supplied in the arguments, overriding automatically set values
-	for k, v := range fields {
-		values[k] = v
+	if fields != nil {
+		for _, k := range fields.Keys() {
+			body.set(k, fields.Get(k))
+		}
 	}
 
 	if requestMethod == HTTP_METHOD_GET {
-		q := url.Values{}
-		for k, v := range values {
-			q.Add(k, v.String())
+		q, err := body.query()
+		if err != nil {
+			return nil, err
 		}
 		requestUrl.RawQuery = q.Encode()
 		return New().Request(res.ctx, requestMethod, rt.ToValue(requestUrl.String()), goja.Null(), requestParams)
 	}
-	return New().Request(res.ctx, requestMethod, rt.ToValue(requestUrl.String()), rt.ToValue(values), requestParams)
+	return New().Request(res.ctx, requestMethod, rt.ToValue(requestUrl.String()), rt.ToValue(body), requestParams)
 }
 
 func (res *HTTPResponse) ClickLink(args ...goja.Value) (*HTTPResponse, error) {
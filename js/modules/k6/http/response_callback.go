@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// ExpectedStatuses is a response callback made by http.expectedStatuses(); a response is expected
+// if its status is one of the listed ones, or falls in one of the listed ranges.
+type ExpectedStatuses struct {
+	statuses []int
+	ranges   []statusRange
+}
+
+type statusRange struct {
+	min, max int
+}
+
+func (e *ExpectedStatuses) match(status int) bool {
+	for _, s := range e.statuses {
+		if s == status {
+			return true
+		}
+	}
+	for _, r := range e.ranges {
+		if status >= r.min && status <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpectedStatuses makes a response callback out of statuses and {min, max} ranges of them, eg.
+// http.expectedStatuses(200, 304, {min: 400, max: 404}).
+func (*HTTP) ExpectedStatuses(args ...goja.Value) (*ExpectedStatuses, error) {
+	if len(args) == 0 {
+		return nil, errors.New("expectedStatuses needs at least one status or range")
+	}
+
+	e := &ExpectedStatuses{}
+	for _, arg := range args {
+		switch v := arg.Export().(type) {
+		case int64:
+			e.statuses = append(e.statuses, int(v))
+		case map[string]interface{}:
+			min, okMin := v["min"].(int64)
+			max, okMax := v["max"].(int64)
+			if !okMin || !okMax {
+				return nil, errors.Errorf("a status range needs integer min and max, got %s", arg)
+			}
+			if min > max {
+				return nil, errors.Errorf("invalid status range: min %d is greater than max %d", min, max)
+			}
+			e.ranges = append(e.ranges, statusRange{int(min), int(max)})
+		default:
+			return nil, errors.Errorf("expectedStatuses takes integers and {min, max} ranges, got %s", arg)
+		}
+	}
+	return e, nil
+}
+
+// SetResponseCallback sets the callback that decides which responses the VU's requests expect;
+// null turns tagging them with expected_response, and http_req_failed, off.
+func (*HTTP) SetResponseCallback(ctx context.Context, v goja.Value) {
+	rt := common.GetRuntime(ctx)
+
+	var slot *common.ResponseCallback
+	if state := common.GetState(ctx); state != nil {
+		slot = state.ResponseCallback
+	} else if env := common.GetInitEnv(ctx); env != nil {
+		slot = env.ResponseCallback
+	}
+	if slot == nil {
+		common.Throw(rt, errors.New("setResponseCallback can't be used here"))
+	}
+
+	cb, err := toResponseCallback(v)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	*slot = cb
+}
+
+// toResponseCallback reads a response callback out of a value passed by a script.
+func toResponseCallback(v goja.Value) (common.ResponseCallback, error) {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil, nil
+	}
+	if e, ok := v.Export().(*ExpectedStatuses); ok {
+		return e.match, nil
+	}
+	return nil, errors.Errorf("a response callback must be made by http.expectedStatuses(), got %s", v)
+}
@@ -23,12 +23,14 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"testing"
 
 	"github.com/loadimpact/k6/js/common"
 	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
 )
 
 const testGetFormHTML = `
@@ -76,6 +78,50 @@ func myFormHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(body)
 }
 
+const testPostFormHTML = `
+<html>
+<body>
+	<form method="post" action="/myforms/echo" enctype="multipart/form-data">
+		<input name="title" type="text" value="My file"/>
+		<input name="tags" type="checkbox" value="a" checked/>
+		<input name="tags" type="checkbox" value="b"/>
+		<input name="tags" type="checkbox" value="c" checked/>
+		<input name="upload" type="file"/>
+		<input name="disabled" type="file" disabled/>
+		<button name="action" value="save">Save</button>
+		<button name="action" value="publish" formaction="/myforms/echo?published=1">Publish</button>
+		<input name="preview" type="image" src="preview.png"/>
+	</form>
+</body>
+`
+
+// myFormEchoHandler responds with the fields and files of a multipart form.
+func myFormEchoHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	files := make(map[string][]string)
+	for name, fhs := range r.MultipartForm.File {
+		for _, fh := range fhs {
+			f, err := fh.Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data, _ := ioutil.ReadAll(f)
+			_ = f.Close()
+			files[name] = append(files[name], fh.Filename+":"+fh.Header.Get("Content-Type")+":"+string(data))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"query": r.URL.Query(),
+		"form":  r.MultipartForm.Value,
+		"files": files,
+	})
+}
+
 func TestResponse(t *testing.T) {
 	tb, state, rt, _ := newRuntime(t)
 	defer tb.Cleanup()
@@ -83,6 +129,11 @@ func TestResponse(t *testing.T) {
 	sr := tb.Replacer.Replace
 
 	tb.Mux.HandleFunc("/myforms/get", myFormHandler)
+	tb.Mux.HandleFunc("/myforms/post", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(testPostFormHTML))
+	})
+	tb.Mux.HandleFunc("/myforms/echo", myFormEchoHandler)
 
 	t.Run("Html", func(t *testing.T) {
 		state.Samples = nil
@@ -133,6 +184,22 @@ func TestResponse(t *testing.T) {
 			assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/html"), "", 200, "::my group")
 		})
 	})
+	t.Run("Xml", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+			let res = http.request("GET", "HTTPBIN_URL/xml");
+			if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+			if (res.xml().find("/slideshow").attr("title") != "Sample Slide Show") { throw new Error("wrong title: " + res.body); }
+			if (res.xml("//slide[@type='all']/title").first().text() != "Wake up to WonderWidgets!") { throw new Error("wrong slide: " + res.body); }
+		`))
+		assert.NoError(t, err)
+		assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/xml"), "", 200, "")
+
+		t.Run("invalid", func(t *testing.T) {
+			_, err := common.RunString(rt, sr(`http.request("GET", "HTTPBIN_URL/get").xml();`))
+			assert.EqualError(t, err, "GoError: text outside of the root element")
+		})
+	})
 	t.Run("Json", func(t *testing.T) {
 		state.Samples = nil
 		_, err := common.RunString(rt, sr(`
@@ -148,6 +215,29 @@ func TestResponse(t *testing.T) {
 			_, err := common.RunString(rt, sr(`http.request("GET", "HTTPBIN_URL/html").json();`))
 			assert.EqualError(t, err, "GoError: invalid character '<' looking for beginning of value")
 		})
+		t.Run("Selector", func(t *testing.T) {
+			tb.Mux.HandleFunc("/slideshow", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"slideshow": {"author": "Yours Truly", "slides": [` +
+					`{"title": "Wake up to WonderWidgets!", "type": "all"},` +
+					`{"title": "Overview", "type": "all", "items": ["Why WonderWidgets are great"]}]}}`))
+			})
+			_, err := common.RunString(rt, sr(`
+			var res = http.request("GET", "HTTPBIN_URL/slideshow");
+			if (res.json("slideshow.author") !== "Yours Truly") { throw new Error("wrong author: " + res.json("slideshow.author")); }
+			if (res.json("slideshow.slides.#") !== 2) { throw new Error("wrong slide count: " + res.json("slideshow.slides.#")); }
+			var titles = res.json("slideshow.slides.#.title");
+			if (titles.length !== 2 || titles[1] !== "Overview") { throw new Error("wrong titles: " + JSON.stringify(titles)); }
+			if (res.json('slideshow.slides.#[title=="Overview"].type') !== "all") { throw new Error("wrong query result"); }
+			if (res.json("slideshow.slides.0").type !== "all") { throw new Error("wrong object: " + JSON.stringify(res.json("slideshow.slides.0"))); }
+			if (res.json("slideshow.missing") !== undefined) { throw new Error("missing value isn't undefined"); }
+			`))
+			assert.NoError(t, err)
+		})
+		t.Run("Selector Invalid", func(t *testing.T) {
+			_, err := common.RunString(rt, sr(`http.request("GET", "HTTPBIN_URL/html").json("a");`))
+			assert.EqualError(t, err, "GoError: the body isn't valid JSON")
+		})
 	})
 
 	t.Run("SubmitForm", func(t *testing.T) {
@@ -246,13 +336,67 @@ func TestResponse(t *testing.T) {
 				if (data.input_with_value[0] !== "value" ||
 					data.input_without_value[0] !== "" ||
 					data.select_one[0] !== "yes this option" ||
-					data.select_multi[0] !== "option 2,option 3" ||
+					data.select_multi.join() !== "option 2,option 3" ||
 					data.textarea[0] !== "Lorem ipsum dolor sit amet"
 				) { throw new Error("incorrect body: " + JSON.stringify(data, null, 4) ); }
 			`))
 			assert.NoError(t, err)
 			assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/myforms/get"), "", 200, "")
 		})
+
+		t.Run("withMultipart", func(t *testing.T) {
+			_, err := common.RunString(rt, sr(`
+				let form = http.request("GET", "HTTPBIN_URL/myforms/post");
+				let data = form.submitForm().json();
+				if (data.form.title[0] !== "My file" ||
+					data.form.tags.join() !== "a,c" ||
+					data.form.action.join() !== "save" ||
+					data.form.disabled !== undefined ||
+					data.form.upload.join() !== "" || // parts without a filename are read as values
+					data.files.upload !== undefined
+				) { throw new Error("incorrect body: " + JSON.stringify(data, null, 4) ); }
+			`))
+			assert.NoError(t, err)
+		})
+
+		t.Run("withFiles", func(t *testing.T) {
+			_, err := common.RunString(rt, sr(`
+				let form = http.request("GET", "HTTPBIN_URL/myforms/post");
+				let data = form.submitForm({ fields: {
+					upload: http.file("file contents", "my.txt", "text/plain"),
+					tags: ["b", "c"],
+				} }).json();
+				if (data.form.tags.join() !== "b,c" ||
+					data.files.upload.join() !== "my.txt:text/plain:file contents"
+				) { throw new Error("incorrect body: " + JSON.stringify(data, null, 4) ); }
+			`))
+			assert.NoError(t, err)
+		})
+
+		t.Run("withSubmitButton", func(t *testing.T) {
+			_, err := common.RunString(rt, sr(`
+				let form = http.request("GET", "HTTPBIN_URL/myforms/post");
+				let data = form.submitForm({ submitSelector: "button[value=publish]" }).json();
+				if (data.query.published[0] !== "1" ||
+					data.form.action.join() !== "publish"
+				) { throw new Error("incorrect body: " + JSON.stringify(data, null, 4) ); }
+
+				data = form.submitForm({ submitSelector: "[name=preview]" }).json();
+				if (data.form["preview.x"][0] !== "0" ||
+					data.form["preview.y"][0] !== "0" ||
+					data.form.action !== undefined
+				) { throw new Error("incorrect body: " + JSON.stringify(data, null, 4) ); }
+			`))
+			assert.NoError(t, err)
+		})
+
+		t.Run("withFilesInGetForm", func(t *testing.T) {
+			_, err := common.RunString(rt, sr(`
+				let res = http.request("GET", "HTTPBIN_URL/myforms/get");
+				res.submitForm({ fields: { upload: http.file("file contents", "my.txt") } });
+			`))
+			assert.EqualError(t, err, "GoError: file field 'upload' can only be sent in a multipart body")
+		})
 	})
 
 	t.Run("ClickLink", func(t *testing.T) {
@@ -305,3 +449,61 @@ func TestResponse(t *testing.T) {
 		})
 	})
 }
+
+func TestResponseTypes(t *testing.T) {
+	tb, state, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	t.Run("text", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+			let res = http.get("HTTPBIN_URL/get", { responseType: "text" });
+			if (typeof res.body !== "string") { throw new Error("wrong body type: " + typeof res.body); }
+			if (res.json().url !== "HTTPBIN_URL/get") { throw new Error("wrong url: " + res.json().url); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+			let res = http.get("HTTPBIN_URL/bytes/100", { responseType: "binary" });
+			if (res.body.length !== 100) { throw new Error("wrong body length: " + res.body.length); }
+			if (typeof res.body[0] !== "number") { throw new Error("wrong byte type: " + typeof res.body[0]); }
+			let json = http.get("HTTPBIN_URL/get", { responseType: "binary" }).json();
+			if (json.url !== "HTTPBIN_URL/get") { throw new Error("wrong url: " + json.url); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+			let res = http.get("HTTPBIN_URL/bytes/100", { responseType: "none" });
+			if (res.status !== 200) { throw new Error("wrong status: " + res.status); }
+			if (res.body !== null) { throw new Error("the body wasn't discarded: " + res.body); }
+		`))
+		assert.NoError(t, err)
+		assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/bytes/100"), "", 200, "")
+	})
+
+	t.Run("discardResponseBodies", func(t *testing.T) {
+		state.Options.DiscardResponseBodies = null.BoolFrom(true)
+		defer func() { state.Options.DiscardResponseBodies = null.Bool{} }()
+
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+			var res = http.get("HTTPBIN_URL/bytes/100");
+			if (res.status !== 200) { throw new Error("wrong status: " + res.status); }
+			if (res.body !== null) { throw new Error("the body wasn't discarded: " + res.body); }
+			res = http.get("HTTPBIN_URL/bytes/100", { responseType: "binary" });
+			if (res.body.length !== 100) { throw new Error("wrong body length: " + res.body.length); }
+		`))
+		assert.NoError(t, err)
+		assertRequestMetricsEmitted(t, state.Samples, "GET", sr("HTTPBIN_URL/bytes/100"), "", 200, "")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`http.get("HTTPBIN_URL/get", { responseType: "json" });`))
+		assert.EqualError(t, err, `GoError: invalid responseType 'json', expected "text", "binary" or "none"`)
+	})
+}
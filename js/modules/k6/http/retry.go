@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+type ctxKey int
+
+// ctxKeyRetries holds how many times a request has been retried, if retries are on; the samples
+// of every attempt are tagged with it.
+const ctxKeyRetries ctxKey = iota
+
+// request makes a request, and makes it again while it fails in a way the httpRetry option, or
+// the request's retry param, says is worth retrying, with a growing backoff between attempts. The
+// samples of every attempt are returned.
+func (h *HTTP) request(ctx context.Context, rt *goja.Runtime, state *common.State, method string, url URL, args ...goja.Value) (*HTTPResponse, []stats.Sample, error) {
+	retry, err := retryConfig(rt, state.Options.HTTPRetry, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !retry.Enabled() {
+		return h.requestOnce(ctx, rt, state, method, url, args...)
+	}
+
+	var samples []stats.Sample
+	for retries := 0; ; retries++ {
+		res, attemptSamples, err := h.requestOnce(context.WithValue(ctx, ctxKeyRetries, retries), rt, state, method, url, args...)
+		samples = append(samples, attemptSamples...)
+		if res != nil {
+			res.Retries = retries
+		}
+		if int64(retries+1) >= retry.Attempts.Int64 || !retryable(retry, res, err) {
+			return res, samples, err
+		}
+
+		timer := time.NewTimer(retry.BackoffFor(retries + 1))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return res, samples, err
+		}
+	}
+}
+
+// retryable returns whether a request's outcome is worth another attempt.
+func retryable(retry lib.RetryConfig, res *HTTPResponse, err error) bool {
+	if err != nil {
+		// With throw on, requests that get no response return their error instead of one.
+		_, ok := err.(*netext.Error)
+		return ok && res == nil
+	}
+	return res != nil && retry.Retryable(res.Status)
+}
+
+// retryConfig returns the option's retry config, with what the retry param sets overwritten: it
+// can be an object like the option, a number of attempts, or false for no retries.
+func retryConfig(rt *goja.Runtime, opt lib.RetryConfig, args []goja.Value) (lib.RetryConfig, error) {
+	if len(args) < 2 || goja.IsUndefined(args[1]) || goja.IsNull(args[1]) {
+		return opt, nil
+	}
+	retryV := args[1].ToObject(rt).Get("retry")
+	if retryV == nil || goja.IsUndefined(retryV) || goja.IsNull(retryV) {
+		return opt, nil
+	}
+
+	var data []byte
+	switch v := retryV.Export().(type) {
+	case bool:
+		if !v {
+			return lib.RetryConfig{}, nil
+		}
+		return opt, nil
+	case int64, float64:
+		data = []byte(`{"attempts":` + retryV.String() + `}`)
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return opt, err
+		}
+	}
+	var param lib.RetryConfig
+	if err := json.Unmarshal(data, &param); err != nil {
+		return opt, errors.Wrap(err, "retry param")
+	}
+	return opt.Apply(param), nil
+}
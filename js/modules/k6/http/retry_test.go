@@ -0,0 +1,131 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestRetries(t *testing.T) {
+	tb, state, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	// Fails as many times as the fail query param says, then succeeds.
+	var calls int64
+	tb.Mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		fail, _ := strconv.ParseInt(r.URL.Query().Get("fail"), 10, 64)
+		if atomic.AddInt64(&calls, 1) <= fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	oldOpts := state.Options
+	defer func() { state.Options = oldOpts }()
+	state.Options.HTTPRetry = lib.RetryConfig{
+		Attempts: null.IntFrom(3),
+		Backoff:  types.NullDurationFrom(time.Millisecond),
+	}
+
+	retriesTags := func() (tags []string) {
+		for _, s := range state.Samples {
+			if s.Metric == metrics.HTTPReqs {
+				retries, _ := s.Tags.Get("retries")
+				tags = append(tags, retries)
+			}
+		}
+		return tags
+	}
+
+	t.Run("Option", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var res = http.get("HTTPBIN_URL/flaky?fail=2");
+		if (res.status != 200 || res.retries != 2) { throw new Error("wrong response: " + res.status + " " + res.retries); }`))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0", "1", "2"}, retriesTags())
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var res = http.get("HTTPBIN_URL/flaky?fail=5");
+		if (res.status != 503 || res.retries != 2) { throw new Error("wrong response: " + res.status + " " + res.retries); }`))
+		require.NoError(t, err)
+		assert.Len(t, retriesTags(), 3)
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var res = http.get("HTTPBIN_URL/status/500");
+		if (res.status != 500 || res.retries != 0) { throw new Error("wrong response: " + res.status + " " + res.retries); }
+		res = http.get("HTTPBIN_URL/status/500", { retry: { statuses: [500], backoff: 1 } });
+		if (res.status != 500 || res.retries != 2) { throw new Error("wrong response: " + res.status + " " + res.retries); }`))
+		require.NoError(t, err)
+		assert.Len(t, retriesTags(), 4)
+	})
+
+	t.Run("Param", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var res = http.get("HTTPBIN_URL/flaky?fail=1", { retry: false });
+		if (res.status != 503) { throw new Error("wrong status: " + res.status); }
+		res = http.get("HTTPBIN_URL/flaky?fail=3", { retry: 4 });
+		if (res.status != 200 || res.retries != 2) { throw new Error("wrong response: " + res.status + " " + res.retries); }`))
+		require.NoError(t, err)
+		// Requests that aren't retried aren't tagged.
+		assert.Equal(t, []string{"", "0", "1", "2"}, retriesTags())
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var res = http.batch([["GET", "HTTPBIN_URL/flaky?fail=1"]]);
+		if (res[0].status != 200 || res[0].retries != 1) { throw new Error("wrong response: " + res[0].status); }`))
+		require.NoError(t, err)
+		assert.Len(t, retriesTags(), 2)
+	})
+
+	t.Run("InvalidParam", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`http.get("HTTPBIN_URL/get", { retry: { attempts: 0 } })`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid retry attempts: 0")
+	})
+}
@@ -0,0 +1,215 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+const (
+	awsV4Algorithm  = "AWS4-HMAC-SHA256"
+	awsV4TimeFormat = "20060102T150405Z"
+)
+
+// AWSCredentials are used to sign requests with AWS Signature Version 4.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+}
+
+func parseAWSCredentials(rt *goja.Runtime, v goja.Value) (*AWSCredentials, error) {
+	obj := v.ToObject(rt)
+	creds := &AWSCredentials{}
+	for _, k := range obj.Keys() {
+		switch k {
+		case "accessKeyId":
+			creds.AccessKeyID = obj.Get(k).String()
+		case "secretAccessKey":
+			creds.SecretAccessKey = obj.Get(k).String()
+		case "sessionToken":
+			creds.SessionToken = obj.Get(k).String()
+		case "region":
+			creds.Region = obj.Get(k).String()
+		case "service":
+			creds.Service = obj.Get(k).String()
+		}
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" || creds.Region == "" || creds.Service == "" {
+		return nil, errors.New("aws: accessKeyId, secretAccessKey, region and service are required")
+	}
+	return creds, nil
+}
+
+// awsPayloadHash returns the hex-encoded SHA-256 of the body a request is sent with. Streamed file
+// uploads are read an extra time for it, since only S3 accepts UNSIGNED-PAYLOAD instead.
+func awsPayloadHash(bodyBuf *bytes.Buffer, streamBody *multipartBody) (string, error) {
+	h := sha256.New()
+	switch {
+	case streamBody != nil:
+		r, err := streamBody.open()
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, r)
+		_ = r.Close()
+		if err != nil {
+			return "", err
+		}
+	case bodyBuf != nil:
+		_, _ = h.Write(bodyBuf.Bytes())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// signAWSv4 adds the headers for AWS Signature Version 4 to a request: X-Amz-Date, Authorization,
+// X-Amz-Security-Token for temporary credentials and X-Amz-Content-Sha256 for S3. The payloadHash
+// is the body's, as returned by awsPayloadHash.
+func signAWSv4(req *http.Request, payloadHash string, creds *AWSCredentials, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(awsV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if creds.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	// Only sign the headers we know won't be changed on the way to the server.
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "content-md5" || strings.HasPrefix(lower, "x-amz-") {
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			headers[lower] = strings.Join(trimmed, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req, creds.Service),
+		awsCanonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format("20060102"), creds.Region, creds.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		awsV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{t.Format("20060102"), creds.Region, creds.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func awsCanonicalURI(req *http.Request, service string) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	// Paths are encoded twice, except for S3.
+	if service == "s3" {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = awsEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func awsCanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything except the RFC 3986 unreserved characters.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
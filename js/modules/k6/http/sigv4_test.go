@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAWSv4(t *testing.T) {
+	// Test vectors from the AWS Signature Version 4 test suite.
+	creds := &AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+	}
+	date := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	testdata := map[string]struct {
+		method, url, contentType, body string
+		signedHeaders, signature       string
+	}{
+		"get-vanilla": {
+			"GET", "https://example.amazonaws.com/", "", "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		"get-vanilla-query-order-key-case": {
+			"GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "",
+			"host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		"post-x-www-form-urlencoded": {
+			"POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1",
+			"content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(data.method, data.url, strings.NewReader(data.body))
+			require.NoError(t, err)
+			if data.contentType != "" {
+				req.Header.Set("Content-Type", data.contentType)
+			}
+			payloadHash, err := awsPayloadHash(bytes.NewBufferString(data.body), nil)
+			require.NoError(t, err)
+			signAWSv4(req, payloadHash, creds, date)
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Equal(t, fmt.Sprintf(
+				"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=%s, Signature=%s",
+				data.signedHeaders, data.signature,
+			), req.Header.Get("Authorization"))
+		})
+	}
+
+	t.Run("S3", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/some%20key", nil)
+		require.NoError(t, err)
+		s3 := *creds
+		s3.Service = "s3"
+		s3.SessionToken = "token"
+		payloadHash, err := awsPayloadHash(nil, nil)
+		require.NoError(t, err)
+		signAWSv4(req, payloadHash, &s3, date)
+		assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", req.Header.Get("X-Amz-Content-Sha256"))
+		assert.Equal(t, "token", req.Header.Get("X-Amz-Security-Token"))
+		assert.Contains(t, req.Header.Get("Authorization"),
+			"SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+	})
+}
+
+func TestAWSParam(t *testing.T) {
+	tb, _, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	t.Run("Signed", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		let res = http.request("GET", "HTTPBIN_URL/headers", null, {
+			aws: { accessKeyId: "AKID", secretAccessKey: "secret", region: "eu-west-1", service: "execute-api" },
+		});
+		let auth = String(res.json().headers["Authorization"]);
+		if (auth.indexOf("AWS4-HMAC-SHA256 Credential=AKID/") !== 0 || auth.indexOf("/eu-west-1/execute-api/aws4_request") === -1) {
+			throw new Error("wrong Authorization header: " + auth);
+		}`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`http.get("HTTPBIN_URL/headers", { aws: { accessKeyId: "AKID" } });`))
+		assert.Contains(t, err.Error(), "aws: accessKeyId, secretAccessKey, region and service are required")
+	})
+}
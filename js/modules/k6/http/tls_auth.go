@@ -0,0 +1,64 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/pkg/errors"
+)
+
+// parseClientCertificates reads the tlsAuth param, which takes a client certificate in the same
+// format as the tlsAuth option, or an array of them to choose from by domain.
+func parseClientCertificates(v goja.Value) ([]netext.ClientCertificate, error) {
+	data, err := json.Marshal(v.Export())
+	if err != nil {
+		return nil, errors.Wrap(err, "tlsAuth")
+	}
+
+	var auths []*lib.TLSAuth
+	if strings.HasPrefix(string(data), "{") {
+		auth := &lib.TLSAuth{}
+		if err := json.Unmarshal(data, auth); err != nil {
+			return nil, errors.Wrap(err, "tlsAuth")
+		}
+		auths = append(auths, auth)
+	} else if err := json.Unmarshal(data, &auths); err != nil {
+		return nil, errors.Wrap(err, "tlsAuth")
+	}
+
+	certs := make([]netext.ClientCertificate, 0, len(auths))
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		cert, err := auth.Certificate()
+		if err != nil {
+			return nil, errors.Wrap(err, "tlsAuth")
+		}
+		certs = append(certs, netext.ClientCertificate{Certificate: cert, Domains: auth.Domains})
+	}
+	return certs, nil
+}
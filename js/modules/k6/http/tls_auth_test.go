@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClientCertificatePEM(t *testing.T, cn string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestRequestTLSAuth(t *testing.T) {
+	tb, state, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			_, _ = fmt.Fprint(w, "anonymous")
+			return
+		}
+		_, _ = fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	transport := srv.Client().Transport.(*http.Transport)
+	origTransport := state.HTTPTransport
+	state.HTTPTransport = netext.NewHTTPTransport(transport)
+	defer func() { state.HTTPTransport = origTransport }()
+
+	aliceCert, aliceKey := newClientCertificatePEM(t, "alice")
+	bobCert, bobKey := newClientCertificatePEM(t, "bob")
+	rt.Set("srvURL", srv.URL)
+	rt.Set("alice", map[string]interface{}{"cert": aliceCert, "key": aliceKey})
+	rt.Set("bob", map[string]interface{}{"cert": bobCert, "key": bobKey, "domains": []string{"127.0.0.1"}})
+	rt.Set("carol", map[string]interface{}{"cert": bobCert, "key": bobKey, "domains": []string{"*.example.com"}})
+
+	t.Run("Single", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let res = http.get(srvURL, { tlsAuth: alice });
+		if (res.body != "alice") { throw new Error("wrong identity: " + res.body); }
+		res = http.get(srvURL, { tlsAuth: bob });
+		if (res.body != "bob") { throw new Error("wrong identity: " + res.body); }
+		res = http.get(srvURL);
+		if (res.body != "anonymous") { throw new Error("wrong identity: " + res.body); }
+		res = http.get(srvURL, { tlsAuth: alice });
+		if (res.body != "alice") { throw new Error("wrong identity: " + res.body); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Domains", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let res = http.get(srvURL, { tlsAuth: [carol, bob, alice] });
+		if (res.body != "bob") { throw new Error("wrong identity: " + res.body); }
+		res = http.get(srvURL, { tlsAuth: [carol] });
+		if (res.body != "anonymous") { throw new Error("wrong identity: " + res.body); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `http.get(srvURL, { tlsAuth: { cert: "nope", key: "nope" } });`)
+		assert.Contains(t, err.Error(), "tlsAuth")
+	})
+}
@@ -0,0 +1,368 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAlgorithm is used when a script doesn't specify a signing algorithm.
+const DefaultAlgorithm = "HS256"
+
+var b64 = base64.URLEncoding.WithPadding(base64.NoPadding)
+
+type JWT struct{}
+
+// A Token is a decoded, but not necessarily verified, JWT.
+type Token struct {
+	Header    map[string]interface{} `js:"header"`
+	Payload   map[string]interface{} `js:"payload"`
+	Signature string                 `js:"signature"`
+}
+
+func New() *JWT {
+	return &JWT{}
+}
+
+// Sign encodes the payload and signs it with the given key. HMAC algorithms take a shared secret,
+// RSA and ECDSA algorithms take a PEM-encoded private key.
+func (*JWT) Sign(payload map[string]interface{}, key string, alg ...string) (string, error) {
+	algorithm := DefaultAlgorithm
+	if len(alg) > 0 && alg[0] != "" {
+		algorithm = alg[0]
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": algorithm, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := b64.EncodeToString(header) + "." + b64.EncodeToString(body)
+	sig, err := sign(algorithm, []byte(signingInput), key)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64.EncodeToString(sig), nil
+}
+
+// Decode splits a token into its parts without verifying the signature.
+func (*JWT) Decode(token string) (*Token, error) {
+	t, _, err := decode(token)
+	return t, err
+}
+
+// Verify checks the signature and the exp/nbf claims of a token, and returns its payload.
+// HMAC algorithms take a shared secret, RSA and ECDSA algorithms take a PEM-encoded public key.
+// The kind of key decides which algorithms are accepted, whatever the token's header says, so a
+// public key can't be passed off as an HMAC secret; alg narrows it down to one algorithm.
+func (*JWT) Verify(token, key string, alg ...string) (map[string]interface{}, error) {
+	t, signingInput, err := decode(token)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, _ := t.Header["alg"].(string)
+	if len(alg) > 0 && alg[0] != "" && alg[0] != algorithm {
+		return nil, errors.Errorf("unexpected algorithm '%s', expected '%s'", algorithm, alg[0])
+	}
+	family, err := keyFamily(key)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(algorithm, family) {
+		return nil, errors.Errorf("unexpected algorithm '%s' for the key, expected one of %s256, %[2]s384 or %[2]s512",
+			algorithm, family)
+	}
+	sig, err := b64.DecodeString(t.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "signature")
+	}
+	if err := verify(algorithm, []byte(signingInput), sig, key); err != nil {
+		return nil, err
+	}
+
+	now := float64(time.Now().Unix())
+	if exp, ok := t.Payload["exp"].(float64); ok && now >= exp {
+		return nil, errors.New("token has expired")
+	}
+	if nbf, ok := t.Payload["nbf"].(float64); ok && now < nbf {
+		return nil, errors.New("token is not valid yet")
+	}
+	return t.Payload, nil
+}
+
+func decode(token string) (*Token, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, "", errors.New("malformed token, expected three dot-separated parts")
+	}
+
+	t := &Token{Signature: parts[2]}
+	for i, dst := range []*map[string]interface{}{&t.Header, &t.Payload} {
+		data, err := b64.DecodeString(parts[i])
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "part %d", i)
+		}
+		if err := json.Unmarshal(data, dst); err != nil {
+			return nil, "", errors.Wrapf(err, "part %d", i)
+		}
+	}
+	return t, parts[0] + "." + parts[1], nil
+}
+
+func hashFor(algorithm string) (crypto.Hash, error) {
+	if len(algorithm) != 5 {
+		return 0, errors.Errorf("unsupported algorithm: %s", algorithm)
+	}
+	switch algorithm[2:] {
+	case "256":
+		return crypto.SHA256, nil
+	case "384":
+		return crypto.SHA384, nil
+	case "512":
+		return crypto.SHA512, nil
+	default:
+		return 0, errors.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
+func digest(h crypto.Hash, data []byte) []byte {
+	switch h {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
+
+func hmacSum(h crypto.Hash, data []byte, key string) []byte {
+	newHash := sha256.New
+	switch h {
+	case crypto.SHA384:
+		newHash = sha512.New384
+	case crypto.SHA512:
+		newHash = sha512.New
+	}
+	mac := hmac.New(newHash, []byte(key))
+	_, _ = mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func sign(algorithm string, data []byte, key string) ([]byte, error) {
+	h, err := hashFor(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm[:2] {
+	case "HS":
+		return hmacSum(h, data, key), nil
+	case "RS":
+		priv, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.Errorf("%s requires an RSA private key", algorithm)
+		}
+		return rsa.SignPKCS1v15(rand.Reader, rsaKey, h, digest(h, data))
+	case "ES":
+		priv, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ecKey, ok := priv.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.Errorf("%s requires an ECDSA private key", algorithm)
+		}
+		if err := checkCurve(algorithm, ecKey.Curve); err != nil {
+			return nil, err
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest(h, data))
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed-size R || S encoding rather than ASN.1.
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[size-len(rb):size], rb)
+		copy(sig[2*size-len(sb):], sb)
+		return sig, nil
+	default:
+		return nil, errors.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
+func verify(algorithm string, data, sig []byte, key string) error {
+	h, err := hashFor(algorithm)
+	if err != nil {
+		return err
+	}
+
+	switch algorithm[:2] {
+	case "HS":
+		if !hmac.Equal(sig, hmacSum(h, data, key)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case "RS":
+		pub, err := parsePublicKey(key)
+		if err != nil {
+			return err
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.Errorf("%s requires an RSA public key", algorithm)
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, h, digest(h, data), sig); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case "ES":
+		pub, err := parsePublicKey(key)
+		if err != nil {
+			return err
+		}
+		ecKey, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.Errorf("%s requires an ECDSA public key", algorithm)
+		}
+		if err := checkCurve(algorithm, ecKey.Curve); err != nil {
+			return err
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(ecKey, digest(h, data), r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
+// checkCurve makes sure that an ECDSA key is on the curve its algorithm is defined for (RFC 7518
+// section 3.4), since the size of the signature depends on it.
+func checkCurve(algorithm string, curve elliptic.Curve) error {
+	want := map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}[algorithm]
+	if name := curve.Params().Name; name != want {
+		return errors.Errorf("%s requires a %s key, not a %s one", algorithm, want, name)
+	}
+	return nil
+}
+
+// keyFamily returns the prefix of the algorithms a verification key can be used with: PEM-encoded
+// keys are RSA or ECDSA public keys, anything else is an HMAC secret.
+func keyFamily(key string) (string, error) {
+	if block, _ := pem.Decode([]byte(key)); block == nil {
+		return "HS", nil
+	}
+	pub, err := parsePublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return "RS", nil
+	case *ecdsa.PublicKey:
+		return "ES", nil
+	default:
+		return "", errors.New("unsupported public key type, expected an RSA or ECDSA key")
+	}
+}
+
+func decodePEM(key string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("key is not PEM-encoded")
+	}
+	return block.Bytes, nil
+}
+
+func parsePrivateKey(key string) (interface{}, error) {
+	der, err := decodePEM(key)
+	if err != nil {
+		return nil, err
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("unsupported private key format")
+	}
+	return k, nil
+}
+
+func parsePublicKey(key string) (interface{}, error) {
+	der, err := decodePEM(key)
+	if err != nil {
+		return nil, err
+	}
+	if k, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return k, nil
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.New("unsupported public key format")
+	}
+	return cert.PublicKey, nil
+}
@@ -68,7 +68,7 @@ func (*K6) Group(ctx context.Context, name string, fn goja.Callable) (goja.Value
 	ret, err := fn(goja.Undefined())
 	t := time.Now()
 
-	tags := state.Options.RunTags.CloneTags()
+	tags := state.CloneTags()
 	if state.Options.SystemTags["group"] {
 		tags["group"] = g.Path
 	}
@@ -96,7 +96,7 @@ func (*K6) Check(ctx context.Context, arg0, checks goja.Value, extras ...goja.Va
 	t := time.Now()
 
 	// Prepare tags, make sure the `group` tag can't be overwritten.
-	commonTags := state.Options.RunTags.CloneTags()
+	commonTags := state.CloneTags()
 	if state.Options.SystemTags["group"] {
 		commonTags["group"] = state.Group.Path
 	}
@@ -142,13 +142,25 @@ func (*K6) Check(ctx context.Context, arg0, checks goja.Value, extras ...goja.Va
 			val = tmpVal
 		}
 
+		// Checks made of expect() results pass if all of them do, and log why they didn't.
+		passed := val.ToBoolean()
+		var failures []*CheckResult
+		if results := checkResults(val); results != nil {
+			for _, r := range results {
+				if !r.OK {
+					failures = append(failures, r)
+				}
+			}
+			passed = len(failures) == 0
+		}
+
 		sampleTags := stats.IntoSampleTags(&tags)
 
 		// Emit! (But only if we have a valid context.)
 		select {
 		case <-ctx.Done():
 		default:
-			if val.ToBoolean() {
+			if passed {
 				atomic.AddInt64(&check.Passes, 1)
 				state.Samples = append(state.Samples,
 					stats.Sample{Time: t, Metric: metrics.Checks, Tags: sampleTags, Value: 1},
@@ -158,6 +170,11 @@ func (*K6) Check(ctx context.Context, arg0, checks goja.Value, extras ...goja.Va
 				state.Samples = append(state.Samples,
 					stats.Sample{Time: t, Metric: metrics.Checks, Tags: sampleTags, Value: 0},
 				)
+				if state.Logger != nil {
+					for _, r := range failures {
+						state.Logger.WithField("group", state.Group.Path).Warnf("check %q failed: %s", name, r)
+					}
+				}
 
 				// A single failure makes the return value false.
 				succ = false
@@ -23,6 +23,7 @@ package metrics
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/dop251/goja"
@@ -34,24 +35,37 @@ type Metric struct {
 	metric *stats.Metric
 }
 
-func newMetric(ctxPtr *context.Context, name string, t stats.MetricType, isTime []bool) (interface{}, error) {
+func newMetric(ctxPtr *context.Context, name string, t stats.MetricType, valueType []goja.Value) (interface{}, error) {
 	if common.GetState(*ctxPtr) != nil {
 		return nil, errors.New("Metrics must be declared in the init context")
 	}
 
-	valueType := stats.Default
-	if len(isTime) > 0 && isTime[0] {
-		valueType = stats.Time
+	// The value type is either true, for time, as it used to be, or the name of one.
+	vt := stats.Default
+	if len(valueType) > 0 && !goja.IsUndefined(valueType[0]) && !goja.IsNull(valueType[0]) {
+		switch v := valueType[0].Export().(type) {
+		case bool:
+			if v {
+				vt = stats.Time
+			}
+		case string:
+			var err error
+			if vt, err = stats.ParseValueType(v); err != nil {
+				return nil, fmt.Errorf("unknown value type for metric %s: %q, it can be one of: default, time, data, dataRate", name, v)
+			}
+		default:
+			return nil, fmt.Errorf("the value type of metric %s must be the name of one, or true for time", name)
+		}
 	}
 
 	rt := common.GetRuntime(*ctxPtr)
-	return common.Bind(rt, Metric{stats.New(name, t, valueType)}, ctxPtr), nil
+	return common.Bind(rt, Metric{stats.New(name, t, vt)}, ctxPtr), nil
 }
 
 func (m Metric) Add(ctx context.Context, v goja.Value, addTags ...map[string]string) {
 	state := common.GetState(ctx)
 
-	tags := state.Options.RunTags.CloneTags()
+	tags := state.CloneTags()
 	if state.Options.SystemTags["group"] {
 		tags["group"] = state.Group.Path
 	}
@@ -78,18 +92,18 @@ func New() *Metrics {
 	return &Metrics{}
 }
 
-func (*Metrics) XCounter(ctx *context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Counter, isTime)
+func (*Metrics) XCounter(ctx *context.Context, name string, valueType ...goja.Value) (interface{}, error) {
+	return newMetric(ctx, name, stats.Counter, valueType)
 }
 
-func (*Metrics) XGauge(ctx *context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Gauge, isTime)
+func (*Metrics) XGauge(ctx *context.Context, name string, valueType ...goja.Value) (interface{}, error) {
+	return newMetric(ctx, name, stats.Gauge, valueType)
 }
 
-func (*Metrics) XTrend(ctx *context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Trend, isTime)
+func (*Metrics) XTrend(ctx *context.Context, name string, valueType ...goja.Value) (interface{}, error) {
+	return newMetric(ctx, name, stats.Trend, valueType)
 }
 
-func (*Metrics) XRate(ctx *context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Rate, isTime)
+func (*Metrics) XRate(ctx *context.Context, name string, valueType ...goja.Value) (interface{}, error) {
+	return newMetric(ctx, name, stats.Rate, valueType)
 }
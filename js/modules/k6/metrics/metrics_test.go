@@ -135,3 +135,42 @@ func TestMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricValueTypes(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctxPtr := new(context.Context)
+	*ctxPtr = common.WithRuntime(context.Background(), rt)
+	rt.Set("metrics", common.Bind(rt, New(), ctxPtr))
+
+	testdata := map[string]stats.ValueType{
+		`false`:      stats.Default,
+		`undefined`:  stats.Default,
+		`"default"`:  stats.Default,
+		`true`:       stats.Time,
+		`"time"`:     stats.Time,
+		`"data"`:     stats.Data,
+		`"dataRate"`: stats.DataRate,
+	}
+	initCtx := *ctxPtr
+	for js, valueType := range testdata {
+		*ctxPtr = initCtx
+		_, err := common.RunString(rt, fmt.Sprintf(`var m = new metrics.Trend("my_metric", %s)`, js))
+		if !assert.NoError(t, err, js) {
+			continue
+		}
+		state := &common.State{Group: &lib.Group{}}
+		*ctxPtr = common.WithState(initCtx, state)
+		_, err = common.RunString(rt, `m.add(1)`)
+		if assert.NoError(t, err, js) && assert.Len(t, state.Samples, 1, js) {
+			assert.Equal(t, valueType, state.Samples[0].Metric.Contains, js)
+		}
+	}
+	*ctxPtr = initCtx
+
+	_, err := common.RunString(rt, `new metrics.Trend("my_metric", "bytes")`)
+	assert.EqualError(t, err, `GoError: unknown value type for metric my_metric: "bytes", it can be one of: default, time, data, dataRate at apply (native)`)
+	_, err = common.RunString(rt, `new metrics.Trend("my_metric", 5)`)
+	assert.EqualError(t, err, `GoError: the value type of metric my_metric must be the name of one, or true for time at apply (native)`)
+}
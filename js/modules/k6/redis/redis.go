@@ -0,0 +1,411 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is how long a command may take if the client doesn't set a timeout.
+const DefaultTimeout = 10 * time.Second
+
+type Redis struct{}
+
+func New() *Redis {
+	return &Redis{}
+}
+
+// Options configure a client: either a redis://[:password@]host[:port][/db] URL, or an object
+// with addr, password, db and timeout (in milliseconds) properties.
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	Timeout  time.Duration
+}
+
+func parseOptions(rt *goja.Runtime, v goja.Value) (Options, error) {
+	opts := Options{Addr: "localhost:6379", Timeout: DefaultTimeout}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return opts, nil
+	}
+
+	if s, ok := v.Export().(string); ok {
+		u, err := url.Parse(s)
+		if err != nil {
+			return opts, err
+		}
+		if u.Scheme != "redis" {
+			return opts, errors.Errorf("invalid redis URL scheme '%s'", u.Scheme)
+		}
+		opts.Addr = u.Host
+		if u.Port() == "" {
+			opts.Addr = net.JoinHostPort(u.Hostname(), "6379")
+		}
+		if u.User != nil {
+			opts.Password, _ = u.User.Password()
+		}
+		if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+			if opts.DB, err = strconv.Atoi(db); err != nil {
+				return opts, errors.Errorf("invalid redis database '%s'", db)
+			}
+		}
+		return opts, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, k := range obj.Keys() {
+		switch k {
+		case "addr":
+			opts.Addr = obj.Get(k).String()
+		case "password":
+			opts.Password = obj.Get(k).String()
+		case "db":
+			opts.DB = int(obj.Get(k).ToInteger())
+		case "timeout":
+			opts.Timeout = time.Duration(obj.Get(k).ToFloat() * float64(time.Millisecond))
+		}
+	}
+	return opts, nil
+}
+
+// A Client sends commands to a Redis server over a connection of its own, which is opened when
+// the first command is sent, and reopened after network errors.
+type Client struct {
+	opts Options
+	conn *conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// XClient creates a client; it may be constructed in the init context, but it only connects once
+// a command is sent.
+func (*Redis) XClient(ctxPtr *context.Context, options goja.Value) (interface{}, error) {
+	rt := common.GetRuntime(*ctxPtr)
+	opts, err := parseOptions(rt, options)
+	if err != nil {
+		return nil, err
+	}
+	return common.Bind(rt, &Client{opts: opts}, ctxPtr), nil
+}
+
+// dial opens a connection, authenticates and selects the database.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("redis commands can only be sent in VU code")
+	}
+	nc, err := state.Dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if c.opts.Password != "" {
+		if _, err := c.roundtrip(cn, []string{"AUTH", c.opts.Password}); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := c.roundtrip(cn, []string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) roundtrip(cn *conn, args []string) (interface{}, error) {
+	if c.opts.Timeout > 0 {
+		_ = cn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	}
+	if err := writeCommand(cn.w, args); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+// do sends a command and returns its reply, emitting metrics for it.
+func (c *Client) do(ctx context.Context, args ...string) (interface{}, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("redis commands can only be sent in VU code")
+	}
+	if c.conn == nil {
+		cn, err := c.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = cn
+	}
+
+	start := time.Now()
+	reply, err := c.roundtrip(c.conn, args)
+	end := time.Now()
+	if _, ok := err.(ReplyError); err != nil && !ok {
+		// The connection is in an unknown state; start over with the next command.
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+
+	tags := state.CloneTags()
+	tags["command"] = strings.ToLower(args[0])
+	if rerr, ok := err.(ReplyError); ok {
+		// Tag with the error prefix, eg. WRONGTYPE, rather than the whole message.
+		tags["error"] = strings.SplitN(string(rerr), " ", 2)[0]
+	} else if err != nil {
+		tags["error"] = "network"
+	}
+	sampleTags := stats.IntoSampleTags(&tags)
+	state.Samples = append(state.Samples,
+		stats.Sample{Metric: metrics.RedisCommands, Time: end, Tags: sampleTags, Value: 1},
+		stats.Sample{Metric: metrics.RedisCommandDuration, Time: end, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+	)
+	return reply, err
+}
+
+// SendCommand sends any command, eg. sendCommand("ZADD", "scores", 10, "alice"), and returns
+// its reply as a string, a number, null or an array.
+func (c *Client) SendCommand(ctx context.Context, command string, args ...string) (interface{}, error) {
+	return c.do(ctx, append([]string{command}, args...)...)
+}
+
+// Get returns the value of a key, or null if it doesn't exist.
+func (c *Client) Get(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "GET", key)
+}
+
+// Set sets the value of a key, optionally expiring after a number of seconds.
+func (c *Client) Set(ctx context.Context, key, value string, expiration int) (interface{}, error) {
+	if expiration > 0 {
+		return c.do(ctx, "SET", key, value, "EX", strconv.Itoa(expiration))
+	}
+	return c.do(ctx, "SET", key, value)
+}
+
+// Del deletes keys, and returns how many existed.
+func (c *Client) Del(ctx context.Context, keys ...string) (interface{}, error) {
+	return c.do(ctx, append([]string{"DEL"}, keys...)...)
+}
+
+// Exists returns how many of the keys exist.
+func (c *Client) Exists(ctx context.Context, keys ...string) (interface{}, error) {
+	return c.do(ctx, append([]string{"EXISTS"}, keys...)...)
+}
+
+// Expire sets a key to expire after a number of seconds, and returns whether it exists.
+func (c *Client) Expire(ctx context.Context, key string, seconds int) (bool, error) {
+	reply, err := c.do(ctx, "EXPIRE", key, strconv.Itoa(seconds))
+	return reply == int64(1), err
+}
+
+// Ttl returns the number of seconds until a key expires, -1 if it doesn't, or -2 if it doesn't
+// exist.
+func (c *Client) Ttl(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "TTL", key)
+}
+
+// Incr increments the number stored at a key, and returns the new value.
+func (c *Client) Incr(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "INCR", key)
+}
+
+// IncrBy increments the number stored at a key by an amount, and returns the new value.
+func (c *Client) IncrBy(ctx context.Context, key string, n int64) (interface{}, error) {
+	return c.do(ctx, "INCRBY", key, strconv.FormatInt(n, 10))
+}
+
+// Decr decrements the number stored at a key, and returns the new value.
+func (c *Client) Decr(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "DECR", key)
+}
+
+// DecrBy decrements the number stored at a key by an amount, and returns the new value.
+func (c *Client) DecrBy(ctx context.Context, key string, n int64) (interface{}, error) {
+	return c.do(ctx, "DECRBY", key, strconv.FormatInt(n, 10))
+}
+
+// Hset sets a field in a hash, and returns 1 if it's new or 0 if it was updated.
+func (c *Client) Hset(ctx context.Context, key, field, value string) (interface{}, error) {
+	return c.do(ctx, "HSET", key, field, value)
+}
+
+// Hget returns the value of a field in a hash, or null.
+func (c *Client) Hget(ctx context.Context, key, field string) (interface{}, error) {
+	return c.do(ctx, "HGET", key, field)
+}
+
+// Hgetall returns all the fields of a hash, as an object.
+func (c *Client) Hgetall(ctx context.Context, key string) (map[string]interface{}, error) {
+	reply, err := c.do(ctx, "HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	hash := make(map[string]interface{}, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		if k, ok := items[i].(string); ok {
+			hash[k] = items[i+1]
+		}
+	}
+	return hash, nil
+}
+
+// Hdel deletes fields from a hash, and returns how many existed.
+func (c *Client) Hdel(ctx context.Context, key string, fields ...string) (interface{}, error) {
+	return c.do(ctx, append([]string{"HDEL", key}, fields...)...)
+}
+
+// Lpush prepends values to a list, and returns its new length.
+func (c *Client) Lpush(ctx context.Context, key string, values ...string) (interface{}, error) {
+	return c.do(ctx, append([]string{"LPUSH", key}, values...)...)
+}
+
+// Rpush appends values to a list, and returns its new length.
+func (c *Client) Rpush(ctx context.Context, key string, values ...string) (interface{}, error) {
+	return c.do(ctx, append([]string{"RPUSH", key}, values...)...)
+}
+
+// Lpop removes and returns the first value of a list, or null if it's empty.
+func (c *Client) Lpop(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "LPOP", key)
+}
+
+// Rpop removes and returns the last value of a list, or null if it's empty.
+func (c *Client) Rpop(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "RPOP", key)
+}
+
+// Lrange returns the values of a list between two indexes, inclusive; negative indexes count from
+// the end.
+func (c *Client) Lrange(ctx context.Context, key string, start, stop int) (interface{}, error) {
+	return c.do(ctx, "LRANGE", key, strconv.Itoa(start), strconv.Itoa(stop))
+}
+
+// Llen returns the length of a list.
+func (c *Client) Llen(ctx context.Context, key string) (interface{}, error) {
+	return c.do(ctx, "LLEN", key)
+}
+
+// Publish sends a message to a channel, and returns how many subscribers received it.
+func (c *Client) Publish(ctx context.Context, channel, message string) (interface{}, error) {
+	return c.do(ctx, "PUBLISH", channel, message)
+}
+
+// A Message is a message received on a subscribed channel.
+type Message struct {
+	Channel string `js:"channel"`
+	Message string `js:"message"`
+}
+
+// A Subscription receives the messages published to one or more channels, over a connection of
+// its own.
+type Subscription struct {
+	client *Client
+	conn   *conn
+}
+
+// Subscribe subscribes to channels on a new connection.
+func (c *Client) Subscribe(ctxPtr *context.Context, channels ...string) (interface{}, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("subscribe needs at least one channel")
+	}
+	cn, err := c.dial(*ctxPtr)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.Timeout > 0 {
+		_ = cn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	}
+	if err := writeCommand(cn.w, append([]string{"SUBSCRIBE"}, channels...)); err != nil {
+		_ = cn.Close()
+		return nil, err
+	}
+	// Every channel is confirmed separately.
+	for range channels {
+		if _, err := readReply(cn.r); err != nil {
+			_ = cn.Close()
+			return nil, err
+		}
+	}
+	return common.Bind(common.GetRuntime(*ctxPtr), &Subscription{client: c, conn: cn}, ctxPtr), nil
+}
+
+// Receive waits for the next message, for up to a timeout in milliseconds (the client's timeout by
+// default), and returns it, or null if none arrived in time.
+func (s *Subscription) Receive(ctx context.Context, timeout float64) (interface{}, error) {
+	d := s.client.opts.Timeout
+	if timeout > 0 {
+		d = time.Duration(timeout * float64(time.Millisecond))
+	}
+	deadline := time.Now().Add(d)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = s.conn.SetReadDeadline(deadline)
+
+	for {
+		reply, err := readReply(s.conn.r)
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 3 || items[0] != "message" {
+			continue
+		}
+		channel, _ := items[1].(string)
+		message, _ := items[2].(string)
+		return &Message{Channel: channel, Message: message}, nil
+	}
+}
+
+// Close unsubscribes and closes the subscription's connection.
+func (s *Subscription) Close() error {
+	return s.conn.Close()
+}
+
+// Close closes the client's connection; it's reopened if more commands are sent.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,296 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer implements just enough of Redis for the tests.
+type fakeServer struct {
+	net.Listener
+
+	mu          sync.Mutex
+	password    string
+	strings     map[string]string
+	hashes      map[string]map[string]string
+	lists       map[string][]string
+	subscribers map[string][]*bufio.Writer
+}
+
+func newFakeServer(t *testing.T, password string) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeServer{
+		Listener:    l,
+		password:    password,
+		strings:     make(map[string]string),
+		hashes:      make(map[string]map[string]string),
+		lists:       make(map[string][]string),
+		subscribers: make(map[string][]*bufio.Writer),
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer func() { _ = c.Close() }()
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	authed := s.password == ""
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items := req.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = item.(string)
+		}
+
+		s.mu.Lock()
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			authed = args[1] == s.password
+			if authed {
+				_, _ = w.WriteString("+OK\r\n")
+			} else {
+				_, _ = w.WriteString("-WRONGPASS invalid password\r\n")
+			}
+		case !authed:
+			_, _ = w.WriteString("-NOAUTH Authentication required.\r\n")
+		case cmd == "SELECT":
+			_, _ = w.WriteString("+OK\r\n")
+		case cmd == "GET":
+			if v, ok := s.strings[args[1]]; ok {
+				writeBulk(w, v)
+			} else if _, ok := s.lists[args[1]]; ok {
+				_, _ = w.WriteString("-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+			} else {
+				_, _ = w.WriteString("$-1\r\n")
+			}
+		case cmd == "SET":
+			s.strings[args[1]] = args[2]
+			_, _ = w.WriteString("+OK\r\n")
+		case cmd == "INCR" || cmd == "INCRBY":
+			n, _ := strconv.ParseInt(s.strings[args[1]], 10, 64)
+			by := int64(1)
+			if cmd == "INCRBY" {
+				by, _ = strconv.ParseInt(args[2], 10, 64)
+			}
+			s.strings[args[1]] = strconv.FormatInt(n+by, 10)
+			_, _ = w.WriteString(":" + s.strings[args[1]] + "\r\n")
+		case cmd == "DEL":
+			n := 0
+			for _, k := range args[1:] {
+				if _, ok := s.strings[k]; ok {
+					delete(s.strings, k)
+					n++
+				}
+			}
+			_, _ = w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+		case cmd == "HSET":
+			if s.hashes[args[1]] == nil {
+				s.hashes[args[1]] = make(map[string]string)
+			}
+			s.hashes[args[1]][args[2]] = args[3]
+			_, _ = w.WriteString(":1\r\n")
+		case cmd == "HGETALL":
+			hash := s.hashes[args[1]]
+			_, _ = w.WriteString("*" + strconv.Itoa(2*len(hash)) + "\r\n")
+			for k, v := range hash {
+				writeBulk(w, k)
+				writeBulk(w, v)
+			}
+		case cmd == "RPUSH":
+			s.lists[args[1]] = append(s.lists[args[1]], args[2:]...)
+			_, _ = w.WriteString(":" + strconv.Itoa(len(s.lists[args[1]])) + "\r\n")
+		case cmd == "LRANGE":
+			list := s.lists[args[1]]
+			_, _ = w.WriteString("*" + strconv.Itoa(len(list)) + "\r\n")
+			for _, v := range list {
+				writeBulk(w, v)
+			}
+		case cmd == "SUBSCRIBE":
+			for i, ch := range args[1:] {
+				s.subscribers[ch] = append(s.subscribers[ch], w)
+				_, _ = w.WriteString("*3\r\n")
+				writeBulk(w, "subscribe")
+				writeBulk(w, ch)
+				_, _ = w.WriteString(":" + strconv.Itoa(i+1) + "\r\n")
+			}
+		case cmd == "PUBLISH":
+			subs := s.subscribers[args[1]]
+			for _, sw := range subs {
+				_, _ = sw.WriteString("*3\r\n")
+				writeBulk(sw, "message")
+				writeBulk(sw, args[1])
+				writeBulk(sw, args[2])
+				_ = sw.Flush()
+			}
+			_, _ = w.WriteString(":" + strconv.Itoa(len(subs)) + "\r\n")
+		default:
+			_, _ = w.WriteString("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+		_ = w.Flush()
+		s.mu.Unlock()
+	}
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	_, _ = w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func newRuntime(t *testing.T) (*goja.Runtime, *context.Context, *common.State) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	state := &common.State{
+		Options: lib.Options{},
+		Dialer:  netext.NewDialer(net.Dialer{}),
+	}
+	ctxPtr := new(context.Context)
+	*ctxPtr = common.WithRuntime(context.Background(), rt)
+	rt.Set("redis", common.Bind(rt, New(), ctxPtr))
+	return rt, ctxPtr, state
+}
+
+func TestClient(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer func() { _ = srv.Close() }()
+
+	rt, ctxPtr, state := newRuntime(t)
+	rt.Set("addr", srv.Addr().String())
+	_, err := common.RunString(rt, `var client = new redis.Client({ addr: addr, password: "secret", db: 1 });`)
+	require.NoError(t, err)
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `client.get("x")`)
+		assert.Contains(t, err.Error(), "redis commands can only be sent in VU code")
+	})
+
+	*ctxPtr = common.WithState(*ctxPtr, state)
+
+	t.Run("Strings", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, `
+		if (client.get("counter") !== null) { throw new Error("unset key isn't null"); }
+		if (client.set("name", "alice") !== "OK") { throw new Error("set failed"); }
+		if (client.get("name") !== "alice") { throw new Error("wrong value"); }
+		if (client.incr("counter") !== 1 || client.incrBy("counter", 10) !== 11) { throw new Error("wrong counter"); }
+		if (client.del("name", "nope") !== 1) { throw new Error("wrong del count"); }
+		`)
+		assert.NoError(t, err)
+
+		if assert.Len(t, state.Samples, 12) {
+			sample := state.Samples[1]
+			assert.Equal(t, metrics.RedisCommandDuration, sample.Metric)
+			tag, _ := sample.Tags.Get("command")
+			assert.Equal(t, "get", tag)
+		}
+	})
+
+	t.Run("Hashes", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		client.hset("user:1", "name", "alice");
+		client.hset("user:1", "age", 30);
+		var user = client.hgetall("user:1");
+		if (user.name !== "alice" || user.age !== "30") { throw new Error("wrong hash: " + JSON.stringify(user)); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Lists", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		if (client.rpush("queue", "a", "b") !== 2) { throw new Error("wrong length"); }
+		if (JSON.stringify(client.lrange("queue", 0, -1)) !== '["a","b"]') { throw new Error("wrong range"); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, `client.get("queue")`)
+		assert.Contains(t, err.Error(), "redis: WRONGTYPE Operation against a key holding the wrong kind of value")
+		_, err = common.RunString(rt, `client.sendCommand("NOPE", "x")`)
+		assert.Contains(t, err.Error(), "redis: ERR unknown command 'NOPE'")
+		if assert.Len(t, state.Samples, 4) {
+			tag, _ := state.Samples[0].Tags.Get("error")
+			assert.Equal(t, "WRONGTYPE", tag)
+		}
+
+		// The connection is still usable after error replies.
+		_, err = common.RunString(rt, `if (client.get("counter") !== "11") { throw new Error("wrong value"); }`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		_, err := common.RunString(rt, `new redis.Client("redis://:wrong@" + addr).get("x")`)
+		assert.Contains(t, err.Error(), "redis: WRONGPASS invalid password")
+	})
+
+	t.Run("PubSub", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var sub = client.subscribe("news", "weather");
+		if (client.publish("weather", "sunny") !== 1) { throw new Error("wrong subscriber count"); }
+		var msg = sub.receive();
+		if (msg.channel !== "weather" || msg.message !== "sunny") { throw new Error("wrong message: " + JSON.stringify(msg)); }
+		if (sub.receive(50) !== null) { throw new Error("received a message that wasn't sent"); }
+		sub.close();
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestParseOptions(t *testing.T) {
+	rt := goja.New()
+	opts, err := parseOptions(rt, rt.ToValue("redis://:pw@cache.local/3"))
+	require.NoError(t, err)
+	assert.Equal(t, Options{Addr: "cache.local:6379", Password: "pw", DB: 3, Timeout: DefaultTimeout}, opts)
+
+	_, err = parseOptions(rt, rt.ToValue("http://cache.local"))
+	assert.EqualError(t, err, "invalid redis URL scheme 'http'")
+
+	opts, err = parseOptions(rt, goja.Undefined())
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:6379", opts.Addr)
+}
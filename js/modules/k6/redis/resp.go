@@ -0,0 +1,103 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package redis
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// A ReplyError is an error reply from the server, eg. "WRONGTYPE Operation against a key holding
+// the wrong kind of value".
+type ReplyError string
+
+func (e ReplyError) Error() string {
+	return "redis: " + string(e)
+}
+
+// writeCommand writes a command in the RESP format, as an array of bulk strings.
+func writeCommand(w *bufio.Writer, args []string) error {
+	_, _ = w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		_, _ = w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		_, _ = w.WriteString(arg)
+		_, _ = w.WriteString("\r\n")
+	}
+	return w.Flush()
+}
+
+// readReply reads a RESP reply; a string, an int64, nil, a []interface{} of those, or a ReplyError.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.Errorf("redis: invalid reply line %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, ReplyError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			if _, ok := err.(ReplyError); err != nil && !ok {
+				return nil, err
+			} else if ok {
+				item = err.Error()
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, errors.Errorf("redis: invalid reply type %q", line[0])
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/secrets"
+	"github.com/pkg/errors"
+)
+
+type Secrets struct{}
+
+func New() *Secrets {
+	return &Secrets{}
+}
+
+// Get returns a secret from the named source, or from the first one given with --secret-source.
+// Secrets are fetched once per test, and redacted from everything k6 logs.
+func (*Secrets) Get(ctx context.Context, name string, source ...string) (string, error) {
+	var m *secrets.Manager
+	if state := common.GetState(ctx); state != nil {
+		m = state.Secrets
+	} else if env := common.GetInitEnv(ctx); env != nil {
+		m = env.Secrets
+	}
+	if m == nil {
+		return "", errors.New("no secret sources are set up, use --secret-source")
+	}
+
+	src := ""
+	if len(source) > 0 {
+		src = source[0]
+	}
+	return m.Get(ctx, src, name)
+}
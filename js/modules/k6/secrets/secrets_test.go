@@ -0,0 +1,74 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/secrets"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretsGet(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/a.env", []byte("TOKEN=abc\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.env", []byte("TOKEN=def\n"), 0644))
+	m := secrets.NewManager()
+	for _, name := range []string{"a", "b"} {
+		src, err := secrets.NewFileSource(fs, "/"+name+".env")
+		require.NoError(t, err)
+		require.NoError(t, m.Add(name, src))
+	}
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Set("secrets", common.Bind(rt, New(), &ctx))
+
+	t.Run("NoSources", func(t *testing.T) {
+		_, err := common.RunString(rt, `secrets.get("TOKEN")`)
+		assert.Contains(t, err.Error(), "no secret sources are set up, use --secret-source")
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		ctx = common.WithInitEnv(context.Background(), &common.InitEnvironment{Secrets: m})
+		v, err := common.RunString(rt, `secrets.get("TOKEN")`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "abc", v.String())
+		}
+	})
+
+	t.Run("VU", func(t *testing.T) {
+		ctx = common.WithState(context.Background(), &common.State{Secrets: m})
+		v, err := common.RunString(rt, `secrets.get("TOKEN", "b")`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "def", v.String())
+		}
+
+		_, err = common.RunString(rt, `secrets.get("PASSWORD")`)
+		assert.Contains(t, err.Error(), "couldn't get the secret PASSWORD from a: no such secret")
+	})
+}
@@ -0,0 +1,190 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+const (
+	soap11EnvelopeNS = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12EnvelopeNS = "http://www.w3.org/2003/05/soap-envelope"
+	xsiNS            = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// envelope builds the SOAP envelope that invokes an operation. The arguments are the contents of
+// the body's element in document style, or the operation's parameters in RPC style; when a
+// document style message has several parts, they're the contents of each part by its name.
+func (svc *service) envelope(rt *goja.Runtime, op *operation, args goja.Value, header string) (string, error) {
+	envNS := soap11EnvelopeNS
+	if svc.version == "1.2" {
+		envNS = soap12EnvelopeNS
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&buf, `<soap:Envelope xmlns:soap="%s" xmlns:xsi="%s">`, envNS, xsiNS)
+	if header != "" {
+		buf.WriteString("<soap:Header>" + header + "</soap:Header>")
+	}
+	buf.WriteString("<soap:Body>")
+
+	w := &writer{rt: rt, buf: &buf}
+	if op.style == "rpc" {
+		fmt.Fprintf(&buf, `<ns:%s xmlns:ns="%s">`, op.name, escape(op.namespace))
+		obj := toObject(rt, args)
+		for _, p := range op.parts {
+			if obj == nil {
+				break
+			}
+			if err := w.element("", p.name, obj.Get(p.name)); err != nil {
+				return "", err
+			}
+		}
+		fmt.Fprintf(&buf, `</ns:%s>`, op.name)
+	} else {
+		obj := toObject(rt, args)
+		for i, p := range op.parts {
+			if p.element.Local == "" {
+				return "", errors.Errorf("part '%s' of operation '%s' has no element", p.name, op.name)
+			}
+			content := args
+			if len(op.parts) > 1 {
+				content = nil
+				if obj != nil {
+					content = obj.Get(p.name)
+				}
+			}
+			prefix := "ns" + strconv.Itoa(i)
+			if svc.qualified[p.element.Space] {
+				w.prefix = prefix
+			} else {
+				w.prefix = ""
+			}
+			fmt.Fprintf(&buf, `<%s:%s xmlns:%s="%s"`, prefix, p.element.Local, prefix, escape(p.element.Space))
+			if err := w.content(content); err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&buf, `</%s:%s>`, prefix, p.element.Local)
+		}
+	}
+
+	buf.WriteString("</soap:Body></soap:Envelope>")
+	return buf.String(), nil
+}
+
+func toObject(rt *goja.Runtime, v goja.Value) *goja.Object {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	return v.ToObject(rt)
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// A writer serializes JS values as XML. Objects become child elements, in the order of their
+// keys, except for keys starting with "@", which are attributes, and "#text", which is text;
+// arrays become repeated elements, and null an element with xsi:nil.
+type writer struct {
+	rt     *goja.Runtime
+	buf    *bytes.Buffer
+	prefix string
+}
+
+// element writes a value as an element. The value isn't namespaced unless the writer has a prefix.
+func (w *writer) element(prefix, name string, v goja.Value) error {
+	if v == nil || goja.IsUndefined(v) {
+		return nil
+	}
+	tag := name
+	if prefix != "" {
+		tag = prefix + ":" + name
+	}
+	if goja.IsNull(v) {
+		fmt.Fprintf(w.buf, `<%s xsi:nil="true"/>`, tag)
+		return nil
+	}
+	if _, ok := v.Export().([]interface{}); ok {
+		obj := v.ToObject(w.rt)
+		length := int(obj.Get("length").ToInteger())
+		for i := 0; i < length; i++ {
+			if err := w.element(prefix, name, obj.Get(strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w.buf.WriteString("<" + tag)
+	if err := w.content(v); err != nil {
+		return err
+	}
+	w.buf.WriteString("</" + tag + ">")
+	return nil
+}
+
+// content writes the rest of an element's start tag, and its content.
+func (w *writer) content(v goja.Value) error {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		w.buf.WriteString(">")
+		return nil
+	}
+	switch e := v.Export().(type) {
+	case map[string]interface{}:
+		obj := v.ToObject(w.rt)
+		keys := obj.Keys()
+		for _, key := range keys {
+			if strings.HasPrefix(key, "@") {
+				fmt.Fprintf(w.buf, ` %s="%s"`, key[1:], escape(obj.Get(key).String()))
+			}
+		}
+		w.buf.WriteString(">")
+		for _, key := range keys {
+			switch {
+			case strings.HasPrefix(key, "@"):
+			case key == "#text":
+				w.buf.WriteString(escape(obj.Get(key).String()))
+			default:
+				if err := w.element(w.prefix, key, obj.Get(key)); err != nil {
+					return err
+				}
+			}
+		}
+	case time.Time:
+		w.buf.WriteString(">" + e.Format(time.RFC3339Nano))
+	case []interface{}:
+		return errors.New("arrays can only be the values of elements")
+	default:
+		w.buf.WriteString(">" + escape(v.String()))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package soap
+
+import (
+	"context"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	k6http "github.com/loadimpact/k6/js/modules/k6/http"
+	"github.com/pkg/errors"
+)
+
+type SOAP struct{}
+
+func New() *SOAP {
+	return &SOAP{}
+}
+
+// A Client invokes the operations of a SOAP service described by a WSDL.
+type Client struct {
+	svc  *service
+	http *k6http.HTTP
+}
+
+// XClient parses a WSDL, which is usually read with open(). The options are endpoint, to send
+// requests somewhere else than the WSDL's address, and port, to pick the port whose binding is used.
+// Besides call(), the client has a function for every operation that doesn't clash with its methods,
+// so client.Add(args) is the same as client.call("Add", args).
+func (*SOAP) XClient(ctxPtr *context.Context, wsdl string, opts goja.Value) (map[string]interface{}, error) {
+	rt := common.GetRuntime(*ctxPtr)
+
+	var endpoint, port string
+	if opts != nil && !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+		obj := opts.ToObject(rt)
+		for _, k := range obj.Keys() {
+			switch k {
+			case "endpoint":
+				endpoint = obj.Get(k).String()
+			case "port":
+				port = obj.Get(k).String()
+			default:
+				return nil, errors.Errorf("unknown SOAP client option '%s'", k)
+			}
+		}
+	}
+
+	svc, err := parseWSDL(wsdl, port)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint != "" {
+		svc.endpoint = endpoint
+	}
+	if svc.endpoint == "" {
+		return nil, errors.New("the WSDL has no address for the port, so an endpoint is needed")
+	}
+
+	c := &Client{svc: svc, http: k6http.New()}
+	exports := common.Bind(rt, c, ctxPtr)
+	for _, name := range svc.names {
+		if _, ok := exports[name]; ok {
+			continue
+		}
+		name := name
+		exports[name] = func(call goja.FunctionCall) goja.Value {
+			if *ctxPtr == nil {
+				common.Throw(rt, errors.Errorf("%s() can only be called from within default()", name))
+			}
+			res, err := c.Call(*ctxPtr, name, call.Argument(0), call.Argument(1))
+			if err != nil {
+				common.Throw(rt, err)
+			}
+			return rt.ToValue(res)
+		}
+	}
+	return exports, nil
+}
+
+// Operations returns the names of the operations, in the order of the WSDL.
+func (c *Client) Operations() []string {
+	return c.svc.names
+}
+
+func (c *Client) operation(name string) (*operation, error) {
+	op, ok := c.svc.operations[name]
+	if !ok {
+		return nil, errors.Errorf("unknown SOAP operation '%s'", name)
+	}
+	return op, nil
+}
+
+// Envelope returns the envelope that call() would send, which helps with debugging a script.
+func (c *Client) Envelope(ctx context.Context, name string, args goja.Value, params goja.Value) (string, error) {
+	rt := common.GetRuntime(ctx)
+	op, err := c.operation(name)
+	if err != nil {
+		return "", err
+	}
+	return c.svc.envelope(rt, op, args, soapHeader(rt, params))
+}
+
+func soapHeader(rt *goja.Runtime, params goja.Value) string {
+	if params == nil || goja.IsUndefined(params) || goja.IsNull(params) {
+		return ""
+	}
+	if header := params.ToObject(rt).Get("soapHeader"); header != nil && !goja.IsUndefined(header) {
+		return header.String()
+	}
+	return ""
+}
+
+// Call POSTs the envelope that invokes an operation, with the SOAPAction the binding specifies,
+// and tags the request with the operation (soap_operation) and the action (soap_action). The
+// params are those of http.request(), plus soapHeader, the XML to put in the envelope's header.
+// Faults are responses like any others, with a 500 status, so res.xml() can read them.
+func (c *Client) Call(ctx context.Context, name string, args goja.Value, params goja.Value) (*k6http.HTTPResponse, error) {
+	rt := common.GetRuntime(ctx)
+	op, err := c.operation(name)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := c.svc.envelope(rt, op, args, soapHeader(rt, params))
+	if err != nil {
+		return nil, err
+	}
+
+	reqParams := rt.NewObject()
+	tags := rt.NewObject()
+	headers := rt.NewObject()
+	if c.svc.version == "1.2" {
+		contentType := "application/soap+xml; charset=utf-8"
+		if op.action != "" {
+			contentType += `; action="` + op.action + `"`
+		}
+		_ = headers.Set("Content-Type", contentType)
+	} else {
+		_ = headers.Set("Content-Type", "text/xml; charset=utf-8")
+		_ = headers.Set("SOAPAction", `"`+op.action+`"`)
+	}
+	_ = tags.Set("soap_operation", op.name)
+	if op.action != "" {
+		_ = tags.Set("soap_action", op.action)
+	}
+	if params != nil && !goja.IsUndefined(params) && !goja.IsNull(params) {
+		paramsObj := params.ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			v := paramsObj.Get(k)
+			switch k {
+			case "soapHeader":
+			case "headers", "tags":
+				if goja.IsUndefined(v) || goja.IsNull(v) {
+					continue
+				}
+				dst := headers
+				if k == "tags" {
+					dst = tags
+				}
+				obj := v.ToObject(rt)
+				for _, key := range obj.Keys() {
+					_ = dst.Set(key, obj.Get(key))
+				}
+			default:
+				_ = reqParams.Set(k, v)
+			}
+		}
+	}
+	_ = reqParams.Set("headers", headers)
+	_ = reqParams.Set("tags", tags)
+
+	return c.http.Request(ctx, k6http.HTTP_METHOD_POST, rt.ToValue(c.svc.endpoint), rt.ToValue(envelope), reqParams)
+}
@@ -0,0 +1,223 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package soap
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/oxtoacart/bpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWSDL = `<?xml version="1.0" encoding="utf-8"?>
+<wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/"
+	xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/"
+	xmlns:soap12="http://schemas.xmlsoap.org/wsdl/soap12/"
+	xmlns:s="http://www.w3.org/2001/XMLSchema"
+	xmlns:tns="http://tempuri.org/"
+	targetNamespace="http://tempuri.org/">
+	<wsdl:types>
+		<s:schema elementFormDefault="qualified" targetNamespace="http://tempuri.org/">
+			<s:element name="Add">
+				<s:complexType><s:sequence>
+					<s:element name="intA" type="s:int"/>
+					<s:element name="intB" type="s:int"/>
+				</s:sequence></s:complexType>
+			</s:element>
+		</s:schema>
+	</wsdl:types>
+	<wsdl:message name="AddSoapIn"><wsdl:part name="parameters" element="tns:Add"/></wsdl:message>
+	<wsdl:message name="EchoIn">
+		<wsdl:part name="text" type="s:string"/>
+		<wsdl:part name="times" type="s:int"/>
+	</wsdl:message>
+	<wsdl:portType name="CalculatorSoap">
+		<wsdl:operation name="Add"><wsdl:input message="tns:AddSoapIn"/></wsdl:operation>
+		<wsdl:operation name="Echo"><wsdl:input message="tns:EchoIn"/></wsdl:operation>
+	</wsdl:portType>
+	<wsdl:binding name="CalculatorSoap" type="tns:CalculatorSoap">
+		<soap:binding transport="http://schemas.xmlsoap.org/soap/http"/>
+		<wsdl:operation name="Add">
+			<soap:operation soapAction="http://tempuri.org/Add" style="document"/>
+			<wsdl:input><soap:body use="literal"/></wsdl:input>
+		</wsdl:operation>
+		<wsdl:operation name="Echo">
+			<soap:operation soapAction="http://tempuri.org/Echo" style="rpc"/>
+			<wsdl:input><soap:body use="literal" namespace="urn:echo"/></wsdl:input>
+		</wsdl:operation>
+	</wsdl:binding>
+	<wsdl:binding name="CalculatorSoap12" type="tns:CalculatorSoap">
+		<soap12:binding transport="http://schemas.xmlsoap.org/soap/http"/>
+		<wsdl:operation name="Add">
+			<soap12:operation soapAction="http://tempuri.org/Add" style="document"/>
+			<wsdl:input><soap12:body use="literal"/></wsdl:input>
+		</wsdl:operation>
+		<wsdl:operation name="Echo">
+			<soap12:operation soapAction="http://tempuri.org/Echo" style="rpc"/>
+			<wsdl:input><soap12:body use="literal" namespace="urn:echo"/></wsdl:input>
+		</wsdl:operation>
+	</wsdl:binding>
+	<wsdl:service name="Calculator">
+		<wsdl:port name="CalculatorSoap" binding="tns:CalculatorSoap">
+			<soap:address location="HTTPBIN_URL/soap"/>
+		</wsdl:port>
+		<wsdl:port name="CalculatorSoap12" binding="tns:CalculatorSoap12">
+			<soap12:address location="HTTPBIN_URL/soap12"/>
+		</wsdl:port>
+	</wsdl:service>
+</wsdl:definitions>`
+
+const testEnvelopeStart = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+	`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`
+
+func TestParseWSDL(t *testing.T) {
+	svc, err := parseWSDL(testWSDL, "")
+	require.NoError(t, err)
+	assert.Equal(t, "HTTPBIN_URL/soap", svc.endpoint)
+	assert.Equal(t, "1.1", svc.version)
+	assert.Equal(t, []string{"Add", "Echo"}, svc.names)
+	assert.Equal(t, &operation{
+		name:   "Add",
+		action: "http://tempuri.org/Add",
+		style:  "document",
+		parts:  []part{{name: "parameters", element: xml.Name{Space: "http://tempuri.org/", Local: "Add"}}},
+	}, svc.operations["Add"])
+	assert.Equal(t, &operation{
+		name:      "Echo",
+		action:    "http://tempuri.org/Echo",
+		style:     "rpc",
+		namespace: "urn:echo",
+		parts:     []part{{name: "text"}, {name: "times"}},
+	}, svc.operations["Echo"])
+	assert.True(t, svc.qualified["http://tempuri.org/"])
+
+	svc, err = parseWSDL(testWSDL, "CalculatorSoap12")
+	require.NoError(t, err)
+	assert.Equal(t, "HTTPBIN_URL/soap12", svc.endpoint)
+	assert.Equal(t, "1.2", svc.version)
+
+	_, err = parseWSDL(testWSDL, "Nope")
+	assert.EqualError(t, err, "no SOAP port named 'Nope' in the WSDL")
+	_, err = parseWSDL(`<a/>`, "")
+	assert.EqualError(t, err, "not a WSDL 1.1 document")
+	_, err = parseWSDL(`<a>`, "")
+	assert.EqualError(t, err, "invalid WSDL: XML syntax error on line 1: unexpected EOF")
+}
+
+func TestSOAP(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"contentType": r.Header.Get("Content-Type"),
+			"soapAction":  r.Header.Get("SOAPAction"),
+			"body":        string(body),
+		})
+	}
+	tb.Mux.HandleFunc("/soap", echo)
+	tb.Mux.HandleFunc("/soap12", echo)
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	state := &common.State{
+		Options:       lib.Options{SystemTags: lib.GetTagSet("url", "status")},
+		Group:         root,
+		HTTPTransport: netext.NewHTTPTransport(tb.HTTPTransport),
+		BPool:         bpool.NewBufferPool(1),
+	}
+	ctx := new(context.Context)
+	*ctx = common.WithRuntime(context.Background(), rt)
+	*ctx = common.WithState(*ctx, state)
+	rt.Set("soap", common.Bind(rt, New(), ctx))
+	rt.Set("wsdl", sr(testWSDL))
+
+	t.Run("Document", func(t *testing.T) {
+		state.Samples = nil
+		v, err := common.RunString(rt, `
+		let client = new soap.Client(wsdl);
+		client.Add({ intA: 1, intB: "<2>" }).json();
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"contentType": "text/xml; charset=utf-8",
+			"soapAction":  `"http://tempuri.org/Add"`,
+			"body": testEnvelopeStart + `<soap:Body><ns0:Add xmlns:ns0="http://tempuri.org/">` +
+				`<ns0:intA>1</ns0:intA><ns0:intB>&lt;2&gt;</ns0:intB></ns0:Add></soap:Body></soap:Envelope>`,
+		}, v.Export())
+
+		require.NotEmpty(t, state.Samples)
+		for _, sample := range state.Samples {
+			op, _ := sample.Tags.Get("soap_operation")
+			action, _ := sample.Tags.Get("soap_action")
+			assert.Equal(t, "Add", op)
+			assert.Equal(t, "http://tempuri.org/Add", action)
+		}
+	})
+
+	t.Run("RPC", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		new soap.Client(wsdl).envelope("Echo", { text: { "@lang": "en", "#text": "hi" }, times: [1, 2] }, { soapHeader: "<auth>x</auth>" });
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, testEnvelopeStart+`<soap:Header><auth>x</auth></soap:Header><soap:Body><ns:Echo xmlns:ns="urn:echo">`+
+			`<text lang="en">hi</text><times>1</times><times>2</times></ns:Echo></soap:Body></soap:Envelope>`, v.Export())
+	})
+
+	t.Run("SOAP12", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		let res = new soap.Client(wsdl, { port: "CalculatorSoap12" }).call("Add", { intA: null }, { tags: { mytag: "a" } });
+		res.json();
+		`)
+		require.NoError(t, err)
+		data := v.Export().(map[string]interface{})
+		assert.Equal(t, `application/soap+xml; charset=utf-8; action="http://tempuri.org/Add"`, data["contentType"])
+		assert.Equal(t, "", data["soapAction"])
+		assert.Contains(t, data["body"], `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"`)
+		assert.Contains(t, data["body"], `<ns0:intA xsi:nil="true"/>`)
+
+		sample := state.Samples[len(state.Samples)-1]
+		assert.Equal(t, "a", sample.Tags.CloneTags()["mytag"])
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := common.RunString(rt, `new soap.Client(wsdl).call("Nope")`)
+		assert.EqualError(t, err, "GoError: unknown SOAP operation 'Nope'")
+		_, err = common.RunString(rt, `new soap.Client(wsdl, { foo: 1 })`)
+		assert.EqualError(t, err, "GoError: unknown SOAP client option 'foo' at apply (native)")
+	})
+}
@@ -0,0 +1,268 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package soap
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	wsdlNS       = "http://schemas.xmlsoap.org/wsdl/"
+	soap11WSDLNS = "http://schemas.xmlsoap.org/wsdl/soap/"
+	soap12WSDLNS = "http://schemas.xmlsoap.org/wsdl/soap12/"
+	xsdNS        = "http://www.w3.org/2001/XMLSchema"
+)
+
+// A node is an element of a parsed WSDL, with the namespaces in scope for it, which are needed to
+// resolve the QNames in attributes such as message="tns:AddRequest".
+type node struct {
+	name     xml.Name
+	attrs    map[string]string
+	scope    map[string]string
+	children []*node
+}
+
+func parseTree(src string) (*node, error) {
+	dec := xml.NewDecoder(strings.NewReader(src))
+	var root *node
+	stack := []*node{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if root != nil && len(stack) == 0 {
+				return root, nil
+			}
+			return nil, errors.Wrap(err, "invalid WSDL")
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attrs: make(map[string]string), scope: make(map[string]string)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				for prefix, space := range parent.scope {
+					n.scope[prefix] = space
+				}
+				parent.children = append(parent.children, n)
+			} else if root != nil {
+				return nil, errors.New("invalid WSDL: more than one root element")
+			} else {
+				root = n
+			}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					n.scope[attr.Name.Local] = attr.Value
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					n.scope[""] = attr.Value
+				case attr.Name.Space == "":
+					n.attrs[attr.Name.Local] = attr.Value
+				}
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// all returns the children with a name.
+func (n *node) all(space, local string) []*node {
+	var nodes []*node
+	for _, child := range n.children {
+		if child.name.Space == space && child.name.Local == local {
+			nodes = append(nodes, child)
+		}
+	}
+	return nodes
+}
+
+// first returns the first child with a name, or nil.
+func (n *node) first(space, local string) *node {
+	if nodes := n.all(space, local); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+// qname resolves the QName in an attribute.
+func (n *node) qname(attr string) xml.Name {
+	value := n.attrs[attr]
+	prefix, local := "", value
+	if i := strings.Index(value, ":"); i != -1 {
+		prefix, local = value[:i], value[i+1:]
+	}
+	return xml.Name{Space: n.scope[prefix], Local: local}
+}
+
+// A part of a message; in document style it refers to the element that makes up the body,
+// otherwise it's named after the operation's parameter it holds.
+type part struct {
+	name    string
+	element xml.Name
+}
+
+// An operation is what a client can invoke, with the parts its input message consists of.
+type operation struct {
+	name      string
+	action    string
+	style     string
+	namespace string
+	parts     []part
+}
+
+type service struct {
+	endpoint   string
+	version    string
+	operations map[string]*operation
+	names      []string
+	// qualified has the target namespaces of the schemas whose local elements are qualified.
+	qualified map[string]bool
+}
+
+// parseWSDL reads the operations of a WSDL 1.1 document's port with a SOAP 1.1 or 1.2 binding;
+// that's the first one found, unless a port is named.
+func parseWSDL(src, portName string) (*service, error) {
+	root, err := parseTree(src)
+	if err != nil {
+		return nil, err
+	}
+	if root.name.Space != wsdlNS || root.name.Local != "definitions" {
+		return nil, errors.New("not a WSDL 1.1 document")
+	}
+
+	svc := &service{operations: make(map[string]*operation), qualified: make(map[string]bool)}
+	if types := root.first(wsdlNS, "types"); types != nil {
+		for _, schema := range types.all(xsdNS, "schema") {
+			if schema.attrs["elementFormDefault"] == "qualified" {
+				svc.qualified[schema.attrs["targetNamespace"]] = true
+			}
+		}
+	}
+
+	var port, binding *node
+	var bindingNS string
+	for _, s := range root.all(wsdlNS, "service") {
+		for _, p := range s.all(wsdlNS, "port") {
+			if portName != "" && p.attrs["name"] != portName {
+				continue
+			}
+			b := findNamed(root, "binding", p.qname("binding").Local)
+			if b == nil {
+				return nil, errors.Errorf("binding '%s' not found", p.attrs["binding"])
+			}
+			for _, ns := range []string{soap11WSDLNS, soap12WSDLNS} {
+				if b.first(ns, "binding") != nil {
+					port, binding, bindingNS = p, b, ns
+					break
+				}
+			}
+			if port != nil {
+				break
+			}
+		}
+		if port != nil {
+			break
+		}
+	}
+	if port == nil {
+		if portName != "" {
+			return nil, errors.Errorf("no SOAP port named '%s' in the WSDL", portName)
+		}
+		return nil, errors.New("no SOAP port found in the WSDL")
+	}
+
+	svc.version = "1.1"
+	if bindingNS == soap12WSDLNS {
+		svc.version = "1.2"
+	}
+	if address := port.first(bindingNS, "address"); address != nil {
+		svc.endpoint = address.attrs["location"]
+	}
+
+	portType := findNamed(root, "portType", binding.qname("type").Local)
+	if portType == nil {
+		return nil, errors.Errorf("port type '%s' not found", binding.attrs["type"])
+	}
+	defaultStyle := binding.first(bindingNS, "binding").attrs["style"]
+	if defaultStyle == "" {
+		defaultStyle = "document"
+	}
+
+	for _, bop := range binding.all(wsdlNS, "operation") {
+		op := &operation{name: bop.attrs["name"], style: defaultStyle}
+		if soapOp := bop.first(bindingNS, "operation"); soapOp != nil {
+			op.action = soapOp.attrs["soapAction"]
+			if style := soapOp.attrs["style"]; style != "" {
+				op.style = style
+			}
+		}
+		if input := bop.first(wsdlNS, "input"); input != nil {
+			if body := input.first(bindingNS, "body"); body != nil {
+				op.namespace = body.attrs["namespace"]
+			}
+		}
+
+		var ptOp *node
+		for _, candidate := range portType.all(wsdlNS, "operation") {
+			if candidate.attrs["name"] == op.name {
+				ptOp = candidate
+				break
+			}
+		}
+		if ptOp == nil {
+			return nil, errors.Errorf("operation '%s' not found in port type '%s'", op.name, portType.attrs["name"])
+		}
+		if input := ptOp.first(wsdlNS, "input"); input != nil {
+			msg := findNamed(root, "message", input.qname("message").Local)
+			if msg == nil {
+				return nil, errors.Errorf("message '%s' not found", input.attrs["message"])
+			}
+			for _, p := range msg.all(wsdlNS, "part") {
+				pt := part{name: p.attrs["name"]}
+				if p.attrs["element"] != "" {
+					pt.element = p.qname("element")
+				}
+				op.parts = append(op.parts, pt)
+			}
+		}
+		if op.style == "rpc" && op.namespace == "" {
+			op.namespace = root.attrs["targetNamespace"]
+		}
+
+		svc.operations[op.name] = op
+		svc.names = append(svc.names, op.name)
+	}
+	return svc, nil
+}
+
+// findNamed returns the top level WSDL element of a kind with a name. Definitions imported from
+// other documents aren't supported, so the names are all in the document's target namespace.
+func findNamed(root *node, kind, name string) *node {
+	for _, n := range root.all(wsdlNS, kind) {
+		if n.attrs["name"] == name {
+			return n
+		}
+	}
+	return nil
+}
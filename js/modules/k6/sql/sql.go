@@ -0,0 +1,195 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+type SQL struct{}
+
+func New() *SQL {
+	return &SQL{}
+}
+
+// Open returns a handle to a database; drivers are "postgres" and "mysql", and the data source
+// name is in the driver's own format. No connection is made until the first query is run.
+func (*SQL) Open(ctxPtr *context.Context, driver, dsn string) (interface{}, error) {
+	if !isRegistered(driver) {
+		return nil, errors.Errorf("unsupported database driver '%s', supported drivers are: %s",
+			driver, strings.Join(dbsql.Drivers(), ", "))
+	}
+	db, err := dbsql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return common.Bind(common.GetRuntime(*ctxPtr), &DB{db: db, driver: driver}, ctxPtr), nil
+}
+
+func isRegistered(driver string) bool {
+	drivers := dbsql.Drivers()
+	i := sort.SearchStrings(drivers, driver)
+	return i < len(drivers) && drivers[i] == driver
+}
+
+// A DB is a pool of connections to a database, shared by everything the VU that opened it runs.
+type DB struct {
+	db     *dbsql.DB
+	driver string
+}
+
+// A Result describes the effect of a statement run with exec().
+type Result struct {
+	RowsAffected int64       `js:"rowsAffected"`
+	LastInsertID interface{} `js:"lastInsertId"`
+}
+
+// Query runs a query and returns its rows, as objects keyed by column name. Arguments are bound to
+// placeholders in the query, $1, $2... for postgres and ? for mysql.
+func (d *DB) Query(ctx context.Context, query string, args ...interface{}) (goja.Value, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("queries can only be run in VU code")
+	}
+
+	var data []byte
+	start := time.Now()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err == nil {
+		data, err = marshalRows(rows)
+	}
+	d.emit(state, "query", start, time.Now(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := common.GetRuntime(ctx)
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	return parse(goja.Undefined(), rt.ToValue(string(data)))
+}
+
+// Exec runs a statement that doesn't return rows, eg. an INSERT or a CREATE TABLE.
+func (d *DB) Exec(ctx context.Context, query string, args ...interface{}) (*Result, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("queries can only be run in VU code")
+	}
+
+	start := time.Now()
+	res, err := d.db.ExecContext(ctx, query, args...)
+	d.emit(state, "exec", start, time.Now(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	if result.RowsAffected, err = res.RowsAffected(); err != nil {
+		return nil, err
+	}
+	// Not every driver supports this, postgres needs a RETURNING clause instead.
+	if id, err := res.LastInsertId(); err == nil {
+		result.LastInsertID = id
+	}
+	return result, nil
+}
+
+// Close closes all of the database's connections.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func (d *DB) emit(state *common.State, operation string, start, end time.Time, err error) {
+	tags := state.CloneTags()
+	tags["driver"] = d.driver
+	tags["operation"] = operation
+	if err != nil {
+		tags["error"] = errorCode(err)
+	}
+	sampleTags := stats.IntoSampleTags(&tags)
+	state.Samples = append(state.Samples,
+		stats.Sample{Metric: metrics.SQLQueries, Time: end, Tags: sampleTags, Value: 1},
+		stats.Sample{Metric: metrics.SQLQueryDuration, Time: end, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+	)
+}
+
+// errorCode returns the database's code for an error, eg. 23505 for a unique violation in
+// postgres or 1062 for a duplicate key in mysql, or "driver" for errors that didn't come from the
+// database itself.
+func errorCode(err error) string {
+	switch e := err.(type) {
+	case *pq.Error:
+		return string(e.Code)
+	case *mysql.MySQLError:
+		return strconv.Itoa(int(e.Number))
+	default:
+		return "driver"
+	}
+}
+
+// marshalRows reads all rows into a JSON array of objects.
+func marshalRows(rows *dbsql.Rows) ([]byte, error) {
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			// Text columns are often returned as raw bytes, which would otherwise become base64.
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,165 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/lib/pq"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver answers a handful of known statements, so tests don't need a real database.
+type fakeDriver struct{}
+
+type fakeConn struct{}
+
+type fakeStmt struct{ query string }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func init() {
+	dbsql.Register("fake", fakeDriver{})
+}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		return driver.RowsAffected(len(args)), nil
+	case strings.HasPrefix(s.query, "DUPLICATE"):
+		return nil, &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}
+	default:
+		return nil, errors.New("syntax error")
+	}
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "SELECT") {
+		return nil, errors.New("syntax error")
+	}
+	rows := &fakeRows{columns: []string{"name", "age", "nickname"}}
+	for _, arg := range args {
+		rows.rows = append(rows.rows, []driver.Value{[]byte(arg.(string)), int64(30), nil})
+	}
+	return rows, nil
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQL(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("sql", common.Bind(rt, New(), &ctx))
+
+	_, err := common.RunString(rt, `var db = sql.open("fake", "");`)
+	require.NoError(t, err)
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `db.query("SELECT 1")`)
+		assert.Contains(t, err.Error(), "queries can only be run in VU code")
+	})
+
+	t.Run("UnknownDriver", func(t *testing.T) {
+		_, err := common.RunString(rt, `sql.open("oracle", "")`)
+		assert.Contains(t, err.Error(), "unsupported database driver 'oracle', supported drivers are: fake, mysql, postgres")
+	})
+
+	state := &common.State{}
+	ctx = common.WithState(ctx, state)
+
+	t.Run("Query", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, `
+		var rows = db.query("SELECT name, age, nickname FROM users WHERE name IN ($1, $2)", "alice", "bob");
+		if (rows.length !== 2) { throw new Error("wrong number of rows: " + rows.length); }
+		if (rows[0].name !== "alice" || rows[1].name !== "bob") { throw new Error("wrong names: " + JSON.stringify(rows)); }
+		if (rows[0].age !== 30 || rows[0].nickname !== null) { throw new Error("wrong values: " + JSON.stringify(rows[0])); }
+		if (db.query("SELECT name FROM users").length !== 0) { throw new Error("expected no rows"); }
+		`)
+		assert.NoError(t, err)
+
+		if assert.Len(t, state.Samples, 4) {
+			assert.Equal(t, metrics.SQLQueries, state.Samples[0].Metric)
+			assert.Equal(t, metrics.SQLQueryDuration, state.Samples[1].Metric)
+			assert.Equal(t, map[string]string{"driver": "fake", "operation": "query"}, state.Samples[0].Tags.CloneTags())
+		}
+	})
+
+	t.Run("Exec", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var res = db.exec("INSERT INTO users (name, age) VALUES (?, ?)", "carol", 25);
+		if (res.rowsAffected !== 2) { throw new Error("wrong rowsAffected: " + res.rowsAffected); }
+		if (res.lastInsertId !== null) { throw new Error("unexpected lastInsertId: " + res.lastInsertId); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, `db.exec("DUPLICATE")`)
+		assert.Contains(t, err.Error(), "duplicate key value violates unique constraint")
+		_, err = common.RunString(rt, `db.query("DROP TABLE users")`)
+		assert.Contains(t, err.Error(), "syntax error")
+
+		if assert.Len(t, state.Samples, 4) {
+			code, _ := state.Samples[0].Tags.Get("error")
+			assert.Equal(t, "23505", code)
+			code, _ = state.Samples[2].Tags.Get("error")
+			assert.Equal(t, "driver", code)
+		}
+	})
+
+	_, err = common.RunString(rt, `db.close()`)
+	assert.NoError(t, err)
+}
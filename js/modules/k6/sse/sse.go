@@ -0,0 +1,392 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sse
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+type SSE struct{}
+
+// A Client is the handle scripts get to an open event stream, to register event handlers, schedule
+// callbacks and close the stream.
+type Client struct {
+	ctx           context.Context
+	body          io.ReadCloser
+	eventHandlers map[string][]goja.Callable
+	scheduled     chan goja.Callable
+	done          chan struct{}
+	shutdownOnce  sync.Once
+
+	eventTimestamps []eventTimestamp
+}
+
+type eventTimestamp struct {
+	name string
+	time time.Time
+}
+
+// An Event is a single event received from the stream.
+type Event struct {
+	ID   string `js:"id"`
+	Name string `js:"name"`
+	Data string `js:"data"`
+}
+
+// A Response describes the response that opened the stream.
+type Response struct {
+	URL     string            `js:"url"`
+	Status  int               `js:"status"`
+	Headers map[string]string `js:"headers"`
+	Error   string            `js:"error"`
+}
+
+func New() *SSE {
+	return &SSE{}
+}
+
+// Open opens an event stream, calls the setup function with the client, and then runs an event
+// loop dispatching events to the client's handlers until the stream is closed, by either side.
+// Params are headers, tags, method and body.
+func (*SSE) Open(ctx context.Context, url string, args ...goja.Value) (*Response, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("event streams can only be opened in VU code")
+	}
+
+	// The params argument is optional
+	var callableV, paramsV goja.Value
+	switch len(args) {
+	case 2:
+		paramsV = args[0]
+		callableV = args[1]
+	case 1:
+		paramsV = goja.Undefined()
+		callableV = args[0]
+	default:
+		return nil, errors.New("invalid number of arguments to sse.open")
+	}
+	setupFn, isFunc := goja.AssertFunction(callableV)
+	if !isFunc {
+		return nil, errors.New("last argument to sse.open must be a function")
+	}
+
+	tags := state.CloneTags()
+	if state.Options.SystemTags["url"] {
+		tags["url"] = url
+	}
+	if state.Options.SystemTags["group"] {
+		tags["group"] = state.Group.Path
+	}
+
+	method := http.MethodGet
+	var body string
+	header := http.Header{}
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		params := paramsV.ToObject(rt)
+		for _, k := range params.Keys() {
+			v := params.Get(k)
+			if goja.IsUndefined(v) || goja.IsNull(v) {
+				continue
+			}
+			switch k {
+			case "headers":
+				headers := v.ToObject(rt)
+				for _, key := range headers.Keys() {
+					header.Set(key, headers.Get(key).String())
+				}
+			case "tags":
+				tagObj := v.ToObject(rt)
+				for _, key := range tagObj.Keys() {
+					tags[key] = tagObj.Get(key).String()
+				}
+			case "method":
+				method = strings.ToUpper(v.String())
+			case "body":
+				body = v.String()
+			}
+		}
+	}
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header = header
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	client := &Client{
+		ctx:           ctx,
+		eventHandlers: make(map[string][]goja.Callable),
+		scheduled:     make(chan goja.Callable),
+		done:          make(chan struct{}),
+	}
+
+	// Run the user-provided set up function
+	if _, err := setupFn(goja.Undefined(), rt.ToValue(client)); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	httpResponse, connErr := (&http.Client{Transport: state.HTTPTransport}).Do(req)
+	connectionEnd := time.Now()
+	connectionDuration := stats.D(connectionEnd.Sub(start))
+
+	if connErr != nil {
+		// Pass the error to the user script before exiting immediately
+		netErr := netext.NewError(connErr)
+		client.handleEvent("error", rt.ToValue(netErr))
+		return nil, netErr
+	}
+	client.body = httpResponse.Body
+	defer func() { _ = httpResponse.Body.Close() }()
+
+	response := &Response{
+		URL:     url,
+		Status:  httpResponse.StatusCode,
+		Headers: make(map[string]string, len(httpResponse.Header)),
+	}
+	for k, vs := range httpResponse.Header {
+		response.Headers[k] = strings.Join(vs, ", ")
+	}
+	if state.Options.SystemTags["status"] {
+		tags["status"] = strconv.Itoa(httpResponse.StatusCode)
+	}
+
+	sampleTags := stats.IntoSampleTags(&tags)
+	samples := []stats.Sample{
+		{Metric: metrics.SSESessions, Time: start, Tags: sampleTags, Value: 1},
+		{Metric: metrics.SSEConnecting, Time: start, Tags: sampleTags, Value: connectionDuration},
+	}
+
+	// Anything else than a 200 with the right content type isn't an event stream.
+	contentType := httpResponse.Header.Get("Content-Type")
+	if httpResponse.StatusCode != http.StatusOK {
+		response.Error = "unexpected status " + httpResponse.Status
+	} else if !strings.HasPrefix(contentType, "text/event-stream") {
+		response.Error = "unexpected content type '" + contentType + "'"
+	}
+	if response.Error != "" {
+		client.handleEvent("error", rt.ToValue(response.Error))
+		state.Samples = append(state.Samples, samples...)
+		return response, nil
+	}
+
+	// The stream is now open, emit the event
+	client.handleEvent("open")
+
+	eventChan := make(chan Event)
+	readErrChan := make(chan error, 1)
+	go readEvents(httpResponse.Body, eventChan, readErrChan, client.done)
+
+	// This is the main control loop. All JS code (including error handlers)
+	// should only be executed by this thread to avoid race conditions
+	for {
+		select {
+		case event := <-eventChan:
+			client.eventTimestamps = append(client.eventTimestamps, eventTimestamp{event.Name, time.Now()})
+			client.handleEvent("event", rt.ToValue(&event))
+
+		case readErr := <-readErrChan:
+			if readErr != io.EOF {
+				client.handleEvent("error", rt.ToValue(readErr))
+			}
+			client.closeStream()
+
+		case scheduledFn := <-client.scheduled:
+			if _, err := scheduledFn(goja.Undefined()); err != nil {
+				client.closeStream()
+				return nil, err
+			}
+
+		case <-ctx.Done():
+			// VU is shutting down during an interrupt
+			// stream events will not be forwarded to the VU
+			client.closeStream()
+
+		case <-client.done:
+			// This is the final exit point normally triggered by closeStream
+			end := time.Now()
+			samples = append(samples, stats.Sample{
+				Metric: metrics.SSESessionDuration, Time: start, Tags: sampleTags, Value: stats.D(end.Sub(start)),
+			})
+			if len(client.eventTimestamps) > 0 {
+				samples = append(samples, stats.Sample{
+					Metric: metrics.SSETimeToFirstEvent, Time: start, Tags: sampleTags,
+					Value: stats.D(client.eventTimestamps[0].time.Sub(connectionEnd)),
+				})
+			}
+			for _, ts := range client.eventTimestamps {
+				eventTags := sampleTags.CloneTags()
+				eventTags["event"] = ts.name
+				samples = append(samples, stats.Sample{
+					Metric: metrics.SSEEventsReceived,
+					Time:   ts.time,
+					Tags:   stats.IntoSampleTags(&eventTags),
+					Value:  1,
+				})
+			}
+			state.Samples = append(state.Samples, samples...)
+			return response, nil
+		}
+	}
+}
+
+// On registers a handler for an event: "open", "event", "error" or "close".
+func (c *Client) On(event string, handler goja.Value) {
+	if handler, ok := goja.AssertFunction(handler); ok {
+		c.eventHandlers[event] = append(c.eventHandlers[event], handler)
+	}
+}
+
+func (c *Client) handleEvent(event string, args ...goja.Value) {
+	if handlers, ok := c.eventHandlers[event]; ok {
+		for _, handler := range handlers {
+			if _, err := handler(goja.Undefined(), args...); err != nil {
+				common.Throw(common.GetRuntime(c.ctx), err)
+			}
+		}
+	}
+}
+
+func (c *Client) SetTimeout(fn goja.Callable, timeoutMs int) {
+	// Starts a goroutine, blocks once on the timeout and pushes the callable
+	// back to the main loop through the scheduled channel
+	go func() {
+		select {
+		case <-time.After(time.Duration(timeoutMs) * time.Millisecond):
+			select {
+			case c.scheduled <- fn:
+			case <-c.done:
+			}
+
+		case <-c.done:
+			return
+		}
+	}()
+}
+
+func (c *Client) SetInterval(fn goja.Callable, intervalMs int) {
+	// Starts a goroutine, blocks forever on the ticker and pushes the callable
+	// back to the main loop through the scheduled channel
+	go func() {
+		ticker := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case c.scheduled <- fn:
+				case <-c.done:
+					return
+				}
+
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close closes the stream, which ends the event loop.
+func (c *Client) Close() {
+	c.closeStream()
+}
+
+func (c *Client) closeStream() {
+	c.shutdownOnce.Do(func() {
+		c.handleEvent("close")
+		if c.body != nil {
+			_ = c.body.Close()
+		}
+
+		// Stops the main control loop
+		close(c.done)
+	})
+}
+
+// readEvents parses the stream according to the EventSource specification, and sends the
+// events it contains to a channel until the stream ends.
+func readEvents(r io.Reader, eventChan chan<- Event, errChan chan<- error, done <-chan struct{}) {
+	br := bufio.NewReader(r)
+	var event Event
+	var data []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			errChan <- err
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// A blank line dispatches the event, if it has any data.
+		if line == "" {
+			if len(data) > 0 {
+				event.Data = strings.Join(data, "\n")
+				if event.Name == "" {
+					event.Name = "message"
+				}
+				select {
+				case eventChan <- event:
+				case <-done:
+					return
+				}
+			}
+			// The last event ID carries over to the next events.
+			event = Event{ID: event.ID}
+			data = nil
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "":
+			// A comment, often used as a keep-alive.
+		case "event":
+			event.Name = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		}
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		_, _ = fmt.Fprint(w, "id: 1\ndata: hello\n\n")
+		_, _ = fmt.Fprint(w, "event: update\r\ndata: first line\r\ndata:second line\r\n\r\n")
+		_, _ = fmt.Fprint(w, "data: {\"n\": 3}\n\n")
+	})
+	mux.HandleFunc("/forever", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/method", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		_, _ = fmt.Fprintf(w, "data: %s %s %s\n\n", r.Method, r.Header.Get("Accept"), r.Header.Get("X-Token"))
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, "<html></html>")
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSSE(t *testing.T) {
+	srv := newServer()
+	defer srv.Close()
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	state := &common.State{
+		Group:         root,
+		HTTPTransport: http.DefaultTransport,
+		Options:       lib.Options{SystemTags: lib.GetTagSet("url", "status")},
+	}
+	ctx := common.WithRuntime(context.Background(), rt)
+	ctx = common.WithState(ctx, state)
+	rt.Set("sse", common.Bind(rt, New(), &ctx))
+	rt.Set("baseURL", srv.URL)
+
+	t.Run("Events", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, `
+		var events = [], opened = false, closed = false;
+		var res = sse.open(baseURL + "/events", function(client) {
+			client.on("open", function() { opened = true; });
+			client.on("event", function(e) { events.push(e.id + "|" + e.name + "|" + e.data); });
+			client.on("close", function() { closed = true; });
+		});
+		if (res.status !== 200) { throw new Error("wrong status: " + res.status); }
+		if (!opened || !closed) { throw new Error("missing open or close event"); }
+		var expected = ["1|message|hello", "1|update|first line\nsecond line", '1|message|{"n": 3}'];
+		if (JSON.stringify(events) !== JSON.stringify(expected)) { throw new Error("wrong events: " + JSON.stringify(events)); }
+		`)
+		assert.NoError(t, err)
+
+		seen := map[*stats.Metric]int{}
+		for _, sample := range state.Samples {
+			seen[sample.Metric]++
+			url, _ := sample.Tags.Get("url")
+			assert.Equal(t, srv.URL+"/events", url)
+		}
+		assert.Equal(t, map[*stats.Metric]int{
+			metrics.SSESessions:         1,
+			metrics.SSEConnecting:       1,
+			metrics.SSESessionDuration:  1,
+			metrics.SSETimeToFirstEvent: 1,
+			metrics.SSEEventsReceived:   3,
+		}, seen)
+		event, _ := state.Samples[len(state.Samples)-2].Tags.Get("event")
+		assert.Equal(t, "update", event)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var count = 0;
+		sse.open(baseURL + "/forever", function(client) {
+			client.on("event", function(e) {
+				if (++count === 3) { client.close(); }
+			});
+			client.setTimeout(function() { throw new Error("the stream wasn't closed"); }, 5000);
+		});
+		if (count !== 3) { throw new Error("wrong count: " + count); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Params", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var data;
+		sse.open(baseURL + "/method", { method: "post", headers: { "X-Token": "abc" } }, function(client) {
+			client.on("event", function(e) { data = e.data; });
+		});
+		if (data !== "POST text/event-stream abc") { throw new Error("wrong data: " + data); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		var errors = [];
+		function collect(client) { client.on("error", function(e) { errors.push(String(e)); }); }
+		var res = sse.open(baseURL + "/html", collect);
+		if (res.error !== "unexpected content type 'text/html'") { throw new Error("wrong error: " + res.error); }
+		res = sse.open(baseURL + "/missing", collect);
+		if (res.status !== 404) { throw new Error("wrong status: " + res.status); }
+		if (errors.length !== 2) { throw new Error("wrong errors: " + JSON.stringify(errors)); }
+		`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `sse.open("http://127.0.0.1:1/", function() {})`)
+		assert.Error(t, err)
+	})
+}
+
+func TestReadEvents(t *testing.T) {
+	eventChan := make(chan Event, 10)
+	errChan := make(chan error, 1)
+	readEvents(strings.NewReader("data: no blank line at the end"), eventChan, errChan, make(chan struct{}))
+	assert.Len(t, eventChan, 0)
+	assert.Error(t, <-errChan)
+}
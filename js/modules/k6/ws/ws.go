@@ -24,9 +24,11 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,6 +38,8 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 )
 
@@ -62,6 +66,12 @@ type Socket struct {
 	pingTimestamps     []pingDelta
 }
 
+// message is a data frame read from the connection, with its type.
+type message struct {
+	mtype int
+	data  []byte
+}
+
 type WSHTTPResponse struct {
 	URL     string
 	Status  int
@@ -102,7 +112,12 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 	// Leave header to nil by default so we can pass it directly to the Dialer
 	var header http.Header
 
-	tags := state.Options.RunTags.CloneTags()
+	// Carries the proxy set in params, if any.
+	dialCtx := ctx
+
+	var enableCompression bool
+
+	tags := state.CloneTags()
 	if state.Options.SystemTags["url"] {
 		tags["url"] = url
 	}
@@ -128,6 +143,33 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 				for _, key := range headersObj.Keys() {
 					header.Set(key, headersObj.Get(key).String())
 				}
+			case "proxy":
+				proxyV := params.Get(k)
+				if goja.IsUndefined(proxyV) || goja.IsNull(proxyV) {
+					continue
+				}
+				// An empty proxy connects directly, whatever the proxy option says.
+				var proxyURL *neturl.URL
+				if str := proxyV.String(); str != "" {
+					var err error
+					if proxyURL, err = types.ParseProxyURL(str); err != nil {
+						return nil, err
+					}
+				}
+				dialCtx = netext.WithProxy(dialCtx, proxyURL)
+			case "compression":
+				compressionV := params.Get(k)
+				if goja.IsUndefined(compressionV) || goja.IsNull(compressionV) {
+					continue
+				}
+				// permessage-deflate is the only extension gorilla/websocket implements.
+				switch algo := compressionV.String(); algo {
+				case "":
+				case "deflate":
+					enableCompression = true
+				default:
+					return nil, fmt.Errorf("unsupported compression algorithm '%s', only 'deflate' is", algo)
+				}
 			case "tags":
 				tagsV := params.Get(k)
 				if goja.IsUndefined(tagsV) || goja.IsNull(tagsV) {
@@ -159,9 +201,27 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 	}
 
 	wsd := websocket.Dialer{
-		NetDial:         netDial,
-		Proxy:           http.ProxyFromEnvironment,
-		TLSClientConfig: tlsConfig,
+		NetDial:           netDial,
+		TLSClientConfig:   tlsConfig,
+		EnableCompression: enableCompression,
+	}
+
+	// Connect through the same proxy as HTTP requests, unless params set another one.
+	proxyURL, err := wsProxy(dialCtx, state, url)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL != nil {
+		switch {
+		case netext.IsSOCKSProxy(proxyURL):
+			if wsd.NetDial, err = state.Dialer.DialSOCKS(ctx, proxyURL); err != nil {
+				return nil, err
+			}
+		case proxyURL.Scheme == "https":
+			return nil, errors.New("websockets can't go through HTTPS proxies, only HTTP and SOCKS5 ones")
+		default:
+			wsd.Proxy = http.ProxyURL(proxyURL)
+		}
 	}
 
 	start := time.Now()
@@ -185,9 +245,10 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 
 	if connErr != nil {
 		// Pass the error to the user script before exiting immediately
-		socket.handleEvent("error", rt.ToValue(connErr))
+		netErr := netext.NewError(connErr)
+		socket.handleEvent("error", rt.ToValue(netErr))
 
-		return nil, connErr
+		return nil, netErr
 	}
 
 	wsResponse, wsRespErr := wrapHTTPResponse(httpResponse)
@@ -214,7 +275,7 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 	conn.SetPingHandler(func(msg string) error { pingChan <- msg; return nil })
 	conn.SetPongHandler(func(pingID string) error { pongChan <- pingID; return nil })
 
-	readDataChan := make(chan []byte)
+	readDataChan := make(chan message)
 	readCloseChan := make(chan int)
 	readErrChan := make(chan error)
 
@@ -242,7 +303,11 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 
 		case readData := <-readDataChan:
 			socket.msgReceivedTimestamps = append(socket.msgReceivedTimestamps, time.Now())
-			socket.handleEvent("message", rt.ToValue(string(readData)))
+			if readData.mtype == websocket.BinaryMessage {
+				socket.handleEvent("binaryMessage", rt.ToValue(readData.data))
+			} else {
+				socket.handleEvent("message", rt.ToValue(string(readData.data)))
+			}
 
 		case readErr := <-readErrChan:
 			socket.handleEvent("error", rt.ToValue(readErr))
@@ -314,6 +379,23 @@ func (s *Socket) On(event string, handler goja.Value) {
 	}
 }
 
+// wsProxy returns the proxy to connect to the given URL through, if any.
+func wsProxy(ctx context.Context, state *common.State, url string) (*neturl.URL, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	// The proxy is picked like for the HTTP request the handshake is.
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	req := (&http.Request{Method: "GET", URL: u, Header: make(http.Header)}).WithContext(ctx)
+	return state.Dialer.ProxyFunc(state.Options.Proxy.ProxyFunc())(req)
+}
+
 func (s *Socket) handleEvent(event string, args ...goja.Value) {
 	if handlers, ok := s.eventHandlers[event]; ok {
 		for _, handler := range handlers {
@@ -325,13 +407,32 @@ func (s *Socket) handleEvent(event string, args ...goja.Value) {
 }
 
 func (s *Socket) Send(message string) {
-	// NOTE: No binary message support for the time being since goja doesn't
-	// support typed arrays.
+	s.write(websocket.TextMessage, []byte(message))
+}
+
+// SendBinary sends a binary message. goja has no typed arrays, so the data is an array of
+// bytes, like the ones open(file, "b") and binary messages give, or a string.
+func (s *Socket) SendBinary(message goja.Value) {
 	rt := common.GetRuntime(s.ctx)
 
-	writeData := []byte(message)
-	if err := s.conn.WriteMessage(websocket.TextMessage, writeData); err != nil {
-		s.handleEvent("error", rt.ToValue(err))
+	var data []byte
+	switch v := message.Export().(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		if err := rt.ExportTo(message, &data); err != nil {
+			common.Throw(rt, fmt.Errorf("sendBinary expects an array of bytes or a string: %s", err))
+		}
+	}
+	s.write(websocket.BinaryMessage, data)
+}
+
+func (s *Socket) write(messageType int, data []byte) {
+	if err := s.conn.WriteMessage(messageType, data); err != nil {
+		s.handleEvent("error", common.GetRuntime(s.ctx).ToValue(err))
+		return
 	}
 
 	s.msgSentTimestamps = append(s.msgSentTimestamps, time.Now())
@@ -437,11 +538,11 @@ func (s *Socket) closeConnection(code int) error {
 }
 
 // Wraps conn.ReadMessage in a channel
-func readPump(conn *websocket.Conn, readChan chan []byte, errorChan chan error, closeChan chan int) {
+func readPump(conn *websocket.Conn, readChan chan message, errorChan chan error, closeChan chan int) {
 	defer func() { _ = conn.Close() }()
 
 	for {
-		_, message, err := conn.ReadMessage()
+		mtype, data, err := conn.ReadMessage()
 		if err != nil {
 
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
@@ -456,7 +557,7 @@ func readPump(conn *websocket.Conn, readChan chan []byte, errorChan chan error,
 			return
 		}
 
-		readChan <- message
+		readChan <- message{mtype, data}
 	}
 }
 
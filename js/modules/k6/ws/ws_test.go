@@ -24,8 +24,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -35,8 +37,10 @@ import (
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/netext"
 	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func assertSessionMetricsEmitted(t *testing.T, samples []stats.Sample, subprotocol, url string, status int, group string) {
@@ -451,3 +455,161 @@ func TestTLSConfig(t *testing.T) {
 	})
 	assertSessionMetricsEmitted(t, state.Samples, "", url, 101, "")
 }
+
+func TestProxy(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	proxy := testutils.NewHTTPProxy(tb.Dialer, "", "")
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	root, err := lib.NewGroup("", nil)
+	assert.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	state := &common.State{
+		Group:  root,
+		Dialer: tb.Dialer,
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("url", "proto", "status", "subproto"),
+			Proxy:      types.NullProxyFrom(proxyURL),
+		},
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("ws", common.Bind(rt, New(), &ctx))
+	rt.Set("PROXY_URL", proxy.URL)
+
+	connect := func(t *testing.T, params string) int64 {
+		before := atomic.LoadInt64(&proxy.Proxied)
+		_, err := common.RunString(rt, sr(`
+		var res = ws.connect("WSBIN_URL/ws-echo", `+params+`, function(socket) {
+			socket.on("open", function() { socket.send("test"); });
+			socket.on("message", function(data) { socket.close(); });
+		});
+		if (res.status != 101) { throw new Error("connection failed with status: " + res.status); }
+		`))
+		require.NoError(t, err)
+		return atomic.LoadInt64(&proxy.Proxied) - before
+	}
+
+	t.Run("option", func(t *testing.T) {
+		assert.Equal(t, int64(1), connect(t, "null"))
+	})
+	t.Run("params", func(t *testing.T) {
+		state.Options.Proxy = types.NullProxy{}
+		assert.Equal(t, int64(0), connect(t, "null"))
+		assert.Equal(t, int64(1), connect(t, "{ proxy: PROXY_URL }"))
+	})
+	t.Run("direct", func(t *testing.T) {
+		state.Options.Proxy = types.NullProxyFrom(proxyURL)
+		assert.Equal(t, int64(0), connect(t, `{ proxy: "" }`))
+	})
+	t.Run("https", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`ws.connect("WSBIN_URL/ws-echo", { proxy: "https://proxy.example.com" }, function(socket) {});`))
+		assert.EqualError(t, err, "GoError: websockets can't go through HTTPS proxies, only HTTP and SOCKS5 ones")
+	})
+}
+
+func TestBinaryAndCompression(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	root, err := lib.NewGroup("", nil)
+	assert.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	state := &common.State{
+		Group:  root,
+		Dialer: tb.Dialer,
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("url", "proto", "status", "subproto"),
+		},
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("ws", common.Bind(rt, New(), &ctx))
+
+	t.Run("binary", func(t *testing.T) {
+		state.Samples = nil
+		_, err := common.RunString(rt, sr(`
+		var received;
+		var res = ws.connect("WSBIN_URL/ws-echo", function(socket) {
+			socket.on("open", function() { socket.sendBinary([0, 1, 254, 255]); });
+			socket.on("message", function(data) { throw new Error("binary message received as text"); });
+			socket.on("binaryMessage", function(data) { received = data; socket.close(); });
+		});
+		if (res.status != 101) { throw new Error("connection failed with status: " + res.status); }
+		if (received.length !== 4 || received[0] !== 0 || received[2] !== 254 || received[3] !== 255) {
+			throw new Error("unexpected binary message: " + JSON.stringify(received));
+		}
+		`))
+		assert.NoError(t, err)
+		assertMetricEmitted(t, metrics.WSMessagesSent, state.Samples, sr("WSBIN_URL/ws-echo"))
+		assertMetricEmitted(t, metrics.WSMessagesReceived, state.Samples, sr("WSBIN_URL/ws-echo"))
+	})
+	t.Run("binary string", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		var received;
+		ws.connect("WSBIN_URL/ws-echo", function(socket) {
+			socket.on("open", function() { socket.sendBinary("k6"); });
+			socket.on("binaryMessage", function(data) { received = data; socket.close(); });
+		});
+		if (String.fromCharCode(received[0], received[1]) !== "k6") {
+			throw new Error("unexpected binary message: " + JSON.stringify(received));
+		}
+		`))
+		assert.NoError(t, err)
+	})
+	t.Run("invalid binary", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		ws.connect("WSBIN_URL/ws-close", function(socket) {
+			socket.on("open", function() { socket.sendBinary({ a: 1 }); });
+		});
+		`))
+		assert.Error(t, err)
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		var received;
+		var res = ws.connect("WSBIN_URL/ws-echo-compressed", { compression: "deflate" }, function(socket) {
+			socket.on("open", function() { socket.send("compressed"); });
+			socket.on("message", function(data) { received = data; socket.close(); });
+		});
+		if (res.headers["Sec-Websocket-Extensions"].indexOf("permessage-deflate") !== 0) {
+			throw new Error("compression wasn't negotiated: " + JSON.stringify(res.headers));
+		}
+		if (received !== "compressed") { throw new Error("unexpected message: " + received); }
+		`))
+		assert.NoError(t, err)
+	})
+	t.Run("no compression", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`
+		var res = ws.connect("WSBIN_URL/ws-echo-compressed", function(socket) {
+			socket.on("open", function() { socket.send("plain"); });
+			socket.on("message", function(data) { socket.close(); });
+		});
+		if (res.headers["Sec-Websocket-Extensions"] !== undefined) {
+			throw new Error("compression was negotiated: " + JSON.stringify(res.headers));
+		}
+		`))
+		assert.NoError(t, err)
+	})
+	t.Run("invalid compression", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`ws.connect("WSBIN_URL/ws-echo", { compression: "gzip" }, function(socket) {});`))
+		assert.EqualError(t, err, "GoError: unsupported compression algorithm 'gzip', only 'deflate' is")
+	})
+}
@@ -0,0 +1,323 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package xml
+
+import (
+	"bytes"
+	goxml "encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xpath"
+	"github.com/pkg/errors"
+)
+
+// A node is a node of a parsed XML document. Attributes are nodes too, so they can be selected.
+type node struct {
+	typ                   xpath.NodeType
+	prefix, local, nsURI  string
+	data                  string
+	attrs                 []*node
+	parent                *node
+	firstChild, lastChild *node
+	prev, next            *node
+}
+
+// name returns the qualified name of an element or attribute, eg. "soap:Body".
+func (n *node) name() string {
+	if n.prefix == "" {
+		return n.local
+	}
+	return n.prefix + ":" + n.local
+}
+
+// text returns the text content of the node, which for elements and documents is the text of
+// all their descendants.
+func (n *node) text() string {
+	switch n.typ {
+	case xpath.ElementNode, xpath.RootNode:
+		var b strings.Builder
+		var walk func(*node)
+		walk = func(n *node) {
+			for c := n.firstChild; c != nil; c = c.next {
+				switch c.typ {
+				case xpath.TextNode:
+					b.WriteString(c.data)
+				case xpath.ElementNode:
+					walk(c)
+				}
+			}
+		}
+		walk(n)
+		return b.String()
+	default:
+		return n.data
+	}
+}
+
+func (n *node) appendChild(c *node) {
+	c.parent = n
+	if n.lastChild == nil {
+		n.firstChild = c
+	} else {
+		n.lastChild.next = c
+		c.prev = n.lastChild
+	}
+	n.lastChild = c
+}
+
+// parse parses an XML document. Namespace declarations are returned in document order, since
+// they're also used to resolve the prefixes in XPath expressions.
+func parse(r io.Reader) (*node, map[string]string, error) {
+	doc := &node{typ: xpath.RootNode}
+	declared := make(map[string]string)
+
+	decoder := goxml.NewDecoder(r)
+	// Bodies have already been decoded to strings, so any declared encoding is taken as is.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	// Namespaces in scope, for every open element.
+	scopes := []map[string]string{{"xml": "http://www.w3.org/XML/1998/namespace"}}
+	resolve := func(prefix string) (string, bool) {
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if uri, ok := scopes[i][prefix]; ok {
+				return uri, true
+			}
+		}
+		return "", prefix == ""
+	}
+
+	parent := doc
+	for {
+		tok, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch tok := tok.(type) {
+		case goxml.StartElement:
+			scope := make(map[string]string)
+			for _, attr := range tok.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					scope[attr.Name.Local] = attr.Value
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					scope[""] = attr.Value
+				}
+			}
+			scopes = append(scopes, scope)
+			for prefix, uri := range scope {
+				if _, ok := declared[prefix]; !ok && prefix != "" {
+					declared[prefix] = uri
+				}
+			}
+
+			el := &node{typ: xpath.ElementNode, prefix: tok.Name.Space, local: tok.Name.Local}
+			uri, ok := resolve(el.prefix)
+			if !ok {
+				return nil, nil, errors.Errorf("undeclared namespace prefix '%s'", el.prefix)
+			}
+			el.nsURI = uri
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				a := &node{
+					typ:    xpath.AttributeNode,
+					prefix: attr.Name.Space,
+					local:  attr.Name.Local,
+					data:   attr.Value,
+					parent: el,
+				}
+				// Unprefixed attributes don't take the default namespace.
+				if a.prefix != "" {
+					if a.nsURI, ok = resolve(a.prefix); !ok {
+						return nil, nil, errors.Errorf("undeclared namespace prefix '%s'", a.prefix)
+					}
+				}
+				el.attrs = append(el.attrs, a)
+			}
+			parent.appendChild(el)
+			parent = el
+
+		case goxml.EndElement:
+			if parent.typ != xpath.ElementNode || parent.prefix != tok.Name.Space || parent.local != tok.Name.Local {
+				return nil, nil, errors.Errorf("unexpected end element </%s>", qualifiedName(tok.Name))
+			}
+			scopes = scopes[:len(scopes)-1]
+			parent = parent.parent
+
+		case goxml.CharData:
+			if parent == doc {
+				// Only whitespace is allowed outside of the root element.
+				if len(bytes.TrimSpace(tok)) > 0 {
+					return nil, nil, errors.New("text outside of the root element")
+				}
+				continue
+			}
+			if last := parent.lastChild; last != nil && last.typ == xpath.TextNode {
+				last.data += string(tok)
+			} else {
+				parent.appendChild(&node{typ: xpath.TextNode, data: string(tok)})
+			}
+
+		case goxml.Comment:
+			parent.appendChild(&node{typ: xpath.CommentNode, data: string(tok)})
+		}
+	}
+	if parent != doc {
+		return nil, nil, errors.Errorf("unclosed element <%s>", parent.name())
+	}
+	if doc.firstChild == nil {
+		return nil, nil, errors.New("no root element")
+	}
+	return doc, declared, nil
+}
+
+func qualifiedName(name goxml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// A navigator implements xpath.NodeNavigator over parsed documents.
+type navigator struct {
+	root, curr *node
+	// Index of the current attribute of curr, or -1.
+	attr int
+}
+
+func newNavigator(root, curr *node) *navigator {
+	if curr.typ == xpath.AttributeNode {
+		for i, a := range curr.parent.attrs {
+			if a == curr {
+				return &navigator{root: root, curr: curr.parent, attr: i}
+			}
+		}
+	}
+	return &navigator{root: root, curr: curr, attr: -1}
+}
+
+// current returns the node the navigator is on.
+func (nav *navigator) current() *node {
+	if nav.attr != -1 {
+		return nav.curr.attrs[nav.attr]
+	}
+	return nav.curr
+}
+
+func (nav *navigator) NodeType() xpath.NodeType {
+	return nav.current().typ
+}
+
+func (nav *navigator) LocalName() string {
+	return nav.current().local
+}
+
+func (nav *navigator) Prefix() string {
+	return nav.current().prefix
+}
+
+func (nav *navigator) NamespaceURL() string {
+	return nav.current().nsURI
+}
+
+func (nav *navigator) Value() string {
+	return nav.current().text()
+}
+
+func (nav *navigator) Copy() xpath.NodeNavigator {
+	n := *nav
+	return &n
+}
+
+func (nav *navigator) MoveToRoot() {
+	nav.curr = nav.root
+	nav.attr = -1
+}
+
+func (nav *navigator) MoveToParent() bool {
+	if nav.attr != -1 {
+		nav.attr = -1
+		return true
+	}
+	if nav.curr.parent == nil {
+		return false
+	}
+	nav.curr = nav.curr.parent
+	return true
+}
+
+func (nav *navigator) MoveToNextAttribute() bool {
+	if nav.attr >= len(nav.curr.attrs)-1 {
+		return false
+	}
+	nav.attr++
+	return true
+}
+
+func (nav *navigator) MoveToChild() bool {
+	if nav.attr != -1 || nav.curr.firstChild == nil {
+		return false
+	}
+	nav.curr = nav.curr.firstChild
+	return true
+}
+
+func (nav *navigator) MoveToFirst() bool {
+	if nav.attr != -1 || nav.curr.parent == nil {
+		return false
+	}
+	nav.curr = nav.curr.parent.firstChild
+	return true
+}
+
+func (nav *navigator) MoveToNext() bool {
+	if nav.attr != -1 || nav.curr.next == nil {
+		return false
+	}
+	nav.curr = nav.curr.next
+	return true
+}
+
+func (nav *navigator) MoveToPrevious() bool {
+	if nav.attr != -1 || nav.curr.prev == nil {
+		return false
+	}
+	nav.curr = nav.curr.prev
+	return true
+}
+
+func (nav *navigator) MoveTo(other xpath.NodeNavigator) bool {
+	o, ok := other.(*navigator)
+	if !ok || o.root != nav.root {
+		return false
+	}
+	nav.curr = o.curr
+	nav.attr = o.attr
+	return true
+}
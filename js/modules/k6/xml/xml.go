@@ -0,0 +1,201 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package xml
+
+import (
+	"context"
+	"strings"
+
+	"github.com/antchfx/xpath"
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+type XML struct{}
+
+func New() *XML {
+	return &XML{}
+}
+
+// ParseXML parses an XML document. Namespace prefixes in XPath expressions resolve to the
+// namespaces declared in the document, which can be overridden with the namespaces option.
+func (XML) ParseXML(ctx context.Context, src string, opts ...goja.Value) (Selection, error) {
+	rt := common.GetRuntime(ctx)
+
+	doc, namespaces, err := parse(strings.NewReader(src))
+	if err != nil {
+		return Selection{}, err
+	}
+	if len(opts) > 0 && !goja.IsUndefined(opts[0]) && !goja.IsNull(opts[0]) {
+		params := opts[0].ToObject(rt)
+		for _, k := range params.Keys() {
+			switch k {
+			case "namespaces":
+				nsV := params.Get(k)
+				if goja.IsUndefined(nsV) || goja.IsNull(nsV) {
+					continue
+				}
+				ns := nsV.ToObject(rt)
+				for _, prefix := range ns.Keys() {
+					namespaces[prefix] = ns.Get(prefix).String()
+				}
+			}
+		}
+	}
+	return Selection{rt: rt, root: doc, nodes: []*node{doc}, namespaces: namespaces}, nil
+}
+
+// A Selection is a set of nodes of an XML document, in document order.
+type Selection struct {
+	rt         *goja.Runtime
+	root       *node
+	nodes      []*node
+	namespaces map[string]string
+}
+
+func (s Selection) withNodes(nodes []*node) Selection {
+	return Selection{rt: s.rt, root: s.root, nodes: nodes, namespaces: s.namespaces}
+}
+
+func (s Selection) compile(expr string) *xpath.Expr {
+	compiled, err := xpath.CompileWithNS(expr, s.namespaces)
+	if err != nil {
+		common.Throw(s.rt, errors.Wrapf(err, "invalid XPath expression '%s'", expr))
+	}
+	return compiled
+}
+
+// Find returns the nodes matching an XPath expression, evaluated against every node in the
+// selection. Relative expressions select from the nodes, eg. find("item/@id").
+func (s Selection) Find(expr string) Selection {
+	compiled := s.compile(expr)
+
+	var nodes []*node
+	seen := make(map[*node]bool)
+	for _, n := range s.nodes {
+		iter := compiled.Select(newNavigator(s.root, n))
+		for iter.MoveNext() {
+			found := iter.Current().(*navigator).current()
+			if !seen[found] {
+				seen[found] = true
+				nodes = append(nodes, found)
+			}
+		}
+	}
+	return s.withNodes(nodes)
+}
+
+// Evaluate evaluates an XPath expression against the first node in the selection, and returns
+// its result: a number, string or boolean, eg. for count(//item), or a selection for node sets.
+func (s Selection) Evaluate(expr string) goja.Value {
+	if len(s.nodes) == 0 {
+		return goja.Undefined()
+	}
+	compiled := s.compile(expr)
+
+	switch v := compiled.Evaluate(newNavigator(s.root, s.nodes[0])).(type) {
+	case *xpath.NodeIterator:
+		var nodes []*node
+		for v.MoveNext() {
+			nodes = append(nodes, v.Current().(*navigator).current())
+		}
+		return s.rt.ToValue(s.withNodes(nodes))
+	default:
+		return s.rt.ToValue(v)
+	}
+}
+
+func (s Selection) Size() int {
+	return len(s.nodes)
+}
+
+func (s Selection) Eq(idx int) Selection {
+	if idx < 0 {
+		idx += len(s.nodes)
+	}
+	if idx < 0 || idx >= len(s.nodes) {
+		return s.withNodes(nil)
+	}
+	return s.withNodes(s.nodes[idx : idx+1])
+}
+
+func (s Selection) First() Selection {
+	return s.Eq(0)
+}
+
+func (s Selection) Last() Selection {
+	return s.Eq(-1)
+}
+
+// Text returns the combined text of the nodes in the selection.
+func (s Selection) Text() string {
+	var b strings.Builder
+	for _, n := range s.nodes {
+		b.WriteString(n.text())
+	}
+	return b.String()
+}
+
+// Name returns the qualified name of the first node in the selection, eg. "soap:Body".
+func (s Selection) Name() goja.Value {
+	if len(s.nodes) == 0 || s.nodes[0].local == "" {
+		return goja.Undefined()
+	}
+	return s.rt.ToValue(s.nodes[0].name())
+}
+
+// Attr returns an attribute of the first element in the selection, by its qualified name.
+func (s Selection) Attr(name string, def ...goja.Value) goja.Value {
+	if len(s.nodes) > 0 {
+		for _, a := range s.nodes[0].attrs {
+			if a.name() == name {
+				return s.rt.ToValue(a.data)
+			}
+		}
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return goja.Undefined()
+}
+
+func (s Selection) Each(v goja.Value) Selection {
+	gojaFn, isFn := goja.AssertFunction(v)
+	if !isFn {
+		common.Throw(s.rt, errors.New("Argument to each() must be a function."))
+	}
+
+	for i := range s.nodes {
+		if _, err := gojaFn(v, s.rt.ToValue(i), s.rt.ToValue(s.Eq(i))); err != nil {
+			common.Throw(s.rt, errors.Wrap(err, "Function passed to each() failed."))
+		}
+	}
+	return s
+}
+
+func (s Selection) ToArray() []Selection {
+	items := make([]Selection, len(s.nodes))
+	for i := range s.nodes {
+		items[i] = s.Eq(i)
+	}
+	return items
+}
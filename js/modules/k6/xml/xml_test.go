@@ -0,0 +1,126 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package xml
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+)
+
+const testXML = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<m:GetItemsResponse xmlns:m="http://example.com/items">
+			<!-- the items -->
+			<m:Item id="1" m:status="active">First<![CDATA[ <item> ]]></m:Item>
+			<m:Item id="2">Second</m:Item>
+			<m:Total>2</m:Total>
+		</m:GetItemsResponse>
+	</soap:Body>
+</soap:Envelope>`
+
+func TestParseXML(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("src", testXML)
+	rt.Set("xml", common.Bind(rt, New(), &ctx))
+
+	_, err := common.RunString(rt, `let doc = xml.parseXML(src)`)
+	assert.NoError(t, err)
+	assert.IsType(t, Selection{}, rt.Get("doc").Export())
+
+	t.Run("Find", func(t *testing.T) {
+		v, err := common.RunString(rt, `doc.find("//m:Item")`)
+		if assert.NoError(t, err) && assert.IsType(t, Selection{}, v.Export()) {
+			sel := v.Export().(Selection)
+			assert.Equal(t, 2, sel.Size())
+			assert.Equal(t, "First <item> Second", sel.Text())
+		}
+	})
+	t.Run("Relative", func(t *testing.T) {
+		v, err := common.RunString(rt, `doc.find("//m:GetItemsResponse").find("m:Item/@id").toArray().map(function(a) { return a.text(); }).join(",")`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "1,2", v.Export())
+		}
+	})
+	t.Run("Predicate", func(t *testing.T) {
+		v, err := common.RunString(rt, `doc.find("//m:Item[@id='2']").text()`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Second", v.Export())
+		}
+	})
+	t.Run("Attr", func(t *testing.T) {
+		v, err := common.RunString(rt, `let item = doc.find("//m:Item").first(); [item.attr("id"), item.attr("m:status"), item.attr("missing"), item.attr("missing", "def")]`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{"1", "active", nil, "def"}, v.Export())
+		}
+	})
+	t.Run("Name", func(t *testing.T) {
+		v, err := common.RunString(rt, `[doc.find("/*").name(), doc.find("//m:Item").last().name(), doc.name()]`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{"soap:Envelope", "m:Item", nil}, v.Export())
+		}
+	})
+	t.Run("Eq", func(t *testing.T) {
+		v, err := common.RunString(rt, `[doc.find("//m:Item").eq(1).text(), doc.find("//m:Item").eq(2).size()]`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{"Second", int64(0)}, v.Export())
+		}
+	})
+	t.Run("Each", func(t *testing.T) {
+		v, err := common.RunString(rt, `let ids = []; doc.find("//m:Item").each(function(i, s) { ids.push(i + ":" + s.attr("id")); }); ids`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{"0:1", "1:2"}, v.Export())
+		}
+	})
+	t.Run("Evaluate", func(t *testing.T) {
+		v, err := common.RunString(rt, `[doc.evaluate("count(//m:Item)"), doc.evaluate("sum(//m:Total) = 2"), doc.evaluate("string(//m:Item[2])"), doc.evaluate("//m:Total").text()]`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{int64(2), true, "Second", "2"}, v.Export())
+		}
+	})
+	t.Run("Namespaces", func(t *testing.T) {
+		v, err := common.RunString(rt, `xml.parseXML(src, { namespaces: { i: "http://example.com/items" } }).find("//i:Item").size()`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(2), v.Export())
+		}
+
+		_, err = common.RunString(rt, `doc.find("//x:Item")`)
+		assert.EqualError(t, err, "GoError: invalid XPath expression '//x:Item': prefix x not defined.")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `xml.parseXML("<a><b></a>")`)
+		assert.EqualError(t, err, "GoError: unexpected end element </a>")
+		_, err = common.RunString(rt, `xml.parseXML("<a>")`)
+		assert.EqualError(t, err, "GoError: unclosed element <a>")
+		_, err = common.RunString(rt, `xml.parseXML("{}")`)
+		assert.EqualError(t, err, "GoError: text outside of the root element")
+		_, err = common.RunString(rt, `xml.parseXML("")`)
+		assert.EqualError(t, err, "GoError: no root element")
+		_, err = common.RunString(rt, `xml.parseXML("<x:a/>")`)
+		assert.EqualError(t, err, "GoError: undeclared namespace prefix 'x'")
+	})
+}
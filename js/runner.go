@@ -24,10 +24,12 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"io"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -41,7 +43,6 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
-	"github.com/viki-org/dnscache"
 	"golang.org/x/net/http2"
 	"golang.org/x/time/rate"
 )
@@ -53,11 +54,23 @@ type Runner struct {
 	Logger       *log.Logger
 	defaultGroup *lib.Group
 
-	BaseDialer net.Dialer
-	Resolver   *dnscache.Resolver
-	RPSLimit   *rate.Limiter
+	BaseDialer  net.Dialer
+	Resolver    netext.Resolver
+	RPSLimit    *rate.Limiter
+	HostLimiter *netext.HostLimiter
+
+	// Shared by the VUs' TLS configs if the preconnect option has them reuse TLS sessions.
+	tlsSessionCache tls.ClientSessionCache
+
+	// Wraps the HTTP requests of every VU, for Go code embedding the runner; set it before
+	// making VUs.
+	Middleware []netext.Middleware
 
 	setupData interface{}
+
+	// What the setup functions of scenarios returned, by scenario name.
+	scenarioData     map[string]interface{}
+	scenarioDataLock sync.RWMutex
 }
 
 func New(src *lib.SourceData, fs afero.Fs, rtOpts lib.RuntimeOptions) (*Runner, error) {
@@ -91,7 +104,6 @@ func NewFromBundle(b *Bundle) (*Runner, error) {
 			KeepAlive: 30 * time.Second,
 			DualStack: true,
 		},
-		Resolver: dnscache.New(0),
 	}
 	r.SetOptions(r.Bundle.Options)
 	return r, nil
@@ -106,6 +118,9 @@ func (r *Runner) NewVU() (lib.VU, error) {
 	if err != nil {
 		return nil, err
 	}
+	if r.Bundle.Options.Preconnect.Enabled() {
+		vu.preconnect()
+	}
 	return lib.VU(vu), nil
 }
 
@@ -145,6 +160,8 @@ func (r *Runner) newVU() (*VU, error) {
 		Resolver:  r.Resolver,
 		Blacklist: r.Bundle.Options.BlacklistIPs,
 		Hosts:     r.Bundle.Options.Hosts,
+
+		BlockedHostnames: r.Bundle.Options.BlockHostnames,
 	}
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: r.Bundle.Options.InsecureSkipTLSVerify.Bool,
@@ -154,30 +171,36 @@ func (r *Runner) newVU() (*VU, error) {
 		Certificates:       certs,
 		NameToCertificate:  nameToCert,
 		Renegotiation:      tls.RenegotiateFreelyAsClient,
+		ClientSessionCache: r.tlsSessionCache,
 	}
 	transport := &http.Transport{
-		Proxy:              http.ProxyFromEnvironment,
+		Proxy:              dialer.ProxyFunc(r.Bundle.Options.Proxy.ProxyFunc()),
 		TLSClientConfig:    tlsConfig,
 		DialContext:        dialer.DialContext,
 		DisableCompression: true,
 	}
+	// Keep all of the preconnected connections, not just as many as are kept by default.
+	if n := r.Bundle.Options.Preconnect.ConnectionsPerURL(); n > http.DefaultMaxIdleConnsPerHost {
+		transport.MaxIdleConnsPerHost = n
+	}
 	_ = http2.ConfigureTransport(transport)
+	httpTransport := netext.NewHTTPTransport(transport)
+	httpTransport.HTTPVersion = r.Bundle.Options.HTTPVersion.Version
+	httpTransport.DialQUIC = dialer.DialQUIC
+	httpTransport.CloseQUIC = dialer.CloseQUIC
+	httpTransport.HostLimiter = r.HostLimiter
+	httpTransport.Middleware = r.Middleware
 
 	vu := &VU{
 		BundleInstance: *bi,
 		Runner:         r,
-		HTTPTransport:  netext.NewHTTPTransport(transport),
+		HTTPTransport:  httpTransport,
 		Dialer:         dialer,
 		TLSConfig:      tlsConfig,
 		Console:        NewConsole(),
 		BPool:          bpool.NewBufferPool(100),
 	}
-	vu.Runtime.Set("console", common.Bind(vu.Runtime, vu.Console, vu.Context))
-	common.BindToGlobal(vu.Runtime, map[string]interface{}{
-		"open": func() {
-			common.Throw(vu.Runtime, errors.New("\"open\" function is only available to the init code (aka global scope), see https://docs.k6.io/docs/test-life-cycle for more information"))
-		},
-	})
+	vu.bindGlobals()
 
 	// Give the VU an initial sense of identity.
 	if err := vu.Reconfigure(0); err != nil {
@@ -188,6 +211,9 @@ func (r *Runner) newVU() (*VU, error) {
 }
 
 func (r *Runner) Setup(ctx context.Context) error {
+	if sc := lib.GetScenario(ctx); sc != nil {
+		return r.setupScenario(ctx, sc)
+	}
 	v, err := r.runPart(ctx, "setup", nil)
 	if err != nil {
 		return errors.Wrap(err, "setup")
@@ -200,10 +226,101 @@ func (r *Runner) Setup(ctx context.Context) error {
 }
 
 func (r *Runner) Teardown(ctx context.Context) error {
+	if sc := lib.GetScenario(ctx); sc != nil {
+		if !sc.Teardown.Valid {
+			return nil
+		}
+		_, err := r.runScenarioPart(ctx, sc, "teardown", sc.Teardown.String, r.getScenarioData(sc))
+		return errors.Wrapf(err, "scenario %s: teardown", sc.Name)
+	}
 	_, err := r.runPart(ctx, "teardown", r.setupData)
 	return err
 }
 
+// setupScenario runs a scenario's own setup function, if it has one, and keeps what it returns
+// for the scenario's iterations and teardown.
+func (r *Runner) setupScenario(ctx context.Context, sc *lib.ScenarioState) error {
+	if !sc.Setup.Valid {
+		return nil
+	}
+	v, err := r.runScenarioPart(ctx, sc, "setup", sc.Setup.String, r.setupData)
+	if err != nil {
+		return errors.Wrapf(err, "scenario %s: setup", sc.Name)
+	}
+	data, err := json.Marshal(v.Export())
+	if err != nil {
+		return errors.Wrapf(err, "scenario %s: setup", sc.Name)
+	}
+	var scData interface{}
+	if err := json.Unmarshal(data, &scData); err != nil {
+		return errors.Wrapf(err, "scenario %s: setup", sc.Name)
+	}
+
+	r.scenarioDataLock.Lock()
+	defer r.scenarioDataLock.Unlock()
+	if r.scenarioData == nil {
+		r.scenarioData = make(map[string]interface{})
+	}
+	r.scenarioData[sc.Name] = scData
+	return nil
+}
+
+// getScenarioData returns the data a scenario's iterations are passed: what its own setup
+// returned, if it has one, or the test's setup data.
+func (r *Runner) getScenarioData(sc *lib.ScenarioState) interface{} {
+	if !sc.Setup.Valid {
+		return r.setupData
+	}
+	r.scenarioDataLock.RLock()
+	defer r.scenarioDataLock.RUnlock()
+	return r.scenarioData[sc.Name]
+}
+
+func (r *Runner) GetSetupData() json.RawMessage {
+	if r.setupData == nil {
+		return nil
+	}
+	data, err := json.Marshal(r.setupData)
+	if err != nil {
+		// It was unmarshaled from JSON to begin with.
+		panic(err)
+	}
+	return data
+}
+
+func (r *Runner) SetSetupData(data json.RawMessage) error {
+	r.setupData = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(data, &r.setupData), "setup data")
+}
+
+func (r *Runner) HandleSummary(ctx context.Context, summary map[string]interface{}) (map[string]io.Reader, error) {
+	vu, fn, err := r.getPart("handleSummary")
+	if err != nil || fn == nil {
+		return nil, errors.Wrap(err, "handleSummary")
+	}
+	v, err := vu.runPart(ctx, fn, summary)
+	if err != nil {
+		return nil, errors.Wrap(err, "handleSummary")
+	}
+
+	files := make(map[string]io.Reader)
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return files, nil
+	}
+	obj := v.ToObject(vu.Runtime)
+	for _, name := range obj.Keys() {
+		content, ok := obj.Get(name).Export().(string)
+		if !ok {
+			return nil, errors.Errorf("handleSummary: the content of '%s' must be a string", name)
+		}
+		files[name] = strings.NewReader(content)
+	}
+	return files, nil
+}
+
 func (r *Runner) GetDefaultGroup() *lib.Group {
 	return r.defaultGroup
 }
@@ -215,36 +332,67 @@ func (r *Runner) GetOptions() lib.Options {
 func (r *Runner) SetOptions(opts lib.Options) {
 	r.Bundle.Options = opts
 
+	ttl, sel, policy := opts.DNS.Settings()
+	r.Resolver = netext.NewResolver(net.LookupIP, ttl, sel, policy)
+
+	r.HostLimiter = nil
+	if opts.HostLimits != nil {
+		r.HostLimiter = netext.NewHostLimiter(opts.HostLimits.Limit)
+	}
+
 	r.RPSLimit = nil
 	if rps := opts.RPS; rps.Valid {
 		r.RPSLimit = rate.NewLimiter(rate.Limit(rps.Int64), 1)
 	}
+
+	r.tlsSessionCache = nil
+	if opts.Preconnect.ReuseTLSSessions.Bool {
+		r.tlsSessionCache = tls.NewLRUClientSessionCache(0)
+	}
 }
 
 // Runs an exported function in its own temporary VU, optionally with an argument. Execution is
 // interrupted if the context expires. No error is returned if the part does not exist.
 func (r *Runner) runPart(ctx context.Context, name string, arg interface{}) (goja.Value, error) {
+	vu, fn, err := r.getPart(name)
+	if err != nil || fn == nil {
+		return goja.Undefined(), err
+	}
+	return vu.runPart(ctx, fn, arg)
+}
+
+// runScenarioPart runs a scenario's setup or teardown function in a temporary VU, like runPart
+// does with the test's.
+func (r *Runner) runScenarioPart(
+	ctx context.Context, sc *lib.ScenarioState, option, name string, arg interface{},
+) (goja.Value, error) {
 	vu, err := r.newVU()
 	if err != nil {
 		return goja.Undefined(), err
 	}
+	fn, ok := getScenarioFunc(vu.Runtime, sc.Name, option, name)
+	if !ok {
+		return goja.Undefined(), nil
+	}
+	return vu.runPart(ctx, fn, arg)
+}
+
+// Returns an exported function along with a temporary VU to run it in, or a nil function if the
+// script doesn't export it.
+func (r *Runner) getPart(name string) (*VU, goja.Callable, error) {
+	vu, err := r.newVU()
+	if err != nil {
+		return nil, nil, err
+	}
 	exp := vu.Runtime.Get("exports").ToObject(vu.Runtime)
 	if exp == nil {
-		return goja.Undefined(), nil
+		return vu, nil, nil
 	}
 	fn, ok := goja.AssertFunction(exp.Get(name))
 	if !ok {
-		return goja.Undefined(), nil
+		return vu, nil, nil
 	}
-
-	ctx, cancel := context.WithCancel(ctx)
-	go func() {
-		<-ctx.Done()
-		vu.Runtime.Interrupt(errInterrupt)
-	}()
-	v, _, err := vu.runFn(ctx, fn, vu.Runtime.ToValue(arg))
-	cancel()
-	return v, err
+	return vu, fn, nil
 }
 
 type VU struct {
@@ -257,11 +405,30 @@ type VU struct {
 	ID            int64
 	Iteration     int64
 
+	// Client profile simulated by the VU, if any, and the one its TLS settings are those of.
+	Profile    *lib.ClientProfile
+	tlsProfile *lib.ClientProfile
+
+	// Geo region the VU is assigned to, if any, and the headers it sends as a result.
+	Region        string
+	RegionHeaders map[string]string
+
 	Console *Console
 	BPool   *bpool.BufferPool
 
 	setupData goja.Value
 
+	// JS-ified setup data of the scenarios with their own setup, by name.
+	scenarioData map[string]goja.Value
+
+	// Functions scenarios run instead of the default one, by scenario name, and the scenario
+	// whose environment __ENV has, if it has its own.
+	execFns     map[string]goja.Callable
+	envScenario *lib.ScenarioState
+
+	// Whether an iteration has run on the VU's instance of the script.
+	dirty bool
+
 	// A VU will track the last context it was called with for cancellation.
 	// Note that interruptTrackedCtx is the context that is currently being tracked, while
 	// interruptCancel cancels an unrelated context that terminates the tracking goroutine
@@ -273,14 +440,128 @@ type VU struct {
 	interruptCancel     context.CancelFunc
 }
 
+// bindGlobals sets up the globals VU code has, on top of the init context's.
+func (u *VU) bindGlobals() {
+	u.Runtime.Set("console", common.Bind(u.Runtime, u.Console, u.Context))
+	common.BindToGlobal(u.Runtime, map[string]interface{}{
+		"open": func() {
+			common.Throw(u.Runtime, errors.New("\"open\" function is only available to the init code (aka global scope), see https://docs.k6.io/docs/test-life-cycle for more information"))
+		},
+		"fetchData": func() {
+			common.Throw(u.Runtime, errors.New("\"fetchData\" function is only available to the init code (aka global scope), use k6/http in VU code, see https://docs.k6.io/docs/test-life-cycle for more information"))
+		},
+	})
+}
+
+// resetState gives the VU a fresh instance of the script, as if it had just been started, and
+// closes its connections; nothing an iteration did is left for the next one to see.
+func (u *VU) resetState() error {
+	bi, err := u.Runner.Bundle.Instantiate()
+	if err != nil {
+		return err
+	}
+	if u.interruptCancel != nil {
+		u.interruptCancel()
+	}
+	u.interruptTrackedCtx, u.interruptCancel = nil, nil
+
+	u.BundleInstance = *bi
+	u.setupData, u.scenarioData, u.execFns, u.envScenario = nil, nil, nil, nil
+	u.bindGlobals()
+	u.Runtime.Set("__VU", u.ID)
+	u.seedRandom()
+	u.HTTPTransport.CloseIdleConnections()
+	return nil
+}
+
 func (u *VU) Reconfigure(id int64) error {
 	u.ID = id
 	u.Iteration = 0
 	u.Runtime.Set("__VU", u.ID)
+
+	opts := u.Runner.Bundle.Options
+	u.Profile = opts.ClientProfiles.Pick(id)
+	tlsChanged := u.applyTLSProfile(u.Profile)
+
+	u.Region, u.RegionHeaders, u.Dialer.Latency = "", nil, 0
+	if name, region := opts.GeoRegions.Pick(id); region != nil {
+		u.Region = name
+		u.RegionHeaders = make(map[string]string, len(region.Headers)+1)
+		for k, v := range region.Headers {
+			u.RegionHeaders[k] = v
+		}
+		if ip := region.ForwardedFor.Pick(id); ip != nil {
+			u.RegionHeaders["X-Forwarded-For"] = ip.String()
+		}
+		u.Dialer.Latency = time.Duration(region.Latency)
+	}
+	u.seedRandom()
+
+	// Connections preconnected with another profile's TLS settings were just closed. VU 0 is
+	// one that's still being created, which NewVU preconnects itself.
+	if tlsChanged && opts.Preconnect.Enabled() && id != 0 {
+		u.preconnect()
+	}
 	return nil
 }
 
+// applyTLSProfile gives the VU's TLS config the cipher suites and curves of a client profile, or
+// the defaults if there's none. Idle connections made with other settings are closed. Explicitly
+// set tlsCipherSuites always win over a profile's. It reports whether anything changed.
+func (u *VU) applyTLSProfile(p *lib.ClientProfile) bool {
+	if p == u.tlsProfile {
+		return false
+	}
+	u.tlsProfile = p
+
+	opts := u.Runner.Bundle.Options
+	u.TLSConfig.CipherSuites, u.TLSConfig.CurvePreferences = nil, nil
+	if opts.TLSCipherSuites != nil {
+		u.TLSConfig.CipherSuites = *opts.TLSCipherSuites
+	} else if p != nil {
+		u.TLSConfig.CipherSuites = p.CipherSuites
+		u.TLSConfig.CurvePreferences = p.CurvePreferences
+	}
+	u.HTTPTransport.CloseIdleConnections()
+	return true
+}
+
+// seedRandom seeds the VU's Math.random if the seed option is set. Every VU, and every fresh
+// instance of the script a VU starts an iteration with, gets a sequence of its own.
+func (u *VU) seedRandom() {
+	if seed := u.Runner.Bundle.Options.Seed; seed.Valid {
+		u.Runtime.SetRandSource(common.NewSeededRandSource(seed.Int64, u.ID, u.Iteration))
+	}
+}
+
+// preconnectTimeout is how long a VU tries to open its connections before the test starts.
+const preconnectTimeout = 30 * time.Second
+
+// preconnect opens the connections the preconnect option asks for, so the VU's first requests
+// don't have to. Failing to isn't fatal, the requests connect themselves then.
+func (u *VU) preconnect() {
+	opts := u.Runner.Bundle.Options.Preconnect
+	ctx, cancel := context.WithTimeout(context.Background(), preconnectTimeout)
+	defer cancel()
+	if err := u.HTTPTransport.Preconnect(ctx, opts.URLs, opts.ConnectionsPerURL()); err != nil {
+		u.Runner.Logger.WithError(err).Warn("Couldn't preconnect")
+	}
+
+	// The traffic isn't part of the test, so it isn't counted in the first iteration's.
+	atomic.StoreInt64(&u.Dialer.BytesWritten, 0)
+	atomic.StoreInt64(&u.Dialer.BytesRead, 0)
+}
+
 func (u *VU) RunOnce(ctx context.Context) ([]stats.Sample, error) {
+	// Scenarios with fresh VU state start every iteration but the VU's first from scratch.
+	sc := lib.GetScenario(ctx)
+	if sc != nil && sc.FreshVUState.Bool && u.dirty {
+		if err := u.resetState(); err != nil {
+			return nil, err
+		}
+	}
+	u.dirty = true
+
 	// Track the context and interrupt JS execution if it's cancelled.
 	if u.interruptTrackedCtx != ctx {
 		interCtx, interCancel := context.WithCancel(context.Background())
@@ -294,6 +575,8 @@ func (u *VU) RunOnce(ctx context.Context) ([]stats.Sample, error) {
 			case <-interCtx.Done():
 			case <-ctx.Done():
 				u.Runtime.Interrupt(errInterrupt)
+				// The VU is stopped, so its connections, and the socket of HTTP/3 ones, aren't needed.
+				_ = u.HTTPTransport.Close()
 			}
 		}()
 	}
@@ -306,16 +589,100 @@ func (u *VU) RunOnce(ctx context.Context) ([]stats.Sample, error) {
 		u.setupData = u.Runtime.ToValue(u.Runner.setupData)
 	}
 
-	// Call the default function.
-	_, state, err := u.runFn(ctx, u.Default, u.setupData)
+	// Call the default function, or the one the scenario runs.
+	fn := u.Default
+	if sc != nil {
+		var err error
+		if fn, err = u.getExec(sc); err != nil {
+			return nil, err
+		}
+	} else if fn == nil {
+		return nil, errors.New("script doesn't export a default function")
+	}
+	u.setEnv(sc)
+
+	_, state, err := u.runFn(ctx, fn, u.getSetupData(sc))
 	if err != nil {
 		return nil, err
 	}
 	return state.Samples, nil
 }
 
+// getSetupData returns the data an iteration of a scenario is passed, JS-ifying it the first
+// time it's needed, like the test's setup data.
+func (u *VU) getSetupData(sc *lib.ScenarioState) goja.Value {
+	if sc == nil || !sc.Setup.Valid {
+		return u.setupData
+	}
+	if v, ok := u.scenarioData[sc.Name]; ok {
+		return v
+	}
+	v := u.Runtime.ToValue(u.Runner.getScenarioData(sc))
+	if u.scenarioData == nil {
+		u.scenarioData = make(map[string]goja.Value)
+	}
+	u.scenarioData[sc.Name] = v
+	return v
+}
+
+// getExec returns the exported function a scenario's iterations run.
+func (u *VU) getExec(sc *lib.ScenarioState) (goja.Callable, error) {
+	name := sc.GetExec()
+	if name == "default" && u.Default != nil {
+		return u.Default, nil
+	}
+	if fn, ok := u.execFns[sc.Name]; ok {
+		return fn, nil
+	}
+	fn, ok := getScenarioFunc(u.Runtime, sc.Name, "exec", name)
+	if !ok {
+		return nil, errors.Errorf("scenario %s: exported function '%s' not found", sc.Name, name)
+	}
+	if u.execFns == nil {
+		u.execFns = make(map[string]goja.Callable)
+	}
+	u.execFns[sc.Name] = fn
+	return fn, nil
+}
+
+// setEnv makes __ENV the test's environment with the scenario's on top, if it has one.
+func (u *VU) setEnv(sc *lib.ScenarioState) {
+	if sc != nil && len(sc.Env) == 0 {
+		sc = nil
+	}
+	if sc == u.envScenario {
+		return
+	}
+	u.envScenario = sc
+	if sc == nil {
+		u.Runtime.Set("__ENV", u.Runner.Bundle.Env)
+		return
+	}
+	env := make(map[string]string, len(u.Runner.Bundle.Env)+len(sc.Env))
+	for k, v := range u.Runner.Bundle.Env {
+		env[k] = v
+	}
+	for k, v := range sc.Env {
+		env[k] = v
+	}
+	u.Runtime.Set("__ENV", env)
+}
+
+// Runs a function outside of an iteration, optionally with an argument, interrupting it if the
+// context expires.
+func (u *VU) runPart(ctx context.Context, fn goja.Callable, arg interface{}) (goja.Value, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		<-ctx.Done()
+		u.Runtime.Interrupt(errInterrupt)
+	}()
+	v, _, err := u.runFn(ctx, fn, u.Runtime.ToValue(arg))
+	cancel()
+	return v, err
+}
+
 func (u *VU) runFn(ctx context.Context, fn goja.Callable, args ...goja.Value) (goja.Value, *common.State, error) {
-	cookieJar, err := cookiejar.New(nil)
+	cookieJar, err := netext.NewCookieJar()
 	if err != nil {
 		return goja.Undefined(), nil, err
 	}
@@ -330,12 +697,41 @@ func (u *VU) runFn(ctx context.Context, fn goja.Callable, args ...goja.Value) (g
 		CookieJar:     cookieJar,
 		RPSLimit:      u.Runner.RPSLimit,
 		BPool:         u.BPool,
+		Profile:       u.Profile,
+		Headers:       u.RegionHeaders,
 		Vu:            u.ID,
 		Iteration:     u.Iteration,
+
+		ResponseCallback: u.ResponseCallback,
+		HTTPHooks:        u.HTTPHooks,
+		VUTags:           u.VUTags,
+		Store:            u.Store,
+		Secrets:          u.Runner.Bundle.Secrets,
 	}
 
+	if u.Region != "" {
+		state.Tags = map[string]string{"region": u.Region}
+	}
+	if sc := lib.GetScenario(ctx); sc != nil {
+		if state.Tags == nil {
+			state.Tags = make(map[string]string, len(sc.Tags)+1)
+		}
+		if state.Options.SystemTags["scenario"] {
+			state.Tags["scenario"] = sc.Name
+		}
+		for k, v := range sc.Tags {
+			state.Tags[k] = v
+		}
+		if sc.ClientProfile.Valid {
+			state.Profile = lib.ClientProfiles[sc.ClientProfile.String]
+		}
+	}
+	u.applyTLSProfile(state.Profile)
+
+	rejections := &common.Rejections{}
 	newctx := common.WithRuntime(ctx, u.Runtime)
 	newctx = common.WithState(newctx, state)
+	newctx = common.WithRejections(newctx, rejections)
 	*u.Context = newctx
 
 	u.Runtime.Set("__ITER", u.Iteration)
@@ -344,9 +740,13 @@ func (u *VU) runFn(ctx context.Context, fn goja.Callable, args ...goja.Value) (g
 
 	startTime := time.Now()
 	v, err := fn(goja.Undefined(), args...) // Actually run the JS script
+	if err == nil {
+		err = rejections.Err()
+	}
 	endTime := time.Now()
+	u.Store.EndIteration()
 
-	tags := state.Options.RunTags.CloneTags()
+	tags := state.CloneTags()
 	if state.Options.SystemTags["vu"] {
 		tags["vu"] = strconv.FormatInt(u.ID, 10)
 	}
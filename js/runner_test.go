@@ -29,6 +29,9 @@ import (
 	stdlog "log"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -36,10 +39,12 @@ import (
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -167,6 +172,191 @@ func TestSetupTeardown(t *testing.T) {
 	}
 }
 
+func TestScenarioSetupTeardown(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			exports.setup = function() { return { token: "test" }; };
+			exports.apiSetup = function(data) { return { token: data.token + "-api" }; };
+			exports.apiTeardown = function(data) {
+				if (data.token !== "test-api") { throw new Error("apiTeardown: wrong data: " + JSON.stringify(data)); }
+			};
+			exports.default = function(data) {
+				if (data.token !== "test") { throw new Error("default: wrong data: " + JSON.stringify(data)); }
+			};
+			exports.api = function(data) {
+				if (data.token !== "test-api") { throw new Error("api: wrong data: " + JSON.stringify(data)); }
+			};
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r.SetOptions(lib.Options{Throw: null.BoolFrom(true)})
+
+	api := &lib.ScenarioState{Name: "api", Scenario: lib.Scenario{
+		Exec:     null.StringFrom("api"),
+		Setup:    null.StringFrom("apiSetup"),
+		Teardown: null.StringFrom("apiTeardown"),
+	}}
+	other := &lib.ScenarioState{Name: "other"}
+	require.NoError(t, r.Setup(context.Background()))
+	require.NoError(t, r.Setup(lib.WithScenario(context.Background(), api)))
+	require.NoError(t, r.Setup(lib.WithScenario(context.Background(), other)))
+
+	vu, err := r.NewVU()
+	require.NoError(t, err)
+	for _, sc := range []*lib.ScenarioState{api, other, api} {
+		_, err := vu.RunOnce(lib.WithScenario(context.Background(), sc))
+		assert.NoError(t, err, sc.Name)
+	}
+
+	assert.NoError(t, r.Teardown(lib.WithScenario(context.Background(), api)))
+	assert.NoError(t, r.Teardown(lib.WithScenario(context.Background(), other)))
+	assert.NoError(t, r.Teardown(context.Background()))
+}
+
+func TestScenarioComposition(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/journeys/checkout.js", []byte(`
+		function checkout(data) {
+			if (data.token !== "test-checkout") { throw new Error("checkout: wrong data: " + JSON.stringify(data)); }
+		}
+		exports.scenarios = {
+			checkout: {
+				executor: "shared-iterations",
+				exec: checkout,
+				setup: function checkoutSetup(data) { return { token: data.token + "-checkout" }; },
+				teardown: function checkoutTeardown(data) {
+					if (data.token !== "test-checkout") { throw new Error("teardown: wrong data: " + JSON.stringify(data)); }
+				},
+			},
+		};
+	`), 0644))
+
+	r1, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			var checkout = require("./journeys/checkout.js");
+			var scenarios = { local: { executor: "shared-iterations", exec: "local" } };
+			for (var name in checkout.scenarios) { scenarios[name] = checkout.scenarios[name]; }
+			exports.options = { scenarios: scenarios };
+			exports.setup = function() { return { token: "test" }; };
+			exports.local = function() {};
+			exports.checkout = function() { throw new Error("the script's own checkout() ran"); };
+		`),
+	}, fs, lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r1.SetOptions(r1.GetOptions().Apply(lib.Options{Throw: null.BoolFrom(true)}))
+
+	sc := r1.GetOptions().Scenarios["checkout"]
+	assert.Equal(t, null.StringFrom("checkout"), sc.Exec)
+	assert.Equal(t, null.StringFrom("checkoutSetup"), sc.Setup)
+	assert.Equal(t, null.StringFrom("checkoutTeardown"), sc.Teardown)
+
+	r2, err := NewFromArchive(r1.MakeArchive(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	for name, r := range map[string]*Runner{"Source": r1, "Archive": r2} {
+		t.Run(name, func(t *testing.T) {
+			checkout := &lib.ScenarioState{Name: "checkout", Scenario: sc}
+			local := &lib.ScenarioState{Name: "local", Scenario: r.GetOptions().Scenarios["local"]}
+			require.NoError(t, r.Setup(context.Background()))
+			require.NoError(t, r.Setup(lib.WithScenario(context.Background(), checkout)))
+
+			vu, err := r.NewVU()
+			require.NoError(t, err)
+			for _, sc := range []*lib.ScenarioState{checkout, local, checkout} {
+				_, err := vu.RunOnce(lib.WithScenario(context.Background(), sc))
+				assert.NoError(t, err, sc.Name)
+			}
+			assert.NoError(t, r.Teardown(lib.WithScenario(context.Background(), checkout)))
+		})
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data: []byte(`
+				exports.options = { scenarios: { a: { executor: "shared-iterations", exec: "missing" } } };
+			`),
+		}, fs, lib.RuntimeOptions{})
+		assert.EqualError(t, err, "scenario a: exported function 'missing' not found")
+	})
+}
+
+func TestHandleSummary(t *testing.T) {
+	summary := map[string]interface{}{
+		"metrics": map[string]interface{}{
+			"iterations": map[string]interface{}{"values": map[string]float64{"count": 3}},
+		},
+	}
+
+	t.Run("Files", func(t *testing.T) {
+		r, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data: []byte(`
+				exports.default = function() {};
+				exports.handleSummary = function(data) {
+					return {
+						"stdout": "iterations: " + data.metrics.iterations.values.count + "\n",
+						"summary.json": JSON.stringify(data),
+					};
+				};
+			`),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+
+		files, err := r.HandleSummary(context.Background(), summary)
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		stdout, err := ioutil.ReadAll(files["stdout"])
+		require.NoError(t, err)
+		assert.Equal(t, "iterations: 3\n", string(stdout))
+		summaryJSON, err := ioutil.ReadAll(files["summary.json"])
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"metrics":{"iterations":{"values":{"count":3}}}}`, string(summaryJSON))
+	})
+	t.Run("Nothing", func(t *testing.T) {
+		r, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data:     []byte(`exports.default = function() {}; exports.handleSummary = function(data) {};`),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+
+		files, err := r.HandleSummary(context.Background(), summary)
+		require.NoError(t, err)
+		assert.NotNil(t, files)
+		assert.Empty(t, files)
+	})
+	t.Run("NotExported", func(t *testing.T) {
+		r, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data:     []byte(`exports.default = function() {};`),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+
+		files, err := r.HandleSummary(context.Background(), summary)
+		assert.NoError(t, err)
+		assert.Nil(t, files)
+	})
+	t.Run("NotAString", func(t *testing.T) {
+		r, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data:     []byte(`exports.default = function() {}; exports.handleSummary = function(data) { return { "summary.json": data }; };`),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+
+		_, err = r.HandleSummary(context.Background(), summary)
+		assert.EqualError(t, err, "handleSummary: the content of 'summary.json' must be a string")
+	})
+	t.Run("NotAFunction", func(t *testing.T) {
+		_, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data:     []byte(`exports.default = function() {}; exports.handleSummary = "summary.txt";`),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		assert.EqualError(t, err, "exported 'handleSummary' must be a function")
+	})
+}
+
 func TestRunnerIntegrationImports(t *testing.T) {
 	t.Run("Modules", func(t *testing.T) {
 		modules := []string{
@@ -235,6 +425,51 @@ func TestRunnerIntegrationImports(t *testing.T) {
 	})
 }
 
+func TestRunnerParallelVUs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/lib.js", []byte(`exports.greeting = "hi";`), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/data.txt", []byte("some data"), 0644))
+	r1, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+		var lib = require("./lib.js");
+		var data = open("./data.txt");
+		exports.default = function() {
+			if (lib.greeting !== "hi" || data !== "some data") { throw new Error("wrong init: " + data); }
+		};
+		`),
+	}, fs, lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r2, err := NewFromArchive(r1.MakeArchive(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	for name, r := range map[string]*Runner{"Source": r1, "Archive": r2} {
+		t.Run(name, func(t *testing.T) {
+			// The VUs share the bundle's compiled programs and files, so they can be set up at once.
+			var wg sync.WaitGroup
+			errs := make(chan error, 20)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					vu, err := r.NewVU()
+					if err == nil {
+						_, err = vu.RunOnce(context.Background())
+					}
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, r.Bundle.BaseInitContext.programs, 1)
+			assert.Len(t, r.Bundle.BaseInitContext.texts, 1)
+		})
+	}
+}
+
 func TestVURunContext(t *testing.T) {
 	r1, err := New(&lib.SourceData{
 		Filename: "/script.js",
@@ -315,6 +550,74 @@ func TestVURunInterrupt(t *testing.T) {
 	}
 }
 
+func TestVUScenario(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			exports.default = function() {
+				if (__ENV.ROLE !== "user") { throw new Error("wrong role: " + __ENV.ROLE); }
+			};
+			exports.admin = function() {
+				if (__ENV.ROLE !== "admin") { throw new Error("wrong role: " + __ENV.ROLE); }
+				if (__ENV.HOST !== "example.com") { throw new Error("wrong host: " + __ENV.HOST); }
+			};
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{Env: map[string]string{"ROLE": "user", "HOST": "example.com"}})
+	require.NoError(t, err)
+	r.SetOptions(lib.Options{Throw: null.BoolFrom(true), SystemTags: lib.GetTagSet("scenario")})
+
+	vu, err := r.newVU()
+	require.NoError(t, err)
+
+	admin := &lib.ScenarioState{Name: "admins", Scenario: lib.Scenario{
+		Exec: null.StringFrom("admin"),
+		Env:  map[string]string{"ROLE": "admin"},
+		Tags: map[string]string{"role": "admin"},
+	}}
+	samples, err := vu.RunOnce(lib.WithScenario(context.Background(), admin))
+	require.NoError(t, err)
+	for _, s := range samples {
+		role, _ := s.Tags.Get("role")
+		assert.Equal(t, "admin", role, s.Metric.Name)
+		scenario, _ := s.Tags.Get("scenario")
+		assert.Equal(t, "admins", scenario, s.Metric.Name)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		_, err := vu.RunOnce(context.Background())
+		assert.NoError(t, err)
+		_, err = vu.RunOnce(lib.WithScenario(context.Background(), &lib.ScenarioState{Name: "users"}))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		missing := &lib.ScenarioState{Name: "missing", Scenario: lib.Scenario{Exec: null.StringFrom("nope")}}
+		_, err := vu.RunOnce(lib.WithScenario(context.Background(), missing))
+		assert.EqualError(t, err, "scenario missing: exported function 'nope' not found")
+	})
+
+	t.Run("NoDefault", func(t *testing.T) {
+		r, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data: []byte(`
+				exports.options = { scenarios: { api: { executor: "constant-vus", duration: "1s", exec: "api" } } };
+				exports.api = function() {};
+			`),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+		vu, err := r.newVU()
+		require.NoError(t, err)
+
+		api := &lib.ScenarioState{Name: "api", Scenario: r.GetOptions().Scenarios["api"]}
+		_, err = vu.RunOnce(lib.WithScenario(context.Background(), api))
+		assert.NoError(t, err)
+		_, err = vu.RunOnce(context.Background())
+		assert.EqualError(t, err, "script doesn't export a default function")
+		_, err = vu.RunOnce(lib.WithScenario(context.Background(), &lib.ScenarioState{Name: "web"}))
+		assert.EqualError(t, err, "scenario web: exported function 'default' not found")
+	})
+}
+
 func TestVUIntegrationGroups(t *testing.T) {
 	r1, err := New(&lib.SourceData{
 		Filename: "/script.js",
@@ -554,8 +857,8 @@ func TestVUIntegrationHosts(t *testing.T) {
 
 	r1.SetOptions(lib.Options{
 		Throw: null.BoolFrom(true),
-		Hosts: map[string]net.IP{
-			"test.loadimpact.com": net.ParseIP("127.0.0.1"),
+		Hosts: types.Hosts{
+			"test.loadimpact.com": {IP: net.ParseIP("127.0.0.1")},
 		},
 	})
 
@@ -712,6 +1015,28 @@ func TestVUIntegrationOpenFunctionError(t *testing.T) {
 	assert.EqualError(t, err, "GoError: \"open\" function is only available to the init code (aka global scope), see https://docs.k6.io/docs/test-life-cycle for more information")
 }
 
+func TestVUIntegrationUnhandledRejection(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			exports.default = function() {
+				crypto.subtle.digest("SHA-256", "handled").then(function() { throw new Error("handled"); }).catch(function() {});
+				if (__ITER === 1) {
+					crypto.subtle.digest("MD5", "unhandled").then(function() {});
+				}
+			}
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	vu, err := r.NewVU()
+	require.NoError(t, err)
+	_, err = vu.RunOnce(context.Background())
+	assert.NoError(t, err)
+	_, err = vu.RunOnce(context.Background())
+	assert.EqualError(t, err, "Uncaught (in promise) GoError: NotSupportedError: unsupported hash algorithm: MD5")
+}
+
 func TestVUIntegrationCookies(t *testing.T) {
 	tb := testutils.NewHTTPMultiBin(t)
 	defer tb.Cleanup()
@@ -798,6 +1123,159 @@ func TestVUIntegrationVUID(t *testing.T) {
 	}
 }
 
+func TestVUIntegrationSeed(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			export let n = null;
+			export default function() { n = Math.random(); }
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	random := func(seed null.Int, id int64) float64 {
+		r.SetOptions(lib.Options{Seed: seed})
+		vu, err := r.newVU()
+		require.NoError(t, err)
+		require.NoError(t, vu.Reconfigure(id))
+		_, err = vu.RunOnce(context.Background())
+		require.NoError(t, err)
+		return vu.Runtime.Get("n").ToFloat()
+	}
+
+	assert.Equal(t, random(null.IntFrom(42), 1), random(null.IntFrom(42), 1))
+	assert.NotEqual(t, random(null.IntFrom(42), 1), random(null.IntFrom(42), 2))
+	assert.NotEqual(t, random(null.IntFrom(42), 1), random(null.IntFrom(43), 1))
+	assert.NotEqual(t, random(null.Int{}, 1), random(null.Int{}, 1))
+}
+
+func TestVUIntegrationPreconnect(t *testing.T) {
+	var conns, resumed int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.DidResume {
+			atomic.AddInt64(&resumed, 1)
+		}
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			var http = require("k6/http");
+			exports.default = function() {
+				for (var i = 0; i < 3; i++) { http.get(%q); }
+			};
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	for _, reuse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ReuseTLSSessions=%t", reuse), func(t *testing.T) {
+			atomic.StoreInt64(&conns, 0)
+			atomic.StoreInt64(&resumed, 0)
+			r.SetOptions(lib.Options{
+				InsecureSkipTLSVerify: null.BoolFrom(true),
+				Preconnect: lib.PreconnectConfig{
+					URLs:             []string{srv.URL},
+					Connections:      null.IntFrom(3),
+					ReuseTLSSessions: null.BoolFrom(reuse),
+				},
+			})
+
+			vu, err := r.NewVU()
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), atomic.LoadInt64(&conns))
+			assert.Equal(t, int64(0), atomic.LoadInt64(&vu.(*VU).Dialer.BytesWritten))
+
+			// The iteration's requests use the connections that are already open.
+			_, err = vu.RunOnce(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), atomic.LoadInt64(&conns))
+
+			// Another VU resumes the sessions of the first one, if they're shared.
+			_, err = r.NewVU()
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), atomic.LoadInt64(&conns))
+			if reuse {
+				assert.Equal(t, int64(3), atomic.LoadInt64(&resumed))
+			} else {
+				assert.Equal(t, int64(0), atomic.LoadInt64(&resumed))
+			}
+		})
+	}
+}
+
+func TestVUIntegrationClientProfiles(t *testing.T) {
+	r1, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			exports.options = { clientProfiles: { "safari-ios": 1 } };
+			exports.default = function() {};
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r2, err := NewFromArchive(r1.MakeArchive(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	runners := map[string]*Runner{"Source": r1, "Archive": r2}
+	for name, r := range runners {
+		t.Run(name, func(t *testing.T) {
+			vu, err := r.newVU()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NoError(t, vu.Reconfigure(1))
+			assert.Equal(t, lib.ClientProfiles["safari-ios"], vu.Profile)
+			assert.Equal(t, lib.ClientProfiles["safari-ios"].CipherSuites, vu.TLSConfig.CipherSuites)
+
+			firefox := &lib.ScenarioState{Name: "firefox"}
+			firefox.ClientProfile = null.StringFrom("firefox-desktop")
+			_, err = vu.RunOnce(lib.WithScenario(context.Background(), firefox))
+			assert.NoError(t, err)
+			assert.Equal(t, lib.ClientProfiles["firefox-desktop"].CipherSuites, vu.TLSConfig.CipherSuites)
+			assert.Equal(t, lib.ClientProfiles["firefox-desktop"].CurvePreferences, vu.TLSConfig.CurvePreferences)
+
+			_, err = vu.RunOnce(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, lib.ClientProfiles["safari-ios"].CipherSuites, vu.TLSConfig.CipherSuites)
+		})
+	}
+
+	t.Run("NoProfile", func(t *testing.T) {
+		r, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data:     []byte(`exports.default = function() {};`),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+		vu, err := r.newVU()
+		require.NoError(t, err)
+
+		safari := &lib.ScenarioState{Name: "safari"}
+		safari.ClientProfile = null.StringFrom("safari-ios")
+		_, err = vu.RunOnce(lib.WithScenario(context.Background(), safari))
+		assert.NoError(t, err)
+		assert.Equal(t, lib.ClientProfiles["safari-ios"].CipherSuites, vu.TLSConfig.CipherSuites)
+
+		_, err = vu.RunOnce(lib.WithScenario(context.Background(), &lib.ScenarioState{Name: "other"}))
+		assert.NoError(t, err)
+		assert.Nil(t, vu.TLSConfig.CipherSuites)
+		assert.Nil(t, vu.TLSConfig.CurvePreferences)
+	})
+}
+
 func TestVUIntegrationClientCerts(t *testing.T) {
 	clientCAPool := x509.NewCertPool()
 	assert.True(t, clientCAPool.AppendCertsFromPEM(
@@ -940,3 +1418,70 @@ func TestVUIntegrationClientCerts(t *testing.T) {
 		}
 	})
 }
+
+func TestVUFreshState(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			var count = 0;
+			exports.default = function() {
+				count++;
+				if (count !== 1) { throw new Error("count is " + count); }
+			};
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r.SetOptions(lib.Options{Throw: null.BoolFrom(true)})
+
+	vu, err := r.newVU()
+	require.NoError(t, err)
+	assert.NoError(t, vu.Reconfigure(7))
+
+	fresh := &lib.ScenarioState{Name: "fresh", Scenario: lib.Scenario{FreshVUState: null.BoolFrom(true)}}
+	for i := 0; i < 3; i++ {
+		_, err := vu.RunOnce(lib.WithScenario(context.Background(), fresh))
+		assert.NoError(t, err, "iteration %d", i)
+	}
+	assert.Equal(t, int64(7), vu.Runtime.Get("__VU").ToInteger())
+
+	// Without it, the next iteration sees what the last one left behind.
+	_, err = vu.RunOnce(lib.WithScenario(context.Background(), &lib.ScenarioState{Name: "reused"}))
+	assert.Error(t, err)
+}
+
+func TestVUStore(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			var execution = require("k6/execution");
+			exports.default = function() {
+				var store = execution.vu().store;
+				var iter = execution.vu().iterationInScenario;
+				if (store.get("iteration") !== undefined) {
+					throw new Error("iteration " + iter + " sees the value of the last one");
+				}
+				if (iter > 0 && store.get("token") !== "token-0") {
+					throw new Error("iteration " + iter + " lost the token: " + store.get("token"));
+				}
+				if (!store.has("token")) { store.set("token", "token-" + iter); }
+				store.set("iteration", iter, { scope: "iteration" });
+			};
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r.SetOptions(lib.Options{Throw: null.BoolFrom(true)})
+
+	vu, err := r.newVU()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := vu.RunOnce(context.Background())
+		assert.NoError(t, err, "iteration %d", i)
+	}
+	assert.Equal(t, []string{"token"}, vu.Store.Keys())
+
+	// A VU starting iterations from scratch starts with an empty store too.
+	fresh := &lib.ScenarioState{Name: "fresh", Scenario: lib.Scenario{FreshVUState: null.BoolFrom(true)}}
+	_, err = vu.RunOnce(lib.WithScenario(context.Background(), fresh))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "iteration 3 lost the token: undefined")
+}
@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import "github.com/dop251/goja"
+
+// Scenarios can be given the functions they run themselves, rather than the names the script
+// exports them under. That way, a library of user journeys can export scenario definitions along
+// with their functions, and scripts can import them and compose their scenarios out of them, eg.
+// with `scenarios: { ...checkout.scenarios, ...browse.scenarios }`, without re-exporting anything.
+
+// scenarioFuncOptions are the scenario options that name a function.
+var scenarioFuncOptions = []string{"exec", "setup", "teardown"}
+
+// exportOptions exports the script's options, with the functions given as scenario options
+// replaced by the names they go by, so that the options can be serialized.
+func exportOptions(rt *goja.Runtime, options goja.Value) interface{} {
+	exported := options.Export()
+	opts, ok := exported.(map[string]interface{})
+	if !ok {
+		return exported
+	}
+	scenarios, ok := opts["scenarios"].(map[string]interface{})
+	if !ok {
+		return exported
+	}
+	for name, v := range scenarios {
+		sc, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, option := range scenarioFuncOptions {
+			if fn := scenarioOptionFunc(rt, options, name, option); fn != nil {
+				sc[option] = scenarioFuncName(name, option, fn)
+			}
+		}
+	}
+	return exported
+}
+
+// scenarioFuncName is the name a function given as a scenario option goes by: its own, if it has
+// one, or else the scenario's and the option's, eg. "checkout.exec".
+func scenarioFuncName(scenario, option string, fn *goja.Object) string {
+	if name := fn.Get("name"); name != nil && !goja.IsUndefined(name) && name.String() != "" {
+		return name.String()
+	}
+	return scenario + "." + option
+}
+
+// scenarioOptionFunc returns the function the options gave as a scenario's option, or nil if
+// they gave it something else.
+func scenarioOptionFunc(rt *goja.Runtime, options goja.Value, scenario, option string) *goja.Object {
+	v := options
+	for _, key := range []string{"scenarios", scenario, option} {
+		obj, ok := v.(*goja.Object)
+		if !ok {
+			return nil
+		}
+		if v = obj.Get(key); v == nil {
+			return nil
+		}
+	}
+	if _, ok := goja.AssertFunction(v); !ok {
+		return nil
+	}
+	return v.ToObject(rt)
+}
+
+// getScenarioFunc returns the function a scenario's exec, setup or teardown option names, in the
+// instance of the script that rt runs: the one the script's options gave for it, or else the one
+// the script exports under the name.
+func getScenarioFunc(rt *goja.Runtime, scenario, option, name string) (goja.Callable, bool) {
+	exports := rt.Get("exports").ToObject(rt)
+	if fn := scenarioOptionFunc(rt, exports.Get("options"), scenario, option); fn != nil &&
+		scenarioFuncName(scenario, option, fn) == name {
+		return goja.AssertFunction(fn)
+	}
+	return goja.AssertFunction(exports.Get(name))
+}
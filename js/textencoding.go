@@ -0,0 +1,226 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// A TextEncoder encodes strings to UTF-8. As there are no typed arrays, bytes are arrays of
+// numbers, like the ones returned by open(file, "b").
+type TextEncoder struct {
+	Encoding string `js:"encoding"`
+}
+
+func (e *TextEncoder) Encode(input goja.Value) []byte {
+	if input == nil || goja.IsUndefined(input) {
+		return []byte{}
+	}
+	return []byte(input.String())
+}
+
+// A TextDecoder decodes bytes in an encoding to a string.
+type TextDecoder struct {
+	Encoding  string `js:"encoding"`
+	Fatal     bool   `js:"fatal"`
+	IgnoreBOM bool   `js:"ignoreBOM"`
+}
+
+// Labels of the supported encodings, from the WHATWG Encoding Standard. As in browsers, ASCII
+// and Latin-1 labels are decoded as windows-1252.
+var textDecoderLabels = map[string]string{
+	"unicode-1-1-utf-8": "utf-8",
+	"utf-8":             "utf-8",
+	"utf8":              "utf-8",
+	"csunicode":         "utf-16le",
+	"iso-10646-ucs-2":   "utf-16le",
+	"ucs-2":             "utf-16le",
+	"unicode":           "utf-16le",
+	"unicodefeff":       "utf-16le",
+	"utf-16":            "utf-16le",
+	"utf-16le":          "utf-16le",
+	"unicodefffe":       "utf-16be",
+	"utf-16be":          "utf-16be",
+	"ansi_x3.4-1968":    "windows-1252",
+	"ascii":             "windows-1252",
+	"cp1252":            "windows-1252",
+	"cp819":             "windows-1252",
+	"csisolatin1":       "windows-1252",
+	"ibm819":            "windows-1252",
+	"iso-8859-1":        "windows-1252",
+	"iso-ir-100":        "windows-1252",
+	"iso8859-1":         "windows-1252",
+	"iso88591":          "windows-1252",
+	"iso_8859-1":        "windows-1252",
+	"iso_8859-1:1987":   "windows-1252",
+	"l1":                "windows-1252",
+	"latin1":            "windows-1252",
+	"us-ascii":          "windows-1252",
+	"windows-1252":      "windows-1252",
+	"x-cp1252":          "windows-1252",
+}
+
+// Characters of windows-1252 bytes 0x80-0x9F; the other bytes are the same code points.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+func newTextDecoder(rt *goja.Runtime, args ...goja.Value) (*TextDecoder, error) {
+	d := &TextDecoder{Encoding: "utf-8"}
+	if len(args) > 0 && !goja.IsUndefined(args[0]) {
+		label := strings.ToLower(strings.TrimSpace(args[0].String()))
+		encoding, ok := textDecoderLabels[label]
+		if !ok {
+			return nil, errors.Errorf("the encoding label provided ('%s') is invalid", args[0].String())
+		}
+		d.Encoding = encoding
+	}
+	if len(args) > 1 && !goja.IsUndefined(args[1]) && !goja.IsNull(args[1]) {
+		opts := args[1].ToObject(rt)
+		for _, k := range opts.Keys() {
+			switch k {
+			case "fatal":
+				d.Fatal = opts.Get(k).ToBoolean()
+			case "ignoreBOM":
+				d.IgnoreBOM = opts.Get(k).ToBoolean()
+			}
+		}
+	}
+	return d, nil
+}
+
+// Decode decodes an array of bytes or a Blob. Invalid sequences are replaced by U+FFFD, or throw
+// an error if the decoder is fatal.
+func (d *TextDecoder) Decode(input goja.Value) (string, error) {
+	data, err := exportBytes(input)
+	if err != nil {
+		return "", err
+	}
+
+	switch d.Encoding {
+	case "utf-16le", "utf-16be":
+		return d.decodeUTF16(data)
+	case "windows-1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			if b >= 0x80 && b <= 0x9F {
+				runes[i] = windows1252[b-0x80]
+			} else {
+				runes[i] = rune(b)
+			}
+		}
+		return string(runes), nil
+	default:
+		if !d.IgnoreBOM {
+			data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+		}
+		if utf8.Valid(data) {
+			return string(data), nil
+		}
+		if d.Fatal {
+			return "", d.invalidError()
+		}
+		return strings.ToValidUTF8(string(data), "�"), nil
+	}
+}
+
+func (d *TextDecoder) decodeUTF16(data []byte) (string, error) {
+	bom := []byte{0xFF, 0xFE}
+	if d.Encoding == "utf-16be" {
+		bom = []byte{0xFE, 0xFF}
+	}
+	if !d.IgnoreBOM {
+		data = bytes.TrimPrefix(data, bom)
+	}
+
+	unit := func(i int) rune {
+		if d.Encoding == "utf-16be" {
+			return rune(data[2*i])<<8 | rune(data[2*i+1])
+		}
+		return rune(data[2*i+1])<<8 | rune(data[2*i])
+	}
+
+	n := len(data) / 2
+	runes := make([]rune, 0, n)
+	for i := 0; i < n; i++ {
+		u := unit(i)
+		if !utf16.IsSurrogate(u) {
+			runes = append(runes, u)
+			continue
+		}
+		if i+1 < n {
+			if r := utf16.DecodeRune(u, unit(i+1)); r != utf8.RuneError {
+				runes = append(runes, r)
+				i++
+				continue
+			}
+		}
+		// An unpaired surrogate.
+		if d.Fatal {
+			return "", d.invalidError()
+		}
+		runes = append(runes, utf8.RuneError)
+	}
+	// A trailing odd byte can't be decoded.
+	if len(data)%2 != 0 {
+		if d.Fatal {
+			return "", d.invalidError()
+		}
+		runes = append(runes, utf8.RuneError)
+	}
+	return string(runes), nil
+}
+
+func (d *TextDecoder) invalidError() error {
+	return errors.Errorf("the encoded data was not valid for encoding %s", d.Encoding)
+}
+
+// exportBytes returns the bytes of an array of numbers or a Blob.
+func exportBytes(v goja.Value) ([]byte, error) {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil, nil
+	}
+	switch data := v.Export().(type) {
+	case []byte:
+		return data, nil
+	case *Blob:
+		return data.data, nil
+	case []interface{}:
+		obj := v.(*goja.Object)
+		b := make([]byte, len(data))
+		for i := range data {
+			b[i] = byte(obj.Get(strconv.Itoa(i)).ToInteger())
+		}
+		return b, nil
+	default:
+		return nil, errors.Errorf("expected an array of bytes or a Blob, got %s", v.String())
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+
+	"github.com/dop251/goja"
+	k6crypto "github.com/loadimpact/k6/js/modules/k6/crypto"
+	k6http "github.com/loadimpact/k6/js/modules/k6/http"
+)
+
+// webAPI provides constructors for the web platform globals that npm libraries bundled into
+// scripts commonly expect, eg. TextEncoder, and the crypto object.
+type webAPI struct {
+	Crypto webCrypto `js:"crypto,bind"`
+
+	rt *goja.Runtime
+}
+
+// webCrypto is the global crypto object. Unlike k6/crypto, it only has the standard members, so
+// libraries that feature-detect it see what they'd see in a browser.
+type webCrypto struct {
+	Subtle *k6crypto.SubtleCrypto `js:"subtle,bind"`
+}
+
+func (webCrypto) GetRandomValues(ctx context.Context, array goja.Value) (goja.Value, error) {
+	return k6crypto.New().GetRandomValues(ctx, array)
+}
+
+func (w webAPI) XTextEncoder() *TextEncoder {
+	return &TextEncoder{Encoding: "utf-8"}
+}
+
+func (w webAPI) XTextDecoder(args ...goja.Value) (*TextDecoder, error) {
+	return newTextDecoder(w.rt, args...)
+}
+
+func (w webAPI) XBlob(args ...goja.Value) (*Blob, error) {
+	return newBlob(w.rt, args...)
+}
+
+func (w webAPI) XFormData() *k6http.FormData {
+	return k6http.NewFormData(w.rt)
+}
@@ -0,0 +1,123 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebAPI(t *testing.T) {
+	b, err := getSimpleBundle("/script.js", `
+		exports.encoded = new TextEncoder().encode("héllo");
+		crypto.subtle.digest("SHA-256", "abc").then(function(digest) { exports.digest = digest.length; });
+		exports.random = crypto.getRandomValues(new Array(16)).length;
+		exports.default = function() {};
+	`)
+	require.NoError(t, err)
+	bi, err := b.Instantiate()
+	require.NoError(t, err)
+	rt := bi.Runtime
+
+	t.Run("TextEncoder", func(t *testing.T) {
+		v, err := rt.RunString(`[new TextEncoder().encoding, exports.encoded.length, Array.prototype.slice.call(exports.encoded, 0, 3).join(), new TextEncoder().encode().length]`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{"utf-8", int64(6), "104,195,169", int64(0)}, v.Export())
+		}
+	})
+
+	t.Run("TextDecoder", func(t *testing.T) {
+		testdata := map[string]struct {
+			script, result string
+		}{
+			"utf-8":        {`new TextDecoder().decode(exports.encoded)`, "héllo"},
+			"BOM":          {`new TextDecoder("utf8").decode([0xEF, 0xBB, 0xBF, 0x61])`, "a"},
+			"ignoreBOM":    {`new TextDecoder("utf8", { ignoreBOM: true }).decode([0xEF, 0xBB, 0xBF, 0x61])`, "\uFEFFa"},
+			"invalid":      {`new TextDecoder().decode([0x61, 0xFF, 0x62])`, "a�b"},
+			"empty":        {`new TextDecoder().decode()`, ""},
+			"blob":         {`new TextDecoder().decode(new Blob(["a", "b"]))`, "ab"},
+			"utf-16le":     {`new TextDecoder("utf-16").decode([0xFF, 0xFE, 0x61, 0x00, 0x3D, 0xD8, 0x00, 0xDE])`, "a\U0001F600"},
+			"utf-16be":     {`new TextDecoder("UTF-16BE").decode([0x00, 0x61, 0xD8, 0x3D])`, "a�"},
+			"windows-1252": {`new TextDecoder("latin1").decode([0x61, 0xE9, 0x80])`, "aé€"},
+			"encoding":     {`new TextDecoder(" Latin1 ").encoding`, "windows-1252"},
+		}
+		for name, data := range testdata {
+			t.Run(name, func(t *testing.T) {
+				v, err := rt.RunString(data.script)
+				if assert.NoError(t, err) {
+					assert.Equal(t, data.result, v.Export())
+				}
+			})
+		}
+
+		t.Run("fatal", func(t *testing.T) {
+			_, err := rt.RunString(`new TextDecoder("utf-8", { fatal: true }).decode([0x61, 0xFF])`)
+			assert.EqualError(t, err, "GoError: the encoded data was not valid for encoding utf-8")
+			_, err = rt.RunString(`new TextDecoder("utf-16le", { fatal: true }).decode([0x61])`)
+			assert.EqualError(t, err, "GoError: the encoded data was not valid for encoding utf-16le")
+		})
+		t.Run("unknown", func(t *testing.T) {
+			_, err := rt.RunString(`new TextDecoder("ebcdic")`)
+			assert.EqualError(t, err, "GoError: the encoding label provided ('ebcdic') is invalid at apply (native)")
+		})
+		t.Run("string", func(t *testing.T) {
+			_, err := rt.RunString(`new TextDecoder().decode("abc")`)
+			assert.EqualError(t, err, "GoError: expected an array of bytes or a Blob, got abc")
+		})
+	})
+
+	t.Run("crypto", func(t *testing.T) {
+		v, err := rt.RunString(`[exports.digest, exports.random, typeof crypto.sha256]`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{int64(32), int64(16), "undefined"}, v.Export())
+		}
+
+		_, err = getSimpleBundle("/script.js", `
+			crypto.subtle.digest("MD5", "abc").then(function() {});
+			exports.default = function() {};
+		`)
+		assert.EqualError(t, err, "Uncaught (in promise) GoError: NotSupportedError: unsupported hash algorithm: MD5")
+	})
+
+	t.Run("Blob", func(t *testing.T) {
+		v, err := rt.RunString(`
+			var blob = new Blob(["héllo", [0x20], new Blob(["world"])], { type: "Text/Plain" });
+			[blob.size, blob.type, blob.text(), blob.bytes().length, blob.slice(-5).text(), blob.slice(1, 3, "a/b").type, blob.slice(3, 1).size, new Blob().size]
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{int64(12), "text/plain", "héllo world", int64(12), "world", "a/b", int64(0), int64(0)}, v.Export())
+		}
+	})
+
+	t.Run("FormData", func(t *testing.T) {
+		v, err := rt.RunString(`
+			var fd = new FormData();
+			fd.append("a", "1");
+			fd.append("file", new Blob(["héllo"], { type: "text/plain" }), "hello.txt");
+			[fd.keys().join(), fd.get("a"), fd.get("file").filename, fd.get("file").content_type, fd.get("file").data.length]
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{"a,file", "1", "hello.txt", "text/plain", int64(6)}, v.Export())
+		}
+	})
+}
@@ -26,7 +26,6 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
-	"os"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -39,6 +38,10 @@ var volumeRE = regexp.MustCompile(`^([a-zA-Z]):(.*)`)
 var sharedRE = regexp.MustCompile(`^//([^/]+)`) // matches a shared folder in Windows after backslack replacement. i.e //VMBOXSVR/k6/script.js
 var homeDirRE = regexp.MustCompile(`^(/[a-zA-Z])?/(Users|home|Documents and Settings)/(?:[^/]+)`)
 
+// All entries of an archive have the same modification time, rather than when it was made, so
+// that archiving the same test twice gives the same bytes.
+var archiveModTime = time.Unix(0, 0).UTC()
+
 // Normalizes (to use a / path separator) and anonymizes a file path, by scrubbing usernames from home directories.
 func NormalizeAndAnonymizePath(path string) string {
 	path = filepath.Clean(path)
@@ -70,6 +73,9 @@ type Archive struct {
 
 	// Environment variables
 	Env map[string]string `json:"env"`
+
+	// Experimental features enabled for the archived test
+	Enable []string `json:"enable,omitempty"`
 }
 
 // Reads an archive created by Archive.Write from a reader.
@@ -145,7 +151,7 @@ func ReadArchive(in io.Reader) (*Archive, error) {
 // the current one.
 func (arc *Archive) Write(out io.Writer) error {
 	w := tar.NewWriter(out)
-	t := time.Now()
+	t := archiveModTime
 
 	metaArc := *arc
 	metaArc.Filename = NormalizeAndAnonymizePath(metaArc.Filename)
@@ -208,7 +214,7 @@ func (arc *Archive) Write(out io.Writer) error {
 			dir := path.Dir(filePath)
 			for {
 				foundDirs[dir] = true
-				idx := strings.LastIndexByte(dir, os.PathSeparator)
+				idx := strings.LastIndexByte(dir, '/')
 				if idx == -1 {
 					break
 				}
@@ -21,11 +21,14 @@
 package lib
 
 import (
+	"archive/tar"
 	"bytes"
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	null "gopkg.in/guregu/null.v3"
 )
 
@@ -139,6 +142,35 @@ func TestArchiveReadWrite(t *testing.T) {
 			assert.Equal(t, arc1Anon, arc2)
 		}
 	})
+	t.Run("Deterministic", func(t *testing.T) {
+		write := func() []byte {
+			arc := &Archive{
+				Type:     "js",
+				Filename: "/path/to/script.js",
+				Data:     []byte(`// contents...`),
+				Pwd:      "/path/to",
+				Scripts:  map[string][]byte{"/path/to/a.js": nil, "/path/to/lib/b.js": nil, "/path/c.js": nil},
+				Files:    map[string][]byte{"/path/to/file1.txt": nil, "/data/file2.txt": nil},
+			}
+			buf := bytes.NewBuffer(nil)
+			require.NoError(t, arc.Write(buf))
+			return buf.Bytes()
+		}
+		data := write()
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, data, write())
+		}
+
+		r := tar.NewReader(bytes.NewReader(data))
+		for {
+			hdr, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), hdr.ModTime.Unix(), hdr.Name)
+		}
+	})
 }
 
 func TestArchiveJSONEscape(t *testing.T) {
@@ -0,0 +1,188 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// A ClientProfile describes the identity of a simulated client: the headers a real browser or
+// device sends with every request, and hints about the shape of its TLS handshake. Note that newer
+// Go versions pick the order of cipher suites themselves, so the TLS hints mainly affect which
+// suites and curves are offered. Header order can't be controlled.
+type ClientProfile struct {
+	Name             string
+	UserAgent        string
+	Accept           string
+	AcceptLanguage   string
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+}
+
+var (
+	chromeCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	}
+	firefoxCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	}
+	safariCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	}
+
+	browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"
+)
+
+// ClientProfiles are the built-in client profile presets.
+var ClientProfiles = map[string]*ClientProfile{
+	"chrome-desktop": {
+		Name:             "chrome-desktop",
+		UserAgent:        "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/69.0.3497.100 Safari/537.36",
+		Accept:           browserAccept,
+		AcceptLanguage:   "en-US,en;q=0.9",
+		CipherSuites:     chromeCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	"chrome-android": {
+		Name:             "chrome-android",
+		UserAgent:        "Mozilla/5.0 (Linux; Android 8.0.0; SM-G960F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/69.0.3497.100 Mobile Safari/537.36",
+		Accept:           browserAccept,
+		AcceptLanguage:   "en-US,en;q=0.9",
+		CipherSuites:     chromeCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	"firefox-desktop": {
+		Name:             "firefox-desktop",
+		UserAgent:        "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:62.0) Gecko/20100101 Firefox/62.0",
+		Accept:           "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		AcceptLanguage:   "en-US,en;q=0.5",
+		CipherSuites:     firefoxCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521},
+	},
+	"safari-macos": {
+		Name:             "safari-macos",
+		UserAgent:        "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_13_6) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.0 Safari/605.1.15",
+		Accept:           "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		AcceptLanguage:   "en-us",
+		CipherSuites:     safariCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521},
+	},
+	"safari-ios": {
+		Name:             "safari-ios",
+		UserAgent:        "Mozilla/5.0 (iPhone; CPU iPhone OS 12_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.0 Mobile/15E148 Safari/604.1",
+		Accept:           "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		AcceptLanguage:   "en-us",
+		CipherSuites:     safariCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521},
+	},
+	"edge-desktop": {
+		Name:             "edge-desktop",
+		UserAgent:        "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/64.0.3282.140 Safari/537.36 Edge/17.17134",
+		Accept:           "text/html, application/xhtml+xml, application/xml; q=0.9, */*; q=0.8",
+		AcceptLanguage:   "en-US",
+		CipherSuites:     chromeCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+}
+
+// ClientProfileMix maps client profile names to their weights in the simulated traffic.
+type ClientProfileMix map[string]float64
+
+func (m *ClientProfileMix) UnmarshalJSON(data []byte) error {
+	var mix map[string]float64
+	if err := json.Unmarshal(data, &mix); err != nil {
+		return err
+	}
+	if err := ClientProfileMix(mix).Validate(); err != nil {
+		return err
+	}
+	*m = mix
+	return nil
+}
+
+// Validate makes sure that all profiles exist and that the weights make sense.
+func (m ClientProfileMix) Validate() error {
+	total := 0.0
+	for name, weight := range m {
+		if _, ok := ClientProfiles[name]; !ok {
+			return errors.Errorf("unknown client profile: %s", name)
+		}
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return errors.Errorf("invalid weight for client profile %s: %v", name, weight)
+		}
+		total += weight
+	}
+	if len(m) > 0 && total <= 0 {
+		return errors.New("the total weight of all client profiles must be positive")
+	}
+	return nil
+}
+
+// Pick assigns a VU a client profile. The assignments are deterministic, and they are spread out
+// so that even a handful of VUs gets close to the requested mix.
+func (m ClientProfileMix) Pick(vuID int64) *ClientProfile {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return ClientProfiles[SpreadPick(vuID, math.Phi-1, names, m)]
+}
@@ -0,0 +1,63 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientProfileMix(t *testing.T) {
+	t.Run("Pick", func(t *testing.T) {
+		mix := ClientProfileMix{"chrome-desktop": 3, "safari-ios": 1, "edge-desktop": 0}
+		counts := map[string]int{}
+		for id := int64(1); id <= 100; id++ {
+			profile := mix.Pick(id)
+			counts[profile.Name]++
+			assert.Equal(t, profile, mix.Pick(id))
+		}
+		assert.InDelta(t, 75, counts["chrome-desktop"], 3)
+		assert.InDelta(t, 25, counts["safari-ios"], 3)
+		assert.Zero(t, counts["edge-desktop"])
+
+		assert.Nil(t, ClientProfileMix(nil).Pick(1))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var mix ClientProfileMix
+		assert.NoError(t, json.Unmarshal([]byte(`{"firefox-desktop": 1, "chrome-android": 2}`), &mix))
+		assert.Equal(t, ClientProfileMix{"firefox-desktop": 1, "chrome-android": 2}, mix)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"netscape": 1}`), &mix), "unknown client profile: netscape")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"safari-ios": -1}`), &mix), "invalid weight for client profile safari-ios: -1")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"safari-ios": 0}`), &mix), "the total weight of all client profiles must be positive")
+	})
+
+	t.Run("Presets", func(t *testing.T) {
+		for name, profile := range ClientProfiles {
+			assert.Equal(t, name, profile.Name)
+			assert.NotEmpty(t, profile.UserAgent, name)
+			assert.NotEmpty(t, profile.CipherSuites, name)
+		}
+	})
+}
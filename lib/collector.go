@@ -47,3 +47,26 @@ type Collector interface {
 	// Return the required system sample tags for the specific collector
 	GetRequiredSystemTags() TagSet
 }
+
+// RunStatus is the status of a test run; the values are the ones the cloud uses.
+type RunStatus int
+
+const (
+	RunStatusCreated RunStatus = iota - 2
+	RunStatusValidated
+	RunStatusQueued
+	RunStatusInitializing
+	RunStatusRunning
+	RunStatusFinished
+	RunStatusTimedOut
+	RunStatusAbortedUser
+	RunStatusAbortedSystem
+	RunStatusAbortedScriptError
+	RunStatusAbortedThreshold
+)
+
+// A RunStatusUpdater is a collector that wants to know when a test starts running and how it
+// ended; the engine calls SetRunStatus before Run() and before the context for Run() is done.
+type RunStatusUpdater interface {
+	SetRunStatus(status RunStatus)
+}
@@ -0,0 +1,69 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import "context"
+
+type ctxKey int
+
+const (
+	ctxKeyExecutor ctxKey = iota
+	ctxKeyIteration
+	ctxKeyScenario
+)
+
+// WithExecutor attaches the executor running a test to a context, so code running in the test can
+// look at its state.
+func WithExecutor(ctx context.Context, ex Executor) context.Context {
+	return context.WithValue(ctx, ctxKeyExecutor, ex)
+}
+
+// GetExecutor returns the executor attached to a context, or nil.
+func GetExecutor(ctx context.Context) Executor {
+	v := ctx.Value(ctxKeyExecutor)
+	if v == nil {
+		return nil
+	}
+	return v.(Executor)
+}
+
+// WithIteration attaches the number of the iteration being run, counting all iterations started
+// in the test, to a context.
+func WithIteration(ctx context.Context, iter int64) context.Context {
+	return context.WithValue(ctx, ctxKeyIteration, iter)
+}
+
+// GetIteration returns the iteration number attached to a context, and whether there is one.
+func GetIteration(ctx context.Context) (int64, bool) {
+	iter, ok := ctx.Value(ctxKeyIteration).(int64)
+	return iter, ok
+}
+
+// WithScenario attaches the scenario an iteration is run for to a context.
+func WithScenario(ctx context.Context, sc *ScenarioState) context.Context {
+	return context.WithValue(ctx, ctxKeyScenario, sc)
+}
+
+// GetScenario returns the scenario attached to a context, or nil if the test doesn't have any.
+func GetScenario(ctx context.Context) *ScenarioState {
+	sc, _ := ctx.Value(ctxKeyScenario).(*ScenarioState)
+	return sc
+}
@@ -0,0 +1,156 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package diskqueue implements a bounded, first-in first-out queue of batches on disk, which
+// outputs use to hold on to samples while their backend is unreachable.
+package diskqueue
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// A Queue holds batches of samples in files in a directory, as opaque bytes. When a new batch
+// doesn't fit, the oldest ones are dropped to make room for it.
+type Queue struct {
+	fs      afero.Fs
+	dir     string
+	maxSize int64
+
+	lock    sync.Mutex
+	batches []batch
+	size    int64
+	seq     int64
+	dropped int64
+}
+
+type batch struct {
+	name    string
+	size    int64
+	samples int64
+}
+
+// New returns a queue in dir, which is created if it doesn't exist, holding up to maxSize bytes.
+func New(fs afero.Fs, dir string, maxSize int64) (*Queue, error) {
+	if maxSize <= 0 {
+		return nil, errors.New("a disk queue needs a positive size")
+	}
+	if err := fs.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "couldn't create the disk queue")
+	}
+	return &Queue{fs: fs, dir: dir, maxSize: maxSize}, nil
+}
+
+// Push adds a batch of samples to the back of the queue. A batch bigger than the whole queue is
+// dropped right away.
+func (q *Queue) Push(data []byte, samples int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	size := int64(len(data))
+	if size > q.maxSize {
+		q.dropped += samples
+		return nil
+	}
+	for q.size+size > q.maxSize && len(q.batches) > 0 {
+		q.dropped += q.batches[0].samples
+		if err := q.removeFirst(); err != nil {
+			return err
+		}
+	}
+
+	q.seq++
+	b := batch{name: filepath.Join(q.dir, fmt.Sprintf("%020d.batch", q.seq)), size: size, samples: samples}
+	if err := afero.WriteFile(q.fs, b.name, data, 0600); err != nil {
+		q.dropped += samples
+		return errors.Wrap(err, "couldn't write to the disk queue")
+	}
+	q.batches = append(q.batches, b)
+	q.size += size
+	return nil
+}
+
+// Peek returns the batch at the front of the queue, or nil if it's empty.
+func (q *Queue) Peek() ([]byte, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.batches) == 0 {
+		return nil, nil
+	}
+	return afero.ReadFile(q.fs, q.batches[0].name)
+}
+
+// Pop removes the batch at the front of the queue, once it's been sent.
+func (q *Queue) Pop() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.batches) == 0 {
+		return nil
+	}
+	return q.removeFirst()
+}
+
+func (q *Queue) removeFirst() error {
+	b := q.batches[0]
+	q.batches = q.batches[1:]
+	q.size -= b.size
+	return q.fs.Remove(b.name)
+}
+
+// Len returns the number of batches in the queue.
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.batches)
+}
+
+// Samples returns the number of samples in the queue.
+func (q *Queue) Samples() int64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var n int64
+	for _, b := range q.batches {
+		n += b.samples
+	}
+	return n
+}
+
+// Dropped returns the number of samples that were dropped because the queue was full.
+func (q *Queue) Dropped() int64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dropped
+}
+
+// Close removes the queue from the disk, along with whatever is still in it.
+func (q *Queue) Close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.batches, q.size = nil, 0
+	return q.fs.RemoveAll(q.dir)
+}
@@ -0,0 +1,79 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package diskqueue
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_, err := New(fs, "/queue", 0)
+	assert.EqualError(t, err, "a disk queue needs a positive size")
+
+	q, err := New(fs, "/queue", 10)
+	require.NoError(t, err)
+
+	data, err := q.Peek()
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+
+	require.NoError(t, q.Push([]byte("aaaa"), 1))
+	require.NoError(t, q.Push([]byte("bbbb"), 2))
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, int64(3), q.Samples())
+
+	// There's no room for a third batch, so the first one makes room for it.
+	require.NoError(t, q.Push([]byte("cccc"), 3))
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, int64(5), q.Samples())
+	assert.Equal(t, int64(1), q.Dropped())
+
+	// A batch that's bigger than the queue never makes it in.
+	require.NoError(t, q.Push([]byte("dddddddddddd"), 4))
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, int64(5), q.Dropped())
+
+	data, err = q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "bbbb", string(data))
+	require.NoError(t, q.Pop())
+	data, err = q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "cccc", string(data))
+	require.NoError(t, q.Pop())
+	assert.Equal(t, 0, q.Len())
+	assert.NoError(t, q.Pop())
+
+	files, err := afero.ReadDir(fs, "/queue")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	require.NoError(t, q.Push([]byte("eeee"), 1))
+	require.NoError(t, q.Close())
+	exists, err := afero.DirExists(fs, "/queue")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,180 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// DNSTTLInfinite is the TTL of lookups that are cached for the whole test, written as "inf".
+const DNSTTLInfinite = types.Duration(-1)
+
+// The DNS settings used for whatever isn't set.
+var (
+	DefaultDNSTTL    = types.Duration(5 * time.Minute)
+	DefaultDNSSelect = netext.DNSRandom
+	DefaultDNSPolicy = netext.DNSPreferIPv4
+)
+
+// DNSConfig controls how hosts are resolved: how long lookups are cached, which of the IPs a host
+// resolves to a connection is made to, and which IP versions are used.
+type DNSConfig struct {
+	TTL    types.NullDuration
+	Select null.String
+	Policy null.String
+}
+
+// Apply returns the config with the fields set on the argument overwritten.
+func (c DNSConfig) Apply(cfg DNSConfig) DNSConfig {
+	if cfg.TTL.Valid {
+		c.TTL = cfg.TTL
+	}
+	if cfg.Select.Valid {
+		c.Select = cfg.Select
+	}
+	if cfg.Policy.Valid {
+		c.Policy = cfg.Policy
+	}
+	return c
+}
+
+// Settings returns what the resolver should do, filling in the defaults.
+func (c DNSConfig) Settings() (time.Duration, netext.DNSSelect, netext.DNSPolicy) {
+	ttl, sel, policy := DefaultDNSTTL, DefaultDNSSelect, DefaultDNSPolicy
+	if c.TTL.Valid {
+		ttl = c.TTL.Duration
+	}
+	if c.Select.Valid {
+		sel = netext.DNSSelect(c.Select.String)
+	}
+	if c.Policy.Valid {
+		policy = netext.DNSPolicy(c.Policy.String)
+	}
+	return time.Duration(ttl), sel, policy
+}
+
+func (c *DNSConfig) set(key, value string) error {
+	switch key {
+	case "ttl":
+		switch value {
+		case "inf":
+			c.TTL = types.NullDuration{Duration: DNSTTLInfinite, Valid: true}
+		default:
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return errors.Errorf("invalid DNS TTL: %s, it has to be a duration like 5m, 0 or inf", value)
+			}
+			c.TTL = types.NullDurationFrom(d)
+		}
+	case "select":
+		switch netext.DNSSelect(value) {
+		case netext.DNSFirst, netext.DNSRoundRobin, netext.DNSRandom:
+			c.Select = null.StringFrom(value)
+		default:
+			return errors.Errorf("invalid DNS select: %s, it can be first, roundRobin or random", value)
+		}
+	case "policy":
+		switch netext.DNSPolicy(value) {
+		case netext.DNSPreferIPv4, netext.DNSPreferIPv6, netext.DNSOnlyIPv4, netext.DNSOnlyIPv6, netext.DNSAny:
+			c.Policy = null.StringFrom(value)
+		default:
+			return errors.Errorf("invalid DNS policy: %s, it can be preferIPv4, preferIPv6, onlyIPv4, onlyIPv6 or any", value)
+		}
+	default:
+		return errors.Errorf("unknown DNS option: %s, they are ttl, select and policy", key)
+	}
+	return nil
+}
+
+// UnmarshalText reads the config from a list like "ttl=1m,select=roundRobin,policy=any", eg. from
+// the --dns flag or the K6_DNS environment variable.
+func (c *DNSConfig) UnmarshalText(data []byte) error {
+	var cfg DNSConfig
+	for _, kv := range strings.Split(string(data), ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("invalid DNS option: %s, it has to be key=value", kv)
+		}
+		if err := cfg.set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// UnmarshalJSON reads the config from an object, where the TTL is a duration string, "inf" or a
+// number of milliseconds.
+func (c *DNSConfig) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var cfg DNSConfig
+	for key, raw := range fields {
+		if bytes.Equal(raw, []byte(`null`)) {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			var ms float64
+			if key != "ttl" || json.Unmarshal(raw, &ms) != nil {
+				return errors.Errorf("invalid DNS %s: %s", key, raw)
+			}
+			value = time.Duration(ms * float64(time.Millisecond)).String()
+		}
+		if err := cfg.set(key, value); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// MarshalJSON writes the config as an object, leaving out what isn't set.
+func (c DNSConfig) MarshalJSON() ([]byte, error) {
+	fields := map[string]string{}
+	if c.TTL.Valid {
+		if c.TTL.Duration == DNSTTLInfinite {
+			fields["ttl"] = "inf"
+		} else {
+			fields["ttl"] = c.TTL.Duration.String()
+		}
+	}
+	if c.Select.Valid {
+		fields["select"] = c.Select.String
+	}
+	if c.Policy.Valid {
+		fields["policy"] = c.Policy.String
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,94 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestDNSConfig(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		var c DNSConfig
+		assert.NoError(t, c.UnmarshalText([]byte("ttl=1m, select=roundRobin,policy=onlyIPv6")))
+		assert.Equal(t, DNSConfig{
+			TTL:    types.NullDurationFrom(1 * time.Minute),
+			Select: null.StringFrom("roundRobin"),
+			Policy: null.StringFrom("onlyIPv6"),
+		}, c)
+
+		assert.NoError(t, c.UnmarshalText([]byte("ttl=inf")))
+		assert.Equal(t, DNSConfig{TTL: types.NullDuration{Duration: DNSTTLInfinite, Valid: true}}, c)
+
+		testdata := map[string]string{
+			"ttl=-1s":       "invalid DNS TTL: -1s, it has to be a duration like 5m, 0 or inf",
+			"select=best":   "invalid DNS select: best, it can be first, roundRobin or random",
+			"policy=ipv4":   "invalid DNS policy: ipv4, it can be preferIPv4, preferIPv6, onlyIPv4, onlyIPv6 or any",
+			"cache=1m":      "unknown DNS option: cache, they are ttl, select and policy",
+			"ttl":           "invalid DNS option: ttl, it has to be key=value",
+			"ttl=1m,select": "invalid DNS option: select, it has to be key=value",
+		}
+		for text, msg := range testdata {
+			assert.EqualError(t, c.UnmarshalText([]byte(text)), msg)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var c DNSConfig
+		assert.NoError(t, json.Unmarshal([]byte(`{"ttl": 1500, "select": "first", "policy": null}`), &c))
+		assert.Equal(t, DNSConfig{
+			TTL:    types.NullDurationFrom(1500 * time.Millisecond),
+			Select: null.StringFrom("first"),
+		}, c)
+
+		data, err := json.Marshal(c)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"ttl": "1.5s", "select": "first"}`, string(data))
+
+		assert.NoError(t, json.Unmarshal([]byte(`{"ttl": "inf"}`), &c))
+		data, err = json.Marshal(c)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"ttl": "inf"}`, string(data))
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"select": 1}`), &c), "invalid DNS select: 1")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"policy": "v4"}`), &c),
+			"invalid DNS policy: v4, it can be preferIPv4, preferIPv6, onlyIPv4, onlyIPv6 or any")
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		ttl, sel, policy := DNSConfig{}.Settings()
+		assert.Equal(t, 5*time.Minute, ttl)
+		assert.Equal(t, netext.DNSRandom, sel)
+		assert.Equal(t, netext.DNSPreferIPv4, policy)
+
+		c := DNSConfig{TTL: types.NullDurationFrom(0)}.Apply(DNSConfig{Select: null.StringFrom("first")})
+		ttl, sel, policy = c.Settings()
+		assert.Equal(t, time.Duration(0), ttl)
+		assert.Equal(t, netext.DNSFirst, sel)
+		assert.Equal(t, netext.DNSPreferIPv4, policy)
+	})
+}
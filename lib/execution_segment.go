@@ -0,0 +1,271 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// An ExecutionSegment is the part of a test, from one fraction of it to another, that an instance
+// of k6 runs, to split a test among several instances that each run their segment of it, eg.
+// 0:1/3, 1/3:2/3 and 2/3:1 for three of them.
+type ExecutionSegment struct {
+	from, to *big.Rat
+}
+
+// NewExecutionSegment returns the segment of a test from one fraction of it to another.
+func NewExecutionSegment(from, to *big.Rat) (*ExecutionSegment, error) {
+	if from.Sign() < 0 {
+		return nil, errors.Errorf("segment start can't be negative: %s", from.RatString())
+	}
+	if to.Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, errors.Errorf("segment end can't be more than 1: %s", to.RatString())
+	}
+	if from.Cmp(to) >= 0 {
+		return nil, errors.Errorf("segment start (%s) must be less than its end (%s)", from.RatString(), to.RatString())
+	}
+	return &ExecutionSegment{from: from, to: to}, nil
+}
+
+// ParseExecutionSegment parses a segment, written as "from:to", where either is a fraction
+// ("1/3"), a decimal ("0.5") or a percentage ("50%"), or as just its end, from 0.
+func ParseExecutionSegment(s string) (*ExecutionSegment, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) == 1 {
+		parts = []string{"0", parts[0]}
+	}
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid execution segment, expected from:to: %s", s)
+	}
+	from, err := parseSegmentPoint(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseSegmentPoint(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewExecutionSegment(from, to)
+}
+
+// parseSegmentPoint parses a fraction of a test.
+func parseSegmentPoint(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	percent := strings.HasSuffix(s, "%")
+	r, ok := new(big.Rat).SetString(strings.TrimSuffix(s, "%"))
+	if !ok {
+		return nil, errors.Errorf("invalid fraction of a test: %q", s)
+	}
+	if percent {
+		r.Quo(r, big.NewRat(100, 1))
+	}
+	return r, nil
+}
+
+// Valid returns whether the segment is set; the zero value isn't, and stands for the whole test.
+func (es ExecutionSegment) Valid() bool {
+	return es.from != nil
+}
+
+// From returns where the segment starts.
+func (es ExecutionSegment) From() *big.Rat {
+	return new(big.Rat).Set(es.from)
+}
+
+// To returns where the segment ends.
+func (es ExecutionSegment) To() *big.Rat {
+	return new(big.Rat).Set(es.to)
+}
+
+func (es ExecutionSegment) String() string {
+	if !es.Valid() {
+		return ""
+	}
+	return es.from.RatString() + ":" + es.to.RatString()
+}
+
+func (es ExecutionSegment) MarshalText() ([]byte, error) {
+	return []byte(es.String()), nil
+}
+
+func (es *ExecutionSegment) UnmarshalText(data []byte) error {
+	if len(data) == 0 {
+		*es = ExecutionSegment{}
+		return nil
+	}
+	seg, err := ParseExecutionSegment(string(data))
+	if err != nil {
+		return err
+	}
+	*es = *seg
+	return nil
+}
+
+// An ExecutionSegmentSequence is the points a test is split at, from 0 to 1, eg. 0,1/3,2/3,1; the
+// segments between them are what the instances running the test run. Instances that run segments
+// of the same sequence interleave their VUs and iterations the same way, so that none of them
+// overlap, and none are left out.
+type ExecutionSegmentSequence []*big.Rat
+
+// ParseExecutionSegmentSequence parses a sequence of comma separated points.
+func ParseExecutionSegmentSequence(s string) (ExecutionSegmentSequence, error) {
+	parts := strings.Split(s, ",")
+	seq := make(ExecutionSegmentSequence, len(parts))
+	for i, part := range parts {
+		r, err := parseSegmentPoint(part)
+		if err != nil {
+			return nil, err
+		}
+		seq[i] = r
+	}
+	if err := seq.Validate(); err != nil {
+		return nil, err
+	}
+	return seq, nil
+}
+
+// Validate checks that the sequence goes from 0 to 1, in order.
+func (ess ExecutionSegmentSequence) Validate() error {
+	if len(ess) < 2 {
+		return errors.New("an execution segment sequence needs at least 2 points")
+	}
+	if ess[0].Sign() != 0 || ess[len(ess)-1].Cmp(big.NewRat(1, 1)) != 0 {
+		return errors.Errorf("an execution segment sequence must go from 0 to 1: %s", ess)
+	}
+	for i := 1; i < len(ess); i++ {
+		if ess[i-1].Cmp(ess[i]) >= 0 {
+			return errors.Errorf("the points of an execution segment sequence must be in order: %s", ess)
+		}
+	}
+	return nil
+}
+
+// Contains returns whether the segment is one of the sequence's.
+func (ess ExecutionSegmentSequence) Contains(es *ExecutionSegment) bool {
+	for i := 1; i < len(ess); i++ {
+		if ess[i-1].Cmp(es.from) == 0 && ess[i].Cmp(es.to) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (ess ExecutionSegmentSequence) String() string {
+	points := make([]string, len(ess))
+	for i, r := range ess {
+		points[i] = r.RatString()
+	}
+	return strings.Join(points, ",")
+}
+
+func (ess ExecutionSegmentSequence) MarshalText() ([]byte, error) {
+	return []byte(ess.String()), nil
+}
+
+func (ess *ExecutionSegmentSequence) UnmarshalText(data []byte) error {
+	if len(data) == 0 {
+		*ess = nil
+		return nil
+	}
+	seq, err := ParseExecutionSegmentSequence(string(data))
+	if err != nil {
+		return err
+	}
+	*ess = seq
+	return nil
+}
+
+// An ExecutionTuple is an execution segment, in the context of its sequence. The VUs and the
+// iterations of a test are dealt out to the segments in a fixed pattern, repeated every period:
+// with the sequence 0,1/3,2/3,1, every third of them goes to each segment, and with 0,1/2,3/4,1,
+// the first segment gets 2 out of every 4, and the others 1 each. A nil tuple is the whole test.
+type ExecutionTuple struct {
+	Segment  *ExecutionSegment
+	Sequence ExecutionSegmentSequence
+
+	period, start, size int64
+}
+
+// NewExecutionTuple returns the tuple of a segment; without a sequence, the segment is taken as
+// one of 0,from,to,1. Instances that split a test without a sequence must be given segments with
+// the same denominators, eg. 0:1/4 and 1/4:1 rather than 0:1/4 and 1/4:1/2 and 1/2:1.
+func NewExecutionTuple(seg *ExecutionSegment, seq ExecutionSegmentSequence) (*ExecutionTuple, error) {
+	if seg == nil {
+		if seq != nil {
+			return nil, errors.New("an execution segment sequence needs an execution segment")
+		}
+		return nil, nil
+	}
+	if seq == nil {
+		seq = ExecutionSegmentSequence{big.NewRat(0, 1), seg.from, seg.to, big.NewRat(1, 1)}
+	} else if !seq.Contains(seg) {
+		return nil, errors.Errorf("execution segment %s isn't one of the sequence %s", seg, seq)
+	}
+
+	period := big.NewInt(1)
+	for _, r := range seq {
+		denom := r.Denom()
+		gcd := new(big.Int).GCD(nil, nil, period, denom)
+		period.Mul(period, new(big.Int).Quo(denom, gcd))
+	}
+	if !period.IsInt64() {
+		return nil, errors.Errorf("the execution segment sequence %s is too fine grained", seq)
+	}
+	p := new(big.Rat).SetInt(period)
+	start := new(big.Rat).Mul(seg.from, p)
+	end := new(big.Rat).Mul(seg.to, p)
+	return &ExecutionTuple{
+		Segment:  seg,
+		Sequence: seq,
+		period:   period.Int64(),
+		start:    start.Num().Int64(),
+		size:     end.Num().Int64() - start.Num().Int64(),
+	}, nil
+}
+
+// Scale returns how many out of n VUs, iterations, or anything else the test has, the segment
+// gets; the segments of a sequence add up to n exactly.
+func (et *ExecutionTuple) Scale(n int64) int64 {
+	if et == nil {
+		return n
+	}
+	return n/et.period*et.size + Min(Max(n%et.period-et.start, 0), et.size)
+}
+
+// GlobalIndex returns which of the test's VUs or iterations the segment's i-th one is, counting
+// from 0; different segments of a sequence never return the same one.
+func (et *ExecutionTuple) GlobalIndex(i int64) int64 {
+	if et == nil {
+		return i
+	}
+	return i/et.size*et.period + et.start + i%et.size
+}
+
+func (et *ExecutionTuple) String() string {
+	if et == nil {
+		return "0:1"
+	}
+	return fmt.Sprintf("%s of %s", et.Segment, et.Sequence)
+}
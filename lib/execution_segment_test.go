@@ -0,0 +1,230 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestParseExecutionSegment(t *testing.T) {
+	testdata := map[string]struct {
+		String string
+		Err    string
+	}{
+		"0:1/3":    {"0:1/3", ""},
+		"1/3:2/3":  {"1/3:2/3", ""},
+		"0.5:1":    {"1/2:1", ""},
+		"25%:75%":  {"1/4:3/4", ""},
+		"1/2":      {"0:1/2", ""},
+		"1":        {"0:1", ""},
+		"a:1":      {"", `invalid fraction of a test: "a"`},
+		"0:1:2":    {"", "invalid execution segment, expected from:to: 0:1:2"},
+		"-1/2:1/2": {"", "segment start can't be negative: -1/2"},
+		"0:3/2":    {"", "segment end can't be more than 1: 3/2"},
+		"1/2:1/3":  {"", "segment start (1/2) must be less than its end (1/3)"},
+		"1/2:1/2":  {"", "segment start (1/2) must be less than its end (1/2)"},
+	}
+	for s, data := range testdata {
+		t.Run(s, func(t *testing.T) {
+			seg, err := ParseExecutionSegment(s)
+			if data.Err != "" {
+				assert.EqualError(t, err, data.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, data.String, seg.String())
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var opts Options
+		require.NoError(t, json.Unmarshal([]byte(`{"executionSegment":"1/3:2/3","executionSegmentSequence":"0,1/3,2/3,1"}`), &opts))
+		assert.Equal(t, "1/3:2/3", opts.ExecutionSegment.String())
+		assert.Equal(t, "0,1/3,2/3,1", opts.ExecutionSegmentSequence.String())
+
+		data, err := json.Marshal(opts)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"executionSegment":"1/3:2/3","executionSegmentSequence":"0,1/3,2/3,1"`)
+	})
+	t.Run("Env", func(t *testing.T) {
+		os.Clearenv()
+		require.NoError(t, os.Setenv("K6_EXECUTION_SEGMENT", "0:1/2"))
+		require.NoError(t, os.Setenv("K6_EXECUTION_SEGMENT_SEQUENCE", "0,1/2,1"))
+		var opts Options
+		require.NoError(t, envconfig.Process("k6", &opts))
+		assert.Equal(t, "0:1/2", opts.ExecutionSegment.String())
+		assert.Equal(t, "0,1/2,1", opts.ExecutionSegmentSequence.String())
+	})
+}
+
+func TestParseExecutionSegmentSequence(t *testing.T) {
+	testdata := map[string]string{
+		"0,1/3,2/3,1": "",
+		"0,0.5,1":     "",
+		"0,1":         "",
+		"1":           "an execution segment sequence needs at least 2 points",
+		"0,1/2":       "an execution segment sequence must go from 0 to 1: 0,1/2",
+		"1/4,1":       "an execution segment sequence must go from 0 to 1: 1/4,1",
+		"0,1/2,1/4,1": "the points of an execution segment sequence must be in order: 0,1/2,1/4,1",
+		"0,a,1":       `invalid fraction of a test: "a"`,
+	}
+	for s, errMsg := range testdata {
+		t.Run(s, func(t *testing.T) {
+			_, err := ParseExecutionSegmentSequence(s)
+			if errMsg != "" {
+				assert.EqualError(t, err, errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExecutionTuple(t *testing.T) {
+	tuples := func(t *testing.T, seq string) []*ExecutionTuple {
+		sequence, err := ParseExecutionSegmentSequence(seq)
+		require.NoError(t, err)
+		var ets []*ExecutionTuple
+		for i := 1; i < len(sequence); i++ {
+			seg, err := NewExecutionSegment(sequence[i-1], sequence[i])
+			require.NoError(t, err)
+			et, err := NewExecutionTuple(seg, sequence)
+			require.NoError(t, err)
+			ets = append(ets, et)
+		}
+		return ets
+	}
+
+	for _, seq := range []string{"0,1", "0,1/2,1", "0,1/3,2/3,1", "0,1/2,3/4,1", "0,0.1,0.35,1"} {
+		t.Run(seq, func(t *testing.T) {
+			ets := tuples(t, seq)
+			// The segments' shares add up to the whole, and never overlap.
+			for n := int64(0); n < 50; n++ {
+				var sum int64
+				seen := make(map[int64]bool)
+				for _, et := range ets {
+					scaled := et.Scale(n)
+					sum += scaled
+					for i := int64(0); i < scaled; i++ {
+						idx := et.GlobalIndex(i)
+						assert.True(t, idx >= 0 && idx < n, "index %d of %d out of range: %d", i, n, idx)
+						assert.False(t, seen[idx], "index %d seen twice", idx)
+						seen[idx] = true
+					}
+				}
+				assert.Equal(t, n, sum)
+			}
+		})
+	}
+
+	t.Run("Interleaved", func(t *testing.T) {
+		ets := tuples(t, "0,1/2,3/4,1")
+		assert.Equal(t, []int64{0, 1, 4, 5}, []int64{ets[0].GlobalIndex(0), ets[0].GlobalIndex(1), ets[0].GlobalIndex(2), ets[0].GlobalIndex(3)})
+		assert.Equal(t, []int64{2, 6}, []int64{ets[1].GlobalIndex(0), ets[1].GlobalIndex(1)})
+		assert.Equal(t, []int64{3, 7}, []int64{ets[2].GlobalIndex(0), ets[2].GlobalIndex(1)})
+		assert.Equal(t, []int64{6, 2, 2}, []int64{ets[0].Scale(10), ets[1].Scale(10), ets[2].Scale(10)})
+	})
+	t.Run("Nil", func(t *testing.T) {
+		et, err := NewExecutionTuple(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), et.Scale(10))
+		assert.Equal(t, int64(3), et.GlobalIndex(3))
+	})
+	t.Run("NoSequence", func(t *testing.T) {
+		seg, err := ParseExecutionSegment("1/3:2/3")
+		require.NoError(t, err)
+		et, err := NewExecutionTuple(seg, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), et.Scale(10))
+		assert.Equal(t, int64(4), et.GlobalIndex(1))
+	})
+	t.Run("NotInSequence", func(t *testing.T) {
+		seg, err := ParseExecutionSegment("0:1/2")
+		require.NoError(t, err)
+		seq, err := ParseExecutionSegmentSequence("0,1/3,2/3,1")
+		require.NoError(t, err)
+		_, err = NewExecutionTuple(seg, seq)
+		assert.EqualError(t, err, "execution segment 0:1/2 isn't one of the sequence 0,1/3,2/3,1")
+	})
+	t.Run("NoSegment", func(t *testing.T) {
+		seq, err := ParseExecutionSegmentSequence("0,1/3,2/3,1")
+		require.NoError(t, err)
+		_, err = NewExecutionTuple(nil, seq)
+		assert.EqualError(t, err, "an execution segment sequence needs an execution segment")
+	})
+}
+
+func TestOptionsSegment(t *testing.T) {
+	seg, err := ParseExecutionSegment("1/2:1")
+	require.NoError(t, err)
+	et, err := NewExecutionTuple(seg, nil)
+	require.NoError(t, err)
+
+	t.Run("Options", func(t *testing.T) {
+		opts := Options{
+			VUs:        null.IntFrom(5),
+			VUsMax:     null.NewInt(10, false),
+			Iterations: null.IntFrom(7),
+			Stages:     []Stage{{Duration: types.NullDurationFrom(time.Second), Target: null.IntFrom(3)}},
+		}.Segment(et)
+		assert.Equal(t, null.IntFrom(2), opts.VUs)
+		assert.Equal(t, null.NewInt(5, false), opts.VUsMax)
+		assert.Equal(t, null.IntFrom(3), opts.Iterations)
+		assert.Equal(t, null.IntFrom(1), opts.Stages[0].Target)
+	})
+	t.Run("Scenarios", func(t *testing.T) {
+		second := types.NullDurationFrom(time.Second)
+		scenarios := Options{Scenarios: Scenarios{
+			"constant": {Executor: ConstantVUsExecutor, VUs: null.IntFrom(3), Duration: second, IterationRate: null.IntFrom(5), RPS: null.IntFrom(1)},
+			"single":   {Executor: ConstantVUsExecutor, Duration: second},
+			"after":    {Executor: SharedIterationsExecutor, VUs: null.IntFrom(4), Iterations: null.IntFrom(5), StartAfter: null.StringFrom("single")},
+			"rate":     {Executor: ConstantArrivalRateExecutor, Rate: null.IntFrom(5), Duration: second, PreAllocatedVUs: null.IntFrom(1), MaxVUs: null.IntFrom(4)},
+			"ramping": {Executor: RampingVUsExecutor, StartVUs: null.IntFrom(0), Stages: []Stage{
+				{Duration: second, Target: null.IntFrom(4)},
+				{Duration: second, Target: null.IntFrom(1)},
+			}},
+		}}.Segment(et).Scenarios
+
+		// The first VU, and the single iteration, go to the first half.
+		assert.NotContains(t, scenarios, "single")
+		assert.Equal(t, null.IntFrom(1), scenarios["constant"].VUs)
+		assert.Equal(t, null.IntFrom(2), scenarios["constant"].IterationRate)
+		assert.Equal(t, null.IntFrom(1), scenarios["constant"].RPS)
+		assert.Equal(t, null.IntFrom(2), scenarios["after"].VUs)
+		assert.Equal(t, null.IntFrom(2), scenarios["after"].Iterations)
+		assert.False(t, scenarios["after"].StartAfter.Valid)
+		assert.Equal(t, types.NullDurationFrom(time.Second), scenarios["after"].StartTime)
+		assert.Equal(t, null.IntFrom(2), scenarios["rate"].Rate)
+		assert.Equal(t, null.IntFrom(1), scenarios["rate"].PreAllocatedVUs)
+		assert.Equal(t, null.IntFrom(2), scenarios["rate"].MaxVUs)
+		assert.Equal(t, null.IntFrom(2), scenarios["ramping"].Stages[0].Target)
+		assert.Equal(t, null.IntFrom(0), scenarios["ramping"].Stages[1].Target)
+	})
+}
@@ -53,6 +53,11 @@ type Executor interface {
 	GetStages() []Stage
 	SetStages(s []Stage)
 
+	// Get and set the scenarios the test is made of. A test with scenarios ignores the stages, end
+	// time and end iterations, and its VU count can't be changed from the outside.
+	GetScenarios() Scenarios
+	SetScenarios(s Scenarios)
+
 	// Get iterations executed so far, get and set how many to end the test after.
 	GetIterations() int64
 	GetEndIterations() null.Int
@@ -84,4 +89,111 @@ type Executor interface {
 	// Set whether or not to run setup/teardown phases. Default is to run all of them.
 	SetRunSetup(r bool)
 	SetRunTeardown(r bool)
+
+	// Abort a running test from within, eg. from a script. The test stops the same way it does
+	// when it reaches its end, except that Run returns the given error. Only the first call for
+	// a test has any effect.
+	Abort(err error)
+}
+
+// ScriptAbortError is what a test that the script aborted, eg. with execution.abort(), fails with.
+type ScriptAbortError struct {
+	Reason string
+}
+
+func (e ScriptAbortError) Error() string {
+	if e.Reason == "" {
+		return "test aborted"
+	}
+	return "test aborted: " + e.Reason
+}
+
+// Progress describes how far along a test is, going by its end conditions.
+type Progress struct {
+	// Time elapsed so far, not counting pauses.
+	Elapsed time.Duration
+
+	// Estimated time until the test ends; invalid if the test has no end condition. When only an
+	// iteration count ends the test, this is extrapolated from the rate of iterations so far.
+	Remaining types.NullDuration
+
+	// Fraction of the test that's done, in the range [0.0 - 1.0]; invalid if the test has no end.
+	Fraction null.Float
+}
+
+// ScenarioProgress describes how far along one of the scenarios of a running test is.
+type ScenarioProgress struct {
+	Name     string
+	Executor string
+
+	// VUs running the scenario right now, and iterations it has finished so far.
+	VUs        int64
+	Iterations int64
+
+	// Time since the scenario started, and the estimated time until it ends; like the
+	// Progress of the test, this is extrapolated from its iterations if they end it sooner.
+	Elapsed   time.Duration
+	Remaining types.NullDuration
+
+	// Fraction of the scenario that's done, in the range [0.0 - 1.0].
+	Fraction float64
+
+	Started, Done bool
+}
+
+// A ScenarioProgressReporter is an executor that can tell how far along each of the scenarios of
+// its test is; the CLI draws a progress bar for every one of them.
+type ScenarioProgressReporter interface {
+	GetScenarioProgress() []ScenarioProgress
+}
+
+// GetEndTime returns when an executor's test ends, going by whichever of its end time, the end of
+// its stages and the end of its scenarios comes first; it's invalid if none of them ends it.
+func GetEndTime(ex Executor) types.NullDuration {
+	end := ex.GetEndTime()
+	if stages := ex.GetStages(); len(stages) > 0 {
+		if stagesEnd := SumStages(stages); stagesEnd.Valid && (!end.Valid || stagesEnd.Duration < end.Duration) {
+			end = stagesEnd
+		}
+	}
+	if scenarios := ex.GetScenarios(); len(scenarios) > 0 {
+		if d := scenarios.GetDuration(); !end.Valid || types.Duration(d) < end.Duration {
+			end = types.NullDurationFrom(d)
+		}
+	}
+	return end
+}
+
+// GetProgress works out how far along an executor's test is. The test ends at whichever of the
+// end time, the end of the stages or scenarios and the end iteration count is reached first.
+func GetProgress(ex Executor) Progress {
+	p := Progress{Elapsed: ex.GetTime()}
+
+	end := GetEndTime(ex)
+	if end.Valid {
+		d := time.Duration(end.Duration)
+		p.Remaining = types.NullDurationFrom(time.Duration(Max(0, int64(d-p.Elapsed))))
+		if d > 0 {
+			p.Fraction = null.FloatFrom(Clampf(float64(p.Elapsed)/float64(d), 0.0, 1.0))
+		} else {
+			p.Fraction = null.FloatFrom(1.0)
+		}
+	}
+
+	if endIters := ex.GetEndIterations(); endIters.Valid {
+		f := 1.0
+		if endIters.Int64 > 0 {
+			f = Clampf(float64(ex.GetIterations())/float64(endIters.Int64), 0.0, 1.0)
+		}
+		if !p.Fraction.Valid || f > p.Fraction.Float64 {
+			p.Fraction = null.FloatFrom(f)
+			if f > 0 {
+				remaining := time.Duration(float64(p.Elapsed) * (1 - f) / f)
+				if !p.Remaining.Valid || remaining < time.Duration(p.Remaining.Duration) {
+					p.Remaining = types.NullDurationFrom(remaining)
+				}
+			}
+		}
+	}
+	return p
 }
@@ -0,0 +1,155 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+)
+
+// IPPool is a set of IP ranges. It de/serialises to JSON as a list of IPs and CIDR ranges.
+type IPPool []*net.IPNet
+
+func (p IPPool) MarshalJSON() ([]byte, error) {
+	strs := make([]string, len(p))
+	for i, n := range p {
+		strs[i] = n.String()
+	}
+	return json.Marshal(strs)
+}
+
+func (p *IPPool) UnmarshalJSON(data []byte) error {
+	var strs []string
+	if err := json.Unmarshal(data, &strs); err != nil {
+		return err
+	}
+	pool := make(IPPool, len(strs))
+	for i, str := range strs {
+		if !strings.Contains(str, "/") {
+			ip := net.ParseIP(str)
+			if ip == nil {
+				return errors.Errorf("invalid IP: %s", str)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			pool[i] = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(str)
+		if err != nil {
+			return err
+		}
+		pool[i] = ipnet
+	}
+	*p = pool
+	return nil
+}
+
+// Pick deterministically picks an address from the pool for a VU.
+func (p IPPool) Pick(vuID int64) net.IP {
+	if len(p) == 0 {
+		return nil
+	}
+	ipnet := p[int(vuID%int64(len(p)))]
+	ones, bits := ipnet.Mask.Size()
+	hostBits := uint(bits - ones)
+	if hostBits > 32 {
+		hostBits = 32
+	}
+
+	// Scatter the VUs over the range with a multiplicative hash.
+	offset := (uint64(vuID) * 2654435761) % (uint64(1) << hostBits)
+	n := new(big.Int).SetBytes(ipnet.IP)
+	n.Add(n, new(big.Int).SetUint64(offset))
+	b := n.Bytes()
+	ip := make(net.IP, len(ipnet.IP))
+	copy(ip[len(ip)-len(b):], b)
+	return ip
+}
+
+// A GeoRegion approximates users in a geographical region: VUs assigned to it send its headers
+// and an X-Forwarded-For address from its pool, and their network traffic is delayed by its latency.
+type GeoRegion struct {
+	// Share of the VUs assigned to the region.
+	Weight float64 `json:"weight"`
+
+	// Headers sent with every HTTP request, eg. Accept-Language.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Addresses to pick the X-Forwarded-For header of each VU from.
+	ForwardedFor IPPool `json:"forwardedFor,omitempty"`
+
+	// Latency added to every network round trip.
+	Latency types.Duration `json:"latency,omitempty"`
+}
+
+// GeoRegions maps region names to their definitions.
+type GeoRegions map[string]*GeoRegion
+
+func (r *GeoRegions) UnmarshalJSON(data []byte) error {
+	var regions map[string]*GeoRegion
+	if err := json.Unmarshal(data, &regions); err != nil {
+		return err
+	}
+	total := 0.0
+	for name, region := range regions {
+		if region == nil {
+			return errors.Errorf("region %s is empty", name)
+		}
+		if region.Weight < 0 || math.IsNaN(region.Weight) || math.IsInf(region.Weight, 0) {
+			return errors.Errorf("invalid weight for region %s: %v", name, region.Weight)
+		}
+		if region.Latency < 0 {
+			return errors.Errorf("invalid latency for region %s: %s", name, region.Latency)
+		}
+		total += region.Weight
+	}
+	if len(regions) > 0 && total <= 0 {
+		return errors.New("the total weight of all regions must be positive")
+	}
+	*r = regions
+	return nil
+}
+
+// Pick deterministically assigns a VU to a region, and returns the region's name.
+func (r GeoRegions) Pick(vuID int64) (string, *GeoRegion) {
+	names := make([]string, 0, len(r))
+	weights := make(map[string]float64, len(r))
+	for name, region := range r {
+		names = append(names, name)
+		weights[name] = region.Weight
+	}
+	sort.Strings(names)
+	name := SpreadPick(vuID, math.Sqrt2-1, names, weights)
+	if name == "" {
+		return "", nil
+	}
+	return name, r[name]
+}
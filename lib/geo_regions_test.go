@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPPool(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var pool IPPool
+		require.NoError(t, json.Unmarshal([]byte(`["192.0.2.1", "198.51.100.0/24", "2001:db8::/64"]`), &pool))
+		require.Len(t, pool, 3)
+		assert.Equal(t, "192.0.2.1/32", pool[0].String())
+		assert.Equal(t, "198.51.100.0/24", pool[1].String())
+		assert.Equal(t, "2001:db8::/64", pool[2].String())
+
+		data, err := json.Marshal(pool)
+		require.NoError(t, err)
+		assert.JSONEq(t, `["192.0.2.1/32", "198.51.100.0/24", "2001:db8::/64"]`, string(data))
+
+		assert.EqualError(t, json.Unmarshal([]byte(`["nope"]`), &pool), "invalid IP: nope")
+		assert.Error(t, json.Unmarshal([]byte(`["10.0.0.0/33"]`), &pool))
+	})
+
+	t.Run("Pick", func(t *testing.T) {
+		var pool IPPool
+		require.NoError(t, json.Unmarshal([]byte(`["192.0.2.7", "198.51.100.0/24", "2001:db8::/64"]`), &pool))
+		seen := map[string]bool{}
+		for id := int64(0); id < 30; id++ {
+			ip := pool.Pick(id)
+			assert.True(t, pool[id%3].Contains(ip), "%s not in %s", ip, pool[id%3])
+			assert.Equal(t, ip, pool.Pick(id))
+			seen[ip.String()] = true
+		}
+		assert.True(t, seen["192.0.2.7"])
+		assert.True(t, len(seen) > 10)
+
+		assert.Len(t, pool.Pick(0), net.IPv4len)
+		assert.Len(t, pool.Pick(2), net.IPv6len)
+		assert.Nil(t, IPPool(nil).Pick(1))
+	})
+}
+
+func TestGeoRegions(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var regions GeoRegions
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"eu-west": {"weight": 2, "headers": {"Accept-Language": "de-DE"}, "forwardedFor": ["192.0.2.0/24"], "latency": "30ms"},
+			"ap-south": {"weight": 1, "latency": "150ms"}
+		}`), &regions))
+		require.Len(t, regions, 2)
+		assert.Equal(t, 2.0, regions["eu-west"].Weight)
+		assert.Equal(t, map[string]string{"Accept-Language": "de-DE"}, regions["eu-west"].Headers)
+		assert.Equal(t, types.Duration(30*time.Millisecond), regions["eu-west"].Latency)
+		assert.Equal(t, types.Duration(150*time.Millisecond), regions["ap-south"].Latency)
+		assert.Empty(t, regions["ap-south"].ForwardedFor)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"eu": {"weight": -1}}`), &regions), "invalid weight for region eu: -1")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"eu": {"weight": 1, "latency": "-1s"}}`), &regions), "invalid latency for region eu: -1s")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"eu": {"weight": 0}}`), &regions), "the total weight of all regions must be positive")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"eu": null}`), &regions), "region eu is empty")
+	})
+
+	t.Run("Pick", func(t *testing.T) {
+		regions := GeoRegions{"eu": {Weight: 1}, "us": {Weight: 3}, "ap": {Weight: 0}}
+		counts := map[string]int{}
+		for id := int64(1); id <= 100; id++ {
+			name, region := regions.Pick(id)
+			assert.Equal(t, regions[name], region)
+			counts[name]++
+		}
+		assert.InDelta(t, 25, counts["eu"], 3)
+		assert.InDelta(t, 75, counts["us"], 3)
+		assert.Zero(t, counts["ap"])
+
+		name, region := GeoRegions(nil).Pick(1)
+		assert.Equal(t, "", name)
+		assert.Nil(t, region)
+	})
+}
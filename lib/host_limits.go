@@ -0,0 +1,80 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// A HostLimit caps the requests to a host, across all VUs, batch() and regular requests alike.
+type HostLimit struct {
+	// How many requests may be in flight at once, ie. how many connections may be busy.
+	MaxConnections null.Int `json:"maxConnections"`
+
+	// How many requests may start per second.
+	RPS null.Int `json:"rps"`
+}
+
+// HostLimits are the limits of hosts, by hostname or wildcard, like *.example.com, which limits
+// each of the subdomains it matches separately. Hostnames take precedence over wildcards, and longer
+// wildcards over shorter ones.
+type HostLimits map[string]HostLimit
+
+// UnmarshalJSON reads the limits from an object of hostnames to limits, validating them.
+func (l *HostLimits) UnmarshalJSON(data []byte) error {
+	var fields map[string]HostLimit
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if fields == nil {
+		*l = nil
+		return nil
+	}
+	limits := make(HostLimits, len(fields))
+	for name, limit := range fields {
+		name = strings.ToLower(name)
+		if err := types.ValidateHostname(name); err != nil {
+			return err
+		}
+		if limit.MaxConnections.Int64 < 0 || limit.RPS.Int64 < 0 {
+			return errors.Errorf("host limit %s: limits can't be negative", name)
+		}
+		limits[name] = limit
+	}
+	*l = limits
+	return nil
+}
+
+// Limit returns the limit of a host, if it has any.
+func (l HostLimits) Limit(hostname string) (netext.HostLimit, bool) {
+	name, ok := types.MatchHostname(hostname, func(name string) bool { _, ok := l[name]; return ok })
+	if !ok {
+		return netext.HostLimit{}, false
+	}
+	limit := l[name]
+	return netext.HostLimit{MaxConnections: limit.MaxConnections.Int64, RPS: limit.RPS.Int64}, true
+}
@@ -0,0 +1,54 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimits(t *testing.T) {
+	var limits HostLimits
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"API.example.com": { "rps": 50, "maxConnections": 10 },
+		"*.example.com": { "maxConnections": 100 }
+	}`), &limits))
+
+	limit, ok := limits.Limit("api.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, netext.HostLimit{MaxConnections: 10, RPS: 50}, limit)
+
+	limit, ok = limits.Limit("cdn.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, netext.HostLimit{MaxConnections: 100}, limit)
+
+	_, ok = limits.Limit("example.com")
+	assert.False(t, ok)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`{"example.com": {"rps": -1}}`), &limits),
+		"host limit example.com: limits can't be negative")
+	assert.EqualError(t, json.Unmarshal([]byte(`{"*": {"rps": 1}}`), &limits),
+		"invalid hostname: *, wildcards can only be at the start, like *.example.com")
+}
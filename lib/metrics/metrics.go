@@ -21,7 +21,10 @@
 package metrics
 
 import (
+	"strings"
+
 	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
 )
 
 //TODO: refactor this, using non thread-safe global variables seems like a bad idea for various reasons...
@@ -31,6 +34,7 @@ var (
 	VUs               = stats.New("vus", stats.Gauge)
 	VUsMax            = stats.New("vus_max", stats.Gauge)
 	Iterations        = stats.New("iterations", stats.Counter)
+	DroppedIterations = stats.New("dropped_iterations", stats.Counter)
 	IterationDuration = stats.New("iteration_duration", stats.Trend, stats.Time)
 	Errors            = stats.New("errors", stats.Counter)
 
@@ -40,6 +44,7 @@ var (
 
 	// HTTP-related.
 	HTTPReqs              = stats.New("http_reqs", stats.Counter)
+	HTTPReqFailed         = stats.New("http_req_failed", stats.Rate)
 	HTTPReqDuration       = stats.New("http_req_duration", stats.Trend, stats.Time)
 	HTTPReqBlocked        = stats.New("http_req_blocked", stats.Trend, stats.Time)
 	HTTPReqConnecting     = stats.New("http_req_connecting", stats.Trend, stats.Time)
@@ -56,7 +61,73 @@ var (
 	WSSessionDuration  = stats.New("ws_session_duration", stats.Trend, stats.Time)
 	WSConnecting       = stats.New("ws_connecting", stats.Trend, stats.Time)
 
+	// Server-Sent Events-related.
+	SSESessions         = stats.New("sse_sessions", stats.Counter)
+	SSEEventsReceived   = stats.New("sse_events_received", stats.Counter)
+	SSESessionDuration  = stats.New("sse_session_duration", stats.Trend, stats.Time)
+	SSEConnecting       = stats.New("sse_connecting", stats.Trend, stats.Time)
+	SSETimeToFirstEvent = stats.New("sse_time_to_first_event", stats.Trend, stats.Time)
+
+	// Redis-related.
+	RedisCommands        = stats.New("redis_commands", stats.Counter)
+	RedisCommandDuration = stats.New("redis_command_duration", stats.Trend, stats.Time)
+
+	// SQL-related.
+	SQLQueries       = stats.New("sql_queries", stats.Counter)
+	SQLQueryDuration = stats.New("sql_query_duration", stats.Trend, stats.Time)
+
+	// AMQP-related.
+	AMQPMessagesPublished = stats.New("amqp_messages_published", stats.Counter)
+	AMQPMessagesReceived  = stats.New("amqp_messages_received", stats.Counter)
+
 	// Network-related; used for future protocols as well.
 	DataSent     = stats.New("data_sent", stats.Counter, stats.Data)
 	DataReceived = stats.New("data_received", stats.Counter, stats.Data)
 )
+
+// builtin has every one of the metrics above, by name.
+var builtin = func() map[string]*stats.Metric {
+	all := []*stats.Metric{
+		VUs, VUsMax, Iterations, DroppedIterations, IterationDuration, Errors,
+		Checks, GroupDuration,
+		HTTPReqs, HTTPReqFailed, HTTPReqDuration, HTTPReqBlocked, HTTPReqConnecting, HTTPReqSending,
+		HTTPReqWaiting, HTTPReqReceiving, HTTPReqTLSHandshaking,
+		WSSessions, WSMessagesSent, WSMessagesReceived, WSPing, WSSessionDuration, WSConnecting,
+		SSESessions, SSEEventsReceived, SSESessionDuration, SSEConnecting, SSETimeToFirstEvent,
+		RedisCommands, RedisCommandDuration,
+		SQLQueries, SQLQueryDuration,
+		AMQPMessagesPublished, AMQPMessagesReceived,
+		DataSent, DataReceived,
+	}
+	m := make(map[string]*stats.Metric, len(all))
+	for _, metric := range all {
+		m[metric.Name] = metric
+	}
+	return m
+}()
+
+// Get returns the built-in metric with a name, or nil if there's none; it's how the types of
+// metrics are known when reading results that don't have them, like CSV files.
+func Get(name string) *stats.Metric {
+	return builtin[name]
+}
+
+// ValidateThresholds makes sure the thresholds on built-in metrics, and on their submetrics, only
+// use what's available on the metric's type. The types of custom metrics aren't known before they
+// get their first sample.
+func ValidateThresholds(thresholds map[string]stats.Thresholds) error {
+	for name, ths := range thresholds {
+		parent := name
+		if i := strings.Index(name, "{"); i >= 0 {
+			parent = name[:i]
+		}
+		m := Get(strings.TrimSpace(parent))
+		if m == nil {
+			continue
+		}
+		if err := ths.Validate(m.Type); err != nil {
+			return errors.Wrapf(err, "invalid thresholds on %s", name)
+		}
+	}
+	return nil
+}
@@ -23,6 +23,10 @@ package netext
 import (
 	"context"
 	"net/http/httptrace"
+	"net/url"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
 )
 
 type ctxKey int
@@ -30,6 +34,11 @@ type ctxKey int
 const (
 	ctxKeyTracer ctxKey = iota
 	ctxKeyAuth
+	ctxKeyClientCertificates
+	ctxKeyProxy
+	ctxKeyConnectTimeout
+	ctxKeyTLSHandshakeTimeout
+	ctxKeyHTTPVersion
 )
 
 func WithTracer(ctx context.Context, tracer *Tracer) context.Context {
@@ -49,3 +58,66 @@ func GetAuth(ctx context.Context) string {
 	}
 	return v.(string)
 }
+
+func WithClientCertificates(ctx context.Context, certs []ClientCertificate) context.Context {
+	return context.WithValue(ctx, ctxKeyClientCertificates, certs)
+}
+
+func GetClientCertificates(ctx context.Context) []ClientCertificate {
+	v := ctx.Value(ctxKeyClientCertificates)
+	if v == nil {
+		return nil
+	}
+	return v.([]ClientCertificate)
+}
+
+// WithProxy makes the requests made with the context go through the given proxy instead of the
+// transport's; a nil URL is no proxy.
+func WithProxy(ctx context.Context, proxy *url.URL) context.Context {
+	return context.WithValue(ctx, ctxKeyProxy, proxy)
+}
+
+// GetProxy returns the proxy set with WithProxy, and whether one was.
+func GetProxy(ctx context.Context) (*url.URL, bool) {
+	v := ctx.Value(ctxKeyProxy)
+	if v == nil {
+		return nil, false
+	}
+	return v.(*url.URL), true
+}
+
+// WithConnectTimeout limits how long the connections dialed for requests made with the context
+// can take to connect, instead of the dialer's timeout.
+func WithConnectTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, ctxKeyConnectTimeout, timeout)
+}
+
+// GetConnectTimeout returns the timeout set with WithConnectTimeout, and whether one was.
+func GetConnectTimeout(ctx context.Context) (time.Duration, bool) {
+	v, ok := ctx.Value(ctxKeyConnectTimeout).(time.Duration)
+	return v, ok
+}
+
+// WithTLSHandshakeTimeout limits how long the TLS handshakes of requests made with the context
+// can take; requests whose handshake takes longer fail.
+func WithTLSHandshakeTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, ctxKeyTLSHandshakeTimeout, timeout)
+}
+
+// GetTLSHandshakeTimeout returns the timeout set with WithTLSHandshakeTimeout, and whether one was.
+func GetTLSHandshakeTimeout(ctx context.Context) (time.Duration, bool) {
+	v, ok := ctx.Value(ctxKeyTLSHandshakeTimeout).(time.Duration)
+	return v, ok
+}
+
+// WithHTTPVersion makes the requests made with the context use the given HTTP version instead of
+// the transport's.
+func WithHTTPVersion(ctx context.Context, version types.HTTPVersion) context.Context {
+	return context.WithValue(ctx, ctxKeyHTTPVersion, version)
+}
+
+// GetHTTPVersion returns the version set with WithHTTPVersion, and whether one was.
+func GetHTTPVersion(ctx context.Context) (types.HTTPVersion, bool) {
+	v, ok := ctx.Value(ctxKeyHTTPVersion).(types.HTTPVersion)
+	return v, ok
+}
@@ -0,0 +1,189 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A CookieJar is a cookie jar that keeps track of the cookies set in it, which the standard
+// library's jar doesn't expose, so they can be exported and imported into another jar.
+type CookieJar struct {
+	*cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies map[string]JarCookie
+}
+
+// A JarCookie is a cookie as it's stored in a jar, with its domain and path resolved.
+type JarCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	HostOnly bool   `json:"host_only"`
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"http_only"`
+	// Unix timestamp in milliseconds, or 0 for session cookies.
+	Expires int64 `json:"expires"`
+}
+
+func (c JarCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// url returns a URL that the cookie is sent to.
+func (c JarCookie) url() *url.URL {
+	scheme := "http"
+	if c.Secure {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: c.Domain, Path: c.Path}
+}
+
+func NewCookieJar() (*CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieJar{Jar: jar, cookies: make(map[string]JarCookie)}, nil
+}
+
+// SetCookies stores cookies received from a URL, like the standard library's jar does.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		jc := JarCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   strings.ToLower(strings.TrimPrefix(c.Domain, ".")),
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if jc.Domain == "" {
+			jc.Domain = strings.ToLower(u.Hostname())
+			jc.HostOnly = true
+		}
+		if jc.Path == "" || jc.Path[0] != '/' {
+			jc.Path = defaultCookiePath(u.Path)
+		}
+
+		// Cookies that expired, or are expired on purpose to delete them, are dropped.
+		switch {
+		case c.MaxAge < 0:
+			delete(j.cookies, jc.key())
+			continue
+		case c.MaxAge > 0:
+			jc.Expires = now.Add(time.Duration(c.MaxAge)*time.Second).UnixNano() / int64(time.Millisecond)
+		case !c.Expires.IsZero():
+			if !c.Expires.After(now) {
+				delete(j.cookies, jc.key())
+				continue
+			}
+			jc.Expires = c.Expires.UnixNano() / int64(time.Millisecond)
+		}
+		j.cookies[jc.key()] = jc
+	}
+}
+
+// defaultCookiePath returns the path of a cookie set without one, as in RFC 6265 section 5.1.4.
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// Export returns the cookies in the jar, sorted by domain, path and name.
+func (j *CookieJar) Export() []JarCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	nowMs := time.Now().UnixNano() / int64(time.Millisecond)
+	cookies := make([]JarCookie, 0, len(j.cookies))
+	for key, jc := range j.cookies {
+		// The jar may have rejected the cookie, eg. for a domain the URL didn't belong to, or it
+		// may have expired since.
+		if (jc.Expires != 0 && jc.Expires <= nowMs) || !j.contains(jc) {
+			delete(j.cookies, key)
+			continue
+		}
+		cookies = append(cookies, jc)
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].key() < cookies[b].key()
+	})
+	return cookies
+}
+
+func (j *CookieJar) contains(jc JarCookie) bool {
+	for _, c := range j.Jar.Cookies(jc.url()) {
+		if c.Name == jc.Name && c.Value == jc.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Import stores exported cookies in the jar.
+func (j *CookieJar) Import(cookies []JarCookie) error {
+	for _, jc := range cookies {
+		if jc.Name == "" {
+			return errors.New("cookies need a name")
+		}
+		if jc.Domain == "" {
+			return errors.Errorf("cookie '%s' needs a domain", jc.Name)
+		}
+		if jc.Path == "" {
+			jc.Path = "/"
+		}
+
+		c := &http.Cookie{
+			Name:     jc.Name,
+			Value:    jc.Value,
+			Path:     jc.Path,
+			Secure:   jc.Secure,
+			HttpOnly: jc.HttpOnly,
+		}
+		if !jc.HostOnly {
+			c.Domain = jc.Domain
+		}
+		if jc.Expires != 0 {
+			c.Expires = time.Unix(0, jc.Expires*int64(time.Millisecond))
+		}
+		j.SetCookies(jc.url(), []*http.Cookie{c})
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieJar(t *testing.T) {
+	u, _ := url.Parse("https://example.com/a/b")
+
+	t.Run("Export", func(t *testing.T) {
+		jar, err := NewCookieJar()
+		require.NoError(t, err)
+		jar.SetCookies(u, []*http.Cookie{
+			{Name: "session", Value: "1"},
+			{Name: "domain", Value: "2", Domain: ".Example.com", Path: "/", Secure: true, HttpOnly: true},
+			{Name: "maxage", Value: "3", MaxAge: 60},
+			{Name: "expired", Value: "4", Expires: time.Now().Add(-time.Hour)},
+			{Name: "other", Value: "5", Domain: "example.org"},
+		})
+
+		cookies := jar.Export()
+		require.Len(t, cookies, 3)
+		assert.Equal(t, JarCookie{Name: "domain", Value: "2", Domain: "example.com", Path: "/", Secure: true, HttpOnly: true}, cookies[0])
+		maxAge := cookies[1]
+		assert.InDelta(t, time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond), maxAge.Expires, 1000)
+		maxAge.Expires = 0
+		assert.Equal(t, JarCookie{Name: "maxage", Value: "3", Domain: "example.com", Path: "/a", HostOnly: true}, maxAge)
+		assert.Equal(t, JarCookie{Name: "session", Value: "1", Domain: "example.com", Path: "/a", HostOnly: true}, cookies[2])
+
+		jar.SetCookies(u, []*http.Cookie{{Name: "maxage", MaxAge: -1}})
+		assert.Len(t, jar.Export(), 2)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		src, err := NewCookieJar()
+		require.NoError(t, err)
+		src.SetCookies(u, []*http.Cookie{
+			{Name: "host", Value: "1"},
+			{Name: "domain", Value: "2", Domain: "example.com", Path: "/"},
+		})
+
+		jar, err := NewCookieJar()
+		require.NoError(t, err)
+		require.NoError(t, jar.Import(src.Export()))
+		assert.Equal(t, src.Export(), jar.Export())
+
+		sub, _ := url.Parse("https://www.example.com/a/c")
+		cookies := jar.Cookies(sub)
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "domain", cookies[0].Name)
+
+		assert.EqualError(t, jar.Import([]JarCookie{{Value: "1"}}), "cookies need a name")
+		assert.EqualError(t, jar.Import([]JarCookie{{Name: "a"}}), "cookie 'a' needs a domain")
+	})
+}
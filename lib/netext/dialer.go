@@ -22,61 +22,167 @@ package netext
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
-	"github.com/pkg/errors"
-	"github.com/viki-org/dnscache"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/quic-go/quic-go"
 )
 
 type Dialer struct {
 	net.Dialer
 
-	Resolver  *dnscache.Resolver
+	Resolver  Resolver
 	Blacklist []*net.IPNet
-	Hosts     map[string]net.IP
+	Hosts     types.Hosts
+
+	// Connections to these hostnames fail before they're even looked up.
+	BlockedHostnames types.Hostnames
+
+	// Latency is added to connection establishment and to every round trip, to simulate a
+	// more distant network location.
+	Latency time.Duration
 
 	BytesRead    int64
 	BytesWritten int64
+
+	// The UDP socket QUIC connections are made over, opened with the first one.
+	quicMu        sync.Mutex
+	quicTransport *quic.Transport
 }
 
 func NewDialer(dialer net.Dialer) *Dialer {
 	return &Dialer{
 		Dialer:   dialer,
-		Resolver: dnscache.New(0),
+		Resolver: NewResolver(net.LookupIP, -1, DNSFirst, DNSAny),
 	}
 }
 
 func (d *Dialer) DialContext(ctx context.Context, proto, addr string) (net.Conn, error) {
+	ipAddr, err := d.resolveAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := sleepContext(ctx, d.Latency); err != nil {
+		return nil, err
+	}
+	dialer := d.Dialer
+	if timeout, ok := GetConnectTimeout(ctx); ok {
+		dialer.Timeout = timeout
+	}
+	conn, err := dialer.DialContext(ctx, proto, ipAddr)
+	if err != nil {
+		return nil, err
+	}
+	if d.Latency > 0 {
+		conn = &latencyConn{Conn: conn, ctx: ctx, latency: d.Latency}
+	}
+	conn = &Conn{conn, &d.BytesRead, &d.BytesWritten}
+	return conn, err
+}
+
+// resolveAddr resolves the host of a "host:port" address, from the hosts option or the resolver,
+// and checks it against the blocked hostnames and the blacklist. It returns the "ip:port" to dial.
+func (d *Dialer) resolveAddr(addr string) (string, error) {
 	delimiter := strings.LastIndex(addr, ":")
-	host := addr[:delimiter]
+	host, port := addr[:delimiter], addr[delimiter+1:]
+
+	if pattern, ok := d.BlockedHostnames.Match(host); ok {
+		return "", &BlockedHostnameError{hostname: host, pattern: pattern}
+	}
 
 	// lookup for domain defined in Hosts option before trying to resolve DNS.
-	ip, ok := d.Hosts[host]
-	if !ok {
+	var ip net.IP
+	if hostAddr, ok := d.Hosts.Match(host); ok {
+		ip = hostAddr.IP
+		if hostAddr.Port != 0 {
+			port = strconv.Itoa(hostAddr.Port)
+		}
+	} else {
 		var err error
-		ip, err = d.Resolver.FetchOne(host)
+		ip, err = d.Resolver.LookupIP(host)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 	}
 
 	for _, net := range d.Blacklist {
 		if net.Contains(ip) {
-			return nil, errors.Errorf("IP (%s) is in a blacklisted range (%s)", ip, net)
+			return "", &BlacklistedIPError{ip: ip, net: net}
 		}
 	}
 	ipStr := ip.String()
 	if strings.ContainsRune(ipStr, ':') {
 		ipStr = "[" + ipStr + "]"
 	}
-	conn, err := d.Dialer.DialContext(ctx, proto, ipStr+":"+addr[delimiter+1:])
-	if err != nil {
-		return nil, err
+	return ipStr + ":" + port, nil
+}
+
+// BlacklistedIPError is returned when a host resolves to an IP in one of the blacklisted ranges.
+type BlacklistedIPError struct {
+	ip  net.IP
+	net *net.IPNet
+}
+
+func (b *BlacklistedIPError) Error() string {
+	return fmt.Sprintf("IP (%s) is in a blacklisted range (%s)", b.ip, b.net)
+}
+
+// BlockedHostnameError is returned when a hostname is one of the blocked hostnames.
+type BlockedHostnameError struct {
+	hostname string
+	pattern  string
+}
+
+func (b *BlockedHostnameError) Error() string {
+	return fmt.Sprintf("hostname (%s) is in a blocked pattern (%s)", b.hostname, b.pattern)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
 	}
-	conn = &Conn{conn, &d.BytesRead, &d.BytesWritten}
-	return conn, err
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// latencyConn delays the first read following a write, which adds a fixed latency to every
+// request/response exchange without slowing down the reading of a response in progress.
+// The wait is cut short by the cancellation of the context the connection was dialed with.
+type latencyConn struct {
+	net.Conn
+
+	ctx     context.Context
+	latency time.Duration
+	pending int32
+}
+
+func (c *latencyConn) Read(b []byte) (int, error) {
+	if atomic.CompareAndSwapInt32(&c.pending, 1, 0) {
+		if err := sleepContext(c.ctx, c.latency); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *latencyConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		atomic.StoreInt32(&c.pending, 1)
+	}
+	return n, err
 }
 
 type Conn struct {
@@ -0,0 +1,120 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialerLatency(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _, _ = io.Copy(conn, conn) }()
+		}
+	}()
+
+	dialer := NewDialer(net.Dialer{})
+	dialer.Latency = 50 * time.Millisecond
+
+	start := time.Now()
+	conn, err := dialer.DialContext(context.Background(), "tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	start = time.Now()
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := dialer.DialContext(ctx, "tcp", l.Addr().String())
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("CancelledRead", func(t *testing.T) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		conn = &latencyConn{Conn: conn, ctx: ctx, latency: time.Minute}
+		_, err = conn.Write([]byte("ping"))
+		require.NoError(t, err)
+		time.AfterFunc(10*time.Millisecond, cancel)
+		start = time.Now()
+		_, err = conn.Read(buf)
+		assert.Equal(t, context.Canceled, err)
+		assert.True(t, time.Since(start) < time.Second)
+	})
+}
+
+func TestDialerHosts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	dialer := NewDialer(net.Dialer{})
+	dialer.Hosts = types.Hosts{
+		"*.example.com": {IP: net.ParseIP("127.0.0.1"), Port: port},
+	}
+	dialer.BlockedHostnames = types.Hostnames{"*.ads.example.com"}
+
+	conn, err := dialer.DialContext(context.Background(), "tcp", "www.example.com:80")
+	require.NoError(t, err)
+	assert.Equal(t, l.Addr().String(), conn.RemoteAddr().String())
+	_ = conn.Close()
+
+	_, err = dialer.DialContext(context.Background(), "tcp", "x.ads.example.com:80")
+	assert.EqualError(t, err, "hostname (x.ads.example.com) is in a blocked pattern (*.ads.example.com)")
+	assert.Equal(t, int(BlockedHostnameErrorCode), NewError(err).Code)
+}
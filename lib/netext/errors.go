@@ -0,0 +1,240 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ErrorCode identifies the cause of a failed network operation. Codes are grouped by category
+// in ranges of 100, eg. all DNS errors are between 1100 and 1199.
+type ErrorCode uint32
+
+const (
+	UnknownErrorCode         ErrorCode = 1000
+	InvalidRequestErrorCode  ErrorCode = 1020
+	RequestTimeoutErrorCode  ErrorCode = 1050
+	RequestCanceledErrorCode ErrorCode = 1060
+
+	DNSErrorCode             ErrorCode = 1100
+	DNSNoSuchHostErrorCode   ErrorCode = 1101
+	BlacklistedIPErrorCode   ErrorCode = 1110
+	BlockedHostnameErrorCode ErrorCode = 1111
+
+	TCPErrorCode                ErrorCode = 1200
+	TCPDialErrorCode            ErrorCode = 1210
+	TCPDialTimeoutErrorCode     ErrorCode = 1211
+	TCPDialRefusedErrorCode     ErrorCode = 1212
+	TCPDialUnreachableErrorCode ErrorCode = 1213
+	TCPResetByPeerErrorCode     ErrorCode = 1220
+	TCPBrokenPipeErrorCode      ErrorCode = 1221
+	TCPTimeoutErrorCode         ErrorCode = 1230
+
+	TLSErrorCode                   ErrorCode = 1300
+	TLSUnknownAuthorityErrorCode   ErrorCode = 1310
+	TLSHostnameMismatchErrorCode   ErrorCode = 1311
+	TLSInvalidCertificateErrorCode ErrorCode = 1312
+	TLSRemoteAlertErrorCode        ErrorCode = 1320
+	TLSHandshakeTimeoutErrorCode   ErrorCode = 1330
+
+	HTTP2ErrorCode ErrorCode = 1400
+)
+
+// Category returns the name of the code's category: "request", "dns", "tcp", "tls", "http2"
+// or "unknown".
+func (c ErrorCode) Category() string {
+	switch c / 100 {
+	case 10:
+		if c == UnknownErrorCode {
+			return "unknown"
+		}
+		return "request"
+	case 11:
+		return "dns"
+	case 12:
+		return "tcp"
+	case 13:
+		return "tls"
+	case 14:
+		return "http2"
+	default:
+		return "unknown"
+	}
+}
+
+// An Error is a failed network operation, classified by its cause. Its message is the same as
+// the underlying error's. The code is a plain int, as that's what scripts get to see.
+type Error struct {
+	Code     int    `js:"code"`
+	Category string `js:"category"`
+	Message  string `js:"message"`
+
+	// Failed operation ("dial", "read", "write"), and the system call and errno behind it, if any.
+	Op      string `js:"op"`
+	Syscall string `js:"syscall"`
+	Errno   string `js:"errno"`
+
+	err error
+}
+
+// NewError classifies an error. Errors that are already classified are returned as they are.
+func NewError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	e := &Error{Message: err.Error(), err: err}
+	code := e.classify(err)
+	e.Code = int(code)
+	e.Category = code.Category()
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Cause returns the underlying error, for github.com/pkg/errors.
+func (e *Error) Cause() error {
+	return e.err
+}
+
+var errnoNames = map[syscall.Errno]string{
+	syscall.ECONNREFUSED: "ECONNREFUSED",
+	syscall.ECONNRESET:   "ECONNRESET",
+	syscall.ECONNABORTED: "ECONNABORTED",
+	syscall.EPIPE:        "EPIPE",
+	syscall.ETIMEDOUT:    "ETIMEDOUT",
+	syscall.EHOSTUNREACH: "EHOSTUNREACH",
+	syscall.ENETUNREACH:  "ENETUNREACH",
+}
+
+// classify unwraps the error until it finds a cause it recognises, recording the operation,
+// system call and errno on the way.
+func (e *Error) classify(err error) ErrorCode {
+	for {
+		switch v := err.(type) {
+		case *url.Error:
+			err = v.Err
+		case *net.OpError:
+			if e.Op == "" {
+				e.Op = v.Op
+			}
+			if v.Timeout() {
+				if v.Op == "dial" {
+					return TCPDialTimeoutErrorCode
+				}
+				return TCPTimeoutErrorCode
+			}
+			if _, ok := v.Err.(*net.DNSError); !ok && v.Op == "dial" {
+				if code := e.classify(v.Err); code != UnknownErrorCode && code != TCPErrorCode {
+					return code
+				}
+				return TCPDialErrorCode
+			}
+			err = v.Err
+		case *os.SyscallError:
+			e.Syscall = v.Syscall
+			err = v.Err
+		case syscall.Errno:
+			e.Errno = errnoNames[v]
+			switch v {
+			case syscall.ECONNREFUSED:
+				return TCPDialRefusedErrorCode
+			case syscall.EHOSTUNREACH, syscall.ENETUNREACH:
+				return TCPDialUnreachableErrorCode
+			case syscall.ECONNRESET, syscall.ECONNABORTED:
+				return TCPResetByPeerErrorCode
+			case syscall.EPIPE:
+				return TCPBrokenPipeErrorCode
+			case syscall.ETIMEDOUT:
+				return TCPTimeoutErrorCode
+			}
+			return TCPErrorCode
+		case *net.DNSError:
+			if v.Err == "no such host" {
+				return DNSNoSuchHostErrorCode
+			}
+			return DNSErrorCode
+		case *BlacklistedIPError:
+			return BlacklistedIPErrorCode
+		case *BlockedHostnameError:
+			return BlockedHostnameErrorCode
+		case *TLSHandshakeTimeoutError:
+			return TLSHandshakeTimeoutErrorCode
+		case x509.UnknownAuthorityError:
+			return TLSUnknownAuthorityErrorCode
+		case x509.HostnameError:
+			return TLSHostnameMismatchErrorCode
+		case x509.CertificateInvalidError:
+			return TLSInvalidCertificateErrorCode
+		default:
+			if err == context.Canceled {
+				return RequestCanceledErrorCode
+			}
+			if err == context.DeadlineExceeded {
+				return RequestTimeoutErrorCode
+			}
+			if code := classifyMessage(err.Error()); code != UnknownErrorCode {
+				return code
+			}
+			// Keep digging through errors wrapped by github.com/pkg/errors or fmt.Errorf.
+			switch w := err.(type) {
+			case interface{ Cause() error }:
+				if cause := w.Cause(); cause != nil && cause != err {
+					err = cause
+					continue
+				}
+			case interface{ Unwrap() error }:
+				if inner := w.Unwrap(); inner != nil {
+					err = inner
+					continue
+				}
+			}
+			return UnknownErrorCode
+		}
+	}
+}
+
+// classifyMessage recognises errors that don't have an exported type to switch on.
+func classifyMessage(msg string) ErrorCode {
+	switch {
+	case strings.Contains(msg, "Client.Timeout exceeded"):
+		return RequestTimeoutErrorCode
+	case strings.Contains(msg, "unsupported protocol scheme"):
+		return InvalidRequestErrorCode
+	case strings.HasSuffix(msg, "TLS handshake timeout"):
+		return TLSHandshakeTimeoutErrorCode
+	case strings.HasPrefix(msg, "remote error: tls:"):
+		return TLSRemoteAlertErrorCode
+	case strings.HasPrefix(msg, "tls:"), strings.HasPrefix(msg, "x509:"):
+		return TLSErrorCode
+	case strings.HasPrefix(msg, "http2:"):
+		return HTTP2ErrorCode
+	default:
+		return UnknownErrorCode
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	dialErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://example.com/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	_, blacklist, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testdata := map[string]struct {
+		err      error
+		code     ErrorCode
+		category string
+		syscall  string
+		errno    string
+	}{
+		"unknown":     {errors.New("oops"), UnknownErrorCode, "unknown", "", ""},
+		"scheme":      {&url.Error{Op: "Get", Err: errors.New("unsupported protocol scheme \"\"")}, InvalidRequestErrorCode, "request", "", ""},
+		"timeout":     {&url.Error{Op: "Get", Err: errors.New("net/http: request canceled (Client.Timeout exceeded while awaiting headers)")}, RequestTimeoutErrorCode, "request", "", ""},
+		"canceled":    {&url.Error{Op: "Get", Err: context.Canceled}, RequestCanceledErrorCode, "request", "", ""},
+		"no host":     {dialErr(&net.DNSError{Err: "no such host", Name: "nope"}), DNSNoSuchHostErrorCode, "dns", "", ""},
+		"dns":         {dialErr(&net.DNSError{Err: "server misbehaving", Name: "nope"}), DNSErrorCode, "dns", "", ""},
+		"blacklisted": {&url.Error{Op: "Get", Err: &BlacklistedIPError{net.ParseIP("10.1.2.3"), blacklist}}, BlacklistedIPErrorCode, "dns", "", ""},
+		"refused":     {dialErr(&os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}), TCPDialRefusedErrorCode, "tcp", "connect", "ECONNREFUSED"},
+		"dial":        {dialErr(&os.SyscallError{Syscall: "connect", Err: syscall.EACCES}), TCPDialErrorCode, "tcp", "connect", ""},
+		"reset": {
+			&url.Error{Op: "Get", Err: &net.OpError{Op: "read", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}},
+			TCPResetByPeerErrorCode, "tcp", "read", "ECONNRESET",
+		},
+		"unknown authority": {&url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, TLSUnknownAuthorityErrorCode, "tls", "", ""},
+		"hostname":          {&url.Error{Op: "Get", Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}}, TLSHostnameMismatchErrorCode, "tls", "", ""},
+		"alert":             {&url.Error{Op: "Get", Err: errors.New("remote error: tls: bad certificate")}, TLSRemoteAlertErrorCode, "tls", "", ""},
+		"tls timeout":       {&url.Error{Op: "Get", Err: errors.New("net/http: TLS handshake timeout")}, TLSHandshakeTimeoutErrorCode, "tls", "", ""},
+		"wrapped":           {errors.Wrap(dialErr(&os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}), "setup"), TCPDialRefusedErrorCode, "tcp", "connect", "ECONNREFUSED"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			e := NewError(data.err)
+			assert.Equal(t, int(data.code), e.Code)
+			assert.Equal(t, data.category, e.Category)
+			assert.Equal(t, data.syscall, e.Syscall)
+			assert.Equal(t, data.errno, e.Errno)
+			assert.Equal(t, data.err.Error(), e.Error())
+			assert.Equal(t, e, NewError(e))
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// A HostLimit caps the requests made to a host: how many may be in flight at once, which is how
+// many connections to it are busy, and how many may start per second. Zero means no limit.
+type HostLimit struct {
+	MaxConnections int64
+	RPS            int64
+}
+
+// hostLimiter enforces a HostLimit for one host.
+type hostLimiter struct {
+	slots chan struct{}
+	rate  *rate.Limiter
+}
+
+// A HostLimiter enforces per-host limits on requests, across all of the transports that use it.
+type HostLimiter struct {
+	limit func(hostname string) (HostLimit, bool)
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+// NewHostLimiter returns a HostLimiter that limits each host as the limit function says; hosts
+// it returns false for aren't limited.
+func NewHostLimiter(limit func(hostname string) (HostLimit, bool)) *HostLimiter {
+	return &HostLimiter{limit: limit, hosts: make(map[string]*hostLimiter)}
+}
+
+func (l *HostLimiter) host(hostname string) *hostLimiter {
+	hostname = strings.ToLower(hostname)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[hostname]
+	if !ok {
+		// Hosts without limits are remembered too, so they're only looked up once.
+		if limit, ok := l.limit(hostname); ok {
+			h = &hostLimiter{}
+			if limit.MaxConnections > 0 {
+				h.slots = make(chan struct{}, limit.MaxConnections)
+			}
+			if limit.RPS > 0 {
+				h.rate = rate.NewLimiter(rate.Limit(limit.RPS), 1)
+			}
+		}
+		l.hosts[hostname] = h
+	}
+	return h
+}
+
+// Acquire waits until a request to the host may start, and returns a function to call once it's
+// done, or an error if the context is done first.
+func (l *HostLimiter) Acquire(ctx context.Context, hostname string) (func(), error) {
+	h := l.host(hostname)
+	if h == nil {
+		return func() {}, nil
+	}
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if h.slots != nil {
+			<-h.slots
+		}
+	}
+	if h.rate != nil {
+		if err := h.rate.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(release) }, nil
+}
+
+// roundTrip makes a request once the host's limits allow it; the request counts against them until
+// its response's body is closed.
+func (l *HostLimiter) roundTrip(req *http.Request, rt func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	release, err := l.Acquire(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	res, err := rt(req)
+	if err != nil || res.Body == nil {
+		release()
+		return res, err
+	}
+	res.Body = &limitedBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *limitedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
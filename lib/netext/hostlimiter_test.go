@@ -0,0 +1,126 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimiter(t *testing.T) {
+	l := NewHostLimiter(func(hostname string) (HostLimit, bool) {
+		switch hostname {
+		case "conns.example.com":
+			return HostLimit{MaxConnections: 2}, true
+		case "rps.example.com":
+			return HostLimit{RPS: 20}, true
+		}
+		return HostLimit{}, false
+	})
+
+	t.Run("MaxConnections", func(t *testing.T) {
+		release1, err := l.Acquire(context.Background(), "conns.example.com")
+		require.NoError(t, err)
+		release2, err := l.Acquire(context.Background(), "CONNS.example.com")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = l.Acquire(ctx, "conns.example.com")
+		assert.Equal(t, context.DeadlineExceeded, err)
+
+		// Other hosts aren't affected.
+		release, err := l.Acquire(ctx, "other.example.com")
+		require.NoError(t, err)
+		release()
+
+		release1()
+		release1() // Releasing twice doesn't free another slot.
+		release3, err := l.Acquire(context.Background(), "conns.example.com")
+		require.NoError(t, err)
+		ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = l.Acquire(ctx, "conns.example.com")
+		assert.Equal(t, context.DeadlineExceeded, err)
+		release2()
+		release3()
+	})
+
+	t.Run("RPS", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			release, err := l.Acquire(context.Background(), "rps.example.com")
+			require.NoError(t, err)
+			release()
+		}
+		assert.True(t, time.Since(start) >= 190*time.Millisecond)
+	})
+}
+
+func TestHostLimiterTransport(t *testing.T) {
+	var inFlight, maxInFlight int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	limiter := NewHostLimiter(func(string) (HostLimit, bool) { return HostLimit{MaxConnections: 3}, true })
+
+	// Each VU has a transport of its own, but they share the limiter.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		transport := NewHTTPTransport(&http.Transport{})
+		transport.HostLimiter = limiter
+		client := &http.Client{Transport: transport}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				res, err := client.Get(srv.URL)
+				if !assert.NoError(t, err) {
+					return
+				}
+				_, _ = ioutil.ReadAll(res.Body)
+				_ = res.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(3), atomic.LoadInt64(&maxInFlight))
+}
@@ -2,49 +2,381 @@ package netext
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ThomsonReutersEikon/go-ntlm/ntlm"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/pkg/errors"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
 )
 
 type HTTPTransport struct {
 	*http.Transport
 
+	// The highest HTTP version of requests that don't ask for one; empty is HTTP/2, which the
+	// embedded transport is for.
+	HTTPVersion types.HTTPVersion
+
+	// Dials the QUIC connections of HTTP/3 requests, which can't be made without it.
+	DialQUIC func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (*quic.Conn, error)
+
+	// Closes what DialQUIC dials over, once Close has closed the HTTP/3 connections.
+	CloseQUIC func() error
+
+	// Limits on the requests to each host, shared with the other VUs' transports.
+	HostLimiter *HostLimiter
+
+	// Wrap every round trip the transport makes, redirects included; the first one is outermost.
+	Middleware []Middleware
+
 	mu        sync.Mutex
 	authCache map[string]bool
+
+	// Transports for other HTTP versions than the embedded one's, or presenting a client
+	// certificate, made out of the embedded one when they're first needed.
+	transports map[transportKey]http.RoundTripper
+}
+
+type transportKey struct {
+	version types.HTTPVersion
+	cert    string // DER encoding of the client certificate
 }
 
 func NewHTTPTransport(transport *http.Transport) *HTTPTransport {
 	return &HTTPTransport{
-		Transport: transport,
-		authCache: make(map[string]bool),
+		Transport:  transport,
+		authCache:  make(map[string]bool),
+		transports: make(map[transportKey]http.RoundTripper),
 	}
 }
 
+// A Middleware wraps the round trips of a transport, to apply something to all requests in one
+// place, eg. signing them or scrubbing their responses. It makes the request by calling next.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to an http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// A ClientCertificate is a TLS client certificate to present to certain hosts.
+type ClientCertificate struct {
+	Certificate *tls.Certificate
+
+	// Domains to present the certificate to, or all if empty. May contain wildcards, eg. "*.example.com".
+	Domains []string
+}
+
+// Matches returns whether the certificate should be presented to the given host.
+func (c ClientCertificate) Matches(host string) bool {
+	if len(c.Domains) == 0 {
+		return true
+	}
+	for _, domain := range c.Domains {
+		if domain == host {
+			return true
+		}
+		if strings.HasPrefix(domain, "*.") {
+			if i := strings.IndexByte(host, '.'); i > 0 && host[i:] == domain[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (t *HTTPTransport) RoundTrip(req *http.Request) (res *http.Response, err error) {
 	if t.Transport == nil {
 		return nil, errors.New("no roundtrip defined")
 	}
+	var next http.RoundTripper = RoundTripperFunc(t.roundTrip)
+	if t.HostLimiter != nil {
+		next = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return t.HostLimiter.roundTrip(req, t.roundTrip)
+		})
+	}
+	for i := len(t.Middleware) - 1; i >= 0; i-- {
+		next = t.Middleware[i](next)
+	}
+	return next.RoundTrip(req)
+}
+
+func (t *HTTPTransport) roundTrip(req *http.Request) (res *http.Response, err error) {
+	if timeout, ok := GetTLSHandshakeTimeout(req.Context()); ok && timeout > 0 {
+		return roundTripWithTLSHandshakeTimeout(req, timeout, t.roundTripOnce)
+	}
+	return t.roundTripOnce(req)
+}
+
+func (t *HTTPTransport) roundTripOnce(req *http.Request) (res *http.Response, err error) {
+	var cert *tls.Certificate
+	for _, c := range GetClientCertificates(req.Context()) {
+		if c.Matches(req.URL.Hostname()) {
+			cert = c.Certificate
+			break
+		}
+	}
+	rt, err := t.transportFor(t.httpVersion(req), cert)
+	if err != nil {
+		return nil, err
+	}
+
+	// checking if the request needs ntlm authentication, either directly or through negotiate
+	if req.URL.User != nil {
+		switch GetAuth(req.Context()) {
+		case "ntlm":
+			return t.roundtripWithNTLM(rt, req, "NTLM")
+		case "negotiate":
+			return t.roundtripWithNTLM(rt, req, "Negotiate")
+		}
+	}
+
+	return rt.RoundTrip(req)
+}
+
+// TLSHandshakeTimeoutError is returned for requests whose TLS handshake took longer than the
+// timeout set with WithTLSHandshakeTimeout.
+type TLSHandshakeTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *TLSHandshakeTimeoutError) Error() string {
+	return fmt.Sprintf("TLS handshake took longer than %s: TLS handshake timeout", e.timeout)
+}
+
+// Timeout is always true, for net.Error.
+func (e *TLSHandshakeTimeoutError) Timeout() bool { return true }
+
+// Temporary is always true, for net.Error.
+func (e *TLSHandshakeTimeoutError) Temporary() bool { return true }
+
+// roundTripWithTLSHandshakeTimeout cancels a round trip whose TLS handshake doesn't finish within
+// the timeout. The transport's own TLSHandshakeTimeout is the same for all of its requests. The
+// request's context lives until its response body is closed, as cancelling it cuts the body off.
+func roundTripWithTLSHandshakeTimeout(
+	req *http.Request, timeout time.Duration, next func(*http.Request) (*http.Response, error),
+) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	var timedOut int32
+	var timer *time.Timer
+	var mu sync.Mutex
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			timer = time.AfterFunc(timeout, func() {
+				atomic.StoreInt32(&timedOut, 1)
+				cancel()
+			})
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+		},
+	}
+
+	res, err := next(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+	if err != nil {
+		cancel()
+		if atomic.LoadInt32(&timedOut) == 1 {
+			return nil, &TLSHandshakeTimeoutError{timeout}
+		}
+		return nil, err
+	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
 
-	// checking if the request needs ntlm authentication
-	if GetAuth(req.Context()) == "ntlm" && req.URL.User != nil {
-		return t.roundtripWithNTLM(req)
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// CloseIdleConnections closes the idle connections of the transport, including the ones made
+// with other HTTP versions and per-request client certificates.
+func (t *HTTPTransport) CloseIdleConnections() {
+	t.Transport.CloseIdleConnections()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, rt := range t.transports {
+		if rt, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			rt.CloseIdleConnections()
+		}
 	}
+}
 
-	return t.Transport.RoundTrip(req)
+// Close closes all of the transport's connections, including the HTTP/3 ones and, with CloseQUIC,
+// the socket they're made over. Connections still in use are cut off. The transport can still be
+// used afterwards, and makes new connections when it is.
+func (t *HTTPTransport) Close() error {
+	t.CloseIdleConnections()
+
+	t.mu.Lock()
+	var err error
+	for key, rt := range t.transports {
+		if rt, ok := rt.(*http3.Transport); ok {
+			if cerr := rt.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+			delete(t.transports, key)
+		}
+	}
+	t.mu.Unlock()
+
+	if t.CloseQUIC != nil {
+		if cerr := t.CloseQUIC(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
 
-func (t *HTTPTransport) roundtripWithNTLM(req *http.Request) (res *http.Response, err error) {
-	rt := t.Transport
+// Preconnect opens the given number of connections to the host of each URL, making a HEAD request
+// over every one of them, and leaves them idle for later requests to use. The requests skip the
+// middleware, and aren't measured.
+func (t *HTTPTransport) Preconnect(ctx context.Context, urls []string, connections int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(urls)*connections)
+	for _, u := range urls {
+		for i := 0; i < connections; i++ {
+			req, err := http.NewRequest("HEAD", u, nil)
+			if err != nil {
+				return err
+			}
+			rt, err := t.transportFor(t.httpVersion(req), nil)
+			if err != nil {
+				return err
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := rt.RoundTrip(req.WithContext(ctx))
+				if err != nil {
+					errs <- errors.Wrapf(err, "couldn't connect to %s", req.URL.Host)
+					return
+				}
+				_, _ = io.Copy(ioutil.Discard, res.Body)
+				_ = res.Body.Close()
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
 
+// httpVersion returns the highest HTTP version the request can be made with. HTTP/2 and HTTP/3
+// need TLS, so plain HTTP requests go through the embedded transport, which makes them with HTTP/1.1.
+func (t *HTTPTransport) httpVersion(req *http.Request) types.HTTPVersion {
+	version := t.HTTPVersion
+	if v, ok := GetHTTPVersion(req.Context()); ok {
+		version = v
+	}
+	if version == "" || req.URL.Scheme != "https" {
+		return types.HTTPVersion2
+	}
+	return version
+}
+
+// transportFor returns the transport for requests with the given HTTP version, presenting the
+// given client certificate, if any. TLS connections are tied to the certificate presented during
+// the handshake, so every certificate needs its own connection pool, and so does every version.
+func (t *HTTPTransport) transportFor(version types.HTTPVersion, cert *tls.Certificate) (http.RoundTripper, error) {
+	if version == types.HTTPVersion2 && cert == nil {
+		return t.Transport, nil
+	}
+	key := transportKey{version: version}
+	if cert != nil {
+		if len(cert.Certificate) == 0 {
+			return nil, errors.New("empty client certificate")
+		}
+		key.cert = string(cert.Certificate[0])
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rt, ok := t.transports[key]; ok {
+		return rt, nil
+	}
+
+	tlsConfig := t.Transport.TLSClientConfig.Clone()
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if cert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+		tlsConfig.NameToCertificate = nil
+	}
+
+	var rt http.RoundTripper
+	if version == types.HTTPVersion3 {
+		if t.DialQUIC == nil {
+			return nil, errors.New("HTTP/3 isn't supported by this transport")
+		}
+		rt = &http3.Transport{
+			TLSClientConfig:    tlsConfig,
+			Dial:               t.DialQUIC,
+			DisableCompression: t.Transport.DisableCompression,
+		}
+	} else {
+		h := t.Transport.Clone()
+		h.TLSClientConfig = tlsConfig
+		// The cloned HTTP/2 upgrade would share its connection pool with the original transport.
+		_, h2 := h.TLSNextProto["h2"]
+		h.TLSNextProto = nil
+		switch {
+		case version == types.HTTPVersion1:
+			// A non-nil empty map keeps net/http from offering HTTP/2 on its own.
+			h.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+			tlsConfig.NextProtos = nil
+		case h2:
+			if err := http2.ConfigureTransport(h); err != nil {
+				return nil, err
+			}
+		}
+		rt = h
+	}
+	t.transports[key] = rt
+	return rt, nil
+}
+
+// roundtripWithNTLM performs an NTLM handshake using the given authentication scheme. With the
+// Negotiate scheme (SPNEGO), the raw NTLM messages are sent, which servers accept as a fallback
+// from Kerberos. A username of the form "DOMAIN\user" authenticates against that domain.
+func (t *HTTPTransport) roundtripWithNTLM(rt http.RoundTripper, req *http.Request, scheme string) (res *http.Response, err error) {
 	username := req.URL.User.Username()
 	password, _ := req.URL.User.Password()
+	domain := ""
+	if i := strings.IndexByte(username, '\\'); i != -1 {
+		domain, username = username[:i], username[i+1:]
+	}
 
 	// Save request body
 	body := bytes.Buffer{}
@@ -64,7 +396,7 @@ func (t *HTTPTransport) roundtripWithNTLM(req *http.Request) (res *http.Response
 	if _, ok := t.getAuthCache(req.URL.String()); ok {
 		req.Header.Del("Authorization")
 	} else {
-		req.Header.Set("Authorization", "NTLM TlRMTVNTUAABAAAAB4IAAAAAAAAAAAAAAAAAAAAAAAAAAAAAMAAAAAAAMAA=")
+		req.Header.Set("Authorization", scheme+" TlRMTVNTUAABAAAAB4IAAAAAAAAAAAAAAAAAAAAAAAAAAAAAMAAAAAAAMAA=")
 	}
 
 	res, err = rt.RoundTrip(req)
@@ -83,13 +415,20 @@ func (t *HTTPTransport) roundtripWithNTLM(req *http.Request) (res *http.Response
 	}
 	req.Body = ioutil.NopCloser(bytes.NewReader(body.Bytes()))
 
-	// retrieve Www-Authenticate header from response
-	ntlmChallenge := res.Header.Get("WWW-Authenticate")
-	if !strings.HasPrefix(ntlmChallenge, "NTLM ") {
+	// retrieve the Www-Authenticate header for our scheme from the response; servers may
+	// offer several schemes in separate headers
+	ntlmChallenge := ""
+	for _, v := range res.Header["Www-Authenticate"] {
+		if strings.HasPrefix(v, scheme+" ") {
+			ntlmChallenge = strings.TrimPrefix(v, scheme+" ")
+			break
+		}
+	}
+	if ntlmChallenge == "" {
 		return nil, errors.New("Invalid WWW-Authenticate header")
 	}
 
-	challengeBytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ntlmChallenge, "NTLM "))
+	challengeBytes, err := base64.StdEncoding.DecodeString(ntlmChallenge)
 	if err != nil {
 		return nil, err
 	}
@@ -99,7 +438,7 @@ func (t *HTTPTransport) roundtripWithNTLM(req *http.Request) (res *http.Response
 		return nil, err
 	}
 
-	session.SetUserInfo(username, password, "")
+	session.SetUserInfo(username, password, domain)
 
 	// parse NTLM challenge
 	challenge, err := ntlm.ParseChallengeMessage(challengeBytes)
@@ -119,7 +458,7 @@ func (t *HTTPTransport) roundtripWithNTLM(req *http.Request) (res *http.Response
 	}
 
 	// set NTLM Authorization header
-	header := "NTLM " + base64.StdEncoding.EncodeToString(authenticate.Bytes())
+	header := scheme + " " + base64.StdEncoding.EncodeToString(authenticate.Bytes())
 	req.Header.Set("Authorization", header)
 
 	t.setAuthCache(req.URL.String(), true)
@@ -0,0 +1,258 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestClientCertificateMatches(t *testing.T) {
+	testdata := map[string]struct {
+		domains []string
+		host    string
+		matches bool
+	}{
+		"any":           {nil, "example.com", true},
+		"exact":         {[]string{"example.com"}, "example.com", true},
+		"other":         {[]string{"example.com"}, "example.org", false},
+		"wildcard":      {[]string{"*.example.com"}, "api.example.com", true},
+		"wildcard deep": {[]string{"*.example.com"}, "a.b.example.com", false},
+		"wildcard apex": {[]string{"*.example.com"}, "example.com", false},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			cert := ClientCertificate{Domains: data.domains}
+			assert.Equal(t, data.matches, cert.Matches(data.host))
+		})
+	}
+}
+
+func TestHTTPTransportMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Order", r.Header.Get("X-Order"))
+	}))
+	defer srv.Close()
+
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Order", name)
+				res, err := next.RoundTrip(req)
+				if err == nil {
+					res.Header.Add("X-Seen", name)
+				}
+				return res, err
+			})
+		}
+	}
+	transport := NewHTTPTransport(&http.Transport{})
+	transport.Middleware = []Middleware{mark("a"), mark("b")}
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+	res, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, "a", res.Header.Get("X-Order"))
+	assert.Equal(t, []string{"b", "a"}, res.Header["X-Seen"])
+	assert.Equal(t, []string{"a", "b"}, req.Header["X-Order"])
+}
+
+func TestHTTPTransportTLSHandshakeTimeout(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		// Accepts connections, but never says anything back.
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = l.Close() }()
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer func() { _ = conn.Close() }()
+			}
+		}()
+
+		transport := NewHTTPTransport(&http.Transport{})
+		req, err := http.NewRequest("GET", "https://"+l.Addr().String(), nil)
+		require.NoError(t, err)
+		req = req.WithContext(WithTLSHandshakeTimeout(req.Context(), 100*time.Millisecond))
+
+		start := time.Now()
+		_, err = transport.RoundTrip(req)
+		assert.WithinDuration(t, start.Add(100*time.Millisecond), time.Now(), time.Second)
+		require.IsType(t, &TLSHandshakeTimeoutError{}, err)
+		assert.Equal(t, int(TLSHandshakeTimeoutErrorCode), NewError(err).Code)
+	})
+
+	t.Run("in time", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer srv.Close()
+
+		transport := NewHTTPTransport(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}})
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		require.NoError(t, err)
+		req = req.WithContext(WithTLSHandshakeTimeout(req.Context(), 100*time.Millisecond))
+
+		res, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		// The body can still be read after the timeout.
+		time.Sleep(200 * time.Millisecond)
+		body, err := ioutil.ReadAll(res.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", string(body))
+		assert.NoError(t, res.Body.Close())
+	})
+}
+
+func TestHTTPTransportPreconnect(t *testing.T) {
+	var conns, requests int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	transport := NewHTTPTransport(&http.Transport{MaxIdleConnsPerHost: 4})
+	require.NoError(t, transport.Preconnect(context.Background(), []string{srv.URL}, 4))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&conns))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&requests))
+
+	// Later requests use the idle connections.
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+	res, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.NoError(t, res.Body.Close())
+	assert.Equal(t, int64(4), atomic.LoadInt64(&conns))
+
+	err = transport.Preconnect(context.Background(), []string{"http://127.0.0.1:1"}, 1)
+	assert.Contains(t, err.Error(), "couldn't connect to 127.0.0.1:1")
+}
+
+func TestHTTPTransportHTTPVersion(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	srv := httptest.NewUnstartedServer(handler)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	// The HTTP/3 server listens on the same port, over UDP.
+	udpConn, err := net.ListenPacket("udp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	h3srv := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(srv.TLS.Clone())}
+	go func() { _ = h3srv.Serve(udpConn) }()
+	defer func() { _ = h3srv.Close() }()
+
+	dialer := NewDialer(net.Dialer{})
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, DialContext: dialer.DialContext}
+	require.NoError(t, http2.ConfigureTransport(transport))
+	httpTransport := NewHTTPTransport(transport)
+	httpTransport.DialQUIC = dialer.DialQUIC
+	httpTransport.CloseQUIC = dialer.CloseQUIC
+
+	get := func(url string, version types.HTTPVersion) (string, string) {
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err)
+		if version != "" {
+			req = req.WithContext(WithHTTPVersion(req.Context(), version))
+		}
+		res, err := httpTransport.RoundTrip(req)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res.Proto, string(body)
+	}
+
+	testdata := map[types.HTTPVersion]string{
+		"":                 "HTTP/2.0",
+		types.HTTPVersion1: "HTTP/1.1",
+		types.HTTPVersion2: "HTTP/2.0",
+		types.HTTPVersion3: "HTTP/3.0",
+	}
+	for version, proto := range testdata {
+		t.Run(string(version), func(t *testing.T) {
+			resProto, body := get(srv.URL, version)
+			assert.Equal(t, proto, resProto)
+			assert.Equal(t, proto, body)
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		httpTransport.HTTPVersion = types.HTTPVersion3
+		defer func() { httpTransport.HTTPVersion = "" }()
+		resProto, _ := get(srv.URL, "")
+		assert.Equal(t, "HTTP/3.0", resProto)
+		resProto, _ = get(srv.URL, types.HTTPVersion1)
+		assert.Equal(t, "HTTP/1.1", resProto)
+	})
+
+	t.Run("plain HTTP", func(t *testing.T) {
+		plain := httptest.NewServer(handler)
+		defer plain.Close()
+		resProto, _ := get(plain.URL, types.HTTPVersion3)
+		assert.Equal(t, "HTTP/1.1", resProto)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		_, _ = get(srv.URL, types.HTTPVersion3)
+		require.NotNil(t, dialer.quicTransport)
+		require.NoError(t, httpTransport.Close())
+		assert.Nil(t, dialer.quicTransport)
+
+		// New connections are made over a new socket.
+		resProto, _ := get(srv.URL, types.HTTPVersion3)
+		assert.Equal(t, "HTTP/3.0", resProto)
+		assert.NotNil(t, dialer.quicTransport)
+	})
+
+	t.Run("data", func(t *testing.T) {
+		read, written := atomic.LoadInt64(&dialer.BytesRead), atomic.LoadInt64(&dialer.BytesWritten)
+		_, _ = get(srv.URL, types.HTTPVersion3)
+		assert.True(t, atomic.LoadInt64(&dialer.BytesRead) > read)
+		assert.True(t, atomic.LoadInt64(&dialer.BytesWritten) > written)
+	})
+}
@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyFunc returns a function for http.Transport's Proxy field that sends requests through the
+// proxy set in their context, if any, or else the one def returns. Connections to a proxy are
+// made to the proxy rather than the host, so requests to blocked hostnames fail here instead.
+func (d *Dialer) ProxyFunc(def func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, ok := GetProxy(req.Context())
+		if !ok {
+			var err error
+			if proxyURL, err = def(req); err != nil {
+				return nil, err
+			}
+		}
+		if proxyURL != nil {
+			host := req.URL.Hostname()
+			if pattern, ok := d.BlockedHostnames.Match(host); ok {
+				return nil, &BlockedHostnameError{hostname: host, pattern: pattern}
+			}
+		}
+		return proxyURL, nil
+	}
+}
+
+// IsSOCKSProxy returns whether the proxy is a SOCKS5 one, rather than an HTTP(S) one.
+func IsSOCKSProxy(proxyURL *url.URL) bool {
+	return proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h"
+}
+
+// DialSOCKS returns a dial function that connects through the given SOCKS5 proxy, which resolves
+// the hosts connected to; the connection to the proxy itself is made by the dialer.
+func (d *Dialer) DialSOCKS(ctx context.Context, proxyURL *url.URL) (func(network, addr string) (net.Conn, error), error) {
+	var auth *proxy.Auth
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth = &proxy.Auth{User: user.Username(), Password: password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyAddr(proxyURL), auth, contextDialer{d, ctx})
+	if err != nil {
+		return nil, err
+	}
+	return dialer.Dial, nil
+}
+
+// proxyAddr returns the host and port of a proxy, filling in the scheme's default port.
+func proxyAddr(proxyURL *url.URL) string {
+	if port := proxyURL.Port(); port != "" {
+		return proxyURL.Host
+	}
+	port := "1080"
+	switch proxyURL.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// contextDialer dials with the dialer and a fixed context, for APIs without contexts.
+type contextDialer struct {
+	dialer *Dialer
+	ctx    context.Context
+}
+
+func (d contextDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.dialer.DialContext(d.ctx, network, addr)
+}
@@ -0,0 +1,209 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialerProxyFunc(t *testing.T) {
+	defaultProxy, _ := url.Parse("http://proxy.example.com:3128")
+	otherProxy, _ := url.Parse("socks5://192.0.2.1:1080")
+
+	dialer := NewDialer(net.Dialer{})
+	dialer.BlockedHostnames = types.Hostnames{"*.blocked.com"}
+	proxyFunc := dialer.ProxyFunc(http.ProxyURL(defaultProxy))
+
+	request := func(ctx context.Context, rawurl string) *http.Request {
+		req, err := http.NewRequest("GET", rawurl, nil)
+		require.NoError(t, err)
+		return req.WithContext(ctx)
+	}
+
+	u, err := proxyFunc(request(context.Background(), "http://example.com/"))
+	require.NoError(t, err)
+	assert.Equal(t, defaultProxy, u)
+
+	u, err = proxyFunc(request(WithProxy(context.Background(), otherProxy), "http://example.com/"))
+	require.NoError(t, err)
+	assert.Equal(t, otherProxy, u)
+
+	u, err = proxyFunc(request(WithProxy(context.Background(), nil), "http://example.com/"))
+	require.NoError(t, err)
+	assert.Nil(t, u)
+
+	_, err = proxyFunc(request(context.Background(), "http://www.blocked.com/"))
+	assert.EqualError(t, err, "hostname (www.blocked.com) is in a blocked pattern (*.blocked.com)")
+
+	// Without a proxy, the dialer blocks it when connecting instead.
+	u, err = proxyFunc(request(WithProxy(context.Background(), nil), "http://www.blocked.com/"))
+	require.NoError(t, err)
+	assert.Nil(t, u)
+}
+
+// serveSOCKS5 is a SOCKS5 proxy that only supports CONNECT, and username/password
+// authentication if user isn't empty.
+func serveSOCKS5(l net.Listener, user, pass string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer func() { _ = conn.Close() }()
+			r := bufio.NewReader(conn)
+
+			// Greeting: version, methods.
+			head := make([]byte, 2)
+			if _, err := io.ReadFull(r, head); err != nil {
+				return
+			}
+			if _, err := io.ReadFull(r, make([]byte, head[1])); err != nil {
+				return
+			}
+			if user == "" {
+				_, _ = conn.Write([]byte{5, 0})
+			} else {
+				_, _ = conn.Write([]byte{5, 2})
+				readString := func() string {
+					n, _ := r.ReadByte()
+					b := make([]byte, n)
+					_, _ = io.ReadFull(r, b)
+					return string(b)
+				}
+				_, _ = r.ReadByte()
+				if readString() != user || readString() != pass {
+					_, _ = conn.Write([]byte{1, 1})
+					return
+				}
+				_, _ = conn.Write([]byte{1, 0})
+			}
+
+			// Request: version, command, reserved, address type, address, port.
+			req := make([]byte, 4)
+			if _, err := io.ReadFull(r, req); err != nil {
+				return
+			}
+			var host string
+			switch req[3] {
+			case 1:
+				ip := make([]byte, 4)
+				_, _ = io.ReadFull(r, ip)
+				host = net.IP(ip).String()
+			case 3:
+				n, _ := r.ReadByte()
+				name := make([]byte, n)
+				_, _ = io.ReadFull(r, name)
+				host = string(name)
+			default:
+				return
+			}
+			port := make([]byte, 2)
+			_, _ = io.ReadFull(r, port)
+			target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+			if err != nil {
+				_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer func() { _ = target.Close() }()
+			_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+			go func() { _, _ = io.Copy(target, r) }()
+			_, _ = io.Copy(conn, target)
+		}()
+	}
+}
+
+func TestDialSOCKS(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = echo.Close() }()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	socks, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = socks.Close() }()
+	go serveSOCKS5(socks, "bob", "pass")
+
+	dialer := NewDialer(net.Dialer{})
+	t.Run("authenticated", func(t *testing.T) {
+		proxyURL, _ := url.Parse("socks5://bob:pass@" + socks.Addr().String())
+		assert.True(t, IsSOCKSProxy(proxyURL))
+		dial, err := dialer.DialSOCKS(context.Background(), proxyURL)
+		require.NoError(t, err)
+		conn, err := dial("tcp", echo.Addr().String())
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(conn, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(buf))
+		assert.True(t, dialer.BytesWritten > 0)
+	})
+	t.Run("wrong password", func(t *testing.T) {
+		proxyURL, _ := url.Parse("socks5://bob:nope@" + socks.Addr().String())
+		dial, err := dialer.DialSOCKS(context.Background(), proxyURL)
+		require.NoError(t, err)
+		_, err = dial("tcp", echo.Addr().String())
+		assert.Error(t, err)
+	})
+}
+
+func TestProxyAddr(t *testing.T) {
+	testdata := map[string]string{
+		"http://proxy.example.com":       "proxy.example.com:80",
+		"https://proxy.example.com":      "proxy.example.com:443",
+		"socks5://proxy.example.com":     "proxy.example.com:1080",
+		"socks5://proxy.example.com:999": "proxy.example.com:999",
+		"socks5://[2001:db8::1]":         "[2001:db8::1]:1080",
+	}
+	for s, addr := range testdata {
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		assert.Equal(t, addr, proxyAddr(u), s)
+	}
+}
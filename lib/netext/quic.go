@@ -0,0 +1,140 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+
+	"github.com/quic-go/quic-go"
+)
+
+// DialQUIC dials a QUIC connection for HTTP/3, with the signature of http3.Transport's Dial.
+// The address is resolved and checked like DialContext's, and the datagrams count towards the
+// bytes read and written. The latency is only added to the dial, not to every round trip.
+//
+// QUIC connects and does the TLS handshake at once, so the trace sees the connection being made
+// instantly and the whole handshake as the TLS handshake.
+func (d *Dialer) DialQUIC(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (*quic.Conn, error) {
+	ipAddr, err := d.resolveAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", ipAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := sleepContext(ctx, d.Latency); err != nil {
+		return nil, err
+	}
+	timeout := d.Timeout
+	if t, ok := GetConnectTimeout(ctx); ok {
+		timeout = t
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("udp", ipAddr)
+	}
+	tr, err := d.getQUICTransport()
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("udp", ipAddr, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	conn, err := tr.DialEarly(ctx, udpAddr, tlsConf, conf)
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		var state tls.ConnectionState
+		if conn != nil {
+			state = conn.ConnectionState().TLS
+		}
+		trace.TLSHandshakeDone(state, err)
+	}
+	return conn, err
+}
+
+// getQUICTransport returns the transport all of the dialer's QUIC connections share a UDP
+// socket through, opening the socket the first time.
+func (d *Dialer) getQUICTransport() (*quic.Transport, error) {
+	d.quicMu.Lock()
+	defer d.quicMu.Unlock()
+
+	if d.quicTransport == nil {
+		udpConn, err := net.ListenUDP("udp", nil)
+		if err != nil {
+			return nil, err
+		}
+		d.quicTransport = &quic.Transport{
+			Conn: &PacketConn{udpConn, &d.BytesRead, &d.BytesWritten},
+		}
+	}
+	return d.quicTransport, nil
+}
+
+// CloseQUIC closes the UDP socket the dialer's QUIC connections share, which closes them too. The
+// next QUIC dial opens a new socket.
+func (d *Dialer) CloseQUIC() error {
+	d.quicMu.Lock()
+	defer d.quicMu.Unlock()
+
+	if d.quicTransport == nil {
+		return nil
+	}
+	err := d.quicTransport.Close()
+	d.quicTransport = nil
+	return err
+}
+
+// PacketConn counts the bytes of the datagrams read from and written to a net.PacketConn.
+type PacketConn struct {
+	net.PacketConn
+
+	BytesRead, BytesWritten *int64
+}
+
+func (c *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if n > 0 {
+		atomic.AddInt64(c.BytesRead, int64(n))
+	}
+	return n, addr, err
+}
+
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if n > 0 {
+		atomic.AddInt64(c.BytesWritten, int64(n))
+	}
+	return n, err
+}
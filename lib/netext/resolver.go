@@ -0,0 +1,153 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DNSSelect is how a connection picks one of the IPs a host resolves to.
+type DNSSelect string
+
+const (
+	DNSFirst      DNSSelect = "first"
+	DNSRoundRobin DNSSelect = "roundRobin"
+	DNSRandom     DNSSelect = "random"
+)
+
+// DNSPolicy is which IP versions a connection is made with.
+type DNSPolicy string
+
+const (
+	DNSPreferIPv4 DNSPolicy = "preferIPv4"
+	DNSPreferIPv6 DNSPolicy = "preferIPv6"
+	DNSOnlyIPv4   DNSPolicy = "onlyIPv4"
+	DNSOnlyIPv6   DNSPolicy = "onlyIPv6"
+	DNSAny        DNSPolicy = "any"
+)
+
+// A Resolver returns the IP a connection to a host is made to.
+type Resolver interface {
+	LookupIP(host string) (net.IP, error)
+}
+
+type resolverRecord struct {
+	ips     []net.IP
+	expires time.Time
+	next    int
+}
+
+type resolver struct {
+	lookup func(host string) ([]net.IP, error)
+	ttl    time.Duration
+	sel    DNSSelect
+	policy DNSPolicy
+
+	mu      sync.Mutex
+	records map[string]*resolverRecord
+}
+
+// NewResolver returns a Resolver that looks hosts up with the given function and caches the results
+// for ttl; a negative ttl caches them for the whole test, and 0 looks hosts up for every connection.
+func NewResolver(lookup func(host string) ([]net.IP, error), ttl time.Duration, sel DNSSelect, policy DNSPolicy) Resolver {
+	return &resolver{
+		lookup:  lookup,
+		ttl:     ttl,
+		sel:     sel,
+		policy:  policy,
+		records: make(map[string]*resolverRecord),
+	}
+}
+
+func (r *resolver) LookupIP(host string) (net.IP, error) {
+	r.mu.Lock()
+	rec := r.records[host]
+	fresh := rec != nil && rec.ips != nil && (r.ttl < 0 || time.Now().Before(rec.expires))
+	r.mu.Unlock()
+
+	if !fresh {
+		ips, err := r.lookup(host)
+		if err != nil {
+			return nil, err
+		}
+		if ips = filterIPs(ips, r.policy); len(ips) == 0 {
+			return nil, errors.Errorf("couldn't find an IP address for %s allowed by the %s DNS policy", host, r.policy)
+		}
+
+		r.mu.Lock()
+		if rec = r.records[host]; rec == nil {
+			rec = &resolverRecord{}
+			r.records[host] = rec
+		}
+		// The round robin carries on where it was, so lookups don't reset it to the first IP.
+		rec.ips = ips
+		rec.expires = time.Now().Add(r.ttl)
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch r.sel {
+	case DNSRoundRobin:
+		ip := rec.ips[rec.next%len(rec.ips)]
+		rec.next = (rec.next + 1) % len(rec.ips)
+		return ip, nil
+	case DNSRandom:
+		return rec.ips[rand.Intn(len(rec.ips))], nil
+	default:
+		return rec.ips[0], nil
+	}
+}
+
+// filterIPs returns the IPs a policy allows, in the order of preference.
+func filterIPs(ips []net.IP, policy DNSPolicy) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch policy {
+	case DNSOnlyIPv4:
+		return v4
+	case DNSOnlyIPv6:
+		return v6
+	case DNSPreferIPv4:
+		if len(v4) > 0 {
+			return v4
+		}
+		return v6
+	case DNSPreferIPv6:
+		if len(v6) > 0 {
+			return v6
+		}
+		return v4
+	default:
+		return ips
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLookup struct {
+	ips   []net.IP
+	calls int
+}
+
+func (l *testLookup) lookup(host string) ([]net.IP, error) {
+	l.calls++
+	if host != "example.com" {
+		return nil, errors.New("no such host")
+	}
+	return l.ips, nil
+}
+
+func TestResolver(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("192.0.2.2"),
+		net.ParseIP("2001:db8::2"),
+	}
+
+	t.Run("Select", func(t *testing.T) {
+		testdata := map[DNSSelect][]string{
+			DNSFirst:      {"2001:db8::1", "2001:db8::1", "2001:db8::1", "2001:db8::1", "2001:db8::1"},
+			DNSRoundRobin: {"2001:db8::1", "192.0.2.1", "192.0.2.2", "2001:db8::2", "2001:db8::1"},
+		}
+		for sel, expected := range testdata {
+			t.Run(string(sel), func(t *testing.T) {
+				r := NewResolver((&testLookup{ips: ips}).lookup, -1, sel, DNSAny)
+				var got []string
+				for range expected {
+					ip, err := r.LookupIP("example.com")
+					require.NoError(t, err)
+					got = append(got, ip.String())
+				}
+				assert.Equal(t, expected, got)
+			})
+		}
+
+		t.Run(string(DNSRandom), func(t *testing.T) {
+			r := NewResolver((&testLookup{ips: ips}).lookup, -1, DNSRandom, DNSAny)
+			seen := map[string]bool{}
+			for i := 0; i < 100; i++ {
+				ip, err := r.LookupIP("example.com")
+				require.NoError(t, err)
+				seen[ip.String()] = true
+			}
+			assert.Len(t, seen, 4)
+		})
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		testdata := map[DNSPolicy][]string{
+			DNSPreferIPv4: {"192.0.2.1", "192.0.2.2"},
+			DNSPreferIPv6: {"2001:db8::1", "2001:db8::2"},
+			DNSOnlyIPv4:   {"192.0.2.1", "192.0.2.2"},
+			DNSOnlyIPv6:   {"2001:db8::1", "2001:db8::2"},
+			DNSAny:        {"2001:db8::1", "192.0.2.1"},
+		}
+		for policy, expected := range testdata {
+			t.Run(string(policy), func(t *testing.T) {
+				r := NewResolver((&testLookup{ips: ips}).lookup, -1, DNSRoundRobin, policy)
+				var got []string
+				for range expected {
+					ip, err := r.LookupIP("example.com")
+					require.NoError(t, err)
+					got = append(got, ip.String())
+				}
+				assert.Equal(t, expected, got)
+			})
+		}
+
+		t.Run("Fallback", func(t *testing.T) {
+			v6 := []net.IP{net.ParseIP("2001:db8::1")}
+			ip, err := NewResolver((&testLookup{ips: v6}).lookup, -1, DNSFirst, DNSPreferIPv4).LookupIP("example.com")
+			require.NoError(t, err)
+			assert.Equal(t, "2001:db8::1", ip.String())
+
+			_, err = NewResolver((&testLookup{ips: v6}).lookup, -1, DNSFirst, DNSOnlyIPv4).LookupIP("example.com")
+			assert.EqualError(t, err, "couldn't find an IP address for example.com allowed by the onlyIPv4 DNS policy")
+		})
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		l := &testLookup{ips: ips}
+		r := NewResolver(l.lookup, -1, DNSFirst, DNSAny)
+		for i := 0; i < 3; i++ {
+			_, err := r.LookupIP("example.com")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 1, l.calls)
+
+		l = &testLookup{ips: ips}
+		r = NewResolver(l.lookup, 0, DNSFirst, DNSAny)
+		for i := 0; i < 3; i++ {
+			_, err := r.LookupIP("example.com")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 3, l.calls)
+
+		l = &testLookup{ips: ips}
+		r = NewResolver(l.lookup, 50*time.Millisecond, DNSFirst, DNSAny)
+		_, _ = r.LookupIP("example.com")
+		_, _ = r.LookupIP("example.com")
+		assert.Equal(t, 1, l.calls)
+		time.Sleep(60 * time.Millisecond)
+		_, _ = r.LookupIP("example.com")
+		assert.Equal(t, 2, l.calls)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := NewResolver((&testLookup{ips: ips}).lookup, -1, DNSFirst, DNSAny).LookupIP("example.net")
+		assert.EqualError(t, err, "no such host")
+	})
+}
@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Propagators are the formats of the headers trace contexts are sent in.
+const (
+	// W3C Trace Context: traceparent: 00-{trace id}-{span id}-{flags}.
+	PropagatorW3C = "w3c"
+	// Zipkin's single b3 header: b3: {trace id}-{span id}-{sampled}.
+	PropagatorB3 = "b3"
+	// Zipkin's X-B3-TraceId, X-B3-SpanId and X-B3-Sampled headers.
+	PropagatorB3Multi = "b3multi"
+)
+
+// A TraceContext places a request in a distributed trace, as the root span of a trace of its own.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// NewTraceContext returns a trace context with random trace and span IDs.
+func NewTraceContext(sampled bool) (TraceContext, error) {
+	tc := TraceContext{Sampled: sampled}
+	if _, err := rand.Read(tc.TraceID[:]); err != nil {
+		return tc, err
+	}
+	if _, err := rand.Read(tc.SpanID[:]); err != nil {
+		return tc, err
+	}
+	return tc, nil
+}
+
+// TraceIDString returns the trace ID as 32 lowercase hex digits.
+func (tc TraceContext) TraceIDString() string {
+	return hex.EncodeToString(tc.TraceID[:])
+}
+
+// SpanIDString returns the span ID as 16 lowercase hex digits.
+func (tc TraceContext) SpanIDString() string {
+	return hex.EncodeToString(tc.SpanID[:])
+}
+
+// Inject sets the headers of the given propagator to the trace context; it returns false for
+// unknown propagators, setting nothing.
+func (tc TraceContext) Inject(propagator string, header http.Header) bool {
+	traceID, spanID := tc.TraceIDString(), tc.SpanIDString()
+	switch propagator {
+	case PropagatorW3C:
+		flags := "00"
+		if tc.Sampled {
+			flags = "01"
+		}
+		header.Set("traceparent", "00-"+traceID+"-"+spanID+"-"+flags)
+	case PropagatorB3:
+		sampled := "0"
+		if tc.Sampled {
+			sampled = "1"
+		}
+		header.Set("b3", traceID+"-"+spanID+"-"+sampled)
+	case PropagatorB3Multi:
+		sampled := "0"
+		if tc.Sampled {
+			sampled = "1"
+		}
+		header.Set("X-B3-TraceId", traceID)
+		header.Set("X-B3-SpanId", spanID)
+		header.Set("X-B3-Sampled", sampled)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceContext(t *testing.T) {
+	tc, err := NewTraceContext(true)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{32}$`), tc.TraceIDString())
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{16}$`), tc.SpanIDString())
+
+	other, err := NewTraceContext(true)
+	require.NoError(t, err)
+	assert.NotEqual(t, tc.TraceID, other.TraceID)
+
+	tc = TraceContext{
+		TraceID: [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	}
+	testdata := map[string]struct {
+		propagator string
+		sampled    bool
+		headers    map[string]string
+	}{
+		"w3c": {PropagatorW3C, true, map[string]string{
+			"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		}},
+		"w3c unsampled": {PropagatorW3C, false, map[string]string{
+			"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		}},
+		"b3": {PropagatorB3, true, map[string]string{
+			"B3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		}},
+		"b3multi": {PropagatorB3Multi, false, map[string]string{
+			"X-B3-Traceid": "4bf92f3577b34da6a3ce929d0e0e4736",
+			"X-B3-Spanid":  "00f067aa0ba902b7",
+			"X-B3-Sampled": "0",
+		}},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			tc.Sampled = data.sampled
+			header := make(http.Header)
+			assert.True(t, tc.Inject(data.propagator, header))
+			assert.Len(t, header, len(data.headers))
+			for k, v := range data.headers {
+				assert.Equal(t, v, header.Get(k), k)
+			}
+		})
+	}
+	t.Run("unknown", func(t *testing.T) {
+		header := make(http.Header)
+		assert.False(t, tc.Inject("jaeger", header))
+		assert.Empty(t, header)
+	})
+}
@@ -24,8 +24,10 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"net"
+	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -33,6 +35,7 @@ import (
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -68,11 +71,46 @@ func TestOptions(t *testing.T) {
 		assert.Len(t, opts.Stages, 1)
 		assert.Equal(t, 1*time.Second, time.Duration(opts.Stages[0].Duration.Duration))
 	})
+	t.Run("Scenarios", func(t *testing.T) {
+		opts := Options{}.Apply(Options{Scenarios: Scenarios{
+			"browse": {Executor: ConstantVUsExecutor, Duration: types.NullDurationFrom(1 * time.Second)},
+		}})
+		assert.Len(t, opts.Scenarios, 1)
+		assert.Equal(t, ConstantVUsExecutor, opts.Scenarios["browse"].Executor)
+
+		var jsonOpts Options
+		assert.NoError(t, json.Unmarshal([]byte(`{"scenarios": {"api": {
+			"executor": "ramping-vus", "startTime": "10s", "exec": "api",
+			"env": {"HOST": "api.example.com"}, "tags": {"type": "api"},
+			"stages": [{"duration": "1m", "target": 5}]
+		}}}`), &jsonOpts))
+		assert.Equal(t, Scenarios{"api": {
+			Executor:  RampingVUsExecutor,
+			StartTime: types.NullDurationFrom(10 * time.Second),
+			Exec:      null.StringFrom("api"),
+			Env:       map[string]string{"HOST": "api.example.com"},
+			Tags:      map[string]string{"type": "api"},
+			Stages:    []Stage{{Duration: types.NullDurationFrom(1 * time.Minute), Target: null.IntFrom(5)}},
+		}}, jsonOpts.Scenarios)
+	})
 	t.Run("RPS", func(t *testing.T) {
 		opts := Options{}.Apply(Options{RPS: null.IntFrom(12345)})
 		assert.True(t, opts.RPS.Valid)
 		assert.Equal(t, int64(12345), opts.RPS.Int64)
 	})
+	t.Run("Seed", func(t *testing.T) {
+		opts := Options{}.Apply(Options{Seed: null.IntFrom(42)})
+		assert.True(t, opts.Seed.Valid)
+		assert.Equal(t, int64(42), opts.Seed.Int64)
+	})
+	t.Run("SetupTimeout", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SetupTimeout: types.NullDurationFrom(20 * time.Second)})
+		assert.Equal(t, types.NullDurationFrom(20*time.Second), opts.SetupTimeout)
+	})
+	t.Run("TeardownTimeout", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TeardownTimeout: types.NullDurationFrom(20 * time.Second)})
+		assert.Equal(t, types.NullDurationFrom(20*time.Second), opts.TeardownTimeout)
+	})
 	t.Run("MaxRedirects", func(t *testing.T) {
 		opts := Options{}.Apply(Options{MaxRedirects: null.IntFrom(12345)})
 		assert.True(t, opts.MaxRedirects.Valid)
@@ -83,6 +121,15 @@ func TestOptions(t *testing.T) {
 		assert.True(t, opts.UserAgent.Valid)
 		assert.Equal(t, "foo", opts.UserAgent.String)
 	})
+	t.Run("ClientProfiles", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ClientProfiles: ClientProfileMix{"safari-ios": 1}})
+		assert.Equal(t, ClientProfileMix{"safari-ios": 1}, opts.ClientProfiles)
+	})
+	t.Run("GeoRegions", func(t *testing.T) {
+		regions := GeoRegions{"eu": {Weight: 1, Headers: map[string]string{"Accept-Language": "de-DE"}}}
+		opts := Options{}.Apply(Options{GeoRegions: regions})
+		assert.Equal(t, regions, opts.GeoRegions)
+	})
 	t.Run("Batch", func(t *testing.T) {
 		opts := Options{}.Apply(Options{Batch: null.IntFrom(12345)})
 		assert.True(t, opts.Batch.Valid)
@@ -280,14 +327,66 @@ func TestOptions(t *testing.T) {
 	})
 
 	t.Run("Hosts", func(t *testing.T) {
-		opts := Options{}.Apply(Options{Hosts: map[string]net.IP{
-			"test.loadimpact.com": net.ParseIP("192.0.2.1"),
+		opts := Options{}.Apply(Options{Hosts: types.Hosts{
+			"test.loadimpact.com": {IP: net.ParseIP("192.0.2.1")},
 		}})
 		assert.NotNil(t, opts.Hosts)
 		assert.NotEmpty(t, opts.Hosts)
 		assert.Equal(t, "192.0.2.1", opts.Hosts["test.loadimpact.com"].String())
 	})
 
+	t.Run("BlockHostnames", func(t *testing.T) {
+		opts := Options{}.Apply(Options{BlockHostnames: types.Hostnames{"*.example.com"}})
+		assert.Equal(t, types.Hostnames{"*.example.com"}, opts.BlockHostnames)
+	})
+
+	t.Run("DNS", func(t *testing.T) {
+		opts := Options{DNS: DNSConfig{TTL: types.NullDurationFrom(time.Minute)}}.
+			Apply(Options{DNS: DNSConfig{Select: null.StringFrom("roundRobin")}})
+		assert.Equal(t, DNSConfig{TTL: types.NullDurationFrom(time.Minute), Select: null.StringFrom("roundRobin")}, opts.DNS)
+	})
+
+	t.Run("Proxy", func(t *testing.T) {
+		proxyURL, _ := url.Parse("socks5://192.0.2.1:1080")
+		opts := Options{}.Apply(Options{Proxy: types.NullProxyFrom(proxyURL)})
+		assert.Equal(t, types.NullProxyFrom(proxyURL), opts.Proxy)
+		opts = opts.Apply(Options{Proxy: types.NullProxyFrom(nil)})
+		assert.Equal(t, types.NullProxyFrom(nil), opts.Proxy)
+		opts = opts.Apply(Options{})
+		assert.Equal(t, types.NullProxyFrom(nil), opts.Proxy)
+	})
+
+	t.Run("HTTPVersion", func(t *testing.T) {
+		opts := Options{}.Apply(Options{HTTPVersion: types.NullHTTPVersionFrom(types.HTTPVersion3)})
+		assert.Equal(t, types.NullHTTPVersionFrom(types.HTTPVersion3), opts.HTTPVersion)
+		opts = opts.Apply(Options{})
+		assert.Equal(t, types.NullHTTPVersionFrom(types.HTTPVersion3), opts.HTTPVersion)
+	})
+
+	t.Run("Tracing", func(t *testing.T) {
+		opts := Options{Tracing: TracingConfig{Sampling: null.FloatFrom(0.1)}}.
+			Apply(Options{Tracing: TracingConfig{Propagator: null.StringFrom("w3c")}})
+		assert.Equal(t, TracingConfig{Propagator: null.StringFrom("w3c"), Sampling: null.FloatFrom(0.1)}, opts.Tracing)
+	})
+
+	t.Run("HTTPRetry", func(t *testing.T) {
+		opts := Options{HTTPRetry: RetryConfig{Statuses: []int{503}}}.
+			Apply(Options{HTTPRetry: RetryConfig{Attempts: null.IntFrom(3)}})
+		assert.Equal(t, RetryConfig{Attempts: null.IntFrom(3), Statuses: []int{503}}, opts.HTTPRetry)
+	})
+
+	t.Run("HostLimits", func(t *testing.T) {
+		limits := HostLimits{"example.com": {RPS: null.IntFrom(10)}}
+		opts := Options{}.Apply(Options{HostLimits: limits})
+		assert.Equal(t, limits, opts.HostLimits)
+	})
+
+	t.Run("DiscardResponseBodies", func(t *testing.T) {
+		opts := Options{}.Apply(Options{DiscardResponseBodies: null.BoolFrom(true)})
+		assert.True(t, opts.DiscardResponseBodies.Valid)
+		assert.True(t, opts.DiscardResponseBodies.Bool)
+	})
+
 	t.Run("Throws", func(t *testing.T) {
 		opts := Options{}.Apply(Options{Throw: null.BoolFrom(true)})
 		assert.True(t, opts.Throw.Valid)
@@ -344,6 +443,19 @@ func TestOptions(t *testing.T) {
 				assert.Nil(t, opts.SystemTags)
 			})
 		})
+		t.Run("Text", func(t *testing.T) {
+			var tags TagSet
+			assert.NoError(t, tags.UnmarshalText([]byte("url, name,,vu")))
+			assert.Equal(t, GetTagSet("url", "name", "vu"), tags)
+			assert.NoError(t, tags.UnmarshalText([]byte("")))
+			assert.Equal(t, TagSet{}, tags)
+		})
+		t.Run("Validate", func(t *testing.T) {
+			assert.NoError(t, GetTagSet(SystemTagList...).Validate())
+			assert.NoError(t, TagSet{}.Validate())
+			assert.EqualError(t, GetTagSet("url", "urls", "a").Validate(),
+				"unknown system tags: a, urls, they can be: "+strings.Join(SystemTagList, ", "))
+		})
 	})
 	t.Run("SummaryTrendStats", func(t *testing.T) {
 		stats := []string{"myStat1", "myStat2"}
@@ -355,6 +467,46 @@ func TestOptions(t *testing.T) {
 		opts := Options{}.Apply(Options{RunTags: tags})
 		assert.Equal(t, tags, opts.RunTags)
 	})
+	t.Run("RunID", func(t *testing.T) {
+		opts := Options{}.Apply(Options{RunID: null.StringFrom("abc123")})
+		assert.Equal(t, null.StringFrom("abc123"), opts.RunID)
+	})
+	t.Run("Metadata", func(t *testing.T) {
+		metadata := map[string]string{"environment": "staging"}
+		opts := Options{}.Apply(Options{Metadata: metadata})
+		assert.Equal(t, metadata, opts.Metadata)
+	})
+}
+
+func TestOptionsWithRunMetadata(t *testing.T) {
+	t.Run("Generated", func(t *testing.T) {
+		a, err := Options{SystemTags: GetTagSet(DefaultSystemTagList...)}.WithRunMetadata()
+		require.NoError(t, err)
+		b, err := Options{SystemTags: GetTagSet(DefaultSystemTagList...)}.WithRunMetadata()
+		require.NoError(t, err)
+		assert.Len(t, a.RunID.String, 32)
+		assert.NotEqual(t, a.RunID, b.RunID)
+		assert.Equal(t, map[string]string{"run_id": a.RunID.String}, a.RunTags.CloneTags())
+	})
+	t.Run("Given", func(t *testing.T) {
+		opts, err := Options{
+			SystemTags: GetTagSet(DefaultSystemTagList...),
+			RunID:      null.StringFrom("abc123"),
+			Metadata:   map[string]string{"environment": "staging", "commit": "4f2a1c"},
+			RunTags:    stats.IntoSampleTags(&map[string]string{"environment": "prod", "team": "web"}),
+		}.WithRunMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, null.StringFrom("abc123"), opts.RunID)
+		assert.Equal(t, map[string]string{
+			"run_id": "abc123", "environment": "prod", "commit": "4f2a1c", "team": "web",
+		}, opts.RunTags.CloneTags())
+	})
+	t.Run("NoSystemTag", func(t *testing.T) {
+		opts, err := Options{SystemTags: GetTagSet("url")}.WithRunMetadata()
+		require.NoError(t, err)
+		assert.True(t, opts.RunID.Valid)
+		assert.Nil(t, opts.RunTags)
+	})
 }
 
 func TestOptionsEnv(t *testing.T) {
@@ -410,10 +562,38 @@ func TestOptionsEnv(t *testing.T) {
 			"true":  null.BoolFrom(true),
 			"false": null.BoolFrom(false),
 		},
+		{"DiscardResponseBodies", "K6_DISCARD_RESPONSE_BODIES"}: {
+			"":      null.Bool{},
+			"true":  null.BoolFrom(true),
+			"false": null.BoolFrom(false),
+		},
+		{"RunID", "K6_RUN_ID"}: {
+			"":       null.String{},
+			"abc123": null.StringFrom("abc123"),
+		},
+		{"Metadata", "K6_METADATA"}: {
+			"environment:staging,commit:4f2a1c": map[string]string{"environment": "staging", "commit": "4f2a1c"},
+		},
 		{"UserAgent", "K6_USER_AGENT"}: {
 			"":    null.String{},
 			"Hi!": null.StringFrom("Hi!"),
 		},
+		{"DNS", "K6_DNS"}: {
+			"":                      DNSConfig{},
+			"ttl=0,policy=onlyIPv4": DNSConfig{TTL: types.NullDurationFrom(0), Policy: null.StringFrom("onlyIPv4")},
+		},
+		{"Tracing", "K6_TRACING"}: {
+			"":              TracingConfig{},
+			"propagator=b3": TracingConfig{Propagator: null.StringFrom("b3")},
+		},
+		{"HTTPRetry", "K6_HTTP_RETRY"}: {
+			"":                        RetryConfig{},
+			"attempts=3,statuses=503": RetryConfig{Attempts: null.IntFrom(3), Statuses: []int{503}},
+		},
+		{"Preconnect", "K6_PRECONNECT"}: {
+			"":                                       PreconnectConfig{},
+			"urls=https://example.com,connections=2": PreconnectConfig{URLs: []string{"https://example.com"}, Connections: null.IntFrom(2)},
+		},
 		{"Throw", "K6_THROW"}: {
 			"":      null.Bool{},
 			"true":  null.BoolFrom(true),
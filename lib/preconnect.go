@@ -0,0 +1,183 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// PreconnectConfig has every VU open connections to some hosts before the test starts, so its
+// first seconds measure the system under test rather than all VUs connecting at once. The
+// connections are kept idle, and the VU's requests use them when the test starts.
+type PreconnectConfig struct {
+	// The URLs of the hosts to connect to, eg. https://test.k6.io; every VU makes a HEAD request
+	// to each of them over every connection.
+	URLs []string
+
+	// How many connections every VU opens to each URL; 1 if not set.
+	Connections null.Int
+
+	// Share TLS sessions between the VUs, so connections made to a host after the first resume
+	// its session rather than doing a full handshake.
+	ReuseTLSSessions null.Bool
+}
+
+// Apply returns the config with the fields set on the argument overwritten.
+func (c PreconnectConfig) Apply(cfg PreconnectConfig) PreconnectConfig {
+	if cfg.URLs != nil {
+		c.URLs = cfg.URLs
+	}
+	if cfg.Connections.Valid {
+		c.Connections = cfg.Connections
+	}
+	if cfg.ReuseTLSSessions.Valid {
+		c.ReuseTLSSessions = cfg.ReuseTLSSessions
+	}
+	return c
+}
+
+// Enabled returns whether VUs connect to anything before the test starts.
+func (c PreconnectConfig) Enabled() bool {
+	return len(c.URLs) > 0
+}
+
+// ConnectionsPerURL returns how many connections every VU opens to each URL.
+func (c PreconnectConfig) ConnectionsPerURL() int {
+	if c.Connections.Valid {
+		return int(c.Connections.Int64)
+	}
+	return 1
+}
+
+func (c *PreconnectConfig) set(key, value string) error {
+	switch key {
+	case "urls":
+		urls := []string{}
+		for _, s := range strings.Fields(value) {
+			u, err := url.Parse(s)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.Errorf("invalid preconnect URL: %s, it has to be an http or https URL", s)
+			}
+			urls = append(urls, s)
+		}
+		c.URLs = urls
+	case "connections":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 1 {
+			return errors.Errorf("invalid preconnect connections: %s, it has to be a whole number from 1", value)
+		}
+		c.Connections = null.IntFrom(n)
+	case "reuseTLSSessions":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("invalid preconnect reuseTLSSessions: %s, it has to be true or false", value)
+		}
+		c.ReuseTLSSessions = null.BoolFrom(b)
+	default:
+		return errors.Errorf("unknown preconnect option: %s, they are urls, connections and reuseTLSSessions", key)
+	}
+	return nil
+}
+
+// UnmarshalText reads the config from a list like "urls=https://a.example.com https://b.example.com,
+// connections=4", eg. from the --preconnect flag or the K6_PRECONNECT environment variable.
+func (c *PreconnectConfig) UnmarshalText(data []byte) error {
+	var cfg PreconnectConfig
+	for _, kv := range strings.Split(string(data), ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("invalid preconnect option: %s, it has to be key=value", kv)
+		}
+		if err := cfg.set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// UnmarshalJSON reads the config from an object, with the URLs in an array.
+func (c *PreconnectConfig) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var cfg PreconnectConfig
+	for key, raw := range fields {
+		if bytes.Equal(raw, []byte(`null`)) {
+			continue
+		}
+		var value string
+		switch key {
+		case "urls":
+			var urls []string
+			if err := json.Unmarshal(raw, &urls); err != nil {
+				return errors.Errorf("invalid preconnect urls: %s", raw)
+			}
+			value = strings.Join(urls, " ")
+		default:
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			switch v := v.(type) {
+			case float64:
+				value = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				value = strconv.FormatBool(v)
+			case string:
+				value = v
+			default:
+				return errors.Errorf("invalid preconnect %s: %s", key, raw)
+			}
+		}
+		if err := cfg.set(key, value); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// MarshalJSON writes the config as an object, leaving out what isn't set.
+func (c PreconnectConfig) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{}
+	if c.URLs != nil {
+		fields["urls"] = c.URLs
+	}
+	if c.Connections.Valid {
+		fields["connections"] = c.Connections.Int64
+	}
+	if c.ReuseTLSSessions.Valid {
+		fields["reuseTLSSessions"] = c.ReuseTLSSessions.Bool
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,86 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestPreconnectConfig(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		var c PreconnectConfig
+		assert.NoError(t, c.UnmarshalText([]byte("urls=https://a.example.com http://b.example.com:8080, connections=4, reuseTLSSessions=true")))
+		assert.Equal(t, PreconnectConfig{
+			URLs:             []string{"https://a.example.com", "http://b.example.com:8080"},
+			Connections:      null.IntFrom(4),
+			ReuseTLSSessions: null.BoolFrom(true),
+		}, c)
+
+		testdata := map[string]string{
+			"urls=a.example.com":     "invalid preconnect URL: a.example.com, it has to be an http or https URL",
+			"urls=ws://example.com":  "invalid preconnect URL: ws://example.com, it has to be an http or https URL",
+			"connections=0":          "invalid preconnect connections: 0, it has to be a whole number from 1",
+			"reuseTLSSessions=maybe": "invalid preconnect reuseTLSSessions: maybe, it has to be true or false",
+			"keepAlive=30s":          "unknown preconnect option: keepAlive, they are urls, connections and reuseTLSSessions",
+			"connections":            "invalid preconnect option: connections, it has to be key=value",
+		}
+		for text, msg := range testdata {
+			assert.EqualError(t, c.UnmarshalText([]byte(text)), msg)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var c PreconnectConfig
+		assert.NoError(t, json.Unmarshal([]byte(`{"urls": ["https://a.example.com"], "connections": 2, "reuseTLSSessions": false}`), &c))
+		assert.Equal(t, PreconnectConfig{
+			URLs:             []string{"https://a.example.com"},
+			Connections:      null.IntFrom(2),
+			ReuseTLSSessions: null.BoolFrom(false),
+		}, c)
+
+		data, err := json.Marshal(c)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"urls": ["https://a.example.com"], "connections": 2, "reuseTLSSessions": false}`, string(data))
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"urls": "https://a.example.com"}`), &c),
+			`invalid preconnect urls: "https://a.example.com"`)
+		assert.EqualError(t, json.Unmarshal([]byte(`{"connections": 1.5}`), &c),
+			"invalid preconnect connections: 1.5, it has to be a whole number from 1")
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		assert.False(t, PreconnectConfig{}.Enabled())
+		assert.True(t, PreconnectConfig{URLs: []string{"https://a.example.com"}}.Enabled())
+		assert.Equal(t, 1, PreconnectConfig{}.ConnectionsPerURL())
+		assert.Equal(t, 3, PreconnectConfig{Connections: null.IntFrom(3)}.ConnectionsPerURL())
+
+		c := PreconnectConfig{URLs: []string{"https://a.example.com"}, Connections: null.IntFrom(3)}
+		assert.Equal(t, PreconnectConfig{
+			URLs:             []string{"https://a.example.com"},
+			Connections:      null.IntFrom(3),
+			ReuseTLSSessions: null.BoolFrom(true),
+		}, c.Apply(PreconnectConfig{ReuseTLSSessions: null.BoolFrom(true)}))
+	})
+}
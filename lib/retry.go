@@ -0,0 +1,231 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// DefaultRetryStatuses are the response statuses a request is retried on when the retry config
+// doesn't list any; requests that fail without a response are always retried.
+var DefaultRetryStatuses = []int{429, 502, 503, 504}
+
+// The backoff before the first retry, and the most any backoff grows to, when they aren't set.
+const (
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 10 * time.Second
+)
+
+// RetryConfig makes HTTP requests again when they fail in a way that's likely to be transient,
+// waiting twice as long before every retry; each attempt is measured on its own.
+type RetryConfig struct {
+	// How many times a request is made at most, including the first; 1 or less is no retries.
+	Attempts null.Int
+
+	// The statuses a response is retried on, if not the defaults.
+	Statuses []int
+
+	// The wait before the first retry, and the most a wait grows to.
+	Backoff    types.NullDuration
+	MaxBackoff types.NullDuration
+}
+
+// Apply returns the config with the fields set on the argument overwritten.
+func (c RetryConfig) Apply(cfg RetryConfig) RetryConfig {
+	if cfg.Attempts.Valid {
+		c.Attempts = cfg.Attempts
+	}
+	if cfg.Statuses != nil {
+		c.Statuses = cfg.Statuses
+	}
+	if cfg.Backoff.Valid {
+		c.Backoff = cfg.Backoff
+	}
+	if cfg.MaxBackoff.Valid {
+		c.MaxBackoff = cfg.MaxBackoff
+	}
+	return c
+}
+
+// Enabled returns whether failed requests are made again.
+func (c RetryConfig) Enabled() bool {
+	return c.Attempts.Valid && c.Attempts.Int64 > 1
+}
+
+// Retryable returns whether a request that got the given status, 0 if it got no response, is
+// worth making again.
+func (c RetryConfig) Retryable(status int) bool {
+	if status == 0 {
+		return true
+	}
+	statuses := c.Statuses
+	if statuses == nil {
+		statuses = DefaultRetryStatuses
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// BackoffFor returns how long to wait before the given retry, counted from 1.
+func (c RetryConfig) BackoffFor(retry int) time.Duration {
+	backoff, max := DefaultRetryBackoff, DefaultRetryMaxBackoff
+	if c.Backoff.Valid {
+		backoff = time.Duration(c.Backoff.Duration)
+	}
+	if c.MaxBackoff.Valid {
+		max = time.Duration(c.MaxBackoff.Duration)
+	}
+	for i := 1; i < retry && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+func (c *RetryConfig) set(key, value string) error {
+	switch key {
+	case "attempts":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 1 {
+			return errors.Errorf("invalid retry attempts: %s, it has to be a whole number from 1", value)
+		}
+		c.Attempts = null.IntFrom(n)
+	case "statuses":
+		statuses := []int{}
+		for _, s := range strings.Fields(value) {
+			status, err := strconv.Atoi(s)
+			if err != nil || status < 100 || status > 599 {
+				return errors.Errorf("invalid retry status: %s", s)
+			}
+			statuses = append(statuses, status)
+		}
+		c.Statuses = statuses
+	case "backoff", "maxBackoff":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return errors.Errorf("invalid retry %s: %s, it has to be a duration like 100ms", key, value)
+		}
+		if key == "backoff" {
+			c.Backoff = types.NullDurationFrom(d)
+		} else {
+			c.MaxBackoff = types.NullDurationFrom(d)
+		}
+	default:
+		return errors.Errorf("unknown retry option: %s, they are attempts, statuses, backoff and maxBackoff", key)
+	}
+	return nil
+}
+
+// UnmarshalText reads the config from a list like "attempts=3,statuses=502 503,backoff=200ms",
+// eg. from the --http-retry flag or the K6_HTTP_RETRY environment variable.
+func (c *RetryConfig) UnmarshalText(data []byte) error {
+	var cfg RetryConfig
+	for _, kv := range strings.Split(string(data), ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("invalid retry option: %s, it has to be key=value", kv)
+		}
+		if err := cfg.set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// UnmarshalJSON reads the config from an object; statuses are an array, and backoffs durations
+// like "100ms" or, like other times scripts deal with, numbers of milliseconds.
+func (c *RetryConfig) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var cfg RetryConfig
+	for key, raw := range fields {
+		if bytes.Equal(raw, []byte(`null`)) {
+			continue
+		}
+		var value string
+		switch key {
+		case "statuses":
+			var statuses []int
+			if err := json.Unmarshal(raw, &statuses); err != nil {
+				return errors.Errorf("invalid retry statuses: %s", raw)
+			}
+			parts := make([]string, len(statuses))
+			for i, s := range statuses {
+				parts[i] = strconv.Itoa(s)
+			}
+			value = strings.Join(parts, " ")
+		default:
+			if err := json.Unmarshal(raw, &value); err != nil {
+				var f float64
+				if json.Unmarshal(raw, &f) != nil {
+					return errors.Errorf("invalid retry %s: %s", key, raw)
+				}
+				value = strconv.FormatFloat(f, 'f', -1, 64)
+				if key == "backoff" || key == "maxBackoff" {
+					value += "ms"
+				}
+			}
+		}
+		if err := cfg.set(key, value); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// MarshalJSON writes the config as an object, leaving out what isn't set.
+func (c RetryConfig) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{}
+	if c.Attempts.Valid {
+		fields["attempts"] = c.Attempts.Int64
+	}
+	if c.Statuses != nil {
+		fields["statuses"] = c.Statuses
+	}
+	if c.Backoff.Valid {
+		fields["backoff"] = c.Backoff.String()
+	}
+	if c.MaxBackoff.Valid {
+		fields["maxBackoff"] = c.MaxBackoff.String()
+	}
+	return json.Marshal(fields)
+}
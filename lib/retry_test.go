@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestRetryConfig(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		var c RetryConfig
+		assert.NoError(t, c.UnmarshalText([]byte("attempts=3, statuses=500 503, backoff=50ms, maxBackoff=1s")))
+		assert.Equal(t, RetryConfig{
+			Attempts:   null.IntFrom(3),
+			Statuses:   []int{500, 503},
+			Backoff:    types.NullDurationFrom(50 * time.Millisecond),
+			MaxBackoff: types.NullDurationFrom(time.Second),
+		}, c)
+
+		testdata := map[string]string{
+			"attempts=0":     "invalid retry attempts: 0, it has to be a whole number from 1",
+			"statuses=5xx":   "invalid retry status: 5xx",
+			"backoff=fast":   "invalid retry backoff: fast, it has to be a duration like 100ms",
+			"jitter=0.1":     "unknown retry option: jitter, they are attempts, statuses, backoff and maxBackoff",
+			"attempts":       "invalid retry option: attempts, it has to be key=value",
+			"maxBackoff=-1s": "invalid retry maxBackoff: -1s, it has to be a duration like 100ms",
+		}
+		for text, msg := range testdata {
+			assert.EqualError(t, c.UnmarshalText([]byte(text)), msg)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var c RetryConfig
+		assert.NoError(t, json.Unmarshal([]byte(`{"attempts": 4, "statuses": [502], "backoff": 250, "maxBackoff": "2s"}`), &c))
+		assert.Equal(t, RetryConfig{
+			Attempts:   null.IntFrom(4),
+			Statuses:   []int{502},
+			Backoff:    types.NullDurationFrom(250 * time.Millisecond),
+			MaxBackoff: types.NullDurationFrom(2 * time.Second),
+		}, c)
+
+		data, err := json.Marshal(c)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"attempts": 4, "statuses": [502], "backoff": "250ms", "maxBackoff": "2s"}`, string(data))
+
+		assert.NoError(t, json.Unmarshal([]byte(`{"attempts": 2, "statuses": []}`), &c))
+		assert.Equal(t, RetryConfig{Attempts: null.IntFrom(2), Statuses: []int{}}, c)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"statuses": "503"}`), &c), `invalid retry statuses: "503"`)
+		assert.EqualError(t, json.Unmarshal([]byte(`{"attempts": 1.5}`), &c),
+			"invalid retry attempts: 1.5, it has to be a whole number from 1")
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		assert.False(t, RetryConfig{}.Enabled())
+		assert.False(t, RetryConfig{Attempts: null.IntFrom(1)}.Enabled())
+		assert.True(t, RetryConfig{Attempts: null.IntFrom(2)}.Enabled())
+
+		assert.True(t, RetryConfig{}.Retryable(0))
+		assert.True(t, RetryConfig{}.Retryable(503))
+		assert.False(t, RetryConfig{}.Retryable(500))
+		assert.True(t, RetryConfig{Statuses: []int{500}}.Retryable(500))
+		assert.False(t, RetryConfig{Statuses: []int{}}.Retryable(503))
+
+		assert.Equal(t, 100*time.Millisecond, RetryConfig{}.BackoffFor(1))
+		assert.Equal(t, 400*time.Millisecond, RetryConfig{}.BackoffFor(3))
+		assert.Equal(t, 10*time.Second, RetryConfig{}.BackoffFor(20))
+		c := RetryConfig{Backoff: types.NullDurationFrom(time.Second), MaxBackoff: types.NullDurationFrom(3 * time.Second)}
+		assert.Equal(t, 2*time.Second, c.BackoffFor(2))
+		assert.Equal(t, 3*time.Second, c.BackoffFor(3))
+	})
+}
@@ -22,6 +22,8 @@ package lib
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 
 	"github.com/loadimpact/k6/stats"
 )
@@ -45,12 +47,22 @@ type Runner interface {
 	// of a test - RunOnce() may be called hundreds of thousands of times, and must be fast.
 	NewVU() (VU, error)
 
-	// Runs pre-test setup, if applicable.
+	// Runs pre-test setup, if applicable, or, if the context has a scenario, the scenario's own.
 	Setup(ctx context.Context) error
 
-	// Runs post-test teardown, if applicable.
+	// Runs post-test teardown, if applicable, or, if the context has a scenario, the scenario's own.
 	Teardown(ctx context.Context) error
 
+	// Get and set the data setup() returned, as JSON, eg. to hand it to other instances of a
+	// distributed test, which don't run setup() themselves.
+	GetSetupData() json.RawMessage
+	SetSetupData(data json.RawMessage) error
+
+	// Hands the end-of-test summary to the script, if it wants it, and returns the files it made
+	// of it by name ("stdout" and "stderr" are the standard streams), or nil if the script doesn't
+	// handle the summary itself.
+	HandleSummary(ctx context.Context, summary map[string]interface{}) (map[string]io.Reader, error)
+
 	// Returns the default (root) Group.
 	GetDefaultGroup() *Group
 
@@ -77,6 +89,9 @@ type MiniRunner struct {
 	Fn         func(ctx context.Context) ([]stats.Sample, error)
 	SetupFn    func(ctx context.Context) error
 	TeardownFn func(ctx context.Context) error
+	SetupData  json.RawMessage
+
+	HandleSummaryFn func(ctx context.Context, summary map[string]interface{}) (map[string]io.Reader, error)
 
 	Group   *Group
 	Options Options
@@ -108,6 +123,22 @@ func (r MiniRunner) Teardown(ctx context.Context) error {
 	return nil
 }
 
+func (r MiniRunner) GetSetupData() json.RawMessage {
+	return r.SetupData
+}
+
+func (r *MiniRunner) SetSetupData(data json.RawMessage) error {
+	r.SetupData = data
+	return nil
+}
+
+func (r MiniRunner) HandleSummary(ctx context.Context, summary map[string]interface{}) (map[string]io.Reader, error) {
+	if fn := r.HandleSummaryFn; fn != nil {
+		return fn(ctx, summary)
+	}
+	return nil, nil
+}
+
 func (r MiniRunner) GetDefaultGroup() *Group {
 	if r.Group == nil {
 		r.Group = &Group{}
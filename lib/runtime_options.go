@@ -20,7 +20,10 @@
 
 package lib
 
-import null "gopkg.in/guregu/null.v3"
+import (
+	"github.com/loadimpact/k6/lib/secrets"
+	null "gopkg.in/guregu/null.v3"
+)
 
 // RuntimeOptions are settings passed onto the goja JS runtime
 type RuntimeOptions struct {
@@ -29,6 +32,13 @@ type RuntimeOptions struct {
 
 	// Environment variables passed onto the runner
 	Env map[string]string `json:"env" envconfig:"env"`
+
+	// Experimental features enabled for the test run
+	Enable []string `json:"enable" envconfig:"enable"`
+
+	// Where the secrets scripts get with k6/secrets come from; never serialized, so that they
+	// can't end up in archives
+	Secrets *secrets.Manager `json:"-" ignored:"true"`
 }
 
 // Apply overwrites the receiver RuntimeOptions' fields with any that are set
@@ -40,5 +50,11 @@ func (o RuntimeOptions) Apply(opts RuntimeOptions) RuntimeOptions {
 	if opts.Env != nil {
 		o.Env = opts.Env
 	}
+	if opts.Enable != nil {
+		o.Enable = opts.Enable
+	}
+	if opts.Secrets != nil {
+		o.Secrets = opts.Secrets
+	}
 	return o
 }
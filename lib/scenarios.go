@@ -0,0 +1,644 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// The executors a scenario can be run with.
+const (
+	// ConstantVUsExecutor loops a fixed number of VUs for a duration.
+	ConstantVUsExecutor = "constant-vus"
+
+	// RampingVUsExecutor loops a number of VUs that's ramped up and down in stages.
+	RampingVUsExecutor = "ramping-vus"
+
+	// ConstantArrivalRateExecutor starts iterations at a fixed rate for a duration, whether or not
+	// the ones before have finished, starting more VUs when all of them are busy.
+	ConstantArrivalRateExecutor = "constant-arrival-rate"
+
+	// ExternallyControlledExecutor loops a number of VUs for a duration, which is scaled from the
+	// REST API or `k6 scale` while the test runs, up to a max.
+	ExternallyControlledExecutor = "externally-controlled"
+
+	// SharedIterationsExecutor runs a number of iterations in total, on whichever of its VUs is
+	// free, within a max duration.
+	SharedIterationsExecutor = "shared-iterations"
+
+	// PerVUIterationsExecutor has each of its VUs run a number of iterations, within a max
+	// duration.
+	PerVUIterationsExecutor = "per-vu-iterations"
+)
+
+// DefaultMaxDuration is how long the iteration based executors are given to run all of their
+// iterations by default.
+const DefaultMaxDuration = 10 * time.Minute
+
+// DefaultGracefulStop is how long iterations in flight are given to finish by default, when their
+// scenario ends or ramps down their VUs, before they're interrupted.
+const DefaultGracefulStop = 30 * time.Second
+
+// scenarioNameRE is what scenario names may look like; they end up in tags and metric names.
+var scenarioNameRE = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// A Scenario is a workload run by a test, independently of its other scenarios. Which of the
+// fields apply depends on the executor it's run with.
+type Scenario struct {
+	// How the scenario schedules its VUs and iterations.
+	Executor string `json:"executor"`
+
+	// When the scenario starts, from the start of the test, or from the end of the scenario
+	// named in startAfter, to run them back to back.
+	StartTime  types.NullDuration `json:"startTime"`
+	StartAfter null.String        `json:"startAfter"`
+
+	// How long the scenario's samples are left out of the metrics, like the test's warmup, from
+	// the first one it emits.
+	Warmup types.NullDuration `json:"warmup"`
+
+	// The exported function its iterations run, "default" by default, and environment variables
+	// and tags they're run with, on top of the test-wide ones.
+	Exec null.String       `json:"exec"`
+	Env  map[string]string `json:"env"`
+	Tags map[string]string `json:"tags"`
+
+	// The built-in client profile the scenario's requests are made as, instead of the one the VU
+	// was assigned from the clientProfiles mix. It sets the requests' headers and the TLS hints
+	// of the connections they're made over.
+	ClientProfile null.String `json:"clientProfile"`
+
+	// Exported functions run before the scenario's first iteration and after its last one, on
+	// top of the test's setup() and teardown(); setup is passed the test's setup data, and what
+	// it returns is what the scenario's iterations, and its teardown, are passed instead.
+	Setup    null.String `json:"setup"`
+	Teardown null.String `json:"teardown"`
+
+	// How long iterations in flight are given to finish when the scenario ends, and, for
+	// ramping-vus, when its VUs are ramped down, before they're interrupted.
+	GracefulStop     types.NullDuration `json:"gracefulStop"`
+	GracefulRampDown types.NullDuration `json:"gracefulRampDown"`
+
+	// Whether every iteration starts from a fresh VU, without the connections or the state of
+	// the script, eg. in module-level variables, that the iterations before left behind. Each
+	// iteration has its own cookies either way.
+	FreshVUState null.Bool `json:"freshVUState"`
+
+	// Limits on the iterations the scenario starts, and the HTTP requests its VUs make, per
+	// second, on top of the test-wide ones; so the VU based executors can be paced to a
+	// throughput. Arrival rates have a rate of their own instead of an iteration rate.
+	IterationRate null.Int `json:"iterationRate"`
+	RPS           null.Int `json:"rps"`
+
+	// Options of the constant-vus executor; the duration is constant-arrival-rate's and
+	// externally-controlled's too, and the VUs are what externally-controlled starts with, and
+	// what the iteration based executors run.
+	VUs      null.Int           `json:"vus"`
+	Duration types.NullDuration `json:"duration"`
+
+	// Options of the ramping-vus executor.
+	StartVUs null.Int `json:"startVUs"`
+	Stages   []Stage  `json:"stages"`
+
+	// Options of the constant-arrival-rate executor: rate iterations are started every time unit
+	// (1s by default), by preAllocatedVUs VUs, or up to maxVUs if those are all busy. maxVUs is
+	// also how far an externally-controlled scenario can be scaled.
+	Rate            null.Int           `json:"rate"`
+	TimeUnit        types.NullDuration `json:"timeUnit"`
+	PreAllocatedVUs null.Int           `json:"preAllocatedVUs"`
+	MaxVUs          null.Int           `json:"maxVUs"`
+
+	// Options of the shared-iterations and per-vu-iterations executors: how many iterations are
+	// run in total, or by each VU, 1 by default, and how long they're given to.
+	Iterations  null.Int           `json:"iterations"`
+	MaxDuration types.NullDuration `json:"maxDuration"`
+}
+
+// Scenarios are a test's scenarios by name.
+type Scenarios map[string]Scenario
+
+// Validate checks that a scenario has the options its executor needs.
+func (s Scenario) Validate() error {
+	if s.StartTime.Valid && s.StartTime.Duration < 0 {
+		return errors.New("startTime can't be negative")
+	}
+	if s.Warmup.Valid && s.Warmup.Duration < 0 {
+		return errors.New("warmup can't be negative")
+	}
+	if s.StartAfter.Valid && s.StartAfter.String == "" {
+		return errors.New("startAfter can't be empty")
+	}
+	if s.Exec.Valid && s.Exec.String == "" {
+		return errors.New("exec can't be empty")
+	}
+	if s.Setup.Valid && s.Setup.String == "" {
+		return errors.New("setup can't be empty")
+	}
+	if s.Teardown.Valid && s.Teardown.String == "" {
+		return errors.New("teardown can't be empty")
+	}
+	if s.ClientProfile.Valid {
+		if _, ok := ClientProfiles[s.ClientProfile.String]; !ok {
+			return errors.Errorf("unknown client profile: %s", s.ClientProfile.String)
+		}
+	}
+	if s.GracefulStop.Valid && s.GracefulStop.Duration < 0 {
+		return errors.New("gracefulStop can't be negative")
+	}
+	if s.GracefulRampDown.Valid && s.GracefulRampDown.Duration < 0 {
+		return errors.New("gracefulRampDown can't be negative")
+	}
+	if s.IterationRate.Valid {
+		if s.Executor == ConstantArrivalRateExecutor {
+			return errors.New("iterationRate can't be used with constant-arrival-rate, which starts iterations at its rate")
+		}
+		if s.IterationRate.Int64 <= 0 {
+			return errors.New("iterationRate must be positive")
+		}
+	}
+	if s.RPS.Valid && s.RPS.Int64 <= 0 {
+		return errors.New("rps must be positive")
+	}
+	switch s.Executor {
+	case ConstantVUsExecutor:
+		if s.VUs.Valid && s.VUs.Int64 <= 0 {
+			return errors.New("vus must be positive")
+		}
+		if !s.Duration.Valid || s.Duration.Duration <= 0 {
+			return errors.New("a positive duration is required")
+		}
+	case RampingVUsExecutor:
+		if s.StartVUs.Valid && s.StartVUs.Int64 < 0 {
+			return errors.New("startVUs can't be negative")
+		}
+		if len(s.Stages) == 0 {
+			return errors.New("at least one stage is required")
+		}
+		for i, stage := range s.Stages {
+			if !stage.Duration.Valid || stage.Duration.Duration < 0 {
+				return errors.Errorf("stage #%d needs a duration", i+1)
+			}
+			if !stage.Target.Valid || stage.Target.Int64 < 0 {
+				return errors.Errorf("stage #%d needs a target that isn't negative", i+1)
+			}
+		}
+	case ConstantArrivalRateExecutor:
+		if !s.Rate.Valid || s.Rate.Int64 <= 0 {
+			return errors.New("a positive rate is required")
+		}
+		if s.TimeUnit.Valid && s.TimeUnit.Duration <= 0 {
+			return errors.New("timeUnit must be positive")
+		}
+		if !s.Duration.Valid || s.Duration.Duration <= 0 {
+			return errors.New("a positive duration is required")
+		}
+		if !s.PreAllocatedVUs.Valid || s.PreAllocatedVUs.Int64 < 0 {
+			return errors.New("preAllocatedVUs is required, and can't be negative")
+		}
+		if s.MaxVUs.Valid && s.MaxVUs.Int64 < s.PreAllocatedVUs.Int64 {
+			return errors.New("maxVUs can't be less than preAllocatedVUs")
+		}
+	case SharedIterationsExecutor, PerVUIterationsExecutor:
+		if s.VUs.Valid && s.VUs.Int64 <= 0 {
+			return errors.New("vus must be positive")
+		}
+		if s.Iterations.Valid && s.Iterations.Int64 <= 0 {
+			return errors.New("iterations must be positive")
+		}
+		if s.MaxDuration.Valid && s.MaxDuration.Duration <= 0 {
+			return errors.New("maxDuration must be positive")
+		}
+		if s.Executor == SharedIterationsExecutor && s.iterations() < s.vus() {
+			return errors.Errorf("the iterations (%d) can't be fewer than the VUs (%d) they're shared among",
+				s.iterations(), s.vus())
+		}
+	case ExternallyControlledExecutor:
+		if s.VUs.Valid && s.VUs.Int64 < 0 {
+			return errors.New("vus can't be negative")
+		}
+		if !s.Duration.Valid || s.Duration.Duration <= 0 {
+			return errors.New("a positive duration is required")
+		}
+		if s.MaxVUs.Valid && s.MaxVUs.Int64 < s.vus() {
+			return errors.New("maxVUs can't be less than vus")
+		}
+	case "":
+		return errors.New("an executor is required")
+	default:
+		return errors.Errorf("unknown executor: %s", s.Executor)
+	}
+	return nil
+}
+
+// GetExec returns the name of the exported function the scenario's iterations run.
+func (s Scenario) GetExec() string {
+	if s.Exec.Valid {
+		return s.Exec.String
+	}
+	return "default"
+}
+
+// InitVUs returns how many VUs the scenario needs before it starts.
+func (s Scenario) InitVUs() int64 {
+	switch s.Executor {
+	case ConstantVUsExecutor, SharedIterationsExecutor, PerVUIterationsExecutor:
+		return s.vus()
+	case RampingVUsExecutor:
+		max := s.startVUs()
+		for _, stage := range s.Stages {
+			max = Max(max, stage.Target.Int64)
+		}
+		return max
+	case ConstantArrivalRateExecutor:
+		return s.PreAllocatedVUs.Int64
+	case ExternallyControlledExecutor:
+		// All of them are initialized up front, so that scaling the scenario is instant.
+		if s.MaxVUs.Valid {
+			return s.MaxVUs.Int64
+		}
+		return s.vus()
+	}
+	return 0
+}
+
+// GetMaxVUs returns how many VUs the scenario may run at most.
+func (s Scenario) GetMaxVUs() int64 {
+	if s.Executor == ConstantArrivalRateExecutor && s.MaxVUs.Valid {
+		return s.MaxVUs.Int64
+	}
+	return s.InitVUs()
+}
+
+// GetTimeUnit returns the time unit of an arrival rate.
+func (s Scenario) GetTimeUnit() time.Duration {
+	if s.TimeUnit.Valid {
+		return time.Duration(s.TimeUnit.Duration)
+	}
+	return time.Second
+}
+
+// GetGracefulStop returns how long iterations are given to finish when the scenario ends.
+func (s Scenario) GetGracefulStop() time.Duration {
+	if s.GracefulStop.Valid {
+		return time.Duration(s.GracefulStop.Duration)
+	}
+	return DefaultGracefulStop
+}
+
+// GetGracefulRampDown returns how long iterations are given to finish when the scenario ramps
+// their VUs down; only ramping-vus does.
+func (s Scenario) GetGracefulRampDown() time.Duration {
+	if s.GracefulRampDown.Valid {
+		return time.Duration(s.GracefulRampDown.Duration)
+	}
+	return DefaultGracefulStop
+}
+
+// GetIterations returns how many iterations an iteration based scenario runs in total, or 0 for
+// the other executors, which run for a duration instead.
+func (s Scenario) GetIterations() int64 {
+	switch s.Executor {
+	case SharedIterationsExecutor:
+		return s.iterations()
+	case PerVUIterationsExecutor:
+		return s.iterations() * s.vus()
+	}
+	return 0
+}
+
+// iterations returns the iterations option of an iteration based scenario.
+func (s Scenario) iterations() int64 {
+	if s.Iterations.Valid {
+		return s.Iterations.Int64
+	}
+	return 1
+}
+
+// vus returns how many VUs a constant-vus or iteration based scenario runs, or an
+// externally-controlled one starts with.
+func (s Scenario) vus() int64 {
+	if s.VUs.Valid {
+		return s.VUs.Int64
+	}
+	return 1
+}
+
+// startVUs returns how many VUs a ramping-vus scenario starts with.
+func (s Scenario) startVUs() int64 {
+	if s.StartVUs.Valid {
+		return s.StartVUs.Int64
+	}
+	return 1
+}
+
+// GetDuration returns how long the scenario runs for, not counting its start time.
+func (s Scenario) GetDuration() time.Duration {
+	switch s.Executor {
+	case ConstantVUsExecutor, ConstantArrivalRateExecutor, ExternallyControlledExecutor:
+		return time.Duration(s.Duration.Duration)
+	case RampingVUsExecutor:
+		return time.Duration(SumStages(s.Stages).Duration)
+	case SharedIterationsExecutor, PerVUIterationsExecutor:
+		// They usually end sooner than this, once their iterations are done.
+		if s.MaxDuration.Valid {
+			return time.Duration(s.MaxDuration.Duration)
+		}
+		return DefaultMaxDuration
+	}
+	return 0
+}
+
+// VUsAt returns how many VUs should be running the scenario at a point of the test, and whether
+// it's still running; it hasn't ended before it starts. Arrival rate executors start more VUs
+// than this when they need to, and externally controlled ones start with it.
+func (s Scenario) VUsAt(t time.Duration) (int64, bool) {
+	t -= time.Duration(s.StartTime.Duration)
+	if t < 0 {
+		return 0, true
+	}
+	if t >= s.GetDuration() {
+		return 0, false
+	}
+	switch s.Executor {
+	case ConstantVUsExecutor, ConstantArrivalRateExecutor, SharedIterationsExecutor, PerVUIterationsExecutor:
+		return s.InitVUs(), true
+	case ExternallyControlledExecutor:
+		return s.vus(), true
+	case RampingVUsExecutor:
+		vus := s.startVUs()
+		var start time.Duration
+		for _, stage := range s.Stages {
+			end := start + time.Duration(stage.Duration.Duration)
+			if end <= t {
+				vus, start = stage.Target.Int64, end
+				continue
+			}
+			prog := Clampf(float64(t-start)/float64(end-start), 0.0, 1.0)
+			return Lerp(vus, stage.Target.Int64, prog), true
+		}
+		return vus, true
+	}
+	return 0, false
+}
+
+// Segment returns the part of the scenario that an instance running a segment of the test runs,
+// and whether there's anything left of it; the scenario's start time, like the test's duration,
+// is the same on every instance, so a scenario must be resolved before it's segmented.
+func (s Scenario) Segment(et *ExecutionTuple) (Scenario, bool) {
+	if et == nil {
+		return s, true
+	}
+	scale := func(n null.Int, def int64) null.Int {
+		if !n.Valid {
+			n = null.IntFrom(def)
+		}
+		return null.IntFrom(et.Scale(n.Int64))
+	}
+	// Every segment is paced, even those whose share of the rate rounds down to nothing.
+	if s.IterationRate.Valid {
+		s.IterationRate = null.IntFrom(Max(et.Scale(s.IterationRate.Int64), 1))
+	}
+	if s.RPS.Valid {
+		s.RPS = null.IntFrom(Max(et.Scale(s.RPS.Int64), 1))
+	}
+	switch s.Executor {
+	case ConstantVUsExecutor, PerVUIterationsExecutor:
+		s.VUs = scale(s.VUs, 1)
+		return s, s.VUs.Int64 > 0
+	case RampingVUsExecutor:
+		s.StartVUs = scale(s.StartVUs, 1)
+		stages := make([]Stage, len(s.Stages))
+		for i, stage := range s.Stages {
+			stage.Target = scale(stage.Target, 0)
+			stages[i] = stage
+		}
+		s.Stages = stages
+		return s, s.InitVUs() > 0
+	case ConstantArrivalRateExecutor:
+		s.Rate = scale(s.Rate, 0)
+		// Every segment with a share of the rate needs a VU to run it with.
+		s.PreAllocatedVUs = null.IntFrom(Max(et.Scale(s.PreAllocatedVUs.Int64), 1))
+		if s.MaxVUs.Valid {
+			s.MaxVUs = null.IntFrom(Max(et.Scale(s.MaxVUs.Int64), s.PreAllocatedVUs.Int64))
+		}
+		return s, s.Rate.Int64 > 0
+	case SharedIterationsExecutor:
+		s.Iterations = scale(s.Iterations, 1)
+		s.VUs = null.IntFrom(Max(Min(et.Scale(s.vus()), s.Iterations.Int64), 1))
+		return s, s.Iterations.Int64 > 0
+	case ExternallyControlledExecutor:
+		s.VUs = scale(s.VUs, 1)
+		if s.MaxVUs.Valid {
+			s.MaxVUs = scale(s.MaxVUs, 0)
+		}
+		return s, s.InitVUs() > 0
+	}
+	return s, true
+}
+
+// Description sums up what the scenario does, eg. for the banner of a test.
+func (s Scenario) Description() string {
+	var desc string
+	switch s.Executor {
+	case ConstantVUsExecutor:
+		desc = fmt.Sprintf("%d looping VUs for %s", s.InitVUs(), s.GetDuration())
+	case RampingVUsExecutor:
+		desc = fmt.Sprintf("Up to %d looping VUs for %s over %d stages", s.InitVUs(), s.GetDuration(), len(s.Stages))
+	case ConstantArrivalRateExecutor:
+		rate := float64(s.Rate.Int64) * float64(time.Second) / float64(s.GetTimeUnit())
+		desc = fmt.Sprintf("%.2f iterations/s for %s (maxVUs: %d-%d)",
+			rate, s.GetDuration(), s.InitVUs(), s.GetMaxVUs())
+	case ExternallyControlledExecutor:
+		desc = fmt.Sprintf("Up to %d externally controlled VUs for %s, starting with %d",
+			s.GetMaxVUs(), s.GetDuration(), s.vus())
+	case SharedIterationsExecutor:
+		desc = fmt.Sprintf("%d iterations shared among %d VUs (maxDuration: %s)",
+			s.iterations(), s.vus(), s.GetDuration())
+	case PerVUIterationsExecutor:
+		desc = fmt.Sprintf("%d iterations for each of %d VUs (maxDuration: %s)",
+			s.iterations(), s.vus(), s.GetDuration())
+	}
+	var extra []string
+	if s.StartAfter.Valid {
+		extra = append(extra, "startAfter: "+s.StartAfter.String)
+	}
+	if s.StartTime.Duration > 0 {
+		extra = append(extra, "startTime: "+time.Duration(s.StartTime.Duration).String())
+	}
+	if s.Exec.Valid {
+		extra = append(extra, "exec: "+s.Exec.String)
+	}
+	if s.Setup.Valid {
+		extra = append(extra, "setup: "+s.Setup.String)
+	}
+	if s.Teardown.Valid {
+		extra = append(extra, "teardown: "+s.Teardown.String)
+	}
+	if s.GracefulStop.Valid {
+		extra = append(extra, "gracefulStop: "+s.GetGracefulStop().String())
+	}
+	if s.FreshVUState.Bool {
+		extra = append(extra, "freshVUState")
+	}
+	if s.GracefulRampDown.Valid && s.Executor == RampingVUsExecutor {
+		extra = append(extra, "gracefulRampDown: "+s.GetGracefulRampDown().String())
+	}
+	if len(extra) > 0 {
+		desc += " (" + strings.Join(extra, ", ") + ")"
+	}
+	return desc
+}
+
+// Validate checks the names and options of all of the scenarios.
+func (s Scenarios) Validate() error {
+	for _, name := range s.Names() {
+		if !scenarioNameRE.MatchString(name) {
+			return errors.Errorf("invalid scenario name %q; only letters, digits, _ and - are allowed", name)
+		}
+		if err := s[name].Validate(); err != nil {
+			return errors.Wrapf(err, "scenario %s", name)
+		}
+		if s[name].Executor == ExternallyControlledExecutor && len(s) > 1 {
+			return errors.Errorf("scenario %s: the %s executor can't be used together with other scenarios",
+				name, ExternallyControlledExecutor)
+		}
+		if after := s[name].StartAfter; after.Valid {
+			if _, ok := s[after.String]; !ok {
+				return errors.Errorf("scenario %s: startAfter names an unknown scenario: %s", name, after.String)
+			}
+		}
+	}
+
+	// Follow every chain of startAfter, which can't lead back to where it started.
+	for _, name := range s.Names() {
+		seen := map[string]bool{name: true}
+		for after := s[name].StartAfter; after.Valid; after = s[after.String].StartAfter {
+			if seen[after.String] {
+				return errors.Errorf("scenario %s: startAfter can't loop back to a scenario that's already started", name)
+			}
+			seen[after.String] = true
+		}
+	}
+	return nil
+}
+
+// GetStartTime returns when a scenario starts, from the start of the test. A scenario started
+// after another starts once that one's duration is over, not counting its gracefulStop.
+func (s Scenarios) GetStartTime(name string) time.Duration {
+	var start time.Duration
+	// Don't follow startAfter any further than there are scenarios, in case they loop.
+	for i := 0; i <= len(s); i++ {
+		sc, ok := s[name]
+		if !ok {
+			break
+		}
+		start += time.Duration(sc.StartTime.Duration)
+		if !sc.StartAfter.Valid {
+			break
+		}
+		name = sc.StartAfter.String
+		start += s[name].GetDuration()
+	}
+	return start
+}
+
+// Resolve returns a scenario with its start time made relative to the start of the test.
+func (s Scenarios) Resolve(name string) Scenario {
+	sc := s[name]
+	if sc.StartAfter.Valid {
+		sc.StartTime = types.NullDurationFrom(s.GetStartTime(name))
+	}
+	return sc
+}
+
+// Segment returns the scenarios, or the parts of them, that an instance running a segment of the
+// test runs, with their start times resolved; those it has no part of are left out.
+func (s Scenarios) Segment(et *ExecutionTuple) Scenarios {
+	if et == nil {
+		return s
+	}
+	segmented := make(Scenarios, len(s))
+	for name := range s {
+		sc := s.Resolve(name)
+		sc.StartAfter = null.String{}
+		if sc, ok := sc.Segment(et); ok {
+			segmented[name] = sc
+		}
+	}
+	return segmented
+}
+
+// Names returns the names of the scenarios, sorted.
+func (s Scenarios) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InitVUs returns how many VUs the scenarios need before the test starts.
+func (s Scenarios) InitVUs() int64 {
+	var vus int64
+	for _, sc := range s {
+		vus += sc.InitVUs()
+	}
+	return vus
+}
+
+// GetMaxVUs returns how many VUs the scenarios may run at most.
+func (s Scenarios) GetMaxVUs() int64 {
+	var vus int64
+	for _, sc := range s {
+		vus += sc.GetMaxVUs()
+	}
+	return vus
+}
+
+// GetDuration returns when the last of the scenarios ends.
+func (s Scenarios) GetDuration() time.Duration {
+	var d time.Duration
+	for name, sc := range s {
+		if end := s.GetStartTime(name) + sc.GetDuration(); end > d {
+			d = end
+		}
+	}
+	return d
+}
+
+// ScenarioState is the scenario an iteration is run for, by name.
+type ScenarioState struct {
+	Name string
+	Scenario
+
+	// Limits the HTTP requests of the scenario's VUs, if it has an rps; shared by all of them.
+	RPSLimit *rate.Limiter
+}
@@ -0,0 +1,267 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestScenarioValidate(t *testing.T) {
+	second := types.NullDurationFrom(1 * time.Second)
+	testdata := map[string]struct {
+		Scenario Scenario
+		Err      string
+	}{
+		"no executor":               {Scenario{}, "an executor is required"},
+		"unknown executor":          {Scenario{Executor: "foo"}, "unknown executor: foo"},
+		"negative startTime":        {Scenario{Executor: ConstantVUsExecutor, Duration: second, StartTime: types.NullDurationFrom(-1)}, "startTime can't be negative"},
+		"negative warmup":           {Scenario{Executor: ConstantVUsExecutor, Duration: second, Warmup: types.NullDurationFrom(-1)}, "warmup can't be negative"},
+		"empty exec":                {Scenario{Executor: ConstantVUsExecutor, Duration: second, Exec: null.StringFrom("")}, "exec can't be empty"},
+		"empty setup":               {Scenario{Executor: ConstantVUsExecutor, Duration: second, Setup: null.StringFrom("")}, "setup can't be empty"},
+		"empty teardown":            {Scenario{Executor: ConstantVUsExecutor, Duration: second, Teardown: null.StringFrom("")}, "teardown can't be empty"},
+		"unknown client profile":    {Scenario{Executor: ConstantVUsExecutor, Duration: second, ClientProfile: null.StringFrom("netscape")}, "unknown client profile: netscape"},
+		"negative gracefulStop":     {Scenario{Executor: ConstantVUsExecutor, Duration: second, GracefulStop: types.NullDurationFrom(-1)}, "gracefulStop can't be negative"},
+		"negative gracefulRampDown": {Scenario{Executor: ConstantVUsExecutor, Duration: second, GracefulRampDown: types.NullDurationFrom(-1)}, "gracefulRampDown can't be negative"},
+		"zero iterationRate":        {Scenario{Executor: ConstantVUsExecutor, Duration: second, IterationRate: null.IntFrom(0)}, "iterationRate must be positive"},
+		"zero rps":                  {Scenario{Executor: ConstantVUsExecutor, Duration: second, RPS: null.IntFrom(0)}, "rps must be positive"},
+		"paced":                     {Scenario{Executor: ConstantVUsExecutor, Duration: second, IterationRate: null.IntFrom(10), RPS: null.IntFrom(100)}, ""},
+		"paced arrival rate": {
+			Scenario{Executor: ConstantArrivalRateExecutor, Rate: null.IntFrom(10), Duration: second, PreAllocatedVUs: null.IntFrom(1), IterationRate: null.IntFrom(10)},
+			"iterationRate can't be used with constant-arrival-rate, which starts iterations at its rate",
+		},
+
+		"constant-vus":             {Scenario{Executor: ConstantVUsExecutor, Duration: second}, ""},
+		"constant-vus/no duration": {Scenario{Executor: ConstantVUsExecutor}, "a positive duration is required"},
+		"constant-vus/zero vus":    {Scenario{Executor: ConstantVUsExecutor, Duration: second, VUs: null.IntFrom(0)}, "vus must be positive"},
+
+		"ramping-vus":             {Scenario{Executor: RampingVUsExecutor, Stages: []Stage{{Duration: second, Target: null.IntFrom(1)}}}, ""},
+		"ramping-vus/no stages":   {Scenario{Executor: RampingVUsExecutor}, "at least one stage is required"},
+		"ramping-vus/no target":   {Scenario{Executor: RampingVUsExecutor, Stages: []Stage{{Duration: second}}}, "stage #1 needs a target that isn't negative"},
+		"ramping-vus/no duration": {Scenario{Executor: RampingVUsExecutor, Stages: []Stage{{Target: null.IntFrom(1)}}}, "stage #1 needs a duration"},
+
+		"constant-arrival-rate":             {Scenario{Executor: ConstantArrivalRateExecutor, Rate: null.IntFrom(10), Duration: second, PreAllocatedVUs: null.IntFrom(1)}, ""},
+		"constant-arrival-rate/no rate":     {Scenario{Executor: ConstantArrivalRateExecutor, Duration: second, PreAllocatedVUs: null.IntFrom(1)}, "a positive rate is required"},
+		"constant-arrival-rate/zero unit":   {Scenario{Executor: ConstantArrivalRateExecutor, Rate: null.IntFrom(10), TimeUnit: types.NullDurationFrom(0), Duration: second, PreAllocatedVUs: null.IntFrom(1)}, "timeUnit must be positive"},
+		"constant-arrival-rate/no duration": {Scenario{Executor: ConstantArrivalRateExecutor, Rate: null.IntFrom(10), PreAllocatedVUs: null.IntFrom(1)}, "a positive duration is required"},
+		"constant-arrival-rate/no prealloc": {Scenario{Executor: ConstantArrivalRateExecutor, Rate: null.IntFrom(10), Duration: second}, "preAllocatedVUs is required, and can't be negative"},
+		"constant-arrival-rate/low max VUs": {Scenario{Executor: ConstantArrivalRateExecutor, Rate: null.IntFrom(10), Duration: second, PreAllocatedVUs: null.IntFrom(2), MaxVUs: null.IntFrom(1)}, "maxVUs can't be less than preAllocatedVUs"},
+
+		"shared-iterations":                  {Scenario{Executor: SharedIterationsExecutor}, ""},
+		"shared-iterations/zero iterations":  {Scenario{Executor: SharedIterationsExecutor, Iterations: null.IntFrom(0)}, "iterations must be positive"},
+		"shared-iterations/too many VUs":     {Scenario{Executor: SharedIterationsExecutor, VUs: null.IntFrom(5), Iterations: null.IntFrom(4)}, "the iterations (4) can't be fewer than the VUs (5) they're shared among"},
+		"shared-iterations/zero maxDuration": {Scenario{Executor: SharedIterationsExecutor, MaxDuration: types.NullDurationFrom(0)}, "maxDuration must be positive"},
+		"per-vu-iterations":                  {Scenario{Executor: PerVUIterationsExecutor, VUs: null.IntFrom(5), Iterations: null.IntFrom(4)}, ""},
+		"per-vu-iterations/zero vus":         {Scenario{Executor: PerVUIterationsExecutor, VUs: null.IntFrom(0)}, "vus must be positive"},
+
+		"externally-controlled":              {Scenario{Executor: ExternallyControlledExecutor, Duration: second}, ""},
+		"externally-controlled/no duration":  {Scenario{Executor: ExternallyControlledExecutor}, "a positive duration is required"},
+		"externally-controlled/negative vus": {Scenario{Executor: ExternallyControlledExecutor, Duration: second, VUs: null.IntFrom(-1)}, "vus can't be negative"},
+		"externally-controlled/low max VUs":  {Scenario{Executor: ExternallyControlledExecutor, Duration: second, VUs: null.IntFrom(2), MaxVUs: null.IntFrom(1)}, "maxVUs can't be less than vus"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			err := data.Scenario.Validate()
+			if data.Err != "" {
+				assert.EqualError(t, err, data.Err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("Scenarios", func(t *testing.T) {
+		assert.NoError(t, Scenarios{"a-b_1": {Executor: ConstantVUsExecutor, Duration: second}}.Validate())
+		assert.EqualError(t, Scenarios{"a b": {Executor: ConstantVUsExecutor, Duration: second}}.Validate(),
+			`invalid scenario name "a b"; only letters, digits, _ and - are allowed`)
+		assert.EqualError(t, Scenarios{"a": {}}.Validate(), "scenario a: an executor is required")
+		assert.EqualError(t, Scenarios{
+			"a": {Executor: ExternallyControlledExecutor, Duration: second},
+			"b": {Executor: ConstantVUsExecutor, Duration: second},
+		}.Validate(), "scenario a: the externally-controlled executor can't be used together with other scenarios")
+		assert.EqualError(t, Scenarios{
+			"a": {Executor: ConstantVUsExecutor, Duration: second, StartAfter: null.StringFrom("b")},
+		}.Validate(), "scenario a: startAfter names an unknown scenario: b")
+		assert.EqualError(t, Scenarios{
+			"a": {Executor: ConstantVUsExecutor, Duration: second, StartAfter: null.StringFrom("c")},
+			"b": {Executor: ConstantVUsExecutor, Duration: second, StartAfter: null.StringFrom("a")},
+			"c": {Executor: ConstantVUsExecutor, Duration: second, StartAfter: null.StringFrom("b")},
+		}.Validate(), "scenario a: startAfter can't loop back to a scenario that's already started")
+		assert.EqualError(t, Scenarios{
+			"a": {Executor: ConstantVUsExecutor, Duration: second, StartAfter: null.StringFrom("")},
+		}.Validate(), "scenario a: startAfter can't be empty")
+	})
+}
+
+func TestScenarioVUsAt(t *testing.T) {
+	t.Run("constant-vus", func(t *testing.T) {
+		sc := Scenario{
+			Executor:  ConstantVUsExecutor,
+			VUs:       null.IntFrom(5),
+			Duration:  types.NullDurationFrom(10 * time.Second),
+			StartTime: types.NullDurationFrom(5 * time.Second),
+		}
+		assert.Equal(t, int64(5), sc.InitVUs())
+		assert.Equal(t, 10*time.Second, sc.GetDuration())
+		for at, vus := range map[time.Duration]int64{0: 0, 5 * time.Second: 5, 14 * time.Second: 5} {
+			v, ok := sc.VUsAt(at)
+			assert.True(t, ok, at.String())
+			assert.Equal(t, vus, v, at.String())
+		}
+		_, ok := sc.VUsAt(15 * time.Second)
+		assert.False(t, ok)
+	})
+	t.Run("ramping-vus", func(t *testing.T) {
+		sc := Scenario{
+			Executor: RampingVUsExecutor,
+			StartVUs: null.IntFrom(0),
+			Stages: []Stage{
+				{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(10)},
+				{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(10)},
+				{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(0)},
+			},
+		}
+		assert.Equal(t, int64(10), sc.InitVUs())
+		assert.Equal(t, 30*time.Second, sc.GetDuration())
+		assert.Equal(t, DefaultGracefulStop, sc.GetGracefulStop())
+		assert.Equal(t, DefaultGracefulStop, sc.GetGracefulRampDown())
+		sc.GracefulStop = types.NullDurationFrom(0)
+		sc.GracefulRampDown = types.NullDurationFrom(5 * time.Second)
+		assert.Equal(t, time.Duration(0), sc.GetGracefulStop())
+		assert.Equal(t, "Up to 10 looping VUs for 30s over 3 stages (gracefulStop: 0s, gracefulRampDown: 5s)",
+			sc.Description())
+		for at, vus := range map[time.Duration]int64{
+			0: 0, 5 * time.Second: 5, 10 * time.Second: 10, 15 * time.Second: 10, 25 * time.Second: 5,
+		} {
+			v, ok := sc.VUsAt(at)
+			assert.True(t, ok, at.String())
+			assert.Equal(t, vus, v, at.String())
+		}
+		_, ok := sc.VUsAt(30 * time.Second)
+		assert.False(t, ok)
+	})
+	t.Run("constant-arrival-rate", func(t *testing.T) {
+		sc := Scenario{
+			Executor:        ConstantArrivalRateExecutor,
+			Rate:            null.IntFrom(30),
+			TimeUnit:        types.NullDurationFrom(1 * time.Minute),
+			Duration:        types.NullDurationFrom(10 * time.Second),
+			PreAllocatedVUs: null.IntFrom(2),
+			MaxVUs:          null.IntFrom(5),
+		}
+		assert.Equal(t, int64(2), sc.InitVUs())
+		assert.Equal(t, int64(5), sc.GetMaxVUs())
+		assert.Equal(t, 10*time.Second, sc.GetDuration())
+		assert.Equal(t, "0.50 iterations/s for 10s (maxVUs: 2-5)", sc.Description())
+		v, ok := sc.VUsAt(5 * time.Second)
+		assert.True(t, ok)
+		assert.Equal(t, int64(2), v)
+		_, ok = sc.VUsAt(10 * time.Second)
+		assert.False(t, ok)
+
+		sc.MaxVUs = null.Int{}
+		assert.Equal(t, int64(2), sc.GetMaxVUs())
+	})
+	t.Run("externally-controlled", func(t *testing.T) {
+		sc := Scenario{
+			Executor: ExternallyControlledExecutor,
+			VUs:      null.IntFrom(2),
+			MaxVUs:   null.IntFrom(10),
+			Duration: types.NullDurationFrom(10 * time.Second),
+		}
+		assert.Equal(t, int64(10), sc.InitVUs())
+		assert.Equal(t, int64(10), sc.GetMaxVUs())
+		assert.Equal(t, "Up to 10 externally controlled VUs for 10s, starting with 2", sc.Description())
+		v, ok := sc.VUsAt(5 * time.Second)
+		assert.True(t, ok)
+		assert.Equal(t, int64(2), v)
+
+		sc.MaxVUs = null.Int{}
+		assert.Equal(t, int64(2), sc.InitVUs())
+	})
+	t.Run("iterations", func(t *testing.T) {
+		shared := Scenario{Executor: SharedIterationsExecutor, VUs: null.IntFrom(4), Iterations: null.IntFrom(100)}
+		assert.Equal(t, int64(4), shared.InitVUs())
+		assert.Equal(t, int64(100), shared.GetIterations())
+		assert.Equal(t, DefaultMaxDuration, shared.GetDuration())
+		assert.Equal(t, "100 iterations shared among 4 VUs (maxDuration: 10m0s)", shared.Description())
+		v, ok := shared.VUsAt(1 * time.Minute)
+		assert.True(t, ok)
+		assert.Equal(t, int64(4), v)
+
+		perVU := Scenario{
+			Executor:    PerVUIterationsExecutor,
+			VUs:         null.IntFrom(4),
+			Iterations:  null.IntFrom(10),
+			MaxDuration: types.NullDurationFrom(1 * time.Minute),
+		}
+		assert.Equal(t, int64(40), perVU.GetIterations())
+		assert.Equal(t, "10 iterations for each of 4 VUs (maxDuration: 1m0s)", perVU.Description())
+		_, ok = perVU.VUsAt(1 * time.Minute)
+		assert.False(t, ok)
+
+		assert.Equal(t, int64(0), Scenario{Executor: ConstantVUsExecutor}.GetIterations())
+	})
+	t.Run("Scenarios", func(t *testing.T) {
+		scenarios := Scenarios{
+			"b": {Executor: ConstantVUsExecutor, VUs: null.IntFrom(2), Duration: types.NullDurationFrom(10 * time.Second)},
+			"a": {
+				Executor:  RampingVUsExecutor,
+				StartTime: types.NullDurationFrom(10 * time.Second),
+				Stages:    []Stage{{Duration: types.NullDurationFrom(5 * time.Second), Target: null.IntFrom(3)}},
+			},
+		}
+		assert.Equal(t, []string{"a", "b"}, scenarios.Names())
+		assert.Equal(t, int64(5), scenarios.InitVUs())
+		assert.Equal(t, 15*time.Second, scenarios.GetDuration())
+	})
+	t.Run("StartAfter", func(t *testing.T) {
+		scenarios := Scenarios{
+			"warmup": {Executor: ConstantVUsExecutor, Duration: types.NullDurationFrom(10 * time.Second)},
+			"load": {
+				Executor:   ConstantVUsExecutor,
+				Duration:   types.NullDurationFrom(20 * time.Second),
+				StartAfter: null.StringFrom("warmup"),
+			},
+			"spike": {
+				Executor:   ConstantVUsExecutor,
+				Duration:   types.NullDurationFrom(5 * time.Second),
+				StartTime:  types.NullDurationFrom(2 * time.Second),
+				StartAfter: null.StringFrom("load"),
+			},
+		}
+		assert.NoError(t, scenarios.Validate())
+		assert.Equal(t, time.Duration(0), scenarios.GetStartTime("warmup"))
+		assert.Equal(t, 10*time.Second, scenarios.GetStartTime("load"))
+		assert.Equal(t, 32*time.Second, scenarios.GetStartTime("spike"))
+		assert.Equal(t, 37*time.Second, scenarios.GetDuration())
+
+		spike := scenarios.Resolve("spike")
+		assert.Equal(t, types.NullDurationFrom(32*time.Second), spike.StartTime)
+		_, ok := spike.VUsAt(36 * time.Second)
+		assert.True(t, ok)
+		assert.Equal(t, "1 looping VUs for 5s (startAfter: load, startTime: 2s)", scenarios["spike"].Description())
+	})
+}
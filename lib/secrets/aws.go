@@ -0,0 +1,192 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const awsService = "secretsmanager"
+
+// An AWSSource reads secrets from AWS Secrets Manager. Credentials and the region come from the
+// usual AWS_* variables; AWS_ENDPOINT_URL can point it at something other than AWS, eg. to test.
+type AWSSource struct {
+	Client   *http.Client
+	Endpoint string
+	Region   string
+
+	AccessKeyID, SecretAccessKey, SessionToken string
+
+	// Prepended to the names of secrets, to keep a test's secrets apart from others.
+	Prefix string
+
+	now func() time.Time
+}
+
+func NewAWSSource(env map[string]string, prefix string) (*AWSSource, error) {
+	region := env["AWS_REGION"]
+	if region == "" {
+		region = env["AWS_DEFAULT_REGION"]
+	}
+	if region == "" {
+		return nil, errors.New("the aws secret source needs AWS_REGION to be set")
+	}
+	if env["AWS_ACCESS_KEY_ID"] == "" || env["AWS_SECRET_ACCESS_KEY"] == "" {
+		return nil, errors.New("the aws secret source needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to be set")
+	}
+	endpoint := env["AWS_ENDPOINT_URL"]
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", awsService, region)
+	}
+	return &AWSSource{
+		Client:          &http.Client{Timeout: 30 * time.Second},
+		Endpoint:        strings.TrimRight(endpoint, "/"),
+		Region:          region,
+		AccessKeyID:     env["AWS_ACCESS_KEY_ID"],
+		SecretAccessKey: env["AWS_SECRET_ACCESS_KEY"],
+		SessionToken:    env["AWS_SESSION_TOKEN"],
+		Prefix:          prefix,
+		now:             time.Now,
+	}, nil
+}
+
+func (s *AWSSource) Get(ctx context.Context, name string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": s.Prefix + name})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", s.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	s.sign(req, payload)
+
+	res, err := s.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var body struct {
+		SecretString string
+		SecretBinary string
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil && res.StatusCode == http.StatusOK {
+		return "", errors.Wrap(err, "invalid response from AWS")
+	}
+	if res.StatusCode != http.StatusOK {
+		if body.Type != "" {
+			// Types may be qualified, like "com.amazonaws...#ResourceNotFoundException".
+			typ := body.Type[strings.LastIndex(body.Type, "#")+1:]
+			return "", errors.Errorf("aws: %s: %s", typ, body.Message)
+		}
+		return "", errors.Errorf("aws: %s", res.Status)
+	}
+
+	if body.SecretBinary != "" {
+		data, err := base64.StdEncoding.DecodeString(body.SecretBinary)
+		return string(data), err
+	}
+	return body.SecretString, nil
+}
+
+// Signs a request with AWS Signature Version 4.
+func (s *AWSSource) sign(req *http.Request, payload []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(req.Header.Get(k))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(payload),
+	}, "\n")
+	scope := strings.Join([]string{date, s.Region, awsService, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(awsSigningKey(s.SecretAccessKey, date, s.Region, awsService), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func awsSigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,85 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSSigningKey(t *testing.T) {
+	// The example from AWS' documentation on deriving signing keys.
+	key := awsSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestAWSSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "20180102T030405Z", r.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		assert.Regexp(t,
+			`^AWS4-HMAC-SHA256 Credential=AKID/20180102/eu-west-1/secretsmanager/aws4_request, `+
+				`SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature=[0-9a-f]{64}$`,
+			r.Header.Get("Authorization"))
+
+		var body struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.SecretId {
+		case "k6/password":
+			_, _ = w.Write([]byte(`{"Name": "k6/password", "SecretString": "hunter2"}`))
+		case "k6/cert":
+			_, _ = w.Write([]byte(`{"Name": "k6/cert", "SecretBinary": "AAEC"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type": "com.amazonaws.secretsmanager#ResourceNotFoundException", "message": "Secrets Manager can't find the specified secret."}`))
+		}
+	}))
+	defer srv.Close()
+
+	src, err := NewAWSSource(map[string]string{
+		"AWS_DEFAULT_REGION":    "eu-west-1",
+		"AWS_ACCESS_KEY_ID":     "AKID",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_SESSION_TOKEN":     "session",
+		"AWS_ENDPOINT_URL":      srv.URL,
+	}, "k6/")
+	require.NoError(t, err)
+	src.now = func() time.Time { return time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	v, err := src.Get(context.Background(), "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", v)
+	v, err = src.Get(context.Background(), "cert")
+	assert.NoError(t, err)
+	assert.Equal(t, "\x00\x01\x02", v)
+	_, err = src.Get(context.Background(), "nope")
+	assert.EqualError(t, err, "aws: ResourceNotFoundException: Secrets Manager can't find the specified secret.")
+}
@@ -0,0 +1,79 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// A FileSource reads secrets from an env file, with a NAME=value per line. Blank lines and lines
+// starting with a # are ignored, and values may be quoted.
+type FileSource struct {
+	secrets map[string]string
+}
+
+func NewFileSource(fs afero.Fs, filename string) (*FileSource, error) {
+	if filename == "" {
+		return nil, errors.New("the file secret source needs a filename, eg. file=secrets.env")
+	}
+	data, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &FileSource{secrets: make(map[string]string)}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		idx := strings.IndexRune(line, '=')
+		if idx < 1 {
+			return nil, errors.Errorf("%s:%d: expected NAME=value", filename, n)
+		}
+		name, value := strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '\'' {
+				value = value[1 : len(value)-1]
+			} else if value, err = strconv.Unquote(value); err != nil {
+				return nil, errors.Errorf("%s:%d: invalid quoted value", filename, n)
+			}
+		}
+		src.secrets[name] = value
+	}
+	return src, scanner.Err()
+}
+
+func (s *FileSource) Get(ctx context.Context, name string) (string, error) {
+	v, ok := s.secrets[name]
+	if !ok {
+		return "", errors.New("no such secret")
+	}
+	return v, nil
+}
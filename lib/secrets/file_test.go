@@ -0,0 +1,67 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSource(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/secrets.env", []byte(`
+# Credentials for the staging environment.
+USER=admin
+PASSWORD = "p@ss=word\n"
+API_KEY='abc "def"'
+EMPTY=
+`), 0644))
+
+	src, err := NewFileSource(fs, "/secrets.env")
+	require.NoError(t, err)
+	for name, value := range map[string]string{
+		"USER":     "admin",
+		"PASSWORD": "p@ss=word\n",
+		"API_KEY":  `abc "def"`,
+		"EMPTY":    "",
+	} {
+		v, err := src.Get(context.Background(), name)
+		assert.NoError(t, err)
+		assert.Equal(t, value, v, name)
+	}
+	_, err = src.Get(context.Background(), "nope")
+	assert.EqualError(t, err, "no such secret")
+
+	t.Run("Invalid", func(t *testing.T) {
+		require.NoError(t, afero.WriteFile(fs, "/invalid.env", []byte("A=1\nB\n"), 0644))
+		_, err := NewFileSource(fs, "/invalid.env")
+		assert.EqualError(t, err, "/invalid.env:2: expected NAME=value")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := NewFileSource(fs, "/missing.env")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,172 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package secrets gets the secrets scripts use, like passwords and API keys, from outside of the
+// scripts, so that they're never part of their sources, or of archives.
+package secrets
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// Redacted is what secrets are replaced with in logs.
+const Redacted = "***SECRET_REDACTED***"
+
+// A Source is somewhere secrets are kept.
+type Source interface {
+	// Returns the secret with the given name.
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// A Manager gets secrets from a set of named sources, and remembers them, so that each is only
+// fetched once per test, and can be redacted from logs.
+type Manager struct {
+	mu      sync.Mutex
+	def     string
+	sources map[string]Source
+	values  map[string]map[string]string
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		sources: make(map[string]Source),
+		values:  make(map[string]map[string]string),
+	}
+}
+
+// Adds a source; the first one added is the default.
+func (m *Manager) Add(name string, src Source) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sources[name]; ok {
+		return errors.Errorf("duplicate secret source: %s", name)
+	}
+	if len(m.sources) == 0 {
+		m.def = name
+	}
+	m.sources[name] = src
+	m.values[name] = make(map[string]string)
+	return nil
+}
+
+// Returns a secret from the named source, or the default one if source is empty. Secrets are
+// fetched while holding a lock, so that VUs initialising at the same time don't all fetch them.
+func (m *Manager) Get(ctx context.Context, source, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if source == "" {
+		source = m.def
+	}
+	src, ok := m.sources[source]
+	if !ok {
+		return "", errors.Errorf("unknown secret source: %s", source)
+	}
+	if v, ok := m.values[source][name]; ok {
+		return v, nil
+	}
+	v, err := src.Get(ctx, name)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't get the secret %s from %s", name, source)
+	}
+	m.values[source][name] = v
+	return v, nil
+}
+
+// Replaces every secret that's been used in a string with Redacted.
+func (m *Manager) Redact(s string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Longer secrets go first, so that ones containing others are redacted whole.
+	var secrets []string
+	for _, values := range m.values {
+		for _, v := range values {
+			if v != "" {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, Redacted, -1)
+	}
+	return s
+}
+
+// Wraps a log formatter, to redact secrets from everything it formats.
+func (m *Manager) Formatter(f logrus.Formatter) logrus.Formatter {
+	return redactingFormatter{f, m}
+}
+
+type redactingFormatter struct {
+	logrus.Formatter
+	m *Manager
+}
+
+func (f redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(f.m.Redact(string(data))), nil
+}
+
+// Parses a source from the command line: "[name:]type[=config]", where type is "file", "vault"
+// or "aws", and the name defaults to the type. Configuration that shouldn't be on the command
+// line, like tokens, comes from env, the way the respective tools take it.
+func ParseSource(fs afero.Fs, env map[string]string, spec string) (string, Source, error) {
+	typ, config := spec, ""
+	if idx := strings.IndexRune(spec, '='); idx != -1 {
+		typ, config = spec[:idx], spec[idx+1:]
+	}
+	name := typ
+	if idx := strings.IndexRune(typ, ':'); idx != -1 {
+		name, typ = typ[:idx], typ[idx+1:]
+	}
+	if name == "" {
+		return "", nil, errors.Errorf("invalid secret source: %s", spec)
+	}
+
+	var src Source
+	var err error
+	switch typ {
+	case "file":
+		src, err = NewFileSource(fs, config)
+	case "vault":
+		src, err = NewVaultSource(env, config)
+	case "aws":
+		src, err = NewAWSSource(env, config)
+	default:
+		err = errors.Errorf("unknown secret source type: %s", typ)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return name, src, nil
+}
@@ -0,0 +1,138 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingSource struct {
+	values map[string]string
+	gets   int
+}
+
+func (s *countingSource) Get(ctx context.Context, name string) (string, error) {
+	s.gets++
+	v, ok := s.values[name]
+	if !ok {
+		return "", errors.New("no such secret")
+	}
+	return v, nil
+}
+
+func TestManager(t *testing.T) {
+	a := &countingSource{values: map[string]string{"token": "abc", "long": "abcdef"}}
+	b := &countingSource{values: map[string]string{"token": "xyz"}}
+	m := NewManager()
+	require.NoError(t, m.Add("a", a))
+	require.NoError(t, m.Add("b", b))
+	assert.EqualError(t, m.Add("a", b), "duplicate secret source: a")
+
+	t.Run("Get", func(t *testing.T) {
+		v, err := m.Get(context.Background(), "", "token")
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", v)
+		v, err = m.Get(context.Background(), "b", "token")
+		assert.NoError(t, err)
+		assert.Equal(t, "xyz", v)
+
+		_, err = m.Get(context.Background(), "a", "token")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, a.gets)
+
+		_, err = m.Get(context.Background(), "c", "token")
+		assert.EqualError(t, err, "unknown secret source: c")
+		_, err = m.Get(context.Background(), "", "nope")
+		assert.EqualError(t, err, "couldn't get the secret nope from a: no such secret")
+	})
+
+	t.Run("Redact", func(t *testing.T) {
+		_, err := m.Get(context.Background(), "", "long")
+		require.NoError(t, err)
+		assert.Equal(t, Redacted+" "+Redacted+" "+Redacted+"123", m.Redact("abcdef xyz abc123"))
+	})
+
+	t.Run("Formatter", func(t *testing.T) {
+		f := m.Formatter(&logrus.TextFormatter{DisableColors: true, DisableTimestamp: true})
+		data, err := f.Format(&logrus.Entry{
+			Message: "logged in with abc",
+			Level:   logrus.InfoLevel,
+			Data:    logrus.Fields{"token": "xyz"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `level=info msg="logged in with `+Redacted+`" token=`+Redacted+"\n", string(data))
+	})
+}
+
+func TestParseSource(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/secrets.env", []byte("A=1"), 0644))
+	env := map[string]string{
+		"VAULT_ADDR":            "http://localhost:8200",
+		"VAULT_TOKEN":           "root",
+		"AWS_REGION":            "eu-west-1",
+		"AWS_ACCESS_KEY_ID":     "AKID",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+	}
+
+	testdata := map[string]struct {
+		name string
+		src  Source
+		err  string
+	}{
+		"file=/secrets.env":       {name: "file", src: &FileSource{}},
+		"local:file=/secrets.env": {name: "local", src: &FileSource{}},
+		"file":                    {err: "the file secret source needs a filename, eg. file=secrets.env"},
+		"vault=secret/k6":         {name: "vault", src: &VaultSource{}},
+		"vault=secret":            {err: "the vault secret source needs a secret, eg. vault=secret/k6"},
+		"aws":                     {name: "aws", src: &AWSSource{}},
+		"prod:aws=prod/":          {name: "prod", src: &AWSSource{}},
+		"gcp=abc":                 {err: "unknown secret source type: gcp"},
+		":file=/secrets.env":      {err: "invalid secret source: :file=/secrets.env"},
+	}
+	for spec, data := range testdata {
+		t.Run(spec, func(t *testing.T) {
+			name, src, err := ParseSource(fs, env, spec)
+			if data.err != "" {
+				assert.EqualError(t, err, data.err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, data.name, name)
+				assert.IsType(t, data.src, src)
+			}
+		})
+	}
+
+	t.Run("MissingEnv", func(t *testing.T) {
+		_, _, err := ParseSource(fs, nil, "vault=secret/k6")
+		assert.EqualError(t, err, "the vault secret source needs VAULT_ADDR and VAULT_TOKEN to be set")
+		_, _, err = ParseSource(fs, nil, "aws")
+		assert.EqualError(t, err, "the aws secret source needs AWS_REGION to be set")
+	})
+}
@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A VaultSource reads secrets from a secret in HashiCorp Vault's KV (version 2) secrets engine,
+// with each of its keys being a secret. Vault is found and logged into with VAULT_ADDR and
+// VAULT_TOKEN, and optionally VAULT_NAMESPACE, like the vault CLI does.
+type VaultSource struct {
+	Client    *http.Client
+	Addr      string
+	Token     string
+	Namespace string
+
+	// Secrets engine the secret is in, and its path there.
+	Mount, Path string
+}
+
+// Creates a source for a secret given as "mount/path", like "secret/k6".
+func NewVaultSource(env map[string]string, secret string) (*VaultSource, error) {
+	parts := strings.SplitN(strings.Trim(secret, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.New("the vault secret source needs a secret, eg. vault=secret/k6")
+	}
+	if env["VAULT_ADDR"] == "" || env["VAULT_TOKEN"] == "" {
+		return nil, errors.New("the vault secret source needs VAULT_ADDR and VAULT_TOKEN to be set")
+	}
+	return &VaultSource{
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		Addr:      strings.TrimRight(env["VAULT_ADDR"], "/"),
+		Token:     env["VAULT_TOKEN"],
+		Namespace: env["VAULT_NAMESPACE"],
+		Mount:     parts[0],
+		Path:      parts[1],
+	}, nil
+}
+
+func (s *VaultSource) Get(ctx context.Context, name string) (string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s/data/%s", s.Addr, s.Mount, s.Path), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.Token)
+	if s.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.Namespace)
+	}
+	res, err := s.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil && res.StatusCode == http.StatusOK {
+		return "", errors.Wrap(err, "invalid response from vault")
+	}
+	if res.StatusCode != http.StatusOK {
+		if len(body.Errors) > 0 {
+			return "", errors.Errorf("vault: %s", strings.Join(body.Errors, ", "))
+		}
+		return "", errors.Errorf("vault: %s", res.Status)
+	}
+
+	switch v := body.Data.Data[name].(type) {
+	case nil:
+		return "", errors.New("no such secret")
+	case string:
+		return v, nil
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/k6" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"password": "hunter2", "port": 5432}, "metadata": {}}}`))
+	}))
+	defer srv.Close()
+
+	src, err := NewVaultSource(map[string]string{"VAULT_ADDR": srv.URL + "/", "VAULT_TOKEN": "root"}, "secret/k6")
+	require.NoError(t, err)
+
+	v, err := src.Get(context.Background(), "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", v)
+	v, err = src.Get(context.Background(), "port")
+	assert.NoError(t, err)
+	assert.Equal(t, "5432", v)
+	_, err = src.Get(context.Background(), "nope")
+	assert.EqualError(t, err, "no such secret")
+
+	src.Path = "other"
+	_, err = src.Get(context.Background(), "password")
+	assert.EqualError(t, err, "vault: 404 Not Found")
+
+	src.Token = "wrong"
+	_, err = src.Get(context.Background(), "password")
+	assert.EqualError(t, err, "vault: permission denied")
+}
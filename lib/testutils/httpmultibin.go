@@ -35,6 +35,7 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/mccutchen/go-httpbin/httpbin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -79,10 +80,11 @@ type HTTPMultiBin struct {
 	Cleanup         func()
 }
 
-func getWebsocketEchoHandler(t *testing.T) http.Handler {
+func getWebsocketEchoHandler(t *testing.T, enableCompression bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		t.Logf("[%p %s] Upgrading to websocket connection...", req, req.URL)
-		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, w.Header())
+		upgrader := websocket.Upgrader{EnableCompression: enableCompression}
+		conn, err := upgrader.Upgrade(w, req, w.Header())
 		if !assert.NoError(t, err) {
 			return
 		}
@@ -110,7 +112,8 @@ func getWebsocketCloserHandler(t *testing.T) http.Handler {
 func NewHTTPMultiBin(t *testing.T) *HTTPMultiBin {
 	// Create a http.ServeMux and set the httpbin handler as the default
 	mux := http.NewServeMux()
-	mux.Handle("/ws-echo", getWebsocketEchoHandler(t))
+	mux.Handle("/ws-echo", getWebsocketEchoHandler(t, false))
+	mux.Handle("/ws-echo-compressed", getWebsocketEchoHandler(t, true))
 	mux.Handle("/ws-close", getWebsocketCloserHandler(t))
 	mux.Handle("/", httpbin.NewHTTPBin().Handler())
 
@@ -135,9 +138,9 @@ func NewHTTPMultiBin(t *testing.T) *HTTPMultiBin {
 		KeepAlive: 10 * time.Second,
 		DualStack: true,
 	})
-	dialer.Hosts = map[string]net.IP{
-		httpDomain:  httpIP,
-		httpsDomain: httpsIP,
+	dialer.Hosts = types.Hosts{
+		httpDomain:  {IP: httpIP},
+		httpsDomain: {IP: httpsIP},
 	}
 
 	// Pre-configure the HTTP client transport with the dialer and TLS config (incl. HTTP2 support)
@@ -162,6 +165,7 @@ func NewHTTPMultiBin(t *testing.T) *HTTPMultiBin {
 			"HTTPSBIN_URL", fmt.Sprintf("https://%s:%s", httpsDomain, httpsURL.Port()),
 			"HTTPSBIN_IP", httpsIP.String(),
 			"HTTPSBIN_PORT", httpsURL.Port(),
+			"WSBIN_URL", fmt.Sprintf("ws://%s:%s", httpDomain, httpURL.Port()),
 		),
 		TLSClientConfig: tlsConfig,
 		Dialer:          dialer,
@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testutils
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	"github.com/loadimpact/k6/lib/netext"
+)
+
+// HTTPProxy is a forward HTTP proxy for tests. It tunnels CONNECT requests and forwards requests
+// for absolute URLs, making its connections with the given dialer, so it knows the HTTPMultiBin
+// domains; with a user set, it asks for basic authentication.
+type HTTPProxy struct {
+	*httptest.Server
+	User, Password string
+
+	// How many requests and tunnels it has proxied; use atomically.
+	Proxied int64
+}
+
+// NewHTTPProxy returns a running HTTPProxy; close it when done.
+func NewHTTPProxy(dialer *netext.Dialer, user, password string) *HTTPProxy {
+	p := &HTTPProxy{User: user, Password: password}
+	transport := &http.Transport{DialContext: dialer.DialContext}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.User != "" {
+			creds := base64.StdEncoding.EncodeToString([]byte(p.User + ":" + p.Password))
+			if r.Header.Get("Proxy-Authorization") != "Basic "+creds {
+				w.Header().Set("Proxy-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+		}
+
+		if r.Method == "CONNECT" {
+			target, err := dialer.DialContext(context.Background(), "tcp", r.Host)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			atomic.AddInt64(&p.Proxied, 1)
+			w.WriteHeader(http.StatusOK)
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				_ = target.Close()
+				return
+			}
+			go func() {
+				_, _ = io.Copy(target, buf)
+				_ = target.Close()
+			}()
+			_, _ = io.Copy(conn, target)
+			_ = conn.Close()
+			return
+		}
+
+		if !r.URL.IsAbs() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Authorization")
+		res, err := transport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+		atomic.AddInt64(&p.Proxied, 1)
+		for k, vs := range res.Header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	}))
+	return p
+}
@@ -0,0 +1,150 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// DefaultTracingSampling is the share of traces marked sampled when it isn't set.
+const DefaultTracingSampling = 1.0
+
+// TracingConfig turns on propagating a trace context with every HTTP request, so the requests can
+// be found in the traces of the system under test; each request starts a trace of its own, and is
+// tagged with its ID.
+type TracingConfig struct {
+	// The format of the headers: w3c, b3 or b3multi; tracing is off if it isn't set.
+	Propagator null.String
+
+	// The share of traces the backend is asked to keep, from 0 to 1.
+	Sampling null.Float
+}
+
+// Apply returns the config with the fields set on the argument overwritten.
+func (c TracingConfig) Apply(cfg TracingConfig) TracingConfig {
+	if cfg.Propagator.Valid {
+		c.Propagator = cfg.Propagator
+	}
+	if cfg.Sampling.Valid {
+		c.Sampling = cfg.Sampling
+	}
+	return c
+}
+
+// Enabled returns whether requests should carry a trace context.
+func (c TracingConfig) Enabled() bool {
+	return c.Propagator.Valid && c.Propagator.String != ""
+}
+
+// SamplingRate returns the share of traces to mark sampled, filling in the default.
+func (c TracingConfig) SamplingRate() float64 {
+	if c.Sampling.Valid {
+		return c.Sampling.Float64
+	}
+	return DefaultTracingSampling
+}
+
+func (c *TracingConfig) set(key, value string) error {
+	switch key {
+	case "propagator":
+		switch value {
+		case netext.PropagatorW3C, netext.PropagatorB3, netext.PropagatorB3Multi:
+			c.Propagator = null.StringFrom(value)
+		default:
+			return errors.Errorf("invalid tracing propagator: %s, it can be w3c, b3 or b3multi", value)
+		}
+	case "sampling":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 || f > 1 {
+			return errors.Errorf("invalid tracing sampling: %s, it has to be a number from 0 to 1", value)
+		}
+		c.Sampling = null.FloatFrom(f)
+	default:
+		return errors.Errorf("unknown tracing option: %s, they are propagator and sampling", key)
+	}
+	return nil
+}
+
+// UnmarshalText reads the config from a list like "propagator=w3c,sampling=0.1", eg. from the
+// --tracing flag or the K6_TRACING environment variable.
+func (c *TracingConfig) UnmarshalText(data []byte) error {
+	var cfg TracingConfig
+	for _, kv := range strings.Split(string(data), ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("invalid tracing option: %s, it has to be key=value", kv)
+		}
+		if err := cfg.set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// UnmarshalJSON reads the config from an object.
+func (c *TracingConfig) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var cfg TracingConfig
+	for key, raw := range fields {
+		if bytes.Equal(raw, []byte(`null`)) {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			var f float64
+			if key != "sampling" || json.Unmarshal(raw, &f) != nil {
+				return errors.Errorf("invalid tracing %s: %s", key, raw)
+			}
+			value = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		if err := cfg.set(key, value); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// MarshalJSON writes the config as an object, leaving out what isn't set.
+func (c TracingConfig) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{}
+	if c.Propagator.Valid {
+		fields["propagator"] = c.Propagator.String
+	}
+	if c.Sampling.Valid {
+		fields["sampling"] = c.Sampling.Float64
+	}
+	return json.Marshal(fields)
+}
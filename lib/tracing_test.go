@@ -0,0 +1,74 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestTracingConfig(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		var c TracingConfig
+		assert.NoError(t, c.UnmarshalText([]byte("propagator=b3, sampling=0.25")))
+		assert.Equal(t, TracingConfig{Propagator: null.StringFrom("b3"), Sampling: null.FloatFrom(0.25)}, c)
+
+		testdata := map[string]string{
+			"propagator=jaeger": "invalid tracing propagator: jaeger, it can be w3c, b3 or b3multi",
+			"sampling=2":        "invalid tracing sampling: 2, it has to be a number from 0 to 1",
+			"sampling=all":      "invalid tracing sampling: all, it has to be a number from 0 to 1",
+			"header=x-trace":    "unknown tracing option: header, they are propagator and sampling",
+			"propagator":        "invalid tracing option: propagator, it has to be key=value",
+		}
+		for text, msg := range testdata {
+			assert.EqualError(t, c.UnmarshalText([]byte(text)), msg)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var c TracingConfig
+		assert.NoError(t, json.Unmarshal([]byte(`{"propagator": "w3c", "sampling": 0.5}`), &c))
+		assert.Equal(t, TracingConfig{Propagator: null.StringFrom("w3c"), Sampling: null.FloatFrom(0.5)}, c)
+
+		data, err := json.Marshal(c)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"propagator": "w3c", "sampling": 0.5}`, string(data))
+
+		assert.NoError(t, json.Unmarshal([]byte(`{"propagator": "b3multi", "sampling": null}`), &c))
+		assert.Equal(t, TracingConfig{Propagator: null.StringFrom("b3multi")}, c)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"propagator": 1}`), &c), "invalid tracing propagator: 1")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"sampling": -0.5}`), &c),
+			"invalid tracing sampling: -0.5, it has to be a number from 0 to 1")
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		assert.False(t, TracingConfig{}.Enabled())
+		assert.Equal(t, 1.0, TracingConfig{}.SamplingRate())
+
+		c := TracingConfig{Sampling: null.FloatFrom(0)}.Apply(TracingConfig{Propagator: null.StringFrom("w3c")})
+		assert.True(t, c.Enabled())
+		assert.Equal(t, 0.0, c.SamplingRate())
+	})
+}
@@ -0,0 +1,222 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HostAddress is the IP, and optionally the port, that connections to a hostname are made to
+// instead of what it resolves to. A zero port keeps the one that was asked for.
+type HostAddress net.TCPAddr
+
+// ParseHostAddress parses an IP with an optional port, eg. "192.0.2.1", "192.0.2.1:8080",
+// "2001:db8::1" or "[2001:db8::1]:8080".
+func ParseHostAddress(s string) (*HostAddress, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		return &HostAddress{IP: ip}, nil
+	}
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, errors.Errorf("invalid host address: %s, it has to be an IP with an optional port", s)
+	}
+	ip := net.ParseIP(host)
+	port, err := strconv.Atoi(portStr)
+	if ip == nil || err != nil || port < 1 || port > 65535 {
+		return nil, errors.Errorf("invalid host address: %s, it has to be an IP with an optional port", s)
+	}
+	return &HostAddress{IP: ip, Port: port}, nil
+}
+
+func (a *HostAddress) String() string {
+	if a.Port == 0 {
+		return a.IP.String()
+	}
+	return net.JoinHostPort(a.IP.String(), strconv.Itoa(a.Port))
+}
+
+func (a *HostAddress) UnmarshalText(data []byte) error {
+	addr, err := ParseHostAddress(string(data))
+	if err != nil {
+		return err
+	}
+	*a = *addr
+	return nil
+}
+
+func (a HostAddress) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Hosts maps hostnames to the addresses connections to them are made to. A hostname may be a
+// wildcard, like "*.example.com", which matches all of its subdomains; exact hostnames take
+// precedence over wildcards, and longer wildcards over shorter ones.
+type Hosts map[string]*HostAddress
+
+// Match returns the address a hostname is mapped to, if any.
+func (h Hosts) Match(hostname string) (*HostAddress, bool) {
+	if len(h) == 0 {
+		return nil, false
+	}
+	name, ok := MatchHostname(hostname, func(name string) bool { _, ok := h[name]; return ok })
+	if !ok {
+		return nil, false
+	}
+	return h[name], true
+}
+
+// MatchHostname returns the most specific of a set of hostnames and wildcards that a hostname
+// matches: the hostname itself, or else the longest wildcard, as checked by the has function.
+func MatchHostname(hostname string, has func(name string) bool) (string, bool) {
+	hostname = strings.ToLower(hostname)
+	if has(hostname) {
+		return hostname, true
+	}
+	for i := strings.IndexByte(hostname, '.'); i >= 0; {
+		if name := "*" + hostname[i:]; has(name) {
+			return name, true
+		}
+		next := strings.IndexByte(hostname[i+1:], '.')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return "", false
+}
+
+// UnmarshalJSON reads the hosts from an object of hostnames to addresses.
+func (h *Hosts) UnmarshalJSON(data []byte) error {
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if fields == nil {
+		*h = nil
+		return nil
+	}
+	hosts := make(Hosts, len(fields))
+	for name, value := range fields {
+		if err := hosts.set(name, value); err != nil {
+			return err
+		}
+	}
+	*h = hosts
+	return nil
+}
+
+// UnmarshalText reads the hosts from a list like "example.com=192.0.2.1,*.example.net=192.0.2.2:8080",
+// eg. from the K6_HOSTS environment variable.
+func (h *Hosts) UnmarshalText(data []byte) error {
+	hosts := Hosts{}
+	for _, kv := range strings.Split(string(data), ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("invalid host: %s, it has to be hostname=address", kv)
+		}
+		if err := hosts.set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return err
+		}
+	}
+	*h = hosts
+	return nil
+}
+
+func (h Hosts) set(name, value string) error {
+	name = strings.ToLower(name)
+	if err := ValidateHostname(name); err != nil {
+		return err
+	}
+	addr, err := ParseHostAddress(value)
+	if err != nil {
+		return errors.Wrapf(err, "host %s", name)
+	}
+	h[name] = addr
+	return nil
+}
+
+// Hostnames is a list of hostnames, which may be wildcards like "*.example.com".
+type Hostnames []string
+
+// Match returns the first hostname or wildcard a hostname matches, if any.
+func (n Hostnames) Match(hostname string) (string, bool) {
+	hostname = strings.ToLower(hostname)
+	for _, pattern := range n {
+		if pattern == hostname || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(hostname, pattern[1:])) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// UnmarshalJSON reads the hostnames from a list.
+func (n *Hostnames) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	return n.set(names)
+}
+
+// UnmarshalText reads the hostnames from a comma separated list, eg. for an environment variable.
+func (n *Hostnames) UnmarshalText(data []byte) error {
+	var names []string
+	for _, name := range strings.Split(string(data), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return n.set(names)
+}
+
+func (n *Hostnames) set(names []string) error {
+	if names == nil {
+		*n = nil
+		return nil
+	}
+	res := make(Hostnames, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if err := ValidateHostname(name); err != nil {
+			return err
+		}
+		res = append(res, name)
+	}
+	*n = res
+	return nil
+}
+
+// ValidateHostname checks that a hostname is either exact or a wildcard for all of its subdomains.
+func ValidateHostname(name string) error {
+	wildcard := strings.TrimPrefix(name, "*.")
+	if name == "" || wildcard == "" || strings.Contains(wildcard, "*") {
+		return errors.Errorf("invalid hostname: %s, wildcards can only be at the start, like *.example.com", name)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHostAddress(t *testing.T) {
+	testdata := map[string]string{
+		"192.0.2.1":           "192.0.2.1",
+		"192.0.2.1:8080":      "192.0.2.1:8080",
+		"2001:db8::1":         "2001:db8::1",
+		"[2001:db8::1]:8080":  "[2001:db8::1]:8080",
+		"example.com":         "",
+		"192.0.2.1:http":      "",
+		"192.0.2.1:70000":     "",
+		"[2001:db8::1]:":      "",
+		"example.com:8080":    "",
+		"192.0.2.1:8080:8080": "",
+	}
+	for s, expected := range testdata {
+		addr, err := ParseHostAddress(s)
+		if expected == "" {
+			assert.EqualError(t, err, "invalid host address: "+s+", it has to be an IP with an optional port")
+			continue
+		}
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, addr.String())
+	}
+}
+
+func TestHosts(t *testing.T) {
+	var hosts Hosts
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"Example.com": "192.0.2.1",
+		"*.example.com": "192.0.2.2:8080",
+		"*.api.example.com": "2001:db8::1"
+	}`), &hosts))
+
+	testdata := map[string]string{
+		"example.com":        "192.0.2.1",
+		"EXAMPLE.com":        "192.0.2.1",
+		"www.example.com":    "192.0.2.2:8080",
+		"a.b.example.com":    "192.0.2.2:8080",
+		"v1.api.example.com": "2001:db8::1",
+		"api.example.com":    "192.0.2.2:8080",
+		"example.net":        "",
+		"badexample.com":     "",
+		"com":                "",
+	}
+	for hostname, expected := range testdata {
+		addr, ok := hosts.Match(hostname)
+		if expected == "" {
+			assert.False(t, ok, hostname)
+			continue
+		}
+		if assert.True(t, ok, hostname) {
+			assert.Equal(t, expected, addr.String(), hostname)
+		}
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(hosts)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"example.com": "192.0.2.1",
+			"*.example.com": "192.0.2.2:8080",
+			"*.api.example.com": "2001:db8::1"
+		}`, string(data))
+
+		var h Hosts
+		assert.EqualError(t, json.Unmarshal([]byte(`{"example.com": "localhost"}`), &h),
+			"host example.com: invalid host address: localhost, it has to be an IP with an optional port")
+		assert.EqualError(t, json.Unmarshal([]byte(`{"api.*.com": "192.0.2.1"}`), &h),
+			"invalid hostname: api.*.com, wildcards can only be at the start, like *.example.com")
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		var h Hosts
+		require.NoError(t, h.UnmarshalText([]byte("example.com=192.0.2.1, *.example.net=[2001:db8::1]:443")))
+		assert.Equal(t, Hosts{
+			"example.com":   {IP: net.ParseIP("192.0.2.1")},
+			"*.example.net": {IP: net.ParseIP("2001:db8::1"), Port: 443},
+		}, h)
+		assert.EqualError(t, h.UnmarshalText([]byte("example.com")), "invalid host: example.com, it has to be hostname=address")
+	})
+}
+
+func TestHostnames(t *testing.T) {
+	var names Hostnames
+	require.NoError(t, names.UnmarshalText([]byte("ads.example.net, *.Analytics.com")))
+	assert.Equal(t, Hostnames{"ads.example.net", "*.analytics.com"}, names)
+
+	testdata := map[string]string{
+		"ads.example.net":       "ads.example.net",
+		"www.ads.example.net":   "",
+		"example.net":           "",
+		"x.analytics.com":       "*.analytics.com",
+		"a.b.ANALYTICS.com":     "*.analytics.com",
+		"analytics.com":         "",
+		"notanalytics.com":      "",
+		"analytics.com.example": "",
+	}
+	for hostname, expected := range testdata {
+		pattern, ok := names.Match(hostname)
+		assert.Equal(t, expected != "", ok, hostname)
+		assert.Equal(t, expected, pattern, hostname)
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`["*.example.com"]`), &names))
+	assert.Equal(t, Hostnames{"*.example.com"}, names)
+	assert.EqualError(t, json.Unmarshal([]byte(`["*"]`), &names),
+		"invalid hostname: *, wildcards can only be at the start, like *.example.com")
+}
@@ -0,0 +1,99 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPVersion is the highest HTTP version requests are made with.
+type HTTPVersion string
+
+// The HTTP versions requests can be made with. HTTP/2 and HTTP/3 need TLS, so requests to http://
+// URLs are always made with HTTP/1.1.
+const (
+	// HTTPVersion1 is HTTP/1.1 only, without offering HTTP/2 in the TLS handshake.
+	HTTPVersion1 HTTPVersion = "1.1"
+	// HTTPVersion2 is HTTP/2 when the server agrees to it in the TLS handshake, and HTTP/1.1
+	// otherwise. It's the default.
+	HTTPVersion2 HTTPVersion = "2"
+	// HTTPVersion3 is HTTP/3 over QUIC. Servers that don't speak QUIC can't be reached with it.
+	HTTPVersion3 HTTPVersion = "3"
+)
+
+// ParseHTTPVersion parses an HTTP version: 1.1, 2 or 3.
+func ParseHTTPVersion(s string) (HTTPVersion, error) {
+	switch v := HTTPVersion(s); v {
+	case HTTPVersion1, HTTPVersion2, HTTPVersion3:
+		return v, nil
+	default:
+		return "", errors.Errorf("invalid HTTP version: %s, it can be 1.1, 2 or 3", s)
+	}
+}
+
+// NullHTTPVersion is an HTTPVersion that may be unset, which is HTTPVersion2.
+type NullHTTPVersion struct {
+	Version HTTPVersion
+	Valid   bool
+}
+
+// NullHTTPVersionFrom returns a valid NullHTTPVersion with the given version.
+func NullHTTPVersionFrom(v HTTPVersion) NullHTTPVersion {
+	return NullHTTPVersion{v, true}
+}
+
+// UnmarshalText reads the version with ParseHTTPVersion.
+func (v *NullHTTPVersion) UnmarshalText(data []byte) error {
+	version, err := ParseHTTPVersion(string(data))
+	if err != nil {
+		return err
+	}
+	*v = NullHTTPVersion{version, true}
+	return nil
+}
+
+// UnmarshalJSON reads the version from a string or a number, eg. "1.1" or 3; null leaves it unset.
+func (v *NullHTTPVersion) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte(`null`)) {
+		*v = NullHTTPVersion{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return errors.Errorf("invalid HTTP version: %s, it has to be a string or a number", data)
+		}
+		s = n.String()
+	}
+	return v.UnmarshalText([]byte(s))
+}
+
+// MarshalJSON writes the version as a string.
+func (v NullHTTPVersion) MarshalJSON() ([]byte, error) {
+	if !v.Valid {
+		return []byte(`null`), nil
+	}
+	return json.Marshal(string(v.Version))
+}
@@ -0,0 +1,80 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTTPVersion(t *testing.T) {
+	testdata := map[string]string{
+		"1.1": "",
+		"2":   "",
+		"3":   "",
+		"":    "invalid HTTP version: , it can be 1.1, 2 or 3",
+		"1.0": "invalid HTTP version: 1.0, it can be 1.1, 2 or 3",
+		"h3":  "invalid HTTP version: h3, it can be 1.1, 2 or 3",
+	}
+	for s, errMsg := range testdata {
+		t.Run(s, func(t *testing.T) {
+			v, err := ParseHTTPVersion(s)
+			if errMsg != "" {
+				assert.EqualError(t, err, errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, HTTPVersion(s), v)
+		})
+	}
+}
+
+func TestNullHTTPVersion(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var v NullHTTPVersion
+		require.NoError(t, json.Unmarshal([]byte(`"3"`), &v))
+		assert.Equal(t, NullHTTPVersionFrom(HTTPVersion3), v)
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		assert.JSONEq(t, `"3"`, string(data))
+
+		require.NoError(t, json.Unmarshal([]byte(`1.1`), &v))
+		assert.Equal(t, NullHTTPVersionFrom(HTTPVersion1), v)
+
+		require.NoError(t, json.Unmarshal([]byte(`null`), &v))
+		assert.Equal(t, NullHTTPVersion{}, v)
+		data, err = json.Marshal(v)
+		require.NoError(t, err)
+		assert.JSONEq(t, `null`, string(data))
+
+		assert.EqualError(t, json.Unmarshal([]byte(`true`), &v),
+			"invalid HTTP version: true, it has to be a string or a number")
+		assert.EqualError(t, json.Unmarshal([]byte(`4`), &v), "invalid HTTP version: 4, it can be 1.1, 2 or 3")
+	})
+	t.Run("Text", func(t *testing.T) {
+		var v NullHTTPVersion
+		require.NoError(t, v.UnmarshalText([]byte("2")))
+		assert.Equal(t, NullHTTPVersionFrom(HTTPVersion2), v)
+	})
+}
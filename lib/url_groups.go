@@ -0,0 +1,126 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A URLGroup gives all of the URLs that match it the same name tag, so that requests to eg.
+// /users/1234 and /users/5678 are one endpoint in the metrics, not thousands of them. It matches
+// either a wildcard pattern, where * stands for anything but a slash, and ** for anything at all,
+// or a regular expression.
+type URLGroup struct {
+	// A wildcard pattern that matches whole URLs, eg. https://example.com/users/*/posts; unless it
+	// has a ?, the query and fragment of a URL aren't matched against it.
+	Pattern string `json:"pattern,omitempty"`
+
+	// A regular expression, matched anywhere in the URL unless it's anchored.
+	Regex string `json:"regex,omitempty"`
+
+	// The name the matching URLs are tagged with, which may refer to the regular expression's
+	// groups as $1 or ${name}; a pattern's name is the pattern itself by default.
+	Name string `json:"name,omitempty"`
+
+	re         *regexp.Regexp
+	ignoreRest bool
+}
+
+// NewURLGroup compiles a URL group.
+func NewURLGroup(pattern, regex, name string) (*URLGroup, error) {
+	g := &URLGroup{Pattern: pattern, Regex: regex, Name: name}
+	switch {
+	case pattern != "" && regex != "":
+		return nil, errors.New("a URL group has either a pattern or a regex, not both")
+	case pattern != "":
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.Replace(expr, `\*\*`, `.*`, -1)
+		expr = strings.Replace(expr, `\*`, `[^/]*`, -1)
+		g.re = regexp.MustCompile("^" + expr + "$")
+		g.ignoreRest = !strings.Contains(pattern, "?")
+		if g.Name == "" {
+			g.Name = pattern
+		}
+	case regex != "":
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "URL group %s", regex)
+		}
+		if name == "" {
+			return nil, errors.Errorf("URL group %s needs a name", regex)
+		}
+		g.re = re
+	default:
+		return nil, errors.New("a URL group needs a pattern or a regex")
+	}
+	return g, nil
+}
+
+// Match returns the name a URL is tagged with, if it matches the group.
+func (g *URLGroup) Match(url string) (string, bool) {
+	if g.ignoreRest {
+		if i := strings.IndexAny(url, "?#"); i >= 0 {
+			url = url[:i]
+		}
+	}
+	m := g.re.FindStringSubmatchIndex(url)
+	if m == nil {
+		return "", false
+	}
+	if g.Regex == "" {
+		return g.Name, true
+	}
+	return string(g.re.ExpandString(nil, g.Name, url, m)), true
+}
+
+func (g *URLGroup) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Pattern string `json:"pattern"`
+		Regex   string `json:"regex"`
+		Name    string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	group, err := NewURLGroup(fields.Pattern, fields.Regex, fields.Name)
+	if err != nil {
+		return err
+	}
+	*g = *group
+	return nil
+}
+
+// URLGroups are tried in order; the first group a URL matches names it.
+type URLGroups []*URLGroup
+
+// Match returns the name of the first group a URL matches, if any.
+func (gs URLGroups) Match(url string) (string, bool) {
+	for _, g := range gs {
+		if name, ok := g.Match(url); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
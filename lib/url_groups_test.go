@@ -0,0 +1,119 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLGroup(t *testing.T) {
+	testdata := map[string]struct {
+		Pattern, Regex, Name string
+		Matches              map[string]string
+		NoMatches            []string
+	}{
+		"pattern": {
+			Pattern: "https://example.com/users/*/posts",
+			Matches: map[string]string{
+				"https://example.com/users/1234/posts":     "https://example.com/users/*/posts",
+				"https://example.com/users/abc/posts?a=1":  "https://example.com/users/*/posts",
+				"https://example.com/users/abc/posts#frag": "https://example.com/users/*/posts",
+			},
+			NoMatches: []string{
+				"https://example.com/users/1/2/posts",
+				"https://example.com/users/1/posts/3",
+				"https://example.org/users/1/posts",
+			},
+		},
+		"double star": {
+			Pattern: "https://example.com/static/**", Name: "static",
+			Matches: map[string]string{
+				"https://example.com/static/js/app.js": "static",
+				"https://example.com/static/":          "static",
+			},
+			NoMatches: []string{"https://example.com/api/static/x"},
+		},
+		"query": {
+			Pattern: "https://example.com/search?q=*",
+			Matches: map[string]string{
+				"https://example.com/search?q=shoes": "https://example.com/search?q=*",
+			},
+			NoMatches: []string{"https://example.com/search"},
+		},
+		"regex": {
+			Regex: `^https://example\.com/(\w+)/\d+$`, Name: "https://example.com/$1/{id}",
+			Matches: map[string]string{
+				"https://example.com/users/1234": "https://example.com/users/{id}",
+				"https://example.com/items/5":    "https://example.com/items/{id}",
+			},
+			NoMatches: []string{"https://example.com/users/abc"},
+		},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			g, err := NewURLGroup(data.Pattern, data.Regex, data.Name)
+			require.NoError(t, err)
+			for url, name := range data.Matches {
+				matched, ok := g.Match(url)
+				assert.True(t, ok, url)
+				assert.Equal(t, name, matched, url)
+			}
+			for _, url := range data.NoMatches {
+				_, ok := g.Match(url)
+				assert.False(t, ok, url)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewURLGroup("", "", "")
+		assert.EqualError(t, err, "a URL group needs a pattern or a regex")
+		_, err = NewURLGroup("a", "b", "")
+		assert.EqualError(t, err, "a URL group has either a pattern or a regex, not both")
+		_, err = NewURLGroup("", "a", "")
+		assert.EqualError(t, err, "URL group a needs a name")
+		_, err = NewURLGroup("", "(", "x")
+		assert.EqualError(t, err, "URL group (: error parsing regexp: missing closing ): `(`")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var opts Options
+		require.NoError(t, json.Unmarshal([]byte(`{"urlGroups": [
+			{"pattern": "https://example.com/users/*"},
+			{"regex": "^https://example\\.com/", "name": "other"}
+		]}`), &opts))
+		name, ok := opts.URLGroups.Match("https://example.com/users/1")
+		assert.True(t, ok)
+		assert.Equal(t, "https://example.com/users/*", name)
+		name, ok = opts.URLGroups.Match("https://example.com/items/1")
+		assert.True(t, ok)
+		assert.Equal(t, "other", name)
+		_, ok = opts.URLGroups.Match("https://example.org/")
+		assert.False(t, ok)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"urlGroups": [{"name": "x"}]}`), &opts),
+			"a URL group needs a pattern or a regex")
+	})
+}
@@ -21,6 +21,7 @@
 package lib
 
 import (
+	"math"
 	"strings"
 
 	"github.com/loadimpact/k6/lib/types"
@@ -79,3 +80,37 @@ func Min(a, b int64) int64 {
 	}
 	return b
 }
+
+// SpreadPick deterministically picks one of the given names for a VU, with probabilities
+// proportional to their weights. The fractional parts of multiples of an irrational step are evenly
+// distributed in [0.0 - 1.0), so even a handful of VUs gets close to the requested mix; different
+// steps keep the picks for different features from being correlated. Names with non-positive
+// weights are never picked, and an empty string is returned if there's nothing to pick from.
+func SpreadPick(vuID int64, step float64, names []string, weights map[string]float64) string {
+	total := 0.0
+	for _, name := range names {
+		if w := weights[name]; w > 0 {
+			total += w
+		}
+	}
+	if total <= 0 {
+		return ""
+	}
+
+	_, point := math.Modf(float64(vuID) * step)
+	target := point * total
+	acc := 0.0
+	last := ""
+	for _, name := range names {
+		w := weights[name]
+		if w <= 0 {
+			continue
+		}
+		last = name
+		acc += w
+		if target < acc {
+			return name
+		}
+	}
+	return last
+}
@@ -0,0 +1,240 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// The prefix of the hashes in lockfiles; the only supported hash so far.
+const hashPrefix = "sha256:"
+
+// Configures a ModuleCache.
+type ModuleCacheConfig struct {
+	// Directory to cache remote modules in.
+	Dir string
+
+	// Lockfile with the hashes remote modules are pinned to, if any.
+	Lockfile string
+
+	// Never fetch remote modules, only use cached ones.
+	Offline bool
+
+	// Require every remote module to be pinned in the lockfile, and never update it.
+	Frozen bool
+}
+
+// A lockfile pins remote modules, by name, to the hashes of their contents.
+type lockfile struct {
+	Modules map[string]string `json:"modules"`
+}
+
+// A ModuleCache keeps remote modules on disk, keyed by the hashes of their contents, so that they
+// don't have to be fetched again, and optionally pins them in a lockfile, so that a test always
+// runs with the same modules it was first run with.
+type ModuleCache struct {
+	fs   afero.Fs
+	conf ModuleCacheConfig
+
+	mu   sync.Mutex
+	lock lockfile
+}
+
+// Opens a module cache, reading its lockfile, if it has one.
+func NewModuleCache(fs afero.Fs, conf ModuleCacheConfig) (*ModuleCache, error) {
+	c := &ModuleCache{fs: fs, conf: conf, lock: lockfile{Modules: make(map[string]string)}}
+	if conf.Lockfile == "" {
+		if conf.Frozen {
+			return nil, errors.New("a frozen module cache needs a lockfile")
+		}
+		return c, nil
+	}
+
+	data, err := afero.ReadFile(fs, conf.Lockfile)
+	switch {
+	case os.IsNotExist(err) && !conf.Frozen:
+		return c, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "couldn't read the lockfile")
+	}
+	if err := json.Unmarshal(data, &c.lock); err != nil {
+		return nil, errors.Wrapf(err, "invalid lockfile %s", conf.Lockfile)
+	}
+	if c.lock.Modules == nil {
+		c.lock.Modules = make(map[string]string)
+	}
+	for name, hash := range c.lock.Modules {
+		if !strings.HasPrefix(hash, hashPrefix) {
+			return nil, errors.Errorf("invalid lockfile %s: unsupported hash for %s: %s", conf.Lockfile, name, hash)
+		}
+	}
+	return c, nil
+}
+
+// Returns a remote module, from the cache if it can, or using fetch otherwise.
+func (c *ModuleCache) Load(name string, fetch func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	pinned, isPinned := c.lock.Modules[name]
+	c.mu.Unlock()
+
+	// A pinned module can always be served from the cache, if it's in there; anything else only
+	// is when offline, as we'd otherwise never notice it changing.
+	hash := pinned
+	if !isPinned && c.conf.Offline {
+		hash = c.readIndex(name)
+	}
+	if hash != "" {
+		if data, ok := c.readBlob(hash); ok {
+			return data, nil
+		}
+	}
+
+	switch {
+	case c.conf.Frozen && !isPinned:
+		return nil, errors.Errorf("%s isn't pinned in the lockfile %s", name, c.conf.Lockfile)
+	case c.conf.Offline:
+		return nil, errors.Errorf("%s isn't in the module cache, and can't be fetched offline", name)
+	}
+
+	data, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	hash = hashOf(data)
+	if isPinned && hash != pinned {
+		return nil, errors.Errorf(
+			"%s doesn't match the lockfile %s: expected %s, got %s",
+			name, c.conf.Lockfile, pinned, hash,
+		)
+	}
+
+	c.writeBlob(name, hash, data)
+	if !isPinned && c.conf.Lockfile != "" && !c.conf.Frozen {
+		if err := c.pin(name, hash); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Pins a module in the lockfile, and writes it out.
+func (c *ModuleCache) pin(name, hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lock.Modules[name] = hash
+	data, err := json.MarshalIndent(c.lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(c.fs, c.conf.Lockfile, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "couldn't write the lockfile")
+	}
+	return nil
+}
+
+func (c *ModuleCache) blobPath(hash string) string {
+	return filepath.Join(c.conf.Dir, "sha256", strings.TrimPrefix(hash, hashPrefix))
+}
+
+// Modules are indexed by the hashes of their names, so that the last fetched version can be found
+// when offline, without a lockfile.
+func (c *ModuleCache) indexPath(name string) string {
+	return filepath.Join(c.conf.Dir, "names", strings.TrimPrefix(hashOf([]byte(name)), hashPrefix))
+}
+
+// Returns a cached module, unless it isn't there or has been tampered with.
+func (c *ModuleCache) readBlob(hash string) ([]byte, bool) {
+	data, err := afero.ReadFile(c.fs, c.blobPath(hash))
+	if err != nil || hashOf(data) != hash {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *ModuleCache) readIndex(name string) string {
+	data, err := afero.ReadFile(c.fs, c.indexPath(name))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Caches a module; failing to is only worth a warning, as it'll just be fetched again next time.
+func (c *ModuleCache) writeBlob(name, hash string, data []byte) {
+	files := []struct {
+		path string
+		data []byte
+	}{
+		{c.blobPath(hash), data},
+		{c.indexPath(name), []byte(hash)},
+	}
+	for _, file := range files {
+		err := c.fs.MkdirAll(filepath.Dir(file.path), 0755)
+		if err == nil {
+			err = afero.WriteFile(c.fs, file.path, file.data, 0644)
+		}
+		if err != nil {
+			log.WithError(err).WithField("name", name).Warn("Couldn't cache a remote module")
+			return
+		}
+	}
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+var (
+	moduleCache   *ModuleCache
+	moduleCacheMu sync.RWMutex
+)
+
+// Sets the cache remote modules are loaded through; nil, the default, loads them straight off the
+// network every time.
+func SetModuleCache(c *ModuleCache) {
+	moduleCacheMu.Lock()
+	defer moduleCacheMu.Unlock()
+	moduleCache = c
+}
+
+// Loads a remote module through the module cache, if there is one.
+func loadRemote(name string, fetch func() ([]byte, error)) ([]byte, error) {
+	moduleCacheMu.RLock()
+	c := moduleCache
+	moduleCacheMu.RUnlock()
+
+	if c == nil {
+		return fetch()
+	}
+	return c.Load(name, fetch)
+}
@@ -0,0 +1,141 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package loader
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleCache(t *testing.T) {
+	const name = "example.com/lib.js"
+	var fetches int
+	fetchData := func(data string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			fetches++
+			return []byte(data), nil
+		}
+	}
+	hash := hashOf([]byte("v1"))
+
+	t.Run("NoLockfile", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		c, err := NewModuleCache(fs, ModuleCacheConfig{Dir: "/cache"})
+		require.NoError(t, err)
+
+		fetches = 0
+		data, err := c.Load(name, fetchData("v1"))
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", string(data))
+		data, err = c.Load(name, fetchData("v2"))
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", string(data))
+		assert.Equal(t, 2, fetches)
+
+		offline, err := NewModuleCache(fs, ModuleCacheConfig{Dir: "/cache", Offline: true})
+		require.NoError(t, err)
+		data, err = offline.Load(name, fetchData("v3"))
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", string(data))
+		assert.Equal(t, 2, fetches)
+
+		_, err = offline.Load("example.com/other.js", fetchData("v3"))
+		assert.EqualError(t, err, "example.com/other.js isn't in the module cache, and can't be fetched offline")
+		assert.Equal(t, 2, fetches)
+	})
+
+	t.Run("Lockfile", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		conf := ModuleCacheConfig{Dir: "/cache", Lockfile: "/k6.lock.json"}
+		c, err := NewModuleCache(fs, conf)
+		require.NoError(t, err)
+
+		fetches = 0
+		_, err = c.Load(name, fetchData("v1"))
+		assert.NoError(t, err)
+		lock, err := afero.ReadFile(fs, "/k6.lock.json")
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"modules": {"`+name+`": "`+hash+`"}}`, string(lock))
+
+		// Pinned modules are served from the cache, without fetching them again.
+		c, err = NewModuleCache(fs, conf)
+		require.NoError(t, err)
+		data, err := c.Load(name, fetchData("v2"))
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", string(data))
+		assert.Equal(t, 1, fetches)
+
+		t.Run("Tampered", func(t *testing.T) {
+			require.NoError(t, afero.WriteFile(fs, c.blobPath(hash), []byte("evil"), 0644))
+			data, err := c.Load(name, fetchData("v1"))
+			assert.NoError(t, err)
+			assert.Equal(t, "v1", string(data))
+			assert.Equal(t, 2, fetches)
+		})
+
+		t.Run("Changed", func(t *testing.T) {
+			require.NoError(t, fs.RemoveAll("/cache"))
+			_, err := c.Load(name, fetchData("v2"))
+			assert.EqualError(t, err, name+" doesn't match the lockfile /k6.lock.json: expected "+hash+", got "+hashOf([]byte("v2")))
+		})
+
+		t.Run("FetchError", func(t *testing.T) {
+			_, err := c.Load(name, func() ([]byte, error) { return nil, errors.New("no network") })
+			assert.EqualError(t, err, "no network")
+		})
+	})
+
+	t.Run("Frozen", func(t *testing.T) {
+		_, err := NewModuleCache(afero.NewMemMapFs(), ModuleCacheConfig{Dir: "/cache", Frozen: true})
+		assert.EqualError(t, err, "a frozen module cache needs a lockfile")
+
+		conf := ModuleCacheConfig{Dir: "/cache", Lockfile: "/k6.lock.json", Frozen: true}
+		_, err = NewModuleCache(afero.NewMemMapFs(), conf)
+		assert.Error(t, err)
+
+		fs := afero.NewMemMapFs()
+		lock := []byte(`{"modules": {"` + name + `": "` + hash + `"}}`)
+		require.NoError(t, afero.WriteFile(fs, "/k6.lock.json", lock, 0644))
+		c, err := NewModuleCache(fs, conf)
+		require.NoError(t, err)
+
+		data, err := c.Load(name, fetchData("v1"))
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", string(data))
+
+		_, err = c.Load("example.com/other.js", fetchData("v1"))
+		assert.EqualError(t, err, "example.com/other.js isn't pinned in the lockfile /k6.lock.json")
+		written, err := afero.ReadFile(fs, "/k6.lock.json")
+		assert.NoError(t, err)
+		assert.Equal(t, string(lock), string(written))
+	})
+
+	t.Run("InvalidLockfile", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/k6.lock.json", []byte(`{"modules": {"a.js": "md5:abc"}}`), 0644))
+		_, err := NewModuleCache(fs, ModuleCacheConfig{Dir: "/cache", Lockfile: "/k6.lock.json"})
+		assert.EqualError(t, err, "invalid lockfile /k6.lock.json: unsupported hash for a.js: md5:abc")
+	})
+}
@@ -107,19 +107,35 @@ func Load(fs afero.Fs, pwd, name string) (*lib.SourceData, error) {
 	// If the file is from a known service, try loading from there.
 	loaderName, loader, loaderArgs := pickLoader(name)
 	if loader != nil {
-		u, err := loader(name, loaderArgs)
+		data, err := loadRemote(name, func() ([]byte, error) {
+			u, err := loader(name, loaderArgs)
+			if err != nil {
+				return nil, err
+			}
+			data, err := fetch(u)
+			return data, errors.Wrap(err, loaderName)
+		})
 		if err != nil {
 			return nil, err
 		}
-		data, err := fetch(u)
-		if err != nil {
-			return nil, errors.Wrap(err, loaderName)
-		}
 		return &lib.SourceData{Filename: name, Data: data}, nil
 	}
 
 	// If it's not a file, check is it a remote location. HTTPS is enforced, because it's 2017, HTTPS is easy,
 	// running arbitrary, trivially MitM'd code (even sandboxed) is very, very bad.
+	data, err := loadRemote(name, func() ([]byte, error) { return fetchRemote(name) })
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO: Parse the HTML, look for meta tags!!
+	// <meta name="k6-import" content="example.com/path/to/real/file.txt" />
+	// <meta name="k6-import" content="github.com/myusername/repo/file.txt" />
+
+	return &lib.SourceData{Filename: name, Data: data}, nil
+}
+
+func fetchRemote(name string) ([]byte, error) {
 	origURL := "https://" + name
 	parsedURL, err := url.Parse(origURL)
 
@@ -149,12 +165,7 @@ func Load(fs afero.Fs, pwd, name string) (*lib.SourceData, error) {
 		}
 		data = data2
 	}
-
-	// TODO: Parse the HTML, look for meta tags!!
-	// <meta name="k6-import" content="example.com/path/to/real/file.txt" />
-	// <meta name="k6-import" content="github.com/myusername/repo/file.txt" />
-
-	return &lib.SourceData{Filename: name, Data: data}, nil
+	return data, nil
 }
 
 func pickLoader(path string) (string, loaderFunc, []string) {
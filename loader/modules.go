@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package loader
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// The extensions tried, in order, for imports that don't have one.
+var moduleExtensions = []string{".js", ".ts"}
+
+// A ModuleFS is what ResolveModule looks for files in.
+type ModuleFS interface {
+	// Returns whether a file exists.
+	IsFile(filename string) bool
+
+	// Returns the contents of a file.
+	ReadFile(filename string) ([]byte, error)
+}
+
+// Resolves an import to the file it refers to. For imports from local files, this works like
+// Node.js does: relative and absolute paths are tried as they are, with an extension, and as a
+// directory with a package.json or an index file, and other names are looked up in node_modules
+// directories from pwd upwards. Anything else is returned as Resolve would, so that it can be
+// loaded from the web.
+func ResolveModule(fs ModuleFS, pwd, name string) string {
+	if name == "" {
+		return name
+	}
+	if !isLocal(pwd) {
+		return Resolve(pwd, name)
+	}
+
+	if name[0] == '.' || isLocal(name) {
+		filename := Resolve(pwd, name)
+		if resolved, ok := resolveModuleFile(fs, filename, true); ok {
+			return resolved
+		}
+		return filename
+	}
+
+	for dir := pwd; ; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) != "node_modules" {
+			filename := filepath.ToSlash(filepath.Join(dir, "node_modules", name))
+			if resolved, ok := resolveModuleFile(fs, filename, true); ok {
+				return resolved
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			return name
+		}
+	}
+}
+
+func isLocal(name string) bool {
+	return name[0] == '/' || filepath.VolumeName(name) != ""
+}
+
+// Tries a path as a file, with each extension, and as a directory. The package.json is only
+// read if pkg is true, so a "main" pointing back at its own directory can't loop.
+func resolveModuleFile(fs ModuleFS, filename string, pkg bool) (string, bool) {
+	if fs.IsFile(filename) {
+		return filename, true
+	}
+	for _, ext := range moduleExtensions {
+		if fs.IsFile(filename + ext) {
+			return filename + ext, true
+		}
+	}
+
+	if pkg {
+		if data, err := fs.ReadFile(filename + "/package.json"); err == nil {
+			var manifest struct {
+				Module string `json:"module"`
+				Main   string `json:"main"`
+			}
+			if err := json.Unmarshal(data, &manifest); err == nil {
+				for _, entry := range []string{manifest.Module, manifest.Main} {
+					if entry == "" {
+						continue
+					}
+					entry = filepath.ToSlash(filepath.Join(filename, entry))
+					if resolved, ok := resolveModuleFile(fs, entry, false); ok {
+						return resolved, true
+					}
+				}
+			}
+		}
+	}
+
+	for _, ext := range moduleExtensions {
+		if index := filename + "/index" + ext; fs.IsFile(index) {
+			return index, true
+		}
+	}
+	return "", false
+}
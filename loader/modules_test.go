@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package loader
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapModuleFS map[string]string
+
+func (fs mapModuleFS) IsFile(filename string) bool {
+	_, ok := fs[filename]
+	return ok
+}
+
+func (fs mapModuleFS) ReadFile(filename string) ([]byte, error) {
+	data, ok := fs[filename]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func TestResolveModule(t *testing.T) {
+	fs := mapModuleFS{
+		"/path/to/file.js":                       "",
+		"/path/to/types.ts":                      "",
+		"/path/to/dir/index.js":                  "",
+		"/path/to/tsdir/index.ts":                "",
+		"/path/to/pkg/package.json":              `{"main": "./lib/main.js"}`,
+		"/path/to/pkg/lib/main.js":               "",
+		"/path/to/esm/package.json":              `{"module": "esm", "main": "cjs"}`,
+		"/path/to/esm/esm/index.js":              "",
+		"/path/to/esm/cjs/index.js":              "",
+		"/path/to/broken/package.json":           `{"main": "missing.js"}`,
+		"/path/to/broken/index.js":               "",
+		"/path/node_modules/lodash/package.json": `{"main": "lodash.js"}`,
+		"/path/node_modules/lodash/lodash.js":    "",
+		"/path/node_modules/lodash/fp.js":        "",
+		"/path/to/node_modules/local/index.js":   "",
+	}
+	testdata := map[string]struct{ pwd, name, filename string }{
+		"Exact":           {"/path/to", "./file.js", "/path/to/file.js"},
+		"Absolute":        {"/", "/path/to/file", "/path/to/file.js"},
+		"Extension":       {"/path/to", "./file", "/path/to/file.js"},
+		"TypeScript":      {"/path/to", "./types", "/path/to/types.ts"},
+		"Parent":          {"/path/to/dir", "../file", "/path/to/file.js"},
+		"Index":           {"/path/to", "./dir", "/path/to/dir/index.js"},
+		"IndexTS":         {"/path/to", "./tsdir", "/path/to/tsdir/index.ts"},
+		"Package":         {"/path/to", "./pkg", "/path/to/pkg/lib/main.js"},
+		"PackageModule":   {"/path/to", "./esm", "/path/to/esm/esm/index.js"},
+		"PackageBroken":   {"/path/to", "./broken", "/path/to/broken/index.js"},
+		"Missing":         {"/path/to", "./missing", "/path/to/missing"},
+		"NodeModules":     {"/path/to/dir", "lodash", "/path/node_modules/lodash/lodash.js"},
+		"NodeModulesFile": {"/path/to/dir", "lodash/fp", "/path/node_modules/lodash/fp.js"},
+		"NodeModulesNear": {"/path/to", "local", "/path/to/node_modules/local/index.js"},
+		"NodeModulesFar":  {"/path", "local", "local"},
+		"Remote":          {"/path/to", "example.com/lib.js", "example.com/lib.js"},
+		"RemotePwd":       {"example.com/path", "./file", "example.com/path/file"},
+		"Blank":           {"/path/to", "", ""},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.filename, ResolveModule(fs, data.pwd, data.name))
+		})
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cloud
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+)
+
+const (
+	DefaultAggregationWaitPeriod     = 5 * time.Second
+	DefaultAggregationMinSamples     = 25
+	DefaultAggregationOutlierIQRCoef = 1.5
+)
+
+// An aggregationBucket holds the HTTP requests with the same tags made during a period.
+type aggregationBucket struct {
+	tags   *stats.SampleTags
+	trails []*SampleData
+}
+
+func (c *Collector) aggregating() bool {
+	return c.config.AggregationPeriod > 0
+}
+
+// addHTTPTrails puts HTTP requests into the buckets for their periods and tags.
+func (c *Collector) addHTTPTrails(trails []*SampleData) {
+	period := int64(c.config.AggregationPeriod)
+
+	c.bucketsMu.Lock()
+	defer c.bucketsMu.Unlock()
+	if c.buckets == nil {
+		c.buckets = make(map[int64]map[string]*aggregationBucket)
+	}
+	for _, trail := range trails {
+		id := time.Time(trail.Time).UnixNano() / period
+		buckets, ok := c.buckets[id]
+		if !ok {
+			buckets = make(map[string]*aggregationBucket)
+			c.buckets[id] = buckets
+		}
+		key := tagsKey(trail.Tags)
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &aggregationBucket{tags: trail.Tags}
+			buckets[key] = bucket
+		}
+		bucket.trails = append(bucket.trails, trail)
+	}
+}
+
+// aggregateHTTPTrails turns the buckets of periods that ended more than the wait period ago, or
+// all of them if forced, into samples.
+func (c *Collector) aggregateHTTPTrails(force bool) {
+	period := time.Duration(c.config.AggregationPeriod)
+	wait := time.Duration(c.config.AggregationWaitPeriod)
+	if wait == 0 {
+		wait = DefaultAggregationWaitPeriod
+	}
+	cutoff := time.Now().Add(-wait)
+
+	c.bucketsMu.Lock()
+	var ids []int64
+	for id := range c.buckets {
+		if force || !time.Unix(0, (id+1)*int64(period)).After(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var samples []*Sample
+	for _, id := range ids {
+		middle := time.Unix(0, id*int64(period)+int64(period)/2)
+		keys := make([]string, 0, len(c.buckets[id]))
+		for key := range c.buckets[id] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			samples = append(samples, c.aggregateBucket(c.buckets[id][key], middle)...)
+		}
+		delete(c.buckets, id)
+	}
+	c.bucketsMu.Unlock()
+
+	if len(samples) > 0 {
+		c.sampleMu.Lock()
+		c.sampleBuffer = append(c.sampleBuffer, samples...)
+		c.sampleMu.Unlock()
+	}
+}
+
+// aggregateBucket returns one aggregated sample for the requests in a bucket, plus the outliers
+// as they are, or all of them as they are if there are too few to aggregate.
+func (c *Collector) aggregateBucket(bucket *aggregationBucket, t time.Time) []*Sample {
+	minSamples := c.config.AggregationMinSamples
+	if minSamples == 0 {
+		minSamples = DefaultAggregationMinSamples
+	}
+	coef := c.config.AggregationOutlierIQRCoef
+	if coef == 0 {
+		coef = DefaultAggregationOutlierIQRCoef
+	}
+
+	raw := func(trail *SampleData) *Sample {
+		return &Sample{Type: DataTypeMap, Metric: "http_req_li_all", Data: trail}
+	}
+	if len(bucket.trails) < minSamples {
+		samples := make([]*Sample, len(bucket.trails))
+		for i, trail := range bucket.trails {
+			samples[i] = raw(trail)
+		}
+		return samples
+	}
+
+	durations := make([]float64, len(bucket.trails))
+	for i, trail := range bucket.trails {
+		durations[i] = trail.Values["http_req_duration"]
+	}
+	sort.Float64s(durations)
+	q1, q3 := quantile(durations, 0.25), quantile(durations, 0.75)
+	iqr := q3 - q1
+	lower, upper := q1-coef*iqr, q3+coef*iqr
+
+	var samples []*Sample
+	aggr := &SampleDataAggregatedHTTPReqs{
+		Time:   Timestamp(t),
+		Type:   "aggregated_trend",
+		Tags:   bucket.tags,
+		Values: make(map[string]AggregatedMetric),
+	}
+	for _, trail := range bucket.trails {
+		if d := trail.Values["http_req_duration"]; d < lower || d > upper {
+			samples = append(samples, raw(trail))
+			continue
+		}
+		aggr.Count++
+		for name, value := range trail.Values {
+			if name == "http_reqs" {
+				continue
+			}
+			m, ok := aggr.Values[name]
+			if !ok {
+				m = AggregatedMetric{Min: math.Inf(1), Max: math.Inf(-1)}
+			}
+			m.Min = math.Min(m.Min, value)
+			m.Max = math.Max(m.Max, value)
+			m.Avg += value // Summed up here, divided by the count below.
+			aggr.Values[name] = m
+		}
+	}
+	if aggr.Count == 0 {
+		return samples
+	}
+	for name, m := range aggr.Values {
+		m.Avg /= float64(aggr.Count)
+		aggr.Values[name] = m
+	}
+	return append([]*Sample{{Type: DataTypeAggregatedHTTPReqs, Metric: "http_req_li_all", Data: aggr}}, samples...)
+}
+
+// quantile returns the q-th quantile of sorted values, interpolating between them.
+func quantile(sorted []float64, q float64) float64 {
+	i := q * float64(len(sorted)-1)
+	lower := sorted[int(math.Floor(i))]
+	upper := sorted[int(math.Ceil(i))]
+	return lower + (upper-lower)*(i-math.Floor(i))
+}
+
+func tagsKey(tags *stats.SampleTags) string {
+	if tags == nil {
+		return ""
+	}
+	m := tags.CloneTags()
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + m[k] + "\x00")
+	}
+	return b.String()
+}
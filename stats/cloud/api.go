@@ -57,10 +57,41 @@ func (ct *Timestamp) UnmarshalJSON(p []byte) error {
 	return nil
 }
 
+// The types of samples the cloud knows; the data of an aggregated sample is a
+// SampleDataAggregatedHTTPReqs, the data of the others a SampleData.
+const (
+	DataTypeSingle             = "Point"
+	DataTypeMap                = "Points"
+	DataTypeAggregatedHTTPReqs = "AggregatedPoints"
+)
+
 type Sample struct {
-	Type   string     `json:"type"`
-	Metric string     `json:"metric"`
-	Data   SampleData `json:"data"`
+	Type   string      `json:"type"`
+	Metric string      `json:"metric"`
+	Data   interface{} `json:"data"`
+}
+
+// UnmarshalJSON decodes the data of a sample into the type that goes with its Type.
+func (s *Sample) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type   string          `json:"type"`
+		Metric string          `json:"metric"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	s.Type, s.Metric = raw.Type, raw.Metric
+	switch raw.Type {
+	case DataTypeAggregatedHTTPReqs:
+		d := &SampleDataAggregatedHTTPReqs{}
+		s.Data = d
+		return json.Unmarshal(raw.Data, d)
+	default:
+		d := &SampleData{}
+		s.Data = d
+		return json.Unmarshal(raw.Data, d)
+	}
 }
 
 type SampleData struct {
@@ -71,6 +102,22 @@ type SampleData struct {
 	Tags   *stats.SampleTags  `json:"tags,omitempty"`
 }
 
+// SampleDataAggregatedHTTPReqs sums up the HTTP requests with the same tags during a period: the
+// minimum, maximum and average of every http_req_* metric, and how many requests there were.
+type SampleDataAggregatedHTTPReqs struct {
+	Time   Timestamp                   `json:"time"`
+	Type   string                      `json:"type"`
+	Count  uint64                      `json:"count"`
+	Tags   *stats.SampleTags           `json:"tags,omitempty"`
+	Values map[string]AggregatedMetric `json:"values"`
+}
+
+type AggregatedMetric struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
 type ThresholdResult map[string]map[string]bool
 
 type TestRun struct {
@@ -192,7 +239,9 @@ func (c *Client) StartCloudTestRun(name string, projectID int, arc *lib.Archive)
 	return ctrr.ReferenceID, nil
 }
 
-func (c *Client) TestFinished(referenceID string, thresholds ThresholdResult, tained bool) error {
+// TestFinished reports the end of a test run with the results of its thresholds, whether any
+// failed, and the status the run ended with.
+func (c *Client) TestFinished(referenceID string, thresholds ThresholdResult, tained bool, runStatus lib.RunStatus) error {
 	url := fmt.Sprintf("%s/tests/%s", c.baseURL, referenceID)
 
 	status := 0
@@ -202,9 +251,11 @@ func (c *Client) TestFinished(referenceID string, thresholds ThresholdResult, ta
 
 	data := struct {
 		ResultStatus int             `json:"result_status"`
+		RunStatus    lib.RunStatus   `json:"run_status"`
 		Thresholds   ThresholdResult `json:"thresholds"`
 	}{
 		status,
+		runStatus,
 		thresholds,
 	}
 
@@ -29,6 +29,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/loadimpact/k6/lib"
 	"github.com/stretchr/testify/require"
 
 	"github.com/stretchr/testify/assert"
@@ -123,7 +124,9 @@ func TestPublishMetric(t *testing.T) {
 }
 
 func TestFinished(t *testing.T) {
+	var body map[string]interface{}
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
 		fmt.Fprintf(w, "")
 	}))
 	defer server.Close()
@@ -135,9 +138,14 @@ func TestFinished(t *testing.T) {
 			"max < 10": true,
 		},
 	}
-	err := client.TestFinished("1", thresholds, true)
+	err := client.TestFinished("1", thresholds, true, lib.RunStatusAbortedThreshold)
 
 	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"result_status": 1.0,
+		"run_status":    float64(lib.RunStatusAbortedThreshold),
+		"thresholds":    map[string]interface{}{"threshold": map[string]interface{}{"max < 10": true}},
+	}, body)
 }
 
 func TestAuthorizedError(t *testing.T) {
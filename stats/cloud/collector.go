@@ -22,26 +22,46 @@ package cloud
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/diskqueue"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 )
 
 const (
 	TestName           = "k6 test"
 	MetricPushInterval = 1 * time.Second
+
+	// Metrics that couldn't be sent are retried after MinRetryBackoff, doubling every time they
+	// fail again, up to MaxRetryBackoff.
+	MinRetryBackoff = 1 * time.Second
+	MaxRetryBackoff = 1 * time.Minute
+
+	// DefaultMaxBufferSize is how much disk space metrics that couldn't be sent can take up.
+	DefaultMaxBufferSize = 100 << 20
 )
 
-// Collector sends result data to the Load Impact cloud service.
+var _ lib.Collector = &Collector{}
+var _ lib.RunStatusUpdater = &Collector{}
+
+// Collector sends result data to the Load Impact cloud service; the test runs locally, so the
+// cloud is told how it ended too.
 type Collector struct {
 	config      Config
 	referenceID string
+	runStatus   lib.RunStatus
 
 	duration   int64
 	thresholds map[string][]*stats.Threshold
@@ -51,12 +71,30 @@ type Collector struct {
 
 	sampleBuffer []*Sample
 	sampleMu     sync.Mutex
+
+	// Metrics that couldn't be sent wait in the queue until retryAt; without a queue, or once
+	// it's full, they're dropped.
+	queue   *diskqueue.Queue
+	retryAt time.Time
+	backoff time.Duration
+	dropped int64
+
+	// HTTP requests waiting to be aggregated, by period and tags.
+	buckets   map[int64]map[string]*aggregationBucket
+	bucketsMu sync.Mutex
 }
 
 // New creates a new cloud collector
 func New(conf Config, src *lib.SourceData, opts lib.Options, version string) (*Collector, error) {
 	if val, ok := opts.External["loadimpact"]; ok {
-		if err := mapstructure.Decode(val, &conf); err != nil {
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook: decodeDuration,
+			Result:     &conf,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Decode(val); err != nil {
 			return nil, err
 		}
 	}
@@ -118,6 +156,18 @@ func (c *Collector) Init() error {
 	}
 	c.referenceID = response.ReferenceID
 
+	dir := c.config.BufferDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("k6-cloud-%d", os.Getpid()))
+	}
+	maxSize := c.config.MaxBufferSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxBufferSize
+	}
+	if c.queue, err = diskqueue.New(afero.NewOsFs(), dir, maxSize); err != nil {
+		log.WithError(err).Warn("Cloud: Metrics that can't be sent will be dropped")
+	}
+
 	log.WithFields(log.Fields{
 		"name":        c.config.Name,
 		"projectId":   c.config.ProjectID,
@@ -137,9 +187,17 @@ func (c *Collector) Run(ctx context.Context) {
 	for {
 		select {
 		case <-timer.C:
+			if c.aggregating() {
+				c.aggregateHTTPTrails(false)
+			}
 			c.pushMetrics()
 		case <-ctx.Done():
+			if c.aggregating() {
+				c.aggregateHTTPTrails(true)
+			}
 			c.pushMetrics()
+			c.pushQueued(true)
+			c.reportDropped()
 			c.testFinished()
 			return
 		}
@@ -156,48 +214,43 @@ func (c *Collector) Collect(samples []stats.Sample) {
 	}
 
 	var cloudSamples []*Sample
-	var httpJSON *Sample
-	var iterationJSON *Sample
+	var httpTrails []*SampleData
+	var httpJSON *SampleData
+	var iterationJSON *SampleData
 	for _, samp := range samples {
 
 		name := samp.Metric.Name
 		if name == "http_reqs" {
-			httpJSON = &Sample{
-				Type:   "Points",
-				Metric: "http_req_li_all",
-				Data: SampleData{
-					Type:   samp.Metric.Type,
-					Time:   Timestamp(samp.Time),
-					Tags:   samp.Tags,
-					Values: make(map[string]float64),
-				},
+			httpJSON = &SampleData{
+				Type:   samp.Metric.Type,
+				Time:   Timestamp(samp.Time),
+				Tags:   samp.Tags,
+				Values: make(map[string]float64),
+			}
+			httpJSON.Values[name] = samp.Value
+			if c.aggregating() {
+				httpTrails = append(httpTrails, httpJSON)
+			} else {
+				cloudSamples = append(cloudSamples, &Sample{Type: DataTypeMap, Metric: "http_req_li_all", Data: httpJSON})
 			}
-			httpJSON.Data.Values[name] = samp.Value
-			cloudSamples = append(cloudSamples, httpJSON)
 		} else if name == "data_sent" {
-			iterationJSON = &Sample{
-				Type:   "Points",
-				Metric: "iter_li_all",
-				Data: SampleData{
-					Type:   samp.Metric.Type,
-					Time:   Timestamp(samp.Time),
-					Tags:   samp.Tags,
-					Values: make(map[string]float64),
-				},
+			iterationJSON = &SampleData{
+				Type:   samp.Metric.Type,
+				Time:   Timestamp(samp.Time),
+				Tags:   samp.Tags,
+				Values: make(map[string]float64),
 			}
-			iterationJSON.Data.Values[name] = samp.Value
-			cloudSamples = append(cloudSamples, iterationJSON)
-		} else if name == "data_received" || name == "iteration_duration" {
-			//TODO: make sure that tags match
-			iterationJSON.Data.Values[name] = samp.Value
-		} else if strings.HasPrefix(name, "http_req_") {
-			//TODO: make sure that tags match
-			httpJSON.Data.Values[name] = samp.Value
+			iterationJSON.Values[name] = samp.Value
+			cloudSamples = append(cloudSamples, &Sample{Type: DataTypeMap, Metric: "iter_li_all", Data: iterationJSON})
+		} else if (name == "data_received" || name == "iteration_duration") && sameSample(iterationJSON, samp) {
+			iterationJSON.Values[name] = samp.Value
+		} else if strings.HasPrefix(name, "http_req_") && sameSample(httpJSON, samp) {
+			httpJSON.Values[name] = samp.Value
 		} else {
 			sampleJSON := &Sample{
-				Type:   "Point",
+				Type:   DataTypeSingle,
 				Metric: name,
-				Data: SampleData{
+				Data: &SampleData{
 					Type:  samp.Metric.Type,
 					Time:  Timestamp(samp.Time),
 					Value: samp.Value,
@@ -208,6 +261,9 @@ func (c *Collector) Collect(samples []stats.Sample) {
 		}
 	}
 
+	if len(httpTrails) > 0 {
+		c.addHTTPTrails(httpTrails)
+	}
 	if len(cloudSamples) > 0 {
 		c.sampleMu.Lock()
 		c.sampleBuffer = append(c.sampleBuffer, cloudSamples...)
@@ -215,25 +271,117 @@ func (c *Collector) Collect(samples []stats.Sample) {
 	}
 }
 
+// Whether a sample belongs with the request or iteration data, which it follows; otherwise, eg.
+// if the sample that starts them was filtered out, it's sent on its own.
+func sameSample(data *SampleData, samp stats.Sample) bool {
+	return data != nil && data.Tags.IsEqual(samp.Tags)
+}
+
+// SetRunStatus keeps the status the test ended with, for testFinished.
+func (c *Collector) SetRunStatus(status lib.RunStatus) {
+	c.runStatus = status
+}
+
 func (c *Collector) pushMetrics() {
 	c.sampleMu.Lock()
-	if len(c.sampleBuffer) == 0 {
-		c.sampleMu.Unlock()
-		return
-	}
 	buffer := c.sampleBuffer
 	c.sampleBuffer = nil
 	c.sampleMu.Unlock()
 
+	if len(buffer) > 0 {
+		if c.queue != nil && c.queue.Len() > 0 {
+			// Keep the metrics in order while older ones are still waiting to be sent.
+			c.enqueue(buffer)
+		} else if err := c.push(buffer); err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Warn("Failed to send metrics to cloud")
+			c.enqueue(buffer)
+			c.backOff()
+		}
+	}
+	c.pushQueued(false)
+}
+
+func (c *Collector) push(buffer []*Sample) error {
 	log.WithFields(log.Fields{
 		"samples": len(buffer),
 	}).Debug("Pushing metrics to cloud")
 
-	err := c.client.PushMetric(c.referenceID, c.config.NoCompress, buffer)
+	return c.client.PushMetric(c.referenceID, c.config.NoCompress, buffer)
+}
+
+// enqueue buffers metrics that couldn't be sent on disk, or drops them if that's not possible.
+func (c *Collector) enqueue(buffer []*Sample) {
+	if c.queue == nil {
+		c.dropped += int64(len(buffer))
+		return
+	}
+	data, err := json.Marshal(buffer)
+	if err == nil {
+		err = c.queue.Push(data, int64(len(buffer)))
+	}
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Warn("Failed to send metrics to cloud")
+		log.WithError(err).Warn("Cloud: Couldn't buffer metrics")
+		c.dropped += int64(len(buffer))
+	}
+}
+
+func (c *Collector) backOff() {
+	c.backoff *= 2
+	if c.backoff < MinRetryBackoff {
+		c.backoff = MinRetryBackoff
+	}
+	if c.backoff > MaxRetryBackoff {
+		c.backoff = MaxRetryBackoff
+	}
+	c.retryAt = time.Now().Add(c.backoff)
+}
+
+// pushQueued sends the buffered metrics, oldest first, until they're all sent or sending fails;
+// unless forced, it waits until the backoff from the last failure is over.
+func (c *Collector) pushQueued(force bool) {
+	if c.queue == nil || (!force && time.Now().Before(c.retryAt)) {
+		return
+	}
+	for c.queue.Len() > 0 {
+		data, err := c.queue.Peek()
+		var buffer []*Sample
+		if err == nil {
+			err = json.Unmarshal(data, &buffer)
+		}
+		if err != nil {
+			log.WithError(err).Warn("Cloud: Couldn't read buffered metrics")
+			c.dropped += int64(len(buffer))
+			_ = c.queue.Pop()
+			continue
+		}
+		if err := c.push(buffer); err != nil {
+			log.WithFields(log.Fields{
+				"error":   err,
+				"samples": c.queue.Samples(),
+			}).Warn("Failed to send buffered metrics to cloud")
+			c.backOff()
+			return
+		}
+		if err := c.queue.Pop(); err != nil {
+			log.WithError(err).Warn("Cloud: Couldn't remove sent metrics from the buffer")
+		}
+		c.backoff = 0
+	}
+}
+
+// reportDropped warns about the metrics that never made it to the cloud, and removes the buffer.
+func (c *Collector) reportDropped() {
+	dropped := c.dropped
+	if c.queue != nil {
+		dropped += c.queue.Dropped() + c.queue.Samples()
+		if err := c.queue.Close(); err != nil {
+			log.WithError(err).Warn("Cloud: Couldn't remove the metrics buffer")
+		}
+	}
+	if dropped > 0 {
+		log.WithField("samples", dropped).Warnf("Cloud: %d samples couldn't be sent to the cloud", dropped)
 	}
 }
 
@@ -255,11 +403,12 @@ func (c *Collector) testFinished() {
 	}
 
 	log.WithFields(log.Fields{
-		"ref":     c.referenceID,
-		"tainted": testTainted,
+		"ref":       c.referenceID,
+		"tainted":   testTainted,
+		"runStatus": c.runStatus,
 	}).Debug("Sending test finished")
 
-	err := c.client.TestFinished(c.referenceID, thresholdResults, testTainted)
+	err := c.client.TestFinished(c.referenceID, thresholdResults, testTainted, c.runStatus)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
@@ -267,6 +416,16 @@ func (c *Collector) testFinished() {
 	}
 }
 
+// decodeDuration lets durations in the options be strings, like "3s".
+func decodeDuration(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(types.Duration(0)) || from.Kind() != reflect.String {
+		return data, nil
+	}
+	var d types.Duration
+	err := d.UnmarshalText([]byte(data.(string)))
+	return d, err
+}
+
 func sumStages(stages []lib.Stage) int64 {
 	var total time.Duration
 	for _, stage := range stages {
@@ -0,0 +1,254 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cloud
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/diskqueue"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metricsServer struct {
+	lock    sync.Mutex
+	down    bool
+	samples []float64
+}
+
+func (s *metricsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var samples []*Sample
+	if err := json.NewDecoder(gz).Decode(&samples); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, sample := range samples {
+		s.samples = append(s.samples, sample.Data.(*SampleData).Value)
+	}
+}
+
+func (s *metricsServer) setDown(down bool) {
+	s.lock.Lock()
+	s.down = down
+	s.lock.Unlock()
+}
+
+func newTestCollector(t *testing.T, srv *httptest.Server, maxBufferSize int64) *Collector {
+	c, err := New(Config{Host: srv.URL}, &lib.SourceData{Filename: "/script.js"}, lib.Options{}, "1.0")
+	require.NoError(t, err)
+	c.client.retries = 1
+	c.referenceID = "123"
+	c.queue, err = diskqueue.New(afero.NewMemMapFs(), "/buffer", maxBufferSize)
+	require.NoError(t, err)
+	return c
+}
+
+func TestCollectorBuffering(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+	sample := func(v float64) []stats.Sample {
+		return []stats.Sample{{Metric: metric, Time: time.Now(), Value: v}}
+	}
+
+	t.Run("Retried", func(t *testing.T) {
+		server := &metricsServer{down: true}
+		srv := httptest.NewServer(server)
+		defer srv.Close()
+		c := newTestCollector(t, srv, 1<<20)
+
+		c.Collect(sample(1))
+		c.pushMetrics()
+		assert.Equal(t, 1, c.queue.Len())
+		assert.Equal(t, MinRetryBackoff, c.backoff)
+
+		// While the backend is backed off from, new metrics wait behind the old ones.
+		server.setDown(false)
+		c.Collect(sample(2))
+		c.pushMetrics()
+		assert.Equal(t, 2, c.queue.Len())
+		assert.Empty(t, server.samples)
+
+		c.retryAt = time.Time{}
+		c.Collect(sample(3))
+		c.pushMetrics()
+		assert.Equal(t, 0, c.queue.Len())
+		assert.Equal(t, time.Duration(0), c.backoff)
+		assert.Equal(t, []float64{1, 2, 3}, server.samples)
+
+		c.reportDropped()
+		assert.Equal(t, int64(0), c.dropped)
+	})
+
+	t.Run("Dropped", func(t *testing.T) {
+		server := &metricsServer{down: true}
+		srv := httptest.NewServer(server)
+		defer srv.Close()
+		// Room for two batches of one sample, but not three.
+		batch, err := json.Marshal([]*Sample{{
+			Type:   "Point",
+			Metric: "my_metric",
+			Data:   SampleData{Type: stats.Gauge, Time: Timestamp(time.Now()), Value: 1},
+		}})
+		require.NoError(t, err)
+		c := newTestCollector(t, srv, int64(2*len(batch)+1))
+
+		for i := 1; i <= 3; i++ {
+			c.Collect(sample(float64(i)))
+			c.pushMetrics()
+		}
+		assert.Equal(t, MinRetryBackoff, c.backoff)
+
+		c.pushQueued(true)
+		assert.Equal(t, 2*MinRetryBackoff, c.backoff)
+
+		// The first sample made room for newer ones, the others never made it.
+		assert.Equal(t, int64(1), c.queue.Dropped())
+		assert.Equal(t, int64(2), c.queue.Samples())
+		c.reportDropped()
+		assert.Equal(t, 0, c.queue.Len())
+	})
+}
+
+func TestCollectorAggregation(t *testing.T) {
+	c, err := New(Config{}, &lib.SourceData{Filename: "/script.js"}, lib.Options{
+		External: map[string]interface{}{
+			"loadimpact": map[string]interface{}{"aggregationPeriod": "3s", "aggregationMinSamples": 4},
+		},
+	}, "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, types.Duration(3*time.Second), c.config.AggregationPeriod)
+	c.referenceID = "123"
+
+	now := time.Now()
+	tags := stats.NewSampleTags(map[string]string{"url": "http://example.com/"})
+	request := func(duration float64, tags *stats.SampleTags) []stats.Sample {
+		return []stats.Sample{
+			{Metric: metrics.HTTPReqs, Time: now, Tags: tags, Value: 1},
+			{Metric: metrics.HTTPReqDuration, Time: now, Tags: tags, Value: duration},
+		}
+	}
+	for _, duration := range []float64{10, 11, 12, 13, 14, 100} {
+		c.Collect(request(duration, tags))
+	}
+	otherTags := stats.NewSampleTags(map[string]string{"url": "http://example.com/other"})
+	c.Collect(request(20, otherTags))
+
+	// Nothing's aggregated before the period and the wait after it are over.
+	c.aggregateHTTPTrails(false)
+	assert.Empty(t, c.sampleBuffer)
+
+	c.aggregateHTTPTrails(true)
+	require.Len(t, c.sampleBuffer, 3)
+	var aggr *SampleDataAggregatedHTTPReqs
+	var raw []float64
+	for _, sample := range c.sampleBuffer {
+		assert.Equal(t, "http_req_li_all", sample.Metric)
+		switch data := sample.Data.(type) {
+		case *SampleDataAggregatedHTTPReqs:
+			assert.Equal(t, DataTypeAggregatedHTTPReqs, sample.Type)
+			aggr = data
+		case *SampleData:
+			assert.Equal(t, DataTypeMap, sample.Type)
+			raw = append(raw, data.Values["http_req_duration"])
+		}
+	}
+	require.NotNil(t, aggr)
+	assert.Equal(t, uint64(5), aggr.Count)
+	assert.Equal(t, tags, aggr.Tags)
+	assert.Equal(t, map[string]AggregatedMetric{"http_req_duration": {Min: 10, Max: 14, Avg: 12}}, aggr.Values)
+	assert.ElementsMatch(t, []float64{100, 20}, raw)
+	assert.Empty(t, c.buckets)
+
+	// Aggregated samples survive being buffered on disk.
+	data, err := json.Marshal(c.sampleBuffer)
+	require.NoError(t, err)
+	var decoded []*Sample
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, aggr.Values, decoded[0].Data.(*SampleDataAggregatedHTTPReqs).Values)
+}
+
+func TestCollectorUnmatchedSamples(t *testing.T) {
+	c, err := New(Config{}, &lib.SourceData{Filename: "/script.js"}, lib.Options{}, "1.0")
+	require.NoError(t, err)
+	c.referenceID = "123"
+
+	now := time.Now()
+	tags := stats.NewSampleTags(map[string]string{"url": "http://example.com/"})
+	otherTags := stats.NewSampleTags(map[string]string{"url": "http://example.com/other"})
+	c.Collect([]stats.Sample{
+		// Without the http_reqs and data_sent samples they'd follow, eg. if those were filtered out.
+		{Metric: metrics.HTTPReqDuration, Time: now, Tags: tags, Value: 10},
+		{Metric: metrics.IterationDuration, Time: now, Tags: tags, Value: 20},
+		// With those of another request.
+		{Metric: metrics.HTTPReqs, Time: now, Tags: tags, Value: 1},
+		{Metric: metrics.HTTPReqDuration, Time: now, Tags: otherTags, Value: 30},
+	})
+
+	require.Len(t, c.sampleBuffer, 4)
+	assert.Equal(t, "http_req_duration", c.sampleBuffer[0].Metric)
+	assert.Equal(t, DataTypeSingle, c.sampleBuffer[0].Type)
+	assert.Equal(t, "iteration_duration", c.sampleBuffer[1].Metric)
+	assert.Equal(t, "http_req_li_all", c.sampleBuffer[2].Metric)
+	assert.Equal(t, map[string]float64{"http_reqs": 1}, c.sampleBuffer[2].Data.(*SampleData).Values)
+	assert.Equal(t, "http_req_duration", c.sampleBuffer[3].Metric)
+	assert.Equal(t, 30.0, c.sampleBuffer[3].Data.(*SampleData).Value)
+}
+
+func TestCollectorRunStatus(t *testing.T) {
+	var body struct {
+		RunStatus lib.RunStatus `json:"run_status"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer srv.Close()
+
+	c, err := New(Config{Host: srv.URL}, &lib.SourceData{Filename: "/script.js"}, lib.Options{}, "1.0")
+	require.NoError(t, err)
+	c.client.retries = 1
+	c.referenceID = "123"
+
+	c.SetRunStatus(lib.RunStatusRunning)
+	c.SetRunStatus(lib.RunStatusAbortedSystem)
+	c.testFinished()
+	assert.Equal(t, lib.RunStatusAbortedSystem, body.RunStatus)
+}
@@ -22,6 +22,8 @@ package cloud
 
 import (
 	"encoding/json"
+
+	"github.com/loadimpact/k6/lib/types"
 )
 
 type ConfigFields struct {
@@ -31,6 +33,21 @@ type ConfigFields struct {
 	NoCompress      bool   `json:"no_compress" mapstructure:"no_compress" envconfig:"CLOUD_NO_COMPRESS"`
 	ProjectID       int    `json:"project_id" mapstructure:"projectID" envconfig:"CLOUD_PROJECT_ID"`
 	DeprecatedToken string `envconfig:"K6CLOUD_TOKEN"`
+
+	// Metrics that couldn't be sent are buffered in BufferDir, up to MaxBufferSize bytes, and
+	// sent again later.
+	BufferDir     string `json:"buffer_dir" mapstructure:"bufferDir" envconfig:"CLOUD_BUFFER_DIR"`
+	MaxBufferSize int64  `json:"max_buffer_size" mapstructure:"maxBufferSize" envconfig:"CLOUD_MAX_BUFFER_SIZE"`
+
+	// If AggregationPeriod is set, HTTP requests with the same tags are sent as one aggregated
+	// sample per period, once AggregationWaitPeriod has passed after its end. Periods with fewer
+	// than AggregationMinSamples requests, and requests that took more than
+	// AggregationOutlierIQRCoef interquartile ranges more or less than the quartiles, are sent
+	// as they are.
+	AggregationPeriod         types.Duration `json:"aggregation_period" mapstructure:"aggregationPeriod" envconfig:"CLOUD_AGGREGATION_PERIOD"`
+	AggregationWaitPeriod     types.Duration `json:"aggregation_wait_period" mapstructure:"aggregationWaitPeriod" envconfig:"CLOUD_AGGREGATION_WAIT_PERIOD"`
+	AggregationMinSamples     int            `json:"aggregation_min_samples" mapstructure:"aggregationMinSamples" envconfig:"CLOUD_AGGREGATION_MIN_SAMPLES"`
+	AggregationOutlierIQRCoef float64        `json:"aggregation_outlier_iqr_coef" mapstructure:"aggregationOutlierIqrCoef" envconfig:"CLOUD_AGGREGATION_OUTLIER_IQR_COEF"`
 }
 
 type Config ConfigFields
@@ -48,6 +65,24 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.ProjectID != 0 {
 		c.ProjectID = cfg.ProjectID
 	}
+	if cfg.BufferDir != "" {
+		c.BufferDir = cfg.BufferDir
+	}
+	if cfg.MaxBufferSize != 0 {
+		c.MaxBufferSize = cfg.MaxBufferSize
+	}
+	if cfg.AggregationPeriod != 0 {
+		c.AggregationPeriod = cfg.AggregationPeriod
+	}
+	if cfg.AggregationWaitPeriod != 0 {
+		c.AggregationWaitPeriod = cfg.AggregationWaitPeriod
+	}
+	if cfg.AggregationMinSamples != 0 {
+		c.AggregationMinSamples = cfg.AggregationMinSamples
+	}
+	if cfg.AggregationOutlierIQRCoef != 0 {
+		c.AggregationOutlierIQRCoef = cfg.AggregationOutlierIQRCoef
+	}
 	return c
 }
 
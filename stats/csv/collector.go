@@ -0,0 +1,173 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package csv
+
+import (
+	"compress/gzip"
+	"context"
+	gocsv "encoding/csv"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	saveInterval = 1 * time.Second
+)
+
+var _ lib.Collector = &Collector{}
+
+// Collector writes a row for every sample: its metric, time and value, a column for each of the
+// configured tags, and the remaining tags in a query string. Files whose names end in .gz are
+// compressed with gzip.
+type Collector struct {
+	Config Config
+
+	closers    []io.Closer
+	csv        *gocsv.Writer
+	tagColumns map[string]bool
+
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+}
+
+func New(fs afero.Fs, conf Config) (*Collector, error) {
+	if conf.TimeFormat != timeFormatUnix && conf.TimeFormat != timeFormatRFC3339 {
+		return nil, errors.Errorf("invalid CSV time format '%s', expected unix or rfc3339", conf.TimeFormat)
+	}
+
+	c := &Collector{Config: conf, tagColumns: make(map[string]bool)}
+	var w io.Writer = os.Stdout
+	if conf.FileName != "" && conf.FileName != "-" {
+		f, err := fs.Create(conf.FileName)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+		c.closers = append(c.closers, f)
+	}
+	if strings.HasSuffix(conf.FileName, ".gz") {
+		gz := gzip.NewWriter(w)
+		w = gz
+		c.closers = append([]io.Closer{gz}, c.closers...)
+	}
+	c.csv = gocsv.NewWriter(w)
+	for _, tag := range conf.Tags {
+		c.tagColumns[tag] = true
+	}
+	return c, nil
+}
+
+// Init writes the header.
+func (c *Collector) Init() error {
+	header := append([]string{"metric_name", "timestamp", "metric_value"}, c.Config.Tags...)
+	header = append(header, "extra_tags")
+	if err := c.csv.Write(header); err != nil {
+		return err
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.WithField("filename", c.Config.FileName).Debug("CSV: Writing CSV metrics")
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit()
+		case <-ctx.Done():
+			c.commit()
+			for _, closer := range c.closers {
+				if err := closer.Close(); err != nil {
+					log.WithError(err).WithField("filename", c.Config.FileName).Error("CSV: Error closing the file")
+				}
+			}
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+func (c *Collector) Link() string {
+	return ""
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+func (c *Collector) commit() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	for _, sample := range samples {
+		if err := c.csv.Write(c.row(sample)); err != nil {
+			log.WithError(err).WithField("filename", c.Config.FileName).Error("CSV: Error writing to file")
+			return
+		}
+	}
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		log.WithError(err).WithField("filename", c.Config.FileName).Error("CSV: Error writing to file")
+	}
+}
+
+func (c *Collector) row(sample stats.Sample) []string {
+	timestamp := strconv.FormatInt(sample.Time.Unix(), 10)
+	if c.Config.TimeFormat == timeFormatRFC3339 {
+		timestamp = sample.Time.Format(time.RFC3339Nano)
+	}
+	row := []string{sample.Metric.Name, timestamp, strconv.FormatFloat(sample.Value, 'f', -1, 64)}
+
+	tags := sample.Tags.CloneTags()
+	for _, tag := range c.Config.Tags {
+		row = append(row, tags[tag])
+	}
+	var extra []string
+	for k, v := range tags {
+		if !c.tagColumns[k] {
+			extra = append(extra, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	sort.Strings(extra)
+	return append(row, strings.Join(extra, "&"))
+}
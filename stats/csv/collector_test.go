@@ -0,0 +1,85 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package csv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	now := time.Unix(1500000000, 500000000).UTC()
+	samples := []stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: stats.IntoSampleTags(&map[string]string{
+			"status": "200", "url": "http://example.com/?a=1,2", "vu": "1", "my tag": "a&b",
+		}), Time: now, Value: 1},
+		{Metric: metrics.VUs, Time: now, Value: 5.5},
+	}
+
+	run := func(t *testing.T, fs afero.Fs, config Config) {
+		c, err := New(fs, config)
+		require.NoError(t, err)
+		require.NoError(t, c.Init())
+		c.Collect(samples)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		c.Run(ctx)
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		run(t, fs, NewConfig().Apply(Config{FileName: "/results.csv", Tags: []string{"status", "url"}}))
+		data, err := afero.ReadFile(fs, "/results.csv")
+		require.NoError(t, err)
+		assert.Equal(t, "metric_name,timestamp,metric_value,status,url,extra_tags\n"+
+			"http_reqs,1500000000,1,200,\"http://example.com/?a=1,2\",my+tag=a%26b&vu=1\n"+
+			"vus,1500000000,5.5,,,\n", string(data))
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		run(t, fs, NewConfig().Apply(Config{FileName: "/results.csv.gz", Tags: []string{"vu"}, TimeFormat: "rfc3339"}))
+		data, err := afero.ReadFile(fs, "/results.csv.gz")
+		require.NoError(t, err)
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		data, err = ioutil.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "metric_name,timestamp,metric_value,vu,extra_tags\n"+
+			"http_reqs,2017-07-14T02:40:00.5Z,1,1,my+tag=a%26b&status=200&url=http%3A%2F%2Fexample.com%2F%3Fa%3D1%2C2\n"+
+			"vus,2017-07-14T02:40:00.5Z,5.5,,\n", string(data))
+	})
+
+	t.Run("InvalidTimeFormat", func(t *testing.T) {
+		_, err := New(afero.NewMemMapFs(), NewConfig().Apply(Config{TimeFormat: "iso"}))
+		assert.EqualError(t, err, "invalid CSV time format 'iso', expected unix or rfc3339")
+	})
+}
@@ -0,0 +1,111 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package csv
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+const (
+	timeFormatUnix    = "unix"
+	timeFormatRFC3339 = "rfc3339"
+)
+
+type ConfigFields struct {
+	FileName   string   `json:"fileName" envconfig:"CSV_FILENAME"`
+	Tags       []string `json:"tags,omitempty" envconfig:"CSV_TAGS"`
+	TimeFormat string   `json:"timeFormat,omitempty" envconfig:"CSV_TIME_FORMAT"`
+}
+
+type Config ConfigFields
+
+// NewConfig returns the defaults, which have a column for each of the default system tags.
+func NewConfig() *Config {
+	tags := make([]string, len(lib.DefaultSystemTagList))
+	copy(tags, lib.DefaultSystemTagList)
+	return &Config{FileName: "file.csv", Tags: tags, TimeFormat: timeFormatUnix}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.FileName != "" {
+		c.FileName = cfg.FileName
+	}
+	if len(cfg.Tags) > 0 {
+		c.Tags = cfg.Tags
+	}
+	if cfg.TimeFormat != "" {
+		c.TimeFormat = cfg.TimeFormat
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is the file name, optionally followed by options;
+// eg. results.csv.gz?tags=status,url&time_format=rfc3339
+func (c *Config) UnmarshalText(text []byte) error {
+	s := string(text)
+	query := ""
+	if i := strings.Index(s, "?"); i != -1 {
+		s, query = s[:i], s[i+1:]
+	}
+	if s != "" {
+		c.FileName = s
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	for k, vs := range values {
+		switch k {
+		case "tags":
+			c.Tags = nil
+			for _, v := range vs {
+				for _, tag := range strings.Split(v, ",") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						c.Tags = append(c.Tags, tag)
+					}
+				}
+			}
+		case "time_format":
+			c.TimeFormat = vs[0]
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return nil
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
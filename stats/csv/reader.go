@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package csv
+
+import (
+	gocsv "encoding/csv"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ReadSamples reads back the samples of a file the collector wrote, in either time format, calling
+// fn with each of them in order. CSV files don't have the types of metrics, so they're looked up
+// with metric; the ones it doesn't know are read as trends of plain values.
+func ReadSamples(r io.Reader, metric func(name string) *stats.Metric, fn func(stats.Sample) error) error {
+	rd := gocsv.NewReader(r)
+	rd.FieldsPerRecord = -1
+	header, err := rd.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(header) < 4 || header[0] != "metric_name" || header[1] != "timestamp" ||
+		header[2] != "metric_value" || header[len(header)-1] != "extra_tags" {
+		return errors.New("the header isn't that of a k6 CSV file")
+	}
+	tagColumns := header[3 : len(header)-1]
+
+	custom := make(map[string]*stats.Metric)
+	for line := 2; ; line++ {
+		row, err := rd.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(row) != len(header) {
+			return errors.Errorf("line %d has %d columns, not %d", line, len(row), len(header))
+		}
+
+		m := metric(row[0])
+		if m == nil {
+			if m = custom[row[0]]; m == nil {
+				m = stats.New(row[0], stats.Trend)
+				custom[row[0]] = m
+			}
+		}
+		t, err := parseTimestamp(row[1])
+		if err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+		value, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+
+		tags := make(map[string]string, len(tagColumns))
+		for i, tag := range tagColumns {
+			if v := row[3+i]; v != "" {
+				tags[tag] = v
+			}
+		}
+		extra, err := url.ParseQuery(row[len(row)-1])
+		if err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+		for k, v := range extra {
+			tags[k] = v[0]
+		}
+
+		if err := fn(stats.Sample{Metric: m, Time: t, Tags: stats.IntoSampleTags(&tags), Value: value}); err != nil {
+			return err
+		}
+	}
+}
+
+// parseTimestamp parses the time of a row, written either as a Unix timestamp or in RFC3339.
+func parseTimestamp(s string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,98 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package csv
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSamples(t *testing.T) {
+	now := time.Unix(1500000000, 500000000).UTC()
+	samples := []stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: stats.IntoSampleTags(&map[string]string{
+			"status": "200", "url": "http://example.com/?a=1,2", "my tag": "a&b",
+		}), Time: now, Value: 1},
+		{Metric: stats.New("my_trend", stats.Trend), Time: now.Add(time.Second), Value: 5.5},
+	}
+
+	for _, format := range []string{timeFormatUnix, timeFormatRFC3339} {
+		t.Run(format, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			c, err := New(fs, NewConfig().Apply(Config{FileName: "/results.csv", Tags: []string{"status"}, TimeFormat: format}))
+			require.NoError(t, err)
+			require.NoError(t, c.Init())
+			c.Collect(samples)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			c.Run(ctx)
+
+			f, err := fs.Open("/results.csv")
+			require.NoError(t, err)
+			defer func() { _ = f.Close() }()
+			var read []stats.Sample
+			require.NoError(t, ReadSamples(f, func(name string) *stats.Metric {
+				if name == metrics.HTTPReqs.Name {
+					return metrics.HTTPReqs
+				}
+				return nil
+			}, func(s stats.Sample) error {
+				read = append(read, s)
+				return nil
+			}))
+
+			require.Len(t, read, 2)
+			assert.True(t, read[0].Metric == metrics.HTTPReqs)
+			assert.Equal(t, "my_trend", read[1].Metric.Name)
+			assert.Equal(t, stats.Trend, read[1].Metric.Type)
+			for i, s := range read {
+				if format == timeFormatUnix {
+					assert.True(t, samples[i].Time.Truncate(time.Second).Equal(s.Time))
+				} else {
+					assert.True(t, samples[i].Time.Equal(s.Time))
+				}
+				assert.Equal(t, samples[i].Value, s.Value)
+				assert.Equal(t, samples[i].Tags.CloneTags(), s.Tags.CloneTags())
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			"a,b,c\n": "the header isn't that of a k6 CSV file",
+			"metric_name,timestamp,metric_value,extra_tags\nm,1500000000,1\n":    "line 2 has 3 columns, not 4",
+			"metric_name,timestamp,metric_value,extra_tags\nm,yesterday,1,\n":    `line 2: parsing time "yesterday" as "2006-01-02T15:04:05.999999999Z07:00": cannot parse "yesterday" as "2006"`,
+			"metric_name,timestamp,metric_value,extra_tags\nm,1500000000,one,\n": `line 2: strconv.ParseFloat: parsing "one": invalid syntax`,
+		}
+		for data, msg := range testdata {
+			err := ReadSamples(strings.NewReader(data), metrics.Get, func(stats.Sample) error { return nil })
+			assert.EqualError(t, err, msg)
+		}
+	})
+}
@@ -0,0 +1,287 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dashboard
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	// maxPoints is how many points the dashboard keeps; an hour's worth with the default period.
+	maxPoints = 3600
+
+	// shutdownTimeout is how long the open pages have to get the end of the test.
+	shutdownTimeout = 1 * time.Second
+)
+
+var _ lib.Collector = &Collector{}
+
+// Collector serves a web page with charts of the test while it runs: requests per second,
+// response time percentiles, failed requests, checks and VUs, overall and by scenario. Samples
+// are aggregated over every period into a Point, which is pushed to the open pages.
+type Collector struct {
+	Config Config
+
+	listener net.Listener
+	server   *http.Server
+
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+
+	// Every point so far, the clients waiting for new ones, and whether the test has ended.
+	points  []Point
+	clients map[chan Point]struct{}
+	ended   bool
+	lock    sync.Mutex
+}
+
+// A Point is what happened during one period of the test.
+type Point struct {
+	Time time.Time `json:"time"`
+	VUs  float64   `json:"vus"`
+
+	// Requests and iterations per second.
+	RPS        float64 `json:"rps"`
+	Iterations float64 `json:"iterations"`
+
+	// Percentiles of http_req_duration, in ms, null if there were no requests.
+	Duration map[string]float64 `json:"duration"`
+
+	// The fraction of requests that failed, and of checks that passed; null if there were none.
+	Failed null.Float `json:"failed"`
+	Checks null.Float `json:"checks"`
+
+	// The same, for every scenario that had samples.
+	Scenarios map[string]*ScenarioPoint `json:"scenarios,omitempty"`
+}
+
+// A ScenarioPoint is what happened in one scenario during one period of the test.
+type ScenarioPoint struct {
+	RPS        float64    `json:"rps"`
+	Iterations float64    `json:"iterations"`
+	P95        null.Float `json:"p95"`
+	Failed     null.Float `json:"failed"`
+}
+
+// durationPercentiles are the percentiles of http_req_duration in every point.
+var durationPercentiles = map[string]float64{"p(50)": 0.5, "p(90)": 0.9, "p(95)": 0.95, "p(99)": 0.99}
+
+func New(conf Config) (*Collector, error) {
+	if conf.Addr == "" {
+		return nil, errors.New("the dashboard needs an address to be served on")
+	}
+	if conf.Period <= 0 {
+		return nil, errors.New("the dashboard needs a positive period")
+	}
+	return &Collector{Config: conf, clients: make(map[chan Point]struct{})}, nil
+}
+
+// Init starts serving the dashboard, so that a taken address is an error before the test starts.
+func (c *Collector) Init() error {
+	listener, err := net.Listen("tcp", c.Config.Addr)
+	if err != nil {
+		return errors.Wrap(err, "couldn't serve the dashboard")
+	}
+	c.listener = listener
+	c.server = &http.Server{Handler: c.handler()}
+	go func() {
+		if err := c.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("Dashboard: Couldn't serve")
+		}
+	}()
+	return nil
+}
+
+// Run aggregates the samples of every period; once the test is over, the open pages are told so,
+// and keep their charts, and the dashboard stops being served.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(c.Config.Period))
+	defer ticker.Stop()
+	for {
+		select {
+		case t := <-ticker.C:
+			c.commit(t)
+		case <-ctx.Done():
+			c.commit(time.Now())
+			c.end()
+			if c.server != nil {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+				_ = c.server.Shutdown(shutdownCtx)
+				cancel()
+			}
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+func (c *Collector) Link() string {
+	if c.listener == nil {
+		return "http://" + c.Config.Addr
+	}
+	return "http://" + c.listener.Addr().String()
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+// commit aggregates the buffered samples into a point, and pushes it to the open pages.
+func (c *Collector) commit(t time.Time) {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var last *Point
+	if len(c.points) > 0 {
+		last = &c.points[len(c.points)-1]
+	}
+	p := newPoint(t, time.Duration(c.Config.Period), samples, last)
+	if len(c.points) >= maxPoints {
+		c.points = append(c.points[:0], c.points[1:]...)
+	}
+	c.points = append(c.points, p)
+
+	for client := range c.clients {
+		select {
+		case client <- p:
+		default:
+			// A client that can't keep up misses points, rather than holding up the test.
+		}
+	}
+}
+
+// end tells the open pages that the test is over.
+func (c *Collector) end() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ended = true
+	for client := range c.clients {
+		close(client)
+		delete(c.clients, client)
+	}
+}
+
+// pointSinks are what a point, or a scenario's, is aggregated from.
+type pointSinks struct {
+	reqs, iterations stats.CounterSink
+	duration         stats.TrendSink
+	failed           stats.RateSink
+}
+
+func (s *pointSinks) add(sample stats.Sample) {
+	switch sample.Metric.Name {
+	case metrics.HTTPReqs.Name:
+		s.reqs.Add(sample)
+	case metrics.Iterations.Name:
+		s.iterations.Add(sample)
+	case metrics.HTTPReqDuration.Name:
+		s.duration.Add(sample)
+	case metrics.HTTPReqFailed.Name:
+		s.failed.Add(sample)
+	}
+}
+
+func rate(s stats.RateSink) null.Float {
+	if s.Total == 0 {
+		return null.Float{}
+	}
+	return null.FloatFrom(float64(s.Trues) / float64(s.Total))
+}
+
+// newPoint aggregates the samples of a period; the VUs are the last point's, if none of the
+// samples says how many there are.
+func newPoint(t time.Time, period time.Duration, samples []stats.Sample, last *Point) Point {
+	p := Point{Time: t}
+	if last != nil {
+		p.VUs = last.VUs
+	}
+
+	var all pointSinks
+	var checks stats.RateSink
+	scenarios := make(map[string]*pointSinks)
+	for _, sample := range samples {
+		switch sample.Metric.Name {
+		case metrics.VUs.Name:
+			p.VUs = sample.Value
+		case metrics.Checks.Name:
+			checks.Add(sample)
+		}
+		all.add(sample)
+		if name, ok := sample.Tags.Get("scenario"); ok && name != "" {
+			sc := scenarios[name]
+			if sc == nil {
+				sc = &pointSinks{}
+				scenarios[name] = sc
+			}
+			sc.add(sample)
+		}
+	}
+
+	secs := period.Seconds()
+	p.RPS = all.reqs.Value / secs
+	p.Iterations = all.iterations.Value / secs
+	if all.duration.Count > 0 {
+		p.Duration = make(map[string]float64, len(durationPercentiles))
+		for name, pct := range durationPercentiles {
+			p.Duration[name] = all.duration.P(pct)
+		}
+	}
+	p.Failed = rate(all.failed)
+	p.Checks = rate(checks)
+
+	if len(scenarios) > 0 {
+		p.Scenarios = make(map[string]*ScenarioPoint, len(scenarios))
+		for name, sc := range scenarios {
+			scp := &ScenarioPoint{
+				RPS:        sc.reqs.Value / secs,
+				Iterations: sc.iterations.Value / secs,
+				Failed:     rate(sc.failed),
+			}
+			if sc.duration.Count > 0 {
+				scp.P95 = null.FloatFrom(sc.duration.P(0.95))
+			}
+			p.Scenarios[name] = scp
+		}
+	}
+	return p
+}
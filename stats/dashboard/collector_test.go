@@ -0,0 +1,133 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestNewPoint(t *testing.T) {
+	now := time.Now()
+	tags := func(scenario string) *stats.SampleTags {
+		return stats.IntoSampleTags(&map[string]string{"scenario": scenario})
+	}
+	samples := []stats.Sample{
+		{Metric: metrics.VUs, Time: now, Value: 7},
+		{Metric: metrics.HTTPReqs, Tags: tags("api"), Time: now, Value: 1},
+		{Metric: metrics.HTTPReqs, Tags: tags("api"), Time: now, Value: 1},
+		{Metric: metrics.HTTPReqs, Tags: tags("web"), Time: now, Value: 1},
+		{Metric: metrics.HTTPReqs, Tags: tags("web"), Time: now, Value: 1},
+		{Metric: metrics.HTTPReqDuration, Tags: tags("api"), Time: now, Value: 100},
+		{Metric: metrics.HTTPReqDuration, Tags: tags("web"), Time: now, Value: 300},
+		{Metric: metrics.HTTPReqFailed, Tags: tags("api"), Time: now, Value: 0},
+		{Metric: metrics.HTTPReqFailed, Tags: tags("web"), Time: now, Value: 1},
+		{Metric: metrics.Checks, Time: now, Value: 1},
+		{Metric: metrics.Iterations, Tags: tags("web"), Time: now, Value: 1},
+	}
+
+	p := newPoint(now, 2*time.Second, samples, nil)
+	assert.Equal(t, now, p.Time)
+	assert.Equal(t, 7.0, p.VUs)
+	assert.Equal(t, 2.0, p.RPS)
+	assert.Equal(t, 0.5, p.Iterations)
+	assert.Equal(t, 200.0, p.Duration["p(50)"])
+	assert.Equal(t, 290.0, p.Duration["p(95)"])
+	assert.Equal(t, null.FloatFrom(0.5), p.Failed)
+	assert.Equal(t, null.FloatFrom(1), p.Checks)
+	assert.Equal(t, map[string]*ScenarioPoint{
+		"api": {RPS: 1, P95: null.FloatFrom(100), Failed: null.FloatFrom(0)},
+		"web": {RPS: 1, Iterations: 0.5, P95: null.FloatFrom(300), Failed: null.FloatFrom(1)},
+	}, p.Scenarios)
+
+	t.Run("empty", func(t *testing.T) {
+		p := newPoint(now, time.Second, nil, &p)
+		assert.Equal(t, Point{Time: now, VUs: 7}, p)
+	})
+}
+
+func TestCollector(t *testing.T) {
+	c, err := New(NewConfig().Apply(Config{Addr: "127.0.0.1:0"}))
+	require.NoError(t, err)
+	require.NoError(t, c.Init())
+	assert.True(t, strings.HasPrefix(c.Link(), "http://127.0.0.1:"))
+
+	get := func(path string) string {
+		res, err := http.Get(c.Link() + path)
+		require.NoError(t, err)
+		defer func() { _ = res.Body.Close() }()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	assert.Contains(t, get("/"), "<title>k6 dashboard</title>")
+	assert.Equal(t, "null", get("/data"))
+
+	c.Collect([]stats.Sample{{Metric: metrics.HTTPReqs, Time: time.Now(), Value: 1}})
+	c.commit(time.Now())
+
+	var points []Point
+	require.NoError(t, json.Unmarshal([]byte(get("/data")), &points))
+	if assert.Len(t, points, 1) {
+		assert.Equal(t, 1.0, points[0].RPS)
+	}
+
+	res, err := http.Get(c.Link() + "/events")
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	events := bufio.NewScanner(res.Body)
+	next := func() string {
+		for events.Scan() {
+			if strings.HasPrefix(events.Text(), "event: ") {
+				return strings.TrimPrefix(events.Text(), "event: ")
+			}
+		}
+		return ""
+	}
+	assert.Equal(t, "point", next())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	c.Collect([]stats.Sample{{Metric: metrics.HTTPReqs, Time: time.Now(), Value: 1}})
+	cancel()
+	<-done
+
+	assert.Equal(t, "point", next())
+	assert.Equal(t, "end", next())
+	assert.Equal(t, "", next())
+}
@@ -0,0 +1,100 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dashboard
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+)
+
+type ConfigFields struct {
+	Addr   string         `json:"addr" envconfig:"DASHBOARD_ADDR"`
+	Period types.Duration `json:"period,omitempty" envconfig:"DASHBOARD_PERIOD"`
+}
+
+type Config ConfigFields
+
+// NewConfig returns the defaults; the dashboard is only served to the local machine, and updated
+// every second.
+func NewConfig() *Config {
+	return &Config{
+		Addr:   "localhost:5665",
+		Period: types.Duration(1 * time.Second),
+	}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.Addr != "" {
+		c.Addr = cfg.Addr
+	}
+	if cfg.Period > 0 {
+		c.Period = cfg.Period
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is optionally the address to serve the dashboard
+// on, followed by options; eg. 0.0.0.0:5665?period=5s
+func (c *Config) UnmarshalText(text []byte) error {
+	s := string(text)
+	query := ""
+	if i := strings.Index(s, "?"); i != -1 {
+		s, query = s[:i], s[i+1:]
+	}
+	if s != "" {
+		c.Addr = s
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	for k, vs := range values {
+		switch k {
+		case "period":
+			d, err := time.ParseDuration(vs[0])
+			if err != nil || d <= 0 {
+				return errors.Errorf("period must be a positive duration, not %s", vs[0])
+			}
+			c.Period = types.Duration(d)
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return nil
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
@@ -0,0 +1,60 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigText(t *testing.T) {
+	testdata := map[string]struct {
+		Config Config
+		Err    string
+	}{
+		"":                           {Config{}, ""},
+		"0.0.0.0:5665":               {Config{Addr: "0.0.0.0:5665"}, ""},
+		"?period=5s":                 {Config{Period: types.Duration(5 * time.Second)}, ""},
+		"localhost:8080?period=0s":   {Config{}, "period must be a positive duration, not 0s"},
+		"localhost:8080?period=soon": {Config{}, "period must be a positive duration, not soon"},
+		"?foo=bar":                   {Config{}, "unknown query parameter: foo"},
+	}
+	for str, data := range testdata {
+		t.Run(str, func(t *testing.T) {
+			var config Config
+			err := config.UnmarshalText([]byte(str))
+			if data.Err != "" {
+				assert.EqualError(t, err, data.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.Config, config)
+		})
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	config := NewConfig().Apply(Config{Period: types.Duration(3 * time.Second)})
+	assert.Equal(t, Config{Addr: "localhost:5665", Period: types.Duration(3 * time.Second)}, config)
+}
@@ -0,0 +1,219 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dashboard
+
+// page is the dashboard; it's self-contained, so that it works without internet access, and
+// draws its charts on canvases from the points it gets from /events.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>k6 dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 0; background: #f4f4f7; color: #222; }
+header { background: #7d64ff; color: #fff; padding: 12px 20px; display: flex; justify-content: space-between; }
+header h1 { font-size: 18px; margin: 0; }
+#status { font-size: 14px; }
+main { display: grid; grid-template-columns: repeat(auto-fill, minmax(480px, 1fr)); gap: 16px; padding: 16px; }
+section { background: #fff; border-radius: 4px; padding: 12px; box-shadow: 0 1px 2px rgba(0,0,0,0.1); }
+section h2 { font-size: 14px; margin: 0 0 8px; }
+canvas { width: 100%; height: 200px; }
+.legend span { display: inline-block; margin-right: 12px; font-size: 12px; }
+.legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; }
+th, td { text-align: right; padding: 4px 8px; border-bottom: 1px solid #eee; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<header><h1>k6</h1><span id="status">Connecting...</span></header>
+<main>
+<section><h2>Requests per second</h2><canvas id="rps"></canvas><div class="legend" id="rps-legend"></div></section>
+<section><h2>Response time (ms)</h2><canvas id="duration"></canvas><div class="legend" id="duration-legend"></div></section>
+<section><h2>Failed requests and passed checks (%)</h2><canvas id="errors"></canvas><div class="legend" id="errors-legend"></div></section>
+<section><h2>VUs and iterations per second</h2><canvas id="vus"></canvas><div class="legend" id="vus-legend"></div></section>
+<section><h2>Requests per second by scenario</h2><canvas id="scenarios"></canvas><div class="legend" id="scenarios-legend"></div></section>
+<section><h2>Scenarios</h2><table><thead><tr><th>Scenario</th><th>Requests/s</th><th>Iterations/s</th><th>p(95) (ms)</th><th>Failed</th></tr></thead><tbody id="scenario-table"></tbody></table></section>
+</main>
+<script>
+(function() {
+    "use strict";
+    var colors = ["#7d64ff", "#ff6584", "#2ec4b6", "#ff9f1c", "#3a86ff", "#8ac926", "#6a4c93", "#e71d36"];
+    var points = [];
+
+    function percent(v) { return v === null || v === undefined ? null : v * 100; }
+    function fixed(v) { return v === null || v === undefined ? "-" : v.toFixed(2); }
+
+    function draw(id, series) {
+        var canvas = document.getElementById(id);
+        var ratio = window.devicePixelRatio || 1;
+        var width = canvas.clientWidth, height = canvas.clientHeight;
+        canvas.width = width * ratio;
+        canvas.height = height * ratio;
+        var ctx = canvas.getContext("2d");
+        ctx.scale(ratio, ratio);
+        ctx.clearRect(0, 0, width, height);
+
+        var max = 0;
+        series.forEach(function(s) {
+            s.values.forEach(function(v) { if (v !== null && v > max) { max = v; } });
+        });
+        max = max > 0 ? max * 1.1 : 1;
+        var left = 48, bottom = height - 16, top = 8, right = width - 8;
+
+        ctx.strokeStyle = "#ddd";
+        ctx.fillStyle = "#666";
+        ctx.font = "11px sans-serif";
+        ctx.textAlign = "right";
+        for (var i = 0; i <= 4; i++) {
+            var y = bottom - (bottom - top) * i / 4;
+            ctx.beginPath();
+            ctx.moveTo(left, y);
+            ctx.lineTo(right, y);
+            ctx.stroke();
+            ctx.fillText((max * i / 4).toPrecision(3), left - 4, y + 4);
+        }
+        if (points.length > 0) {
+            ctx.textAlign = "left";
+            ctx.fillText(new Date(points[0].time).toLocaleTimeString(), left, height - 2);
+            ctx.textAlign = "right";
+            ctx.fillText(new Date(points[points.length - 1].time).toLocaleTimeString(), right, height - 2);
+        }
+
+        var n = points.length;
+        series.forEach(function(s, si) {
+            ctx.strokeStyle = colors[si % colors.length];
+            ctx.lineWidth = 1.5;
+            ctx.beginPath();
+            var drawing = false;
+            s.values.forEach(function(v, i) {
+                if (v === null) {
+                    drawing = false;
+                    return;
+                }
+                var x = n > 1 ? left + (right - left) * i / (n - 1) : left;
+                var y = bottom - (bottom - top) * v / max;
+                if (drawing) {
+                    ctx.lineTo(x, y);
+                } else {
+                    ctx.moveTo(x, y);
+                    drawing = true;
+                }
+            });
+            ctx.stroke();
+        });
+
+        var legend = document.getElementById(id + "-legend");
+        legend.innerHTML = "";
+        series.forEach(function(s, si) {
+            var span = document.createElement("span");
+            var swatch = document.createElement("i");
+            swatch.style.background = colors[si % colors.length];
+            span.appendChild(swatch);
+            var last = s.values.length > 0 ? s.values[s.values.length - 1] : null;
+            span.appendChild(document.createTextNode(s.label + ": " + fixed(last)));
+            legend.appendChild(span);
+        });
+    }
+
+    function values(f) {
+        return points.map(function(p) {
+            var v = f(p);
+            return v === undefined ? null : v;
+        });
+    }
+
+    function scenarioNames() {
+        var names = {};
+        points.forEach(function(p) {
+            Object.keys(p.scenarios || {}).forEach(function(name) { names[name] = true; });
+        });
+        return Object.keys(names).sort();
+    }
+
+    function render() {
+        draw("rps", [{ label: "requests/s", values: values(function(p) { return p.rps; }) }]);
+        draw("duration", ["p(50)", "p(90)", "p(95)", "p(99)"].map(function(name) {
+            return { label: name, values: values(function(p) { return p.duration ? p.duration[name] : null; }) };
+        }));
+        draw("errors", [
+            { label: "failed requests", values: values(function(p) { return percent(p.failed); }) },
+            { label: "passed checks", values: values(function(p) { return percent(p.checks); }) }
+        ]);
+        draw("vus", [
+            { label: "VUs", values: values(function(p) { return p.vus; }) },
+            { label: "iterations/s", values: values(function(p) { return p.iterations; }) }
+        ]);
+
+        var names = scenarioNames();
+        draw("scenarios", names.map(function(name) {
+            return { label: name, values: values(function(p) { return p.scenarios && p.scenarios[name] ? p.scenarios[name].rps : 0; }) };
+        }));
+
+        var last = points.length > 0 ? points[points.length - 1] : null;
+        var rows = document.getElementById("scenario-table");
+        rows.innerHTML = "";
+        names.forEach(function(name) {
+            var sc = last && last.scenarios && last.scenarios[name] ? last.scenarios[name] : {};
+            var tr = document.createElement("tr");
+            [name, fixed(sc.rps), fixed(sc.iterations), fixed(sc.p95), sc.failed === null || sc.failed === undefined ? "-" : fixed(sc.failed * 100) + "%"].forEach(function(text) {
+                var td = document.createElement("td");
+                td.textContent = text;
+                tr.appendChild(td);
+            });
+            rows.appendChild(tr);
+        });
+    }
+
+    var status = document.getElementById("status");
+    var pending = false;
+    function update() {
+        if (!pending) {
+            pending = true;
+            window.requestAnimationFrame(function() {
+                pending = false;
+                render();
+            });
+        }
+    }
+
+    var source = new EventSource("events");
+    source.addEventListener("open", function() { status.textContent = "Running"; });
+    source.addEventListener("point", function(e) {
+        points.push(JSON.parse(e.data));
+        update();
+    });
+    source.addEventListener("end", function() {
+        status.textContent = "Test finished";
+        source.close();
+    });
+    source.addEventListener("error", function() {
+        if (status.textContent !== "Test finished") {
+            status.textContent = "Disconnected";
+        }
+        source.close();
+    });
+    window.addEventListener("resize", update);
+})();
+</script>
+</body>
+</html>
+`
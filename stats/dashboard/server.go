@@ -0,0 +1,119 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// handler serves the page at /, every point so far as JSON at /data, and a stream of them as
+// server-sent events at /events: every point so far, then every new one, then an `end` event.
+func (c *Collector) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = rw.Write([]byte(page))
+	})
+	mux.HandleFunc("/data", func(rw http.ResponseWriter, r *http.Request) {
+		c.lock.Lock()
+		data, err := json.Marshal(c.points)
+		c.lock.Unlock()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(data)
+	})
+	mux.HandleFunc("/events", c.handleEvents)
+	return mux
+}
+
+func (c *Collector) handleEvents(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+
+	c.lock.Lock()
+	points := make([]Point, len(c.points))
+	copy(points, c.points)
+	ended := c.ended
+	var client chan Point
+	if !ended {
+		client = make(chan Point, 16)
+		c.clients[client] = struct{}{}
+	}
+	c.lock.Unlock()
+	if client != nil {
+		defer func() {
+			c.lock.Lock()
+			delete(c.clients, client)
+			c.lock.Unlock()
+		}()
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	for _, p := range points {
+		if err := writeEvent(rw, "point", p); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for !ended {
+		select {
+		case p, ok := <-client:
+			if !ok {
+				ended = true
+				break
+			}
+			if err := writeEvent(rw, "point", p); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+	_ = writeEvent(rw, "end", nil)
+	flusher.Flush()
+}
+
+func writeEvent(rw http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.WithError(err).Error("Dashboard: Couldn't encode an event")
+		return err
+	}
+	_, err = fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
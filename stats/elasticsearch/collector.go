@@ -0,0 +1,282 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	pushInterval = 1 * time.Second
+	pushTimeout  = 30 * time.Second
+
+	// dateLayout is what {date} is replaced with in index names.
+	dateLayout = "2006.01.02"
+)
+
+var _ lib.Collector = &Collector{}
+
+// Collector bulk-indexes samples into Elasticsearch or OpenSearch, as documents with the ID of
+// the test run, which also has a document with the test's metadata. An index template maps the
+// fields, unless it's disabled by naming it "-".
+type Collector struct {
+	Config Config
+	Client *http.Client
+
+	testID   string
+	script   string
+	version  string
+	template []byte
+
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+}
+
+func New(conf Config, fs afero.Fs, src *lib.SourceData, version string) (*Collector, error) {
+	if conf.URL == "" {
+		return nil, errors.New("the Elasticsearch output needs a URL")
+	}
+	if conf.Index == "" {
+		return nil, errors.New("the Elasticsearch output needs an index")
+	}
+	var template []byte
+	if conf.TemplateFile != "" {
+		data, err := afero.ReadFile(fs, conf.TemplateFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't read the index template")
+		}
+		template = data
+	} else {
+		template = defaultTemplate(conf.Index)
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: conf.Insecure}}
+	return &Collector{
+		Config:   conf,
+		Client:   &http.Client{Transport: transport, Timeout: pushTimeout},
+		testID:   hex.EncodeToString(id),
+		script:   src.Filename,
+		version:  version,
+		template: template,
+	}, nil
+}
+
+// defaultTemplate maps tags as keywords, so they can be aggregated on in Kibana.
+func defaultTemplate(index string) []byte {
+	template, _ := json.Marshal(map[string]interface{}{
+		"index_patterns": []string{strings.Replace(index, "{date}", "*", -1)},
+		"template": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"@timestamp":  map[string]string{"type": "date"},
+					"type":        map[string]string{"type": "keyword"},
+					"test_id":     map[string]string{"type": "keyword"},
+					"metric":      map[string]string{"type": "keyword"},
+					"metric_type": map[string]string{"type": "keyword"},
+					"value":       map[string]string{"type": "double"},
+				},
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{"tags": map[string]interface{}{
+						"path_match": "tags.*",
+						"mapping":    map[string]string{"type": "keyword"},
+					}},
+				},
+			},
+		},
+	})
+	return template
+}
+
+// Init installs the index template, and indexes the test's metadata.
+func (c *Collector) Init() error {
+	if c.Config.TemplateName != "-" {
+		if err := c.do("PUT", "/_index_template/"+c.Config.TemplateName, "application/json", c.template, nil); err != nil {
+			return errors.Wrap(err, "couldn't install the index template")
+		}
+	}
+
+	now := time.Now()
+	var buf bytes.Buffer
+	if err := c.writeDoc(&buf, now, map[string]interface{}{
+		"@timestamp": now,
+		"type":       "test",
+		"test_id":    c.testID,
+		"script":     c.script,
+		"k6_version": c.version,
+	}); err != nil {
+		return err
+	}
+	return c.bulk(buf.Bytes())
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.WithField("test_id", c.testID).Debug("Elasticsearch: Running!")
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit()
+		case <-ctx.Done():
+			c.commit()
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+func (c *Collector) Link() string {
+	return c.Config.URL + " (test_id: " + c.testID + ")"
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+func (c *Collector) commit() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	if len(samples) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		err := c.writeDoc(&buf, sample.Time, map[string]interface{}{
+			"@timestamp":  sample.Time,
+			"type":        "sample",
+			"test_id":     c.testID,
+			"metric":      sample.Metric.Name,
+			"metric_type": sample.Metric.Type,
+			"value":       sample.Value,
+			"tags":        sample.Tags,
+		})
+		if err != nil {
+			log.WithError(err).Warning("Elasticsearch: Couldn't encode a sample")
+		}
+	}
+
+	log.WithField("samples", len(samples)).Debug("Elasticsearch: Indexing...")
+	startTime := time.Now()
+	if err := c.bulk(buf.Bytes()); err != nil {
+		log.WithError(err).Error("Elasticsearch: Couldn't index samples")
+		return
+	}
+	log.WithField("t", time.Since(startTime)).Debug("Elasticsearch: Samples indexed!")
+}
+
+// writeDoc writes the action that indexes a document to a bulk request.
+func (c *Collector) writeDoc(buf *bytes.Buffer, t time.Time, doc map[string]interface{}) error {
+	index := strings.Replace(c.Config.Index, "{date}", t.UTC().Format(dateLayout), -1)
+	action, err := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": index}})
+	if err != nil {
+		return err
+	}
+	source, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	buf.Write(action)
+	buf.WriteByte('\n')
+	buf.Write(source)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// bulk sends a bulk request, and fails if any of its actions did.
+func (c *Collector) bulk(body []byte) error {
+	var res struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := c.do("POST", "/_bulk", "application/x-ndjson", body, &res); err != nil {
+		return err
+	}
+	if !res.Errors {
+		return nil
+	}
+	failed := 0
+	var first json.RawMessage
+	for _, item := range res.Items {
+		for _, result := range item {
+			if len(result.Error) > 0 {
+				if first == nil {
+					first = result.Error
+				}
+				failed++
+			}
+		}
+	}
+	return errors.Errorf("%d documents couldn't be indexed, eg.: %s", failed, first)
+}
+
+func (c *Collector) do(method, path, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Config.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.Config.Username != "" {
+		req.SetBasicAuth(c.Config.Username, c.Config.Password)
+	}
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.Errorf("%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
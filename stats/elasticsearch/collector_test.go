@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type esRequest struct {
+	method, path, body string
+}
+
+func newServer(t *testing.T, requests *[]esRequest, response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "elastic:changeme", user+":"+pass)
+		*requests = append(*requests, esRequest{r.Method, r.URL.Path, string(body)})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestCollector(t *testing.T) {
+	var requests []esRequest
+	srv := newServer(t, &requests, `{"errors": false, "items": []}`)
+	defer srv.Close()
+
+	config := NewConfig().Apply(Config{URL: srv.URL, Username: "elastic", Password: "changeme"})
+	c, err := New(config, afero.NewMemMapFs(), &lib.SourceData{Filename: "/script.js"}, "0.0.1")
+	require.NoError(t, err)
+	require.NoError(t, c.Init())
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "PUT", requests[0].method)
+	assert.Equal(t, "/_index_template/k6", requests[0].path)
+	var template map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(requests[0].body), &template))
+	assert.Equal(t, []interface{}{"k6-*"}, template["index_patterns"])
+
+	assert.Equal(t, "/_bulk", requests[1].path)
+	lines := strings.Split(strings.TrimSuffix(requests[1].body, "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `{"index":{"_index":"k6-`)
+	var meta map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &meta))
+	assert.Equal(t, "test", meta["type"])
+	assert.Equal(t, "/script.js", meta["script"])
+	assert.Equal(t, "0.0.1", meta["k6_version"])
+	assert.Equal(t, c.testID, meta["test_id"])
+
+	requests = nil
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Collect([]stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: stats.IntoSampleTags(&map[string]string{"status": "200"}), Time: now, Value: 1},
+	})
+	c.commit()
+	require.Len(t, requests, 1)
+	assert.Equal(t, `{"index":{"_index":"k6-2018.01.02"}}`+"\n"+
+		`{"@timestamp":"2018-01-02T03:04:05Z","metric":"http_reqs","metric_type":"counter","tags":{"status":"200"},"test_id":"`+c.testID+`","type":"sample","value":1}`+"\n",
+		requests[0].body)
+
+	t.Run("Empty", func(t *testing.T) {
+		requests = nil
+		c.commit()
+		assert.Empty(t, requests)
+	})
+}
+
+func TestCollectorTemplates(t *testing.T) {
+	var requests []esRequest
+	srv := newServer(t, &requests, `{"errors": true, "items": [{"index": {"error": {"type": "mapper_parsing_exception"}}}, {"index": {}}]}`)
+	defer srv.Close()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/template.json", []byte(`{"index_patterns": ["perf-*"]}`), 0644))
+
+	config := NewConfig().Apply(Config{
+		URL: srv.URL, Username: "elastic", Password: "changeme",
+		Index: "perf-{date}", TemplateName: "perf", TemplateFile: "/template.json",
+	})
+	c, err := New(config, fs, &lib.SourceData{Filename: "/script.js"}, "0.0.1")
+	require.NoError(t, err)
+	err = c.Init()
+	assert.EqualError(t, err, `1 documents couldn't be indexed, eg.: {"type": "mapper_parsing_exception"}`)
+	require.Len(t, requests, 2)
+	assert.Equal(t, esRequest{"PUT", "/_index_template/perf", `{"index_patterns": ["perf-*"]}`}, requests[0])
+	assert.Contains(t, requests[1].body, `{"index":{"_index":"perf-`)
+
+	t.Run("Disabled", func(t *testing.T) {
+		requests = nil
+		c.Config.TemplateName = "-"
+		_ = c.Init()
+		require.Len(t, requests, 1)
+		assert.Equal(t, "/_bulk", requests[0].path)
+	})
+}
@@ -0,0 +1,55 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package elasticsearch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigText(t *testing.T) {
+	testdata := map[string]struct {
+		Config Config
+		Err    string
+	}{
+		"":                              {Config{}, ""},
+		"http://localhost:9200":         {Config{URL: "http://localhost:9200"}, ""},
+		"https://u:p@es:9200?index=a-b": {Config{URL: "https://es:9200", Username: "u", Password: "p", Index: "a-b"}, ""},
+		"?template_name=perf":           {Config{TemplateName: "perf"}, ""},
+		"?template_file=t.json":         {Config{TemplateFile: "t.json"}, ""},
+		"?insecure=true":                {Config{Insecure: true}, ""},
+		"?insecure=ture":                {Config{}, "insecure must be true or false, not ture"},
+		"?foo=bar":                      {Config{}, "unknown query parameter: foo"},
+	}
+	for str, data := range testdata {
+		t.Run(str, func(t *testing.T) {
+			var config Config
+			err := config.UnmarshalText([]byte(str))
+			if data.Err != "" {
+				assert.EqualError(t, err, data.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.Config, config)
+		})
+	}
+}
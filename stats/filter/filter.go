@@ -0,0 +1,143 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package filter wraps an output so that it only gets some of the metrics, or samples without
+// some of their tags; eg. to keep high-cardinality tags like url out of a time series database,
+// while still writing them to a file.
+package filter
+
+import (
+	"strings"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+)
+
+// Config says what an output gets. Metric names can end with a *, which matches any name that
+// starts with the rest of it, eg. http_req_*.
+type Config struct {
+	// Metrics, if there are any, are the only metrics the output gets.
+	Metrics []string `json:"metrics"`
+	// SkipMetrics are metrics the output doesn't get.
+	SkipMetrics []string `json:"skipMetrics"`
+	// DropTags are removed from every sample.
+	DropTags []string `json:"dropTags"`
+}
+
+// IsEmpty returns whether the config lets everything through.
+func (c Config) IsEmpty() bool {
+	return len(c.Metrics) == 0 && len(c.SkipMetrics) == 0 && len(c.DropTags) == 0
+}
+
+// maxCachedTags is how many sets of tags without the dropped ones are remembered, so they aren't
+// made again for every sample; samples usually share their tags.
+const maxCachedTags = 10000
+
+// Collector passes the samples its config lets through to another collector.
+type Collector struct {
+	lib.Collector
+
+	conf      Config
+	dropTags  map[string]bool
+	tagsCache map[*stats.SampleTags]*stats.SampleTags
+}
+
+var _ lib.RunStatusUpdater = &Collector{}
+
+// New wraps a collector in a filter.
+func New(c lib.Collector, conf Config) *Collector {
+	f := &Collector{Collector: c, conf: conf}
+	if len(conf.DropTags) > 0 {
+		f.dropTags = make(map[string]bool, len(conf.DropTags))
+		for _, tag := range conf.DropTags {
+			f.dropTags[tag] = true
+		}
+		f.tagsCache = make(map[*stats.SampleTags]*stats.SampleTags)
+	}
+	return f
+}
+
+func matches(names []string, name string) bool {
+	for _, n := range names {
+		if n == name || (strings.HasSuffix(n, "*") && strings.HasPrefix(name, strings.TrimSuffix(n, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows returns whether the output gets the samples of a metric.
+func (f *Collector) Allows(name string) bool {
+	if len(f.conf.Metrics) > 0 && !matches(f.conf.Metrics, name) {
+		return false
+	}
+	return !matches(f.conf.SkipMetrics, name)
+}
+
+func (f *Collector) Collect(samples []stats.Sample) {
+	filtered := make([]stats.Sample, 0, len(samples))
+	for _, sample := range samples {
+		if !f.Allows(sample.Metric.Name) {
+			continue
+		}
+		if f.dropTags != nil {
+			sample.Tags = f.withoutDroppedTags(sample.Tags)
+		}
+		filtered = append(filtered, sample)
+	}
+	if len(filtered) > 0 {
+		f.Collector.Collect(filtered)
+	}
+}
+
+func (f *Collector) withoutDroppedTags(tags *stats.SampleTags) *stats.SampleTags {
+	if tags == nil {
+		return nil
+	}
+	if cached, ok := f.tagsCache[tags]; ok {
+		return cached
+	}
+
+	m := tags.CloneTags()
+	dropped := false
+	for tag := range m {
+		if f.dropTags[tag] {
+			delete(m, tag)
+			dropped = true
+		}
+	}
+	result := tags
+	if dropped {
+		result = stats.NewSampleTags(m)
+	}
+
+	if len(f.tagsCache) >= maxCachedTags {
+		f.tagsCache = make(map[*stats.SampleTags]*stats.SampleTags)
+	}
+	f.tagsCache[tags] = result
+	return result
+}
+
+// SetRunStatus passes the status of the run on, if the wrapped collector wants to know it.
+func (f *Collector) SetRunStatus(status lib.RunStatus) {
+	if updater, ok := f.Collector.(lib.RunStatusUpdater); ok {
+		updater.SetRunStatus(status)
+	}
+}
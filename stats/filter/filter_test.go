@@ -0,0 +1,93 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/stats/dummy"
+	"github.com/stretchr/testify/assert"
+)
+
+type statusCollector struct {
+	dummy.Collector
+	status lib.RunStatus
+}
+
+func (c *statusCollector) SetRunStatus(status lib.RunStatus) {
+	c.status = status
+}
+
+func TestCollector(t *testing.T) {
+	reqs := stats.New("http_reqs", stats.Counter)
+	duration := stats.New("http_req_duration", stats.Trend)
+	vus := stats.New("vus", stats.Gauge)
+	iterations := stats.New("iterations", stats.Counter)
+	tags := stats.NewSampleTags(map[string]string{"url": "http://example.com/1", "status": "200"})
+	samples := []stats.Sample{
+		{Metric: reqs, Tags: tags, Value: 1},
+		{Metric: duration, Tags: tags, Value: 2},
+		{Metric: vus, Value: 3},
+		{Metric: iterations, Tags: stats.NewSampleTags(map[string]string{"group": ""}), Value: 4},
+	}
+	values := func(samples []stats.Sample) []float64 {
+		var vs []float64
+		for _, s := range samples {
+			vs = append(vs, s.Value)
+		}
+		return vs
+	}
+
+	t.Run("Metrics", func(t *testing.T) {
+		c := &dummy.Collector{}
+		New(c, Config{Metrics: []string{"http_req*", "vus"}, SkipMetrics: []string{"http_reqs"}}).Collect(samples)
+		assert.Equal(t, []float64{2, 3}, values(c.Samples))
+	})
+	t.Run("DropTags", func(t *testing.T) {
+		c := &dummy.Collector{}
+		f := New(c, Config{DropTags: []string{"url"}})
+		f.Collect(samples)
+		f.Collect(samples)
+		assert.Equal(t, []float64{1, 2, 3, 4, 1, 2, 3, 4}, values(c.Samples))
+		assert.Equal(t, map[string]string{"status": "200"}, c.Samples[0].Tags.CloneTags())
+		assert.Nil(t, c.Samples[2].Tags)
+		assert.Equal(t, map[string]string{"group": ""}, c.Samples[3].Tags.CloneTags())
+
+		// Samples with the same tags share them afterwards too, and the originals are untouched.
+		assert.True(t, c.Samples[0].Tags == c.Samples[5].Tags)
+		assert.Equal(t, "http://example.com/1", samples[0].Tags.CloneTags()["url"])
+	})
+	t.Run("Nothing", func(t *testing.T) {
+		c := &dummy.Collector{}
+		New(c, Config{Metrics: []string{"data_sent"}}).Collect(samples)
+		assert.Nil(t, c.Samples)
+	})
+	t.Run("RunStatus", func(t *testing.T) {
+		c := &statusCollector{}
+		f := New(c, Config{})
+		f.SetRunStatus(lib.RunStatusFinished)
+		assert.Equal(t, lib.RunStatusFinished, c.status)
+		assert.Equal(t, "http://example.com/", f.Link())
+		New(&dummy.Collector{}, Config{}).SetRunStatus(lib.RunStatusFinished)
+	})
+}
@@ -0,0 +1,168 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pushInterval = 1 * time.Second
+	dialTimeout  = 5 * time.Second
+)
+
+var _ lib.Collector = &Collector{}
+
+// Collector sends samples to a Carbon receiver with Graphite's plaintext protocol, over TCP.
+// Graphite doesn't know about metric types, so every sample is sent as it is; with tags enabled,
+// the whitelisted sample tags are sent as Graphite 1.1's tags.
+type Collector struct {
+	Config Config
+
+	conn       net.Conn
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+}
+
+func New(conf Config) (*Collector, error) {
+	if conf.Addr == "" {
+		return nil, errors.New("the Graphite output needs an address")
+	}
+	return &Collector{Config: conf}, nil
+}
+
+func (c *Collector) Init() error {
+	conn, err := net.DialTimeout("tcp", c.Config.Addr, dialTimeout)
+	if err != nil {
+		return errors.Wrap(err, "couldn't connect to Graphite")
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("Graphite: Running!")
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit()
+		case <-ctx.Done():
+			c.commit()
+			if c.conn != nil {
+				_ = c.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+func (c *Collector) Link() string {
+	return c.Config.Addr
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+// commit sends the buffered samples. If the connection was lost, it's reopened first; if that
+// fails, the samples are dropped, as Carbon would have dropped them if it was overloaded.
+func (c *Collector) commit() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	if len(samples) == 0 {
+		return
+	}
+	if c.conn == nil {
+		if err := c.Init(); err != nil {
+			log.WithError(err).Error("Graphite: Couldn't send samples")
+			return
+		}
+	}
+
+	log.WithField("samples", len(samples)).Debug("Graphite: Sending...")
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		buf.WriteString(c.line(sample))
+		buf.WriteByte('\n')
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		log.WithError(err).Error("Graphite: Couldn't send samples")
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// line formats a sample as "path;tag=value value timestamp".
+func (c *Collector) line(sample stats.Sample) string {
+	path := pathEscaper.Replace(c.Config.Namespace + sample.Metric.Name)
+	if tags := c.tags(sample.Tags); len(tags) > 0 {
+		path += ";" + strings.Join(tags, ";")
+	}
+	return path + " " + strconv.FormatFloat(sample.Value, 'f', -1, 64) + " " +
+		strconv.FormatInt(sample.Time.Unix(), 10)
+}
+
+var (
+	// pathEscaper replaces the characters that separate the fields of a line.
+	pathEscaper = strings.NewReplacer(" ", "_", ";", "_", "\n", "_")
+
+	// tagEscaper also replaces the characters Graphite doesn't allow in tags.
+	tagEscaper = strings.NewReplacer(" ", "_", ";", "_", "\n", "_", "~", "_", "=", "_", "!", "_", "^", "_")
+)
+
+func (c *Collector) tags(sampleTags *stats.SampleTags) []string {
+	if !c.Config.EnableTags {
+		return nil
+	}
+	var tags []string
+	for _, key := range c.Config.TagWhitelist {
+		// Graphite doesn't allow empty tag values.
+		if value, ok := sampleTags.Get(key); ok && value != "" {
+			tags = append(tags, tagEscaper.Replace(key)+"="+tagEscaper.Replace(value))
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
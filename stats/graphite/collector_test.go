@@ -0,0 +1,113 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package graphite
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	read := func(n int) []string {
+		var result []string
+		for i := 0; i < n; i++ {
+			select {
+			case line := <-lines:
+				result = append(result, line)
+			case <-time.After(time.Second):
+				t.Fatalf("only got %d of %d lines", i, n)
+			}
+		}
+		return result
+	}
+
+	now := time.Unix(1500000000, 0)
+	tags := stats.IntoSampleTags(&map[string]string{"status": "200", "method": "GET", "url": "http://example.com/a;b"})
+	groupTags := stats.IntoSampleTags(&map[string]string{"group": "::a b=c", "name": ""})
+	samples := []stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: tags, Time: now, Value: 1},
+		{Metric: metrics.HTTPReqDuration, Tags: tags, Time: now, Value: 12.5},
+		{Metric: metrics.VUs, Time: now, Value: 5},
+		{Metric: stats.New("my trend", stats.Trend), Tags: groupTags, Time: now, Value: 3},
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		c, err := New(NewConfig().Apply(Config{Addr: listener.Addr().String()}))
+		require.NoError(t, err)
+		require.NoError(t, c.Init())
+		c.Collect(samples)
+		c.commit()
+		assert.Equal(t, []string{
+			"k6.http_reqs 1 1500000000",
+			"k6.http_req_duration 12.5 1500000000",
+			"k6.vus 5 1500000000",
+			"k6.my_trend 3 1500000000",
+		}, read(4))
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		c, err := New(NewConfig().Apply(Config{Addr: listener.Addr().String(), EnableTags: true}))
+		require.NoError(t, err)
+		require.NoError(t, c.Init())
+		c.Collect(samples)
+		c.commit()
+		assert.Equal(t, []string{
+			"k6.http_reqs;method=GET;status=200 1 1500000000",
+			"k6.http_req_duration;method=GET;status=200 12.5 1500000000",
+			"k6.vus 5 1500000000",
+			"k6.my_trend;group=::a_b_c 3 1500000000",
+		}, read(4))
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		c, err := New(NewConfig().Apply(Config{Addr: listener.Addr().String()}))
+		require.NoError(t, err)
+		c.Collect(samples[2:3])
+		c.commit()
+		assert.Equal(t, []string{"k6.vus 5 1500000000"}, read(1))
+	})
+}
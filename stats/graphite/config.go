@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package graphite
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type ConfigFields struct {
+	// Connection.
+	Addr string `json:"addr" envconfig:"GRAPHITE_ADDR"`
+
+	// Samples.
+	Namespace    string   `json:"namespace,omitempty" envconfig:"GRAPHITE_NAMESPACE"`
+	EnableTags   bool     `json:"enableTags,omitempty" envconfig:"GRAPHITE_ENABLE_TAGS"`
+	TagWhitelist []string `json:"tagWhitelist,omitempty" envconfig:"GRAPHITE_TAG_WHITELIST"`
+}
+
+type Config ConfigFields
+
+// NewConfig returns the defaults; tags are off, since they need Graphite 1.1 or later, and only
+// tags with few values are sent with them, since every combination of tags is a separate series.
+func NewConfig() *Config {
+	return &Config{
+		Addr:         "localhost:2003",
+		Namespace:    "k6.",
+		TagWhitelist: []string{"status", "method", "name", "group", "check", "error_code", "tls_version"},
+	}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.Addr != "" {
+		c.Addr = cfg.Addr
+	}
+	if cfg.Namespace != "" {
+		c.Namespace = cfg.Namespace
+	}
+	if cfg.EnableTags {
+		c.EnableTags = cfg.EnableTags
+	}
+	if len(cfg.TagWhitelist) > 0 {
+		c.TagWhitelist = cfg.TagWhitelist
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is the Carbon receiver's address, optionally
+// followed by options; eg. localhost:2003?namespace=shop.&enable_tags=true
+func (c *Config) UnmarshalText(text []byte) error {
+	s := string(text)
+	query := ""
+	if i := strings.Index(s, "?"); i != -1 {
+		s, query = s[:i], s[i+1:]
+	}
+	if s != "" {
+		c.Addr = s
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	for k, vs := range values {
+		switch k {
+		case "namespace":
+			c.Namespace = vs[0]
+		case "enable_tags":
+			switch vs[0] {
+			case "":
+			case "false":
+				c.EnableTags = false
+			case "true":
+				c.EnableTags = true
+			default:
+				return errors.Errorf("enable_tags must be true or false, not %s", vs[0])
+			}
+		case "tag_whitelist":
+			c.TagWhitelist = nil
+			for _, v := range vs {
+				for _, tag := range strings.Split(v, ",") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						c.TagWhitelist = append(c.TagWhitelist, tag)
+					}
+				}
+			}
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return nil
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
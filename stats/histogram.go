@@ -0,0 +1,129 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// histogramAccuracy is how far off, relative to the actual value, a value estimated from a
+// histogram can be.
+const histogramAccuracy = 0.01
+
+var (
+	histogramGamma    = (1 + histogramAccuracy) / (1 - histogramAccuracy)
+	histogramLogGamma = math.Log(histogramGamma)
+)
+
+// A histogram counts values in buckets that grow exponentially, so that every value is within
+// histogramAccuracy of the value its bucket stands for. Only buckets that have values take up
+// memory; a few thousand of them cover everything from nanoseconds to days.
+type histogram struct {
+	Count    uint64
+	Zeros    uint64           // Values of exactly 0, which no bucket covers.
+	Positive map[int32]uint64 // Buckets of positive values, by index.
+	Negative map[int32]uint64 // Buckets of negative values, by the index of their absolute value.
+}
+
+func newHistogram() *histogram {
+	return &histogram{Positive: make(map[int32]uint64), Negative: make(map[int32]uint64)}
+}
+
+// bucketIndex returns the index of the bucket a positive value goes in.
+func bucketIndex(v float64) int32 {
+	return int32(math.Ceil(math.Log(v) / histogramLogGamma))
+}
+
+// bucketValue returns the value a bucket stands for, the one in its middle.
+func bucketValue(i int32) float64 {
+	return 2 * math.Pow(histogramGamma, float64(i)) / (histogramGamma + 1)
+}
+
+func (h *histogram) Add(v float64) {
+	h.Count++
+	switch {
+	case v > 0:
+		h.Positive[bucketIndex(v)]++
+	case v < 0:
+		h.Negative[bucketIndex(-v)]++
+	default:
+		h.Zeros++
+	}
+}
+
+// merge adds the values of another histogram to this one.
+func (h *histogram) merge(o *histogram) {
+	h.Count += o.Count
+	h.Zeros += o.Zeros
+	for i, count := range o.Positive {
+		h.Positive[i] += count
+	}
+	for i, count := range o.Negative {
+		h.Negative[i] += count
+	}
+}
+
+// histogramBucket is a bucket's value, and how many values it has.
+type histogramBucket struct {
+	value float64
+	count uint64
+}
+
+// buckets returns the buckets that have values, from the lowest value to the highest.
+func (h *histogram) buckets() []histogramBucket {
+	buckets := make([]histogramBucket, 0, len(h.Negative)+len(h.Positive)+1)
+	for i, count := range h.Negative {
+		buckets = append(buckets, histogramBucket{-bucketValue(i), count})
+	}
+	if h.Zeros > 0 {
+		buckets = append(buckets, histogramBucket{0, h.Zeros})
+	}
+	for i, count := range h.Positive {
+		buckets = append(buckets, histogramBucket{bucketValue(i), count})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].value < buckets[j].value })
+	return buckets
+}
+
+// Quantile returns the value at a fraction of the counted values, interpolated between the two
+// closest ones, like TrendSink.P() does with the actual values.
+func (h *histogram) Quantile(q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := q * float64(h.Count-1)
+	lower, upper := uint64(math.Floor(rank)), uint64(math.Ceil(rank))
+
+	var j, k float64
+	var seen uint64
+	for _, b := range h.buckets() {
+		if seen <= lower && lower < seen+b.count {
+			j = b.value
+		}
+		if seen <= upper && upper < seen+b.count {
+			k = b.value
+			break
+		}
+		seen += b.count
+	}
+	return j + (k-j)*(rank-math.Floor(rank))
+}
@@ -0,0 +1,61 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, 0.0, newHistogram().Quantile(0.5))
+	})
+	t.Run("accuracy", func(t *testing.T) {
+		for _, v := range []float64{0.000001, 0.5, 1, 3, 1234.5678, 1e9} {
+			h := newHistogram()
+			h.Add(v)
+			assert.InEpsilon(t, v, h.Quantile(0.5), histogramAccuracy, "%v", v)
+		}
+	})
+	t.Run("signs", func(t *testing.T) {
+		h := newHistogram()
+		for _, v := range []float64{-10, -1, 0, 0, 1, 10} {
+			h.Add(v)
+		}
+		assert.Equal(t, uint64(6), h.Count)
+		assert.Equal(t, uint64(2), h.Zeros)
+		assert.InEpsilon(t, -10, h.Quantile(0), histogramAccuracy)
+		assert.InEpsilon(t, -1, h.Quantile(0.2), histogramAccuracy)
+		assert.Equal(t, 0.0, h.Quantile(0.5))
+		assert.InEpsilon(t, 1, h.Quantile(0.8), histogramAccuracy)
+		assert.InEpsilon(t, 10, h.Quantile(1), histogramAccuracy)
+	})
+	t.Run("buckets", func(t *testing.T) {
+		// Values that are close enough share a bucket.
+		h := newHistogram()
+		for i := 0; i < 1000; i++ {
+			h.Add(100 + float64(i)/1000)
+		}
+		assert.Len(t, h.Positive, 1)
+	})
+}
@@ -60,6 +60,11 @@ func New(conf Config) (*Collector, error) {
 }
 
 func (c *Collector) Init() error {
+	// Buckets can't be created with the write API of InfluxDB 2.
+	if c.Config.IsV2() {
+		return nil
+	}
+
 	// Try to create the database if it doesn't exist. Failure to do so is USUALLY harmless; it
 	// usually means we're either a non-admin user to an existing DB or connecting over UDP.
 	_, err := c.Client.Query(client.NewQuery("CREATE DATABASE "+c.BatchConf.Database, "", ""))
@@ -37,6 +37,11 @@ type ConfigFields struct {
 	Insecure    bool   `json:"insecure,omitempty" envconfig:"INFLUXDB_INSECURE"`
 	PayloadSize int    `json:"payload_size,omitempty" envconfig:"INFLUXDB_PAYLOAD_SIZE"`
 
+	// InfluxDB 2, which is used if any of these are set.
+	Token        string `json:"token,omitempty" envconfig:"INFLUXDB_TOKEN"`
+	Organization string `json:"organization,omitempty" envconfig:"INFLUXDB_ORGANIZATION"`
+	Bucket       string `json:"bucket,omitempty" envconfig:"INFLUXDB_BUCKET"`
+
 	// Samples.
 	DB           string   `json:"db" envconfig:"INFLUXDB_DB"`
 	Precision    string   `json:"precision,omitempty" envconfig:"INFLUXDB_PRECISION"`
@@ -68,6 +73,15 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.PayloadSize > 0 {
 		c.PayloadSize = cfg.PayloadSize
 	}
+	if cfg.Token != "" {
+		c.Token = cfg.Token
+	}
+	if cfg.Organization != "" {
+		c.Organization = cfg.Organization
+	}
+	if cfg.Bucket != "" {
+		c.Bucket = cfg.Bucket
+	}
 	if cfg.DB != "" {
 		c.DB = cfg.DB
 	}
@@ -86,6 +100,12 @@ func (c Config) Apply(cfg Config) Config {
 	return c
 }
 
+// IsV2 returns whether samples are written to InfluxDB 2, which authenticates with a token and
+// stores samples in the buckets of organizations rather than in databases.
+func (c Config) IsV2() bool {
+	return c.Token != "" || c.Organization != "" || c.Bucket != ""
+}
+
 func (c *Config) UnmarshalText(text []byte) error {
 	u, err := url.Parse(string(text))
 	if err != nil {
@@ -115,6 +135,12 @@ func (c *Config) UnmarshalText(text []byte) error {
 			}
 		case "payload_size":
 			c.PayloadSize, err = strconv.Atoi(vs[0])
+		case "token":
+			c.Token = vs[0]
+		case "org":
+			c.Organization = vs[0]
+		case "bucket":
+			c.Bucket = vs[0]
 		case "precision":
 			c.Precision = vs[0]
 		case "retention":
@@ -44,6 +44,9 @@ func TestConfigText(t *testing.T) {
 		"?insecure=ture":   {Config{}, "insecure must be true or false, not ture"},
 		"?payload_size=69": {Config{PayloadSize: 69}, ""},
 		"?payload_size=a":  {Config{}, "strconv.Atoi: parsing \"a\": invalid syntax"},
+		"?token=abc":       {Config{Token: "abc"}, ""},
+		"?org=myorg":       {Config{Organization: "myorg"}, ""},
+		"?bucket=k6":       {Config{Bucket: "k6"}, ""},
 	}
 	for str, data := range testdata {
 		t.Run(str, func(t *testing.T) {
@@ -27,6 +27,13 @@ import (
 )
 
 func MakeClient(conf Config) (client.Client, error) {
+	if conf.IsV2() {
+		cl, err := newV2Client(conf)
+		if err != nil {
+			return nil, err
+		}
+		return cl, nil
+	}
 	if strings.HasPrefix(conf.Addr, "udp://") {
 		return client.NewUDPClient(client.UDPConfig{
 			Addr:        strings.TrimPrefix(conf.Addr, "udp://"),
@@ -46,6 +53,10 @@ func MakeClient(conf Config) (client.Client, error) {
 }
 
 func MakeBatchConfig(conf Config) client.BatchPointsConfig {
+	// InfluxDB 2 writes to buckets, which are named in the path of the URL like databases are.
+	if conf.IsV2() && conf.Bucket != "" {
+		conf.DB = conf.Bucket
+	}
 	if conf.DB == "" {
 		conf.DB = "k6"
 	}
@@ -40,4 +40,10 @@ func TestMakeBatchConfig(t *testing.T) {
 			MakeBatchConfig(Config{DB: "dbname"}),
 		)
 	})
+	t.Run("Bucket Set", func(t *testing.T) {
+		assert.Equal(t,
+			client.BatchPointsConfig{Database: "bucket"},
+			MakeBatchConfig(Config{DB: "dbname", Bucket: "bucket"}),
+		)
+	})
 }
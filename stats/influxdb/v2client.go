@@ -0,0 +1,177 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	client "github.com/influxdata/influxdb/client/v2"
+	"github.com/pkg/errors"
+)
+
+const (
+	// InfluxDB 2 recommends writing batches of 5000 lines.
+	v2BatchSize  = 5000
+	v2MaxRetries = 3
+	v2Timeout    = 10 * time.Second
+)
+
+// v2Client writes to InfluxDB 2 through its write API, which takes a token instead of a username
+// and password, and writes to a bucket of an organization instead of a database. Big batches are
+// split up, and writes are retried if the server is overloaded.
+type v2Client struct {
+	addr          string
+	token         string
+	org           string
+	precision     string
+	httpClient    *http.Client
+	batchSize     int
+	retryInterval time.Duration
+}
+
+var _ client.Client = &v2Client{}
+
+func newV2Client(conf Config) (*v2Client, error) {
+	precision := conf.Precision
+	switch precision {
+	case "", "n", "ns":
+		precision = "ns"
+	case "u", "us":
+		precision = "us"
+	case "ms", "s":
+	default:
+		return nil, errors.Errorf("InfluxDB 2 doesn't support the precision %s", precision)
+	}
+	if conf.Organization == "" {
+		return nil, errors.New("InfluxDB 2 needs an organization")
+	}
+	addr := conf.Addr
+	if addr == "" {
+		addr = "http://localhost:8086"
+	}
+	return &v2Client{
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     conf.Token,
+		org:       conf.Organization,
+		precision: precision,
+		httpClient: &http.Client{
+			Timeout:   v2Timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: conf.Insecure}},
+		},
+		batchSize:     v2BatchSize,
+		retryInterval: 1 * time.Second,
+	}, nil
+}
+
+func (c *v2Client) Ping(timeout time.Duration) (time.Duration, string, error) {
+	start := time.Now()
+	cl := *c.httpClient
+	cl.Timeout = timeout
+	res, err := cl.Get(c.addr + "/ping")
+	if err != nil {
+		return 0, "", err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return 0, "", errors.New(res.Status)
+	}
+	return time.Since(start), res.Header.Get("X-Influxdb-Version"), nil
+}
+
+// Write writes the points to the batch's database, which is the bucket.
+func (c *v2Client) Write(bp client.BatchPoints) error {
+	points := bp.Points()
+	for len(points) > 0 {
+		n := c.batchSize
+		if n > len(points) {
+			n = len(points)
+		}
+		var b bytes.Buffer
+		for _, p := range points[:n] {
+			b.WriteString(p.PrecisionString(c.precision))
+			b.WriteByte('\n')
+		}
+		if err := c.write(bp.Database(), b.Bytes()); err != nil {
+			return err
+		}
+		points = points[n:]
+	}
+	return nil
+}
+
+// write POSTs lines, retrying if the server responds with 429 Too Many Requests or 503 Service
+// Unavailable, after the time it asks for in Retry-After, or else after a backoff.
+func (c *v2Client) write(bucket string, lines []byte) error {
+	params := url.Values{}
+	params.Set("org", c.org)
+	params.Set("bucket", bucket)
+	params.Set("precision", c.precision)
+	u := c.addr + "/api/v2/write?" + params.Encode()
+
+	wait := c.retryInterval
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("POST", u, bytes.NewReader(lines))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		req.Header.Set("User-Agent", "k6")
+		if c.token != "" {
+			req.Header.Set("Authorization", "Token "+c.token)
+		}
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+
+		switch {
+		case res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusOK:
+			return nil
+		case (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) &&
+			attempt < v2MaxRetries:
+			delay := wait
+			if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+				delay = time.Duration(s) * time.Second
+			}
+			time.Sleep(delay)
+			wait *= 2
+		default:
+			return errors.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+		}
+	}
+}
+
+func (c *v2Client) Query(q client.Query) (*client.Response, error) {
+	return nil, errors.New("queries aren't supported with InfluxDB 2")
+}
+
+func (c *v2Client) Close() error {
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package influxdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	client "github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestV2Client(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	failures := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if failures > 0 {
+			failures--
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	conf := Config{Addr: srv.URL, Token: "secret", Organization: "myorg", Bucket: "k6", Precision: "ms"}
+	cl, err := MakeClient(conf)
+	require.NoError(t, err)
+	v2 := cl.(*v2Client)
+	v2.batchSize = 2
+	v2.retryInterval = time.Millisecond
+
+	batch, err := client.NewBatchPoints(MakeBatchConfig(conf))
+	require.NoError(t, err)
+	now := time.Unix(1, 0)
+	for _, value := range []float64{1, 2, 3} {
+		p, err := client.NewPoint("http_reqs", map[string]string{"status": "200"}, map[string]interface{}{"value": value}, now)
+		require.NoError(t, err)
+		batch.AddPoint(p)
+	}
+
+	t.Run("Write", func(t *testing.T) {
+		failures = 2
+		require.NoError(t, cl.Write(batch))
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/api/v2/write", requests[0].URL.Path)
+		assert.Equal(t, "k6", requests[0].URL.Query().Get("bucket"))
+		assert.Equal(t, "myorg", requests[0].URL.Query().Get("org"))
+		assert.Equal(t, "ms", requests[0].URL.Query().Get("precision"))
+		assert.Equal(t, "Token secret", requests[0].Header.Get("Authorization"))
+		assert.Equal(t, []string{
+			"http_reqs,status=200 value=1 1000\nhttp_reqs,status=200 value=2 1000\n",
+			"http_reqs,status=200 value=3 1000\n",
+		}, bodies)
+	})
+
+	t.Run("TooManyRetries", func(t *testing.T) {
+		failures = v2MaxRetries + 1
+		assert.EqualError(t, cl.Write(batch), "429 Too Many Requests: ")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := MakeClient(Config{Token: "secret"})
+		assert.EqualError(t, err, "InfluxDB 2 needs an organization")
+		_, err = MakeClient(Config{Organization: "myorg", Precision: "h"})
+		assert.EqualError(t, err, "InfluxDB 2 doesn't support the precision h")
+	})
+}
@@ -21,21 +21,50 @@
 package json
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
 
 type Collector struct {
-	outfile     io.WriteCloser
-	fname       string
+	fs    afero.Fs
+	conf  Config
+	fname string
+
+	// outfile is what rows are written to; closers are closed, in order, when the file is done.
+	outfile io.Writer
+	closers []io.Closer
+	opened  time.Time
+	written int64
+	files   int
+
 	seenMetrics []string
+	lock        sync.Mutex
+
+	series     map[string]*series
+	seriesKeys []string
+}
+
+// A series is the samples of a metric with the same tags, aggregated between two writes.
+type series struct {
+	metric *stats.Metric
+	tags   *stats.SampleTags
+	sink   stats.Sink
 }
 
 func (c *Collector) HasSeenMetric(str string) bool {
@@ -47,22 +76,89 @@ func (c *Collector) HasSeenMetric(str string) bool {
 	return false
 }
 
-func New(fs afero.Fs, fname string) (*Collector, error) {
-	if fname == "" || fname == "-" {
-		return &Collector{
-			outfile: os.Stdout,
-			fname:   "-",
-		}, nil
+// New returns a collector writing to the file named by arg, or stdout for "" or "-". The file
+// name may be followed by options, see Config; files ending in .gz are gzipped.
+func New(fs afero.Fs, arg string) (*Collector, error) {
+	var conf Config
+	if err := conf.UnmarshalText([]byte(arg)); err != nil {
+		return nil, err
 	}
 
-	logfile, err := fs.Create(fname)
-	if err != nil {
+	c := &Collector{fs: fs, conf: conf, series: make(map[string]*series)}
+	if conf.FileName == "" || conf.FileName == "-" {
+		if conf.RotateSize > 0 || conf.RotateInterval > 0 {
+			return nil, errors.New("JSON output can only be rotated when it's written to a file")
+		}
+		c.fname = "-"
+		c.outfile = os.Stdout
+		c.closers = []io.Closer{os.Stdout}
+		return c, nil
+	}
+
+	if err := c.open(); err != nil {
 		return nil, err
 	}
-	return &Collector{
-		outfile: logfile,
-		fname:   fname,
-	}, nil
+	return c, nil
+}
+
+// fileName returns the name of the i-th file written, eg. results.json.gz, results.1.json.gz...
+func (c *Collector) fileName(i int) string {
+	if i == 0 {
+		return c.conf.FileName
+	}
+	name, gz := c.conf.FileName, ""
+	if strings.HasSuffix(name, ".gz") {
+		name, gz = strings.TrimSuffix(name, ".gz"), ".gz"
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s.%d%s%s", strings.TrimSuffix(name, ext), i, ext, gz)
+}
+
+func (c *Collector) open() error {
+	fname := c.fileName(c.files)
+	f, err := c.fs.Create(fname)
+	if err != nil {
+		return err
+	}
+	c.fname = fname
+	c.files++
+	c.opened = time.Now()
+	c.written = 0
+	c.seenMetrics = nil
+	if strings.HasSuffix(fname, ".gz") {
+		gz := gzip.NewWriter(f)
+		c.outfile = gz
+		c.closers = []io.Closer{gz, f}
+	} else {
+		c.outfile = f
+		c.closers = []io.Closer{f}
+	}
+	return nil
+}
+
+func (c *Collector) close() {
+	for _, closer := range c.closers {
+		if err := closer.Close(); err != nil {
+			log.WithError(err).WithField("filename", c.fname).Error("JSON: Couldn't close file")
+		}
+	}
+	c.closers = nil
+}
+
+// rotate starts a new file if the current one is big or old enough.
+func (c *Collector) rotate() {
+	full := c.conf.RotateSize > 0 && c.written >= c.conf.RotateSize
+	old := c.conf.RotateInterval > 0 && time.Since(c.opened) >= c.conf.RotateInterval
+	if !full && !old {
+		return
+	}
+	c.close()
+	if err := c.open(); err != nil {
+		log.WithError(err).WithField("filename", c.fileName(c.files)).Error("JSON: Couldn't rotate file")
+		c.outfile = ioutil.Discard
+		return
+	}
+	log.WithField("filename", c.fname).Debug("JSON: Rotated file")
 }
 
 func (c *Collector) Init() error {
@@ -71,8 +167,26 @@ func (c *Collector) Init() error {
 
 func (c *Collector) Run(ctx context.Context) {
 	log.WithField("filename", c.fname).Debug("JSON: Writing JSON metrics")
+	if c.conf.Aggregate > 0 {
+		ticker := time.NewTicker(c.conf.Aggregate)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.flush(time.Now())
+			case <-ctx.Done():
+				c.flush(time.Now())
+				c.lock.Lock()
+				c.close()
+				c.lock.Unlock()
+				return
+			}
+		}
+	}
 	<-ctx.Done()
-	_ = c.outfile.Close()
+	c.lock.Lock()
+	c.close()
+	c.lock.Unlock()
 }
 
 func (c *Collector) HandleMetric(m *stats.Metric) {
@@ -90,15 +204,31 @@ func (c *Collector) HandleMetric(m *stats.Metric) {
 		return
 	}
 
+	c.write(row)
+}
+
+func (c *Collector) write(row []byte) {
 	row = append(row, '\n')
-	_, err = c.outfile.Write(row)
+	n, err := c.outfile.Write(row)
+	c.written += int64(n)
 	if err != nil {
 		log.WithField("filename", c.fname).Error("JSON: Error writing to file")
 	}
 }
 
 func (c *Collector) Collect(samples []stats.Sample) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conf.Aggregate > 0 {
+		for _, sample := range samples {
+			c.aggregate(sample)
+		}
+		return
+	}
+
 	for _, sample := range samples {
+		c.rotate()
 		c.HandleMetric(sample.Metric)
 
 		env := WrapSample(&sample)
@@ -110,13 +240,53 @@ func (c *Collector) Collect(samples []stats.Sample) {
 				"JSON: Envelope is nil or Sample couldn't be marshalled to JSON")
 			continue
 		}
-		row = append(row, '\n')
-		_, err = c.outfile.Write(row)
+		c.write(row)
+	}
+}
+
+func (c *Collector) aggregate(sample stats.Sample) {
+	key := sample.Metric.Name
+	if sample.Tags != nil {
+		tags := sample.Tags.CloneTags()
+		names := make([]string, 0, len(tags))
+		for k := range tags {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			key += "\x00" + k + "=" + tags[k]
+		}
+	}
+	s, ok := c.series[key]
+	if !ok {
+		s = &series{metric: sample.Metric, tags: sample.Tags, sink: stats.New("", sample.Metric.Type).Sink}
+		c.series[key] = s
+		c.seriesKeys = append(c.seriesKeys, key)
+	}
+	s.sink.Add(sample)
+}
+
+// flush writes a point for every series that got samples since the last flush.
+func (c *Collector) flush(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, key := range c.seriesKeys {
+		s := c.series[key]
+		c.rotate()
+		c.HandleMetric(s.metric)
+
+		env := WrapAggregate(s.metric, now, s.tags, s.sink, c.conf.Aggregate)
+		row, err := json.Marshal(env)
 		if err != nil {
-			log.WithField("filename", c.fname).Error("JSON: Error writing to file")
+			log.WithField("filename", c.fname).Warning(
+				"JSON: Aggregated point couldn't be marshalled to JSON")
 			continue
 		}
+		c.write(row)
 	}
+	c.series = make(map[string]*series)
+	c.seriesKeys = nil
 }
 
 func (c *Collector) Link() string {
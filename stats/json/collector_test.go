@@ -21,11 +21,18 @@
 package json
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/loadimpact/k6/stats"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -50,3 +57,95 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func readEnvelopes(t *testing.T, fs afero.Fs, fname string) []map[string]interface{} {
+	f, err := fs.Open(fname)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var envs []map[string]interface{}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var env map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &env))
+		envs = append(envs, env)
+	}
+	require.NoError(t, scanner.Err())
+	return envs
+}
+
+func TestCollectRotated(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	collector, err := New(fs, "results.json.gz?rotate_size=400")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { collector.Run(ctx); close(done) }()
+
+	m := stats.New("my_metric", stats.Counter)
+	tags := stats.NewSampleTags(map[string]string{"a": "1"})
+	for i := 0; i < 4; i++ {
+		collector.Collect([]stats.Sample{{Metric: m, Time: time.Unix(int64(i), 0), Tags: tags, Value: 1}})
+	}
+	cancel()
+	<-done
+
+	// Every file starts with the metric, then has as many points as fit.
+	first := readEnvelopes(t, fs, "results.json.gz")
+	second := readEnvelopes(t, fs, "results.1.json.gz")
+	assert.Len(t, first, 3)
+	assert.Len(t, second, 3)
+	for _, envs := range [][]map[string]interface{}{first, second} {
+		assert.Equal(t, "Metric", envs[0]["type"])
+		assert.Equal(t, "Point", envs[1]["type"])
+		assert.Equal(t, "my_metric", envs[1]["metric"])
+	}
+	_, err = fs.Stat("results.2.json.gz")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = New(fs, "-?rotate_interval=1h")
+	assert.EqualError(t, err, "JSON output can only be rotated when it's written to a file")
+}
+
+func TestCollectAggregated(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	collector, err := New(fs, "results.json.gz?aggregate=1h")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { collector.Run(ctx); close(done) }()
+
+	m := stats.New("my_trend", stats.Trend)
+	tags1 := stats.NewSampleTags(map[string]string{"a": "1"})
+	tags2 := stats.NewSampleTags(map[string]string{"a": "2"})
+	collector.Collect([]stats.Sample{
+		{Metric: m, Time: time.Now(), Tags: tags1, Value: 1},
+		{Metric: m, Time: time.Now(), Tags: tags2, Value: 10},
+		{Metric: m, Time: time.Now(), Tags: tags1, Value: 3},
+	})
+	cancel()
+	<-done
+
+	envs := readEnvelopes(t, fs, "results.json.gz")
+	require.Len(t, envs, 3)
+	assert.Equal(t, "Metric", envs[0]["type"])
+
+	point := envs[1]
+	assert.Equal(t, "AggregatedPoint", point["type"])
+	assert.Equal(t, "my_trend", point["metric"])
+	data := point["data"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"a": "1"}, data["tags"])
+	values := data["values"].(map[string]interface{})
+	assert.Equal(t, 2.0, values["count"])
+	assert.Equal(t, 1.0, values["min"])
+	assert.Equal(t, 3.0, values["max"])
+	assert.Equal(t, 2.0, values["avg"])
+
+	data = envs[2]["data"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"a": "2"}, data["tags"])
+	assert.Equal(t, 1.0, data["values"].(map[string]interface{})["count"])
+}
@@ -0,0 +1,81 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+// Config is read from the --out argument, which is the file name, optionally followed by options;
+// eg. results.json.gz?rotate_size=500MB&aggregate=10s
+type Config struct {
+	FileName string
+
+	// RotateSize and RotateInterval start a new file once the current one has reached a size, in
+	// uncompressed bytes, or has been written to for a while.
+	RotateSize     int64
+	RotateInterval time.Duration
+
+	// Aggregate writes a point for every metric and set of tags once every interval, with the
+	// values its thresholds could use, instead of a point for every sample.
+	Aggregate time.Duration
+}
+
+func (c *Config) UnmarshalText(text []byte) error {
+	s := string(text)
+	query := ""
+	if i := strings.Index(s, "?"); i != -1 {
+		s, query = s[:i], s[i+1:]
+	}
+	c.FileName = s
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	for k, vs := range values {
+		switch k {
+		case "rotate_size":
+			size, err := humanize.ParseBytes(vs[0])
+			if err != nil {
+				return errors.Wrap(err, "invalid rotate_size")
+			}
+			c.RotateSize = int64(size)
+		case "rotate_interval", "aggregate":
+			d, err := time.ParseDuration(vs[0])
+			if err != nil || d <= 0 {
+				return errors.Errorf("%s must be a positive duration, not %s", k, vs[0])
+			}
+			if k == "aggregate" {
+				c.Aggregate = d
+			} else {
+				c.RotateInterval = d
+			}
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigText(t *testing.T) {
+	testdata := map[string]struct {
+		Config Config
+		Err    string
+	}{
+		"":                       {Config{}, ""},
+		"results.json":           {Config{FileName: "results.json"}, ""},
+		"results.json.gz":        {Config{FileName: "results.json.gz"}, ""},
+		"-?aggregate=10s":        {Config{FileName: "-", Aggregate: 10 * time.Second}, ""},
+		"a.json?rotate_size=1MB": {Config{FileName: "a.json", RotateSize: 1000000}, ""},
+		"a.json?rotate_size=1MiB&rotate_interval=1h": {
+			Config{FileName: "a.json", RotateSize: 1 << 20, RotateInterval: time.Hour}, "",
+		},
+		"a.json?rotate_size=big":     {Config{}, "invalid rotate_size: strconv.ParseFloat: parsing \"\": invalid syntax"},
+		"a.json?rotate_interval=-1s": {Config{}, "rotate_interval must be a positive duration, not -1s"},
+		"a.json?aggregate=often":     {Config{}, "aggregate must be a positive duration, not often"},
+		"a.json?compress=1":          {Config{}, "unknown query parameter: compress"},
+	}
+	for text, data := range testdata {
+		t.Run(text, func(t *testing.T) {
+			var config Config
+			err := config.UnmarshalText([]byte(text))
+			if data.Err != "" {
+				assert.EqualError(t, err, data.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.Config, config)
+		})
+	}
+}
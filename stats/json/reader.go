@@ -0,0 +1,82 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ReadSamples reads back the samples of a file the collector wrote, calling fn with each of them
+// in order. Their metrics are those of the file's Metric lines, which come before the first point
+// of each. Aggregated points can't be turned back into samples, so a file with them is an error.
+func ReadSamples(r io.Reader, fn func(stats.Sample) error) error {
+	metrics := make(map[string]*stats.Metric)
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var env struct {
+			Type   string          `json:"type"`
+			Metric string          `json:"metric"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := dec.Decode(&env); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+
+		switch env.Type {
+		case "Metric":
+			var data struct {
+				Type     stats.MetricType `json:"type"`
+				Contains stats.ValueType  `json:"contains"`
+			}
+			if err := json.Unmarshal(env.Data, &data); err != nil {
+				return errors.Wrapf(err, "line %d", line)
+			}
+			m := stats.New(env.Metric, data.Type, data.Contains)
+			if m == nil {
+				return errors.Errorf("line %d: metric %s has an unknown type", line, env.Metric)
+			}
+			metrics[env.Metric] = m
+		case "Point":
+			m, ok := metrics[env.Metric]
+			if !ok {
+				return errors.Errorf("line %d: a point of metric %s comes before the metric", line, env.Metric)
+			}
+			var data JSONSample
+			if err := json.Unmarshal(env.Data, &data); err != nil {
+				return errors.Wrapf(err, "line %d", line)
+			}
+			if err := fn(stats.Sample{Metric: m, Time: data.Time, Tags: data.Tags, Value: data.Value}); err != nil {
+				return err
+			}
+		case "AggregatedPoint":
+			return errors.Errorf("line %d: the samples of aggregated points can't be read back, "+
+				"only those of files written without the aggregate option", line)
+		default:
+			return errors.Errorf("line %d: unknown type %q", line, env.Type)
+		}
+	}
+}
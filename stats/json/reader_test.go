@@ -0,0 +1,90 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSamples(t *testing.T) {
+	t.Run("Collector", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		collector, err := New(fs, "/results.json")
+		require.NoError(t, err)
+		require.NoError(t, collector.Init())
+
+		trend := stats.New("my_trend", stats.Trend, stats.Time)
+		counter := stats.New("my_counter", stats.Counter)
+		tags := stats.IntoSampleTags(&map[string]string{"status": "200"})
+		now := time.Unix(1500000000, 500000000).UTC()
+		samples := []stats.Sample{
+			{Metric: trend, Time: now, Tags: tags, Value: 12.5},
+			{Metric: counter, Time: now.Add(time.Second), Value: 1},
+			{Metric: trend, Time: now.Add(2 * time.Second), Value: 7},
+		}
+		collector.Collect(samples)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		collector.Run(ctx)
+
+		f, err := fs.Open("/results.json")
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		var read []stats.Sample
+		require.NoError(t, ReadSamples(f, func(s stats.Sample) error {
+			read = append(read, s)
+			return nil
+		}))
+
+		require.Len(t, read, 3)
+		for i, s := range read {
+			assert.Equal(t, samples[i].Metric.Name, s.Metric.Name)
+			assert.Equal(t, samples[i].Metric.Type, s.Metric.Type)
+			assert.Equal(t, samples[i].Metric.Contains, s.Metric.Contains)
+			assert.True(t, samples[i].Time.Equal(s.Time))
+			assert.Equal(t, samples[i].Value, s.Value)
+			assert.Equal(t, samples[i].Tags.CloneTags(), s.Tags.CloneTags())
+		}
+		assert.True(t, read[0].Metric == read[2].Metric, "the samples of a metric have different metrics")
+	})
+
+	testdata := map[string]string{
+		`{"type":"Point","metric":"m","data":{"time":"2017-07-14T02:40:00Z","value":1,"tags":null}}`: "line 1: a point of metric m comes before the metric",
+
+		`{"type":"Metric","metric":"m","data":{"type":"trend"}}
+{"type":"AggregatedPoint","metric":"m","data":{}}`: "line 2: the samples of aggregated points can't be read back, only those of files written without the aggregate option",
+
+		`{"type":"Unknown"}`: `line 1: unknown type "Unknown"`,
+		`{"type":`:           "line 1: unexpected EOF",
+	}
+	for data, msg := range testdata {
+		err := ReadSamples(strings.NewReader(data), func(stats.Sample) error { return nil })
+		assert.EqualError(t, err, msg)
+	}
+}
@@ -68,3 +68,23 @@ func WrapMetric(metric *stats.Metric) *Envelope {
 		Data:   metric,
 	}
 }
+
+// AggregatedPoint holds the values of a metric, with a set of tags, over an interval; they're
+// what the metric's thresholds would see for that interval. Trends also have their count.
+type AggregatedPoint struct {
+	Time   time.Time          `json:"time"`
+	Tags   *stats.SampleTags  `json:"tags"`
+	Values map[string]float64 `json:"values"`
+}
+
+func WrapAggregate(metric *stats.Metric, t time.Time, tags *stats.SampleTags, sink stats.Sink, interval time.Duration) *Envelope {
+	values := sink.Format(interval)
+	if trend, ok := sink.(*stats.TrendSink); ok {
+		values["count"] = float64(trend.Count)
+	}
+	return &Envelope{
+		Type:   "AggregatedPoint",
+		Metric: metric.Name,
+		Data:   AggregatedPoint{Time: t, Tags: tags, Values: values},
+	}
+}
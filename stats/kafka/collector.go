@@ -0,0 +1,162 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	client "github.com/influxdata/influxdb/client/v2"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/pkg/errors"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pushInterval = 1 * time.Second
+)
+
+var _ lib.Collector = &Collector{}
+
+// A producer publishes messages; it's a kafka-go Writer, except in tests.
+type producer interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Collector publishes every sample as a message to a Kafka topic, either in the JSON output's
+// format or as an InfluxDB line. Messages are keyed by the metric's name, so the samples of a
+// metric go to the same partition, in order.
+type Collector struct {
+	Config   Config
+	Producer producer
+
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+}
+
+func New(conf Config) (*Collector, error) {
+	if len(conf.Brokers) == 0 {
+		return nil, errors.New("the Kafka output needs at least one broker")
+	}
+	if conf.Topic == "" {
+		return nil, errors.New("the Kafka output needs a topic")
+	}
+	if conf.Format != formatJSON && conf.Format != formatInfluxDB {
+		return nil, errors.Errorf("invalid Kafka message format '%s', expected json or influxdb", conf.Format)
+	}
+
+	dialer := &kafkago.Dialer{Timeout: 10 * time.Second, DualStack: true}
+	if conf.Username != "" {
+		dialer.SASLMechanism = plain.Mechanism{Username: conf.Username, Password: conf.Password}
+	}
+	writer := kafkago.NewWriter(kafkago.WriterConfig{
+		Brokers:  conf.Brokers,
+		Topic:    conf.Topic,
+		Dialer:   dialer,
+		Balancer: &kafkago.Hash{},
+	})
+	return &Collector{Config: conf, Producer: writer}, nil
+}
+
+func (c *Collector) Init() error {
+	return nil
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("Kafka: Running!")
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit()
+		case <-ctx.Done():
+			c.commit()
+			if err := c.Producer.Close(); err != nil {
+				log.WithError(err).Error("Kafka: Couldn't close the producer")
+			}
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+func (c *Collector) Link() string {
+	return strings.Join(c.Config.Brokers, ",") + "/" + c.Config.Topic
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+func (c *Collector) commit() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	if len(samples) == 0 {
+		return
+	}
+	msgs := make([]kafkago.Message, 0, len(samples))
+	for i := range samples {
+		value, err := c.format(&samples[i])
+		if err != nil {
+			log.WithError(err).Warning("Kafka: Couldn't format a sample")
+			continue
+		}
+		msgs = append(msgs, kafkago.Message{Key: []byte(samples[i].Metric.Name), Value: value})
+	}
+
+	log.WithField("messages", len(msgs)).Debug("Kafka: Publishing...")
+	startTime := time.Now()
+	if err := c.Producer.WriteMessages(context.Background(), msgs...); err != nil {
+		log.WithError(err).Error("Kafka: Couldn't publish samples")
+		return
+	}
+	log.WithField("t", time.Since(startTime)).Debug("Kafka: Samples published!")
+}
+
+func (c *Collector) format(sample *stats.Sample) ([]byte, error) {
+	if c.Config.Format == formatInfluxDB {
+		p, err := client.NewPoint(sample.Metric.Name, sample.Tags.CloneTags(),
+			map[string]interface{}{"value": sample.Value}, sample.Time)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(p.String()), nil
+	}
+	return json.Marshal(jsonc.WrapSample(sample))
+}
@@ -0,0 +1,89 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProducer struct {
+	msgs []kafkago.Message
+}
+
+func (p *fakeProducer) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func (p *fakeProducer) Close() error {
+	return nil
+}
+
+func TestCollector(t *testing.T) {
+	now := time.Unix(1, 0).UTC()
+	samples := []stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: stats.IntoSampleTags(&map[string]string{"status": "200"}), Time: now, Value: 1},
+		{Metric: metrics.VUs, Time: now, Value: 5},
+	}
+
+	testdata := map[string][]string{
+		formatJSON: {
+			`{"type":"Point","data":{"time":"1970-01-01T00:00:01Z","value":1,"tags":{"status":"200"}},"metric":"http_reqs"}`,
+			`{"type":"Point","data":{"time":"1970-01-01T00:00:01Z","value":5,"tags":null},"metric":"vus"}`,
+		},
+		formatInfluxDB: {
+			`http_reqs,status=200 value=1 1000000000`,
+			`vus value=5 1000000000`,
+		},
+	}
+	for format, expected := range testdata {
+		t.Run(format, func(t *testing.T) {
+			c, err := New(Config{Brokers: []string{"localhost:9092"}, Topic: "k6", Format: format})
+			require.NoError(t, err)
+			p := &fakeProducer{}
+			c.Producer = p
+			c.Collect(samples)
+			c.commit()
+
+			require.Len(t, p.msgs, 2)
+			assert.Equal(t, "http_reqs", string(p.msgs[0].Key))
+			assert.Equal(t, "vus", string(p.msgs[1].Key))
+			assert.Equal(t, expected, []string{string(p.msgs[0].Value), string(p.msgs[1].Value)})
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := New(Config{Topic: "k6", Format: formatJSON})
+		assert.EqualError(t, err, "the Kafka output needs at least one broker")
+		_, err = New(Config{Brokers: []string{"localhost:9092"}, Format: formatJSON})
+		assert.EqualError(t, err, "the Kafka output needs a topic")
+		_, err = New(Config{Brokers: []string{"localhost:9092"}, Topic: "k6", Format: "csv"})
+		assert.EqualError(t, err, "invalid Kafka message format 'csv', expected json or influxdb")
+	})
+}
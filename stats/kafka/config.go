@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kafka
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	formatJSON     = "json"
+	formatInfluxDB = "influxdb"
+)
+
+type ConfigFields struct {
+	// Connection.
+	Brokers  []string `json:"brokers" envconfig:"KAFKA_BROKERS"`
+	Username string   `json:"username,omitempty" envconfig:"KAFKA_USERNAME"`
+	Password string   `json:"password,omitempty" envconfig:"KAFKA_PASSWORD"`
+
+	// Samples.
+	Topic  string `json:"topic" envconfig:"KAFKA_TOPIC"`
+	Format string `json:"format,omitempty" envconfig:"KAFKA_FORMAT"`
+}
+
+type Config ConfigFields
+
+func NewConfig() *Config {
+	return &Config{Brokers: []string{"localhost:9092"}, Topic: "k6", Format: formatJSON}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if len(cfg.Brokers) > 0 {
+		c.Brokers = cfg.Brokers
+	}
+	if cfg.Username != "" {
+		c.Username = cfg.Username
+	}
+	if cfg.Password != "" {
+		c.Password = cfg.Password
+	}
+	if cfg.Topic != "" {
+		c.Topic = cfg.Topic
+	}
+	if cfg.Format != "" {
+		c.Format = cfg.Format
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is a comma separated list of brokers, optionally
+// followed by options; eg. broker1:9092,broker2:9092?topic=k6&format=influxdb
+func (c *Config) UnmarshalText(text []byte) error {
+	s := string(text)
+	query := ""
+	if i := strings.Index(s, "?"); i != -1 {
+		s, query = s[:i], s[i+1:]
+	}
+	if s != "" {
+		c.Brokers = strings.Split(s, ",")
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	for k, vs := range values {
+		switch k {
+		case "topic":
+			c.Topic = vs[0]
+		case "format":
+			c.Format = vs[0]
+		case "username":
+			c.Username = vs[0]
+		case "password":
+			c.Password = vs[0]
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return nil
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
@@ -0,0 +1,265 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package newrelic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const pushTimeout = 30 * time.Second
+
+var _ lib.Collector = &Collector{}
+
+// Collector aggregates samples by metric and tags for every push interval, and sends them to
+// New Relic's Metric API: counters as counts, gauges as the last value they had, rates as the
+// fraction of non-zero samples in a gauge, and trends as summaries.
+type Collector struct {
+	Config Config
+	Client *http.Client
+
+	start      time.Time
+	series     map[string]*series
+	seriesLock sync.Mutex
+}
+
+// series is what a metric with a set of tags has been sent during an interval.
+type series struct {
+	metric   *stats.Metric
+	tags     *stats.SampleTags
+	count    float64
+	nonzero  float64
+	sum      float64
+	min, max float64
+	last     float64
+}
+
+// nrMetric is a data point in the Metric API's format.
+type nrMetric struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Value      interface{}       `json:"value"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type nrSummary struct {
+	Count float64 `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+type nrCommon struct {
+	Timestamp  int64             `json:"timestamp"`
+	IntervalMs int64             `json:"interval.ms"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type nrPayload struct {
+	Common  nrCommon   `json:"common"`
+	Metrics []nrMetric `json:"metrics"`
+}
+
+func New(conf Config) (*Collector, error) {
+	if conf.URL == "" {
+		return nil, errors.New("the New Relic output needs a URL")
+	}
+	if conf.APIKey == "" {
+		return nil, errors.New("the New Relic output needs an API key, eg. in K6_NEWRELIC_API_KEY")
+	}
+	if conf.PushInterval <= 0 {
+		return nil, errors.New("the New Relic push interval must be positive")
+	}
+	return &Collector{
+		Config: conf,
+		Client: &http.Client{Timeout: pushTimeout},
+		start:  time.Now(),
+		series: make(map[string]*series),
+	}, nil
+}
+
+func (c *Collector) Init() error {
+	return nil
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("New Relic: Running!")
+	ticker := time.NewTicker(time.Duration(c.Config.PushInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit(time.Now())
+		case <-ctx.Done():
+			c.commit(time.Now())
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.seriesLock.Lock()
+	defer c.seriesLock.Unlock()
+	for _, sample := range samples {
+		key := sample.Metric.Name + "\x00" + tagsKey(sample.Tags)
+		s, ok := c.series[key]
+		if !ok {
+			s = &series{metric: sample.Metric, tags: sample.Tags, min: math.Inf(1), max: math.Inf(-1)}
+			c.series[key] = s
+		}
+		s.count++
+		if sample.Value != 0 {
+			s.nonzero++
+		}
+		s.sum += sample.Value
+		s.min = math.Min(s.min, sample.Value)
+		s.max = math.Max(s.max, sample.Value)
+		s.last = sample.Value
+	}
+}
+
+func (c *Collector) Link() string {
+	return c.Config.URL
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+// commit sends what was collected since the last push, as data points for the interval between
+// them. If the push fails, the interval is lost, since the next one would overlap it.
+func (c *Collector) commit(now time.Time) {
+	c.seriesLock.Lock()
+	collected, start := c.series, c.start
+	c.series, c.start = make(map[string]*series), now
+	c.seriesLock.Unlock()
+
+	if len(collected) == 0 {
+		return
+	}
+	payload := c.payload(collected, start, now)
+	log.WithField("metrics", len(payload.Metrics)).Debug("New Relic: Sending...")
+	startTime := time.Now()
+	if err := c.send([]nrPayload{payload}); err != nil {
+		log.WithError(err).Error("New Relic: Couldn't send metrics")
+		return
+	}
+	log.WithField("t", time.Since(startTime)).Debug("New Relic: Metrics sent!")
+}
+
+func (c *Collector) payload(collected map[string]*series, start, end time.Time) nrPayload {
+	keys := make([]string, 0, len(collected))
+	for key := range collected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	payload := nrPayload{
+		Common: nrCommon{
+			Timestamp:  start.UnixNano() / int64(time.Millisecond),
+			IntervalMs: int64(end.Sub(start) / time.Millisecond),
+		},
+		Metrics: make([]nrMetric, 0, len(keys)),
+	}
+	for _, key := range keys {
+		s := collected[key]
+		m := nrMetric{Name: c.Config.Namespace + s.metric.Name}
+		if s.tags != nil {
+			m.Attributes = s.tags.CloneTags()
+		}
+		switch s.metric.Type {
+		case stats.Counter:
+			m.Type, m.Value = "count", s.sum
+		case stats.Gauge:
+			m.Type, m.Value = "gauge", s.last
+		case stats.Rate:
+			m.Type, m.Value = "gauge", s.nonzero/s.count
+		case stats.Trend:
+			m.Type, m.Value = "summary", nrSummary{Count: s.count, Sum: s.sum, Min: s.min, Max: s.max}
+		}
+		payload.Metrics = append(payload.Metrics, m)
+	}
+	return payload
+}
+
+// send posts gzipped metrics to the Metric API, which accepts them with a 202.
+func (c *Collector) send(payloads []nrPayload) error {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	if err := json.NewEncoder(gz).Encode(payloads); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.Config.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Api-Key", c.Config.APIKey)
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.Errorf("%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// tagsKey identifies a set of tags, whichever order they're in.
+func tagsKey(tags *stats.SampleTags) string {
+	if tags == nil {
+		return ""
+	}
+	m := tags.CloneTags()
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + m[k] + "\x00")
+	}
+	return b.String()
+}
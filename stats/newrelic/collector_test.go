@@ -0,0 +1,89 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package newrelic
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	var payloads []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("Api-Key"))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		var body []map[string]interface{}
+		require.NoError(t, json.NewDecoder(gz).Decode(&body))
+		payloads = append(payloads, body...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	_, err := New(NewConfig().Apply(Config{URL: srv.URL}))
+	assert.EqualError(t, err, "the New Relic output needs an API key, eg. in K6_NEWRELIC_API_KEY")
+
+	c, err := New(NewConfig().Apply(Config{URL: srv.URL, APIKey: "secret"}))
+	require.NoError(t, err)
+	require.NoError(t, c.Init())
+
+	start := time.Unix(1500000000, 0)
+	c.start = start
+	tags := stats.IntoSampleTags(&map[string]string{"status": "200", "method": "GET"})
+	c.Collect([]stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: tags, Time: start, Value: 1},
+		{Metric: metrics.HTTPReqs, Tags: tags, Time: start, Value: 1},
+		{Metric: metrics.HTTPReqDuration, Tags: tags, Time: start, Value: 10},
+		{Metric: metrics.HTTPReqDuration, Tags: tags, Time: start, Value: 30},
+		{Metric: metrics.VUs, Time: start, Value: 5},
+		{Metric: metrics.VUs, Time: start, Value: 8},
+		{Metric: metrics.Checks, Time: start, Value: 1},
+		{Metric: metrics.Checks, Time: start, Value: 0},
+		{Metric: metrics.Checks, Time: start, Value: 1},
+		{Metric: metrics.Checks, Time: start, Value: 1},
+	})
+	c.commit(start.Add(10 * time.Second))
+	c.commit(start.Add(20 * time.Second))
+
+	require.Len(t, payloads, 1)
+	assert.Equal(t, map[string]interface{}{
+		"timestamp":   float64(1500000000000),
+		"interval.ms": float64(10000),
+	}, payloads[0]["common"])
+	attrs := map[string]interface{}{"status": "200", "method": "GET"}
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "k6.checks", "type": "gauge", "value": 0.75},
+		map[string]interface{}{"name": "k6.http_req_duration", "type": "summary", "attributes": attrs,
+			"value": map[string]interface{}{"count": 2.0, "sum": 40.0, "min": 10.0, "max": 30.0}},
+		map[string]interface{}{"name": "k6.http_reqs", "type": "count", "value": 2.0, "attributes": attrs},
+		map[string]interface{}{"name": "k6.vus", "type": "gauge", "value": 8.0},
+	}, payloads[0]["metrics"])
+}
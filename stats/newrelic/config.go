@@ -0,0 +1,120 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package newrelic
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+)
+
+// DefaultURL is the Metric API's endpoint in the US region; accounts in the EU region use
+// https://metric-api.eu.newrelic.com/metric/v1 instead.
+const DefaultURL = "https://metric-api.newrelic.com/metric/v1"
+
+type ConfigFields struct {
+	// Connection.
+	URL    string `json:"url" envconfig:"NEWRELIC_URL"`
+	APIKey string `json:"apiKey,omitempty" envconfig:"NEWRELIC_API_KEY"`
+
+	// Samples.
+	Namespace    string         `json:"namespace,omitempty" envconfig:"NEWRELIC_NAMESPACE"`
+	PushInterval types.Duration `json:"pushInterval,omitempty" envconfig:"NEWRELIC_PUSH_INTERVAL"`
+}
+
+type Config ConfigFields
+
+// NewConfig returns the defaults; samples are aggregated for 10s, since the Metric API limits
+// how many data points an account can send every minute.
+func NewConfig() *Config {
+	return &Config{
+		URL:          DefaultURL,
+		Namespace:    "k6.",
+		PushInterval: types.Duration(10 * time.Second),
+	}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.URL != "" {
+		c.URL = cfg.URL
+	}
+	if cfg.APIKey != "" {
+		c.APIKey = cfg.APIKey
+	}
+	if cfg.Namespace != "" {
+		c.Namespace = cfg.Namespace
+	}
+	if cfg.PushInterval > 0 {
+		c.PushInterval = cfg.PushInterval
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is optionally the Metric API's URL, followed by
+// options; eg. https://metric-api.eu.newrelic.com/metric/v1?namespace=shop.&push_interval=30s
+// The API key isn't one of them, so it doesn't end up in shell histories; it's set with
+// K6_NEWRELIC_API_KEY instead.
+func (c *Config) UnmarshalText(text []byte) error {
+	s := string(text)
+	query := ""
+	if i := strings.Index(s, "?"); i != -1 {
+		s, query = s[:i], s[i+1:]
+	}
+	if s != "" {
+		c.URL = s
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	for k, vs := range values {
+		switch k {
+		case "namespace":
+			c.Namespace = vs[0]
+		case "push_interval":
+			d, err := time.ParseDuration(vs[0])
+			if err != nil || d <= 0 {
+				return errors.Errorf("push_interval must be a positive duration, not %s", vs[0])
+			}
+			c.PushInterval = types.Duration(d)
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return nil
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
@@ -0,0 +1,287 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pushInterval = 1 * time.Second
+	pushTimeout  = 10 * time.Second
+)
+
+// The quantiles that trends are summarized with.
+var summaryQuantiles = []float64{0, 0.5, 0.9, 0.95, 0.99, 1}
+
+var _ lib.Collector = &Collector{}
+
+// Collector exports metrics, and optionally a span for every HTTP request, to an OpenTelemetry
+// collector with OTLP over HTTP, in its JSON encoding. Samples are aggregated every second:
+// counters become delta sums, gauges and rates gauges, and trends summaries.
+type Collector struct {
+	Config Config
+	Client *http.Client
+
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+	lastPush   time.Time
+}
+
+func New(conf Config) (*Collector, error) {
+	if conf.Endpoint == "" {
+		return nil, errors.New("the OTLP output needs an endpoint")
+	}
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: conf.Insecure}}
+	return &Collector{
+		Config: conf,
+		Client: &http.Client{Transport: transport, Timeout: pushTimeout},
+	}, nil
+}
+
+func (c *Collector) Init() error {
+	c.lastPush = time.Now()
+	return nil
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("OTLP: Running!")
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit()
+		case <-ctx.Done():
+			c.commit()
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+func (c *Collector) Link() string {
+	return c.Config.Endpoint
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+func (c *Collector) commit() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	start, now := c.lastPush, time.Now()
+	c.lastPush = now
+	if len(samples) == 0 {
+		return
+	}
+
+	log.WithField("samples", len(samples)).Debug("OTLP: Exporting metrics...")
+	if err := c.push("/v1/metrics", c.metricsRequest(samples, start, now)); err != nil {
+		log.WithError(err).Error("OTLP: Couldn't export metrics")
+	}
+	if !c.Config.Traces {
+		return
+	}
+	if req := c.tracesRequest(samples); req != nil {
+		if err := c.push("/v1/traces", req); err != nil {
+			log.WithError(err).Error("OTLP: Couldn't export traces")
+		}
+	}
+}
+
+func (c *Collector) push(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.Config.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.Config.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.Errorf("%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (c *Collector) resource() resource {
+	return resource{Attributes: []keyValue{stringAttr("service.name", c.Config.ServiceName)}}
+}
+
+// A series is the samples of a metric with the same tags.
+type series struct {
+	metric *stats.Metric
+	tags   map[string]string
+	sink   stats.Sink
+	last   time.Time
+}
+
+func (c *Collector) metricsRequest(samples []stats.Sample, start, end time.Time) *metricsRequest {
+	all := make(map[string]*series)
+	var keys []string
+	for _, sample := range samples {
+		tags := sample.Tags.CloneTags()
+		key := seriesKey(sample.Metric.Name, tags)
+		s, ok := all[key]
+		if !ok {
+			s = &series{metric: sample.Metric, tags: tags, sink: newSink(sample.Metric.Type)}
+			all[key] = s
+			keys = append(keys, key)
+		}
+		s.sink.Add(sample)
+		if sample.Time.After(s.last) {
+			s.last = sample.Time
+		}
+	}
+	sort.Strings(keys)
+
+	byName := make(map[string]*metric)
+	var names []string
+	for _, key := range keys {
+		s := all[key]
+		m, ok := byName[s.metric.Name]
+		if !ok {
+			m = newMetric(s.metric)
+			byName[s.metric.Name] = m
+			names = append(names, s.metric.Name)
+		}
+		m.add(s, start, end)
+	}
+
+	metrics := make([]*metric, len(names))
+	for i, name := range names {
+		metrics[i] = byName[name]
+	}
+	return &metricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     c.resource(),
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: "k6"}, Metrics: metrics}},
+	}}}
+}
+
+// tracesRequest returns a span for every HTTP request, which ended at the time of its
+// http_req_duration sample, or nil if there were no requests.
+func (c *Collector) tracesRequest(samples []stats.Sample) *tracesRequest {
+	var spans []span
+	for _, sample := range samples {
+		if sample.Metric != metrics.HTTPReqDuration {
+			continue
+		}
+		tags := sample.Tags.CloneTags()
+		end := sample.Time
+		startTime := end.Add(-time.Duration(sample.Value * float64(time.Millisecond)))
+		sp := span{
+			TraceID:           randomID(16),
+			SpanID:            randomID(8),
+			Name:              "HTTP " + tags["method"],
+			Kind:              spanKindClient,
+			StartTimeUnixNano: nanos(startTime),
+			EndTimeUnixNano:   nanos(end),
+			Attributes:        attributes(tags),
+			Status:            spanStatus{Code: statusOK},
+		}
+		if status, err := strconv.Atoi(tags["status"]); tags["error"] != "" || (err == nil && (status == 0 || status >= 400)) {
+			sp.Status = spanStatus{Code: statusError, Message: tags["error"]}
+		}
+		spans = append(spans, sp)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return &tracesRequest{ResourceSpans: []resourceSpans{{
+		Resource:   c.resource(),
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "k6"}, Spans: spans}},
+	}}}
+}
+
+func newSink(typ stats.MetricType) stats.Sink {
+	switch typ {
+	case stats.Counter:
+		return &stats.CounterSink{}
+	case stats.Gauge:
+		return &stats.GaugeSink{}
+	case stats.Trend:
+		return &stats.TrendSink{}
+	case stats.Rate:
+		return &stats.RateSink{}
+	default:
+		return stats.DummySink{}
+	}
+}
+
+func seriesKey(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + tags[k])
+	}
+	return b.String()
+}
+
+func randomID(n int) string {
+	id := make([]byte, n)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
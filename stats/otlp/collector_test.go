@@ -0,0 +1,124 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	var mu sync.Mutex
+	payloads := map[string][]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "abc", r.Header.Get("X-Token"))
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		mu.Lock()
+		payloads[r.URL.Path] = append(payloads[r.URL.Path], payload)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	config := NewConfig().Apply(Config{Endpoint: srv.URL, Traces: true, Headers: map[string]string{"X-Token": "abc"}})
+	c, err := New(config)
+	require.NoError(t, err)
+	require.NoError(t, c.Init())
+
+	now := time.Now()
+	tags := stats.IntoSampleTags(&map[string]string{"method": "GET", "status": "200", "url": "http://example.com"})
+	failed := stats.IntoSampleTags(&map[string]string{"method": "POST", "status": "503", "url": "http://example.com"})
+	c.Collect([]stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: tags, Time: now, Value: 1},
+		{Metric: metrics.HTTPReqs, Tags: tags, Time: now, Value: 1},
+		{Metric: metrics.HTTPReqs, Tags: failed, Time: now, Value: 1},
+		{Metric: metrics.HTTPReqDuration, Tags: tags, Time: now, Value: 10},
+		{Metric: metrics.HTTPReqDuration, Tags: tags, Time: now, Value: 30},
+		{Metric: metrics.HTTPReqDuration, Tags: failed, Time: now, Value: 20},
+		{Metric: metrics.VUs, Tags: stats.IntoSampleTags(&map[string]string{}), Time: now, Value: 5},
+	})
+	c.commit()
+
+	require.Len(t, payloads["/v1/metrics"], 1)
+	var req metricsRequest
+	roundTrip(t, payloads["/v1/metrics"][0], &req)
+	require.Len(t, req.ResourceMetrics, 1)
+	assert.Equal(t, []keyValue{stringAttr("service.name", "k6")}, req.ResourceMetrics[0].Resource.Attributes)
+
+	byName := map[string]*metric{}
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		byName[m.Name] = m
+	}
+	require.Len(t, byName, 3)
+
+	reqs := byName["http_reqs"].Sum
+	require.NotNil(t, reqs)
+	assert.True(t, reqs.IsMonotonic)
+	assert.Equal(t, aggregationTemporalityDelta, reqs.AggregationTemporality)
+	require.Len(t, reqs.DataPoints, 2)
+	assert.Equal(t, 2.0, reqs.DataPoints[0].AsDouble)
+	assert.Equal(t, 1.0, reqs.DataPoints[1].AsDouble)
+
+	duration := byName["http_req_duration"]
+	assert.Equal(t, "ms", duration.Unit)
+	require.Len(t, duration.Summary.DataPoints, 2)
+	dp := duration.Summary.DataPoints[0]
+	assert.Equal(t, "2", dp.Count)
+	assert.Equal(t, 40.0, dp.Sum)
+	assert.Equal(t, quantileValue{Quantile: 0.5, Value: 20}, dp.QuantileValues[1])
+
+	assert.Equal(t, 5.0, byName["vus"].Gauge.DataPoints[0].AsDouble)
+
+	require.Len(t, payloads["/v1/traces"], 1)
+	var traces tracesRequest
+	roundTrip(t, payloads["/v1/traces"][0], &traces)
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 3)
+	assert.Equal(t, "HTTP GET", spans[0].Name)
+	assert.Equal(t, spanKindClient, spans[0].Kind)
+	assert.Len(t, spans[0].TraceID, 32)
+	assert.Len(t, spans[0].SpanID, 16)
+	assert.Equal(t, nanos(now.Add(-10*time.Millisecond)), spans[0].StartTimeUnixNano)
+	assert.Equal(t, nanos(now), spans[0].EndTimeUnixNano)
+	assert.Equal(t, spanStatus{Code: statusOK}, spans[0].Status)
+	assert.Equal(t, spanStatus{Code: statusError}, spans[2].Status)
+
+	t.Run("Empty", func(t *testing.T) {
+		c.commit()
+		assert.Len(t, payloads["/v1/metrics"], 1)
+	})
+}
+
+func roundTrip(t *testing.T, payload map[string]interface{}, v interface{}) {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
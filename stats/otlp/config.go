@@ -0,0 +1,124 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type ConfigFields struct {
+	// Connection.
+	Endpoint string            `json:"endpoint" envconfig:"OTLP_ENDPOINT"`
+	Headers  map[string]string `json:"headers,omitempty" envconfig:"OTLP_HEADERS"`
+	Insecure bool              `json:"insecure,omitempty" envconfig:"OTLP_INSECURE"`
+
+	// Telemetry.
+	ServiceName string `json:"serviceName,omitempty" envconfig:"OTLP_SERVICE_NAME"`
+	Traces      bool   `json:"traces,omitempty" envconfig:"OTLP_TRACES"`
+}
+
+type Config ConfigFields
+
+func NewConfig() *Config {
+	return &Config{Endpoint: "http://localhost:4318", ServiceName: "k6"}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.Endpoint != "" {
+		c.Endpoint = cfg.Endpoint
+	}
+	if len(cfg.Headers) > 0 {
+		c.Headers = cfg.Headers
+	}
+	if cfg.Insecure {
+		c.Insecure = cfg.Insecure
+	}
+	if cfg.ServiceName != "" {
+		c.ServiceName = cfg.ServiceName
+	}
+	if cfg.Traces {
+		c.Traces = cfg.Traces
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is the collector's URL; eg.
+// https://collector:4318?traces=true&header=Authorization:Bearer%20abc
+func (c *Config) UnmarshalText(text []byte) error {
+	u, err := url.Parse(string(text))
+	if err != nil {
+		return err
+	}
+	if u.Host != "" {
+		c.Endpoint = u.Scheme + "://" + u.Host + strings.TrimSuffix(u.Path, "/")
+	}
+	for k, vs := range u.Query() {
+		switch k {
+		case "insecure", "traces":
+			var value bool
+			switch vs[0] {
+			case "", "false":
+			case "true":
+				value = true
+			default:
+				return errors.Errorf("%s must be true or false, not %s", k, vs[0])
+			}
+			if k == "insecure" {
+				c.Insecure = value
+			} else {
+				c.Traces = value
+			}
+		case "service_name":
+			c.ServiceName = vs[0]
+		case "header":
+			for _, v := range vs {
+				parts := strings.SplitN(v, ":", 2)
+				if len(parts) != 2 {
+					return errors.Errorf("header must be written as name:value, not %s", v)
+				}
+				if c.Headers == nil {
+					c.Headers = make(map[string]string)
+				}
+				c.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return nil
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
@@ -0,0 +1,58 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigText(t *testing.T) {
+	testdata := map[string]struct {
+		Config Config
+		Err    string
+	}{
+		"":                             {Config{}, ""},
+		"http://localhost:4318":        {Config{Endpoint: "http://localhost:4318"}, ""},
+		"https://collector/otlp/":      {Config{Endpoint: "https://collector/otlp"}, ""},
+		"http://c:4318?traces=true":    {Config{Endpoint: "http://c:4318", Traces: true}, ""},
+		"http://c:4318?insecure=true":  {Config{Endpoint: "http://c:4318", Insecure: true}, ""},
+		"http://c:4318?insecure=ture":  {Config{}, "insecure must be true or false, not ture"},
+		"?service_name=shop":           {Config{ServiceName: "shop"}, ""},
+		"?header=Authorization:%20abc": {Config{Headers: map[string]string{"Authorization": "abc"}}, ""},
+		"?header=a:1&header=b:2":       {Config{Headers: map[string]string{"a": "1", "b": "2"}}, ""},
+		"?header=a":                    {Config{}, "header must be written as name:value, not a"},
+		"?foo=bar":                     {Config{}, "unknown query parameter: foo"},
+	}
+	for str, data := range testdata {
+		t.Run(str, func(t *testing.T) {
+			var config Config
+			err := config.UnmarshalText([]byte(str))
+			if data.Err != "" {
+				assert.EqualError(t, err, data.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.Config, config)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+)
+
+// The types below are the JSON encoding of the OTLP protobuf messages, of which only the fields
+// that k6 has data for are set. Like in the protobuf JSON mapping, 64 bit integers are strings.
+
+const (
+	aggregationTemporalityDelta = 1
+
+	spanKindClient = 3
+
+	statusOK    = 1
+	statusError = 2
+)
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func stringAttr(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: value}}
+}
+
+// attributes returns the attributes for sample tags, sorted by key.
+func attributes(tags map[string]string) []keyValue {
+	attrs := make([]keyValue, 0, len(tags))
+	for k, v := range tags {
+		attrs = append(attrs, stringAttr(k, v))
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type metricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope     `json:"scope"`
+	Metrics []*metric `json:"metrics"`
+}
+
+type metric struct {
+	Name    string   `json:"name"`
+	Unit    string   `json:"unit,omitempty"`
+	Sum     *sum     `json:"sum,omitempty"`
+	Gauge   *gauge   `json:"gauge,omitempty"`
+	Summary *summary `json:"summary,omitempty"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type summary struct {
+	DataPoints []summaryDataPoint `json:"dataPoints"`
+}
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsDouble          float64    `json:"asDouble"`
+}
+
+type summaryDataPoint struct {
+	Attributes        []keyValue      `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	QuantileValues    []quantileValue `json:"quantileValues"`
+}
+
+type quantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+func newMetric(m *stats.Metric) *metric {
+	out := &metric{Name: m.Name}
+	switch m.Contains {
+	case stats.Time:
+		out.Unit = "ms"
+	case stats.Data:
+		out.Unit = "By"
+	case stats.DataRate:
+		out.Unit = "By/s"
+	}
+	switch m.Type {
+	case stats.Counter:
+		out.Sum = &sum{AggregationTemporality: aggregationTemporalityDelta, IsMonotonic: true}
+	case stats.Trend:
+		out.Summary = &summary{}
+	default:
+		out.Gauge = &gauge{}
+	}
+	return out
+}
+
+// add adds a data point for a series, which covers the time since the last push.
+func (m *metric) add(s *series, start, end time.Time) {
+	attrs := attributes(s.tags)
+	switch sink := s.sink.(type) {
+	case *stats.CounterSink:
+		m.Sum.DataPoints = append(m.Sum.DataPoints, numberDataPoint{
+			Attributes: attrs, StartTimeUnixNano: nanos(start), TimeUnixNano: nanos(end), AsDouble: sink.Value,
+		})
+	case *stats.GaugeSink:
+		m.Gauge.DataPoints = append(m.Gauge.DataPoints, numberDataPoint{
+			Attributes: attrs, TimeUnixNano: nanos(s.last), AsDouble: sink.Value,
+		})
+	case *stats.RateSink:
+		m.Gauge.DataPoints = append(m.Gauge.DataPoints, numberDataPoint{
+			Attributes: attrs, TimeUnixNano: nanos(s.last), AsDouble: float64(sink.Trues) / float64(sink.Total),
+		})
+	case *stats.TrendSink:
+		dp := summaryDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: nanos(start),
+			TimeUnixNano:      nanos(end),
+			Count:             strconv.FormatUint(sink.Count, 10),
+			Sum:               sink.Sum,
+		}
+		for _, q := range summaryQuantiles {
+			dp.QuantileValues = append(dp.QuantileValues, quantileValue{Quantile: q, Value: sink.P(q)})
+		}
+		m.Summary.DataPoints = append(m.Summary.DataPoints, dp)
+	}
+}
+
+type tracesRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes"`
+	Status            spanStatus `json:"status"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
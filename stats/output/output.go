@@ -0,0 +1,159 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package output is the API for outputs that aren't part of k6 itself. An output registers a
+// constructor under a name from an init() function, and is compiled into k6 by importing its
+// package from main; --out name=arg then creates it like any built-in output.
+package output
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// Params are what an output is created from.
+type Params struct {
+	// OutputType is the name the output was registered as, and ConfigArgument what came after
+	// the = in --out name=arg, if anything.
+	OutputType     string
+	ConfigArgument string
+
+	FS            afero.Fs
+	Source        *lib.SourceData
+	ScriptOptions lib.Options
+	Version       string
+}
+
+// An Output receives the samples of a test run.
+type Output interface {
+	// Description is shown to the user next to the output's name when the test starts, eg. a
+	// link to the results.
+	Description() string
+
+	// Start is called before the test starts; anything slow, like connecting, belongs here
+	// rather than in the constructor.
+	Start() error
+
+	// AddMetricSamples receives samples while the test runs. It's never called concurrently,
+	// but should return quickly; writing the samples out belongs in a goroutine of its own.
+	AddMetricSamples(samples []stats.Sample)
+
+	// Stop is called once the test has finished and no more samples will be added; it should
+	// flush anything that's still buffered.
+	Stop() error
+}
+
+// WithRunStatusUpdates is an Output that wants to know when the test starts running and how
+// it ended.
+type WithRunStatusUpdates interface {
+	Output
+	SetRunStatus(status lib.RunStatus)
+}
+
+// A Constructor creates an output.
+type Constructor func(params Params) (Output, error)
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]Constructor)
+)
+
+// Register makes an output available as --out name. It panics if the name is taken, so it's
+// meant to be called from an init() function; the names of the built-in outputs always win.
+func Register(name string, constructor Constructor) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("an output named '%s' is already registered", name))
+	}
+	registry[name] = constructor
+}
+
+// Get returns the constructor registered as name, if there is one.
+func Get(name string) (Constructor, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	constructor, ok := registry[name]
+	return constructor, ok
+}
+
+// Names returns the names of the registered outputs, sorted.
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collector runs an Output as a lib.Collector, which is what the engine works with.
+type collector struct {
+	out Output
+}
+
+var _ lib.RunStatusUpdater = &collector{}
+
+// AsCollector returns a collector that feeds an output.
+func AsCollector(out Output) lib.Collector {
+	return &collector{out: out}
+}
+
+func (c *collector) Init() error {
+	return c.out.Start()
+}
+
+func (c *collector) Run(ctx context.Context) {
+	<-ctx.Done()
+	if err := c.out.Stop(); err != nil {
+		log.WithError(err).WithField("output", c.out.Description()).Error("Output: Couldn't stop")
+	}
+}
+
+func (c *collector) Collect(samples []stats.Sample) {
+	c.out.AddMetricSamples(samples)
+}
+
+func (c *collector) Link() string {
+	return c.out.Description()
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+func (c *collector) SetRunStatus(status lib.RunStatus) {
+	if out, ok := c.out.(WithRunStatusUpdates); ok {
+		out.SetRunStatus(status)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package output
+
+import (
+	"context"
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testOutput struct {
+	params   Params
+	started  bool
+	stopped  bool
+	samples  []stats.Sample
+	statuses []lib.RunStatus
+}
+
+func (o *testOutput) Description() string                { return "test output (" + o.params.ConfigArgument + ")" }
+func (o *testOutput) Start() error                       { o.started = true; return nil }
+func (o *testOutput) AddMetricSamples(ss []stats.Sample) { o.samples = append(o.samples, ss...) }
+func (o *testOutput) Stop() error                        { o.stopped = true; return nil }
+func (o *testOutput) SetRunStatus(status lib.RunStatus)  { o.statuses = append(o.statuses, status) }
+
+func TestRegister(t *testing.T) {
+	var created *testOutput
+	Register("test-register", func(params Params) (Output, error) {
+		if params.ConfigArgument == "bad" {
+			return nil, errors.New("bad argument")
+		}
+		created = &testOutput{params: params}
+		return created, nil
+	})
+	assert.Contains(t, Names(), "test-register")
+	assert.Panics(t, func() { Register("test-register", nil) })
+
+	_, ok := Get("test-missing")
+	assert.False(t, ok)
+
+	constructor, ok := Get("test-register")
+	require.True(t, ok)
+	_, err := constructor(Params{ConfigArgument: "bad"})
+	assert.EqualError(t, err, "bad argument")
+
+	out, err := constructor(Params{OutputType: "test-register", ConfigArgument: "arg"})
+	require.NoError(t, err)
+	assert.Equal(t, "test-register", created.params.OutputType)
+	assert.Equal(t, "test output (arg)", out.Description())
+}
+
+func TestAsCollector(t *testing.T) {
+	out := &testOutput{params: Params{ConfigArgument: "arg"}}
+	c := AsCollector(out)
+	assert.Equal(t, "test output (arg)", c.Link())
+	assert.Empty(t, c.GetRequiredSystemTags())
+
+	require.NoError(t, c.Init())
+	assert.True(t, out.started)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { c.Run(ctx); close(done) }()
+
+	c.(lib.RunStatusUpdater).SetRunStatus(lib.RunStatusRunning)
+	sample := stats.Sample{Metric: stats.New("my_metric", stats.Counter), Value: 1}
+	c.Collect([]stats.Sample{sample})
+	c.(lib.RunStatusUpdater).SetRunStatus(lib.RunStatusFinished)
+	cancel()
+	<-done
+
+	assert.True(t, out.stopped)
+	assert.Equal(t, []stats.Sample{sample}, out.samples)
+	assert.Equal(t, []lib.RunStatus{lib.RunStatusRunning, lib.RunStatusFinished}, out.statuses)
+}
@@ -0,0 +1,199 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package results
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// A Run has the summary values of the metrics of a test run, by metric name, the way they're
+// exported with --summary-export; it's what's compared between two runs.
+type Run map[string]RunMetric
+
+// RunMetric is a metric of a Run, with its values by stat, eg. "p(95)".
+type RunMetric struct {
+	Type     stats.MetricType
+	Contains stats.ValueType
+	Values   map[string]float64
+}
+
+// KeyStats are the stats compared for every metric of a type, along with the ones that have a
+// tolerance; gauges change too much over a test to compare their end values.
+var KeyStats = map[stats.MetricType][]string{
+	stats.Counter: {"count", "rate"},
+	stats.Rate:    {"rate"},
+	stats.Trend:   {"avg", "p(95)"},
+}
+
+// DefaultTolerances are the tolerances regressions are flagged with, if none are given.
+var DefaultTolerances = []string{
+	"http_req_duration.p(95)=+10%",
+	"iteration_duration.p(95)=+10%",
+	"http_req_failed.rate=+0.01",
+	"checks.rate=-0.01",
+}
+
+// A Tolerance is how much a stat of a metric may change from a baseline run before it's a
+// regression. Positive tolerances flag increases beyond them, negative ones decreases; either by
+// a fraction of the baseline's value, or by an amount.
+type Tolerance struct {
+	Metric, Stat string
+	Change       float64
+	Relative     bool
+}
+
+// ParseTolerance parses a tolerance written as `metric.stat=change`, eg. `http_req_duration.p(95)=+10%`
+// or `checks.rate=-0.01`; the metric may be a submetric, like `http_req_duration{status:200}`.
+func ParseTolerance(s string) (Tolerance, error) {
+	invalid := errors.Errorf("invalid tolerance %s, expected metric.stat=+change or metric.stat=-change", s)
+
+	// Submetric conditions may have dots and equals signs of their own.
+	from := 0
+	if end := strings.Index(s, "}"); strings.Contains(s, "{") && end >= 0 {
+		from = end
+	}
+	dot := strings.Index(s[from:], ".")
+	eq := strings.Index(s[from:], "=")
+	if dot < 0 || eq < dot {
+		return Tolerance{}, invalid
+	}
+	t := Tolerance{
+		Metric: strings.TrimSpace(s[:from+dot]),
+		Stat:   strings.TrimSpace(s[from+dot+1 : from+eq]),
+	}
+	change := strings.TrimSpace(s[from+eq+1:])
+	if t.Metric == "" || t.Stat == "" || !strings.HasPrefix(change, "+") && !strings.HasPrefix(change, "-") {
+		return Tolerance{}, invalid
+	}
+	if strings.HasSuffix(change, "%") {
+		t.Relative = true
+		change = strings.TrimSuffix(change, "%")
+	}
+	var err error
+	if t.Change, err = strconv.ParseFloat(change, 64); err != nil {
+		return Tolerance{}, invalid
+	}
+	if t.Relative {
+		t.Change /= 100
+	}
+	return t, nil
+}
+
+func (t Tolerance) String() string {
+	if t.Relative {
+		return fmt.Sprintf("%s.%s=%+g%%", t.Metric, t.Stat, t.Change*100)
+	}
+	return fmt.Sprintf("%s.%s=%+g", t.Metric, t.Stat, t.Change)
+}
+
+// regressed returns whether a stat changed beyond the tolerance.
+func (t Tolerance) regressed(baseline, current float64) bool {
+	limit := t.Change
+	if t.Relative {
+		limit *= math.Abs(baseline)
+	}
+	if t.Change >= 0 {
+		return current-baseline > limit
+	}
+	return current-baseline < limit
+}
+
+// A Delta is how a stat of a metric changed from the baseline run to the current one.
+type Delta struct {
+	Metric, Stat      string
+	Baseline, Current float64
+
+	// The stat's tolerance if it has one, and whether the change is beyond it.
+	Tolerance *Tolerance
+	Regressed bool
+}
+
+// Change returns the change relative to the baseline's value; it's infinite if the baseline's
+// value is 0 and the current one isn't.
+func (d Delta) Change() float64 {
+	if d.Baseline == 0 {
+		if d.Current == 0 {
+			return 0
+		}
+		return math.Inf(int(math.Copysign(1, d.Current)))
+	}
+	return (d.Current - d.Baseline) / math.Abs(d.Baseline)
+}
+
+// Compare works out how the key stats of the metrics both runs have, and those with tolerances,
+// changed from the baseline to the current run, by metric and stat name.
+func Compare(baseline, current Run, tolerances []Tolerance) []Delta {
+	byStat := make(map[string]map[string]*Tolerance)
+	for i, t := range tolerances {
+		if byStat[t.Metric] == nil {
+			byStat[t.Metric] = make(map[string]*Tolerance)
+		}
+		byStat[t.Metric][t.Stat] = &tolerances[i]
+	}
+
+	names := make([]string, 0, len(baseline))
+	for name := range baseline {
+		if _, ok := current[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var deltas []Delta
+	for _, name := range names {
+		bm, cm := baseline[name], current[name]
+		statNames := append([]string{}, KeyStats[bm.Type]...)
+		for stat := range byStat[name] {
+			if !containsString(statNames, stat) {
+				statNames = append(statNames, stat)
+			}
+		}
+		for _, stat := range statNames {
+			bv, bok := bm.Values[stat]
+			cv, cok := cm.Values[stat]
+			if !bok || !cok {
+				continue
+			}
+			d := Delta{Metric: name, Stat: stat, Baseline: bv, Current: cv, Tolerance: byStat[name][stat]}
+			if d.Tolerance != nil {
+				d.Regressed = d.Tolerance.regressed(bv, cv)
+			}
+			deltas = append(deltas, d)
+		}
+	}
+	return deltas
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package results
+
+import (
+	"math"
+	"testing"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTolerance(t *testing.T) {
+	testdata := map[string]Tolerance{
+		"http_req_duration.p(95)=+10%":            {Metric: "http_req_duration", Stat: "p(95)", Change: 0.1, Relative: true},
+		"http_req_duration.p(99.9)=+5%":           {Metric: "http_req_duration", Stat: "p(99.9)", Change: 0.05, Relative: true},
+		"checks.rate = -0.01":                     {Metric: "checks", Stat: "rate", Change: -0.01},
+		`http_req_duration{name:"a.b=c"}.avg=+20`: {Metric: `http_req_duration{name:"a.b=c"}`, Stat: "avg", Change: 20},
+	}
+	for s, expected := range testdata {
+		tol, err := ParseTolerance(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, tol, s)
+		}
+	}
+	assert.Equal(t, "http_req_duration.p(95)=+10%", testdata["http_req_duration.p(95)=+10%"].String())
+	assert.Equal(t, "checks.rate=-0.01", testdata["checks.rate = -0.01"].String())
+
+	for _, s := range []string{"checks=+1", "checks.rate", "checks.rate=1", ".rate=+1", "checks.=+1", "checks.rate=+a%"} {
+		_, err := ParseTolerance(s)
+		assert.EqualError(t, err, "invalid tolerance "+s+", expected metric.stat=+change or metric.stat=-change", s)
+	}
+
+	for _, s := range DefaultTolerances {
+		_, err := ParseTolerance(s)
+		assert.NoError(t, err, s)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := Run{
+		"http_req_duration": {Type: stats.Trend, Contains: stats.Time, Values: map[string]float64{"avg": 100, "p(95)": 200, "max": 500}},
+		"checks":            {Type: stats.Rate, Values: map[string]float64{"rate": 0.99, "passes": 99, "fails": 1}},
+		"http_reqs":         {Type: stats.Counter, Values: map[string]float64{"count": 1000, "rate": 0}},
+		"vus":               {Type: stats.Gauge, Values: map[string]float64{"value": 10}},
+		"only_baseline":     {Type: stats.Counter, Values: map[string]float64{"count": 1, "rate": 1}},
+	}
+	current := Run{
+		"http_req_duration": {Type: stats.Trend, Contains: stats.Time, Values: map[string]float64{"avg": 105, "p(95)": 230, "max": 400}},
+		"checks":            {Type: stats.Rate, Values: map[string]float64{"rate": 0.97, "passes": 97, "fails": 3}},
+		"http_reqs":         {Type: stats.Counter, Values: map[string]float64{"count": 900, "rate": 5}},
+		"vus":               {Type: stats.Gauge, Values: map[string]float64{"value": 20}},
+	}
+	var tolerances []Tolerance
+	for _, s := range []string{"http_req_duration.p(95)=+10%", "http_req_duration.max=+0%", "checks.rate=-0.01", "http_reqs.count=-5%"} {
+		tol, err := ParseTolerance(s)
+		require.NoError(t, err)
+		tolerances = append(tolerances, tol)
+	}
+
+	deltas := Compare(baseline, current, tolerances)
+	type row struct {
+		name      string
+		regressed bool
+		tolerance bool
+	}
+	rows := make([]row, len(deltas))
+	for i, d := range deltas {
+		rows[i] = row{d.Metric + "." + d.Stat, d.Regressed, d.Tolerance != nil}
+	}
+	assert.Equal(t, []row{
+		{"checks.rate", true, true},
+		{"http_req_duration.avg", false, false},
+		{"http_req_duration.p(95)", true, true},
+		{"http_req_duration.max", false, true},
+		{"http_reqs.count", true, true},
+		{"http_reqs.rate", false, false},
+	}, rows)
+
+	assert.InDelta(t, 0.15, deltas[2].Change(), 1e-9)
+	assert.InDelta(t, -0.1, deltas[4].Change(), 1e-9)
+	assert.True(t, math.IsInf(deltas[5].Change(), 1))
+	assert.Equal(t, 0.0, Delta{}.Change())
+}
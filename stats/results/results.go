@@ -0,0 +1,258 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package results adds the samples a test run's output saved back up into the metrics, checks
+// and threshold results the end-of-test summary of the run had, so they can be looked at again,
+// or differently, without running the test again.
+package results
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Results are the metrics of a test run, added up from its samples.
+type Results struct {
+	Root           *lib.Group
+	Metrics        map[string]*stats.Metric
+	GroupDurations map[string]*stats.Metric
+
+	// The metrics of every scenario that the samples are tagged with.
+	Scenarios map[string]map[string]*stats.Metric
+
+	// Times of the first and the last of the samples; the run is taken to have lasted in between.
+	First, Last time.Time
+
+	thresholds map[string]stats.Thresholds
+	submetrics map[string][]*stats.Submetric
+	filter     *stats.Submetric
+}
+
+// New returns empty Results that run thresholds on the metrics, and submetrics, they're set for.
+// Only samples whose tags match a filter are added, if there's one; it's written like the
+// conditions of a submetric, eg. `status:200,scenario!=warmup`.
+func New(thresholds map[string]stats.Thresholds, filter string) (*Results, error) {
+	root, err := lib.NewGroup("", nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &Results{
+		Root:           root,
+		Metrics:        make(map[string]*stats.Metric),
+		GroupDurations: make(map[string]*stats.Metric),
+		Scenarios:      make(map[string]map[string]*stats.Metric),
+		thresholds:     make(map[string]stats.Thresholds, len(thresholds)),
+		submetrics:     make(map[string][]*stats.Submetric),
+	}
+	if err := metrics.ValidateThresholds(thresholds); err != nil {
+		return nil, err
+	}
+	for name, ths := range thresholds {
+		ths.Metrics = r.metricSink
+		r.thresholds[name] = ths
+		if !strings.Contains(name, "{") {
+			continue
+		}
+		parent, sm, err := stats.NewSubmetric(name)
+		if err != nil {
+			return nil, err
+		}
+		r.submetrics[parent] = append(r.submetrics[parent], sm)
+	}
+	if filter != "" {
+		if _, r.filter, err = stats.NewSubmetric("filter{" + filter + "}"); err != nil {
+			return nil, errors.Errorf("invalid filter %s: %s", filter,
+				strings.TrimPrefix(err.Error(), "invalid submetric filter{"+filter+"}: "))
+		}
+	}
+	return r, nil
+}
+
+// Add adds a sample to the metrics it's a part of, unless the filter leaves it out.
+func (r *Results) Add(sample stats.Sample) error {
+	if r.filter != nil && !r.filter.Matches(sample.Tags) {
+		return nil
+	}
+	if r.First.IsZero() || sample.Time.Before(r.First) {
+		r.First = sample.Time
+	}
+	if sample.Time.After(r.Last) {
+		r.Last = sample.Time
+	}
+
+	m, ok := r.Metrics[sample.Metric.Name]
+	if !ok {
+		m = stats.New(sample.Metric.Name, sample.Metric.Type, sample.Metric.Contains)
+		m.Thresholds = r.thresholds[m.Name]
+		if err := m.Thresholds.Validate(m.Type); err != nil {
+			return errors.Wrapf(err, "invalid thresholds on %s", m.Name)
+		}
+		m.Submetrics = r.submetrics[m.Name]
+		r.Metrics[m.Name] = m
+	}
+	m.Sink.Add(sample)
+
+	for _, sm := range m.Submetrics {
+		if !sm.Matches(sample.Tags) {
+			continue
+		}
+		if sm.Metric == nil {
+			sm.Metric = stats.New(sm.Name, m.Type, m.Contains)
+			sm.Metric.Sub = *sm
+			sm.Metric.Thresholds = r.thresholds[sm.Name]
+			if err := sm.Metric.Thresholds.Validate(m.Type); err != nil {
+				return errors.Wrapf(err, "invalid thresholds on %s", sm.Name)
+			}
+			r.Metrics[sm.Name] = sm.Metric
+		}
+		sm.Metric.Sink.Add(sample)
+	}
+
+	if name, ok := sample.Tags.Get("scenario"); ok {
+		scMetrics, ok := r.Scenarios[name]
+		if !ok {
+			scMetrics = make(map[string]*stats.Metric)
+			r.Scenarios[name] = scMetrics
+		}
+		scm, ok := scMetrics[m.Name]
+		if !ok {
+			scm = stats.New(m.Name, m.Type, m.Contains)
+			scMetrics[m.Name] = scm
+		}
+		scm.Sink.Add(sample)
+	}
+
+	switch m.Name {
+	case metrics.GroupDuration.Name:
+		path, ok := sample.Tags.Get("group")
+		if !ok {
+			break
+		}
+		if _, err := r.group(path); err != nil {
+			return err
+		}
+		gd, ok := r.GroupDurations[path]
+		if !ok {
+			gd = stats.New(m.Name, m.Type, m.Contains)
+			r.GroupDurations[path] = gd
+		}
+		gd.Sink.Add(sample)
+	case metrics.Checks.Name:
+		name, ok := sample.Tags.Get("check")
+		if !ok {
+			break
+		}
+		path, _ := sample.Tags.Get("group")
+		group, err := r.group(path)
+		if err != nil {
+			return err
+		}
+		check, err := group.Check(name)
+		if err != nil {
+			return err
+		}
+		if sample.Value != 0 {
+			check.Passes++
+		} else {
+			check.Fails++
+		}
+	}
+	return nil
+}
+
+// group returns the group with a path, like `::login::submit`, creating it and its parents.
+func (r *Results) group(path string) (*lib.Group, error) {
+	group := r.Root
+	if path == "" {
+		return group, nil
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(path, lib.GroupSeparator), lib.GroupSeparator) {
+		var err error
+		if group, err = group.Group(name); err != nil {
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// Duration returns how long the run lasted, going by its samples.
+func (r *Results) Duration() time.Duration {
+	if r.First.IsZero() {
+		return 0
+	}
+	return r.Last.Sub(r.First)
+}
+
+// RunThresholds evaluates the thresholds on the metrics as they are at the end of the run,
+// marking the metrics whose thresholds failed as tainted, and returns whether all of them passed.
+func (r *Results) RunThresholds() (bool, error) {
+	names := make([]string, 0, len(r.Metrics))
+	for name := range r.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t := r.Duration()
+	passed := true
+	for _, name := range names {
+		m := r.Metrics[name]
+		if len(m.Thresholds.Thresholds) == 0 {
+			continue
+		}
+		succ, err := m.Thresholds.Run(m.Sink, t)
+		if err != nil {
+			return false, errors.Wrapf(err, "threshold of %s", name)
+		}
+		m.Tainted = null.BoolFrom(!succ)
+		if !succ {
+			passed = false
+		}
+	}
+	return passed, nil
+}
+
+// MissingThresholds returns the names of the metrics that have thresholds but no samples, which
+// the thresholds can't be evaluated on.
+func (r *Results) MissingThresholds() []string {
+	var missing []string
+	for name := range r.thresholds {
+		if _, ok := r.Metrics[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// metricSink returns the sink of a metric for thresholds that refer to it, or nil if the metric
+// has had no samples.
+func (r *Results) metricSink(name string) stats.Sink {
+	if m, ok := r.Metrics[name]; ok {
+		return m.Sink
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package results
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResults(t *testing.T) {
+	tags := func(kv ...string) *stats.SampleTags {
+		m := make(map[string]string, len(kv)/2)
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return stats.IntoSampleTags(&m)
+	}
+	start := time.Unix(1500000000, 0)
+	samples := []stats.Sample{
+		{Metric: metrics.HTTPReqDuration, Time: start, Value: 100, Tags: tags("status", "200", "scenario", "browse")},
+		{Metric: metrics.HTTPReqDuration, Time: start.Add(1 * time.Second), Value: 300, Tags: tags("status", "500", "scenario", "browse")},
+		{Metric: metrics.HTTPReqDuration, Time: start.Add(2 * time.Second), Value: 200, Tags: tags("status", "200", "scenario", "api")},
+		{Metric: metrics.Checks, Time: start.Add(3 * time.Second), Value: 1, Tags: tags("check", "ok", "group", "::login::submit", "scenario", "browse")},
+		{Metric: metrics.Checks, Time: start.Add(3 * time.Second), Value: 0, Tags: tags("check", "ok", "group", "::login::submit", "scenario", "browse")},
+		{Metric: metrics.Checks, Time: start.Add(3 * time.Second), Value: 1, Tags: tags("check", "top", "group", "", "scenario", "api")},
+		{Metric: metrics.GroupDuration, Time: start.Add(4 * time.Second), Value: 50, Tags: tags("group", "::login", "scenario", "browse")},
+	}
+
+	thresholds := func(t *testing.T, ths map[string][]string) map[string]stats.Thresholds {
+		res := make(map[string]stats.Thresholds, len(ths))
+		for name, sources := range ths {
+			th, err := stats.NewThresholds(sources)
+			require.NoError(t, err)
+			res[name] = th
+		}
+		return res
+	}
+
+	t.Run("All", func(t *testing.T) {
+		r, err := New(thresholds(t, map[string][]string{
+			"http_req_duration":             {"max<250"},
+			"http_req_duration{status:200}": {"max<250", "avg < metric('checks').rate * 1000"},
+			"http_req_duration{status:404}": {"max<1"},
+			"checks":                        {"rate>0.9"},
+		}), "")
+		require.NoError(t, err)
+		for _, s := range samples {
+			require.NoError(t, r.Add(s))
+		}
+
+		assert.Equal(t, 4*time.Second, r.Duration())
+		assert.Len(t, r.Metrics, 4)
+		assert.Equal(t, uint64(3), r.Metrics["http_req_duration"].Sink.(*stats.TrendSink).Count)
+		assert.Equal(t, uint64(2), r.Metrics["http_req_duration{status:200}"].Sink.(*stats.TrendSink).Count)
+		assert.Equal(t, []string{"http_req_duration{status:404}"}, r.MissingThresholds())
+
+		if assert.Len(t, r.Scenarios, 2) {
+			assert.Equal(t, uint64(2), r.Scenarios["browse"]["http_req_duration"].Sink.(*stats.TrendSink).Count)
+			assert.Equal(t, uint64(1), r.Scenarios["api"]["http_req_duration"].Sink.(*stats.TrendSink).Count)
+		}
+
+		submit := r.Root.Groups["login"].Groups["submit"]
+		require.NotNil(t, submit)
+		assert.Equal(t, "::login::submit", submit.Path)
+		assert.Equal(t, int64(1), submit.Checks["ok"].Passes)
+		assert.Equal(t, int64(1), submit.Checks["ok"].Fails)
+		assert.Equal(t, int64(1), r.Root.Checks["top"].Passes)
+		assert.Equal(t, 50.0, r.GroupDurations["::login"].Sink.(*stats.TrendSink).Max)
+
+		passed, err := r.RunThresholds()
+		require.NoError(t, err)
+		assert.False(t, passed)
+		assert.True(t, r.Metrics["http_req_duration"].Tainted.Bool)
+		assert.False(t, r.Metrics["http_req_duration{status:200}"].Tainted.Bool)
+		assert.True(t, r.Metrics["checks"].Tainted.Bool)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		r, err := New(nil, "scenario:browse,status!=500")
+		require.NoError(t, err)
+		for _, s := range samples {
+			require.NoError(t, r.Add(s))
+		}
+		assert.Equal(t, uint64(1), r.Metrics["http_req_duration"].Sink.(*stats.TrendSink).Count)
+		assert.Equal(t, []string{"browse"}, func() (names []string) {
+			for name := range r.Scenarios {
+				names = append(names, name)
+			}
+			return names
+		}())
+		assert.Nil(t, r.Root.Checks["top"])
+		assert.Equal(t, 4*time.Second, r.Duration())
+
+		passed, err := r.RunThresholds()
+		require.NoError(t, err)
+		assert.True(t, passed)
+
+		_, err = New(nil, ":browse")
+		assert.EqualError(t, err, `invalid filter :browse: ":browse" needs a tag name`)
+	})
+	t.Run("QuantileOnNonTrend", func(t *testing.T) {
+		_, err := New(thresholds(t, map[string][]string{"checks": {"p(95)>0"}}), "")
+		assert.EqualError(t, err, "invalid thresholds on checks: "+
+			"threshold 'p(95)>0' uses p(), which is only available on trend metrics, not on rate metrics")
+
+		// The types of custom metrics are only known once their samples are read.
+		r, err := New(thresholds(t, map[string][]string{"errors_total": {"quantile(0.5)<1"}}), "")
+		require.NoError(t, err)
+		err = r.Add(stats.Sample{Metric: stats.New("errors_total", stats.Counter), Time: start, Value: 1})
+		assert.EqualError(t, err, "invalid thresholds on errors_total: "+
+			"threshold 'quantile(0.5)<1' uses quantile(), which is only available on trend metrics, not on counter metrics")
+	})
+}
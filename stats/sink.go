@@ -22,6 +22,7 @@ package stats
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"sort"
 	"time"
@@ -41,6 +42,54 @@ type Sink interface {
 	Format(t time.Duration) map[string]float64 // Data for thresholds.
 }
 
+// NewSink returns an empty sink for a type of metric, or nil for an unknown type.
+func NewSink(typ MetricType) Sink {
+	switch typ {
+	case Counter:
+		return &CounterSink{}
+	case Gauge:
+		return &GaugeSink{}
+	case Trend:
+		return &TrendSink{}
+	case Rate:
+		return &RateSink{}
+	default:
+		return nil
+	}
+}
+
+// MergeSink adds what one sink has had to another of the same kind, eg. to add up the time
+// series of a metric.
+func MergeSink(dst, src Sink) error {
+	switch d := dst.(type) {
+	case *CounterSink:
+		if s, ok := src.(*CounterSink); ok {
+			d.Value += s.Value
+			if d.First.IsZero() || (!s.First.IsZero() && s.First.Before(d.First)) {
+				d.First = s.First
+			}
+			return nil
+		}
+	case *GaugeSink:
+		if s, ok := src.(*GaugeSink); ok {
+			d.merge(s)
+			return nil
+		}
+	case *TrendSink:
+		if s, ok := src.(*TrendSink); ok {
+			d.merge(s)
+			return nil
+		}
+	case *RateSink:
+		if s, ok := src.(*RateSink); ok {
+			d.Trues += s.Trues
+			d.Total += s.Total
+			return nil
+		}
+	}
+	return fmt.Errorf("can't merge a %T into a %T", src, dst)
+}
+
 type CounterSink struct {
 	Value float64
 	First time.Time
@@ -56,9 +105,14 @@ func (c *CounterSink) Add(s Sample) {
 func (c *CounterSink) Calc() {}
 
 func (c *CounterSink) Format(t time.Duration) map[string]float64 {
+	// No time has passed before the test starts, eg. for the REST API of a paused test.
+	var rate float64
+	if t > 0 {
+		rate = c.Value / (float64(t) / float64(time.Second))
+	}
 	return map[string]float64{
 		"count": c.Value,
-		"rate":  c.Value / (float64(t) / float64(time.Second)),
+		"rate":  rate,
 	}
 }
 
@@ -66,10 +120,14 @@ type GaugeSink struct {
 	Value    float64
 	Max, Min float64
 	minSet   bool
+
+	// When Value was set, so that the latest of merged gauges wins.
+	last time.Time
 }
 
 func (g *GaugeSink) Add(s Sample) {
 	g.Value = s.Value
+	g.last = s.Time
 	if s.Value > g.Max {
 		g.Max = s.Value
 	}
@@ -79,16 +137,41 @@ func (g *GaugeSink) Add(s Sample) {
 	}
 }
 
+func (g *GaugeSink) merge(o *GaugeSink) {
+	if !o.minSet {
+		return
+	}
+	if !o.last.Before(g.last) {
+		g.Value = o.Value
+		g.last = o.last
+	}
+	if o.Max > g.Max {
+		g.Max = o.Max
+	}
+	if o.Min < g.Min || !g.minSet {
+		g.Min = o.Min
+		g.minSet = true
+	}
+}
+
 func (g *GaugeSink) Calc() {}
 
 func (g *GaugeSink) Format(t time.Duration) map[string]float64 {
 	return map[string]float64{"value": g.Value}
 }
 
+// MaxTrendValues is how many values a TrendSink keeps before it starts counting them in a
+// histogram instead, so that long tests don't run out of memory. Percentiles are exact up to
+// that point, and within 1% of the actual values after it.
+const MaxTrendValues = 10000
+
 type TrendSink struct {
 	Values  []float64
 	jumbled bool
 
+	// Replaces Values once there are more than MaxTrendValues of them.
+	hist *histogram
+
 	Count    uint64
 	Min, Max float64
 	Sum, Avg float64
@@ -96,7 +179,18 @@ type TrendSink struct {
 }
 
 func (t *TrendSink) Add(s Sample) {
-	t.Values = append(t.Values, s.Value)
+	if t.hist != nil {
+		t.hist.Add(s.Value)
+	} else {
+		t.Values = append(t.Values, s.Value)
+		if len(t.Values) > MaxTrendValues {
+			t.hist = newHistogram()
+			for _, v := range t.Values {
+				t.hist.Add(v)
+			}
+			t.Values = nil
+		}
+	}
 	t.jumbled = true
 	t.Count += 1
 	t.Sum += s.Value
@@ -110,12 +204,61 @@ func (t *TrendSink) Add(s Sample) {
 	}
 }
 
+// NewApproxTrendSink returns a TrendSink that counts values in a histogram from the start, which
+// takes much less memory than keeping them, at the cost of percentiles always being approximate.
+func NewApproxTrendSink() *TrendSink {
+	return &TrendSink{hist: newHistogram()}
+}
+
+func (t *TrendSink) merge(o *TrendSink) {
+	if o.Count == 0 {
+		return
+	}
+	if t.hist == nil && o.hist == nil && len(t.Values)+len(o.Values) <= MaxTrendValues {
+		t.Values = append(t.Values, o.Values...)
+	} else {
+		if t.hist == nil {
+			t.hist = newHistogram()
+			for _, v := range t.Values {
+				t.hist.Add(v)
+			}
+			t.Values = nil
+		}
+		if o.hist != nil {
+			t.hist.merge(o.hist)
+		} else {
+			for _, v := range o.Values {
+				t.hist.Add(v)
+			}
+		}
+	}
+	if o.Max > t.Max || t.Count == 0 {
+		t.Max = o.Max
+	}
+	if o.Min < t.Min || t.Count == 0 {
+		t.Min = o.Min
+	}
+	t.jumbled = true
+	t.Count += o.Count
+	t.Sum += o.Sum
+	t.Avg = t.Sum / float64(t.Count)
+}
+
 // P calculates the given percentile from sink values.
 func (t *TrendSink) P(pct float64) float64 {
-	switch t.Count {
-	case 0:
+	switch {
+	case t.Count == 0:
 		return 0
-	case 1:
+	case t.hist != nil:
+		// The lowest and highest values are known exactly, their buckets only stand for them.
+		if pct <= 0 {
+			return t.Min
+		}
+		if pct >= 1 {
+			return t.Max
+		}
+		return math.Min(math.Max(t.hist.Quantile(pct), t.Min), t.Max)
+	case t.Count == 1:
 		return t.Values[0]
 	default:
 		// If percentile falls on a value in Values slice, we return that value.
@@ -134,9 +277,14 @@ func (t *TrendSink) Calc() {
 	if !t.jumbled {
 		return
 	}
+	t.jumbled = false
+
+	if t.hist != nil {
+		t.Med = t.P(0.5)
+		return
+	}
 
 	sort.Float64s(t.Values)
-	t.jumbled = false
 
 	// The median of an even number of values is the average of the middle two.
 	if (t.Count & 0x01) == 0 {
@@ -59,6 +59,7 @@ func TestCounterSink(t *testing.T) {
 			sink.Add(Sample{Metric: &Metric{}, Value: s, Time: now})
 		}
 		assert.Equal(t, map[string]float64{"count": 145, "rate": 145.0}, sink.Format(1*time.Second))
+		assert.Equal(t, map[string]float64{"count": 145, "rate": 0}, sink.Format(0))
 	})
 }
 
@@ -212,6 +213,26 @@ func TestTrendSink(t *testing.T) {
 			"p(95)": 95.49999999999999,
 		}, sink.Format(0))
 	})
+	t.Run("histogram", func(t *testing.T) {
+		sink := TrendSink{}
+		n := 10 * MaxTrendValues
+		for i := n; i > 0; i-- {
+			sink.Add(Sample{Metric: &Metric{}, Value: float64(i)})
+		}
+		assert.Nil(t, sink.Values)
+		assert.Equal(t, uint64(n), sink.Count)
+		assert.Equal(t, 1.0, sink.Min)
+		assert.Equal(t, float64(n), sink.Max)
+		assert.Equal(t, float64(n+1)/2, sink.Avg)
+
+		sink.Calc()
+		assert.InEpsilon(t, float64(n+1)/2, sink.Med, 0.01)
+		for _, pct := range []float64{0.1, 0.5, 0.9, 0.95, 0.99} {
+			assert.InEpsilon(t, 1+pct*float64(n-1), sink.P(pct), 0.01, "p(%v)", pct*100)
+		}
+		assert.Equal(t, 1.0, sink.P(0))
+		assert.Equal(t, float64(n), sink.P(1))
+	})
 }
 
 func TestRateSink(t *testing.T) {
@@ -269,3 +290,65 @@ func TestDummySinkCalcDoesNothing(t *testing.T) {
 func TestDummySinkFormatReturnsItself(t *testing.T) {
 	assert.Equal(t, map[string]float64{"a": 1}, DummySink{"a": 1}.Format(0))
 }
+
+func TestMergeSink(t *testing.T) {
+	now := time.Now()
+	sample := func(v float64, d time.Duration) Sample {
+		return Sample{Metric: &Metric{}, Value: v, Time: now.Add(d)}
+	}
+
+	t.Run("counter", func(t *testing.T) {
+		a, b := &CounterSink{}, &CounterSink{}
+		a.Add(sample(2, time.Second))
+		b.Add(sample(3, 0))
+		assert.NoError(t, MergeSink(a, b))
+		assert.Equal(t, 5.0, a.Value)
+		assert.Equal(t, now, a.First)
+	})
+	t.Run("gauge", func(t *testing.T) {
+		a, b := &GaugeSink{}, &GaugeSink{}
+		a.Add(sample(2, time.Second))
+		b.Add(sample(-1, 0))
+		b.Add(sample(5, 0))
+		assert.NoError(t, MergeSink(a, b))
+		assert.Equal(t, 2.0, a.Value)
+		assert.Equal(t, 5.0, a.Max)
+		assert.Equal(t, -1.0, a.Min)
+		assert.NoError(t, MergeSink(a, &GaugeSink{}))
+		assert.Equal(t, 2.0, a.Value)
+	})
+	t.Run("rate", func(t *testing.T) {
+		a, b := &RateSink{}, &RateSink{}
+		a.Add(sample(1, 0))
+		b.Add(sample(0, 0))
+		b.Add(sample(1, 0))
+		assert.NoError(t, MergeSink(a, b))
+		assert.Equal(t, RateSink{Trues: 2, Total: 3}, *a)
+	})
+	t.Run("trend", func(t *testing.T) {
+		a, b := &TrendSink{}, NewApproxTrendSink()
+		for i := 1; i <= 50; i++ {
+			a.Add(sample(float64(i), 0))
+			b.Add(sample(float64(i+50), 0))
+		}
+		assert.NoError(t, MergeSink(a, b))
+		assert.Equal(t, uint64(100), a.Count)
+		assert.Equal(t, 1.0, a.Min)
+		assert.Equal(t, 100.0, a.Max)
+		assert.Equal(t, 50.5, a.Avg)
+		assert.InEpsilon(t, 50.5, a.P(0.5), histogramAccuracy)
+		assert.InEpsilon(t, 90.1, a.P(0.9), histogramAccuracy)
+
+		c := &TrendSink{}
+		c.Add(sample(7, 0))
+		assert.NoError(t, MergeSink(c, &TrendSink{}))
+		assert.NoError(t, MergeSink(c, &TrendSink{Values: []float64{3}, Count: 1, Min: 3, Max: 3, Sum: 3}))
+		assert.Equal(t, []float64{7, 3}, c.Values)
+		assert.Equal(t, 3.0, c.Min)
+		assert.Equal(t, 5.0, c.Avg)
+	})
+	t.Run("different kinds", func(t *testing.T) {
+		assert.EqualError(t, MergeSink(&CounterSink{}, &RateSink{}),
+			"can't merge a *stats.RateSink into a *stats.CounterSink")
+	})
+}
@@ -23,6 +23,7 @@ package stats
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -37,9 +38,10 @@ const (
 	trendString   = `"trend"`
 	rateString    = `"rate"`
 
-	defaultString = `"default"`
-	timeString    = `"time"`
-	dataString    = `"data"`
+	defaultString  = `"default"`
+	timeString     = `"time"`
+	dataString     = `"data"`
+	dataRateString = `"dataRate"`
 )
 
 // Possible values for MetricType.
@@ -52,9 +54,10 @@ const (
 
 // Possible values for ValueType.
 const (
-	Default = ValueType(iota) // Values are presented as-is
-	Time                      // Values are timestamps (nanoseconds)
-	Data                      // Values are data amounts (bytes)
+	Default  = ValueType(iota) // Values are presented as-is
+	Time                       // Values are timestamps (nanoseconds)
+	Data                       // Values are data amounts (bytes)
+	DataRate                   // Values are data transfer rates (bytes per second)
 )
 
 // The serialized metric type is invalid.
@@ -127,6 +130,8 @@ func (t ValueType) MarshalJSON() ([]byte, error) {
 		return []byte(timeString), nil
 	case Data:
 		return []byte(dataString), nil
+	case DataRate:
+		return []byte(dataRateString), nil
 	default:
 		return nil, ErrInvalidValueType
 	}
@@ -141,6 +146,8 @@ func (t *ValueType) UnmarshalJSON(data []byte) error {
 		*t = Time
 	case dataString:
 		*t = Data
+	case dataRateString:
+		*t = DataRate
 	default:
 		return ErrInvalidValueType
 	}
@@ -148,6 +155,13 @@ func (t *ValueType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ParseValueType returns the value type with a name, eg. "time" or "dataRate".
+func ParseValueType(name string) (ValueType, error) {
+	var t ValueType
+	err := t.UnmarshalJSON([]byte(strconv.Quote(name)))
+	return t, err
+}
+
 func (t ValueType) String() string {
 	switch t {
 	case Default:
@@ -156,6 +170,8 @@ func (t ValueType) String() string {
 		return timeString
 	case Data:
 		return dataString
+	case DataRate:
+		return dataRateString
 	default:
 		return "[INVALID]"
 	}
@@ -197,6 +213,22 @@ func (st *SampleTags) IsEqual(other *SampleTags) bool {
 	return true
 }
 
+// Contains returns whether the tag set has all of the other set's tags, with the same values.
+func (st *SampleTags) Contains(other *SampleTags) bool {
+	if st == other || other == nil || len(other.tags) == 0 {
+		return true
+	}
+	if st == nil || len(st.tags) < len(other.tags) {
+		return false
+	}
+	for k, v := range other.tags {
+		if myv, ok := st.tags[k]; !ok || myv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // MarshalJSON serializes SampleTags to a JSON string and caches
 // the result. It is not thread safe in the sense that the Go race
 // detector will complain if it's used concurrently, but no data
@@ -281,17 +313,8 @@ func New(name string, typ MetricType, t ...ValueType) *Metric {
 	if len(t) > 0 {
 		vt = t[0]
 	}
-	var sink Sink
-	switch typ {
-	case Counter:
-		sink = &CounterSink{}
-	case Gauge:
-		sink = &GaugeSink{}
-	case Trend:
-		sink = &TrendSink{}
-	case Rate:
-		sink = &RateSink{}
-	default:
+	sink := NewSink(typ)
+	if sink == nil {
 		return nil
 	}
 	return &Metric{Name: name, Type: typ, Contains: vt, Sink: sink}
@@ -319,46 +342,166 @@ func (m *Metric) HumanizeValue(v float64) string {
 			return d.String()
 		case Data:
 			return humanize.Bytes(uint64(v))
+		case DataRate:
+			return humanize.Bytes(uint64(v)) + "/s"
 		default:
 			return humanize.Ftoa(v)
 		}
 	}
 }
 
-// A Submetric represents a filtered dataset based on a parent metric.
+// A Submetric represents a filtered dataset based on a parent metric: the samples that have all
+// of its Tags, and none of its NotTags.
 type Submetric struct {
-	Name   string      `json:"name"`
-	Parent string      `json:"parent"`
-	Suffix string      `json:"suffix"`
-	Tags   *SampleTags `json:"tags"`
-	Metric *Metric     `json:"-"`
+	Name    string      `json:"name"`
+	Parent  string      `json:"parent"`
+	Suffix  string      `json:"suffix"`
+	Tags    *SampleTags `json:"tags"`
+	NotTags *SampleTags `json:"notTags,omitempty"`
+	Metric  *Metric     `json:"-"`
 }
 
-// Creates a submetric from a name.
-func NewSubmetric(name string) (parentName string, sm *Submetric) {
+// Creates a submetric from a name, like `http_req_duration{status:200,name!="login page"}`. Its
+// conditions are separated by commas; `tag:value` matches samples with that tag value, `tag!=value`
+// ones without it, and a bare `tag` ones where it's empty. Keys and values can be quoted, to use
+// commas, colons or spaces in them.
+func NewSubmetric(name string) (parentName string, sm *Submetric, err error) {
 	parts := strings.SplitN(strings.TrimSuffix(name, "}"), "{", 2)
 	if len(parts) == 1 {
-		return parts[0], &Submetric{Name: name}
+		return parts[0], &Submetric{Name: name}, nil
 	}
 
-	kvs := strings.Split(parts[1], ",")
-	tags := make(map[string]string, len(kvs))
-	for _, kv := range kvs {
-		if kv == "" {
-			continue
+	conds, err := splitSubmetricConditions(parts[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid submetric %s: %s", name, err)
+	}
+	tags := make(map[string]string, len(conds))
+	var notTags map[string]string
+	for _, cond := range conds {
+		if cond.Not {
+			if notTags == nil {
+				notTags = make(map[string]string)
+			}
+			notTags[cond.Key] = cond.Value
+		} else {
+			tags[cond.Key] = cond.Value
 		}
-		parts := strings.SplitN(kv, ":", 2)
+	}
+	sm = &Submetric{Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags)}
+	if notTags != nil {
+		sm.NotTags = IntoSampleTags(&notTags)
+	}
+	return parts[0], sm, nil
+}
 
-		key := strings.TrimSpace(strings.Trim(parts[0], `"'`))
-		if len(parts) != 2 {
-			tags[key] = ""
+// Matches returns whether a sample with the given tags belongs to the submetric.
+func (sm *Submetric) Matches(tags *SampleTags) bool {
+	if !tags.Contains(sm.Tags) {
+		return false
+	}
+	if sm.NotTags != nil {
+		for k, v := range sm.NotTags.tags {
+			if tv, ok := tags.Get(k); ok && tv == v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// A submetricCondition is one of the comma separated conditions of a submetric.
+type submetricCondition struct {
+	Key, Value string
+	Not        bool
+}
+
+// splitSubmetricConditions parses the conditions of a submetric, between its braces.
+func splitSubmetricConditions(s string) ([]submetricCondition, error) {
+	var conds []submetricCondition
+	for s != "" {
+		i, err := indexUnquoted(s, ",")
+		if err != nil {
+			return nil, err
+		}
+		part := s
+		if i >= 0 {
+			part, s = s[:i], s[i+1:]
+		} else {
+			s = ""
+		}
+		if strings.TrimSpace(part) == "" {
 			continue
 		}
 
-		value := strings.TrimSpace(strings.Trim(parts[1], `"'`))
-		tags[key] = value
+		var cond submetricCondition
+		colon, err := indexUnquoted(part, ":")
+		if err != nil {
+			return nil, err
+		}
+		not, err := indexUnquoted(part, "!=")
+		if err != nil {
+			return nil, err
+		}
+		key, value := part, ""
+		switch {
+		case not >= 0 && (colon < 0 || not < colon):
+			key, value, cond.Not = part[:not], part[not+2:], true
+		case colon >= 0:
+			key, value = part[:colon], part[colon+1:]
+		}
+		if cond.Key, err = unquoteSubmetric(key); err != nil {
+			return nil, err
+		}
+		if cond.Value, err = unquoteSubmetric(value); err != nil {
+			return nil, err
+		}
+		if cond.Key == "" {
+			return nil, fmt.Errorf("%q needs a tag name", strings.TrimSpace(part))
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+// indexUnquoted returns the index of the first sep in s that isn't in quotes, or -1.
+func indexUnquoted(s, sep string) (int, error) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			return i, nil
+		}
+	}
+	if quote != 0 {
+		return -1, fmt.Errorf("unterminated quote in %q", s)
+	}
+	return -1, nil
+}
+
+// unquoteSubmetric returns a key or value of a submetric, without its quotes, if it has them.
+func unquoteSubmetric(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return s, nil
+	}
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("%s has something after its closing quote", s)
+	}
+	var buf strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		buf.WriteByte(s[i])
 	}
-	return parts[0], &Submetric{Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags)}
+	return buf.String(), nil
 }
 
 func (m *Metric) Summary(t time.Duration) *Summary {
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMetricHumanizeValue(t *testing.T) {
@@ -116,6 +117,14 @@ func TestMetricHumanizeValue(t *testing.T) {
 			1.0:       "100.00%",
 			1.5:       "150.00%",
 		},
+		{Type: Gauge, Contains: Data}: {
+			1.0:       "1 B",
+			1234567.0: "1.2 MB",
+		},
+		{Type: Trend, Contains: DataRate}: {
+			1.0:       "1 B/s",
+			1234567.0: "1.2 MB/s",
+		},
 	}
 
 	for m, values := range data {
@@ -130,6 +139,21 @@ func TestMetricHumanizeValue(t *testing.T) {
 	}
 }
 
+func TestParseValueType(t *testing.T) {
+	t.Parallel()
+	for name, vt := range map[string]ValueType{"default": Default, "time": Time, "data": Data, "dataRate": DataRate} {
+		parsed, err := ParseValueType(name)
+		assert.NoError(t, err)
+		assert.Equal(t, vt, parsed)
+
+		data, err := json.Marshal(vt)
+		assert.NoError(t, err)
+		assert.Equal(t, `"`+name+`"`, string(data))
+	}
+	_, err := ParseValueType("bytes")
+	assert.Equal(t, ErrInvalidValueType, err)
+}
+
 func TestNew(t *testing.T) {
 	t.Parallel()
 	testdata := map[string]struct {
@@ -155,31 +179,81 @@ func TestNew(t *testing.T) {
 func TestNewSubmetric(t *testing.T) {
 	t.Parallel()
 	testdata := map[string]struct {
-		parent string
-		tags   map[string]string
+		parent  string
+		tags    map[string]string
+		notTags map[string]string
 	}{
-		"my_metric":                 {"my_metric", nil},
-		"my_metric{}":               {"my_metric", map[string]string{}},
-		"my_metric{a}":              {"my_metric", map[string]string{"a": ""}},
-		"my_metric{a:1}":            {"my_metric", map[string]string{"a": "1"}},
-		"my_metric{ a : 1 }":        {"my_metric", map[string]string{"a": "1"}},
-		"my_metric{a,b}":            {"my_metric", map[string]string{"a": "", "b": ""}},
-		"my_metric{a:1,b:2}":        {"my_metric", map[string]string{"a": "1", "b": "2"}},
-		"my_metric{ a : 1, b : 2 }": {"my_metric", map[string]string{"a": "1", "b": "2"}},
+		"my_metric":                 {"my_metric", nil, nil},
+		"my_metric{}":               {"my_metric", map[string]string{}, nil},
+		"my_metric{a}":              {"my_metric", map[string]string{"a": ""}, nil},
+		"my_metric{a:1}":            {"my_metric", map[string]string{"a": "1"}, nil},
+		"my_metric{ a : 1 }":        {"my_metric", map[string]string{"a": "1"}, nil},
+		"my_metric{a,b}":            {"my_metric", map[string]string{"a": "", "b": ""}, nil},
+		"my_metric{a:1,b:2}":        {"my_metric", map[string]string{"a": "1", "b": "2"}, nil},
+		"my_metric{ a : 1, b : 2 }": {"my_metric", map[string]string{"a": "1", "b": "2"}, nil},
+		"checks{group:::login}":     {"checks", map[string]string{"group": "::login"}, nil},
+		"my_metric{'a':'1'}":        {"my_metric", map[string]string{"a": "1"}, nil},
+		`my_metric{url:"http://example.com/?a=1,2", name:" x "}`: {
+			"my_metric", map[string]string{"url": "http://example.com/?a=1,2", "name": " x "}, nil,
+		},
+		`my_metric{a:"say \"hi\""}`: {"my_metric", map[string]string{"a": `say "hi"`}, nil},
+		"my_metric{a:1,b!=2}":       {"my_metric", map[string]string{"a": "1"}, map[string]string{"b": "2"}},
+		`my_metric{b != "x:y"}`:     {"my_metric", map[string]string{}, map[string]string{"b": "x:y"}},
+		"my_metric{a:b!=c}":         {"my_metric", map[string]string{"a": "b!=c"}, nil},
 	}
 
 	for name, data := range testdata {
+		name, data := name, data
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
-			parent, sm := NewSubmetric(name)
+			parent, sm, err := NewSubmetric(name)
+			require.NoError(t, err)
 			assert.Equal(t, data.parent, parent)
 			if data.tags != nil {
 				assert.EqualValues(t, data.tags, sm.Tags.tags)
 			} else {
 				assert.Nil(t, sm.Tags)
 			}
+			if data.notTags != nil {
+				assert.EqualValues(t, data.notTags, sm.NotTags.tags)
+			} else {
+				assert.Nil(t, sm.NotTags)
+			}
 		})
 	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		for name, msg := range map[string]string{
+			`my_metric{a:"1}`:   `invalid submetric my_metric{a:"1}: unterminated quote in "a:\"1"`,
+			`my_metric{:1}`:     `invalid submetric my_metric{:1}: ":1" needs a tag name`,
+			`my_metric{!=1}`:    `invalid submetric my_metric{!=1}: "!=1" needs a tag name`,
+			`my_metric{a:"1"2}`: `invalid submetric my_metric{a:"1"2}: "1"2 has something after its closing quote`,
+		} {
+			_, _, err := NewSubmetric(name)
+			assert.EqualError(t, err, msg, name)
+		}
+	})
+}
+
+func TestSubmetricMatches(t *testing.T) {
+	t.Parallel()
+	_, sm, err := NewSubmetric("my_metric{a:1,b!=2}")
+	require.NoError(t, err)
+	testdata := map[string]struct {
+		tags    map[string]string
+		matches bool
+	}{
+		"exact":     {map[string]string{"a": "1"}, true},
+		"more tags": {map[string]string{"a": "1", "c": "3"}, true},
+		"other b":   {map[string]string{"a": "1", "b": "3"}, true},
+		"excluded":  {map[string]string{"a": "1", "b": "2"}, false},
+		"other a":   {map[string]string{"a": "2"}, false},
+		"no tags":   {map[string]string{}, false},
+	}
+	for name, data := range testdata {
+		assert.Equal(t, data.matches, sm.Matches(IntoSampleTags(&data.tags)), name)
+	}
 }
 
 func TestSampleTags(t *testing.T) {
@@ -224,6 +298,13 @@ func TestSampleTags(t *testing.T) {
 	assert.False(t, tags.IsEqual(IntoSampleTags(&map[string]string{"key1": "val1", "key2": "val3"})))
 	assert.Equal(t, tagMap, tags.CloneTags())
 
+	assert.True(t, tags.Contains(nilTags))
+	assert.True(t, tags.Contains(emptyTags))
+	assert.True(t, tags.Contains(IntoSampleTags(&map[string]string{"key2": "val2"})))
+	assert.False(t, tags.Contains(IntoSampleTags(&map[string]string{"key2": "val3"})))
+	assert.False(t, tags.Contains(IntoSampleTags(&map[string]string{"key1": "val1", "key3": "val3"})))
+	assert.False(t, nilTags.Contains(tags))
+
 	assert.Nil(t, tags.json) // No cache
 	tagsJSON, err := json.Marshal(tags)
 	expJSON := `{"key1":"val1","key2":"val2"}`
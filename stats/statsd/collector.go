@@ -0,0 +1,173 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pushInterval = 1 * time.Second
+)
+
+var _ lib.Collector = &Collector{}
+
+// Collector sends samples to a StatsD agent over UDP. With tags enabled, the whitelisted sample
+// tags are sent with DogStatsD's extension, as Datadog's agent expects them; plain StatsD has no
+// tags, and they're left out.
+type Collector struct {
+	Config Config
+
+	conn       net.Conn
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+}
+
+func New(conf Config) (*Collector, error) {
+	if conf.Addr == "" {
+		return nil, errors.New("the StatsD output needs an address")
+	}
+	if conf.BufferSize <= 0 {
+		return nil, errors.New("the StatsD buffer size must be positive")
+	}
+	return &Collector{Config: conf}, nil
+}
+
+func (c *Collector) Init() error {
+	conn, err := net.Dial("udp", c.Config.Addr)
+	if err != nil {
+		return errors.Wrap(err, "couldn't connect to the StatsD agent")
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("StatsD: Running!")
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit()
+		case <-ctx.Done():
+			c.commit()
+			_ = c.conn.Close()
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+func (c *Collector) Link() string {
+	return c.Config.Addr
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+// commit sends the buffered samples, with as many lines in a datagram as fit in the buffer size.
+func (c *Collector) commit() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	log.WithField("samples", len(samples)).Debug("StatsD: Sending...")
+	var buf bytes.Buffer
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		if _, err := c.conn.Write(buf.Bytes()); err != nil {
+			log.WithError(err).Error("StatsD: Couldn't send samples")
+		}
+		buf.Reset()
+	}
+	for _, sample := range samples {
+		line := c.line(sample)
+		if buf.Len() > 0 && buf.Len()+1+len(line) > c.Config.BufferSize {
+			flush()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	flush()
+}
+
+// line formats a sample; counters and rates are counts, gauges gauges, and trends timings if
+// they're times, or histograms otherwise.
+func (c *Collector) line(sample stats.Sample) string {
+	typ := "c"
+	switch sample.Metric.Type {
+	case stats.Gauge:
+		typ = "g"
+	case stats.Trend:
+		typ = "h"
+		if sample.Metric.Contains == stats.Time {
+			typ = "ms"
+		}
+	}
+	line := c.Config.Namespace + sample.Metric.Name + ":" +
+		strconv.FormatFloat(sample.Value, 'f', -1, 64) + "|" + typ
+	if tags := c.tags(sample.Tags); len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// tagEscaper replaces the characters that separate tags and fields in a line.
+var tagEscaper = strings.NewReplacer(",", "_", "|", "_", "\n", "_")
+
+func (c *Collector) tags(sampleTags *stats.SampleTags) []string {
+	if !c.Config.EnableTags {
+		return nil
+	}
+	var tags []string
+	for _, key := range c.Config.TagWhitelist {
+		if value, ok := sampleTags.Get(key); ok {
+			tags = append(tags, tagEscaper.Replace(key)+":"+tagEscaper.Replace(value))
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
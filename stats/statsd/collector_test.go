@@ -0,0 +1,92 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	read := func() []string {
+		var datagrams []string
+		buf := make([]byte, 2048)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return datagrams
+			}
+			datagrams = append(datagrams, string(buf[:n]))
+		}
+	}
+
+	tags := stats.IntoSampleTags(&map[string]string{"status": "200", "method": "GET", "url": "http://example.com/a,b"})
+	groupTags := stats.IntoSampleTags(&map[string]string{"group": "::a,b|c"})
+	samples := []stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: tags, Time: time.Now(), Value: 1},
+		{Metric: metrics.HTTPReqDuration, Tags: tags, Time: time.Now(), Value: 12.5},
+		{Metric: metrics.VUs, Time: time.Now(), Value: 5},
+		{Metric: stats.New("my_trend", stats.Trend), Tags: groupTags, Time: time.Now(), Value: 3},
+		{Metric: metrics.Checks, Time: time.Now(), Value: 0},
+	}
+
+	t.Run("StatsD", func(t *testing.T) {
+		config := NewConfig().Apply(Config{Addr: conn.LocalAddr().String()})
+		c, err := New(config)
+		require.NoError(t, err)
+		require.NoError(t, c.Init())
+		c.Collect(samples)
+		c.commit()
+		assert.Equal(t, []string{strings.Join([]string{
+			"k6.http_reqs:1|c",
+			"k6.http_req_duration:12.5|ms",
+			"k6.vus:5|g",
+			"k6.my_trend:3|h",
+			"k6.checks:0|c",
+		}, "\n")}, read())
+	})
+
+	t.Run("Datadog", func(t *testing.T) {
+		config := NewConfig().Apply(Config{Addr: conn.LocalAddr().String(), EnableTags: true, BufferSize: 60})
+		c, err := New(config)
+		require.NoError(t, err)
+		require.NoError(t, c.Init())
+		c.Collect(samples)
+		c.commit()
+		assert.Equal(t, []string{
+			"k6.http_reqs:1|c|#method:GET,status:200",
+			"k6.http_req_duration:12.5|ms|#method:GET,status:200",
+			"k6.vus:5|g\nk6.my_trend:3|h|#group:::a_b_c\nk6.checks:0|c",
+		}, read())
+	})
+}
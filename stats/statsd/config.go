@@ -0,0 +1,123 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type ConfigFields struct {
+	// Connection.
+	Addr       string `json:"addr" envconfig:"STATSD_ADDR"`
+	BufferSize int    `json:"bufferSize,omitempty" envconfig:"STATSD_BUFFER_SIZE"`
+
+	// Samples.
+	Namespace    string   `json:"namespace,omitempty" envconfig:"STATSD_NAMESPACE"`
+	EnableTags   bool     `json:"enableTags,omitempty" envconfig:"STATSD_ENABLE_TAGS"`
+	TagWhitelist []string `json:"tagWhitelist,omitempty" envconfig:"STATSD_TAG_WHITELIST"`
+}
+
+type Config ConfigFields
+
+// NewConfig returns the defaults: the buffer fits in an Ethernet frame, and only tags with few
+// values are sent, since every combination of tags is a separate series in Datadog.
+func NewConfig() *Config {
+	return &Config{
+		Addr:         "localhost:8125",
+		BufferSize:   1432,
+		Namespace:    "k6.",
+		TagWhitelist: []string{"status", "method", "name", "group", "check", "error_code", "tls_version"},
+	}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.Addr != "" {
+		c.Addr = cfg.Addr
+	}
+	if cfg.BufferSize > 0 {
+		c.BufferSize = cfg.BufferSize
+	}
+	if cfg.Namespace != "" {
+		c.Namespace = cfg.Namespace
+	}
+	if cfg.EnableTags {
+		c.EnableTags = cfg.EnableTags
+	}
+	if len(cfg.TagWhitelist) > 0 {
+		c.TagWhitelist = cfg.TagWhitelist
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is the agent's address, optionally followed by
+// options; eg. localhost:8125?namespace=shop.&tag_whitelist=status,method
+func (c *Config) UnmarshalText(text []byte) error {
+	s := string(text)
+	query := ""
+	if i := strings.Index(s, "?"); i != -1 {
+		s, query = s[:i], s[i+1:]
+	}
+	if s != "" {
+		c.Addr = s
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	for k, vs := range values {
+		switch k {
+		case "buffer_size":
+			c.BufferSize, err = strconv.Atoi(vs[0])
+		case "namespace":
+			c.Namespace = vs[0]
+		case "tag_whitelist":
+			c.TagWhitelist = nil
+			for _, v := range vs {
+				for _, tag := range strings.Split(v, ",") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						c.TagWhitelist = append(c.TagWhitelist, tag)
+					}
+				}
+			}
+		default:
+			return errors.Errorf("unknown query parameter: %s", k)
+		}
+	}
+	return err
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
@@ -0,0 +1,55 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigText(t *testing.T) {
+	testdata := map[string]struct {
+		Config Config
+		Err    string
+	}{
+		"":                                 {Config{}, ""},
+		"localhost:8125":                   {Config{Addr: "localhost:8125"}, ""},
+		"10.0.0.1:9125?namespace=shop.":    {Config{Addr: "10.0.0.1:9125", Namespace: "shop."}, ""},
+		"?buffer_size=512":                 {Config{BufferSize: 512}, ""},
+		"?buffer_size=a":                   {Config{}, "strconv.Atoi: parsing \"a\": invalid syntax"},
+		"?tag_whitelist=status,%20method":  {Config{TagWhitelist: []string{"status", "method"}}, ""},
+		"?tag_whitelist=a&tag_whitelist=b": {Config{TagWhitelist: []string{"a", "b"}}, ""},
+		"?foo=bar":                         {Config{}, "unknown query parameter: foo"},
+	}
+	for str, data := range testdata {
+		t.Run(str, func(t *testing.T) {
+			var config Config
+			err := config.UnmarshalText([]byte(str))
+			if data.Err != "" {
+				assert.EqualError(t, err, data.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.Config, config)
+		})
+	}
+}
@@ -22,6 +22,8 @@ package stats
 
 import (
 	"encoding/json"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/dop251/goja"
@@ -31,10 +33,19 @@ import (
 
 const jsEnvSrc = `
 function p(pct) {
-	return __sink__.P(pct/100.0);
+	return quantile(pct/100.0);
+};
+function quantile(q) {
+	if (typeof __sink__.P !== "function") {
+		throw new Error("p() and quantile() are only available in thresholds on trend metrics");
+	}
+	return __sink__.P(q);
 };
 `
 
+// quantileCallRe finds calls to p() and quantile(), but not to the p() of a metric('name').
+var quantileCallRe = regexp.MustCompile(`(^|[^.\w$])(p|quantile)\s*\(`)
+
 var jsEnv *goja.Program
 
 func init() {
@@ -116,6 +127,23 @@ type Thresholds struct {
 	Runtime    *goja.Runtime
 	Thresholds []*Threshold
 	Abort      bool
+
+	// Finds the sinks of the test's other metrics by name, so expressions can compare against
+	// them with metric(name), eg. "rate < metric('checks').rate"; nil if there are none.
+	Metrics func(name string) Sink
+
+	// Totals of a counter or rate metric as of past evaluations, oldest first, for rateOver().
+	history []thresholdSnapshot
+	// The longest window rateOver() has been asked for, which is as far back as history goes.
+	maxWindow time.Duration
+}
+
+// thresholdSnapshot is what a counter or rate metric had added up to by a time in the test: for
+// counters, value is the count; for rates, value is the trues out of total.
+type thresholdSnapshot struct {
+	t     time.Duration
+	value float64
+	total float64
 }
 
 func NewThresholds(sources []string) (Thresholds, error) {
@@ -142,7 +170,22 @@ func NewThresholdsWithConfig(configs []ThresholdConfig) (Thresholds, error) {
 		ts[i] = t
 	}
 
-	return Thresholds{rt, ts, false}, nil
+	return Thresholds{Runtime: rt, Thresholds: ts}, nil
+}
+
+// Validate makes sure the thresholds only use what's available on metrics of type typ: p() and
+// quantile() need all the values, which only trends keep.
+func (ts Thresholds) Validate(typ MetricType) error {
+	if typ == Trend {
+		return nil
+	}
+	for _, t := range ts.Thresholds {
+		if m := quantileCallRe.FindStringSubmatch(t.Source); m != nil {
+			return errors.Errorf("threshold '%s' uses %s(), which is only available on trend metrics, not on %s metrics",
+				t.Source, m[2], strings.Trim(typ.String(), `"`))
+		}
+	}
+	return nil
 }
 
 func (ts *Thresholds) UpdateVM(sink Sink, t time.Duration) error {
@@ -151,9 +194,105 @@ func (ts *Thresholds) UpdateVM(sink Sink, t time.Duration) error {
 	for k, v := range f {
 		ts.Runtime.Set(k, v)
 	}
+	ts.Runtime.Set("duration", t.Seconds())
+	if now, ok := snapshotSink(sink, t); ok {
+		ts.record(now)
+		ts.Runtime.Set("rateOver", func(window string) (float64, error) {
+			return ts.rateOver(sink, now, window)
+		})
+	}
+	if ts.Metrics != nil {
+		ts.Runtime.Set("metric", func(name string) (*goja.Object, error) {
+			return ts.metricValues(name, t)
+		})
+	}
 	return nil
 }
 
+// metricValues gives expressions the values of another metric as an object, with a p() of its
+// own for trends.
+func (ts *Thresholds) metricValues(name string, t time.Duration) (*goja.Object, error) {
+	sink := ts.Metrics(name)
+	if sink == nil {
+		return nil, errors.Errorf("metric %s has no samples", name)
+	}
+	obj := ts.Runtime.NewObject()
+	for k, v := range sink.Format(t) {
+		if err := obj.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if trend, ok := sink.(*TrendSink); ok {
+		if err := obj.Set("p", func(pct float64) float64 { return trend.P(pct / 100.0) }); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func snapshotSink(sink Sink, t time.Duration) (thresholdSnapshot, bool) {
+	switch s := sink.(type) {
+	case *CounterSink:
+		return thresholdSnapshot{t: t, value: s.Value}, true
+	case *RateSink:
+		return thresholdSnapshot{t: t, value: float64(s.Trues), total: float64(s.Total)}, true
+	default:
+		return thresholdSnapshot{}, false
+	}
+}
+
+// record adds a snapshot to the history, and forgets the ones no window reaches back to anymore,
+// keeping the last one from before the longest window so it still has a starting point.
+func (ts *Thresholds) record(now thresholdSnapshot) {
+	if n := len(ts.history); n > 0 && ts.history[n-1].t >= now.t {
+		ts.history = ts.history[:n-1]
+	}
+	ts.history = append(ts.history, now)
+
+	cutoff := now.t - ts.maxWindow
+	drop := 0
+	for drop+1 < len(ts.history) && ts.history[drop+1].t <= cutoff {
+		drop++
+	}
+	ts.history = ts.history[drop:]
+}
+
+// rateOver is the rate of a metric over the last window of the test: for counters, how much it
+// went up by per second; for rates, the share of the values added in the window that were true.
+// Windows longer than the test so far start at its beginning.
+func (ts *Thresholds) rateOver(sink Sink, now thresholdSnapshot, window string) (float64, error) {
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.Errorf("the window for rateOver() must be positive, not %s", window)
+	}
+	if d > ts.maxWindow {
+		ts.maxWindow = d
+	}
+
+	var then thresholdSnapshot
+	for _, s := range ts.history {
+		if s.t > now.t-d {
+			break
+		}
+		then = s
+	}
+
+	if _, ok := sink.(*CounterSink); ok {
+		secs := (now.t - then.t).Seconds()
+		if secs <= 0 {
+			return 0, nil
+		}
+		return (now.value - then.value) / secs, nil
+	}
+	if now.total == then.total {
+		return 0, nil
+	}
+	return (now.value - then.value) / (now.total - then.total), nil
+}
+
 func (ts *Thresholds) RunAll(t time.Duration) (bool, error) {
 	succ := true
 	for i, th := range ts.Thresholds {
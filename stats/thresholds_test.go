@@ -22,6 +22,7 @@ package stats
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -216,6 +217,171 @@ func TestThresholdsRun(t *testing.T) {
 	})
 }
 
+func TestThresholdsRateOver(t *testing.T) {
+	t.Run("counter", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"count/duration < 100", "rateOver('10s') < 100"})
+		assert.NoError(t, err)
+		sink := &CounterSink{}
+
+		// 50/s for the first 20s, then 150/s.
+		for sec := 1; sec <= 30; sec++ {
+			if sec <= 20 {
+				sink.Value += 50
+			} else {
+				sink.Value += 150
+			}
+			_, err := ts.Run(sink, time.Duration(sec)*time.Second)
+			assert.NoError(t, err)
+		}
+		assert.False(t, ts.Thresholds[0].Failed)
+		assert.True(t, ts.Thresholds[1].Failed)
+
+		v, err := ts.rateOver(sink, thresholdSnapshot{t: 30 * time.Second, value: sink.Value}, "10s")
+		assert.NoError(t, err)
+		assert.Equal(t, 150.0, v)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"rateOver('2s') >= 0.5"})
+		assert.NoError(t, err)
+		sink := &RateSink{}
+
+		for sec, trues := range []int64{10, 10, 0, 0} {
+			sink.Trues += trues
+			sink.Total += 10
+			b, err := ts.Run(sink, time.Duration(sec+1)*time.Second)
+			assert.NoError(t, err)
+			// Half of the whole test is still true after 4s, but none of its last 2s are.
+			assert.Equal(t, sec < 3, b, "%ds", sec+1)
+		}
+	})
+
+	t.Run("longer than the test", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"rateOver('1m') == 10"})
+		assert.NoError(t, err)
+		b, err := ts.Run(&CounterSink{Value: 50}, 5*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, b)
+	})
+
+	t.Run("bad window", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"rateOver('nope') > 0"})
+		assert.NoError(t, err)
+		_, err = ts.Run(&CounterSink{Value: 50}, 5*time.Second)
+		assert.Error(t, err)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"rateOver('5s') >= 0"})
+		assert.NoError(t, err)
+		for sec := 1; sec <= 100; sec++ {
+			_, err := ts.Run(&CounterSink{Value: float64(sec)}, time.Duration(sec)*time.Second)
+			assert.NoError(t, err)
+		}
+		assert.Len(t, ts.history, 6)
+	})
+}
+
+func TestThresholdsQuantile(t *testing.T) {
+	sink := &TrendSink{}
+	for i := 1; i <= 100; i++ {
+		sink.Add(Sample{Value: float64(i)})
+	}
+	ts, err := NewThresholds([]string{"quantile(0.95) == p(95)"})
+	assert.NoError(t, err)
+	b, err := ts.Run(sink, 0)
+	assert.NoError(t, err)
+	assert.True(t, b)
+}
+
+func TestThresholdsQuantileMetricTypes(t *testing.T) {
+	trend := &TrendSink{}
+	for i := 1; i <= 100; i++ {
+		trend.Add(Sample{Value: float64(i)})
+	}
+	testdata := map[MetricType]Sink{
+		Counter: &CounterSink{Value: 10},
+		Gauge:   &GaugeSink{Value: 10, Max: 10, Min: 10},
+		Rate:    &RateSink{Trues: 9, Total: 10},
+		Trend:   trend,
+	}
+	for typ, sink := range testdata {
+		name := strings.Trim(typ.String(), `"`)
+		t.Run(name, func(t *testing.T) {
+			for _, src := range []string{"quantile(0.95) < 100", "p(95) < 100"} {
+				ts, err := NewThresholds([]string{src})
+				if !assert.NoError(t, err) {
+					continue
+				}
+				b, runErr := ts.Run(sink, 0)
+				err = ts.Validate(typ)
+				if typ == Trend {
+					assert.NoError(t, err)
+					assert.NoError(t, runErr)
+					assert.True(t, b)
+					continue
+				}
+				fn := src[:strings.Index(src, "(")]
+				assert.EqualError(t, err, "threshold '"+src+"' uses "+fn+"(), which is only available on trend metrics, not on "+name+" metrics")
+				if assert.Error(t, runErr) {
+					assert.Contains(t, runErr.Error(), "p() and quantile() are only available in thresholds on trend metrics")
+				}
+			}
+
+			// The p() of another metric is fine on any type.
+			ts, err := NewThresholds([]string{"metric('http_req_duration').p(95) < 1"})
+			if assert.NoError(t, err) {
+				assert.NoError(t, ts.Validate(typ))
+			}
+		})
+	}
+}
+
+func TestThresholdsMetrics(t *testing.T) {
+	trend := &TrendSink{}
+	for _, v := range []float64{100, 200, 300, 400} {
+		trend.Add(Sample{Value: v})
+	}
+	sinks := map[string]Sink{
+		"checks":   &RateSink{Trues: 9, Total: 10},
+		"duration": trend,
+	}
+
+	testdata := map[string]bool{
+		"rate > 0.5 && rate < metric('checks').rate":             true,
+		"rate >= metric('checks').rate":                          false,
+		"metric('duration').avg == 250":                          true,
+		"metric('duration').p(50) < 2 * metric('duration').min":  false,
+		"metric('duration').max - metric('duration').min == 300": true,
+	}
+	for src, pass := range testdata {
+		t.Run(src, func(t *testing.T) {
+			ts, err := NewThresholds([]string{src})
+			assert.NoError(t, err)
+			ts.Metrics = func(name string) Sink { return sinks[name] }
+
+			b, err := ts.Run(&RateSink{Trues: 8, Total: 10}, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, pass, b)
+		})
+	}
+
+	t.Run("no samples", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"rate < metric('nope').rate"})
+		assert.NoError(t, err)
+		ts.Metrics = func(name string) Sink { return sinks[name] }
+		_, err = ts.Run(&RateSink{Trues: 8, Total: 10}, 0)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "metric nope has no samples")
+	})
+	t.Run("no metrics", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"rate < metric('checks').rate"})
+		assert.NoError(t, err)
+		_, err = ts.Run(&RateSink{Trues: 8, Total: 10}, 0)
+		assert.Error(t, err)
+	})
+}
+
 func TestThresholdsJSON(t *testing.T) {
 	var testdata = []struct {
 		JSON        string
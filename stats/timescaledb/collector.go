@@ -0,0 +1,182 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timescaledb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pushInterval = 1 * time.Second
+)
+
+var _ lib.Collector = &Collector{}
+
+// Collector writes samples into a Postgres table, with a row for every sample. The table is
+// created if it doesn't exist, and made a hypertable if the TimescaleDB extension is installed.
+type Collector struct {
+	DB     *sql.DB
+	Config Config
+
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+}
+
+func New(conf Config) (*Collector, error) {
+	db, err := sql.Open("postgres", conf.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &Collector{DB: db, Config: conf}, nil
+}
+
+func (c *Collector) Init() error {
+	table := pq.QuoteIdentifier(c.Config.Table)
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+			ts timestamptz NOT NULL,
+			metric varchar(128) NOT NULL,
+			tags jsonb,
+			value double precision
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(c.Config.Table+"_metric_ts") +
+			` ON ` + table + ` (metric, ts DESC)`,
+	}
+	for _, stmt := range schema {
+		if _, err := c.DB.Exec(stmt); err != nil {
+			return errors.Wrap(err, "couldn't create the samples table")
+		}
+	}
+
+	var timescale bool
+	err := c.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')`).Scan(&timescale)
+	if err != nil {
+		return errors.Wrap(err, "couldn't check for the TimescaleDB extension")
+	}
+	if timescale {
+		_, err := c.DB.Exec(`SELECT create_hypertable($1, 'ts', if_not_exists => TRUE)`, c.Config.Table)
+		if err != nil {
+			return errors.Wrap(err, "couldn't make the samples table a hypertable")
+		}
+	}
+	return nil
+}
+
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("TimescaleDB: Running!")
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.commit()
+		case <-ctx.Done():
+			c.commit()
+			_ = c.DB.Close()
+			return
+		}
+	}
+}
+
+func (c *Collector) Collect(samples []stats.Sample) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, samples...)
+	c.bufferLock.Unlock()
+}
+
+// Link returns the database's URL, without the password.
+func (c *Collector) Link() string {
+	u, err := url.Parse(c.Config.URL)
+	if err != nil {
+		return ""
+	}
+	if u.User != nil {
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+func (c *Collector) commit() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	if len(samples) == 0 {
+		return
+	}
+	log.WithField("samples", len(samples)).Debug("TimescaleDB: Writing...")
+	startTime := time.Now()
+	if err := c.copy(samples); err != nil {
+		log.WithError(err).Error("TimescaleDB: Couldn't write samples")
+		return
+	}
+	log.WithField("t", time.Since(startTime)).Debug("TimescaleDB: Samples written!")
+}
+
+// copy inserts samples with COPY, which is much faster than INSERTs for big batches.
+func (c *Collector) copy(samples []stats.Sample) error {
+	txn, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := txn.Prepare(pq.CopyIn(c.Config.Table, "ts", "metric", "tags", "value"))
+	if err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+	for _, sample := range samples {
+		tags, err := json.Marshal(sample.Tags)
+		if err != nil {
+			_ = txn.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(sample.Time, sample.Metric.Name, string(tags), sample.Value); err != nil {
+			_ = txn.Rollback()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+	return txn.Commit()
+}
@@ -0,0 +1,143 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timescaledb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver records the statements it's given, so tests don't need a real database. It
+// pretends that the TimescaleDB extension is installed.
+type fakeDriver struct{ log *[]string }
+
+type fakeConn struct{ log *[]string }
+
+type fakeStmt struct {
+	log   *[]string
+	query string
+}
+
+type fakeRows struct{ done bool }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn(d), nil }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.log, query}, nil }
+func (fakeConn) Close() error                                { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	*c.log = append(*c.log, "BEGIN")
+	return c, nil
+}
+func (c fakeConn) Commit() error {
+	*c.log = append(*c.log, "COMMIT")
+	return nil
+}
+func (c fakeConn) Rollback() error {
+	*c.log = append(*c.log, "ROLLBACK")
+	return nil
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	entry := strings.Join(strings.Fields(s.query), " ")
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			entry += " | " + v.UTC().Format(time.RFC3339)
+		case float64:
+			entry += " | " + strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			entry += " | " + v.(string)
+		}
+	}
+	*s.log = append(*s.log, entry)
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+func (*fakeRows) Columns() []string { return []string{"exists"} }
+func (*fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = true
+	return nil
+}
+
+func TestCollector(t *testing.T) {
+	var log []string
+	sql.Register("fake", fakeDriver{&log})
+	db, err := sql.Open("fake", "")
+	require.NoError(t, err)
+
+	c := &Collector{DB: db, Config: *NewConfig()}
+	require.NoError(t, c.Init())
+	assert.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "samples" ( ts timestamptz NOT NULL, metric varchar(128) NOT NULL, tags jsonb, value double precision )`,
+		`CREATE INDEX IF NOT EXISTS "samples_metric_ts" ON "samples" (metric, ts DESC)`,
+		`SELECT create_hypertable($1, 'ts', if_not_exists => TRUE) | samples`,
+	}, log)
+
+	log = nil
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Collect([]stats.Sample{
+		{Metric: metrics.HTTPReqs, Tags: stats.IntoSampleTags(&map[string]string{"status": "200"}), Time: now, Value: 1},
+		{Metric: metrics.VUs, Time: now, Value: 5},
+	})
+	c.commit()
+	assert.Equal(t, []string{
+		"BEGIN",
+		`COPY "samples" ("ts", "metric", "tags", "value") FROM STDIN | 2018-01-02T03:04:05Z | http_reqs | {"status":"200"} | 1`,
+		`COPY "samples" ("ts", "metric", "tags", "value") FROM STDIN | 2018-01-02T03:04:05Z | vus | null | 5`,
+		`COPY "samples" ("ts", "metric", "tags", "value") FROM STDIN`,
+		"COMMIT",
+	}, log)
+
+	t.Run("Empty", func(t *testing.T) {
+		log = nil
+		c.commit()
+		assert.Empty(t, log)
+	})
+}
+
+func TestConfigText(t *testing.T) {
+	var config Config
+	require.NoError(t, config.UnmarshalText([]byte("postgres://k6:secret@db:5432/results?sslmode=disable&table=k6_samples")))
+	assert.Equal(t, Config{URL: "postgres://k6:secret@db:5432/results?sslmode=disable", Table: "k6_samples"}, config)
+	assert.Equal(t, "postgres://k6@db:5432/results?sslmode=disable", (&Collector{Config: config}).Link())
+}
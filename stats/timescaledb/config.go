@@ -0,0 +1,80 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timescaledb
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+type ConfigFields struct {
+	URL   string `json:"url" envconfig:"TIMESCALEDB_URL"`
+	Table string `json:"table,omitempty" envconfig:"TIMESCALEDB_TABLE"`
+}
+
+type Config ConfigFields
+
+func NewConfig() *Config {
+	return &Config{URL: "postgres://localhost/k6?sslmode=disable", Table: "samples"}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.URL != "" {
+		c.URL = cfg.URL
+	}
+	if cfg.Table != "" {
+		c.Table = cfg.Table
+	}
+	return c
+}
+
+// UnmarshalText reads an --out argument, which is a Postgres connection URL; the table can be
+// given with the table query parameter, and the rest of the parameters are the connection's.
+func (c *Config) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		return nil
+	}
+	u, err := url.Parse(string(text))
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	if table := query.Get("table"); table != "" {
+		c.Table = table
+	}
+	query.Del("table")
+	u.RawQuery = query.Encode()
+	c.URL = u.String()
+	return nil
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	fields := ConfigFields(*c)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*c = Config(fields)
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ConfigFields(c))
+}
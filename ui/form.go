@@ -24,8 +24,10 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/fatih/color"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // A Field in a form.
@@ -60,9 +62,18 @@ func (f Form) Run(r io.Reader, w io.Writer) (map[string]interface{}, error) {
 			}
 			fmt.Fprintf(w, "  "+displayLabel+": ")
 
-			color.Set(color.FgCyan)
-			s, err := buf.ReadString('\n')
-			color.Unset()
+			var s string
+			var err error
+			if fd, ok := terminalFd(r); ok && isPasswordField(field) {
+				var pw []byte
+				pw, err = terminal.ReadPassword(fd)
+				fmt.Fprintln(w)
+				s = string(pw)
+			} else {
+				color.Set(color.FgCyan)
+				s, err = buf.ReadString('\n')
+				color.Unset()
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -80,3 +91,17 @@ func (f Form) Run(r io.Reader, w io.Writer) (map[string]interface{}, error) {
 
 	return data, nil
 }
+
+func isPasswordField(field Field) bool {
+	_, ok := field.(PasswordField)
+	return ok
+}
+
+// Returns the file descriptor of r, if it's a terminal.
+func terminalFd(r io.Reader) (int, bool) {
+	f, ok := r.(*os.File)
+	if !ok || !terminal.IsTerminal(int(f.Fd())) {
+		return 0, false
+	}
+	return int(f.Fd()), true
+}
@@ -27,6 +27,7 @@ import (
 )
 
 var _ Field = StringField{}
+var _ Field = PasswordField{}
 
 type StringField struct {
 	Key     string
@@ -62,3 +63,40 @@ func (f StringField) Clean(s string) (interface{}, error) {
 	}
 	return s, nil
 }
+
+// A PasswordField is a field for a secret; it isn't echoed when typed into a terminal, and its
+// default, if any, isn't shown.
+type PasswordField struct {
+	Key     string
+	Label   string
+	Default string
+
+	// Length constraints.
+	Min int
+}
+
+func (f PasswordField) GetKey() string {
+	return f.Key
+}
+
+func (f PasswordField) GetLabel() string {
+	return f.Label
+}
+
+func (f PasswordField) GetLabelExtra() string {
+	if f.Default == "" {
+		return ""
+	}
+	return "unchanged"
+}
+
+func (f PasswordField) Clean(s string) (interface{}, error) {
+	s = strings.TrimRight(s, "\r\n")
+	if f.Min != 0 && len(s) < f.Min {
+		return nil, errors.Errorf("invalid input, min length is %d", f.Min)
+	}
+	if s == "" {
+		s = f.Default
+	}
+	return s, nil
+}
@@ -61,3 +61,30 @@ func TestStringField(t *testing.T) {
 		assert.Equal(t, "default", v)
 	})
 }
+
+func TestPasswordField(t *testing.T) {
+	f := PasswordField{Key: "key", Label: "label"}
+	assert.Equal(t, "key", f.GetKey())
+	assert.Equal(t, "label", f.GetLabel())
+	assert.Equal(t, "", f.GetLabelExtra())
+
+	t.Run("Valid", func(t *testing.T) {
+		v, err := f.Clean(" s3cret \r\n")
+		assert.NoError(t, err)
+		assert.Equal(t, " s3cret ", v)
+	})
+	t.Run("Min", func(t *testing.T) {
+		f := f
+		f.Min = 10
+		_, err := f.Clean("short")
+		assert.EqualError(t, err, "invalid input, min length is 10")
+	})
+	t.Run("Default", func(t *testing.T) {
+		f := f
+		f.Default = "default"
+		assert.Equal(t, "unchanged", f.GetLabelExtra())
+		v, err := f.Clean("\n")
+		assert.NoError(t, err)
+		assert.Equal(t, "default", v)
+	})
+}
@@ -68,6 +68,19 @@ func TestForm(t *testing.T) {
 		assert.Equal(t, map[string]interface{}{"a": "1", "b": "2"}, data)
 		assert.Equal(t, "  label a:   label b: ", out.String())
 	})
+	t.Run("Password", func(t *testing.T) {
+		f := Form{
+			Fields: []Field{
+				PasswordField{Key: "pass", Label: "password", Default: "old"},
+			},
+		}
+		out := bytes.NewBuffer(nil)
+		data, err := f.Run(strings.NewReader("new\n"), out)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"pass": "new"}, data)
+		assert.Contains(t, out.String(), "password")
+		assert.NotContains(t, out.String(), "old")
+	})
 	t.Run("Defaults", func(t *testing.T) {
 		f := Form{
 			Fields: []Field{
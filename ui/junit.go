@@ -0,0 +1,136 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// junitTestSuites is the root of a JUnit XML report, as CI servers read it.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+func (s *junitTestSuite) add(c junitTestCase) {
+	s.Tests++
+	if c.Failure != nil {
+		s.Failures++
+	}
+	s.Cases = append(s.Cases, c)
+}
+
+// JUnit returns the summary as a JUnit XML report: every threshold is a test case in the
+// "thresholds" suite, and every check one in the "checks" suite, which failed if the check ever
+// did.
+func (d SummaryData) JUnit() ([]byte, error) {
+	thresholds := junitTestSuite{Name: "thresholds"}
+	names := make([]string, 0, len(d.Metrics))
+	for name := range d.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, th := range d.Metrics[name].Thresholds.Thresholds {
+			c := junitTestCase{Name: name + ": " + th.Source, ClassName: "k6.thresholds." + name}
+			if th.Failed {
+				c.Failure = &junitFailure{Message: fmt.Sprintf("%s failed the threshold %s", name, th.Source)}
+			}
+			thresholds.add(c)
+		}
+	}
+
+	checks := junitTestSuite{Name: "checks"}
+	if d.Root != nil {
+		addJUnitChecks(&checks, d.Root)
+	}
+
+	report := junitTestSuites{
+		Time:   fmt.Sprintf("%.3f", d.Time.Seconds()),
+		Suites: []junitTestSuite{thresholds, checks},
+	}
+	for _, suite := range report.Suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func addJUnitChecks(suite *junitTestSuite, group *lib.Group) {
+	className := "k6.checks"
+	if group.Path != "" {
+		className += strings.Replace(group.Path, lib.GroupSeparator, ".", -1)
+	}
+
+	checkNames := make([]string, 0, len(group.Checks))
+	for name := range group.Checks {
+		checkNames = append(checkNames, name)
+	}
+	sort.Strings(checkNames)
+	for _, name := range checkNames {
+		check := group.Checks[name]
+		c := junitTestCase{Name: check.Name, ClassName: className}
+		if check.Fails > 0 {
+			c.Failure = &junitFailure{Message: fmt.Sprintf(
+				"%d of %d checks failed", check.Fails, check.Passes+check.Fails,
+			)}
+		}
+		suite.add(c)
+	}
+
+	groupNames := make([]string, 0, len(group.Groups))
+	for name := range group.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		addJUnitChecks(suite, group.Groups[name])
+	}
+}
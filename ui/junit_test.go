@@ -0,0 +1,75 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJUnit(t *testing.T) {
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	rootCheck, err := root.Check("status is 200")
+	require.NoError(t, err)
+	rootCheck.Passes = 10
+	group, err := root.Group("login")
+	require.NoError(t, err)
+	check, err := group.Check("has token")
+	require.NoError(t, err)
+	check.Passes, check.Fails = 3, 1
+
+	trend := stats.New("http_req_duration", stats.Trend, stats.Time)
+	trend.Sink = createTestTrendSink(3)
+	thresholds, err := stats.NewThresholds([]string{"avg<1", "max<10"})
+	require.NoError(t, err)
+	trend.Thresholds = thresholds
+	_, err = trend.Thresholds.Run(trend.Sink, time.Second)
+	require.NoError(t, err)
+
+	data, err := SummaryData{
+		Root:    root,
+		Metrics: map[string]*stats.Metric{"http_req_duration": trend, "iterations": stats.New("iterations", stats.Counter)},
+		Time:    1500 * time.Millisecond,
+	}.JUnit()
+	require.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="4" failures="2" time="1.500">
+  <testsuite name="thresholds" tests="2" failures="1">
+    <testcase name="http_req_duration: avg&lt;1" classname="k6.thresholds.http_req_duration">
+      <failure message="http_req_duration failed the threshold avg&lt;1"></failure>
+    </testcase>
+    <testcase name="http_req_duration: max&lt;10" classname="k6.thresholds.http_req_duration"></testcase>
+  </testsuite>
+  <testsuite name="checks" tests="2" failures="1">
+    <testcase name="status is 200" classname="k6.checks"></testcase>
+    <testcase name="has token" classname="k6.checks.login">
+      <failure message="1 of 4 checks failed"></failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(data))
+}
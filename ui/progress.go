@@ -0,0 +1,140 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// How long the iteration rate of a scenario is measured over.
+const rateInterval = 1 * time.Second
+
+// ProgressUI draws the progress of a running test: a bar for the whole test, followed by one for
+// every scenario of it with its VUs, iteration rate and ETA. On a terminal they're redrawn in
+// place; otherwise there's only Fields(), for plain periodic log lines.
+type ProgressUI struct {
+	Width         int // Of the bar for the whole test; the scenario bars are narrower.
+	ScenarioWidth int
+
+	drawn int // Lines drawn last time, to move the cursor back over.
+	rates map[string]*iterationRate
+}
+
+// iterationRate measures the rate of a scenario's iterations over the last rateInterval.
+type iterationRate struct {
+	at    time.Duration
+	iters int64
+	rate  float64
+}
+
+// rate returns the rate of a scenario's iterations per second, averaged over the last full
+// rateInterval, or over the whole scenario while it hasn't been running for that long yet.
+func (u *ProgressUI) rate(p lib.ScenarioProgress) float64 {
+	if u.rates == nil {
+		u.rates = make(map[string]*iterationRate)
+	}
+	r, ok := u.rates[p.Name]
+	if !ok {
+		r = &iterationRate{}
+		u.rates[p.Name] = r
+	}
+	if d := p.Elapsed - r.at; d >= rateInterval {
+		r.rate = float64(p.Iterations-r.iters) / d.Seconds()
+		r.at, r.iters = p.Elapsed, p.Iterations
+	} else if r.at == 0 && p.Elapsed > 0 {
+		return float64(p.Iterations) / p.Elapsed.Seconds()
+	}
+	return r.rate
+}
+
+// Render draws the bar of the whole test and those of its scenarios, over what was drawn last
+// time; the cursor is left on the line below them.
+func (u *ProgressUI) Render(w io.Writer, test ProgressBar, scenarios []lib.ScenarioProgress) {
+	lines := append(make([]string, 0, len(scenarios)+1), test.String())
+	nameWidth := 0
+	for _, p := range scenarios {
+		if len(p.Name) > nameWidth {
+			nameWidth = len(p.Name)
+		}
+	}
+	for _, p := range scenarios {
+		p := p
+		bar := ProgressBar{
+			Width:    u.ScenarioWidth,
+			Progress: p.Fraction,
+			Left:     func() string { return fmt.Sprintf("  %-*s", nameWidth, p.Name) },
+			Right:    func() string { return u.describe(p) },
+		}
+		lines = append(lines, bar.String())
+	}
+
+	var buf strings.Builder
+	if u.drawn > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dA", u.drawn)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(&buf, "\r%s\x1b[0K\n", line)
+	}
+	buf.WriteString("\x1b[0J")
+	_, _ = io.WriteString(w, buf.String())
+	u.drawn = len(lines)
+}
+
+// describe returns what's shown next to the bar of a scenario.
+func (u *ProgressUI) describe(p lib.ScenarioProgress) string {
+	switch {
+	case !p.Started:
+		return "waiting"
+	case p.Done:
+		return fmt.Sprintf("done, %d iterations", p.Iterations)
+	}
+	s := fmt.Sprintf("%d VUs, %d iterations, %.1f it/s", p.VUs, p.Iterations, u.rate(p))
+	if p.Remaining.Valid {
+		s += ", ETA " + formatETA(time.Duration(p.Remaining.Duration))
+	}
+	return s
+}
+
+// Fields returns the progress of a scenario as the fields of a log line, for when the output
+// isn't a terminal that the bars can be drawn on.
+func (u *ProgressUI) Fields(p lib.ScenarioProgress) map[string]interface{} {
+	fields := map[string]interface{}{
+		"scenario": p.Name,
+		"vus":      p.VUs,
+		"i":        p.Iterations,
+		"it/s":     fmt.Sprintf("%.1f", u.rate(p)),
+		"progress": fmt.Sprintf("%.0f%%", p.Fraction*100),
+	}
+	if p.Remaining.Valid {
+		fields["eta"] = formatETA(time.Duration(p.Remaining.Duration))
+	}
+	return fields
+}
+
+// formatETA rounds an estimated time to whole seconds, as anything finer just flickers.
+func formatETA(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,100 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressUIRender(t *testing.T) {
+	u := ProgressUI{Width: 20, ScenarioWidth: 10}
+	test := ProgressBar{Width: 20, Progress: 0.5, Left: func() string { return " running" }}
+	scenarios := []lib.ScenarioProgress{
+		{
+			Name: "browse", Started: true, VUs: 2, Iterations: 10, Fraction: 0.5,
+			Elapsed: 2 * time.Second, Remaining: types.NullDurationFrom(1600 * time.Millisecond),
+		},
+		{Name: "api", Started: true, Done: true, Iterations: 5, Fraction: 1},
+		{Name: "soak"},
+	}
+
+	var buf bytes.Buffer
+	u.Render(&buf, test, scenarios)
+	out := buf.String()
+	assert.False(t, strings.HasPrefix(out, "\x1b["), "moved the cursor up on the first render")
+	lines := strings.Split(strings.TrimSuffix(out, "\n\x1b[0J"), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Contains(t, lines[0], " running [")
+		assert.Contains(t, lines[1], "  browse [")
+		assert.Contains(t, lines[1], "] 2 VUs, 10 iterations, 5.0 it/s, ETA 2s")
+		assert.Contains(t, lines[2], "  api    [")
+		assert.Contains(t, lines[2], "] done, 5 iterations")
+		assert.Contains(t, lines[3], "  soak   [")
+		assert.Contains(t, lines[3], "] waiting")
+	}
+
+	buf.Reset()
+	u.Render(&buf, test, scenarios[:1])
+	assert.True(t, strings.HasPrefix(buf.String(), "\x1b[4A\r"), "didn't redraw over the last render")
+	assert.True(t, strings.HasSuffix(buf.String(), "\n\x1b[0J"), "didn't clear the lines no longer drawn")
+}
+
+func TestProgressUIFields(t *testing.T) {
+	u := ProgressUI{}
+	p := lib.ScenarioProgress{
+		Name: "browse", Started: true, VUs: 3, Iterations: 5, Fraction: 0.25,
+		Elapsed: 500 * time.Millisecond, Remaining: types.NullDurationFrom(1500 * time.Millisecond),
+	}
+	assert.Equal(t, map[string]interface{}{
+		"scenario": "browse",
+		"vus":      int64(3),
+		"i":        int64(5),
+		"it/s":     "10.0",
+		"progress": "25%",
+		"eta":      "2s",
+	}, u.Fields(p))
+
+	t.Run("rate", func(t *testing.T) {
+		// Once it's been running for a while, the rate is that of the last second measured.
+		u := ProgressUI{}
+		for _, step := range []struct {
+			elapsed time.Duration
+			iters   int64
+			rate    string
+		}{
+			{500 * time.Millisecond, 5, "10.0"},
+			{1 * time.Second, 10, "10.0"},
+			{1500 * time.Millisecond, 40, "10.0"},
+			{2 * time.Second, 12, "2.0"},
+			{3 * time.Second, 42, "30.0"},
+		} {
+			p := lib.ScenarioProgress{Name: "browse", Started: true, Elapsed: step.elapsed, Iterations: step.iters}
+			assert.Equal(t, step.rate, u.Fields(p)["it/s"], "at %s", step.elapsed)
+		}
+	})
+}
@@ -163,6 +163,16 @@ type SummaryData struct {
 	Root    *lib.Group
 	Metrics map[string]*stats.Metric
 	Time    time.Duration
+
+	// The metrics of every scenario of the test, by scenario and metric name, if it has any.
+	Scenarios map[string]map[string]*stats.Metric
+
+	// The group_duration of every group, by group path, shown along with the group tree.
+	GroupDurations map[string]*stats.Metric
+
+	// Whether the script aborted the test, eg. with execution.abort(), and why.
+	Aborted     bool
+	AbortReason string
 }
 
 func SummarizeCheck(w io.Writer, indent string, check *lib.Check) {
@@ -182,10 +192,16 @@ func SummarizeCheck(w io.Writer, indent string, check *lib.Check) {
 	}
 }
 
-func SummarizeGroup(w io.Writer, indent string, group *lib.Group) {
+// SummarizeGroup prints a group's checks and subgroups, each group along with how long it took if
+// durations has its group_duration.
+func SummarizeGroup(w io.Writer, indent string, group *lib.Group, durations map[string]*stats.Metric) {
 	if group.Name != "" {
 		_, _ = fmt.Fprintf(w, "%s%s %s\n\n", indent, GroupPrefix, group.Name)
 		indent = indent + "  "
+		if m := durations[group.Path]; m != nil {
+			SummarizeMetrics(w, indent, 0, map[string]*stats.Metric{m.Name: m})
+			fmt.Fprintf(w, "\n")
+		}
 	}
 
 	var checkNames []string
@@ -206,7 +222,7 @@ func SummarizeGroup(w io.Writer, indent string, group *lib.Group) {
 	}
 	sort.Strings(groupNames)
 	for _, name := range groupNames {
-		SummarizeGroup(w, indent, group.Groups[name])
+		SummarizeGroup(w, indent, group.Groups[name], durations)
 	}
 }
 
@@ -352,7 +368,166 @@ func SummarizeMetrics(w io.Writer, indent string, t time.Duration, metrics map[s
 // Summarizes a dataset and returns whether the test run was considered a success.
 func Summarize(w io.Writer, indent string, data SummaryData) {
 	if data.Root != nil {
-		SummarizeGroup(w, indent+"    ", data.Root)
+		SummarizeGroup(w, indent+"    ", data.Root, data.GroupDurations)
 	}
 	SummarizeMetrics(w, indent+"  ", data.Time, data.Metrics)
+	SummarizeScenarios(w, indent+"  ", data.Time, data.Scenarios)
+}
+
+// SummarizeScenarios breaks the metrics down by scenario, in the order of their names, skipping
+// the ones that didn't emit any.
+func SummarizeScenarios(w io.Writer, indent string, t time.Duration, scenarios map[string]map[string]*stats.Metric) {
+	var names []string
+	for name, metrics := range scenarios {
+		if len(metrics) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "\n%s  %s scenario %s\n\n", indent, GroupPrefix, name)
+		SummarizeMetrics(w, indent+"    ", t, scenarios[name])
+	}
+}
+
+// MetricValues returns the values shown for a metric in the summary, by name; trends have the
+// configured trend columns.
+func MetricValues(t time.Duration, m *stats.Metric) map[string]float64 {
+	switch sink := m.Sink.(type) {
+	case *stats.CounterSink:
+		rate := 0.0
+		if t > 0 {
+			rate = sink.Value / (float64(t) / float64(time.Second))
+		}
+		return map[string]float64{"count": sink.Value, "rate": rate}
+	case *stats.GaugeSink:
+		return map[string]float64{"value": sink.Value, "min": sink.Min, "max": sink.Max}
+	case *stats.TrendSink:
+		sink.Calc()
+		values := make(map[string]float64, len(TrendColumns))
+		for _, col := range TrendColumns {
+			values[col.Key] = col.Get(sink)
+		}
+		return values
+	case *stats.RateSink:
+		rate := 0.0
+		if sink.Total > 0 {
+			rate = float64(sink.Trues) / float64(sink.Total)
+		}
+		return map[string]float64{
+			"rate":   rate,
+			"passes": float64(sink.Trues),
+			"fails":  float64(sink.Total - sink.Trues),
+		}
+	default:
+		return map[string]float64{}
+	}
+}
+
+// Export returns the summary as plain data, the way scripts and machine-readable reports see it:
+// the test run's ID, metadata and duration and whether the script aborted it, every metric's values
+// and thresholds, and the group and check tree along with the groups' durations.
+func (d SummaryData) Export() map[string]interface{} {
+	metrics := make(map[string]interface{}, len(d.Metrics))
+	for name, m := range d.Metrics {
+		metric := map[string]interface{}{
+			"type":     strings.Trim(m.Type.String(), `"`),
+			"contains": strings.Trim(m.Contains.String(), `"`),
+			"values":   MetricValues(d.Time, m),
+		}
+		if len(m.Thresholds.Thresholds) > 0 {
+			thresholds := make(map[string]interface{}, len(m.Thresholds.Thresholds))
+			for _, th := range m.Thresholds.Thresholds {
+				thresholds[th.Source] = map[string]interface{}{"ok": !th.Failed}
+			}
+			metric["thresholds"] = thresholds
+		}
+		metrics[name] = metric
+	}
+
+	trendStats := make([]interface{}, len(TrendColumns))
+	for i, col := range TrendColumns {
+		trendStats[i] = col.Key
+	}
+
+	state := map[string]interface{}{"testRunDurationMs": float64(d.Time) / float64(time.Millisecond)}
+	if d.Opts.RunID.Valid {
+		state["testRunId"] = d.Opts.RunID.String
+	}
+	if d.Aborted {
+		state["testAborted"] = true
+		state["testAbortReason"] = d.AbortReason
+	}
+	data := map[string]interface{}{
+		"state":   state,
+		"options": map[string]interface{}{"summaryTrendStats": trendStats},
+		"metrics": metrics,
+	}
+	if len(d.Opts.Metadata) > 0 {
+		metadata := make(map[string]interface{}, len(d.Opts.Metadata))
+		for k, v := range d.Opts.Metadata {
+			metadata[k] = v
+		}
+		data["metadata"] = metadata
+	}
+	if d.Root != nil {
+		data["root_group"] = d.exportGroup(d.Root)
+	}
+	if len(d.Scenarios) > 0 {
+		scenarios := make(map[string]interface{}, len(d.Scenarios))
+		for name, scMetrics := range d.Scenarios {
+			metrics := make(map[string]interface{}, len(scMetrics))
+			for metricName, m := range scMetrics {
+				metrics[metricName] = map[string]interface{}{
+					"type":     strings.Trim(m.Type.String(), `"`),
+					"contains": strings.Trim(m.Contains.String(), `"`),
+					"values":   MetricValues(d.Time, m),
+				}
+			}
+			scenarios[name] = map[string]interface{}{"metrics": metrics}
+		}
+		data["scenarios"] = scenarios
+	}
+	return data
+}
+
+func (d SummaryData) exportGroup(group *lib.Group) map[string]interface{} {
+	var groupNames []string
+	for name := range group.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	groups := make([]interface{}, len(groupNames))
+	for i, name := range groupNames {
+		groups[i] = d.exportGroup(group.Groups[name])
+	}
+
+	var checkNames []string
+	for name := range group.Checks {
+		checkNames = append(checkNames, name)
+	}
+	sort.Strings(checkNames)
+	checks := make([]interface{}, len(checkNames))
+	for i, name := range checkNames {
+		check := group.Checks[name]
+		checks[i] = map[string]interface{}{
+			"name":   check.Name,
+			"path":   check.Path,
+			"id":     check.ID,
+			"passes": check.Passes,
+			"fails":  check.Fails,
+		}
+	}
+
+	data := map[string]interface{}{
+		"name":   group.Name,
+		"path":   group.Path,
+		"id":     group.ID,
+		"groups": groups,
+		"checks": checks,
+	}
+	if m := d.GroupDurations[group.Path]; m != nil {
+		data["duration"] = MetricValues(d.Time, m)
+	}
+	return data
 }
@@ -21,10 +21,16 @@
 package ui
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 )
 
 var verifyTests = []struct {
@@ -158,3 +164,154 @@ func TestGeneratePercentileTrendColumn(t *testing.T) {
 		assert.Exactly(t, err, ErrPercentileStatInvalidValue)
 	})
 }
+
+func TestSummaryExport(t *testing.T) {
+	TrendColumns = defaultTrendColumns
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	group, err := root.Group("my group")
+	require.NoError(t, err)
+	check, err := group.Check("my check")
+	require.NoError(t, err)
+	check.Passes, check.Fails = 3, 1
+
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	trend.Sink = createTestTrendSink(3)
+	thresholds, err := stats.NewThresholds([]string{"avg<1", "max<10"})
+	require.NoError(t, err)
+	trend.Thresholds = thresholds
+	_, err = trend.Thresholds.Run(trend.Sink, time.Second)
+	require.NoError(t, err)
+
+	counter := stats.New("my_counter", stats.Counter)
+	counter.Sink.Add(stats.Sample{Value: 10})
+	rate := stats.New("my_rate", stats.Rate)
+
+	data := SummaryData{
+		Root:    root,
+		Metrics: map[string]*stats.Metric{"my_trend": trend, "my_counter": counter, "my_rate": rate},
+		Time:    2 * time.Second,
+	}.Export()
+
+	assert.Equal(t, map[string]interface{}{"testRunDurationMs": 2000.0}, data["state"])
+	aborted := SummaryData{Time: time.Second, Aborted: true, AbortReason: "smoke check failed"}.Export()
+	assert.Equal(t, map[string]interface{}{
+		"testRunDurationMs": 1000.0, "testAborted": true, "testAbortReason": "smoke check failed",
+	}, aborted["state"])
+	assert.NotContains(t, data, "metadata")
+	described := SummaryData{Time: time.Second, Opts: lib.Options{
+		RunID:    null.StringFrom("abc123"),
+		Metadata: map[string]string{"environment": "staging", "commit": "4f2a1c"},
+	}}.Export()
+	assert.Equal(t, map[string]interface{}{"testRunDurationMs": 1000.0, "testRunId": "abc123"}, described["state"])
+	assert.Equal(t, map[string]interface{}{"environment": "staging", "commit": "4f2a1c"}, described["metadata"])
+	assert.Equal(t, map[string]interface{}{
+		"summaryTrendStats": []interface{}{"avg", "min", "med", "max", "p(90)", "p(95)"},
+	}, data["options"])
+
+	metrics := data["metrics"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"type":     "trend",
+		"contains": "time",
+		"values": map[string]float64{
+			"avg": 1, "min": 0, "med": 1, "max": 2, "p(90)": 1.8, "p(95)": 1.9,
+		},
+		"thresholds": map[string]interface{}{
+			"avg<1":  map[string]interface{}{"ok": false},
+			"max<10": map[string]interface{}{"ok": true},
+		},
+	}, metrics["my_trend"])
+	assert.Equal(t, map[string]interface{}{
+		"type":     "counter",
+		"contains": "default",
+		"values":   map[string]float64{"count": 10, "rate": 5},
+	}, metrics["my_counter"])
+	assert.Equal(t, map[string]float64{"rate": 0, "passes": 0, "fails": 0},
+		metrics["my_rate"].(map[string]interface{})["values"])
+
+	rootGroup := data["root_group"].(map[string]interface{})
+	assert.Equal(t, "", rootGroup["name"])
+	assert.Empty(t, rootGroup["checks"])
+	groups := rootGroup["groups"].([]interface{})
+	require.Len(t, groups, 1)
+	assert.Equal(t, map[string]interface{}{
+		"name":   "my group",
+		"path":   "::my group",
+		"id":     group.ID,
+		"groups": []interface{}{},
+		"checks": []interface{}{map[string]interface{}{
+			"name":   "my check",
+			"path":   "::my group::my check",
+			"id":     check.ID,
+			"passes": int64(3),
+			"fails":  int64(1),
+		}},
+	}, groups[0])
+}
+
+func TestSummarizeGroupDurations(t *testing.T) {
+	TrendColumns = defaultTrendColumns
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	login, err := root.Group("login")
+	require.NoError(t, err)
+	_, err = root.Group("browse")
+	require.NoError(t, err)
+
+	duration := stats.New("group_duration", stats.Trend, stats.Time)
+	duration.Sink = createTestTrendSink(3)
+	data := SummaryData{
+		Root:           root,
+		Metrics:        map[string]*stats.Metric{},
+		Time:           2 * time.Second,
+		GroupDurations: map[string]*stats.Metric{login.Path: duration},
+	}
+
+	var buf bytes.Buffer
+	Summarize(&buf, "", data)
+	out := buf.String()
+	loginAt := strings.Index(out, GroupPrefix+" login")
+	require.True(t, loginAt >= 0)
+	assert.Contains(t, out[loginAt:], "group_duration")
+	assert.Equal(t, 1, strings.Count(out, "group_duration"))
+
+	groups := data.Export()["root_group"].(map[string]interface{})["groups"].([]interface{})
+	require.Len(t, groups, 2)
+	assert.NotContains(t, groups[0], "duration")
+	assert.Equal(t, map[string]float64{
+		"avg": 1, "min": 0, "med": 1, "max": 2, "p(90)": 1.8, "p(95)": 1.9,
+	}, groups[1].(map[string]interface{})["duration"])
+}
+
+func TestSummarizeScenarios(t *testing.T) {
+	counter := stats.New("iterations", stats.Counter)
+	counter.Sink.Add(stats.Sample{Value: 4})
+	data := SummaryData{
+		Metrics: map[string]*stats.Metric{},
+		Time:    2 * time.Second,
+		Scenarios: map[string]map[string]*stats.Metric{
+			"checkout": {"iterations": counter},
+			"idle":     {},
+		},
+	}
+
+	var buf bytes.Buffer
+	Summarize(&buf, "", data)
+	assert.Contains(t, buf.String(), GroupPrefix+" scenario checkout\n")
+	assert.Contains(t, buf.String(), "iterations")
+	assert.NotContains(t, buf.String(), "scenario idle")
+
+	scenarios := data.Export()["scenarios"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"metrics": map[string]interface{}{
+			"iterations": map[string]interface{}{
+				"type":     "counter",
+				"contains": "default",
+				"values":   map[string]float64{"count": 4, "rate": 2},
+			},
+		},
+	}, scenarios["checkout"])
+	assert.Equal(t, map[string]interface{}{"metrics": map[string]interface{}{}}, scenarios["idle"])
+}